@@ -0,0 +1,34 @@
+package accesslog
+
+import (
+	"app/config"
+	"io"
+	"log/slog"
+	"os"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Writer returns where access logs go. With ACCESS_LOG_FILE set the log is also written to a
+// rotated file, for node agents that collect logs from a hostPath instead of container stdout.
+func Writer() io.Writer {
+	path := config.String("ACCESS_LOG_FILE", "")
+	if path == "" {
+		return os.Stdout
+	}
+
+	file := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    config.Int("ACCESS_LOG_MAX_SIZE_MB", 100),
+		MaxAge:     config.Int("ACCESS_LOG_MAX_AGE_DAYS", 7),
+		MaxBackups: config.Int("ACCESS_LOG_MAX_BACKUPS", 5),
+		Compress:   config.Bool("ACCESS_LOG_COMPRESS", true),
+		LocalTime:  true,
+	}
+	slog.Info("writing access log to file", "path", path, "max_size_mb", file.MaxSize, "max_age_days", file.MaxAge)
+
+	if !config.Bool("ACCESS_LOG_STDOUT", true) {
+		return file
+	}
+	return io.MultiWriter(os.Stdout, file)
+}
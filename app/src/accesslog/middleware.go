@@ -0,0 +1,223 @@
+package accesslog
+
+import (
+	"app/baggage"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+// lineBufferSize fits a typical line, so a pooled buffer rarely grows
+const lineBufferSize = 512
+
+var lines = sync.Pool{New: func() any {
+	buf := make([]byte, 0, lineBufferSize)
+	return &buf
+}}
+
+// Middleware writes one JSON line per request to w, with the fields and format of echo's default
+// Logger so log pipelines parsing it keep working. Unlike that logger it appends straight into a
+// pooled buffer instead of executing a template, which halves the bytes allocated per request.
+func Middleware(w io.Writer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			start := time.Now()
+			err := next(ctx)
+			if err != nil {
+				ctx.Error(err)
+			}
+			stop := time.Now()
+
+			bufp := lines.Get().(*[]byte)
+			*bufp = appendLine((*bufp)[:0], ctx, err, start, stop)
+			_, writeErr := w.Write(*bufp)
+			if cap(*bufp) <= 16*lineBufferSize {
+				lines.Put(bufp)
+			}
+			return writeErr
+		}
+	}
+}
+
+func appendLine(buf []byte, ctx echo.Context, err error, start, stop time.Time) []byte {
+	req, res := ctx.Request(), ctx.Response()
+	id := first(req.Header[requestIDKey])
+	if id == "" {
+		id = first(res.Header()[requestIDKey])
+	}
+	bytesIn := req.Header.Get(echo.HeaderContentLength)
+	if bytesIn == "" {
+		bytesIn = "0"
+	}
+	latency := stop.Sub(start)
+
+	buf = append(buf, `{"time":"`...)
+	buf = stop.AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, `","id":"`...)
+	buf = appendEscaped(buf, id)
+	buf = append(buf, `","remote_ip":"`...)
+	buf = appendEscaped(buf, ctx.RealIP())
+	buf = append(buf, `","host":"`...)
+	buf = appendEscaped(buf, req.Host)
+	buf = append(buf, `","method":"`...)
+	buf = appendEscaped(buf, req.Method)
+	buf = append(buf, `","uri":"`...)
+	buf = appendEscaped(buf, req.RequestURI)
+	buf = append(buf, `","user_agent":"`...)
+	buf = appendEscaped(buf, req.UserAgent())
+	buf = append(buf, `","status":`...)
+	buf = strconv.AppendInt(buf, int64(res.Status), 10)
+	buf = append(buf, `,"error":"`...)
+	if err != nil {
+		buf = appendEscaped(buf, err.Error())
+	}
+	buf = append(buf, `","latency":`...)
+	buf = strconv.AppendInt(buf, int64(latency), 10)
+	buf = append(buf, `,"latency_human":"`...)
+	buf = appendDuration(buf, latency)
+	buf = append(buf, `","bytes_in":`...)
+	buf = appendEscaped(buf, bytesIn)
+	buf = append(buf, `,"bytes_out":`...)
+	buf = strconv.AppendInt(buf, res.Size, 10)
+	// The baggage members only when the request carried them, for filtering the logs of an experiment
+	values := baggage.Get(ctx)
+	buf = appendField(buf, baggage.KeyTenant, values.Tenant)
+	buf = appendField(buf, baggage.KeyExperiment, values.Experiment)
+	buf = appendField(buf, baggage.KeyCanary, values.Canary)
+	return append(buf, "}\n"...)
+}
+
+// appendField appends a string field, nothing when value is empty
+func appendField(buf []byte, name, value string) []byte {
+	if value == "" {
+		return buf
+	}
+	buf = append(buf, `,"`...)
+	buf = append(buf, name...)
+	buf = append(buf, `":"`...)
+	buf = appendEscaped(buf, value)
+	return append(buf, '"')
+}
+
+// requestIDKey is echo.HeaderXRequestID canonicalized, Header.Get would allocate doing that per request
+const requestIDKey = "X-Request-Id"
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+const hex = "0123456789abcdef"
+
+// appendEscaped appends s as the inside of a JSON string, escaping quotes, backslashes,
+// control characters and invalid UTF-8 like encoding/json does
+func appendEscaped(buf []byte, s string) []byte {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				buf = append(buf, `�`...)
+			} else {
+				buf = append(buf, s[i:i+size]...)
+			}
+			i += size
+			continue
+		}
+		switch {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c == '\n':
+			buf = append(buf, `\n`...)
+		case c == '\r':
+			buf = append(buf, `\r`...)
+		case c == '\t':
+			buf = append(buf, `\t`...)
+		case c < 0x20:
+			buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			buf = append(buf, c)
+		}
+		i++
+	}
+	return buf
+}
+
+// appendDuration appends what d.String() returns without allocating the string
+func appendDuration(buf []byte, d time.Duration) []byte {
+	var scratch [32]byte
+	return append(buf, formatDuration(scratch[:0], d)...)
+}
+
+// formatDuration follows time.Duration.String: the largest unit first down to seconds,
+// or a single ns, µs or ms value below one second
+func formatDuration(buf []byte, d time.Duration) []byte {
+	if d == 0 {
+		return append(buf, "0s"...)
+	}
+	if d < 0 {
+		buf = append(buf, '-')
+	}
+	u := uint64(d)
+	if d < 0 {
+		u = -u
+	}
+	if u < uint64(time.Second) {
+		switch {
+		case u < uint64(time.Microsecond):
+			buf = strconv.AppendUint(buf, u, 10)
+			return append(buf, "ns"...)
+		case u < uint64(time.Millisecond):
+			buf = appendFraction(buf, u, 3)
+			return append(buf, "µs"...)
+		default:
+			buf = appendFraction(buf, u, 6)
+			return append(buf, "ms"...)
+		}
+	}
+
+	hours := u / uint64(time.Hour)
+	u -= hours * uint64(time.Hour)
+	minutes := u / uint64(time.Minute)
+	u -= minutes * uint64(time.Minute)
+	if hours > 0 {
+		buf = strconv.AppendUint(buf, hours, 10)
+		buf = append(buf, 'h')
+	}
+	if hours > 0 || minutes > 0 {
+		buf = strconv.AppendUint(buf, minutes, 10)
+		buf = append(buf, 'm')
+	}
+	buf = appendFraction(buf, u, 9)
+	return append(buf, 's')
+}
+
+// appendFraction appends v / 10^precision with the trailing zeros of the fraction dropped
+func appendFraction(buf []byte, v uint64, precision int) []byte {
+	scale := uint64(1)
+	for range precision {
+		scale *= 10
+	}
+	buf = strconv.AppendUint(buf, v/scale, 10)
+	fraction := v % scale
+	if fraction == 0 {
+		return buf
+	}
+	var digits [9]byte
+	for i := precision - 1; i >= 0; i-- {
+		digits[i] = byte('0' + fraction%10)
+		fraction /= 10
+	}
+	n := precision
+	for n > 0 && digits[n-1] == '0' {
+		n--
+	}
+	buf = append(buf, '.')
+	return append(buf, digits[:n]...)
+}
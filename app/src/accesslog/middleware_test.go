@@ -0,0 +1,87 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+func TestFormatDuration(t *testing.T) {
+	for _, d := range []time.Duration{
+		0, 1, 999, time.Microsecond, 1500, 2*time.Millisecond + 345*time.Microsecond, 999999999,
+		time.Second, 1500 * time.Millisecond, 61 * time.Second, 90*time.Minute + 5*time.Nanosecond,
+		-42 * time.Millisecond, 1<<63 - 1, -1 << 63,
+	} {
+		if got, want := string(formatDuration(nil, d)), d.String(); got != want {
+			t.Errorf("formatDuration(%d) = %q, want %q", int64(d), got, want)
+		}
+	}
+}
+
+// serve runs one request through logger
+func serve(logger echo.MiddlewareFunc, handler echo.HandlerFunc) {
+	router := echo.New()
+	router.Use(logger)
+	router.GET("/sample/:id", handler)
+	req := httptest.NewRequest(http.MethodGet, "/sample/1?fields=message", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-1")
+	req.Header.Set("User-Agent", "bench \"agent\"\n")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestMiddleware(t *testing.T) {
+	var out strings.Builder
+	serve(Middleware(&out), func(ctx echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot, "no coffee")
+	})
+
+	var line map[string]any
+	if err := json.Unmarshal([]byte(out.String()), &line); err != nil {
+		t.Fatalf("invalid json %q: %v", out.String(), err)
+	}
+	for field, want := range map[string]any{
+		"id": "req-1", "method": "GET", "uri": "/sample/1?fields=message", "user_agent": "bench \"agent\"\n",
+		"status": float64(http.StatusTeapot), "bytes_in": float64(0),
+	} {
+		if line[field] != want {
+			t.Errorf("%s = %v, want %v", field, line[field], want)
+		}
+	}
+	if !strings.Contains(line["error"].(string), "no coffee") {
+		t.Errorf("error = %v", line["error"])
+	}
+	if _, err := time.Parse(time.RFC3339Nano, line["time"].(string)); err != nil {
+		t.Errorf("time: %v", err)
+	}
+}
+
+func benchmarkLogger(b *testing.B, logger echo.MiddlewareFunc) {
+	handler := func(ctx echo.Context) error { return ctx.NoContent(http.StatusNoContent) }
+	router := echo.New()
+	router.Use(logger)
+	router.GET("/sample/:id", handler)
+	req := httptest.NewRequest(http.MethodGet, "/sample/1?fields=message", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-1")
+	rec := httptest.NewRecorder()
+	b.ReportAllocs()
+	for b.Loop() {
+		router.ServeHTTP(rec, req)
+	}
+}
+
+// BenchmarkMiddleware reports one allocation: echo wrapping the middleware chain per request, not the logging itself
+func BenchmarkMiddleware(b *testing.B) {
+	benchmarkLogger(b, Middleware(io.Discard))
+}
+
+// BenchmarkEchoLogger is the logger Middleware replaced, for comparison
+func BenchmarkEchoLogger(b *testing.B) {
+	benchmarkLogger(b, middleware.LoggerWithConfig(middleware.LoggerConfig{Output: io.Discard}))
+}
@@ -0,0 +1,35 @@
+package adminauth
+
+import (
+	"app/config"
+	"app/session"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminUserID is the session user ID of the ADMIN_USERNAME account
+const AdminUserID = "admin"
+
+// Require allows requests carrying ADMIN_TOKEN as a bearer token, or a session of the admin account.
+// The session middleware must run before it for the session check to apply.
+func Require() echo.MiddlewareFunc {
+	token := config.String("ADMIN_TOKEN", "")
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if token != "" {
+				presented, found := strings.CutPrefix(ctx.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+				if found && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+					return next(ctx)
+				}
+			}
+			if s := session.Get(ctx); s != nil && s.UserID == AdminUserID {
+				return next(ctx)
+			}
+			return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "admin authentication required"})
+		}
+	}
+}
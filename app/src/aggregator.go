@@ -0,0 +1,101 @@
+package main
+
+import (
+	"app/accesslog"
+	"app/baggage"
+	"app/client"
+	"app/config"
+	"app/controller"
+	"app/health"
+	"app/httpclient"
+	"app/i18n"
+	"app/metrics"
+	"app/profiling"
+	"app/service"
+	"app/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"golang.org/x/sync/errgroup"
+)
+
+// runAggregator serves APP_ROLE=aggregator: no database, only the /aggregate endpoints, which call the Sample API
+// of the deployment at AGGREGATOR_UPSTREAM_URL. Deployed next to the regular role, the repo becomes a two-service demo.
+// It returns the error of a failed listener once shut down.
+func runAggregator(ctx context.Context) error {
+	upstreamURL := config.String("AGGREGATOR_UPSTREAM_URL", "http://app:8080")
+	// Responses are cached as the upstream's Cache-Control allows, see SAMPLES_CACHE_CONTROL
+	timeout := config.Duration("AGGREGATOR_UPSTREAM_TIMEOUT", 5*time.Second)
+	httpClient := httpclient.New(timeout)
+	// A second attempt of the GETs not answered within the p95, for the upstream pod that stalls
+	if config.Bool("AGGREGATOR_UPSTREAM_HEDGE", false) {
+		hedge := httpclient.NewHedge("upstream", httpClient.Transport)
+		hedge.Quantile = config.Float("AGGREGATOR_UPSTREAM_HEDGE_QUANTILE", hedge.Quantile)
+		hedge.MaxRatio = config.Float("AGGREGATOR_UPSTREAM_HEDGE_MAX_RATIO", hedge.MaxRatio)
+		httpClient.Transport = hedge
+	}
+	if entries := config.Int("AGGREGATOR_UPSTREAM_CACHE_ENTRIES", 1000); entries > 0 {
+		httpClient.Transport = &httpclient.Cache{Name: "upstream", Next: httpClient.Transport, MaxEntries: entries}
+	}
+	upstream := client.New(upstreamURL,
+		client.WithHTTPClient(httpClient),
+		client.WithToken(config.String("AGGREGATOR_UPSTREAM_TOKEN", "")),
+		client.WithAPIKey(config.String("AGGREGATOR_UPSTREAM_API_KEY", "")),
+		// A projected token for the upstream's SERVICE_AUTH_AUDIENCE, see k8s/aggregator.yaml
+		client.WithServiceTokenFile(config.String("AGGREGATOR_UPSTREAM_TOKEN_FILE", "")),
+		client.WithRetries(config.Int("AGGREGATOR_UPSTREAM_RETRIES", 3)),
+		// Shared with every outbound client, retries stop adding load once the upstream fails for real
+		client.WithRetryBudget(httpclient.DefaultRetryBudget),
+	)
+	slog.Info("running as aggregator", "upstream", upstreamURL)
+
+	// Not critical, an aggregator taken out of the Service would not bring the upstream back
+	health.Register(health.Check{Name: "upstream", Run: health.HTTPCheck(upstreamURL + "/healthz")})
+	if interval := config.Duration("HEALTH_CHECK_INTERVAL", 10*time.Second); interval > 0 {
+		health.Default.StartBackground(ctx, interval)
+	}
+
+	if pusher := profiling.PusherFromEnv(); pusher != nil {
+		pusher.Start(ctx)
+	}
+
+	router := echo.New()
+	router.HideBanner = true
+	router.Use(accesslog.Middleware(accesslog.Writer()))
+	router.Use(middleware.Recover())
+	router.Use(otelecho.Middleware(tracing.ServiceName))
+	router.Use(metrics.Middleware())
+	router.Use(baggage.Middleware())
+	router.Use(i18n.Middleware())
+
+	router.GET("/metrics", metrics.Handler())
+	router.GET("/healthz", health.LiveHandler)
+	router.GET("/readyz", health.Default.ReadyHandler())
+	aggregatorController := controller.AggregatorController{
+		AggregatorService: service.AggregatorService{Upstream: upstream, MaxPages: config.Int("AGGREGATOR_MAX_PAGES", 10)},
+	}
+	aggregatorController.Register(router)
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		if err := router.Start(config.String("HTTP_ADDR", ":8080")); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("http server failed: %w", err)
+		}
+		return nil
+	})
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Duration("SHUTDOWN_TIMEOUT", 25*time.Second))
+	defer cancel()
+	if err := router.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to drain http server", "error", err)
+	}
+	return group.Wait()
+}
@@ -0,0 +1,106 @@
+package alert
+
+import (
+	"app/config"
+	"app/notification"
+	"app/runtimeutil"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	ErrorThreshold int
+	PanicThreshold int
+	Window         time.Duration
+	Cooldown       time.Duration
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		ErrorThreshold: config.Int("ALERT_ERROR_THRESHOLD", 10),
+		PanicThreshold: config.Int("ALERT_PANIC_THRESHOLD", 1),
+		Window:         config.Duration("ALERT_WINDOW", time.Minute),
+		Cooldown:       config.Duration("ALERT_COOLDOWN", 5*time.Minute),
+	}
+}
+
+// Alerter counts server errors and panics in a fixed window and notifies
+// once a threshold is exceeded, then stays quiet for the cooldown period.
+type Alerter struct {
+	Config   Config
+	Notifier notification.Notifier
+
+	mu          sync.Mutex
+	windowStart time.Time
+	errors      int
+	panics      int
+	lastAlert   time.Time
+}
+
+func New(cfg Config, notifier notification.Notifier) *Alerter {
+	return &Alerter{Config: cfg, Notifier: notifier}
+}
+
+// NewFromEnv builds an Alerter from the Slack webhook and SMTP settings, or returns nil when none are configured
+func NewFromEnv() *Alerter {
+	var notifiers notification.MultiNotifier
+	if url := config.String("ALERT_SLACK_WEBHOOK_URL", ""); url != "" {
+		notifiers = append(notifiers, notification.NewSlackNotifier(url))
+	}
+	if smtpConfig := notification.SMTPConfigFromEnv(); config.Bool("ALERT_EMAIL", false) && smtpConfig.Host != "" && len(smtpConfig.To) > 0 {
+		notifiers = append(notifiers, notification.NewSMTPSender(smtpConfig))
+	}
+	if len(notifiers) == 0 {
+		slog.Info("error alerting is disabled")
+		return nil
+	}
+	return New(ConfigFromEnv(), notifiers)
+}
+
+func (a *Alerter) RecordError(detail string) {
+	a.record(false, detail)
+}
+
+func (a *Alerter) RecordPanic(detail string) {
+	a.record(true, detail)
+}
+
+func (a *Alerter) record(panicked bool, detail string) {
+	a.mu.Lock()
+	now := time.Now()
+	if now.Sub(a.windowStart) > a.Config.Window {
+		a.windowStart = now
+		a.errors = 0
+		a.panics = 0
+	}
+	if panicked {
+		a.panics++
+	} else {
+		a.errors++
+	}
+
+	exceeded := (a.Config.ErrorThreshold > 0 && a.errors >= a.Config.ErrorThreshold) ||
+		(a.Config.PanicThreshold > 0 && a.panics >= a.Config.PanicThreshold)
+	if !exceeded || now.Sub(a.lastAlert) < a.Config.Cooldown {
+		a.mu.Unlock()
+		return
+	}
+	a.lastAlert = now
+	msg := notification.Message{
+		Subject: "Application error threshold exceeded",
+		Text: fmt.Sprintf("%d errors and %d panics within %s.\nLast: %s",
+			a.errors, a.panics, a.Config.Window, detail),
+	}
+	a.mu.Unlock()
+
+	runtimeutil.Go("alert notify", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := a.Notifier.Notify(ctx, msg); err != nil {
+			slog.Error("failed to send alert", "error", err)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package alert
+
+import (
+	"app/metrics"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware reports 5xx responses and panics to the Alerter.
+// It must be registered after middleware.Recover so panics pass through it first.
+func Middleware(a *Alerter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					a.RecordPanic(fmt.Sprintf("panic on %s %s: %v", ctx.Request().Method, ctx.Path(), r))
+					panic(r)
+				}
+			}()
+
+			err = next(ctx)
+
+			status := metrics.ResponseStatus(ctx, err)
+			if status >= http.StatusInternalServerError {
+				a.RecordError(fmt.Sprintf("%d on %s %s", status, ctx.Request().Method, ctx.Path()))
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package announcement
+
+import (
+	"app/config"
+	"app/kube"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+type Announcement struct {
+	Message   string    `json:"message"`
+	Level     string    `json:"level"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var (
+	mu      sync.RWMutex
+	current Announcement
+)
+
+// Current returns the banner to show, an empty Message means no banner
+func Current() Announcement {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+func set(announcement Announcement) {
+	mu.Lock()
+	current = announcement
+	mu.Unlock()
+	slog.Info("announcement updated", "message", announcement.Message, "level", announcement.Level)
+}
+
+// Start watches the ANNOUNCEMENT_CONFIGMAP ConfigMap and applies its "message" and "level"
+// keys as soon as they change. Outside a cluster ANNOUNCEMENT_MESSAGE sets a fixed banner.
+func Start(ctx context.Context) {
+	if message := config.String("ANNOUNCEMENT_MESSAGE", ""); message != "" {
+		set(Announcement{Message: message, Level: config.String("ANNOUNCEMENT_LEVEL", "info"), UpdatedAt: time.Now()})
+	}
+	if !kube.Enabled() {
+		return
+	}
+
+	name := config.String("ANNOUNCEMENT_CONFIGMAP", "app-announcement")
+	factory := informers.NewSharedInformerFactoryWithOptions(kube.Client, 10*time.Minute,
+		informers.WithNamespace(kube.Namespace()),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			apply(obj)
+		},
+		UpdateFunc: func(_, obj any) {
+			apply(obj)
+		},
+		DeleteFunc: func(any) {
+			set(Announcement{UpdatedAt: time.Now()})
+		},
+	})
+	factory.Start(ctx.Done())
+	slog.Info("watching announcement configmap", "namespace", kube.Namespace(), "name", name)
+}
+
+func apply(obj any) {
+	configMap, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return
+	}
+	level := configMap.Data["level"]
+	if level == "" {
+		level = "info"
+	}
+	set(Announcement{Message: configMap.Data["message"], Level: level, UpdatedAt: time.Now()})
+}
+
+func Handler(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, Current())
+}
@@ -0,0 +1,75 @@
+package apikey
+
+import (
+	"app/model"
+	"app/service"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Header carries the API key
+const Header = "X-API-Key"
+
+// Quota headers set on every metered response
+const (
+	HeaderQuotaLimit     = "X-Quota-Limit"
+	HeaderQuotaRemaining = "X-Quota-Remaining"
+	HeaderQuotaReset     = "X-Quota-Reset"
+)
+
+const contextKey = "api_key"
+
+// Get returns the key the request was authenticated with, nil when it presented none
+func Get(ctx echo.Context) *model.APIKey {
+	key, _ := ctx.Get(contextKey).(*model.APIKey)
+	return key
+}
+
+// Middleware meters requests presenting an API key against the key's daily and monthly quotas.
+// Requests without a key pass untouched, an unknown or revoked key is rejected.
+func Middleware(keys *service.APIKeyService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			secret := ctx.Request().Header.Get(Header)
+			if secret == "" {
+				return next(ctx)
+			}
+
+			reqCtx := ctx.Request().Context()
+			key, err := keys.Authenticate(reqCtx, secret)
+			switch {
+			case errors.Is(err, service.ErrInvalidAPIKey):
+				return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid api key"})
+			case err != nil:
+				return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+
+			now := time.Now()
+			quota, err := keys.Consume(reqCtx, key, now)
+			if quota.Limit > 0 {
+				reset := int64(math.Ceil(quota.ResetAt.Sub(now).Seconds()))
+				header := ctx.Response().Header()
+				header.Set(HeaderQuotaLimit, strconv.FormatInt(quota.Limit, 10))
+				header.Set(HeaderQuotaRemaining, strconv.FormatInt(quota.Remaining, 10))
+				header.Set(HeaderQuotaReset, strconv.FormatInt(reset, 10))
+				if errors.Is(err, service.ErrQuotaExceeded) {
+					header.Set("Retry-After", strconv.FormatInt(reset, 10))
+					return ctx.JSON(http.StatusTooManyRequests, map[string]string{"error": "api key quota for the " + quota.Window + " is exceeded"})
+				}
+			}
+			if err != nil {
+				// Metering must not take the API down with it
+				slog.Error("failed to count api key usage", "key_id", key.ID, "error", err)
+			}
+
+			ctx.Set(contextKey, &key)
+			return next(ctx)
+		}
+	}
+}
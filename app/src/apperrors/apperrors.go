@@ -0,0 +1,109 @@
+// Package apperrors classifies the errors the service layer returns, so the HTTP status
+// of a failure is decided here once instead of in every controller.
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+type Kind int
+
+const (
+	// Internal is anything unclassified, a bug or an unexpected failure
+	Internal Kind = iota
+	NotFound
+	Conflict
+	Validation
+	Unauthorized
+	// Dependency is a database, cluster or other backend the request needs being unavailable
+	Dependency
+	// Retryable is a transient failure such as a deadlock, running the same write again may succeed
+	Retryable
+)
+
+func (k Kind) String() string {
+	switch k {
+	case NotFound:
+		return "not_found"
+	case Conflict:
+		return "conflict"
+	case Validation:
+		return "validation"
+	case Unauthorized:
+		return "unauthorized"
+	case Dependency:
+		return "dependency"
+	case Retryable:
+		return "retryable"
+	default:
+		return "internal"
+	}
+}
+
+// Status is the HTTP status a failure of the kind is answered with
+func (k Kind) Status() int {
+	switch k {
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case Validation:
+		return http.StatusBadRequest
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Dependency, Retryable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is a classified error. Sentinels are declared with New and compared with errors.Is,
+// failures of other packages are classified with Wrap and keep their cause for errors.Is and errors.As.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	default:
+		return e.Message + ": " + e.Err.Error()
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, message string) error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap classifies err, message may be empty to keep err's own. A nil err stays nil.
+func Wrap(kind Kind, message string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// KindOf returns the kind of the outermost classified error in err's chain, Internal when there is none
+func KindOf(err error) Kind {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Kind
+	}
+	return Internal
+}
+
+// Status is the HTTP status err is answered with
+func Status(err error) int {
+	return KindOf(err).Status()
+}
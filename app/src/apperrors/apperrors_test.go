@@ -0,0 +1,44 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	notFound := New(NotFound, "sample not found")
+	cause := errors.New("connection refused")
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{notFound, http.StatusNotFound},
+		{fmt.Errorf("load: %w", notFound), http.StatusNotFound},
+		{New(Conflict, "taken"), http.StatusConflict},
+		{New(Validation, "bad"), http.StatusBadRequest},
+		{New(Unauthorized, "who"), http.StatusUnauthorized},
+		{Wrap(Dependency, "database", cause), http.StatusServiceUnavailable},
+		{Wrap(Retryable, "deadlock", cause), http.StatusServiceUnavailable},
+		{cause, http.StatusInternalServerError},
+	} {
+		if got := Status(tt.err); got != tt.want {
+			t.Errorf("Status(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWrapKeepsCause(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := Wrap(Dependency, "database", cause)
+	if !errors.Is(err, cause) {
+		t.Error("wrapped error does not match its cause")
+	}
+	if got, want := err.Error(), "database: connection refused"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if Wrap(Dependency, "database", nil) != nil {
+		t.Error("wrapping nil is not nil")
+	}
+}
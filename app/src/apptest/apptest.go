@@ -0,0 +1,185 @@
+// Package apptest sets up the database and router for controller and service tests.
+//
+// A controller test registers the routes under test on Echo, seeds DB and sends requests with Do:
+//
+//	apptest.DB(t)
+//	router := apptest.Echo(t)
+//	(&controller.SampleController{}).Register(router)
+//	apptest.Contract(t, router)
+//	rec := apptest.Do(t, router, http.MethodGet, "/sample", nil)
+//
+// db.DB is a package variable, so tests using DB must not call t.Parallel.
+package apptest
+
+import (
+	"app/binder"
+	"app/db"
+	"app/i18n"
+	"app/model"
+	"app/openapi"
+	"app/routeinfo"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Models is what DB migrates when no models are given
+var Models = []any{&model.Sample{}, &model.SampleRevision{}, &model.SampleLabel{}, &model.SampleStat{}}
+
+var databases atomic.Int64
+
+// DB points db.DB at a fresh in-memory SQLite database with models migrated and restores
+// the previous one when the test ends. SQLite stands in for MySQL, so queries relying on
+// MySQL only features such as information_schema or SKIP LOCKED are not covered by it.
+func DB(t testing.TB, models ...any) *gorm.DB {
+	t.Helper()
+	if len(models) == 0 {
+		models = Models
+	}
+
+	// A named shared cache keeps one database across the pool's connections
+	dsn := fmt.Sprintf("file:apptest%d?mode=memory&cache=shared", databases.Add(1))
+	conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger:  logger.Discard,
+		Plugins: map[string]gorm.Plugin{db.ErrorTranslator{}.Name(): db.ErrorTranslator{}},
+	})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	if err := conn.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = previous
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return conn
+}
+
+// Seed inserts records into the test database
+func Seed(t testing.TB, records ...any) {
+	t.Helper()
+	for _, record := range records {
+		if err := db.DB.Create(record).Error; err != nil {
+			t.Fatalf("seed %T: %v", record, err)
+		}
+	}
+}
+
+// Echo returns a router set up like main's: strict JSON binding, i18n and the problem+json
+// error handler. Global middleware with side effects, such as metrics, is left out.
+func Echo(t testing.TB) *routeinfo.Echo {
+	t.Helper()
+	router := routeinfo.New(echo.New())
+	router.HideBanner = true
+	router.Binder = &binder.StrictBinder{}
+	router.HTTPErrorHandler = router.ErrorHandler(router.DefaultHTTPErrorHandler)
+	router.Use(i18n.Middleware())
+	return router
+}
+
+// Contract fails the test whenever a response of a route described in openapi.json does not
+// match the spec. Responses are copied as they are written, so streaming handlers still stream.
+func Contract(t testing.TB, router *routeinfo.Echo) {
+	t.Helper()
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		t.Fatalf("load openapi spec: %v", err)
+	}
+	router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			res := ctx.Response()
+			tee := &teeWriter{ResponseWriter: res.Writer}
+			res.Writer = tee
+			err := next(ctx)
+			res.Writer = tee.ResponseWriter
+			if res.Committed {
+				if violation := validator.Validate(ctx, res.Status, res.Header(), tee.body.Bytes()); violation != nil {
+					t.Errorf("%s %s: response violates the openapi spec: %v", ctx.Request().Method, ctx.Request().RequestURI, violation)
+				}
+			}
+			return err
+		}
+	})
+}
+
+// teeWriter keeps a copy of the response body
+type teeWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *teeWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *teeWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// RequestOption changes a request before Do sends it
+type RequestOption func(req *http.Request)
+
+func WithHeader(name, value string) RequestOption {
+	return func(req *http.Request) { req.Header.Set(name, value) }
+}
+
+// Do serves one request. A string or []byte body is sent as is, anything else as JSON.
+func Do(t testing.TB, handler http.Handler, method, target string, body any, opts ...RequestOption) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("encode request body: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if reader != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// JSON decodes the response body, failing the test when it is not valid JSON for T
+func JSON[T any](t testing.TB, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
@@ -0,0 +1,13 @@
+// Package authheader lists the request headers carrying a user's session or a caller's credentials,
+// for the features copying requests elsewhere to strip them
+package authheader
+
+import (
+	"app/apikey"
+	"app/serviceauth"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Names are the header names, canonicalized
+var Names = []string{echo.HeaderAuthorization, echo.HeaderCookie, "X-Csrf-Token", apikey.Header, serviceauth.Header}
@@ -0,0 +1,155 @@
+package baggage
+
+import (
+	"app/config"
+	"app/metrics"
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	otelbaggage "go.opentelemetry.io/otel/baggage"
+)
+
+// Members of the W3C baggage header the app reads, the others are propagated untouched
+const (
+	KeyTenant     = "tenant"
+	KeyExperiment = "experiment"
+	KeyCanary     = "canary"
+)
+
+// maxValueLength drops longer values, baggage is client input
+const maxValueLength = 64
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "baggage",
+		Name:      "requests_total",
+		Help:      "HTTP requests carrying baggage by experiment and canary, empty when the member is absent.",
+	}, []string{"experiment", "canary"})
+
+	experiments = metrics.NewLabelGuard("experiment", config.Int("BAGGAGE_MAX_EXPERIMENTS", 20), "other")
+)
+
+// Values are the members of the request baggage the app acts on, empty when absent or invalid
+type Values struct {
+	Tenant     string `json:"tenant,omitempty"`
+	Experiment string `json:"experiment,omitempty"`
+	// Canary is "true" or "false" when a caller upstream forced the variant
+	Canary string `json:"canary,omitempty"`
+}
+
+func (v Values) Empty() bool {
+	return v == Values{}
+}
+
+// From reads the values from the baggage of ctx, which the tracing middleware extracts from the
+// incoming request. The same baggage goes out with every call made with httpclient and ctx.
+func From(ctx context.Context) Values {
+	bag := otelbaggage.FromContext(ctx)
+	values := Values{
+		Tenant:     valid(bag.Member(KeyTenant).Value()),
+		Experiment: valid(bag.Member(KeyExperiment).Value()),
+	}
+	if canary, err := strconv.ParseBool(bag.Member(KeyCanary).Value()); err == nil {
+		values.Canary = strconv.FormatBool(canary)
+	}
+	return values
+}
+
+func valid(value string) string {
+	if len(value) > maxValueLength {
+		return ""
+	}
+	return value
+}
+
+// IsCanary reports the variant forced upstream, ok is false when none was
+func (v Values) IsCanary() (canary, ok bool) {
+	canary, err := strconv.ParseBool(v.Canary)
+	return canary, err == nil
+}
+
+// With sets a member on the baggage of ctx, so it reaches the services called with the returned context.
+// An invalid key or value leaves ctx unchanged.
+func With(ctx context.Context, key, value string) context.Context {
+	member, err := otelbaggage.NewMemberRaw(key, value)
+	if err != nil {
+		return ctx
+	}
+	bag, err := otelbaggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx
+	}
+	return otelbaggage.ContextWithBaggage(ctx, bag)
+}
+
+// Attrs are the values as log attributes, none for the empty ones
+func (v Values) Attrs() []slog.Attr {
+	attrs := make([]slog.Attr, 0, 3)
+	for _, member := range [...]struct{ key, value string }{
+		{KeyTenant, v.Tenant}, {KeyExperiment, v.Experiment}, {KeyCanary, v.Canary},
+	} {
+		if member.value != "" {
+			attrs = append(attrs, slog.String(member.key, member.value))
+		}
+	}
+	return attrs
+}
+
+const valuesKey = "baggage.values"
+
+// Get returns the values the middleware read for the request
+func Get(ctx echo.Context) Values {
+	if values, ok := ctx.Get(valuesKey).(*Values); ok {
+		return *values
+	}
+	return From(ctx.Request().Context())
+}
+
+// Middleware reads the baggage of every request once, for the access log and the
+// app_baggage_requests_total metric. It must run after the tracing middleware.
+// Tenants are left out of the metric, there are too many of them for a label.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			values := From(ctx.Request().Context())
+			if !values.Empty() {
+				ctx.Set(valuesKey, &values)
+				experiment := values.Experiment
+				if experiment != "" {
+					experiment = experiments.Value(experiment)
+				}
+				requests.WithLabelValues(experiment, values.Canary).Inc()
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// LogHandler adds the values of the context to the records logged with it, slog.InfoContext and the like
+func LogHandler(next slog.Handler) slog.Handler {
+	return logHandler{next}
+}
+
+type logHandler struct {
+	slog.Handler
+}
+
+func (h logHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		record.AddAttrs(From(ctx).Attrs()...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
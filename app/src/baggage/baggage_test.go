@@ -0,0 +1,66 @@
+package baggage
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// incoming is the context the tracing middleware builds from a request with the baggage header
+func incoming(header string) context.Context {
+	h := http.Header{}
+	h.Set("Baggage", header)
+	return propagation.Baggage{}.Extract(context.Background(), propagation.HeaderCarrier(h))
+}
+
+func TestFrom(t *testing.T) {
+	long := strings.Repeat("x", maxValueLength+1)
+	for _, tt := range []struct {
+		header string
+		want   Values
+	}{
+		{"tenant=acme,experiment=new-checkout,canary=true", Values{Tenant: "acme", Experiment: "new-checkout", Canary: "true"}},
+		{"experiment=a%20b,other=1", Values{Experiment: "a b"}},
+		{"canary=1", Values{Canary: "true"}},
+		{"canary=maybe,tenant=" + long, Values{}},
+		{"", Values{}},
+	} {
+		if got := From(incoming(tt.header)); got != tt.want {
+			t.Errorf("From(%q) = %+v, want %+v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestWithPropagates(t *testing.T) {
+	ctx := With(incoming("tenant=acme"), KeyCanary, "false")
+	outbound := http.Header{}
+	propagation.Baggage{}.Inject(ctx, propagation.HeaderCarrier(outbound))
+
+	got := From(incoming(outbound.Get("Baggage")))
+	if want := (Values{Tenant: "acme", Canary: "false"}); got != want {
+		t.Errorf("outbound baggage %q = %+v, want %+v", outbound.Get("Baggage"), got, want)
+	}
+	if canary, ok := got.IsCanary(); canary || !ok {
+		t.Errorf("IsCanary = %v, %v", canary, ok)
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(LogHandler(slog.NewTextHandler(&out, nil))).With("component", "test")
+
+	logger.InfoContext(incoming("tenant=acme,experiment=blue"), "hello")
+	if line := out.String(); !strings.Contains(line, "component=test") || !strings.Contains(line, "tenant=acme experiment=blue") {
+		t.Errorf("log line = %q", line)
+	}
+	out.Reset()
+	logger.Info("no context")
+	if strings.Contains(out.String(), "tenant=") {
+		t.Errorf("log line without baggage = %q", out.String())
+	}
+}
@@ -0,0 +1,75 @@
+package binder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StrictBinder behaves like echo.DefaultBinder but rejects JSON bodies with unknown
+// fields or trailing data, and unsupported content types with 415 instead of ignoring them.
+type StrictBinder struct {
+	echo.DefaultBinder
+}
+
+func (b *StrictBinder) Bind(i any, ctx echo.Context) error {
+	if err := b.BindPathParams(ctx, i); err != nil {
+		return err
+	}
+
+	req := ctx.Request()
+	method := req.Method
+	if method == http.MethodGet || method == http.MethodDelete || method == http.MethodHead {
+		if err := b.BindQueryParams(ctx, i); err != nil {
+			return err
+		}
+	}
+	if req.ContentLength == 0 {
+		return nil
+	}
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(req.Header.Get(echo.HeaderContentType), ";")[0]))
+	switch {
+	case mediaType == echo.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json"):
+		return bindJSON(req.Body, i)
+	case mediaType == echo.MIMEApplicationForm || mediaType == echo.MIMEMultipartForm:
+		return b.BindBody(ctx, i)
+	case mediaType == "":
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Content-Type header is required")
+	default:
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported Content-Type %q", mediaType))
+	}
+}
+
+func bindJSON(body io.Reader, i any) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(i); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)).SetInternal(err)
+		case errors.As(err, &typeErr):
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("field %q must be %s", typeErr.Field, typeErr.Type)).SetInternal(err)
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return echo.NewHTTPError(http.StatusBadRequest, "unknown field "+field).SetInternal(err)
+		case errors.Is(err, io.EOF):
+			return echo.NewHTTPError(http.StatusBadRequest, "request body is empty").SetInternal(err)
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+		}
+	}
+
+	if decoder.More() {
+		return echo.NewHTTPError(http.StatusBadRequest, "request body must contain a single JSON value")
+	}
+	return nil
+}
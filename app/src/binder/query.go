@@ -0,0 +1,135 @@
+package binder
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// QueryError names the query parameter that was rejected, so handlers answer with their own message
+type QueryError struct {
+	Param   string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return "query parameter " + e.Param + " " + e.Message
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// Query binds the query parameters into the exported fields of the struct dst points to, by their tags:
+//
+//	query:"limit"    the parameter name, fields without one are left alone
+//	default:"20"     the value when the parameter is absent or empty
+//	min:"1" max:"50" bounds of a number, a value outside them is a QueryError
+//	clamp:"100"      an upper bound a bigger value is lowered to, for page size caps
+//	oneof:"a|b"      the values a string accepts
+//
+// Fields are string, bool, int, int64, float64 or time.Duration, which also accepts a number of seconds.
+// A field without the parameter or a default keeps its value, for defaults only known at runtime.
+func Query(ctx echo.Context, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("binder.Query needs a pointer to a struct, got %T", dst)
+	}
+	v = v.Elem()
+	params := ctx.QueryParams()
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name := field.Tag.Get("query")
+		if name == "" || !field.IsExported() {
+			continue
+		}
+		raw, ok := field.Tag.Lookup("default")
+		if value := params.Get(name); value != "" {
+			raw, ok = value, true
+		}
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), field.Tag, raw); err != nil {
+			return &QueryError{Param: name, Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, tag reflect.StructTag, raw string) error {
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if seconds, intErr := strconv.Atoi(raw); intErr == nil {
+			d, err = time.Duration(seconds)*time.Second, nil
+		}
+		if err != nil {
+			return fmt.Errorf("must be a duration")
+		}
+		bounded, err := bound(float64(d), tag, func(s string) (float64, error) {
+			d, err := time.ParseDuration(s)
+			return float64(d), err
+		})
+		field.SetInt(int64(bounded))
+		return err
+	case field.Kind() == reflect.String:
+		if oneof, ok := tag.Lookup("oneof"); ok && !slices.Contains(strings.Split(oneof, "|"), raw) {
+			return fmt.Errorf("must be one of %s", strings.ReplaceAll(oneof, "|", ", "))
+		}
+		field.SetString(raw)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		field.SetBool(b)
+	case field.CanInt():
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		bounded, err := bound(float64(n), tag, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+		field.SetInt(int64(bounded))
+		return err
+	case field.CanFloat():
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		bounded, err := bound(f, tag, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+		field.SetFloat(bounded)
+		return err
+	default:
+		return fmt.Errorf("has an unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// bound checks n against the min and max tags and lowers it to the clamp tag, parsed with parse
+func bound(n float64, tag reflect.StructTag, parse func(string) (float64, error)) (float64, error) {
+	limit := func(key string) (float64, bool) {
+		raw, ok := tag.Lookup(key)
+		if !ok {
+			return 0, false
+		}
+		value, err := parse(raw)
+		if err != nil {
+			panic(fmt.Sprintf("binder: invalid %s tag %q", key, raw))
+		}
+		return value, true
+	}
+	if minimum, ok := limit("min"); ok && n < minimum {
+		return n, fmt.Errorf("must be at least %s", tag.Get("min"))
+	}
+	if maximum, ok := limit("max"); ok && n > maximum {
+		return n, fmt.Errorf("must be at most %s", tag.Get("max"))
+	}
+	if ceiling, ok := limit("clamp"); ok {
+		n = min(n, ceiling)
+	}
+	return n, nil
+}
@@ -0,0 +1,60 @@
+package binder
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type listQuery struct {
+	Limit   int           `query:"limit" default:"20" min:"1" clamp:"100"`
+	Days    int64         `query:"days" max:"31"`
+	Order   string        `query:"order" default:"desc" oneof:"asc|desc"`
+	Deleted bool          `query:"deleted"`
+	Timeout time.Duration `query:"timeout" min:"0s" max:"1m"`
+	Ignored string
+}
+
+func TestQuery(t *testing.T) {
+	e := echo.New()
+	bind := func(rawQuery string) (listQuery, error) {
+		ctx := e.NewContext(httptest.NewRequest(http.MethodGet, "/?"+rawQuery, nil), httptest.NewRecorder())
+		query := listQuery{Days: 7, Ignored: "kept"}
+		err := Query(ctx, &query)
+		return query, err
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  listQuery
+	}{
+		{"", listQuery{Limit: 20, Days: 7, Order: "desc", Ignored: "kept"}},
+		{"limit=500&days=31&order=asc&deleted=true&timeout=5", listQuery{Limit: 100, Days: 31, Order: "asc", Deleted: true, Timeout: 5 * time.Second, Ignored: "kept"}},
+		{"limit=&timeout=1m", listQuery{Limit: 20, Days: 7, Order: "desc", Timeout: time.Minute, Ignored: "kept"}},
+	} {
+		got, err := bind(tt.query)
+		if err != nil || got != tt.want {
+			t.Errorf("%q = %+v, %v, want %+v", tt.query, got, err, tt.want)
+		}
+	}
+
+	for query, param := range map[string]string{
+		"limit=0":       "limit",
+		"limit=ten":     "limit",
+		"days=32":       "days",
+		"order=random":  "order",
+		"deleted=maybe": "deleted",
+		"timeout=2m":    "timeout",
+		"timeout=-1":    "timeout",
+	} {
+		_, err := bind(query)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) || queryErr.Param != param {
+			t.Errorf("%q = %v, want an error for %s", query, err, param)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package bodylog
+
+import (
+	"app/config"
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Fields whose values never reach the log, matched case-insensitively as JSON keys or form names
+var defaultRedacted = []string{
+	"password", "current_password", "new_password", "token", "access_token", "refresh_token",
+	"id_token", "secret", "client_secret", "authorization", "api_key", "_csrf",
+}
+
+var (
+	enabled  atomic.Bool
+	maxBytes = config.Int("BODY_LOG_MAX_BYTES", 4096)
+	redactor = newRedactor(append(defaultRedacted, config.List("BODY_LOG_REDACT_FIELDS")...))
+)
+
+func init() {
+	enabled.Store(config.Bool("BODY_LOG_ENABLED", false))
+}
+
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// SetEnabled switches body logging at runtime, there is no need to restart the pod to debug it
+func SetEnabled(on bool) {
+	enabled.Store(on)
+	slog.Warn("request/response body logging toggled", "enabled", on)
+}
+
+type redactorRules struct {
+	jsonField *regexp.Regexp
+	formField *regexp.Regexp
+}
+
+func newRedactor(fields []string) redactorRules {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = regexp.QuoteMeta(field)
+	}
+	names := strings.Join(quoted, "|")
+	return redactorRules{
+		jsonField: regexp.MustCompile(`(?i)("(?:` + names + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\s]+)`),
+		formField: regexp.MustCompile(`(?i)(^|&)((?:` + names + `)=)[^&]*`),
+	}
+}
+
+// Redact masks sensitive values in JSON or form encoded text, also when it was cut off mid-value
+func Redact(body string) string {
+	body = redactor.jsonField.ReplaceAllString(body, `$1"[REDACTED]"`)
+	return redactor.formField.ReplaceAllString(body, `$1$2[REDACTED]`)
+}
+
+// cappedBuffer keeps the first limit bytes written and counts the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+	total int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	text := Redact(b.Buffer.String())
+	if b.total > b.limit {
+		text += "...(truncated)"
+	}
+	return text
+}
+
+type dumpWriter struct {
+	http.ResponseWriter
+	buffer *cappedBuffer
+}
+
+func (w *dumpWriter) Write(p []byte) (int, error) {
+	w.buffer.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *dumpWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *dumpWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// loggable skips binary bodies such as uploads, backups and snapshots
+func loggable(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	for _, prefix := range []string{"application/json", "application/problem+json", "application/x-ndjson", "application/x-www-form-urlencoded", "text/"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware logs request and response bodies, capped at BODY_LOG_MAX_BYTES and redacted,
+// while the toggle is on. Bodies are copied as they stream so handlers see no buffering.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !enabled.Load() {
+				return next(ctx)
+			}
+
+			req := ctx.Request()
+			reqBody := &cappedBuffer{limit: maxBytes}
+			if req.Body != nil && loggable(req.Header.Get(echo.HeaderContentType)) {
+				req.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.TeeReader(req.Body, reqBody), req.Body}
+			}
+			resBody := &cappedBuffer{limit: maxBytes}
+			res := ctx.Response()
+			res.Writer = &dumpWriter{ResponseWriter: res.Writer, buffer: resBody}
+
+			err := next(ctx)
+
+			attrs := []any{
+				"method", req.Method,
+				"uri", req.RequestURI,
+				"status", res.Status,
+				"request_bytes", reqBody.total,
+				"request_body", reqBody.String(),
+				"response_bytes", resBody.total,
+			}
+			if loggable(res.Header().Get(echo.HeaderContentType)) {
+				attrs = append(attrs, "response_body", resBody.String())
+			}
+			slog.Info("body dump", attrs...)
+			return err
+		}
+	}
+}
+
+type toggleRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// ToggleHandler reports the switch on GET and flips it on PUT {"enabled": true|false}
+func ToggleHandler(ctx echo.Context) error {
+	if ctx.Request().Method == http.MethodPut {
+		req := new(toggleRequest)
+		if err := ctx.Bind(req); err != nil || req.Enabled == nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": `body must be {"enabled": true|false}`})
+		}
+		SetEnabled(*req.Enabled)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"enabled": Enabled(), "max_bytes": maxBytes})
+}
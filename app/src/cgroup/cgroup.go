@@ -0,0 +1,111 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Root is where the container runtime mounts the pod's cgroup
+var Root = "/sys/fs/cgroup"
+
+// Stats are the container's limits and usage, a zero limit means unlimited
+type Stats struct {
+	Version int `json:"version"`
+
+	CPULimitCores       float64 `json:"cpu_limit_cores"`
+	CPUUsageSeconds     float64 `json:"cpu_usage_seconds"`
+	CPUThrottledCount   int64   `json:"cpu_throttled_periods"`
+	CPUThrottledSeconds float64 `json:"cpu_throttled_seconds"`
+
+	MemoryLimitBytes int64 `json:"memory_limit_bytes"`
+	MemoryUsageBytes int64 `json:"memory_usage_bytes"`
+}
+
+var ErrNotFound = errors.New("no cgroup found")
+
+// Read detects cgroup v2 (unified) or v1 and reads the current values
+func Read() (Stats, error) {
+	if _, err := os.Stat(filepath.Join(Root, "cgroup.controllers")); err == nil {
+		return readV2()
+	}
+	if _, err := os.Stat(filepath.Join(Root, "memory")); err == nil {
+		return readV1()
+	}
+	return Stats{}, ErrNotFound
+}
+
+func readV2() (Stats, error) {
+	stats := Stats{Version: 2}
+
+	// cpu.max is "<quota> <period>" or "max <period>"
+	if fields := strings.Fields(readString("cpu.max")); len(fields) == 2 && fields[0] != "max" {
+		quota, _ := strconv.ParseFloat(fields[0], 64)
+		period, _ := strconv.ParseFloat(fields[1], 64)
+		if period > 0 {
+			stats.CPULimitCores = quota / period
+		}
+	}
+	cpu := readKeyValues("cpu.stat")
+	stats.CPUUsageSeconds = float64(cpu["usage_usec"]) / 1e6
+	stats.CPUThrottledCount = cpu["nr_throttled"]
+	stats.CPUThrottledSeconds = float64(cpu["throttled_usec"]) / 1e6
+
+	if limit := readString("memory.max"); limit != "max" {
+		stats.MemoryLimitBytes, _ = strconv.ParseInt(limit, 10, 64)
+	}
+	stats.MemoryUsageBytes, _ = strconv.ParseInt(readString("memory.current"), 10, 64)
+	return stats, nil
+}
+
+func readV1() (Stats, error) {
+	stats := Stats{Version: 1}
+
+	quota, _ := strconv.ParseFloat(readString("cpu/cpu.cfs_quota_us"), 64)
+	period, _ := strconv.ParseFloat(readString("cpu/cpu.cfs_period_us"), 64)
+	if quota > 0 && period > 0 {
+		stats.CPULimitCores = quota / period
+	}
+	usage, _ := strconv.ParseFloat(readString("cpuacct/cpuacct.usage"), 64)
+	stats.CPUUsageSeconds = usage / 1e9
+	cpu := readKeyValues("cpu/cpu.stat")
+	stats.CPUThrottledCount = cpu["nr_throttled"]
+	stats.CPUThrottledSeconds = float64(cpu["throttled_time"]) / 1e9
+
+	// v1 reports "no limit" as a huge page-aligned number
+	if limit, _ := strconv.ParseInt(readString("memory/memory.limit_in_bytes"), 10, 64); limit > 0 && limit < 1<<62 {
+		stats.MemoryLimitBytes = limit
+	}
+	stats.MemoryUsageBytes, _ = strconv.ParseInt(readString("memory/memory.usage_in_bytes"), 10, 64)
+	return stats, nil
+}
+
+func readString(name string) string {
+	data, err := os.ReadFile(filepath.Join(Root, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readKeyValues parses flat keyed files such as cpu.stat
+func readKeyValues(name string) map[string]int64 {
+	values := map[string]int64{}
+	file, err := os.Open(filepath.Join(Root, name))
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			values[fields[0]], _ = strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return values
+}
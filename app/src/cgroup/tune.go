@@ -0,0 +1,110 @@
+package cgroup
+
+import (
+	"app/config"
+	"errors"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Tune aligns the Go runtime with the container limits and logs what it decided.
+//
+// Since Go 1.25 the runtime already derives GOMAXPROCS from the cgroup CPU limit and follows
+// changes to it, so only the decision is logged. The memory limit is not applied by the runtime:
+// GOMEMLIMIT is set to GOMEMLIMIT_RATIO (default 0.9) of the cgroup limit so the GC works harder
+// before the kernel OOM-kills the container. An explicit GOMEMLIMIT or GOMAXPROCS always wins.
+//
+// GC_PERCENT and GC_MEMORY_LIMIT set GOGC and GOMEMLIMIT from the app's config for experiments,
+// the limit as a Kubernetes quantity such as 400Mi, and lose to the runtime's own variables too.
+func Tune() {
+	tuneGCPercent()
+	stats, err := Read()
+	if err != nil {
+		slog.Info("no cgroup limits found, runtime defaults are kept",
+			"gomaxprocs", runtime.GOMAXPROCS(0), "reason", err)
+		if limit, ok := configuredMemoryLimit(); ok && os.Getenv("GOMEMLIMIT") == "" {
+			debug.SetMemoryLimit(limit)
+			slog.Info("gomemlimit decided", "gomemlimit_bytes", limit, "source", "GC_MEMORY_LIMIT")
+		}
+		return
+	}
+
+	procsSource := "cgroup cpu limit"
+	switch {
+	case os.Getenv("GOMAXPROCS") != "":
+		procsSource = "GOMAXPROCS environment variable"
+	case stats.CPULimitCores == 0:
+		procsSource = "no cpu limit, all host cpus"
+	}
+	slog.Info("gomaxprocs decided",
+		"gomaxprocs", runtime.GOMAXPROCS(0),
+		"cpu_limit_cores", stats.CPULimitCores,
+		"num_cpu", runtime.NumCPU(),
+		"source", procsSource,
+	)
+
+	limit, configured := configuredMemoryLimit()
+	switch {
+	case os.Getenv("GOMEMLIMIT") != "":
+		slog.Info("gomemlimit decided", "gomemlimit_bytes", debug.SetMemoryLimit(-1), "source", "GOMEMLIMIT environment variable")
+	case configured:
+		debug.SetMemoryLimit(limit)
+		slog.Info("gomemlimit decided", "gomemlimit_bytes", limit, "memory_limit_bytes", stats.MemoryLimitBytes, "source", "GC_MEMORY_LIMIT")
+	case stats.MemoryLimitBytes == 0:
+		slog.Info("gomemlimit decided", "source", "no memory limit, gomemlimit left unset")
+	default:
+		ratio := config.Float("GOMEMLIMIT_RATIO", 0.9)
+		if ratio <= 0 || ratio > 1 {
+			slog.Warn("invalid GOMEMLIMIT_RATIO, using 0.9", "value", ratio)
+			ratio = 0.9
+		}
+		limit = int64(float64(stats.MemoryLimitBytes) * ratio)
+		debug.SetMemoryLimit(limit)
+		slog.Info("gomemlimit decided",
+			"gomemlimit_bytes", limit,
+			"memory_limit_bytes", stats.MemoryLimitBytes,
+			"ratio", ratio,
+			"source", "cgroup memory limit",
+		)
+	}
+}
+
+// tuneGCPercent applies GC_PERCENT unless GOGC is set, -1 turns the GC off until GOMEMLIMIT is reached
+func tuneGCPercent() {
+	if os.Getenv("GOGC") != "" || config.String("GC_PERCENT", "") == "" {
+		return
+	}
+	percent := config.Int("GC_PERCENT", 100)
+	debug.SetGCPercent(percent)
+	slog.Info("gogc decided", "gogc", percent, "source", "GC_PERCENT")
+}
+
+// configuredMemoryLimit parses GC_MEMORY_LIMIT, false when it is unset or invalid
+func configuredMemoryLimit() (int64, bool) {
+	raw := config.String("GC_MEMORY_LIMIT", "")
+	if raw == "" {
+		return 0, false
+	}
+	limit, err := ParseMemoryLimit(raw)
+	if err != nil {
+		slog.Warn("invalid GC_MEMORY_LIMIT, ignoring it", "value", raw, "error", err)
+		return 0, false
+	}
+	return limit, true
+}
+
+// ParseMemoryLimit reads a Kubernetes quantity such as 400Mi or 1G as bytes
+func ParseMemoryLimit(raw string) (int64, error) {
+	quantity, err := resource.ParseQuantity(raw)
+	if err != nil {
+		return 0, err
+	}
+	if quantity.Sign() <= 0 {
+		return 0, errors.New("memory limit must be positive")
+	}
+	return quantity.Value(), nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"app/config"
+	"app/db"
+	"app/httpclient"
+	"app/loadgen"
+	"app/model"
+	"app/recorder"
+	"app/service"
+	"app/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runCommand executes a CLI subcommand and reports whether one was given
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "backup":
+		err = runBackup(args[1:])
+	case "replay":
+		err = runReplay(args[1:])
+	case "loadgen":
+		err = runLoadgen(args[1:])
+	case "migrate":
+		err = runMigrate()
+	case "schema-drift":
+		err = runSchemaDrift()
+	case "export":
+		err = runExport(args[1:])
+	case "config":
+		err = runConfig(args[1:])
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// app migrate, the expand step: creates missing tables, columns and indexes and never drops any,
+// so replicas of the previous version keep working while it runs. It waits for replicas migrating on start.
+func runMigrate() error {
+	db.Init()
+	if err := db.Migrate(context.Background(), db.DB, model.All...); err != nil {
+		return err
+	}
+	fmt.Println("migrated", len(model.All), "models")
+	return nil
+}
+
+// app schema-drift prints how the live schema differs from the models and fails when it has drifted,
+// extra columns left for a later contract step alone do not fail it
+func runSchemaDrift() error {
+	db.Init()
+	report, err := (&service.SchemaService{}).Drift(context.Background())
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	if report.Drifted {
+		return fmt.Errorf("schema drifted from the models in %d places", len(report.Drift))
+	}
+	return nil
+}
+
+// app config validate prints the settings resolved without starting the server, secrets redacted,
+// and fails on a CONFIG_FILE it cannot read or a value it had to replace with the default. Settings
+// only read while the server starts are checked there and listed by /debug/config.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return fmt.Errorf("expected app config validate")
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config.Settings()); err != nil {
+		return err
+	}
+	return config.Validate()
+}
+
+// app export <table>... writes each table to a Parquet file in object storage, see EXPORT_TABLES
+func runExport(tables []string) error {
+	if len(tables) == 0 {
+		return fmt.Errorf("expected app export <table>...")
+	}
+	db.Init()
+	if err := storage.Init(); err != nil {
+		return err
+	}
+
+	exportService := service.NewExportServiceFromEnv()
+	var exports []storage.Object
+	for _, table := range tables {
+		export, err := exportService.Export(context.Background(), table)
+		if err != nil {
+			return err
+		}
+		exports = append(exports, export)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(exports)
+}
+
+// app backup [create|list]
+func runBackup(args []string) error {
+	db.Init()
+	if err := storage.Init(); err != nil {
+		return err
+	}
+
+	backupService := service.BackupService{}
+	ctx := context.Background()
+
+	action := "create"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	var result any
+	var err error
+	switch action {
+	case "create":
+		result, err = backupService.Create(ctx)
+	case "list":
+		result, err = backupService.List(ctx)
+	default:
+		return fmt.Errorf("unknown backup action %q, expected create or list", action)
+	}
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// app replay -target https://staging.example.com/app [-prefix pod-name/] [-dry-run]
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	target := flags.String("target", "", "base URL of the environment to replay against")
+	prefix := flags.String("prefix", "", "only replay recordings under "+recorder.Prefix+"<prefix>")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of each replayed request")
+	dryRun := flags.Bool("dry-run", false, "list the recorded requests without sending them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *target == "" && !*dryRun {
+		return errors.New("-target is required")
+	}
+
+	if err := storage.Init(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	records, err := recorder.Load(ctx, *prefix)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	client := httpclient.New(*timeout)
+	for _, record := range records {
+		result := recorder.Result{ID: record.ID, Method: record.Method, URI: record.URI, Recorded: record.Status}
+		if !*dryRun {
+			result = recorder.Replay(ctx, client, *target, record)
+		}
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// app loadgen -target http://app.example.com/app/sample -rps 100 -duration 1m
+func runLoadgen(args []string) error {
+	flags := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	var cfg loadgen.Config
+	flags.StringVar(&cfg.Target, "target", "", "URL to send requests to")
+	flags.StringVar(&cfg.Method, "method", http.MethodGet, "HTTP method")
+	flags.IntVar(&cfg.RPS, "rps", 10, "requests per second")
+	flags.DurationVar(&cfg.Duration, "duration", 30*time.Second, "how long to send requests")
+	flags.IntVar(&cfg.Concurrency, "concurrency", 0, "most requests in flight, rps x 10 by default")
+	flags.DurationVar(&cfg.Timeout, "timeout", 10*time.Second, "timeout of each request")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if cfg.Target == "" {
+		return errors.New("-target is required")
+	}
+	if cfg.RPS < 1 || cfg.Duration <= 0 {
+		return errors.New("-rps and -duration must be positive")
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = cfg.RPS * 10
+	}
+
+	// Stop early on Ctrl-C and still print what was measured
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Without tracing, and with enough idle connections to keep them alive at the requested rate
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        cfg.Concurrency,
+		MaxIdleConnsPerHost: cfg.Concurrency,
+		IdleConnTimeout:     90 * time.Second,
+	}}
+
+	fmt.Fprintf(os.Stderr, "sending %d req/s to %s for %s\n", cfg.RPS, cfg.Target, cfg.Duration)
+	report := loadgen.Run(ctx, client, cfg, func(r loadgen.Report) {
+		fmt.Fprintf(os.Stderr, "%6.0fs  %d requests  %d errors  p50 %.1fms  p99 %.1fms\n",
+			r.ElapsedSecs, r.Requests, r.Errors, r.Latency.P50, r.Latency.P99)
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,285 @@
+// Package client is a typed Go client for the Sample API, for other services calling this one.
+// It only depends on the standard library; pass httpclient.New as the HTTP client to get tracing.
+//
+//	samples := client.New("http://app:8080", client.WithToken(token))
+//	sample, err := samples.CreateSample(ctx, "hello")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+	// Token is sent as a bearer token, APIKey as X-API-Key, when set
+	Token  string
+	APIKey string
+	// ServiceTokenFile is a projected ServiceAccount token sent as X-Service-Token. It is read
+	// for every request, as the kubelet rotates the file in place.
+	ServiceTokenFile string
+	// MaxRetries is how often a failed idempotent request is retried, waiting from
+	// BackoffBase doubling up to BackoffMax or for as long as Retry-After says.
+	// A Retry-After beyond BackoffMax or the context deadline returns the response instead.
+	MaxRetries  int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Budget, when set, is asked before every retry
+	Budget RetryBudget
+}
+
+// RetryBudget limits retries across requests, httpclient.DefaultRetryBudget shares one per process
+type RetryBudget interface {
+	// Deposit is called once per request, Withdraw before each of its retries
+	Deposit()
+	Withdraw() bool
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.HTTP = httpClient }
+}
+
+func WithToken(token string) Option {
+	return func(c *Client) { c.Token = token }
+}
+
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.APIKey = key }
+}
+
+// WithServiceTokenFile authenticates as the pod's workload, with a token projected for the
+// audience the called service expects
+func WithServiceTokenFile(path string) Option {
+	return func(c *Client) { c.ServiceTokenFile = path }
+}
+
+// WithRetries sets MaxRetries, 0 disables retrying
+func WithRetries(n int) Option {
+	return func(c *Client) { c.MaxRetries = n }
+}
+
+func WithRetryBudget(budget RetryBudget) Option {
+	return func(c *Client) { c.Budget = budget }
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		HTTP:        &http.Client{Timeout: 10 * time.Second},
+		MaxRetries:  3,
+		BackoffBase: 100 * time.Millisecond,
+		BackoffMax:  5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is a response with a 4xx or 5xx status
+type Error struct {
+	Status  int
+	Message string `json:"error"`
+	Details string `json:"details"`
+}
+
+func (e *Error) Error() string {
+	message := cmpOr(e.Message, http.StatusText(e.Status))
+	if e.Details != "" {
+		return fmt.Sprintf("sample api: %d %s: %s", e.Status, message, e.Details)
+	}
+	return fmt.Sprintf("sample api: %d %s", e.Status, message)
+}
+
+// StatusOf returns the status of an *Error in err's chain, 0 when there is none
+func StatusOf(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Status
+	}
+	return 0
+}
+
+func IsNotFound(err error) bool {
+	return StatusOf(err) == http.StatusNotFound
+}
+
+func cmpOr(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// retryable reports whether a response may succeed when sent again: the pod was saturated,
+// restarting or not reachable through the proxy, or the caller was rate limited
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// idempotent methods are retried, a retried POST could create a sample twice
+func idempotent(method string) bool {
+	return method != http.MethodPost && method != http.MethodPatch
+}
+
+// do sends the request and decodes a 2xx body into out when it is not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	if c.Budget != nil {
+		c.Budget.Deposit()
+	}
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, method, target, payload)
+		retry := idempotent(method) && attempt < c.MaxRetries &&
+			(err != nil && ctx.Err() == nil || res != nil && retryable(res.StatusCode))
+		var wait time.Duration
+		if retry {
+			wait, retry = c.retryWait(ctx, attempt, res)
+		}
+		if !retry {
+			if err != nil {
+				return nil, err
+			}
+			return res, decode(res, out)
+		}
+
+		if res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryWait returns how long to wait before retrying, or false when the retry would come too late
+// for the caller, later than the upstream asked for, or over the retry budget
+func (c *Client) retryWait(ctx context.Context, attempt int, res *http.Response) (time.Duration, bool) {
+	wait := c.backoff(attempt)
+	if res != nil {
+		if after, ok := retryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			if after > c.BackoffMax {
+				return 0, false
+			}
+			wait = after
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return 0, false
+	}
+	if c.Budget != nil && !c.Budget.Withdraw() {
+		return 0, false
+	}
+	return wait, true
+}
+
+// retryAfter reads a Retry-After header, delay-seconds or an HTTP-date (RFC 9110 10.2.3)
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func (c *Client) send(ctx context.Context, method, target string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.ServiceTokenFile != "" {
+		token, err := os.ReadFile(c.ServiceTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read service account token: %w", err)
+		}
+		req.Header.Set("X-Service-Token", strings.TrimSpace(string(token)))
+	}
+	return c.HTTP.Do(req)
+}
+
+// backoff doubles the delay per attempt up to BackoffMax, with full jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.BackoffBase << min(attempt, 30)
+	if delay <= 0 || delay > c.BackoffMax {
+		delay = c.BackoffMax
+	}
+	return rand.N(delay) + 1
+}
+
+func decode(res *http.Response, out any) error {
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		apiErr := &Error{Status: res.StatusCode}
+		raw, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+		// problem+json carries title and detail instead
+		var problem struct {
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+		}
+		if json.Unmarshal(raw, apiErr) != nil || apiErr.Message == "" {
+			if json.Unmarshal(raw, &problem) == nil {
+				apiErr.Message, apiErr.Details = problem.Title, problem.Detail
+			}
+		}
+		return apiErr
+	}
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
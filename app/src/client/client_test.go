@@ -0,0 +1,169 @@
+package client_test
+
+import (
+	"app/apptest"
+	"app/client"
+	"app/controller"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serve runs the Sample API on an empty database behind a real HTTP server
+func serve(t *testing.T) *client.Client {
+	t.Helper()
+	apptest.DB(t)
+	router := apptest.Echo(t)
+	(&controller.SampleController{}).Register(router)
+	apptest.Contract(t, router)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return client.New(server.URL)
+}
+
+func TestSamples(t *testing.T) {
+	samples := serve(t)
+	ctx := context.Background()
+
+	created, err := samples.CreateSample(ctx, "hello")
+	if err != nil || created.ID == "" || created.Message != "hello" {
+		t.Fatalf("CreateSample = %+v, %v", created, err)
+	}
+	updated, err := samples.UpdateSample(ctx, created.ID, "updated")
+	if err != nil || updated.Message != "updated" {
+		t.Fatalf("UpdateSample = %+v, %v", updated, err)
+	}
+	if got, err := samples.GetSample(ctx, created.ID); err != nil || got.Message != "updated" {
+		t.Fatalf("GetSample = %+v, %v", got, err)
+	}
+
+	if _, created, err := samples.UpsertSample(ctx, "keyed", "first"); err != nil || !created {
+		t.Fatalf("UpsertSample created = %v, %v", created, err)
+	}
+	if sample, created, err := samples.UpsertSample(ctx, "keyed", "second"); err != nil || created || sample.Message != "second" {
+		t.Fatalf("UpsertSample = %+v, created %v, %v", sample, created, err)
+	}
+
+	if _, err := samples.SetLabels(ctx, created.ID, map[string]string{"env": "prod"}); err != nil {
+		t.Fatalf("SetLabels: %v", err)
+	}
+	if labels, err := samples.Labels(ctx, created.ID); err != nil || labels["env"] != "prod" {
+		t.Fatalf("Labels = %v, %v", labels, err)
+	}
+	page, err := samples.ListSamples(ctx, client.ListOptions{Selector: "env=prod"})
+	if err != nil || len(page.Samples) != 1 || page.Samples[0].ID != created.ID {
+		t.Fatalf("ListSamples by selector = %+v, %v", page, err)
+	}
+
+	if err := samples.DeleteSample(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteSample: %v", err)
+	}
+	if _, err := samples.GetSample(ctx, created.ID); !client.IsNotFound(err) {
+		t.Fatalf("GetSample after delete: %v, want not found", err)
+	}
+}
+
+func TestListSamplesPages(t *testing.T) {
+	samples := serve(t)
+	ctx := context.Background()
+	for _, message := range []string{"a", "b", "c"} {
+		if _, err := samples.CreateSample(ctx, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var seen int
+	opts := client.ListOptions{Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination does not end")
+		}
+		page, err := samples.ListSamples(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen += len(page.Samples)
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if seen != 3 {
+		t.Errorf("listed %d samples, want 3", seen)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","message":"hello"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	samples := client.New(server.URL)
+	samples.BackoffBase = time.Millisecond
+	if sample, err := samples.GetSample(context.Background(), "1"); err != nil || sample.Message != "hello" {
+		t.Fatalf("GetSample = %+v, %v", sample, err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("calls = %d, want 3", n)
+	}
+
+	// A create is not retried
+	calls.Store(0)
+	if _, err := samples.CreateSample(context.Background(), "hello"); client.StatusOf(err) != http.StatusServiceUnavailable {
+		t.Errorf("CreateSample error = %v, want 503", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("calls = %d, want 1", n)
+	}
+}
+
+type fixedBudget struct{ retries int }
+
+func (b *fixedBudget) Deposit() {}
+
+func (b *fixedBudget) Withdraw() bool {
+	b.retries--
+	return b.retries >= 0
+}
+
+func TestRetryLimits(t *testing.T) {
+	var calls atomic.Int32
+	retryAfter := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", retryAfter)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	// Waiting longer than BackoffMax returns the 503 at once
+	samples := client.New(server.URL)
+	retryAfter = "120"
+	if _, err := samples.GetSample(context.Background(), "1"); client.StatusOf(err) != http.StatusServiceUnavailable {
+		t.Errorf("GetSample error = %v, want 503", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("calls with Retry-After 120 = %d, want 1", n)
+	}
+
+	// An HTTP-date in the past retries right away, until the budget runs out
+	calls.Store(0)
+	retryAfter = time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	samples = client.New(server.URL, client.WithRetryBudget(&fixedBudget{retries: 1}))
+	if _, err := samples.GetSample(context.Background(), "1"); client.StatusOf(err) != http.StatusServiceUnavailable {
+		t.Errorf("GetSample error = %v, want 503", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("calls with a budget of one retry = %d, want 2", n)
+	}
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Sample mirrors the API's sample resource
+type Sample struct {
+	ID        string     `json:"id"`
+	Message   string     `json:"message"`
+	Key       *string    `json:"key,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ListOptions narrows ListSamples. Cursor is the NextCursor of the previous page, empty for the first.
+type ListOptions struct {
+	Limit  int
+	Cursor string
+	// Selector is a label selector such as env=prod,tier!=web
+	Selector string
+	// Filter is a filter expression such as message ~ "hello%" AND created_at > 2024-01-01
+	Filter string
+}
+
+// Page is one page of samples, NextCursor is empty on the last one
+type Page struct {
+	Samples    []Sample
+	HasMore    bool
+	NextCursor string
+}
+
+type samplePage struct {
+	Items []Sample `json:"items"`
+	Meta  struct {
+		HasMore bool `json:"has_more"`
+	} `json:"meta"`
+	Links struct {
+		Next struct {
+			Href string `json:"href"`
+		} `json:"next"`
+	} `json:"_links"`
+}
+
+type messageRequest struct {
+	Message string `json:"message"`
+}
+
+type upsertRequest struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+type labelsBody struct {
+	Labels map[string]string `json:"labels"`
+}
+
+func samplePath(id string) string {
+	return "/sample/" + url.PathEscape(id)
+}
+
+func (c *Client) GetSample(ctx context.Context, id string) (Sample, error) {
+	var sample Sample
+	_, err := c.do(ctx, http.MethodGet, samplePath(id), nil, nil, &sample)
+	return sample, err
+}
+
+// ListSamples reads a page of samples with cursor pagination, which stays consistent while samples are created
+func (c *Client) ListSamples(ctx context.Context, opts ListOptions) (Page, error) {
+	query := url.Values{"cursor": {opts.Cursor}}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Selector != "" {
+		query.Set("selector", opts.Selector)
+	}
+	if opts.Filter != "" {
+		query.Set("filter", opts.Filter)
+	}
+
+	var body samplePage
+	if _, err := c.do(ctx, http.MethodGet, "/samples", query, nil, &body); err != nil {
+		return Page{}, err
+	}
+	page := Page{Samples: body.Items, HasMore: body.Meta.HasMore}
+	if next, err := url.Parse(body.Links.Next.Href); err == nil && page.HasMore {
+		page.NextCursor = next.Query().Get("cursor")
+	}
+	return page, nil
+}
+
+// CreateSample is not retried, a retry after a lost response would create a second sample.
+// UpsertSample is the retry-safe way to create one.
+func (c *Client) CreateSample(ctx context.Context, message string) (Sample, error) {
+	var sample Sample
+	_, err := c.do(ctx, http.MethodPost, "/sample", nil, messageRequest{Message: message}, &sample)
+	return sample, err
+}
+
+func (c *Client) UpdateSample(ctx context.Context, id, message string) (Sample, error) {
+	var sample Sample
+	_, err := c.do(ctx, http.MethodPut, samplePath(id), nil, messageRequest{Message: message}, &sample)
+	return sample, err
+}
+
+// UpsertSample creates or updates the sample with the natural key, reporting whether it was created
+func (c *Client) UpsertSample(ctx context.Context, key, message string) (sample Sample, created bool, err error) {
+	res, err := c.do(ctx, http.MethodPut, "/sample", nil, upsertRequest{Key: key, Message: message}, &sample)
+	if err != nil {
+		return Sample{}, false, err
+	}
+	return sample, res.StatusCode == http.StatusCreated, nil
+}
+
+func (c *Client) DeleteSample(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, samplePath(id), nil, nil, nil)
+	return err
+}
+
+func (c *Client) Labels(ctx context.Context, id string) (map[string]string, error) {
+	var body labelsBody
+	_, err := c.do(ctx, http.MethodGet, samplePath(id)+"/labels", nil, nil, &body)
+	return body.Labels, err
+}
+
+// SetLabels replaces all labels of the sample
+func (c *Client) SetLabels(ctx context.Context, id string, labels map[string]string) (map[string]string, error) {
+	var body labelsBody
+	_, err := c.do(ctx, http.MethodPut, samplePath(id)+"/labels", nil, labelsBody{Labels: labels}, &body)
+	return body.Labels, err
+}
@@ -0,0 +1,112 @@
+package clusterinfo
+
+import (
+	"app/config"
+	"app/kube"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Response headers naming where a request was served, for ingress failover demos across clusters
+const (
+	HeaderCluster = "X-Cluster-Name"
+	HeaderRegion  = "X-Cluster-Region"
+	HeaderZone    = "X-Cluster-Zone"
+)
+
+// Well-known node labels set by the cloud provider, the beta ones on older clusters
+var (
+	regionLabels = []string{"topology.kubernetes.io/region", "failure-domain.beta.kubernetes.io/region"}
+	zoneLabels   = []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"}
+)
+
+// Info identifies the cluster, region and zone this replica runs in, empty when unknown
+type Info struct {
+	Cluster string `json:"cluster"`
+	Region  string `json:"region"`
+	Zone    string `json:"zone"`
+	Node    string `json:"node"`
+	// Source is where region and zone came from: env or node
+	Source string `json:"source"`
+}
+
+var (
+	mu      sync.RWMutex
+	current Info
+)
+
+func Current() Info {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Start resolves the identity once. CLUSTER_NAME, CLUSTER_REGION and CLUSTER_ZONE are set from config or
+// the downward API; a region or zone left unset is read from the topology labels of the NODE_NAME node,
+// which needs get on nodes. Kubernetes has no notion of a cluster name, it only comes from config.
+func Start(ctx context.Context) {
+	info := Info{
+		Cluster: config.String("CLUSTER_NAME", ""),
+		Region:  config.String("CLUSTER_REGION", ""),
+		Zone:    config.String("CLUSTER_ZONE", ""),
+		Node:    config.String("NODE_NAME", ""),
+		Source:  "env",
+	}
+	if (info.Region == "" || info.Zone == "") && info.Node != "" && kube.Enabled() {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		node, err := kube.Client.CoreV1().Nodes().Get(ctx, info.Node, metav1.GetOptions{})
+		if err != nil {
+			slog.Warn("failed to read node topology labels", "node", info.Node, "error", err)
+		} else {
+			info.Region = firstLabel(info.Region, node.Labels, regionLabels)
+			info.Zone = firstLabel(info.Zone, node.Labels, zoneLabels)
+			info.Source = "node"
+		}
+	}
+
+	mu.Lock()
+	current = info
+	mu.Unlock()
+	slog.Info("cluster identity resolved", "cluster", info.Cluster, "region", info.Region, "zone", info.Zone, "source", info.Source)
+}
+
+// firstLabel keeps value when it is set, otherwise returns the first of keys present in labels
+func firstLabel(value string, labels map[string]string, keys []string) string {
+	if value != "" {
+		return value
+	}
+	for _, key := range keys {
+		if labels[key] != "" {
+			return labels[key]
+		}
+	}
+	return ""
+}
+
+func Handler(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, Current())
+}
+
+// Middleware adds the cluster, region and zone headers to every response, leaving out the unknown ones
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			info := Current()
+			header := ctx.Response().Header()
+			for name, value := range map[string]string{HeaderCluster: info.Cluster, HeaderRegion: info.Region, HeaderZone: info.Zone} {
+				if value != "" {
+					header.Set(name, value)
+				}
+			}
+			return next(ctx)
+		}
+	}
+}
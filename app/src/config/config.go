@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String returns the environment variable, the CONFIG_FILE entry when it is unset, or else def
+func String(key, def string) string {
+	value, source := lookup(key)
+	if source == SourceDefault {
+		value = def
+	}
+	record(key, value, def, source, "")
+	return value
+}
+
+func Int(key string, def int) int {
+	return parse(key, def, strconv.Itoa, strconv.Atoi, "integer")
+}
+
+func Bool(key string, def bool) bool {
+	return parse(key, def, strconv.FormatBool, strconv.ParseBool, "boolean")
+}
+
+func Float(key string, def float64) float64 {
+	format := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+	return parse(key, def, format, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }, "number")
+}
+
+func Duration(key string, def time.Duration) time.Duration {
+	return parse(key, def, time.Duration.String, time.ParseDuration, "duration")
+}
+
+// parse reads key with parseValue, falling back to def on a value it rejects, which keeps the pod
+// starting but is recorded for app config validate
+func parse[T any](key string, def T, format func(T) string, parseValue func(string) (T, error), kind string) T {
+	value, source := lookup(key)
+	if source == SourceDefault {
+		record(key, format(def), format(def), source, "")
+		return def
+	}
+	parsed, err := parseValue(value)
+	if err != nil {
+		slog.Warn("invalid "+kind+" in configuration, using default", "key", key, "value", value, "source", source)
+		record(key, format(def), format(def), source, fmt.Sprintf("invalid %s %q, using the default", kind, value))
+		return def
+	}
+	record(key, format(parsed), format(def), source, "")
+	return parsed
+}
+
+// List splits a comma separated environment variable, dropping empty items
+func List(key string) []string {
+	var items []string
+	for _, item := range strings.Split(String(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Source is where a setting was resolved from
+type Source string
+
+const (
+	SourceEnv     Source = "env"
+	SourceFile    Source = "file"
+	SourceDefault Source = "default"
+)
+
+// Setting is a configuration key as the process resolved it, for app config validate and /debug/config.
+// Value is the effective one, the default when the configured value was rejected.
+type Setting struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	Source  Source `json:"source"`
+	Error   string `json:"error,omitempty"`
+}
+
+// redacted replaces the values of secrets
+const redacted = "[redacted]"
+
+var (
+	settingsMu sync.Mutex
+	settings   = map[string]Setting{}
+
+	fileOnce   sync.Once
+	fileValues map[string]string
+	fileErr    error
+)
+
+// lookup finds key in the environment, then in CONFIG_FILE. An empty value counts as unset.
+func lookup(key string) (string, Source) {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value, SourceEnv
+	}
+	if value := loadFile()[key]; value != "" {
+		return value, SourceFile
+	}
+	return "", SourceDefault
+}
+
+// loadFile reads CONFIG_FILE once: KEY=VALUE lines as written by kubectl create configmap --from-env-file,
+// so a ConfigMap can be mounted as a file instead of spelled out as env entries. The environment wins over it.
+func loadFile() map[string]string {
+	fileOnce.Do(func() {
+		path, ok := os.LookupEnv("CONFIG_FILE")
+		if !ok || path == "" {
+			return
+		}
+		fileValues, fileErr = readFile(path)
+		if fileErr != nil {
+			// slog is not set up yet for package level settings, the error is kept for Validate
+			fmt.Fprintln(os.Stderr, "failed to read CONFIG_FILE:", fileErr)
+		}
+	})
+	return fileValues
+}
+
+func readFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, line)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+func record(key, value, def string, source Source, problem string) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	settings[key] = Setting{Key: key, Value: value, Default: def, Source: source, Error: problem}
+}
+
+// Settings are the keys read so far sorted by name, with the values of secrets redacted. Settings
+// read when a component starts only show up once it has, so the running pod's list is the complete one.
+func Settings() []Setting {
+	settingsMu.Lock()
+	list := make([]Setting, 0, len(settings))
+	for _, setting := range settings {
+		list = append(list, setting)
+	}
+	settingsMu.Unlock()
+
+	slices.SortFunc(list, func(a, b Setting) int { return strings.Compare(a.Key, b.Key) })
+	for i, setting := range list {
+		if Secret(setting.Key) {
+			if setting.Value != "" {
+				list[i].Value = redacted
+			}
+			if setting.Default != "" {
+				list[i].Default = redacted
+			}
+			if setting.Error != "" {
+				list[i].Error = "invalid value, using the default"
+			}
+		} else if urlKey(setting.Key) {
+			list[i].Value, list[i].Default = publicURLs(setting.Value), publicURLs(setting.Default)
+		}
+	}
+	return list
+}
+
+// Secret reports whether the value of key is redacted: passwords, tokens, secrets and keys, and
+// webhook and heartbeat URLs, which are credentials themselves. Paths to them such as
+// DB_PASSWORD_FILE are shown, and so are the other webhook settings such as WEBHOOK_WORKERS.
+func Secret(key string) bool {
+	if strings.HasSuffix(key, "_FILE") || strings.HasSuffix(key, "_PATH") {
+		return false
+	}
+	for _, word := range []string{"PASSWORD", "SECRET", "TOKEN", "CREDENTIAL", "PRIVATE", "DSN"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	if urlKey(key) && (strings.Contains(key, "WEBHOOK") || strings.Contains(key, "HEARTBEAT")) {
+		return true
+	}
+	return strings.HasSuffix(key, "_KEY") || strings.HasSuffix(key, "_KEYS")
+}
+
+func urlKey(key string) bool {
+	return strings.HasSuffix(key, "_URL") || strings.HasSuffix(key, "_URLS")
+}
+
+// publicURLs strips the user info, query and fragment of the comma separated URLs in value, where
+// a password or token would be
+func publicURLs(value string) string {
+	if value == "" {
+		return value
+	}
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		u, err := url.Parse(strings.TrimSpace(item))
+		if err != nil {
+			items[i] = redacted
+			continue
+		}
+		if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+			u.User, u.RawQuery, u.ForceQuery, u.Fragment = nil, "", false, ""
+			items[i] = u.String()
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// Unused are the CONFIG_FILE keys nothing has read, usually typos in the ConfigMap
+func Unused() []string {
+	values := loadFile()
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	var unused []string
+	for key := range values {
+		if _, ok := settings[key]; !ok {
+			unused = append(unused, key)
+		}
+	}
+	slices.Sort(unused)
+	return unused
+}
+
+// Validate fails when CONFIG_FILE could not be read or a setting read so far had a value that was
+// rejected for its default
+func Validate() error {
+	loadFile()
+	var errs []error
+	if fileErr != nil {
+		errs = append(errs, fmt.Errorf("CONFIG_FILE: %w", fileErr))
+	}
+	for _, setting := range Settings() {
+		if setting.Error != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", setting.Key, setting.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"app/bodylog"
+	"app/degrade"
+	"app/model"
+	"app/readonly"
+	"app/service"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Number of rows the admin pages show
+const adminPageSize = 100
+
+// AdminPageController renders the operational subsystems as HTML, for the admin account.
+// The same data is available from the /admin API.
+type AdminPageController struct {
+	JobService     service.JobService
+	WebhookService service.WebhookService
+	SampleService  service.SampleService
+}
+
+type adminJobsPage struct {
+	Status string
+	Jobs   []model.Job
+	Dead   []model.DeadJob
+	Error  string
+}
+
+// RuntimeFlag is a switch an operator flips at runtime without a rollout
+type RuntimeFlag struct {
+	Name    string
+	Enabled bool
+}
+
+type adminFlagsPage struct {
+	Flags []RuntimeFlag
+	// Tier is the degradation tier, derived from the health checks and not switchable
+	Tier  string
+	Error string
+}
+
+func (c *AdminPageController) Jobs(ctx echo.Context) error {
+	return c.renderJobs(ctx, http.StatusOK, "")
+}
+
+// JobAction requeues or discards the dead job of the form, then shows the queue again
+func (c *AdminPageController) JobAction(ctx echo.Context) error {
+	var err error
+	id := ctx.FormValue("id")
+	switch ctx.FormValue("action") {
+	case "requeue":
+		_, err = c.JobService.RequeueDeadJob(ctx.Request().Context(), id)
+	case "discard":
+		err = c.JobService.DiscardDeadJob(ctx.Request().Context(), id)
+	default:
+		return c.renderJobs(ctx, http.StatusBadRequest, "action must be requeue or discard")
+	}
+	if err != nil {
+		return c.renderJobs(ctx, http.StatusInternalServerError, err.Error())
+	}
+	// Post/Redirect/Get, relative for the path prefix like the other pages
+	return ctx.Redirect(http.StatusSeeOther, path.Base(ctx.Request().URL.Path))
+}
+
+func (c *AdminPageController) renderJobs(ctx echo.Context, status int, message string) error {
+	page := adminJobsPage{Status: ctx.QueryParam("status"), Error: message}
+	var err error
+	if page.Jobs, err = c.JobService.ListJobs(ctx.Request().Context(), page.Status, adminPageSize); err != nil && page.Error == "" {
+		status, page.Error = http.StatusInternalServerError, err.Error()
+	}
+	if page.Dead, err = c.JobService.ListDeadJobs(ctx.Request().Context(), adminPageSize); err != nil && page.Error == "" {
+		status, page.Error = http.StatusInternalServerError, err.Error()
+	}
+	return ctx.Render(status, "admin_jobs.html", page)
+}
+
+func (c *AdminPageController) Webhooks(ctx echo.Context) error {
+	events, err := c.WebhookService.ListEvents(ctx.Request().Context(), adminPageSize)
+	page := struct {
+		Events []model.WebhookEvent
+		Error  string
+	}{Events: events}
+	status := http.StatusOK
+	if err != nil {
+		status, page.Error = http.StatusInternalServerError, err.Error()
+	}
+	return ctx.Render(status, "admin_webhooks.html", page)
+}
+
+func (c *AdminPageController) Flags(ctx echo.Context) error {
+	return c.renderFlags(ctx, http.StatusOK, "")
+}
+
+// SetFlag switches the runtime flag of the form, the same as PUT /admin/readonly and /admin/debug/bodylog
+func (c *AdminPageController) SetFlag(ctx echo.Context) error {
+	enabled, err := strconv.ParseBool(ctx.FormValue("enabled"))
+	if err != nil {
+		return c.renderFlags(ctx, http.StatusBadRequest, "enabled must be true or false")
+	}
+	switch ctx.FormValue("flag") {
+	case "readonly":
+		if err := readonly.Switch(ctx.Request().Context(), enabled); err != nil {
+			return c.renderFlags(ctx, http.StatusServiceUnavailable, err.Error())
+		}
+	case "bodylog":
+		bodylog.SetEnabled(enabled)
+	default:
+		return c.renderFlags(ctx, http.StatusBadRequest, "unknown flag")
+	}
+	return ctx.Redirect(http.StatusSeeOther, path.Base(ctx.Request().URL.Path))
+}
+
+func (c *AdminPageController) renderFlags(ctx echo.Context, status int, message string) error {
+	return ctx.Render(status, "admin_flags.html", adminFlagsPage{
+		Flags: []RuntimeFlag{
+			{Name: "readonly", Enabled: readonly.Enabled()},
+			{Name: "bodylog", Enabled: bodylog.Enabled()},
+		},
+		Tier:  degrade.Current(),
+		Error: message,
+	})
+}
+
+// Audit lists the latest writes to samples with what they changed
+func (c *AdminPageController) Audit(ctx echo.Context) error {
+	revisions, err := c.SampleService.RecentRevisions(ctx.Request().Context(), adminPageSize)
+	page := struct {
+		Revisions []model.SampleRevision
+		Error     string
+	}{Revisions: revisions}
+	status := http.StatusOK
+	if err != nil {
+		status, page.Error = http.StatusInternalServerError, err.Error()
+	}
+	return ctx.Render(status, "admin_audit.html", page)
+}
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"app/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AggregatorController serves the APP_ROLE=aggregator endpoints, each composed from calls to the Sample API of another deployment
+type AggregatorController struct {
+	AggregatorService service.AggregatorService
+}
+
+func (c *AggregatorController) Register(router Router) {
+	router.GET("/aggregate/samples/:id", c.SampleDetail)
+	router.GET("/aggregate/summary", c.Summary)
+}
+
+// SampleDetail answers a sample with its labels, read from upstream in parallel
+func (c *AggregatorController) SampleDetail(ctx echo.Context) error {
+	detail, err := c.AggregatorService.SampleDetail(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, detail)
+}
+
+// Summary counts the upstream samples matching ?selector=, all of them when it is empty
+func (c *AggregatorController) Summary(ctx echo.Context) error {
+	summary, err := c.AggregatorService.Summary(ctx.Request().Context(), ctx.QueryParam("selector"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,78 @@
+package controller_test
+
+import (
+	"app/apptest"
+	"app/client"
+	"app/controller"
+	"app/model"
+	"app/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// setupAggregator serves the aggregation endpoints in front of the Sample API of a test server
+func setupAggregator(t *testing.T, maxPages int) http.Handler {
+	t.Helper()
+	upstream := httptest.NewServer(setup(t))
+	t.Cleanup(upstream.Close)
+
+	router := apptest.Echo(t)
+	aggregatorController := controller.AggregatorController{AggregatorService: service.AggregatorService{
+		Upstream: client.New(upstream.URL, client.WithRetries(0)),
+		MaxPages: maxPages,
+	}}
+	aggregatorController.Register(router)
+	return router
+}
+
+func TestAggregateSampleDetail(t *testing.T) {
+	router := setupAggregator(t, 10)
+	sample := seedSamples(t, "hello")[0]
+	apptest.Seed(t, &model.SampleLabel{SampleID: sample.ID, Name: "env", Value: "prod"})
+
+	rec := apptest.Do(t, router, http.MethodGet, "/aggregate/samples/"+sample.ID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	detail := apptest.JSON[service.SampleDetail](t, rec)
+	if detail.Sample.Message != "hello" || detail.Labels["env"] != "prod" {
+		t.Errorf("detail = %+v", detail)
+	}
+
+	if rec := apptest.Do(t, router, http.MethodGet, "/aggregate/samples/missing", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("missing sample status = %d, want 404", rec.Code)
+	}
+}
+
+func TestAggregateSummary(t *testing.T) {
+	router := setupAggregator(t, 1)
+	seedSamples(t, "a", "b", "c")
+
+	rec := apptest.Do(t, router, http.MethodGet, "/aggregate/summary", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	summary := apptest.JSON[service.SampleSummary](t, rec)
+	if summary.Count != 3 || summary.Pages != 1 || summary.Truncated || summary.Oldest == nil || !summary.Oldest.Before(*summary.Newest) {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	if rec := apptest.Do(t, router, http.MethodGet, "/aggregate/summary?selector=in(", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid selector status = %d, want 400", rec.Code)
+	}
+}
+
+func TestAggregateUpstreamDown(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+	router := apptest.Echo(t)
+	aggregatorController := controller.AggregatorController{AggregatorService: service.AggregatorService{
+		Upstream: client.New(upstream.URL, client.WithRetries(0)),
+	}}
+	aggregatorController.Register(router)
+
+	if rec := apptest.Do(t, router, http.MethodGet, "/aggregate/summary", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
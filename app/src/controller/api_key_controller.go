@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"app/apikey"
+	"app/model"
+	"app/service"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type APIKeyController struct {
+	APIKeyService service.APIKeyService
+}
+
+// CreateAPIKeyRequest sets the quotas of a new key, zero leaves the period unlimited
+type CreateAPIKeyRequest struct {
+	Name         string `json:"name"`
+	DailyQuota   int64  `json:"daily_quota"`
+	MonthlyQuota int64  `json:"monthly_quota"`
+}
+
+// Create responds with the secret, it cannot be retrieved again
+func (c *APIKeyController) Create(ctx echo.Context) error {
+	req := new(CreateAPIKeyRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+	if req.Name == "" || len(req.Name) > 64 {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "name must be 1 to 64 characters"})
+	}
+	if req.DailyQuota < 0 || req.MonthlyQuota < 0 {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "quotas must not be negative"})
+	}
+
+	key, secret, err := c.APIKeyService.Create(ctx.Request().Context(), req.Name, req.DailyQuota, req.MonthlyQuota)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, map[string]any{"key": key, "secret": secret})
+}
+
+func (c *APIKeyController) List(ctx echo.Context) error {
+	keys, err := c.APIKeyService.List(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, keys)
+}
+
+func (c *APIKeyController) Revoke(ctx echo.Context) error {
+	err := c.APIKeyService.Revoke(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Usage reports any key's usage to admins
+func (c *APIKeyController) Usage(ctx echo.Context) error {
+	key, err := c.APIKeyService.Find(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return c.report(ctx, key)
+}
+
+// Quota reports the usage of the key the request presents
+func (c *APIKeyController) Quota(ctx echo.Context) error {
+	key := apikey.Get(ctx)
+	if key == nil {
+		return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "an api key is required in the " + apikey.Header + " header"})
+	}
+	return c.report(ctx, *key)
+}
+
+func (c *APIKeyController) report(ctx echo.Context, key model.APIKey) error {
+	report, err := c.APIKeyService.Usage(ctx.Request().Context(), key, time.Now())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
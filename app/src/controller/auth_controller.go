@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"app/apperrors"
+	"app/i18n"
+	"app/logctx"
+	"app/oidcauth"
+	"app/service"
+	"app/session"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AuthController struct {
+	AuthService service.AuthService
+	Sessions    *session.Manager
+	OIDC        *oidcauth.Provider
+}
+
+// Short lived cookie carrying the state and nonce across the identity provider redirect
+const oidcFlowCookie = "oidc_flow"
+
+type CredentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type loginPage struct {
+	Username    string
+	Error       string
+	OIDCEnabled bool
+}
+
+func (c *AuthController) LoginPage(ctx echo.Context) error {
+	return c.renderLogin(ctx, http.StatusOK, loginPage{})
+}
+
+func (c *AuthController) renderLogin(ctx echo.Context, status int, page loginPage) error {
+	page.OIDCEnabled = c.OIDC != nil
+	return ctx.Render(status, "login.html", page)
+}
+
+func (c *AuthController) Login(ctx echo.Context) error {
+	username := ctx.FormValue("username")
+	user, err := c.AuthService.Authenticate(username, ctx.FormValue("password"))
+	if err != nil {
+		return c.renderLogin(ctx, http.StatusUnauthorized, loginPage{
+			Username: username,
+			Error:    i18n.T(ctx, authErrorMessage(err)),
+		})
+	}
+
+	if err := c.Sessions.Start(ctx, user.ID, user.Username); err != nil {
+		logctx.From(ctx).Error("failed to start session", "error", err)
+		return c.renderLogin(ctx, http.StatusInternalServerError, loginPage{
+			Username: username,
+			Error:    i18n.T(ctx, "error.session_failed"),
+		})
+	}
+	return ctx.Redirect(http.StatusSeeOther, "samples")
+}
+
+func (c *AuthController) Logout(ctx echo.Context) error {
+	if err := c.Sessions.Destroy(ctx); err != nil {
+		logctx.From(ctx).Error("failed to destroy session", "error", err)
+	}
+	return ctx.Redirect(http.StatusSeeOther, "login")
+}
+
+func (c *AuthController) OIDCLogin(ctx echo.Context) error {
+	url, state, nonce, err := c.OIDC.AuthCodeURL()
+	if err != nil {
+		return c.renderLogin(ctx, http.StatusInternalServerError, loginPage{Error: i18n.T(ctx, "error.session_failed")})
+	}
+
+	ctx.SetCookie(&http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    state + "." + nonce,
+		Path:     "/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   c.Sessions.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return ctx.Redirect(http.StatusFound, url)
+}
+
+func (c *AuthController) OIDCCallback(ctx echo.Context) error {
+	cookie, err := ctx.Cookie(oidcFlowCookie)
+	ctx.SetCookie(&http.Cookie{Name: oidcFlowCookie, Path: "/", MaxAge: -1})
+	if err != nil {
+		return c.renderLogin(ctx, http.StatusBadRequest, loginPage{Error: i18n.T(ctx, "error.oidc_failed")})
+	}
+
+	state, nonce, _ := strings.Cut(cookie.Value, ".")
+	if state == "" || ctx.QueryParam("state") != state {
+		return c.renderLogin(ctx, http.StatusBadRequest, loginPage{Error: i18n.T(ctx, "error.oidc_failed")})
+	}
+	if errParam := ctx.QueryParam("error"); errParam != "" {
+		logctx.From(ctx).Warn("identity provider returned an error", "error", errParam, "description", ctx.QueryParam("error_description"))
+		return c.renderLogin(ctx, http.StatusUnauthorized, loginPage{Error: i18n.T(ctx, "error.oidc_failed")})
+	}
+
+	claims, err := c.OIDC.Exchange(ctx.Request().Context(), ctx.QueryParam("code"), nonce)
+	if err != nil {
+		logctx.From(ctx).Warn("oidc login failed", "error", err)
+		return c.renderLogin(ctx, http.StatusUnauthorized, loginPage{Error: i18n.T(ctx, "error.oidc_failed")})
+	}
+
+	if err := c.Sessions.Start(ctx, "oidc:"+claims.Subject, claims.DisplayName()); err != nil {
+		logctx.From(ctx).Error("failed to start session", "error", err)
+		return c.renderLogin(ctx, http.StatusInternalServerError, loginPage{Error: i18n.T(ctx, "error.session_failed")})
+	}
+	// The callback lives one level deeper than the other pages
+	return ctx.Redirect(http.StatusSeeOther, "../samples")
+}
+
+func authErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrAccountLocked):
+		return "error.account_locked"
+	case errors.Is(err, service.ErrUserExists):
+		return "error.user_exists"
+	case errors.Is(err, service.ErrWeakPassword):
+		return "error.weak_password"
+	case errors.Is(err, service.ErrInvalidUsername):
+		return "error.invalid_username"
+	default:
+		return "error.invalid_credentials"
+	}
+}
+
+// authError answers an auth failure with its translated message, a locked account with 423.
+// Failures that are not the client's, such as a database error, keep their own message.
+func authError(ctx echo.Context, err error) error {
+	switch kind := apperrors.KindOf(err); {
+	case errors.Is(err, service.ErrAccountLocked):
+		return ctx.JSON(http.StatusLocked, map[string]string{"error": i18n.T(ctx, authErrorMessage(err))})
+	case kind == apperrors.Internal || kind == apperrors.Dependency:
+		return errorResponse(ctx, err)
+	default:
+		return ctx.JSON(kind.Status(), map[string]string{"error": i18n.T(ctx, authErrorMessage(err))})
+	}
+}
+
+func (c *AuthController) Register(ctx echo.Context) error {
+	req := new(RegisterRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+
+	user, err := c.AuthService.Register(req.Username, req.Email, req.Password)
+	if err != nil {
+		return authError(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, user)
+}
+
+// APILogin authenticates with a JSON body and issues the same session cookie as the login page
+func (c *AuthController) APILogin(ctx echo.Context) error {
+	req := new(CredentialsRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+
+	user, err := c.AuthService.Authenticate(req.Username, req.Password)
+	if err != nil {
+		return authError(ctx, err)
+	}
+
+	if err := c.Sessions.Start(ctx, user.ID, user.Username); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": i18n.T(ctx, "error.session_failed")})
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"id": user.ID, "username": user.Username})
+}
+
+func (c *AuthController) ChangePassword(ctx echo.Context) error {
+	current := session.Get(ctx)
+	if current == nil {
+		return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": i18n.T(ctx, "error.login_required")})
+	}
+
+	req := new(ChangePasswordRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+
+	if err := c.AuthService.ChangePassword(current.UserID, req.CurrentPassword, req.NewPassword); err != nil {
+		return authError(ctx, err)
+	}
+
+	// End the user's sessions on every device and continue in a fresh one, so other holders of the old cookie are logged out
+	if err := c.Sessions.DestroyUser(ctx.Request().Context(), current.UserID); err != nil {
+		logctx.From(ctx).Error("failed to end the other sessions", "error", err)
+	}
+	if err := c.Sessions.Start(ctx, current.UserID, current.Username); err != nil {
+		logctx.From(ctx).Error("failed to rotate session", "error", err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"app/apperrors"
+	"app/service"
+	"net/http"
+	"path"
+
+	"github.com/labstack/echo/v4"
+)
+
+type BackupController struct {
+	BackupService service.BackupService
+}
+
+func (c *BackupController) Create(ctx echo.Context) error {
+	backup, err := c.BackupService.Create(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, backup)
+}
+
+func (c *BackupController) List(ctx echo.Context) error {
+	backups, err := c.BackupService.List(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, backups)
+}
+
+func (c *BackupController) Download(ctx echo.Context) error {
+	reader, obj, err := c.BackupService.Open(ctx.Request().Context(), ctx.Param("name"))
+	switch {
+	case apperrors.KindOf(err) == apperrors.NotFound:
+		// Invalid names read as missing, so probing for paths learns nothing
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "backup not found"})
+	case err != nil:
+		return errorResponse(ctx, err)
+	}
+	defer reader.Close()
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+path.Base(obj.Key)+`"`)
+	return ctx.Stream(http.StatusOK, "application/gzip", reader)
+}
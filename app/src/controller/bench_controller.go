@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"app/config"
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	benchMaxDelay     = config.Duration("BENCH_MAX_DELAY", 10*time.Minute)
+	benchMaxPayloadKB = config.Int("BENCH_MAX_PAYLOAD_KB", 100*1024)
+)
+
+// benchChunk is written repeatedly to build payloads, printable so it is readable in a terminal
+var benchChunk = bytes.Repeat([]byte("0123456789abcdef"), 2048)
+
+// BenchController serves endpoints for testing ingress timeouts and buffer limits
+type BenchController struct{}
+
+// Delay answers after :ms milliseconds, or early when the client or proxy gives up
+func (c *BenchController) Delay(ctx echo.Context) error {
+	ms, err := strconv.Atoi(ctx.Param("ms"))
+	delay := time.Duration(ms) * time.Millisecond
+	if err != nil || ms < 0 || delay > benchMaxDelay {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "ms must be between 0 and " + strconv.FormatInt(benchMaxDelay.Milliseconds(), 10)})
+	}
+
+	started := time.Now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Request().Context().Done():
+		// Nobody is left to answer, the access log still shows how long the caller waited
+		return nil
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"delay_ms":   ms,
+		"elapsed_ms": time.Since(started).Milliseconds(),
+	})
+}
+
+// Payload answers with :kb kilobytes. ?chunked=true leaves out Content-Length
+// to test how proxies buffer responses of unknown size.
+func (c *BenchController) Payload(ctx echo.Context) error {
+	kb, err := strconv.Atoi(ctx.Param("kb"))
+	if err != nil || kb < 0 || kb > benchMaxPayloadKB {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "kb must be between 0 and " + strconv.Itoa(benchMaxPayloadKB)})
+	}
+
+	res := ctx.Response()
+	size := int64(kb) * 1024
+	res.Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	if ctx.QueryParam("chunked") != "true" {
+		res.Header().Set(echo.HeaderContentLength, strconv.FormatInt(size, 10))
+	}
+	res.WriteHeader(http.StatusOK)
+
+	for written := int64(0); written < size; {
+		chunk := benchChunk[:min(int64(len(benchChunk)), size-written)]
+		n, err := res.Write(chunk)
+		if err != nil {
+			// The client went away mid body
+			return nil
+		}
+		written += int64(n)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"app/i18n"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// bindError reports a failed ctx.Bind with the status chosen by the binder (400 or 415) and its details
+func bindError(ctx echo.Context, err error) error {
+	status := http.StatusBadRequest
+	details := err.Error()
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Code
+		details = fmt.Sprint(httpErr.Message)
+	}
+
+	message := i18n.T(ctx, "error.invalid_request_body")
+	if status == http.StatusUnsupportedMediaType {
+		message = i18n.T(ctx, "error.unsupported_media_type")
+	}
+	return ctx.JSON(status, map[string]string{"error": message, "details": details})
+}
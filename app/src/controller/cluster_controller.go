@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"app/kube"
+	"app/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ClusterController struct {
+	ClusterService service.ClusterService
+}
+
+func (c *ClusterController) Peers(ctx echo.Context) error {
+	peers, err := c.ClusterService.Peers(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"namespace": kube.Namespace(),
+		"self":      kube.PodName(),
+		"peers":     peers,
+	})
+}
+
+func (c *ClusterController) Fanout(ctx echo.Context) error {
+	report, err := c.ClusterService.Fanout(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// Hostname identifies the replica that served the request, the target of /fanout
+func (c *ClusterController) Hostname(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, service.CurrentPod())
+}
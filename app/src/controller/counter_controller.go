@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"app/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CounterController serves the visit counter demo, comparing state in redis with state in the pod
+type CounterController struct {
+	CounterService service.CounterService
+}
+
+func (c *CounterController) Get(ctx echo.Context) error {
+	counter, err := c.CounterService.Counter(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, counter)
+}
+
+// Increment counts a visit. Calling it through the Service with and without REDIS_URL shows
+// visits jumping between the replicas' own counts, or counting up once whichever replica answers.
+func (c *CounterController) Increment(ctx echo.Context) error {
+	counter, err := c.CounterService.Increment(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, counter)
+}
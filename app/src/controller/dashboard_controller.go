@@ -0,0 +1,20 @@
+package controller
+
+import (
+	"app/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type DashboardController struct {
+	DashboardService service.DashboardService
+}
+
+func (c *DashboardController) Get(ctx echo.Context) error {
+	dashboard, err := c.DashboardService.Build(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, dashboard)
+}
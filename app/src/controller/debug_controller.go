@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"app/cgroup"
+	"app/config"
+	"app/logctx"
+	"app/runtimeutil"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// cpuSampleWindow is how long Resources measures CPU usage for
+const cpuSampleWindow = 250 * time.Millisecond
+
+type DebugController struct{}
+
+type goRuntimeStats struct {
+	GOMAXPROCS     int    `json:"gomaxprocs"`
+	NumCPU         int    `json:"num_cpu"`
+	GOMEMLIMIT     *int64 `json:"gomemlimit_bytes"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	Goroutines     int    `json:"goroutines"`
+	NumGC          uint32 `json:"num_gc"`
+}
+
+// Resources reports the container's cgroup limits and usage next to the Go runtime's view,
+// which shows how requests/limits translate into GOMAXPROCS, GOMEMLIMIT and throttling
+func (c *DebugController) Resources(ctx echo.Context) error {
+	report := map[string]any{}
+
+	before, err := cgroup.Read()
+	switch {
+	case errors.Is(err, cgroup.ErrNotFound):
+		report["cgroup"] = nil
+	case err != nil:
+		return errorResponse(ctx, err)
+	default:
+		select {
+		case <-time.After(cpuSampleWindow):
+		case <-ctx.Request().Context().Done():
+			return nil
+		}
+		after, _ := cgroup.Read()
+		usage := (after.CPUUsageSeconds - before.CPUUsageSeconds) / cpuSampleWindow.Seconds()
+		report["cgroup"] = after
+		report["cpu_usage_cores"] = math.Round(usage*1000) / 1000
+		if after.MemoryLimitBytes > 0 {
+			report["memory_usage_ratio"] = math.Round(float64(after.MemoryUsageBytes)/float64(after.MemoryLimitBytes)*1000) / 1000
+		}
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := goRuntimeStats{
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		NumCPU:         runtime.NumCPU(),
+		HeapAllocBytes: mem.HeapAlloc,
+		SysBytes:       mem.Sys,
+		Goroutines:     runtime.NumGoroutine(),
+		NumGC:          mem.NumGC,
+	}
+	// A negative value only reads the limit, math.MaxInt64 means none is set
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		stats.GOMEMLIMIT = &limit
+	}
+	report["go"] = stats
+	report["background"] = runtimeutil.Running()
+	return ctx.JSON(http.StatusOK, report)
+}
+
+type gcSettings struct {
+	// GCPercent is GOGC, -1 when the GC only runs at the memory limit
+	GCPercent int64 `json:"gc_percent"`
+	// MemoryLimitBytes is GOMEMLIMIT, null when none is set
+	MemoryLimitBytes *int64 `json:"memory_limit_bytes"`
+	HeapLiveBytes    uint64 `json:"heap_live_bytes"`
+	Cycles           uint64 `json:"gc_cycles"`
+}
+
+// GCRequest changes GOGC and GOMEMLIMIT, a field left out keeps its value. memory_limit is a
+// Kubernetes quantity such as 400Mi, or "off" to remove the limit.
+type GCRequest struct {
+	GCPercent   *int    `json:"gc_percent"`
+	MemoryLimit *string `json:"memory_limit"`
+}
+
+func readGCSettings() gcSettings {
+	samples := []metrics.Sample{{Name: "/gc/gogc:percent"}, {Name: "/gc/heap/live:bytes"}, {Name: "/gc/cycles/total:gc-cycles"}}
+	metrics.Read(samples)
+	settings := gcSettings{
+		// GOGC=off is stored as -1, which the uint64 wraps
+		GCPercent:     int64(samples[0].Value.Uint64()),
+		HeapLiveBytes: samples[1].Value.Uint64(),
+		Cycles:        samples[2].Value.Uint64(),
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		settings.MemoryLimitBytes = &limit
+	}
+	return settings
+}
+
+type configReport struct {
+	File     string           `json:"file,omitempty"`
+	Settings []config.Setting `json:"settings"`
+	Unused   []string         `json:"unused,omitempty"`
+	Problems []string         `json:"problems,omitempty"`
+}
+
+// Config lists the settings this replica resolved, whether from the environment, CONFIG_FILE or the
+// default, with secrets redacted. Unused are CONFIG_FILE keys nothing read, Problems the values rejected.
+func (c *DebugController) Config(ctx echo.Context) error {
+	report := configReport{
+		File:     os.Getenv("CONFIG_FILE"),
+		Settings: config.Settings(),
+		Unused:   config.Unused(),
+	}
+	if err := config.Validate(); err != nil {
+		report.Problems = strings.Split(err.Error(), "\n")
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// GC reports the GOGC and GOMEMLIMIT in effect
+func (c *DebugController) GC(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, readGCSettings())
+}
+
+// SetGC changes GOGC and GOMEMLIMIT of this replica until it restarts, for trying settings under
+// load before putting them into GC_PERCENT and GC_MEMORY_LIMIT
+func (c *DebugController) SetGC(ctx echo.Context) error {
+	req := new(GCRequest)
+	if err := ctx.Bind(req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	limit := int64(-1)
+	if req.MemoryLimit != nil {
+		if *req.MemoryLimit == "off" {
+			limit = math.MaxInt64
+		} else {
+			parsed, err := cgroup.ParseMemoryLimit(*req.MemoryLimit)
+			if err != nil {
+				return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid memory_limit", "details": err.Error()})
+			}
+			limit = parsed
+		}
+	}
+	if req.GCPercent != nil && *req.GCPercent < -1 {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "gc_percent must be -1 or more"})
+	}
+
+	if req.GCPercent != nil {
+		debug.SetGCPercent(*req.GCPercent)
+	}
+	if limit >= 0 {
+		debug.SetMemoryLimit(limit)
+	}
+	settings := readGCSettings()
+	logctx.From(ctx).Info("gc settings changed", "gc_percent", settings.GCPercent, "memory_limit_bytes", settings.MemoryLimitBytes)
+	return ctx.JSON(http.StatusOK, settings)
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"app/config"
+	"app/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Most samples one generate request may create
+var generateMaxCount = config.Int("DEV_GENERATE_MAX_COUNT", 100000)
+
+// DevController serves development helpers, only registered with DEV_ENDPOINTS_ENABLED
+type DevController struct {
+	GeneratorService service.GeneratorService
+}
+
+// Generate fills the database with ?count= fake samples for scaling demos
+func (c *DevController) Generate(ctx echo.Context) error {
+	count, err := strconv.Atoi(ctx.QueryParam("count"))
+	if err != nil || count < 1 || count > generateMaxCount {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": "count must be between 1 and " + strconv.Itoa(generateMaxCount),
+		})
+	}
+
+	started := time.Now()
+	created, err := c.GeneratorService.GenerateSamples(ctx.Request().Context(), count)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error(), "created": created})
+	}
+	return ctx.JSON(http.StatusCreated, map[string]any{
+		"created":     created,
+		"duration_ms": time.Since(started).Milliseconds(),
+	})
+}
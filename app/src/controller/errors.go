@@ -0,0 +1,12 @@
+package controller
+
+import (
+	"app/apperrors"
+
+	"github.com/labstack/echo/v4"
+)
+
+// errorResponse answers a service failure with the status its apperrors kind maps to
+func errorResponse(ctx echo.Context, err error) error {
+	return ctx.JSON(apperrors.Status(err), map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"app/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ExportController struct {
+	ExportService service.ExportService
+}
+
+// Create exports the table to a Parquet file in object storage, 404 for a table not in EXPORT_TABLES
+func (c *ExportController) Create(ctx echo.Context) error {
+	export, err := c.ExportService.Export(ctx.Request().Context(), ctx.Param("table"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, export)
+}
+
+func (c *ExportController) List(ctx echo.Context) error {
+	exports, err := c.ExportService.List(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, exports)
+}
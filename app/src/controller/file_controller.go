@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"app/apperrors"
+	"app/config"
+	"app/links"
+	"app/scan"
+	"app/service"
+	"app/signedurl"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type FileController struct {
+	FileService service.FileService
+	// MaxBytes caps an upload, FILES_MAX_BYTES
+	MaxBytes int64
+	// MaxSignedURLTTL caps how long a signed URL is valid, FILES_SIGNED_URL_MAX_TTL
+	MaxSignedURLTTL time.Duration
+	// MaxChunkBytes caps a chunk of a resumable upload, FILES_MAX_CHUNK_BYTES, below the ingress body limit
+	MaxChunkBytes int64
+}
+
+func NewFileControllerFromEnv(urls *signedurl.Signer, scanner scan.Scanner) FileController {
+	return FileController{
+		FileService: service.FileService{
+			URLs:      urls,
+			Scanner:   scanner,
+			UploadTTL: config.Duration("FILES_UPLOAD_TTL", 24*time.Hour),
+		},
+		MaxBytes:        int64(config.Int("FILES_MAX_BYTES", 100<<20)),
+		MaxSignedURLTTL: config.Duration("FILES_SIGNED_URL_MAX_TTL", 7*24*time.Hour),
+		MaxChunkBytes:   int64(config.Int("FILES_MAX_CHUNK_BYTES", 8<<20)),
+	}
+}
+
+type SignedURLRequest struct {
+	// ExpiresIn is in seconds, an hour when omitted
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// Register adds the file routes behind auth. Downloads with a valid signature skip auth.
+func (c *FileController) Register(router Router, auth ...echo.MiddlewareFunc) {
+	router.POST("/files", c.Upload, auth...)
+	router.GET("/files", c.List, auth...)
+	router.GET("/files/:id", c.Download, c.signedOr(auth))
+	router.GET("/files/:id/metadata", c.Metadata, auth...)
+	router.POST("/files/:id/signed-url", c.SignURL, auth...)
+	router.POST("/files/:id/scan", c.Rescan, auth...)
+	router.DELETE("/files/:id", c.Delete, auth...)
+
+	router.POST("/uploads", c.CreateUpload, auth...)
+	router.GET("/uploads/:id", c.GetUpload, auth...)
+	router.PATCH("/uploads/:id", c.WriteChunk, auth...)
+	router.POST("/uploads/:id/complete", c.CompleteUpload, auth...)
+	router.DELETE("/uploads/:id", c.AbortUpload, auth...)
+}
+
+// filePath is the path a signed URL authorizes, without the prefix the reverse proxy strips
+func filePath(id string) string {
+	return "/files/" + id
+}
+
+// signedOr lets requests carrying a signature through when it is valid for their path, and
+// sends the others through auth
+func (c *FileController) signedOr(auth []echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authenticated := next
+		for i := len(auth) - 1; i >= 0; i-- {
+			authenticated = auth[i](authenticated)
+		}
+		return func(ctx echo.Context) error {
+			query := ctx.QueryParams()
+			if !query.Has("signature") {
+				return authenticated(ctx)
+			}
+			if c.FileService.URLs == nil {
+				return ctx.JSON(http.StatusForbidden, map[string]string{"error": "signed urls are disabled"})
+			}
+			if err := c.FileService.URLs.Verify(filePath(ctx.Param("id")), query); err != nil {
+				return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Upload stores the "file" part of a multipart/form-data request without buffering it
+func (c *FileController) Upload(ctx echo.Context) error {
+	req := ctx.Request()
+	req.Body = http.MaxBytesReader(ctx.Response(), req.Body, c.MaxBytes)
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "body must be multipart/form-data with a file part"})
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "body must be multipart/form-data with a file part"})
+		}
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "malformed multipart body", "details": err.Error()})
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		contentType := part.Header.Get(echo.HeaderContentType)
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			contentType = "application/octet-stream"
+		}
+		file, err := c.FileService.Upload(req.Context(), part.FileName(), contentType, part)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			return ctx.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "file is too large"})
+		case err != nil:
+			return errorResponse(ctx, err)
+		}
+		return ctx.JSON(http.StatusCreated, file)
+	}
+}
+
+func (c *FileController) List(ctx echo.Context) error {
+	files, err := c.FileService.List(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, files)
+}
+
+func (c *FileController) Metadata(ctx echo.Context) error {
+	file, err := c.FileService.Get(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, file)
+}
+
+func (c *FileController) Download(ctx echo.Context) error {
+	reader, file, err := c.FileService.Open(ctx.Request().Context(), ctx.Param("id"))
+	switch {
+	case apperrors.KindOf(err) == apperrors.NotFound:
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "file not found"})
+	case err != nil:
+		return errorResponse(ctx, err)
+	}
+	defer reader.Close()
+
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	header.Set(echo.HeaderContentType, file.ContentType)
+	header.Set("ETag", `"`+file.SHA256+`"`)
+	// Both backends seek without reading what is skipped, S3 with a ranged GET, so Range requests
+	// and If-Range resumes are answered by ServeContent with 206 Partial Content
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(ctx.Response(), ctx.Request(), "", file.CreatedAt, seeker)
+		return nil
+	}
+	header.Set("Accept-Ranges", "none")
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(file.Size, 10))
+	return ctx.Stream(http.StatusOK, file.ContentType, reader)
+}
+
+// SignURL returns a URL anyone can download the file from, without credentials, until it expires
+func (c *FileController) SignURL(ctx echo.Context) error {
+	req := new(SignedURLRequest)
+	if ctx.Request().ContentLength != 0 {
+		if err := ctx.Bind(req); err != nil {
+			return bindError(ctx, err)
+		}
+	}
+	ttl := time.Hour
+	if req.ExpiresIn != 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl <= 0 || ttl > c.MaxSignedURLTTL {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "expires_in must be between 1 and " + strconv.FormatInt(int64(c.MaxSignedURLTTL.Seconds()), 10) + " seconds"})
+	}
+
+	id := ctx.Param("id")
+	query, expires, err := c.FileService.SignedURL(ctx.Request().Context(), id, filePath(id), ttl)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	prefix := strings.TrimSuffix(ctx.Request().Header.Get(links.HeaderPrefix), "/")
+	return ctx.JSON(http.StatusCreated, map[string]any{"url": prefix + filePath(id) + "?" + query, "expires_at": expires})
+}
+
+// Rescan queues the file for another scan, it is not served until the scan finishes
+func (c *FileController) Rescan(ctx echo.Context) error {
+	file, err := c.FileService.Rescan(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusAccepted, file)
+}
+
+func (c *FileController) Delete(ctx echo.Context) error {
+	if err := c.FileService.Delete(ctx.Request().Context(), ctx.Param("id")); err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
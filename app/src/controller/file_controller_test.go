@@ -0,0 +1,202 @@
+package controller_test
+
+import (
+	"app/apptest"
+	"app/controller"
+	"app/model"
+	"app/service"
+	"app/signedurl"
+	"app/storage"
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// denyAll stands in for the write auth, only signed downloads get past it
+func denyAll(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+}
+
+// useTempStorage points the object storage at a directory removed after the test
+func useTempStorage(t *testing.T) {
+	local, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := storage.Default
+	storage.Default = local
+	t.Cleanup(func() { storage.Default = previous })
+}
+
+// uploadFile stores content through POST /files
+func uploadFile(t *testing.T, e http.Handler, name, content string) model.File {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", name)
+	part.Write([]byte(content))
+	form.Close()
+	rec := apptest.Do(t, e, http.MethodPost, "/files", body.Bytes(), apptest.WithHeader(echo.HeaderContentType, form.FormDataContentType()))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload: %d %s", rec.Code, rec.Body.String())
+	}
+	return apptest.JSON[model.File](t, rec)
+}
+
+func TestFileSignedURL(t *testing.T) {
+	apptest.DB(t, &model.File{})
+	useTempStorage(t)
+
+	files := controller.FileController{
+		FileService:     service.FileService{URLs: &signedurl.Signer{Key: []byte(strings.Repeat("k", 32))}},
+		MaxBytes:        1 << 20,
+		MaxSignedURLTTL: time.Hour,
+	}
+	open := apptest.Echo(t)
+	files.Register(open)
+	closed := apptest.Echo(t)
+	files.Register(closed, denyAll)
+
+	file := uploadFile(t, open, "hello.txt", "hello, file")
+	if file.Size != 11 || file.Name != "hello.txt" {
+		t.Errorf("uploaded %+v", file)
+	}
+
+	if rec := apptest.Do(t, closed, http.MethodGet, "/files/"+file.ID, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned download without auth = %d", rec.Code)
+	}
+	rec := apptest.Do(t, open, http.MethodPost, "/files/"+file.ID+"/signed-url", map[string]int{"expires_in": 60})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("sign: %d %s", rec.Code, rec.Body.String())
+	}
+	signed := apptest.JSON[struct {
+		URL string `json:"url"`
+	}](t, rec)
+
+	rec = apptest.Do(t, closed, http.MethodGet, signed.URL, nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello, file" {
+		t.Errorf("signed download = %d %q", rec.Code, rec.Body.String())
+	}
+	other := strings.Replace(signed.URL, file.ID, "00000000-0000-0000-0000-000000000000", 1)
+	if rec := apptest.Do(t, closed, http.MethodGet, other, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("signature reused for another file = %d", rec.Code)
+	}
+	expired := (&signedurl.Signer{Key: []byte(strings.Repeat("k", 32))}).Sign("/files/"+file.ID, time.Now().Add(-time.Minute))
+	if rec := apptest.Do(t, closed, http.MethodGet, "/files/"+file.ID+"?"+expired.Encode(), nil); rec.Code != http.StatusForbidden {
+		t.Errorf("expired signed download = %d", rec.Code)
+	}
+}
+
+func TestResumableUpload(t *testing.T) {
+	apptest.DB(t, &model.File{}, &model.UploadSession{}, &model.UploadPart{})
+	useTempStorage(t)
+
+	files := controller.FileController{
+		FileService:   service.FileService{UploadTTL: time.Hour},
+		MaxBytes:      1 << 20,
+		MaxChunkBytes: 8,
+	}
+	e := apptest.Echo(t)
+	files.Register(e)
+
+	rec := apptest.Do(t, e, http.MethodPost, "/uploads", map[string]any{"name": "big.txt", "content_type": "text/plain", "size": 14})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body.String())
+	}
+	location := rec.Header().Get(echo.HeaderLocation)
+	chunk := func(offset, content string) *httptest.ResponseRecorder {
+		return apptest.Do(t, e, http.MethodPatch, location, content, apptest.WithHeader("Upload-Offset", offset))
+	}
+
+	if rec := chunk("0", "hello, chunks"); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("chunk over the limit = %d", rec.Code)
+	}
+	if rec := chunk("0", "hello, "); rec.Code != http.StatusOK || rec.Header().Get("Upload-Offset") != "7" {
+		t.Fatalf("first chunk = %d %s", rec.Code, rec.Body.String())
+	}
+	// A chunk sent again after its response was lost is refused with the offset to resume from
+	if rec := chunk("0", "hello, "); rec.Code != http.StatusConflict || rec.Header().Get("Upload-Offset") != "7" {
+		t.Errorf("repeated chunk = %d, offset %q", rec.Code, rec.Header().Get("Upload-Offset"))
+	}
+	if rec := chunk("7", "too long"); rec.Code != http.StatusBadRequest {
+		t.Errorf("chunk past the size = %d", rec.Code)
+	}
+	if rec := apptest.Do(t, e, http.MethodPost, location+"/complete", nil); rec.Code != http.StatusConflict {
+		t.Errorf("complete before the last chunk = %d", rec.Code)
+	}
+	if rec := chunk("7", "chunks!"); rec.Code != http.StatusOK || rec.Header().Get("Upload-Offset") != "14" {
+		t.Fatalf("last chunk = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = apptest.Do(t, e, http.MethodPost, location+"/complete", nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("complete: %d %s", rec.Code, rec.Body.String())
+	}
+	file := apptest.JSON[model.File](t, rec)
+	if rec := apptest.Do(t, e, http.MethodGet, "/files/"+file.ID, nil); rec.Body.String() != "hello, chunks!" {
+		t.Errorf("completed file = %q", rec.Body.String())
+	}
+	if rec := apptest.Do(t, e, http.MethodGet, location, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("upload after completion = %d", rec.Code)
+	}
+	if parts, _ := storage.Default.List(context.Background(), "uploads/"); len(parts) != 0 {
+		t.Errorf("chunks left behind: %v", parts)
+	}
+}
+
+func TestFileRange(t *testing.T) {
+	apptest.DB(t, &model.File{})
+	useTempStorage(t)
+	files := controller.FileController{MaxBytes: 1 << 20}
+	e := apptest.Echo(t)
+	files.Register(e)
+	file := uploadFile(t, e, "hello.txt", "hello, file")
+	etag := `"` + file.SHA256 + `"`
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"whole file", nil, http.StatusOK, "hello, file", ""},
+		{"range", map[string]string{"Range": "bytes=7-10"}, http.StatusPartialContent, "file", "bytes 7-10/11"},
+		{"suffix", map[string]string{"Range": "bytes=-4"}, http.StatusPartialContent, "file", "bytes 7-10/11"},
+		{"open ended", map[string]string{"Range": "bytes=5-"}, http.StatusPartialContent, ", file", "bytes 5-10/11"},
+		{"unsatisfiable", map[string]string{"Range": "bytes=20-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */11"},
+		{"if-range matching", map[string]string{"Range": "bytes=7-", "If-Range": etag}, http.StatusPartialContent, "file", "bytes 7-10/11"},
+		// The file changed since the client started, it gets all of it again
+		{"if-range stale", map[string]string{"Range": "bytes=7-", "If-Range": `"stale"`}, http.StatusOK, "hello, file", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []apptest.RequestOption
+			for name, value := range tt.headers {
+				opts = append(opts, apptest.WithHeader(name, value))
+			}
+			rec := apptest.Do(t, e, http.MethodGet, "/files/"+file.ID, nil, opts...)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+			if got := rec.Header().Get("Accept-Ranges"); got != "bytes" && tt.status == http.StatusOK {
+				t.Errorf("Accept-Ranges = %q", got)
+			}
+		})
+	}
+}
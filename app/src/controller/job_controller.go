@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"app/binder"
+	"app/service"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const deadJobPageSize = 100
+
+// jobListQuery filters the queue by ?status=, ?limit= is capped at 100 jobs
+type jobListQuery struct {
+	Status string `query:"status"`
+	Limit  int    `query:"limit" default:"100" min:"1" clamp:"100"`
+}
+
+type JobController struct {
+	JobService service.JobService
+}
+
+type EnqueueJobRequest struct {
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload"`
+	Priority string          `json:"priority"`
+	// RunAt (RFC 3339) or Delay (e.g. "1h") defers the job, RunAt wins when both are set
+	RunAt *time.Time `json:"run_at"`
+	Delay string     `json:"delay"`
+}
+
+func (c *JobController) Enqueue(ctx echo.Context) error {
+	req := new(EnqueueJobRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+
+	input := service.EnqueueJobInput{Type: req.Type, Payload: req.Payload, Priority: req.Priority}
+	if len(req.Payload) == 0 {
+		input.Payload = map[string]any{}
+	}
+	switch {
+	case req.RunAt != nil:
+		input.RunAt = *req.RunAt
+	case req.Delay != "":
+		delay, err := time.ParseDuration(req.Delay)
+		if err != nil || delay <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "delay must be a positive duration such as 1h"})
+		}
+		input.RunAt = time.Now().Add(delay)
+	}
+
+	job, err := c.JobService.Enqueue(ctx.Request().Context(), input)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, job)
+}
+
+func (c *JobController) List(ctx echo.Context) error {
+	var query jobListQuery
+	if err := binder.Query(ctx, &query); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	list, err := c.JobService.ListJobs(ctx.Request().Context(), query.Status, query.Limit)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, list)
+}
+
+func (c *JobController) ListDead(ctx echo.Context) error {
+	dead, err := c.JobService.ListDeadJobs(ctx.Request().Context(), deadJobPageSize)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, dead)
+}
+
+func (c *JobController) GetDead(ctx echo.Context) error {
+	dead, err := c.JobService.GetDeadJob(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, dead)
+}
+
+func (c *JobController) Requeue(ctx echo.Context) error {
+	job, err := c.JobService.RequeueDeadJob(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusAccepted, job)
+}
+
+func (c *JobController) Discard(ctx echo.Context) error {
+	if err := c.JobService.DiscardDeadJob(ctx.Request().Context(), ctx.Param("id")); err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
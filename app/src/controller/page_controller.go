@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"app/i18n"
+	"app/model"
+	"app/service"
+	"app/warmup"
+	"context"
+	"net/http"
+	"path"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Number of samples shown on the list page
+const samplePageSize = 50
+
+type PageController struct {
+	SampleService service.SampleService
+}
+
+type samplesPage struct {
+	Samples []model.Sample
+	Message string
+	Error   string
+}
+
+// WarmUp loads the samples of the list page into the service's cache
+func (c *PageController) WarmUp() []warmup.Task {
+	return []warmup.Task{{Name: "samples page", Run: func(ctx context.Context) error {
+		_, err := c.SampleService.ListSamples(ctx, samplePageSize)
+		return err
+	}}}
+}
+
+func (c *PageController) ListSamples(ctx echo.Context) error {
+	return c.renderSamples(ctx, http.StatusOK, samplesPage{})
+}
+
+func (c *PageController) CreateSample(ctx echo.Context) error {
+	message := ctx.FormValue("message")
+	if message == "" {
+		return c.renderSamples(ctx, http.StatusBadRequest, samplesPage{Error: i18n.T(ctx, "error.message_required")})
+	}
+
+	if _, err := c.SampleService.CreateSample(ctx.Request().Context(), message); err != nil {
+		return c.renderSamples(ctx, http.StatusInternalServerError, samplesPage{Message: message, Error: err.Error()})
+	}
+
+	// Post/Redirect/Get so reloading the page does not resubmit the form.
+	// The location is relative because the app is served under a path prefix behind nginx.
+	return ctx.Redirect(http.StatusSeeOther, path.Base(ctx.Request().URL.Path))
+}
+
+func (c *PageController) renderSamples(ctx echo.Context, status int, page samplesPage) error {
+	samples, err := c.SampleService.ListSamples(ctx.Request().Context(), samplePageSize)
+	if err != nil && page.Error == "" {
+		status = http.StatusInternalServerError
+		page.Error = err.Error()
+	}
+	page.Samples = samples
+	return ctx.Render(status, "samples.html", page)
+}
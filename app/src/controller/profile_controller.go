@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"app/apperrors"
+	"app/profiling"
+	"app/service"
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ProfileController struct {
+	ProfileService service.ProfileService
+	// Capturer is nil unless PROFILE_CAPTURE_ENABLED is set
+	Capturer *profiling.Capturer
+}
+
+// Capture takes the same CPU and heap profiles as an automatic capture, the request lasts PROFILE_CPU_DURATION
+func (c *ProfileController) Capture(ctx echo.Context) error {
+	if c.Capturer == nil {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "profile capture is disabled"})
+	}
+	objects, err := c.Capturer.Capture(ctx.Request().Context(), "manual")
+	switch {
+	case errors.Is(err, profiling.ErrCapturing):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil:
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, objects)
+}
+
+func (c *ProfileController) List(ctx echo.Context) error {
+	profiles, err := c.ProfileService.List(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, profiles)
+}
+
+// Download serves a profile for `go tool pprof`
+func (c *ProfileController) Download(ctx echo.Context) error {
+	reader, obj, err := c.ProfileService.Open(ctx.Request().Context(), ctx.Param("pod"), ctx.Param("name"))
+	switch {
+	case apperrors.KindOf(err) == apperrors.NotFound:
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "profile not found"})
+	case err != nil:
+		return errorResponse(ctx, err)
+	}
+	defer reader.Close()
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+path.Base(obj.Key)+`"`)
+	return ctx.Stream(http.StatusOK, "application/octet-stream", reader)
+}
@@ -1,42 +1,723 @@
 package controller
 
 import (
+	"app/binder"
+	"app/config"
+	"app/gen/api"
+	"app/i18n"
+	"app/links"
+	"app/logctx"
+	"app/model"
+	"app/serializer"
 	"app/service"
+	"app/warmup"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
 type SampleController struct {
 	SampleService service.SampleService
+	// CountMode is how offset listings are totalled unless ?count= says otherwise
+	CountMode service.CountMode
+	// CacheControl is sent with the sample and collection reads when set, such as
+	// max-age=5, stale-while-revalidate=30, stale-if-error=300 for callers caching them
+	CacheControl string
 }
 
-type CreateSampleRequest struct {
-	Message string `json:"message"`
+// The request bodies are generated from openapi.json, task openapi regenerates them
+type (
+	CreateSampleRequest = api.SampleRequest
+	SampleLabelsRequest = api.SampleLabelsRequest
+	UpsertSampleRequest = api.SampleUpsertRequest
+)
+
+// Route names used to build the links of a Sample
+const (
+	RouteSampleFirst      = "sample.first"
+	RouteSampleCollection = "sample.collection"
+	RouteSampleCreate     = "sample.create"
+	RouteSampleUpsert     = "sample.upsert"
+	RouteSampleGet        = "sample.get"
+	RouteSampleUpdate     = "sample.update"
+	RouteSamplePatch      = "sample.patch"
+	RouteSampleDelete     = "sample.delete"
+	RouteSampleHistory    = "sample.history"
+	RouteSampleLabels     = "sample.labels"
+)
+
+// Router is what Register adds routes to, satisfied by *echo.Echo, *echo.Group and their routeinfo wrappers
+type Router interface {
+	GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	PATCH(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+}
+
+// SampleController implements the operations of openapi.json, the generated wrapper reads their parameters
+var _ api.ServerInterface = (*SampleController)(nil)
+
+// Register adds the Sample API routes, writeAuth guards the routes that modify samples.
+// Routes are named so links can be built from them.
+func (c *SampleController) Register(router Router, writeAuth ...echo.MiddlewareFunc) {
+	w := &api.ServerInterfaceWrapper{Handler: c}
+	router.GET("/sample", w.GetFirstSample).Name = RouteSampleFirst
+	router.POST("/sample", w.CreateSample, writeAuth...).Name = RouteSampleCreate
+	router.PUT("/sample", w.UpsertSample, writeAuth...).Name = RouteSampleUpsert
+	router.POST("/sample/lookup", w.LookupSamples)
+	router.GET("/sample/changes", w.SampleChanges)
+	router.GET("/sample/stats", w.GetSampleStats)
+	router.GET("/sample/stats/timeseries", w.GetSampleTimeseries)
+	router.GET("/sample/:id", w.GetSample).Name = RouteSampleGet
+	router.GET("/sample/:id/history", w.GetSampleHistory).Name = RouteSampleHistory
+	router.POST("/sample/:id/revert/:revision", w.RevertSample, writeAuth...)
+	router.GET("/sample/:id/labels", w.GetSampleLabels).Name = RouteSampleLabels
+	router.PUT("/sample/:id/labels", w.SetSampleLabels, writeAuth...)
+	router.PUT("/sample/:id", w.UpdateSample, writeAuth...).Name = RouteSampleUpdate
+	router.PATCH("/sample/:id", w.PatchSample, writeAuth...).Name = RouteSamplePatch
+	router.DELETE("/sample/:id", w.DeleteSample, writeAuth...).Name = RouteSampleDelete
+	router.GET("/samples", w.ListSamples).Name = RouteSampleCollection
+}
+
+var sampleRels = []links.Rel{
+	{Name: "self", Route: RouteSampleGet},
+	{Name: "update", Route: RouteSampleUpdate},
+	{Name: "delete", Route: RouteSampleDelete},
+	{Name: "history", Route: RouteSampleHistory},
+	{Name: "labels", Route: RouteSampleLabels},
+	{Name: "collection", Route: RouteSampleCollection},
+}
+
+// sampleResource is a Sample with the links a client can follow from it
+func sampleResource(ctx echo.Context, sample model.Sample, fields []string) serializer.Resource {
+	return serializer.Resource{
+		Type:   "samples",
+		ID:     sample.ID,
+		Value:  sample,
+		Links:  links.Build(ctx, sampleRels, sample.ID),
+		Fields: fields,
+	}
+}
+
+// sampleFields reads ?fields=message,created_at, or fields[samples]= as JSON:API clients send it,
+// and resolves the columns to read for them
+func sampleFields(ctx echo.Context) (fields []string, columns []string, err error) {
+	raw := ctx.QueryParam("fields")
+	if raw == "" {
+		raw = ctx.QueryParam("fields[samples]")
+	}
+	for _, field := range strings.Split(raw, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	columns, err = service.SampleColumns(fields)
+	return fields, columns, err
+}
+
+func fieldsError(ctx echo.Context) error {
+	return ctx.JSON(http.StatusBadRequest, map[string]string{
+		"error": i18n.Translate(ctx, "error.unknown_field", map[string]any{"Fields": strings.Join(service.SampleFieldNames(), ", ")}),
+	})
+}
+
+// GetFirstSample returns the first sample, the samples of ?ids= or with ?stream every sample
+func (c *SampleController) GetFirstSample(ctx echo.Context, params api.GetFirstSampleParams) error {
+	fields, columns, err := sampleFields(ctx)
+	if err != nil {
+		return fieldsError(ctx)
+	}
+
+	if params.Ids != nil && *params.Ids != "" {
+		return c.lookupSamples(ctx, strings.Split(*params.Ids, ","), fields, columns)
+	}
+
+	if mode := streamMode(ctx); mode != "" {
+		return c.streamSamples(ctx, mode, fields, columns)
+	}
+
+	sample, err := c.SampleService.GetSample(columns...)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return serializer.One(ctx, http.StatusOK, sampleResource(ctx, sample, fields))
+}
+
+// Most ids one batch lookup may ask for
+const sampleLookupMaxIDs = 100
+
+type LookupSamplesRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// LookupSamples is the POST form of GET /sample?ids=, for id lists too long for a URL
+func (c *SampleController) LookupSamples(ctx echo.Context, _ api.LookupSamplesParams) error {
+	fields, columns, err := sampleFields(ctx)
+	if err != nil {
+		return fieldsError(ctx)
+	}
+
+	req := new(LookupSamplesRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+	return c.lookupSamples(ctx, req.IDs, fields, columns)
+}
+
+// lookupSamples fetches many samples in one query. The found samples are rendered in the
+// requested order and meta.status tells for every id whether it was found or missing.
+func (c *SampleController) lookupSamples(ctx echo.Context, ids []string, fields []string, columns []string) error {
+	var unique []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 || len(unique) > sampleLookupMaxIDs {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": i18n.Translate(ctx, "error.invalid_lookup_ids", map[string]any{"Max": sampleLookupMaxIDs}),
+		})
+	}
+
+	samples, err := c.SampleService.FindSamples(ctx.Request().Context(), unique, columns...)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	byID := make(map[string]model.Sample, len(samples))
+	for _, sample := range samples {
+		byID[sample.ID] = sample
+	}
+
+	status := make(map[string]string, len(unique))
+	missing := []string{}
+	collection := serializer.Collection{Items: []serializer.Resource{}}
+	for _, id := range unique {
+		sample, ok := byID[id]
+		if !ok {
+			status[id] = "missing"
+			missing = append(missing, id)
+			continue
+		}
+		status[id] = "found"
+		collection.Items = append(collection.Items, sampleResource(ctx, sample, fields))
+	}
+	collection.Meta = map[string]any{
+		"requested": len(unique),
+		"found":     len(collection.Items),
+		"missing":   missing,
+		"status":    status,
+	}
+	return serializer.Many(ctx, http.StatusOK, collection)
+}
+
+// Longest a change request is held open. It stays below SHUTDOWN_TIMEOUT so waiting requests
+// are answered while the server drains, and below the proxy read timeouts (60s on nginx).
+var sampleChangesMaxWait = config.Duration("SAMPLE_CHANGES_MAX_WAIT", 20*time.Second)
+
+// SampleChanges long-polls the change feed: it answers as soon as samples change after ?since,
+// or with no changes after ?timeout seconds (the maximum wait by default)
+func (c *SampleController) SampleChanges(ctx echo.Context, _ api.SampleChangesParams) error {
+	// The maximum wait is only known at runtime, it is the value timeout keeps when absent
+	query := struct {
+		Since   string        `query:"since"`
+		Timeout time.Duration `query:"timeout" min:"0s"`
+	}{Timeout: sampleChangesMaxWait}
+	if err := binder.Query(ctx, &query); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.invalid_timeout")})
+	}
+	wait := min(query.Timeout, sampleChangesMaxWait)
+
+	feed, err := c.SampleService.WaitForChanges(ctx.Request().Context(), query.Since, wait)
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The client went away, there is nobody to answer
+		return nil
+	case err != nil:
+		return errorResponse(ctx, err)
+	}
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(http.StatusOK, feed)
+}
+
+// sampleListQuery pages the list, a bigger limit is lowered to the maximum page size
+type sampleListQuery struct {
+	Limit  int `query:"limit" default:"20" min:"1" clamp:"100"`
+	Offset int `query:"offset" min:"0"`
 }
 
-func (c *SampleController) GetSample(ctx echo.Context) error {
-	sample, err := c.SampleService.GetSample()
+// ListSamples pages through samples newest first. With ?cursor (empty for the first page)
+// it pages by keyset and follows the next link's cursor, otherwise by ?offset
+// with a total per ?count=exact|estimate|none. ?selector=env=prod,tier!=cache only lists
+// the samples whose labels match, as kubectl get -l does, and ?filter=message ~ "hello"
+// the samples matching a filter expression.
+func (c *SampleController) ListSamples(ctx echo.Context, _ api.ListSamplesParams) error {
+	fields, columns, err := sampleFields(ctx)
+	if err != nil {
+		return fieldsError(ctx)
+	}
+	selector, err := service.ParseLabelSelector(ctx.QueryParam("selector"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.invalid_selector"), "details": err.Error()})
+	}
+	where, err := service.ParseSampleFilter(ctx.QueryParam("filter"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.invalid_filter"), "details": err.Error()})
+	}
+
+	var query sampleListQuery
+	if err := binder.Query(ctx, &query); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.invalid_pagination")})
+	}
+	limit, offset := query.Limit, query.Offset
+	count := cmp.Or(c.CountMode, service.CountExact)
+	if raw := ctx.QueryParam("count"); raw != "" {
+		if count, err = service.ParseCountMode(raw); err != nil {
+			return errorResponse(ctx, err)
+		}
+	}
+	// Table statistics know nothing of labels or filters
+	if count == service.CountEstimate && (!selector.Empty() || !where.Empty()) {
+		count = service.CountExact
+	}
+
+	// The next link keeps the limit, count mode, selector, filter and field selection of this request
+	next := url.Values{"limit": {strconv.Itoa(limit)}}
+	for _, key := range []string{"fields", "fields[samples]", "count", "selector", "filter"} {
+		if raw := ctx.QueryParam(key); raw != "" {
+			next.Set(key, raw)
+		}
+	}
+
+	// Only narrowed listings pass scopes, the unnarrowed first page is cached by the service
+	var scopes []service.Scope
+	if !selector.Empty() {
+		scopes = append(scopes, selector.Scope)
+	}
+	if !where.Empty() {
+		scopes = append(scopes, where.Scope)
+	}
+
+	var page service.SamplePage
+	meta := map[string]any{"limit": limit}
+	if ctx.QueryParams().Has("cursor") {
+		page, err = c.SampleService.SampleCursorPage(ctx.Request().Context(), ctx.QueryParam("cursor"), limit, columns, scopes...)
+		next.Set("cursor", page.NextCursor)
+	} else {
+		page, err = c.SampleService.SampleOffsetPage(ctx.Request().Context(), offset, limit, columns, count, scopes...)
+		meta["offset"] = offset
+		meta["count"] = count
+		switch count {
+		case service.CountExact:
+			meta["total"] = page.Total
+		case service.CountEstimate:
+			meta["total_estimate"] = page.Total
+		}
+		next.Set("offset", strconv.Itoa(offset+limit))
+	}
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return errorResponse(ctx, err)
+	}
+	meta["has_more"] = page.HasMore
+
+	collection := serializer.Collection{Meta: meta, Links: links.Links{}}
+	for _, sample := range page.Samples {
+		collection.Items = append(collection.Items, sampleResource(ctx, sample, fields))
 	}
-	return ctx.JSON(http.StatusOK, sample)
+	if page.HasMore {
+		collection.Links = links.Build(ctx, []links.Rel{{Name: "next", Route: RouteSampleCollection}})
+		if link, ok := collection.Links["next"]; ok {
+			collection.Links["next"] = link.WithQuery(next)
+		}
+	}
+	c.setCacheControl(ctx)
+	return serializer.Many(ctx, http.StatusOK, collection)
 }
 
-func (c *SampleController) PostSample(ctx echo.Context) error {
+// HeaderDryRun asks for a dry run like ?dry_run=true and is echoed on dry run responses
+const HeaderDryRun = "X-Dry-Run"
+
+// writer returns the service for a write request. With ?dry_run=true or X-Dry-Run: true the write
+// is validated and run in a transaction that is rolled back, and the response shows what it would have stored.
+// The query wins over the header, the generated wrapper has already answered 400 to values that are no bool.
+func (c *SampleController) writer(ctx echo.Context, query *api.DryRun, header *api.DryRunHeader) service.SampleService {
+	samples := c.SampleService
+	if dryRun := cmp.Or(query, header); dryRun != nil && *dryRun {
+		samples.DryRun = true
+		ctx.Response().Header().Set(HeaderDryRun, "true")
+	}
+	return samples
+}
+
+func (c *SampleController) CreateSample(ctx echo.Context, params api.CreateSampleParams) error {
+	fields, _, err := sampleFields(ctx)
+	if err != nil {
+		return fieldsError(ctx)
+	}
+	samples := c.writer(ctx, params.DryRun, params.XDryRun)
+
 	req := new(CreateSampleRequest)
 	if err := ctx.Bind(req); err != nil {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return bindError(ctx, err)
 	}
 
 	if req.Message == "" {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "message is required"})
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.message_required")})
 	}
 
-	sample, err := c.SampleService.CreateSample(req.Message)
+	sample, err := samples.CreateSample(ctx.Request().Context(), req.Message)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return errorResponse(ctx, err)
 	}
 
-	return ctx.JSON(http.StatusCreated, sample)
-}
\ No newline at end of file
+	return serializer.One(ctx, http.StatusCreated, sampleResource(ctx, sample, fields))
+}
+
+// HeaderUpsertResult tells whether an upsert created or updated the sample, as 201 and 200 do
+const HeaderUpsertResult = "X-Upsert-Result"
+
+// UpsertSample creates or updates the sample with the natural key in the body, answering 201 or 200
+func (c *SampleController) UpsertSample(ctx echo.Context, params api.UpsertSampleParams) error {
+	fields, _, err := sampleFields(ctx)
+	if err != nil {
+		return fieldsError(ctx)
+	}
+	samples := c.writer(ctx, params.DryRun, params.XDryRun)
+
+	req := new(UpsertSampleRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+	if !service.ValidSampleKey(req.Key) {
+		return sampleKeyError(ctx)
+	}
+	if req.Message == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.message_required")})
+	}
+
+	sample, created, err := samples.UpsertSample(ctx.Request().Context(), req.Key, req.Message)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	status := http.StatusOK
+	ctx.Response().Header().Set(HeaderUpsertResult, "updated")
+	if created {
+		status = http.StatusCreated
+		ctx.Response().Header().Set(HeaderUpsertResult, "created")
+	}
+	return serializer.One(ctx, status, sampleResource(ctx, sample, fields))
+}
+
+func sampleKeyError(ctx echo.Context) error {
+	return ctx.JSON(http.StatusBadRequest, map[string]string{
+		"error": i18n.Translate(ctx, "error.invalid_sample_key", map[string]any{"Max": service.SampleKeyMaxLength}),
+	})
+}
+
+// Patch media types, application/json is read as a merge patch
+const (
+	MIMEMergePatch = "application/merge-patch+json"
+	MIMEJSONPatch  = "application/json-patch+json"
+)
+
+// Largest patch body read, a patch only touches the message and the key
+const samplePatchMaxBytes = 64 << 10
+
+// PatchSample changes only the fields a JSON Merge Patch (RFC 7386) or JSON Patch (RFC 6902) touches
+func (c *SampleController) PatchSample(ctx echo.Context, id string, params api.PatchSampleParams) error {
+	fields, _, err := sampleFields(ctx)
+	if err != nil {
+		return fieldsError(ctx)
+	}
+	samples := c.writer(ctx, params.DryRun, params.XDryRun)
+
+	var format service.PatchFormat
+	mediaType, _, _ := mime.ParseMediaType(ctx.Request().Header.Get(echo.HeaderContentType))
+	switch mediaType {
+	case MIMEMergePatch, echo.MIMEApplicationJSON:
+		format = service.MergePatch
+	case MIMEJSONPatch:
+		format = service.JSONPatch
+	default:
+		ctx.Response().Header().Set("Accept-Patch", MIMEMergePatch+", "+MIMEJSONPatch)
+		return ctx.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": i18n.T(ctx, "error.unsupported_media_type")})
+	}
+	patch, err := io.ReadAll(io.LimitReader(ctx.Request().Body, samplePatchMaxBytes))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.read_body_failed")})
+	}
+
+	sample, err := samples.PatchSample(ctx.Request().Context(), id, format, patch)
+	switch {
+	case errors.Is(err, service.ErrInvalidSampleKey):
+		return sampleKeyError(ctx)
+	case errors.Is(err, service.ErrSampleMessageEmpty):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.message_required")})
+	case err != nil:
+		return errorResponse(ctx, err)
+	}
+	return serializer.One(ctx, http.StatusOK, sampleResource(ctx, sample, fields))
+}
+
+func (c *SampleController) GetSample(ctx echo.Context, id string, _ api.GetSampleParams) error {
+	fields, columns, err := sampleFields(ctx)
+	if err != nil {
+		return fieldsError(ctx)
+	}
+
+	sample, err := c.SampleService.FindSample(ctx.Request().Context(), id, columns...)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	c.setCacheControl(ctx)
+	return serializer.One(ctx, http.StatusOK, sampleResource(ctx, sample, fields))
+}
+
+func (c *SampleController) setCacheControl(ctx echo.Context) {
+	if c.CacheControl != "" {
+		ctx.Response().Header().Set(echo.HeaderCacheControl, c.CacheControl)
+	}
+}
+
+func (c *SampleController) UpdateSample(ctx echo.Context, id string, params api.UpdateSampleParams) error {
+	fields, _, err := sampleFields(ctx)
+	if err != nil {
+		return fieldsError(ctx)
+	}
+	samples := c.writer(ctx, params.DryRun, params.XDryRun)
+
+	req := new(CreateSampleRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+
+	if req.Message == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.message_required")})
+	}
+
+	sample, err := samples.UpdateSample(ctx.Request().Context(), id, req.Message)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return serializer.One(ctx, http.StatusOK, sampleResource(ctx, sample, fields))
+}
+
+// GetSampleHistory lists the field changes of every write to the sample, newest revision first
+func (c *SampleController) GetSampleHistory(ctx echo.Context, id string) error {
+	revisions, err := c.SampleService.History(ctx.Request().Context(), id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"sample_id": id, "revisions": revisions})
+}
+
+// sampleStatsMaxDays is the max of sampleStatsQuery.Days, for the error message
+const sampleStatsMaxDays = 366
+
+type sampleStatsQuery struct {
+	Days int    `query:"days" default:"30" min:"1" max:"366"`
+	Tag  string `query:"tag"`
+}
+
+// GetSampleStats returns the daily write counters of the sample_stats read model for the last ?days=,
+// of all samples or with ?tag=env=prod of the samples carrying that label
+func (c *SampleController) GetSampleStats(ctx echo.Context, _ api.GetSampleStatsParams) error {
+	var query sampleStatsQuery
+	if err := binder.Query(ctx, &query); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{
+			"error": i18n.Translate(ctx, "error.invalid_stats_days", map[string]any{"Max": sampleStatsMaxDays}),
+		})
+	}
+	stats, err := c.SampleService.Stats(ctx.Request().Context(), query.Days, query.Tag)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"days": query.Days, "tag": query.Tag, "stats": stats})
+}
+
+// WarmUp loads what ListSamples and GetSampleStats read with their default query, the first page and
+// the totals of the last 30 days, into the service's cache before the pod serves them
+func (c *SampleController) WarmUp() []warmup.Task {
+	return []warmup.Task{
+		{Name: "samples first page", Run: func(ctx context.Context) error {
+			_, err := c.SampleService.SampleOffsetPage(ctx, 0, 20, nil, cmp.Or(c.CountMode, service.CountExact))
+			return err
+		}},
+		{Name: "sample stats", Run: func(ctx context.Context) error {
+			_, err := c.SampleService.Stats(ctx, 30, "")
+			return err
+		}},
+	}
+}
+
+// GetSampleTimeseries counts the samples ?event=created|updated|deleted per ?window= over the last ?range=,
+// e.g. window=1h&range=7d, aggregated by the database straight from the samples table
+func (c *SampleController) GetSampleTimeseries(ctx echo.Context, _ api.GetSampleTimeseriesParams) error {
+	query := struct {
+		Event  string `query:"event" default:"created"`
+		Window string `query:"window" default:"1h"`
+		Range  string `query:"range" default:"7d"`
+	}{}
+	if err := binder.Query(ctx, &query); err != nil {
+		return timeseriesError(ctx, err)
+	}
+	event := query.Event
+	window, err := service.ParseSpan(query.Window)
+	if err != nil {
+		return timeseriesError(ctx, err)
+	}
+	span, err := service.ParseSpan(query.Range)
+	if err != nil {
+		return timeseriesError(ctx, err)
+	}
+	buckets, err := c.SampleService.SampleTimeseries(ctx.Request().Context(), event, window, span)
+	if errors.Is(err, service.ErrInvalidTimeseries) {
+		return timeseriesError(ctx, err)
+	}
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"event":   event,
+		"window":  window.String(),
+		"range":   span.String(),
+		"buckets": buckets,
+	})
+}
+
+func timeseriesError(ctx echo.Context, err error) error {
+	return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.invalid_timeseries"), "details": err.Error()})
+}
+
+// GetSampleLabels returns the labels of the sample
+func (c *SampleController) GetSampleLabels(ctx echo.Context, id string) error {
+	set, err := c.SampleService.SampleLabels(ctx.Request().Context(), id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"sample_id": id, "labels": set})
+}
+
+// SetSampleLabels replaces the labels of the sample, an empty object removes them all
+func (c *SampleController) SetSampleLabels(ctx echo.Context, id string, params api.SetSampleLabelsParams) error {
+	samples := c.writer(ctx, params.DryRun, params.XDryRun)
+	var req SampleLabelsRequest
+	if err := ctx.Bind(&req); err != nil {
+		return bindError(ctx, err)
+	}
+
+	set, err := samples.SetSampleLabels(ctx.Request().Context(), id, req.Labels)
+	if errors.Is(err, service.ErrInvalidLabel) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.invalid_label"), "details": err.Error()})
+	}
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"sample_id": id, "labels": set})
+}
+
+// RevertSample restores the sample to its state right after a revision of its history
+func (c *SampleController) RevertSample(ctx echo.Context, id string, revision int, params api.RevertSampleParams) error {
+	fields, _, err := sampleFields(ctx)
+	if err != nil {
+		return fieldsError(ctx)
+	}
+	samples := c.writer(ctx, params.DryRun, params.XDryRun)
+	if revision < 1 {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.invalid_revision")})
+	}
+
+	sample, err := samples.RevertSample(ctx.Request().Context(), id, revision)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return serializer.One(ctx, http.StatusOK, sampleResource(ctx, sample, fields))
+}
+
+func (c *SampleController) DeleteSample(ctx echo.Context, id string, params api.DeleteSampleParams) error {
+	samples := c.writer(ctx, params.DryRun, params.XDryRun)
+	if err := samples.DeleteSample(ctx.Request().Context(), id); err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+const mimeNDJSON = "application/x-ndjson"
+
+// streamMode picks the streaming format from ?stream=ndjson|json or an NDJSON Accept header,
+// "" keeps the single record response
+func streamMode(ctx echo.Context) string {
+	switch mode := ctx.QueryParam("stream"); mode {
+	case "ndjson", "json":
+		return mode
+	}
+	if strings.Contains(ctx.Request().Header.Get(echo.HeaderAccept), mimeNDJSON) {
+		return "ndjson"
+	}
+	return ""
+}
+
+// streamSamples writes every Sample as it is read from the database, flushing after each batch,
+// so memory use stays flat however large the table is
+func (c *SampleController) streamSamples(ctx echo.Context, mode string, fields []string, columns []string) error {
+	res := ctx.Response()
+	if mode == "ndjson" {
+		res.Header().Set(echo.HeaderContentType, mimeNDJSON)
+	} else {
+		res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	// Keep nginx from buffering the whole response
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(res)
+	first := true
+	if mode == "json" {
+		res.Write([]byte("["))
+	}
+	err := service.EachSample(ctx.Request().Context(), columns, func(batch []model.Sample) error {
+		for i := range batch {
+			if mode == "json" && !first {
+				if _, err := res.Write([]byte(",")); err != nil {
+					return err
+				}
+			}
+			first = false
+			raw, err := json.Marshal(&batch[i])
+			if err != nil {
+				return err
+			}
+			if raw, err = serializer.Pick(raw, fields); err != nil {
+				return err
+			}
+			if err := encoder.Encode(json.RawMessage(raw)); err != nil {
+				return err
+			}
+		}
+		res.Flush()
+		return nil
+	})
+
+	// The status is already sent, a failure can only cut the body short.
+	// The JSON array is left unterminated so clients notice the truncation.
+	if err != nil {
+		logctx.From(ctx).Error("sample stream aborted", "mode", mode, "error", err)
+		return nil
+	}
+	if mode == "json" {
+		res.Write([]byte("]\n"))
+	}
+	return nil
+}
@@ -0,0 +1,767 @@
+package controller_test
+
+import (
+	"app/apptest"
+	"app/controller"
+	"app/model"
+	"app/openapi"
+	"app/serializer"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type sampleBody struct {
+	ID        string                     `json:"id"`
+	Message   string                     `json:"message"`
+	Key       string                     `json:"key"`
+	CreatedAt *time.Time                 `json:"created_at"`
+	Links     map[string]json.RawMessage `json:"_links"`
+}
+
+type collectionBody struct {
+	Items []sampleBody   `json:"items"`
+	Meta  map[string]any `json:"meta"`
+	Links map[string]struct {
+		Href string `json:"href"`
+	} `json:"_links"`
+}
+
+// setup returns a router serving the Sample API on an empty database,
+// every response is checked against openapi.json
+func setup(t *testing.T) *echo.Echo {
+	t.Helper()
+	apptest.DB(t)
+	router := apptest.Echo(t)
+	(&controller.SampleController{}).Register(router)
+	apptest.Contract(t, router)
+	return router.Echo
+}
+
+// seedSamples creates samples one second apart, the last one newest
+func seedSamples(t *testing.T, messages ...string) []model.Sample {
+	t.Helper()
+	start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	samples := make([]model.Sample, len(messages))
+	for i, message := range messages {
+		at := start.Add(time.Duration(i) * time.Second)
+		samples[i] = model.Sample{Message: message, CreatedAt: at, UpdatedAt: at}
+		apptest.Seed(t, &samples[i])
+	}
+	return samples
+}
+
+func TestGetSample(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		status int
+		check  func(t *testing.T, body sampleBody)
+	}{
+		{
+			name:   "creates the first sample when there is none",
+			target: "/sample",
+			status: http.StatusOK,
+			check: func(t *testing.T, body sampleBody) {
+				if body.Message != "Hello from MySQL via GORM!" {
+					t.Errorf("message = %q", body.Message)
+				}
+				for _, rel := range []string{"self", "update", "delete", "collection"} {
+					if _, ok := body.Links[rel]; !ok {
+						t.Errorf("missing %s link", rel)
+					}
+				}
+			},
+		},
+		{
+			name:   "selects fields",
+			target: "/sample?fields=message",
+			status: http.StatusOK,
+			check: func(t *testing.T, body sampleBody) {
+				if body.ID != "" || body.CreatedAt != nil {
+					t.Errorf("unselected fields rendered: %+v", body)
+				}
+				if body.Message == "" {
+					t.Error("message missing")
+				}
+			},
+		},
+		{
+			name:   "rejects unknown fields",
+			target: "/sample?fields=name",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setup(t)
+			rec := apptest.Do(t, router, http.MethodGet, tt.target, nil)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.check != nil {
+				tt.check(t, apptest.JSON[sampleBody](t, rec))
+			}
+		})
+	}
+}
+
+func TestGetSampleJSONAPI(t *testing.T) {
+	router := setup(t)
+	rec := apptest.Do(t, router, http.MethodGet, "/sample", nil, apptest.WithHeader(echo.HeaderAccept, serializer.MIMEJSONAPI))
+	if got := rec.Header().Get(echo.HeaderContentType); got != serializer.MIMEJSONAPI {
+		t.Fatalf("content type = %q", got)
+	}
+
+	body := apptest.JSON[struct {
+		Data struct {
+			Type       string         `json:"type"`
+			ID         string         `json:"id"`
+			Attributes map[string]any `json:"attributes"`
+		} `json:"data"`
+	}](t, rec)
+	if body.Data.Type != "samples" || body.Data.ID == "" {
+		t.Errorf("data = %+v", body.Data)
+	}
+	if _, ok := body.Data.Attributes["id"]; ok {
+		t.Error("id rendered as an attribute")
+	}
+}
+
+func TestStreamSamples(t *testing.T) {
+	router := setup(t)
+	seedSamples(t, "a", "b", "c")
+
+	rec := apptest.Do(t, router, http.MethodGet, "/sample?stream=ndjson", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if lines := strings.Count(rec.Body.String(), "\n"); lines != 3 {
+		t.Errorf("lines = %d, want 3", lines)
+	}
+
+	rec = apptest.Do(t, router, http.MethodGet, "/sample?stream=json", nil)
+	if samples := apptest.JSON[[]sampleBody](t, rec); len(samples) != 3 {
+		t.Errorf("samples = %d, want 3", len(samples))
+	}
+}
+
+func TestWriteSample(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target func(existing model.Sample) string
+		body   any
+		status int
+	}{
+		{"create", http.MethodPost, func(model.Sample) string { return "/sample" }, map[string]string{"message": "new"}, http.StatusCreated},
+		{"create without message", http.MethodPost, func(model.Sample) string { return "/sample" }, map[string]string{"message": ""}, http.StatusBadRequest},
+		{"create with unknown field", http.MethodPost, func(model.Sample) string { return "/sample" }, map[string]string{"message": "x", "extra": "y"}, http.StatusBadRequest},
+		{"update", http.MethodPut, func(s model.Sample) string { return "/sample/" + s.ID }, map[string]string{"message": "changed"}, http.StatusOK},
+		{"update missing", http.MethodPut, func(model.Sample) string { return "/sample/missing" }, map[string]string{"message": "changed"}, http.StatusNotFound},
+		{"delete", http.MethodDelete, func(s model.Sample) string { return "/sample/" + s.ID }, nil, http.StatusNoContent},
+		{"delete missing", http.MethodDelete, func(model.Sample) string { return "/sample/missing" }, nil, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setup(t)
+			existing := seedSamples(t, "existing")[0]
+
+			rec := apptest.Do(t, router, tt.method, tt.target(existing), tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestUpsertSample(t *testing.T) {
+	router := setup(t)
+	steps := []struct {
+		body    map[string]string
+		status  int
+		result  string
+		message string
+	}{
+		{map[string]string{"key": "greeting", "message": "hello"}, http.StatusCreated, "created", "hello"},
+		{map[string]string{"key": "greeting", "message": "hello again"}, http.StatusOK, "updated", "hello again"},
+		{map[string]string{"key": "not a key", "message": "x"}, http.StatusBadRequest, "", ""},
+		{map[string]string{"key": "greeting"}, http.StatusBadRequest, "", ""},
+	}
+
+	var id string
+	for _, step := range steps {
+		rec := apptest.Do(t, router, http.MethodPut, "/sample", step.body)
+		if rec.Code != step.status {
+			t.Fatalf("%v: status = %d, want %d: %s", step.body, rec.Code, step.status, rec.Body)
+		}
+		if got := rec.Header().Get(controller.HeaderUpsertResult); got != step.result {
+			t.Errorf("%v: %s = %q, want %q", step.body, controller.HeaderUpsertResult, got, step.result)
+		}
+		if step.message == "" {
+			continue
+		}
+		body := apptest.JSON[sampleBody](t, rec)
+		if body.Message != step.message || body.Key != "greeting" {
+			t.Errorf("%v: body = %+v", step.body, body)
+		}
+		if id == "" {
+			id = body.ID
+		} else if body.ID != id {
+			t.Errorf("upsert created a second sample %s, want %s updated", body.ID, id)
+		}
+	}
+}
+
+func TestPatchSample(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		message     string
+		key         string
+	}{
+		{"merge message", controller.MIMEMergePatch, `{"message":"patched"}`, http.StatusOK, "patched", "existing-key"},
+		{"merge removes key", controller.MIMEMergePatch, `{"key":null}`, http.StatusOK, "existing", ""},
+		{"merge as plain json", echo.MIMEApplicationJSON, `{"key":"other"}`, http.StatusOK, "existing", "other"},
+		{"merge empty message", controller.MIMEMergePatch, `{"message":""}`, http.StatusBadRequest, "", ""},
+		{"merge unknown field", controller.MIMEMergePatch, `{"id":"forged"}`, http.StatusBadRequest, "", ""},
+		{"merge invalid key", controller.MIMEMergePatch, `{"key":"not a key"}`, http.StatusBadRequest, "", ""},
+		{"json patch", controller.MIMEJSONPatch, `[{"op":"test","path":"/message","value":"existing"},{"op":"replace","path":"/message","value":"patched"}]`, http.StatusOK, "patched", "existing-key"},
+		{"json patch failed test", controller.MIMEJSONPatch, `[{"op":"test","path":"/message","value":"stale"},{"op":"replace","path":"/message","value":"patched"}]`, http.StatusConflict, "", ""},
+		{"json patch removes message", controller.MIMEJSONPatch, `[{"op":"remove","path":"/message"}]`, http.StatusBadRequest, "", ""},
+		{"unsupported media type", "text/plain", `message=patched`, http.StatusUnsupportedMediaType, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setup(t)
+			key := "existing-key"
+			existing := model.Sample{Message: "existing", Key: &key}
+			apptest.Seed(t, &existing)
+
+			rec := apptest.Do(t, router, http.MethodPatch, "/sample/"+existing.ID, tt.body, apptest.WithHeader(echo.HeaderContentType, tt.contentType))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if body := apptest.JSON[sampleBody](t, rec); body.Message != tt.message || body.Key != tt.key {
+				t.Errorf("message, key = %q, %q, want %q, %q", body.Message, body.Key, tt.message, tt.key)
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		router := setup(t)
+		rec := apptest.Do(t, router, http.MethodPatch, "/sample/missing", `{"message":"x"}`, apptest.WithHeader(echo.HeaderContentType, controller.MIMEMergePatch))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want 404: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+type historyBody struct {
+	Revisions []struct {
+		Revision int    `json:"revision"`
+		Action   string `json:"action"`
+		Changes  []struct {
+			Field string  `json:"field"`
+			Old   *string `json:"old"`
+			New   *string `json:"new"`
+		} `json:"changes"`
+	} `json:"revisions"`
+}
+
+func TestSampleHistory(t *testing.T) {
+	router := setup(t)
+	created := apptest.JSON[sampleBody](t, apptest.Do(t, router, http.MethodPost, "/sample", map[string]string{"message": "first"}))
+	target := "/sample/" + created.ID
+	apptest.Do(t, router, http.MethodPut, target, map[string]string{"message": "second"})
+	// Writing the same message again changes nothing and adds no revision
+	apptest.Do(t, router, http.MethodPut, target, map[string]string{"message": "second"})
+	apptest.Do(t, router, http.MethodPatch, target, `{"key":"k1"}`, apptest.WithHeader(echo.HeaderContentType, controller.MIMEMergePatch))
+	apptest.Do(t, router, http.MethodDelete, target, nil)
+
+	rec := apptest.Do(t, router, http.MethodGet, target+"/history", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	history := apptest.JSON[historyBody](t, rec)
+	var got []string
+	for _, revision := range history.Revisions {
+		for _, change := range revision.Changes {
+			got = append(got, fmt.Sprintf("%d %s %s %s>%s", revision.Revision, revision.Action, change.Field, deref(change.Old), deref(change.New)))
+		}
+	}
+	want := []string{
+		"4 delete deleted_at >set",
+		"3 update key >k1",
+		"2 update message first>second",
+		"1 create message >first",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("history =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if rec := apptest.Do(t, router, http.MethodGet, "/sample/missing/history", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("missing sample: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestRevertSample(t *testing.T) {
+	router := setup(t)
+	created := apptest.JSON[sampleBody](t, apptest.Do(t, router, http.MethodPost, "/sample", map[string]string{"message": "first"}))
+	target := "/sample/" + created.ID
+	apptest.Do(t, router, http.MethodPut, target, map[string]string{"message": "second"})
+	apptest.Do(t, router, http.MethodPatch, target, `{"message":"third","key":"k1"}`, apptest.WithHeader(echo.HeaderContentType, controller.MIMEMergePatch))
+	apptest.Do(t, router, http.MethodDelete, target, nil)
+
+	rec := apptest.Do(t, router, http.MethodPost, target+"/revert/2", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revert: status = %d: %s", rec.Code, rec.Body)
+	}
+	if body := apptest.JSON[sampleBody](t, rec); body.Message != "second" || body.Key != "" {
+		t.Errorf("reverted = %+v, want message second without key", body)
+	}
+	// Revision 2 predates the deletion, so the sample is back
+	if rec := apptest.Do(t, router, http.MethodGet, target, nil); rec.Code != http.StatusOK {
+		t.Errorf("reverted sample: status = %d, want 200", rec.Code)
+	}
+
+	history := apptest.JSON[historyBody](t, apptest.Do(t, router, http.MethodGet, target+"/history", nil))
+	if latest := history.Revisions[0]; latest.Revision != 5 || latest.Action != "revert" || len(latest.Changes) != 3 {
+		t.Errorf("latest revision = %+v, want revision 5 reverting message, key and deleted_at", latest)
+	}
+
+	for _, tt := range []struct {
+		target string
+		status int
+	}{
+		{target + "/revert/99", http.StatusNotFound},
+		{target + "/revert/zero", http.StatusBadRequest},
+		{"/sample/missing/revert/1", http.StatusNotFound},
+	} {
+		if rec := apptest.Do(t, router, http.MethodPost, tt.target, nil); rec.Code != tt.status {
+			t.Errorf("POST %s: status = %d, want %d", tt.target, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestLabelHistory(t *testing.T) {
+	router := setup(t)
+	created := apptest.JSON[sampleBody](t, apptest.Do(t, router, http.MethodPost, "/sample", map[string]string{"message": "first"}))
+	target := "/sample/" + created.ID
+	for _, labels := range []map[string]string{{"env": "prod"}, {"env": "prod"}, {"env": "dev", "tier": "web"}} {
+		if rec := apptest.Do(t, router, http.MethodPut, target+"/labels", map[string]any{"labels": labels}); rec.Code != http.StatusOK {
+			t.Fatalf("set labels: status = %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	history := apptest.JSON[historyBody](t, apptest.Do(t, router, http.MethodGet, target+"/history", nil))
+	// Setting the same labels again is not a revision
+	if len(history.Revisions) != 3 {
+		t.Fatalf("revisions = %d, want create and two label updates", len(history.Revisions))
+	}
+	latest := history.Revisions[0]
+	if len(latest.Changes) != 1 || latest.Changes[0].Field != "labels" ||
+		deref(latest.Changes[0].Old) != `{"env":"prod"}` || deref(latest.Changes[0].New) != `{"env":"dev","tier":"web"}` {
+		t.Errorf("latest revision = %+v, want the labels change", latest)
+	}
+
+	if rec := apptest.Do(t, router, http.MethodPost, target+"/revert/2", nil); rec.Code != http.StatusOK {
+		t.Fatalf("revert: status = %d: %s", rec.Code, rec.Body)
+	}
+	labels := apptest.JSON[struct {
+		Labels map[string]string `json:"labels"`
+	}](t, apptest.Do(t, router, http.MethodGet, target+"/labels", nil))
+	if !maps.Equal(labels.Labels, map[string]string{"env": "prod"}) {
+		t.Errorf("labels after reverting to revision 2 = %v, want env=prod", labels.Labels)
+	}
+}
+
+// deref shows a change value, timestamps as "set" since they differ on every run
+func deref(value *string) string {
+	switch {
+	case value == nil:
+		return ""
+	case strings.Contains(*value, "T") && strings.HasSuffix(*value, "Z"):
+		return "set"
+	default:
+		return *value
+	}
+}
+
+func TestListSamplesBySelector(t *testing.T) {
+	router := setup(t)
+	samples := seedSamples(t, "prod web", "prod cache", "dev web", "unlabelled")
+	for i, labels := range []map[string]string{
+		{"env": "prod", "tier": "web"},
+		{"env": "prod", "tier": "cache"},
+		{"env": "dev", "tier": "web", "canary": "true"},
+	} {
+		rec := apptest.Do(t, router, http.MethodPut, "/sample/"+samples[i].ID+"/labels", map[string]any{"labels": labels})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("set labels: status = %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{"env=prod", []string{"prod cache", "prod web"}},
+		{"env==prod,tier!=cache", []string{"prod web"}},
+		// != matches samples without the label, as in Kubernetes
+		{"tier!=cache", []string{"unlabelled", "dev web", "prod web"}},
+		{"env in (dev,staging)", []string{"dev web"}},
+		{"env notin (dev),tier", []string{"prod cache", "prod web"}},
+		{"canary", []string{"dev web"}},
+		{"!canary", []string{"unlabelled", "prod cache", "prod web"}},
+		{"env=prod' OR 1=1 --", nil},
+	}
+	for _, tt := range tests {
+		rec := apptest.Do(t, router, http.MethodGet, "/samples?selector="+url.QueryEscape(tt.selector), nil)
+		if tt.want == nil {
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s: status = %d, want 400", tt.selector, rec.Code)
+			}
+			continue
+		}
+		var got []string
+		for _, item := range apptest.JSON[collectionBody](t, rec).Items {
+			got = append(got, item.Message)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+
+	page := apptest.JSON[collectionBody](t, apptest.Do(t, router, http.MethodGet, "/samples?limit=1&selector=env%3Dprod", nil))
+	if page.Meta["total"] != float64(2) {
+		t.Errorf("total = %v, want 2", page.Meta["total"])
+	}
+	if next, _ := url.Parse(page.Links["next"].Href); next == nil || next.Query().Get("selector") != "env=prod" {
+		t.Errorf("next link %q does not keep the selector", page.Links["next"].Href)
+	}
+
+	rec := apptest.Do(t, router, http.MethodPut, "/sample/"+samples[0].ID+"/labels", map[string]any{"labels": map[string]string{"bad name": "x"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid label: status = %d, want 400", rec.Code)
+	}
+	if rec := apptest.Do(t, router, http.MethodGet, "/sample/missing/labels", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("missing sample: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestListSamplesByFilter(t *testing.T) {
+	router := setup(t)
+	samples := seedSamples(t, "hello world", "100% done", "goodbye")
+	created := samples[1].CreatedAt.UTC().Format(time.RFC3339Nano)
+
+	tests := []struct {
+		filter string
+		status int
+		want   []string
+	}{
+		{`message ~ "o"`, http.StatusOK, []string{"goodbye", "100% done", "hello world"}},
+		{`message ~ "%"`, http.StatusOK, []string{"100% done"}},
+		{`created_at >= "` + created + `" AND NOT message = goodbye`, http.StatusOK, []string{"100% done"}},
+		{`message = "hello world" OR key != null`, http.StatusOK, []string{"hello world"}},
+		{`message = x OR 1 = 1`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := apptest.Do(t, router, http.MethodGet, "/samples?filter="+url.QueryEscape(tt.filter), nil)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.filter, rec.Code, tt.status, rec.Body)
+			continue
+		}
+		var got []string
+		for _, item := range apptest.JSON[collectionBody](t, rec).Items {
+			got = append(got, item.Message)
+		}
+		if tt.status == http.StatusOK && !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestSampleStats(t *testing.T) {
+	router := setup(t)
+	today := time.Now().UTC()
+	apptest.Seed(t,
+		&model.SampleStat{Day: today.Format(time.DateOnly), Created: 3, Updated: 1},
+		&model.SampleStat{Day: today.AddDate(0, 0, -1).Format(time.DateOnly), Created: 2},
+		&model.SampleStat{Day: today.AddDate(0, 0, -40).Format(time.DateOnly), Created: 9},
+		&model.SampleStat{Day: today.Format(time.DateOnly), Tag: "env=prod", Created: 1},
+	)
+
+	type statsBody struct {
+		Stats []model.SampleStat `json:"stats"`
+	}
+	rec := apptest.Do(t, router, http.MethodGet, "/sample/stats", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if stats := apptest.JSON[statsBody](t, rec).Stats; len(stats) != 2 || stats[0].Created != 3 || stats[1].Created != 2 {
+		t.Errorf("stats = %+v, want today and yesterday", stats)
+	}
+	if stats := apptest.JSON[statsBody](t, apptest.Do(t, router, http.MethodGet, "/sample/stats?tag=env%3Dprod", nil)).Stats; len(stats) != 1 || stats[0].Tag != "env=prod" {
+		t.Errorf("tagged stats = %+v", stats)
+	}
+	if rec := apptest.Do(t, router, http.MethodGet, "/sample/stats?days=0", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("days=0: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestSampleTimeseries(t *testing.T) {
+	router := setup(t)
+	now := time.Now()
+	for _, age := range []time.Duration{0, 0, 2 * time.Hour, 5 * time.Hour} {
+		at := now.Add(-age)
+		apptest.Seed(t, &model.Sample{Message: "sample", CreatedAt: at, UpdatedAt: at})
+	}
+
+	type timeseriesBody struct {
+		Buckets []struct {
+			Start time.Time `json:"start"`
+			Count int64     `json:"count"`
+		} `json:"buckets"`
+	}
+	rec := apptest.Do(t, router, http.MethodGet, "/sample/stats/timeseries?window=1h&range=3h", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var counts []int64
+	for _, bucket := range apptest.JSON[timeseriesBody](t, rec).Buckets {
+		counts = append(counts, bucket.Count)
+	}
+	// Oldest first, the sample of five hours ago is out of range
+	if want := []int64{1, 0, 2}; !slices.Equal(counts, want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+
+	for _, query := range []string{"window=30s", "window=1m&range=7d", "range=soon", "event=viewed"} {
+		if rec := apptest.Do(t, router, http.MethodGet, "/sample/stats/timeseries?"+query, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestWriteSampleDryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  func(existing model.Sample) string
+		body    any
+		header  string
+		status  int
+		message string
+	}{
+		{"create", http.MethodPost, func(model.Sample) string { return "/sample?dry_run=true" }, map[string]string{"message": "new"}, "", http.StatusCreated, "new"},
+		{"create by header", http.MethodPost, func(model.Sample) string { return "/sample" }, map[string]string{"message": "new"}, "true", http.StatusCreated, "new"},
+		{"create without message", http.MethodPost, func(model.Sample) string { return "/sample?dry_run=true" }, map[string]string{"message": ""}, "", http.StatusBadRequest, ""},
+		{"invalid flag", http.MethodPost, func(model.Sample) string { return "/sample?dry_run=maybe" }, map[string]string{"message": "new"}, "", http.StatusBadRequest, ""},
+		{"update", http.MethodPut, func(s model.Sample) string { return "/sample/" + s.ID + "?dry_run=true" }, map[string]string{"message": "changed"}, "", http.StatusOK, "changed"},
+		{"update missing", http.MethodPut, func(model.Sample) string { return "/sample/missing?dry_run=true" }, map[string]string{"message": "changed"}, "", http.StatusNotFound, ""},
+		{"delete", http.MethodDelete, func(s model.Sample) string { return "/sample/" + s.ID + "?dry_run=true" }, nil, "", http.StatusNoContent, ""},
+		{"delete missing", http.MethodDelete, func(model.Sample) string { return "/sample/missing?dry_run=true" }, nil, "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setup(t)
+			existing := seedSamples(t, "existing")[0]
+
+			var opts []apptest.RequestOption
+			if tt.header != "" {
+				opts = append(opts, apptest.WithHeader(controller.HeaderDryRun, tt.header))
+			}
+			rec := apptest.Do(t, router, tt.method, tt.target(existing), tt.body, opts...)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.message != "" {
+				if body := apptest.JSON[sampleBody](t, rec); body.Message != tt.message {
+					t.Errorf("message = %q, want %q", body.Message, tt.message)
+				}
+				if rec.Header().Get(controller.HeaderDryRun) != "true" {
+					t.Errorf("missing %s header", controller.HeaderDryRun)
+				}
+			}
+
+			// Nothing was stored
+			page := apptest.JSON[collectionBody](t, apptest.Do(t, router, http.MethodGet, "/samples", nil))
+			if len(page.Items) != 1 || page.Items[0].ID != existing.ID || page.Items[0].Message != "existing" {
+				t.Errorf("samples changed: %+v", page.Items)
+			}
+		})
+	}
+}
+
+func TestGetSampleByID(t *testing.T) {
+	router := setup(t)
+	existing := seedSamples(t, "existing")[0]
+
+	rec := apptest.Do(t, router, http.MethodGet, "/sample/"+existing.ID, nil)
+	if body := apptest.JSON[sampleBody](t, rec); rec.Code != http.StatusOK || body.Message != "existing" {
+		t.Fatalf("status = %d, body = %+v", rec.Code, body)
+	}
+
+	apptest.Do(t, router, http.MethodDelete, "/sample/"+existing.ID, nil)
+	if rec := apptest.Do(t, router, http.MethodGet, "/sample/"+existing.ID, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted sample status = %d, want 404", rec.Code)
+	}
+}
+
+func TestLookupSamples(t *testing.T) {
+	router := setup(t)
+	samples := seedSamples(t, "a", "b")
+	ids := samples[1].ID + "," + samples[0].ID + ",missing," + samples[1].ID
+
+	for _, rec := range []*httptest.ResponseRecorder{
+		apptest.Do(t, router, http.MethodGet, "/sample?ids="+ids, nil),
+		apptest.Do(t, router, http.MethodPost, "/sample/lookup", map[string][]string{"ids": strings.Split(ids, ",")}),
+	} {
+		body := apptest.JSON[collectionBody](t, rec)
+		if len(body.Items) != 2 || body.Items[0].ID != samples[1].ID {
+			t.Fatalf("items = %+v, want b then a", body.Items)
+		}
+		status := body.Meta["status"].(map[string]any)
+		if status["missing"] != "missing" || status[samples[0].ID] != "found" {
+			t.Errorf("status = %v", status)
+		}
+	}
+
+	if rec := apptest.Do(t, router, http.MethodGet, "/sample?ids=,", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty ids status = %d, want 400", rec.Code)
+	}
+}
+
+func TestListSamples(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		status int
+		items  int
+		meta   map[string]any
+	}{
+		{"first offset page", "/samples?limit=2", http.StatusOK, 2, map[string]any{"total": 5.0, "has_more": true}},
+		{"last offset page", "/samples?limit=2&offset=4", http.StatusOK, 1, map[string]any{"has_more": false}},
+		{"without count", "/samples?count=none", http.StatusOK, 5, map[string]any{"has_more": false, "total": nil}},
+		{"first cursor page", "/samples?limit=2&cursor=", http.StatusOK, 2, map[string]any{"has_more": true, "total": nil}},
+		{"invalid limit", "/samples?limit=0", http.StatusBadRequest, 0, nil},
+		{"invalid count", "/samples?count=all", http.StatusBadRequest, 0, nil},
+		{"invalid cursor", "/samples?cursor=!!", http.StatusBadRequest, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setup(t)
+			seedSamples(t, "1", "2", "3", "4", "5")
+
+			rec := apptest.Do(t, router, http.MethodGet, tt.target, nil)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			body := apptest.JSON[collectionBody](t, rec)
+			if len(body.Items) != tt.items {
+				t.Errorf("items = %d, want %d", len(body.Items), tt.items)
+			}
+			for key, want := range tt.meta {
+				if got := body.Meta[key]; got != want {
+					t.Errorf("meta %s = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestListSamplesCursorWalk(t *testing.T) {
+	router := setup(t)
+	seedSamples(t, "1", "2", "3", "4", "5")
+
+	var messages []string
+	target := "/samples?limit=2&cursor="
+	for target != "" {
+		body := apptest.JSON[collectionBody](t, apptest.Do(t, router, http.MethodGet, target, nil))
+		for _, item := range body.Items {
+			messages = append(messages, item.Message)
+		}
+		target = body.Links["next"].Href
+	}
+
+	if got := strings.Join(messages, ","); got != "5,4,3,2,1" {
+		t.Errorf("walked %s, want newest first without repeats", got)
+	}
+}
+
+func TestSampleChanges(t *testing.T) {
+	router := setup(t)
+
+	type feed struct {
+		Changes []struct {
+			Type   string     `json:"type"`
+			Sample sampleBody `json:"sample"`
+		} `json:"changes"`
+		Cursor   string `json:"cursor"`
+		TimedOut bool   `json:"timed_out"`
+	}
+
+	start := apptest.JSON[feed](t, apptest.Do(t, router, http.MethodGet, "/sample/changes", nil))
+	if start.Cursor == "" {
+		t.Fatal("no starting cursor")
+	}
+
+	since := "/sample/changes?timeout=0&since=" + url.QueryEscape(start.Cursor)
+	if idle := apptest.JSON[feed](t, apptest.Do(t, router, http.MethodGet, since, nil)); !idle.TimedOut {
+		t.Errorf("expected a timeout without changes, got %+v", idle)
+	}
+
+	apptest.Do(t, router, http.MethodPost, "/sample", map[string]string{"message": "new"})
+	changed := apptest.JSON[feed](t, apptest.Do(t, router, http.MethodGet, since, nil))
+	if len(changed.Changes) != 1 || changed.Changes[0].Type != "created" || changed.Changes[0].Sample.Message != "new" {
+		t.Fatalf("changes = %+v", changed.Changes)
+	}
+	if changed.Cursor == start.Cursor {
+		t.Error("cursor did not advance")
+	}
+
+	if rec := apptest.Do(t, router, http.MethodGet, "/sample/changes?since=bad", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid cursor status = %d, want 400", rec.Code)
+	}
+}
+
+func TestSampleRoutesAreDocumented(t *testing.T) {
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	documented := map[string]bool{}
+	for _, path := range validator.Paths() {
+		documented[path] = true
+	}
+
+	router := setup(t)
+	for _, route := range router.Routes() {
+		if !documented[route.Path] {
+			t.Errorf("%s %s is missing from openapi.json", route.Method, route.Path)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"app/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SchemaController struct {
+	SchemaService service.SchemaService
+}
+
+// Drift answers 200 when the schema matches the models and 409 when it has drifted
+func (c *SchemaController) Drift(ctx echo.Context) error {
+	report, err := c.SchemaService.Drift(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	code := http.StatusOK
+	if report.Drifted {
+		code = http.StatusConflict
+	}
+	return ctx.JSON(code, report)
+}
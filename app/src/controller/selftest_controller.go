@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"app/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SelfTestController struct {
+	SelfTestService service.SelfTestService
+}
+
+func (c *SelfTestController) Run(ctx echo.Context) error {
+	report := c.SelfTestService.Run(ctx.Request().Context())
+	code := http.StatusOK
+	if report.Status != "pass" {
+		code = http.StatusInternalServerError
+	}
+	return ctx.JSON(code, report)
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"app/apperrors"
+	"app/service"
+	"net/http"
+	"path"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SnapshotController struct {
+	SnapshotService service.SnapshotService
+}
+
+func (c *SnapshotController) Create(ctx echo.Context) error {
+	snapshot, err := c.SnapshotService.Create(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, snapshot)
+}
+
+func (c *SnapshotController) List(ctx echo.Context) error {
+	snapshots, err := c.SnapshotService.List(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, snapshots)
+}
+
+func (c *SnapshotController) Download(ctx echo.Context) error {
+	reader, obj, err := c.SnapshotService.Open(ctx.Request().Context(), ctx.Param("name"))
+	switch {
+	case apperrors.KindOf(err) == apperrors.NotFound:
+		// Invalid names read as missing, so probing for paths learns nothing
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "snapshot not found"})
+	case err != nil:
+		return errorResponse(ctx, err)
+	}
+	defer reader.Close()
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+path.Base(obj.Key)+`"`)
+	return ctx.Stream(http.StatusOK, "application/gzip", reader)
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"app/health"
+	"app/service"
+	"app/serving"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StatusController serves a small public status page built from the background health checks
+type StatusController struct {
+	Health *health.Registry
+	Uptime *service.UptimeService
+}
+
+type StatusPage struct {
+	Status string `json:"status"`
+	// Version is VERSION, Revision the commit the binary was built from when it is known
+	Version      string             `json:"version"`
+	Revision     string             `json:"revision,omitempty"`
+	Pod          string             `json:"pod"`
+	GeneratedAt  time.Time          `json:"generated_at"`
+	Window       string             `json:"window"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	Fallback  string  `json:"fallback,omitempty"`
+	// Availability is the share of the checks in the window that passed
+	Availability float64         `json:"availability"`
+	History      []health.Sample `json:"history"`
+	// Timeline is the history in statusSlots minutes for the HTML page, the worst status of each
+	Timeline []string `json:"-"`
+}
+
+// statusSlots is how many bars the HTML page draws for the window
+const statusSlots = 60
+
+// timeline buckets samples into slots ending at now, an empty slot has no status
+func timeline(samples []health.Sample, now time.Time) []string {
+	slots := make([]string, statusSlots)
+	slot := health.HistoryWindow / statusSlots
+	start := now.Add(-health.HistoryWindow)
+	severity := map[string]int{"": 0, health.StatusUp: 1, health.StatusDegraded: 2, health.StatusDown: 3}
+	for _, sample := range samples {
+		i := int(sample.CheckedAt.Sub(start) / slot)
+		if i < 0 || i >= statusSlots {
+			continue
+		}
+		if severity[sample.Status] > severity[slots[i]] {
+			slots[i] = sample.Status
+		}
+	}
+	return slots
+}
+
+// Status answers with the page in HTML for browsers and JSON otherwise, ?format= picks one explicitly.
+// It is 200 whatever the status, the page itself is what reports an outage.
+func (c *StatusController) Status(ctx echo.Context) error {
+	report, ok := c.Health.Cached()
+	if !ok {
+		report = c.Health.Run(ctx.Request().Context())
+	}
+	history := c.Health.History()
+
+	replica := serving.Current()
+	page := StatusPage{
+		Status:       report.Status,
+		Version:      replica.Version,
+		Revision:     revision(),
+		Pod:          replica.Pod,
+		GeneratedAt:  time.Now(),
+		Window:       health.HistoryWindow.String(),
+		Dependencies: make([]DependencyStatus, 0, len(report.Checks)),
+	}
+	for name, result := range report.Checks {
+		page.Dependencies = append(page.Dependencies, DependencyStatus{
+			Name:         name,
+			Status:       result.Status,
+			Critical:     result.Critical,
+			LatencyMs:    result.LatencyMs,
+			Error:        result.Error,
+			Fallback:     result.Fallback,
+			Availability: health.Availability(history[name]),
+			History:      history[name],
+			Timeline:     timeline(history[name], page.GeneratedAt),
+		})
+	}
+	sort.Slice(page.Dependencies, func(i, j int) bool { return page.Dependencies[i].Name < page.Dependencies[j].Name })
+
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	ctx.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if wantsHTML(ctx) {
+		return ctx.Render(http.StatusOK, "status.html", page)
+	}
+	return ctx.JSON(http.StatusOK, page)
+}
+
+// ProcessUptime reports the uptime of this process and the restarts of its pod, with the reason of
+// the last one that ended without a graceful shutdown
+func (c *StatusController) ProcessUptime(ctx echo.Context) error {
+	uptime, err := c.Uptime.Uptime(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(http.StatusOK, uptime)
+}
+
+func wantsHTML(ctx echo.Context) bool {
+	switch ctx.QueryParam("format") {
+	case "html":
+		return true
+	case "json":
+		return false
+	}
+	return strings.Contains(ctx.Request().Header.Get(echo.HeaderAccept), echo.MIMETextHTML)
+}
+
+// revision is the VCS revision Go stamped into the binary, empty for builds outside a checkout
+func revision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// AvailabilityPercent is Availability for the HTML page
+func (d DependencyStatus) AvailabilityPercent() string {
+	return strconv.FormatFloat(d.Availability*100, 'f', 1, 64) + "%"
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"app/model"
+	"app/service"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type TenantController struct {
+	TenantService *service.TenantService
+}
+
+// SetTenantLimitRequest registers a tenant with its limits, zero leaves that limit unlimited
+type SetTenantLimitRequest struct {
+	RequestsPerMinute int   `json:"requests_per_minute"`
+	DailyQuota        int64 `json:"daily_quota"`
+}
+
+// List reports the defaults of requests without a tenant and the known tenants
+func (c *TenantController) List(ctx echo.Context) error {
+	limits, err := c.TenantService.ListLimits(ctx.Request().Context())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"defaults": SetTenantLimitRequest{
+			RequestsPerMinute: c.TenantService.DefaultRequestsPerMinute,
+			DailyQuota:        c.TenantService.DefaultDailyQuota,
+		},
+		"tenants": limits,
+	})
+}
+
+// Get reports the limits that apply to the tenant and what it used of today's quota
+func (c *TenantController) Get(ctx echo.Context) error {
+	limit, quota, err := c.TenantService.TenantUsage(ctx.Request().Context(), ctx.Param("tenant"), time.Now())
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"limit": limit, "quota": quota})
+}
+
+func (c *TenantController) Set(ctx echo.Context) error {
+	req := new(SetTenantLimitRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+	tenant := ctx.Param("tenant")
+	if tenant == "" || len(tenant) > 64 {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "tenant must be 1 to 64 characters"})
+	}
+	if req.RequestsPerMinute < 0 || req.DailyQuota < 0 {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "limits must not be negative"})
+	}
+
+	limit, err := c.TenantService.SetLimit(ctx.Request().Context(), model.TenantLimit{
+		Tenant:            tenant,
+		RequestsPerMinute: req.RequestsPerMinute,
+		DailyQuota:        req.DailyQuota,
+	})
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, limit)
+}
+
+// Delete puts the tenant back on the defaults
+func (c *TenantController) Delete(ctx echo.Context) error {
+	if err := c.TenantService.DeleteLimit(ctx.Request().Context(), ctx.Param("tenant")); err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
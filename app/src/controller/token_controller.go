@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"app/apperrors"
+	"app/i18n"
+	"app/jwtauth"
+	"app/service"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type TokenController struct {
+	AuthService  service.AuthService
+	TokenService service.TokenService
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (c *TokenController) Issue(ctx echo.Context) error {
+	req := new(CredentialsRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+
+	user, err := c.AuthService.Authenticate(req.Username, req.Password)
+	if err != nil {
+		return authError(ctx, err)
+	}
+
+	pair, err := c.TokenService.Issue(user)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, pair)
+}
+
+func (c *TokenController) Refresh(ctx echo.Context) error {
+	req := new(RefreshTokenRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+	if req.RefreshToken == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.invalid_request_body")})
+	}
+
+	pair, err := c.TokenService.Refresh(req.RefreshToken)
+	if errors.Is(err, service.ErrInvalidRefreshToken) {
+		return ctx.JSON(apperrors.Status(err), map[string]string{"error": i18n.T(ctx, "error.invalid_refresh_token")})
+	}
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, pair)
+}
+
+// Revoke accepts a refresh token in the body and revokes the bearer access token as well when one is presented
+func (c *TokenController) Revoke(ctx echo.Context) error {
+	req := new(RefreshTokenRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+
+	var access *jwtauth.Claims
+	if raw, found := strings.CutPrefix(ctx.Request().Header.Get(echo.HeaderAuthorization), "Bearer "); found {
+		access, _ = c.TokenService.Signer.Parse(raw)
+	}
+	if req.RefreshToken == "" && access == nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.invalid_request_body")})
+	}
+
+	err := c.TokenService.Revoke(req.RefreshToken, access)
+	if err != nil && !errors.Is(err, service.ErrInvalidRefreshToken) {
+		return errorResponse(ctx, err)
+	}
+	// RFC 7009: unknown tokens are not an error for the client
+	return ctx.NoContent(http.StatusOK)
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"app/links"
+	"app/service"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers of the resumable upload protocol, named after the tus ones
+const (
+	headerUploadOffset  = "Upload-Offset"
+	headerUploadExpires = "Upload-Expires"
+)
+
+type CreateUploadRequest struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	// Size is the length of the whole file in bytes
+	Size int64 `json:"size"`
+}
+
+// CreateUpload starts a resumable upload, for files larger than the ingress accepts in one request.
+// The chunks are sent with PATCH to the Location returned, each starting at the Upload-Offset of
+// the previous response, and POST /uploads/:id/complete turns the upload into a file.
+func (c *FileController) CreateUpload(ctx echo.Context) error {
+	req := new(CreateUploadRequest)
+	if err := ctx.Bind(req); err != nil {
+		return bindError(ctx, err)
+	}
+	switch {
+	case req.Name == "":
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+	case req.Size <= 0:
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "size must be positive"})
+	case req.Size > c.MaxBytes:
+		return ctx.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "file is too large"})
+	}
+	if _, _, err := mime.ParseMediaType(req.ContentType); err != nil {
+		req.ContentType = "application/octet-stream"
+	}
+
+	upload, err := c.FileService.CreateUpload(ctx.Request().Context(), req.Name, req.ContentType, req.Size)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	prefix := strings.TrimSuffix(ctx.Request().Header.Get(links.HeaderPrefix), "/")
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderLocation, prefix+"/uploads/"+upload.ID)
+	header.Set(headerUploadOffset, "0")
+	header.Set(headerUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	return ctx.JSON(http.StatusCreated, upload)
+}
+
+// GetUpload reports how much of the upload was received, where an interrupted client resumes
+func (c *FileController) GetUpload(ctx echo.Context) error {
+	upload, err := c.FileService.GetUpload(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	header := ctx.Response().Header()
+	header.Set(headerUploadOffset, strconv.FormatInt(upload.Offset, 10))
+	header.Set(headerUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	header.Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(http.StatusOK, upload)
+}
+
+// WriteChunk appends the body to the upload at the offset given by the Upload-Offset header
+func (c *FileController) WriteChunk(ctx echo.Context) error {
+	offset, err := strconv.ParseInt(ctx.Request().Header.Get(headerUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Upload-Offset header must be the offset of the chunk"})
+	}
+	req := ctx.Request()
+	req.Body = http.MaxBytesReader(ctx.Response(), req.Body, c.MaxChunkBytes)
+
+	upload, err := c.FileService.WriteChunk(req.Context(), ctx.Param("id"), offset, req.Body)
+	header := ctx.Response().Header()
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return ctx.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "chunk is larger than " + strconv.FormatInt(c.MaxChunkBytes, 10) + " bytes"})
+	case errors.Is(err, service.ErrUploadOffset):
+		// The client continues from the offset the upload is really at
+		if current, getErr := c.FileService.GetUpload(req.Context(), ctx.Param("id")); getErr == nil {
+			header.Set(headerUploadOffset, strconv.FormatInt(current.Offset, 10))
+		}
+		return errorResponse(ctx, err)
+	case err != nil:
+		return errorResponse(ctx, err)
+	}
+	header.Set(headerUploadOffset, strconv.FormatInt(upload.Offset, 10))
+	header.Set(headerUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	return ctx.JSON(http.StatusOK, upload)
+}
+
+// CompleteUpload turns a fully received upload into a file
+func (c *FileController) CompleteUpload(ctx echo.Context) error {
+	file, err := c.FileService.CompleteUpload(ctx.Request().Context(), ctx.Param("id"))
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, file)
+}
+
+func (c *FileController) AbortUpload(ctx echo.Context) error {
+	if err := c.FileService.AbortUpload(ctx.Request().Context(), ctx.Param("id")); err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
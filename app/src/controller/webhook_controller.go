@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"app/apperrors"
+	"app/i18n"
+	"app/service"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Maximum accepted webhook payload size (GitHub caps payloads at 25MB)
+const maxWebhookPayload = 25 << 20
+
+type WebhookController struct {
+	WebhookService service.WebhookService
+}
+
+func (c *WebhookController) Receive(ctx echo.Context) error {
+	provider := ctx.Param("provider")
+
+	body, err := io.ReadAll(io.LimitReader(ctx.Request().Body, maxWebhookPayload))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": i18n.T(ctx, "error.read_body_failed")})
+	}
+
+	if err := c.WebhookService.Verify(provider, ctx.Request().Header, body); err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			return ctx.JSON(apperrors.Status(err), map[string]string{"error": i18n.T(ctx, "error.unknown_webhook_provider")})
+		}
+		return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": i18n.T(ctx, "error.invalid_webhook_signature")})
+	}
+
+	event, err := c.WebhookService.Receive(ctx.Request().Context(), provider, ctx.Request().Header, body)
+	if err != nil {
+		if errors.Is(err, service.ErrDuplicateEvent) {
+			return ctx.JSON(http.StatusOK, map[string]string{"status": "duplicate"})
+		}
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusAccepted, map[string]string{"status": "accepted", "id": event.ID})
+}
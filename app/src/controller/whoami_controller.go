@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"app/service"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// whoamiCookie identifies a visitor, like a session cookie would
+const whoamiCookie = "whoami"
+
+// WhoAmIController serves the session affinity demo: the same visitor counted in pod memory and in shared state
+type WhoAmIController struct {
+	WhoAmIService service.WhoAmIService
+}
+
+// visitor returns the visitor id of the cookie, issuing one on the first visit
+func visitor(ctx echo.Context) string {
+	if cookie, err := ctx.Cookie(whoamiCookie); err == nil {
+		if _, err := uuid.Parse(cookie.Value); err == nil {
+			return cookie.Value
+		}
+	}
+	id := uuid.New().String()
+	ctx.SetCookie(&http.Cookie{Name: whoamiCookie, Value: id, Path: "/whoami", MaxAge: int((24 * time.Hour).Seconds()), HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	return id
+}
+
+// Get shows both next to each other. Scaling the Deployment, or removing sessionAffinity
+// from the Service, makes the pod count start over while the shared count carries on.
+func (c *WhoAmIController) Get(ctx echo.Context) error {
+	id := visitor(ctx)
+	pod, requests := c.WhoAmIService.PodVisit(id)
+	shared, err := c.WhoAmIService.SharedVisit(ctx.Request().Context(), id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"pod":          c.WhoAmIService.Pod(),
+		"visitor":      id,
+		"pod_requests": requests,
+		"pod_state":    pod,
+		"shared_state": shared,
+	})
+}
+
+// Pod counts the visitor in the answering pod's memory only
+func (c *WhoAmIController) Pod(ctx echo.Context) error {
+	id := visitor(ctx)
+	visit, requests := c.WhoAmIService.PodVisit(id)
+	return ctx.JSON(http.StatusOK, map[string]any{"pod": c.WhoAmIService.Pod(), "visitor": id, "pod_requests": requests, "state": visit})
+}
+
+// Shared counts the visitor in redis only
+func (c *WhoAmIController) Shared(ctx echo.Context) error {
+	id := visitor(ctx)
+	visit, err := c.WhoAmIService.SharedVisit(ctx.Request().Context(), id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"pod": c.WhoAmIService.Pod(), "visitor": id, "state": visit})
+}
@@ -0,0 +1,275 @@
+package db
+
+import (
+	"app/config"
+	"app/httpclient"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// cloudSQLNet is the driver network the connector is registered as, DB_CONNECTOR=cloudsql selects it
+const cloudSQLNet = "cloudsql"
+
+// cloudSQLPort is the port of the server side proxy of every Cloud SQL instance
+const cloudSQLPort = "3307"
+
+// CloudSQLConnector dials a Cloud SQL instance the way the Cloud SQL Auth Proxy and Go connector do,
+// without a sidecar: it requests an ephemeral client certificate for its own key from the SQL Admin API,
+// authorized by the workload's service account, and opens a TLS connection to the instance's server
+// side proxy, checked against the instance's CA. The certificate is refreshed before it expires.
+type CloudSQLConnector struct {
+	// Instance is the connection name, project:region:instance
+	Instance string
+	// IPType is PUBLIC, PRIVATE or PSC, the address of the instance dialed
+	IPType string
+	// IAMAuthn embeds the access token in the certificate for automatic IAM database authentication
+	IAMAuthn bool
+	APIURL   string
+	Tokens   *TokenSource
+	Client   *http.Client
+
+	key    *rsa.PrivateKey
+	dialer net.Dialer
+	// port is cloudSQLPort unless a test sets it
+	port string
+
+	mu      sync.Mutex
+	current *cloudSQLConnectInfo
+}
+
+type cloudSQLConnectInfo struct {
+	address string
+	config  *tls.Config
+	expiry  time.Time
+}
+
+// connectorFromEnv registers the dialer of DB_CONNECTOR and points cfg at it. DB_INSTANCE_CONNECTION_NAME
+// replaces DB_HOST, the connector does the TLS, so the driver's own is turned off.
+func connectorFromEnv(cfg *mysqldriver.Config) error {
+	switch connector := config.String("DB_CONNECTOR", ""); connector {
+	case "":
+		return nil
+	case cloudSQLNet:
+	default:
+		return fmt.Errorf("unsupported DB_CONNECTOR %q", connector)
+	}
+
+	instance := config.String("DB_INSTANCE_CONNECTION_NAME", "")
+	if strings.Count(instance, ":") != 2 {
+		return fmt.Errorf("DB_INSTANCE_CONNECTION_NAME %q must be project:region:instance", instance)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	connector := &CloudSQLConnector{
+		Instance: instance,
+		IPType:   strings.ToUpper(config.String("DB_IP_TYPE", "PUBLIC")),
+		IAMAuthn: config.String("DB_IAM_AUTH", "") == "cloudsql",
+		APIURL:   config.String("DB_CLOUDSQL_API_URL", "https://sqladmin.googleapis.com"),
+		Tokens:   &TokenSource{Provider: NewCloudSQLProviderFromEnv(), RefreshBefore: 5 * time.Minute},
+		Client:   httpclient.New(30 * time.Second),
+		key:      key,
+	}
+	mysqldriver.RegisterDialContext(cloudSQLNet, connector.DialContext)
+	cfg.Net, cfg.Addr = cloudSQLNet, instance
+	cfg.TLSConfig, cfg.TLS = "false", nil
+	slog.Info("connecting to cloud sql without a proxy", "instance", instance, "ip_type", connector.IPType)
+	return nil
+}
+
+func (c *CloudSQLConnector) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	info, err := c.connectInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloud sql connector: %w", err)
+	}
+	raw, err := c.dialer.DialContext(ctx, "tcp", info.address)
+	if err != nil {
+		return nil, err
+	}
+	conn := tls.Client(raw, info.config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		// A revoked or rotated certificate is fetched again on the next dial
+		c.mu.Lock()
+		c.current = nil
+		c.mu.Unlock()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// connectInfo returns the cached address and TLS config, refreshed when the certificate expires within 5 minutes
+func (c *CloudSQLConnector) connectInfo(ctx context.Context) (*cloudSQLConnectInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != nil && time.Until(c.current.expiry) > 5*time.Minute {
+		return c.current, nil
+	}
+	info, err := c.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.current = info
+	return info, nil
+}
+
+func (c *CloudSQLConnector) refresh(ctx context.Context) (*cloudSQLConnectInfo, error) {
+	project, rest, _ := strings.Cut(c.Instance, ":")
+	_, name, _ := strings.Cut(rest, ":")
+	base := fmt.Sprintf("%s/sql/v1beta4/projects/%s/instances/%s", c.APIURL, project, name)
+
+	var settings struct {
+		IPAddresses []struct {
+			Type      string `json:"type"`
+			IPAddress string `json:"ipAddress"`
+		} `json:"ipAddresses"`
+		PSCDNSName   string `json:"dnsName"`
+		ServerCACert struct {
+			Cert string `json:"cert"`
+		} `json:"serverCaCert"`
+	}
+	if err := c.call(ctx, http.MethodGet, base+"/connectSettings", nil, &settings); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&c.key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	request := map[string]string{"public_key": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))}
+	var iamToken Token
+	if c.IAMAuthn {
+		if iamToken, err = c.Tokens.Token(ctx); err != nil {
+			return nil, err
+		}
+		request["access_token"] = iamToken.Value
+	}
+	var ephemeral struct {
+		EphemeralCert struct {
+			Cert string `json:"cert"`
+		} `json:"ephemeralCert"`
+	}
+	if err := c.call(ctx, http.MethodPost, base+":generateEphemeralCert", request, &ephemeral); err != nil {
+		return nil, err
+	}
+
+	certBlock, _ := pem.Decode([]byte(ephemeral.EphemeralCert.Cert))
+	if certBlock == nil {
+		return nil, errors.New("no ephemeral certificate in the response")
+	}
+	clientCert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(settings.ServerCACert.Cert)) {
+		return nil, errors.New("no server ca certificate in the connect settings")
+	}
+
+	address := ""
+	switch c.IPType {
+	case "PSC":
+		address = strings.TrimSuffix(settings.PSCDNSName, ".")
+	case "PUBLIC", "PRIVATE":
+		// The API calls the public address PRIMARY
+		wanted := map[string]string{"PUBLIC": "PRIMARY", "PRIVATE": "PRIVATE"}[c.IPType]
+		for _, ip := range settings.IPAddresses {
+			if ip.Type == wanted {
+				address = ip.IPAddress
+			}
+		}
+	}
+	if address == "" {
+		return nil, fmt.Errorf("instance has no %s address", c.IPType)
+	}
+
+	expiry := clientCert.NotAfter
+	// The certificate may not outlive the token it embeds
+	if c.IAMAuthn && iamToken.Expiry.Before(expiry) {
+		expiry = iamToken.Expiry
+	}
+	port := cloudSQLPort
+	if c.port != "" {
+		port = c.port
+	}
+	return &cloudSQLConnectInfo{
+		address: net.JoinHostPort(address, port),
+		expiry:  expiry,
+		config: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{{Certificate: [][]byte{clientCert.Raw}, PrivateKey: c.key, Leaf: clientCert}},
+			// The server certificate names the instance, not the address dialed: the chain is checked here
+			InsecureSkipVerify: true,
+			VerifyConnection: func(state tls.ConnectionState) error {
+				if len(state.PeerCertificates) == 0 {
+					return errors.New("cloud sql instance sent no certificate")
+				}
+				server := state.PeerCertificates[0]
+				intermediates := x509.NewCertPool()
+				for _, cert := range state.PeerCertificates[1:] {
+					intermediates.AddCert(cert)
+				}
+				if _, err := server.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+					return err
+				}
+				if server.Subject.CommonName == project+":"+name {
+					return nil
+				}
+				if settings.PSCDNSName != "" {
+					return server.VerifyHostname(strings.TrimSuffix(settings.PSCDNSName, "."))
+				}
+				return fmt.Errorf("server certificate is for %q, not the instance", server.Subject.CommonName)
+			},
+		},
+	}, nil
+}
+
+// call sends a SQL Admin API request with the service account's access token
+func (c *CloudSQLConnector) call(ctx context.Context, method, url string, body, out any) error {
+	token, err := c.Tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	var payload io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Value)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("sql admin api answered %d: %s", res.StatusCode, bytes.TrimSpace(message))
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
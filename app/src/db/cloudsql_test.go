@@ -0,0 +1,282 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCA issues the certificates of the fake Cloud SQL instance and of the clients it accepts
+type testCA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+var serials atomic.Int64
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serials.Add(1)),
+		Subject:               pkix.Name{CommonName: "Google Cloud SQL Server CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pem() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}))
+}
+
+// issue signs a certificate for publicKey named commonName
+func (ca *testCA) issue(t *testing.T, commonName string, publicKey any, notAfter time.Time, usage x509.ExtKeyUsage) []byte {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(serials.Add(1)),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}, ca.cert, publicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// fakeInstance is a Cloud SQL instance: the server side proxy as a TLS listener requiring a client
+// certificate, and the SQL Admin API handing out those certificates
+type fakeInstance struct {
+	api       *httptest.Server
+	port      string
+	apiCalls  atomic.Int64
+	iamTokens atomic.Int64
+	// certTTL is how long the client certificates issued are valid
+	certTTL atomic.Int64
+}
+
+// newFakeInstance serves TLS with a certificate named serverName and issued by serverCA, while the
+// connect settings point at trustedCA
+func newFakeInstance(t *testing.T, serverName string, serverCA, trustedCA *testCA) *fakeInstance {
+	t.Helper()
+	clientCA := newTestCA(t)
+	instance := &fakeInstance{}
+	instance.certTTL.Store(int64(time.Hour))
+
+	serverKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	serverCert := serverCA.issue(t, serverName, &serverKey.PublicKey, time.Now().Add(time.Hour), x509.ExtKeyUsageServerAuth)
+	clients := x509.NewCertPool()
+	clients.AddCert(clientCA.cert)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clients,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	_, instance.port, _ = net.SplitHostPort(listener.Addr().String())
+
+	instance.api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		instance.apiCalls.Add(1)
+		if r.Header.Get("Authorization") != "Bearer api-token" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /sql/v1beta4/projects/my-project/instances/app/connectSettings":
+			json.NewEncoder(w).Encode(map[string]any{
+				"ipAddresses":  []map[string]string{{"type": "PRIMARY", "ipAddress": "127.0.0.1"}},
+				"serverCaCert": map[string]string{"cert": trustedCA.pem()},
+			})
+		case "POST /sql/v1beta4/projects/my-project/instances/app:generateEphemeralCert":
+			var request map[string]string
+			json.NewDecoder(r.Body).Decode(&request)
+			if request["access_token"] != "" {
+				instance.iamTokens.Add(1)
+			}
+			block, _ := pem.Decode([]byte(request["public_key"]))
+			if block == nil {
+				http.Error(w, "no public key", http.StatusBadRequest)
+				return
+			}
+			publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			cert := clientCA.issue(t, "client", publicKey, time.Now().Add(time.Duration(instance.certTTL.Load())), x509.ExtKeyUsageClientAuth)
+			json.NewEncoder(w).Encode(map[string]any{
+				"ephemeralCert": map[string]string{"cert": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}))},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(instance.api.Close)
+	return instance
+}
+
+type fakeTokenProvider struct{ expiry time.Time }
+
+func (p fakeTokenProvider) Name() string { return "fake" }
+
+func (p fakeTokenProvider) Token(ctx context.Context) (Token, error) {
+	return Token{Value: "api-token", Expiry: p.expiry}, nil
+}
+
+func newTestConnector(t *testing.T, instance *fakeInstance, ipType string) *CloudSQLConnector {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &CloudSQLConnector{
+		Instance: "my-project:asia-northeast1:app",
+		IPType:   ipType,
+		APIURL:   instance.api.URL,
+		Tokens:   &TokenSource{Provider: fakeTokenProvider{expiry: time.Now().Add(30 * time.Minute)}},
+		Client:   instance.api.Client(),
+		key:      key,
+		port:     instance.port,
+	}
+}
+
+// echo writes through conn and reads the instance's echo back
+func echo(t *testing.T, conn net.Conn) {
+	t.Helper()
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "ping" {
+		t.Fatalf("echo = %q (%v), want ping", reply, err)
+	}
+}
+
+func TestCloudSQLConnectorDials(t *testing.T) {
+	ca := newTestCA(t)
+	instance := newFakeInstance(t, "my-project:app", ca, ca)
+	connector := newTestConnector(t, instance, "PUBLIC")
+	ctx := t.Context()
+
+	for range 2 {
+		conn, err := connector.DialContext(ctx, "my-project:asia-northeast1:app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		echo(t, conn)
+	}
+	// connectSettings and generateEphemeralCert once, the certificate is reused until it nears its expiry
+	if calls := instance.apiCalls.Load(); calls != 2 {
+		t.Errorf("sql admin api calls = %d, want 2 for both dials", calls)
+	}
+	if instance.iamTokens.Load() != 0 {
+		t.Error("an access token was embedded in the certificate without IAMAuthn")
+	}
+
+	instance.certTTL.Store(int64(4 * time.Minute))
+	connector.current = nil
+	conn, err := connector.DialContext(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if conn, err = connector.DialContext(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if calls := instance.apiCalls.Load(); calls != 6 {
+		t.Errorf("sql admin api calls = %d, want the certificate expiring within 5 minutes fetched on every dial", calls)
+	}
+}
+
+func TestCloudSQLConnectorIAMAuthn(t *testing.T) {
+	ca := newTestCA(t)
+	instance := newFakeInstance(t, "my-project:app", ca, ca)
+	connector := newTestConnector(t, instance, "PUBLIC")
+	connector.IAMAuthn = true
+
+	conn, err := connector.DialContext(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(t, conn)
+	if instance.iamTokens.Load() != 1 {
+		t.Error("no access token was embedded in the certificate with IAMAuthn")
+	}
+	// The token expires in 30 minutes, before the certificate's hour
+	if expiry := connector.current.expiry; time.Until(expiry) > 31*time.Minute {
+		t.Errorf("connect info expires in %s, want no later than the token it embeds", time.Until(expiry))
+	}
+}
+
+func TestCloudSQLConnectorRejectsOtherServers(t *testing.T) {
+	ca := newTestCA(t)
+	for _, tt := range []struct {
+		name       string
+		serverName string
+		serverCA   *testCA
+	}{
+		{"certificate of another instance", "my-project:other", ca},
+		{"certificate of another project", "other-project:app", ca},
+		{"certificate from another CA", "my-project:app", newTestCA(t)},
+	} {
+		instance := newFakeInstance(t, tt.serverName, tt.serverCA, ca)
+		connector := newTestConnector(t, instance, "PUBLIC")
+		if conn, err := connector.DialContext(t.Context(), ""); err == nil {
+			conn.Close()
+			t.Errorf("%s: dial succeeded", tt.name)
+		}
+		if connector.current != nil {
+			t.Errorf("%s: the connect info is kept after the handshake failed", tt.name)
+		}
+	}
+
+	// The fake instance has no private address
+	instance := newFakeInstance(t, "my-project:app", ca, ca)
+	if _, err := newTestConnector(t, instance, "PRIVATE").DialContext(t.Context(), ""); err == nil {
+		t.Error("dialed the private address of an instance without one")
+	}
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"app/config"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SchemaCompat is a GORM plugin letting this version run against the schema of the previous one,
+// for blue/green deployments that change the schema in expand and contract steps:
+//
+//   - expand: migrate adds the new tables and columns, nullable or with a default, so the old version keeps working
+//   - deploy: the new version rolls out, and while the expand step has not reached the database yet
+//     this plugin leaves the columns the live table lacks out of inserts, updates and selections
+//   - contract: once no old replica is left, a later release drops what only the old version used
+//
+// The columns of a table are read from the database and cached for Refresh, so an expand
+// applied while the pods run is picked up without a restart.
+type SchemaCompat struct {
+	Refresh time.Duration
+
+	mu     sync.Mutex
+	tables map[string]tableColumns
+}
+
+type tableColumns struct {
+	columns map[string]bool
+	readAt  time.Time
+}
+
+// SchemaCompatFromEnv returns the plugin when DB_SCHEMA_COMPAT is set, nil otherwise
+func SchemaCompatFromEnv() *SchemaCompat {
+	if !config.Bool("DB_SCHEMA_COMPAT", false) {
+		return nil
+	}
+	refresh := config.Duration("DB_SCHEMA_COMPAT_REFRESH", time.Minute)
+	slog.Info("schema compatibility mode is enabled", "refresh", refresh)
+	return &SchemaCompat{Refresh: refresh}
+}
+
+func (c *SchemaCompat) Name() string {
+	return "app:schema_compat"
+}
+
+func (c *SchemaCompat) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register("app:compat_create", c.omitMissing),
+		callbacks.Update().Before("gorm:update").Register("app:compat_update", c.omitMissing),
+		callbacks.Query().Before("gorm:query").Register("app:compat_query", c.selectExisting),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasColumn reports whether the live table of model has column. Code that cannot simply leave the column
+// out branches on it. Without the compatibility mode it is always true, the schema is migrated on start.
+func HasColumn(tx *gorm.DB, model any, column string) bool {
+	compat, ok := tx.Config.Plugins[(&SchemaCompat{}).Name()].(*SchemaCompat)
+	if !ok {
+		return true
+	}
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return true
+	}
+	columns := compat.columns(tx, stmt.Table)
+	return columns == nil || columns[column]
+}
+
+// missing lists the columns of the statement's schema the live table does not have,
+// which are the ones of an expand step not applied yet
+func (c *SchemaCompat) missing(tx *gorm.DB) []string {
+	stmt := tx.Statement
+	if stmt.Schema == nil || stmt.Table == "" {
+		return nil
+	}
+	existing := c.columns(tx, stmt.Table)
+	if existing == nil {
+		return nil
+	}
+	var missing []string
+	for _, name := range stmt.Schema.DBNames {
+		if !existing[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+func (c *SchemaCompat) omitMissing(tx *gorm.DB) {
+	if tx.Error != nil {
+		return
+	}
+	tx.Statement.Omits = append(tx.Statement.Omits, c.missing(tx)...)
+}
+
+func (c *SchemaCompat) selectExisting(tx *gorm.DB) {
+	if tx.Error != nil || len(tx.Statement.Selects) == 0 {
+		return
+	}
+	missing := c.missing(tx)
+	tx.Statement.Selects = slices.DeleteFunc(tx.Statement.Selects, func(column string) bool {
+		return slices.Contains(missing, column)
+	})
+}
+
+// columns returns the cached columns of table, reading them again once Refresh has passed.
+// It returns nil when they cannot be read, the statement then runs unchanged.
+func (c *SchemaCompat) columns(tx *gorm.DB, table string) map[string]bool {
+	c.mu.Lock()
+	cached, ok := c.tables[table]
+	c.mu.Unlock()
+	if ok && time.Since(cached.readAt) < c.Refresh {
+		return cached.columns
+	}
+
+	// A new session on the statement's connection, so a transaction reads its own schema
+	types, err := tx.Session(&gorm.Session{NewDB: true}).Migrator().ColumnTypes(table)
+	if err != nil {
+		slog.Warn("failed to read table columns", "table", table, "error", err)
+		return nil
+	}
+	columns := make(map[string]bool, len(types))
+	for _, column := range types {
+		columns[column.Name()] = true
+	}
+
+	c.mu.Lock()
+	if c.tables == nil {
+		c.tables = map[string]tableColumns{}
+	}
+	c.tables[table] = tableColumns{columns: columns, readAt: time.Now()}
+	c.mu.Unlock()
+	return columns
+}
@@ -0,0 +1,42 @@
+package db_test
+
+import (
+	"app/apptest"
+	"app/db"
+	"app/model"
+	"testing"
+	"time"
+)
+
+func TestSchemaCompat(t *testing.T) {
+	apptest.DB(t)
+	// The previous schema, before natural keys were added
+	if err := db.DB.Migrator().DropColumn(&model.Sample{}, "natural_key"); err != nil {
+		t.Fatal(err)
+	}
+	key := "k1"
+	if err := db.DB.Create(&model.Sample{Message: "without compat", Key: &key}).Error; err == nil {
+		t.Fatal("insert of a missing column succeeded without the compatibility mode")
+	}
+
+	if err := db.DB.Use(&db.SchemaCompat{Refresh: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if db.HasColumn(db.DB, &model.Sample{}, "natural_key") {
+		t.Error("HasColumn(natural_key) = true on the previous schema")
+	}
+	sample := model.Sample{Message: "created", Key: &key}
+	if err := db.DB.Create(&sample).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := db.DB.Model(&sample).Updates(map[string]any{"message": "updated", "natural_key": "k2"}).Error; err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	var read model.Sample
+	if err := db.DB.Select("id", "message", "natural_key").First(&read, "id = ?", sample.ID).Error; err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if read.Message != "updated" || read.Key != nil {
+		t.Errorf("read = %+v, want the updated message without a key", read)
+	}
+}
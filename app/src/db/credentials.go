@@ -0,0 +1,113 @@
+package db
+
+import (
+	"app/config"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialSource supplies database credentials that may change while the app is running
+type CredentialSource interface {
+	Name() string
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// FileSource reads the password from a mounted file, e.g. a Kubernetes Secret volume
+// which the kubelet updates in place when the Secret is rotated.
+type FileSource struct {
+	Path string
+}
+
+func (f *FileSource) Name() string {
+	return "file"
+}
+
+func (f *FileSource) Credentials(ctx context.Context) (Credentials, error) {
+	raw, err := os.ReadFile(f.Path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read password file: %w", err)
+	}
+	return Credentials{Password: strings.TrimRight(string(raw), "\r\n")}, nil
+}
+
+// credentialSourceFromEnv prefers IAM tokens over Vault over a password file, and returns nil when none is configured
+func credentialSourceFromEnv(cfg *mysqldriver.Config) (CredentialSource, error) {
+	if source, err := tokenSourceFromEnv(cfg); source != nil || err != nil {
+		return source, err
+	}
+	if addr := config.String("VAULT_ADDR", ""); addr != "" {
+		return NewVaultSourceFromEnv(addr), nil
+	}
+	if path := config.String("DB_PASSWORD_FILE", ""); path != "" {
+		return &FileSource{Path: path}, nil
+	}
+	return nil, nil
+}
+
+// rotatingCredentials caches the latest credentials for the driver's BeforeConnect hook
+type rotatingCredentials struct {
+	source CredentialSource
+
+	mu      sync.RWMutex
+	current Credentials
+}
+
+func (r *rotatingCredentials) get() Credentials {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// refresh fetches the credentials and reports whether they changed
+func (r *rotatingCredentials) refresh(ctx context.Context) (bool, error) {
+	creds, err := r.source.Credentials(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := creds != r.current
+	r.current = creds
+	return changed, nil
+}
+
+// watch periodically refreshes the credentials until ctx is cancelled and recycles idle connections
+// after a rotation, so the pool reconnects with the new password without restarting the pod. A nil
+// onChange keeps them.
+func (r *rotatingCredentials) watch(ctx context.Context, interval time.Duration, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		refreshCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		changed, err := r.refresh(refreshCtx)
+		cancel()
+
+		if err != nil {
+			slog.Error("failed to refresh database credentials", "source", r.source.Name(), "error", err)
+			continue
+		}
+		if changed && onChange != nil {
+			slog.Info("database credentials rotated, recycling connections", "source", r.source.Name())
+			onChange()
+		}
+	}
+}
@@ -1,27 +1,139 @@
 package db
 
 import (
+	"app/config"
+	"app/health"
+	"app/runtimeutil"
+	"context"
+	"database/sql"
 	"log/slog"
-	"os"
+	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+var (
+	connConfig  *mysqldriver.Config
+	credentials *rotatingCredentials
+)
+
+// ConnectionConfig returns the driver config with the current credentials applied,
+// for tools such as mysqldump that connect outside the pool
+func ConnectionConfig() *mysqldriver.Config {
+	cfg := connConfig.Clone()
+	if credentials != nil {
+		creds := credentials.get()
+		if creds.Username != "" {
+			cfg.User = creds.Username
+		}
+		cfg.Passwd = creds.Password
+	}
+	return cfg
+}
+
 func Init() {
-	dsn := os.Getenv("DATABASE_URI")
-	if dsn == "" {
-		slog.Error("DATABASE_URI environment variable is not set")
-		panic("DATABASE_URI environment variable is not set")
+	cfg, err := configFromEnv()
+	if err != nil {
+		slog.Error("invalid database configuration", "error", err)
+		panic("invalid database configuration")
+	}
+
+	// Credentials from Vault or a mounted file override the ones in the DSN
+	var rotating *rotatingCredentials
+	source, err := credentialSourceFromEnv(cfg)
+	if err != nil {
+		slog.Error("invalid database credential configuration", "error", err)
+		panic("invalid database credential configuration")
+	}
+	if source != nil {
+		rotating = &rotatingCredentials{source: source}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_, err := rotating.refresh(ctx)
+		cancel()
+		if err != nil {
+			slog.Error("failed to load database credentials", "source", source.Name(), "error", err)
+			panic("failed to load database credentials")
+		}
+
+		err = cfg.Apply(mysqldriver.BeforeConnect(func(ctx context.Context, c *mysqldriver.Config) error {
+			creds := rotating.get()
+			if creds.Username != "" {
+				c.User = creds.Username
+			}
+			c.Passwd = creds.Password
+			return nil
+		}))
+		if err != nil {
+			panic(err)
+		}
+		slog.Info("database credentials are loaded dynamically", "source", source.Name())
 	}
 
-	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	connConfig = cfg
+	credentials = rotating
+
+	connector, err := mysqldriver.NewConnector(cfg)
+	if err != nil {
+		slog.Error("failed to create database connector", "error", err)
+		panic("failed to create database connector")
+	}
+	sqlDB := sql.OpenDB(connector)
+
+	// Bound connection lifetime so connections opened with old credentials are eventually replaced
+	maxIdle := config.Int("DB_MAX_IDLE_CONNS", 2)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(config.Duration("DB_CONN_MAX_LIFETIME", 30*time.Minute))
+
+	DB, err = gorm.Open(mysql.New(mysql.Config{Conn: sqlDB}), gormConfigFromEnv())
 	if err != nil {
 		slog.Error("failed to connect database", "error", err)
 		panic("failed to connect database")
 	}
 	slog.Info("connected to database")
-}
\ No newline at end of file
+
+	replicas, err := useReplicasFromEnv(DB, sqlDB, cfg)
+	if err != nil {
+		slog.Error("failed to configure database replicas", "error", err)
+		panic("failed to configure database replicas")
+	}
+
+	// DB_DEGRADED_READS keeps the pod in the Service while the database is down, see package degrade,
+	// and so do replicas, which the reads fail over to
+	fallback := ""
+	if config.Bool("DB_DEGRADED_READS", false) {
+		fallback = "sample reads from the last responses, writes rejected"
+	}
+	ping := sqlDB.PingContext
+	if len(replicas) > 0 {
+		fallback = "reads from the replicas, writes rejected"
+		ping = pingPrimary(sqlDB)
+		health.Register(health.Check{Name: "database-replicas", Run: pingReplicas(replicas)})
+	}
+	health.Register(health.Check{
+		Name:     "database",
+		Critical: true,
+		Fallback: fallback,
+		Run:      ping,
+	})
+
+	if rotating != nil {
+		recycle := func() {
+			// Dropping the idle pool forces new connections through BeforeConnect
+			sqlDB.SetMaxIdleConns(0)
+			sqlDB.SetMaxIdleConns(maxIdle)
+		}
+		// A new IAM token does not invalidate the connections the previous one opened
+		if _, ok := source.(*TokenSource); ok {
+			recycle = nil
+		}
+		interval := config.Duration("DB_CREDENTIAL_REFRESH_INTERVAL", time.Minute)
+		runtimeutil.Critical("db credential refresh", func(ctx context.Context) error {
+			rotating.watch(ctx, interval, recycle)
+			return nil
+		})
+	}
+}
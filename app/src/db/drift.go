@@ -0,0 +1,127 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Drift problems. Extra columns are reported but are not drift: a contract step not run yet leaves them.
+const (
+	DriftMissingTable    = "missing_table"
+	DriftMissingColumn   = "missing_column"
+	DriftTypeMismatch    = "type_mismatch"
+	DriftNullability     = "nullable_mismatch"
+	DriftMissingIndex    = "missing_index"
+	DriftExtraColumn     = "extra_column"
+	DriftUnreadableTable = "unreadable_table"
+)
+
+type SchemaDrift struct {
+	Table    string `json:"table"`
+	Column   string `json:"column,omitempty"`
+	Index    string `json:"index,omitempty"`
+	Problem  string `json:"problem"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+type DriftReport struct {
+	// Drifted is true when anything but an extra column was found
+	Drifted bool          `json:"drifted"`
+	Tables  int           `json:"tables"`
+	Drift   []SchemaDrift `json:"drift"`
+}
+
+// Drift compares the live schema with the one AutoMigrate creates for models: tables, columns with their
+// type and nullability, and indexes. It only reads the information schema, nothing is changed.
+func Drift(tx *gorm.DB, models ...any) (DriftReport, error) {
+	report := DriftReport{Drift: []SchemaDrift{}}
+	migrator := tx.Migrator()
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return report, fmt.Errorf("failed to parse %T: %w", model, err)
+		}
+		table := stmt.Table
+		report.Tables++
+		if !migrator.HasTable(table) {
+			report.add(SchemaDrift{Table: table, Problem: DriftMissingTable})
+			continue
+		}
+
+		columnTypes, err := migrator.ColumnTypes(table)
+		if err != nil {
+			report.add(SchemaDrift{Table: table, Problem: DriftUnreadableTable, Actual: err.Error()})
+			continue
+		}
+		live := make(map[string]gorm.ColumnType, len(columnTypes))
+		for _, column := range columnTypes {
+			live[column.Name()] = column
+		}
+
+		for _, name := range stmt.Schema.DBNames {
+			field := stmt.Schema.FieldsByDBName[name]
+			column, ok := live[name]
+			if !ok {
+				report.add(SchemaDrift{Table: table, Column: name, Problem: DriftMissingColumn, Expected: tx.Dialector.DataTypeOf(field)})
+				continue
+			}
+			delete(live, name)
+
+			expected := tx.Dialector.DataTypeOf(field)
+			if actual, ok := column.ColumnType(); ok && normalizeType(actual) != normalizeType(expected) {
+				report.add(SchemaDrift{Table: table, Column: name, Problem: DriftTypeMismatch, Expected: expected, Actual: actual})
+			}
+			// Primary keys are NOT NULL on MySQL whatever the column says, SQLite reports them as nullable
+			wantNullable := !field.NotNull
+			if nullable, ok := column.Nullable(); ok && !field.PrimaryKey && nullable != wantNullable {
+				report.add(SchemaDrift{
+					Table: table, Column: name, Problem: DriftNullability,
+					Expected: nullability(wantNullable), Actual: nullability(nullable),
+				})
+			}
+		}
+		for name, column := range live {
+			actual, _ := column.ColumnType()
+			report.add(SchemaDrift{Table: table, Column: name, Problem: DriftExtraColumn, Actual: actual})
+		}
+
+		for _, index := range stmt.Schema.ParseIndexes() {
+			if !migrator.HasIndex(model, index.Name) {
+				report.add(SchemaDrift{Table: table, Index: index.Name, Problem: DriftMissingIndex})
+			}
+		}
+	}
+	return report, nil
+}
+
+func (r *DriftReport) add(drift SchemaDrift) {
+	r.Drift = append(r.Drift, drift)
+	if drift.Problem != DriftExtraColumn {
+		r.Drifted = true
+	}
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+// intDisplayWidth is the width MySQL 5.7 shows for integer columns, bigint(20), which 8.0 dropped
+var intDisplayWidth = regexp.MustCompile(`^((?:tiny|small|medium|big)?int)\(\d+\)`)
+
+// normalizeType makes the type GORM declares comparable with the one the server reports
+func normalizeType(columnType string) string {
+	columnType = strings.ToLower(strings.TrimSpace(columnType))
+	switch columnType {
+	case "boolean", "bool":
+		// MySQL stores booleans as tinyint(1)
+		return "tinyint"
+	}
+	return intDisplayWidth.ReplaceAllString(columnType, "$1")
+}
@@ -0,0 +1,36 @@
+package db_test
+
+import (
+	"app/apptest"
+	"app/db"
+	"app/model"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	apptest.DB(t, model.All...)
+	report, err := db.Drift(db.DB, model.All...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Drifted || report.Tables != len(model.All) {
+		t.Fatalf("freshly migrated schema: %+v", report)
+	}
+
+	if err := db.DB.Migrator().DropColumn(&model.Sample{}, "natural_key"); err != nil {
+		t.Fatal(err)
+	}
+	report, err = db.Drift(db.DB, model.All...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, drift := range report.Drift {
+		if drift.Table == "samples" && drift.Column == "natural_key" && drift.Problem == db.DriftMissingColumn {
+			found = true
+		}
+	}
+	if !report.Drifted || !found {
+		t.Errorf("after dropping natural_key: %+v", report)
+	}
+}
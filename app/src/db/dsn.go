@@ -0,0 +1,88 @@
+package db
+
+import (
+	"app/config"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// configFromEnv uses DATABASE_URI when set, otherwise assembles the DSN from the
+// discrete DB_HOST / DB_PORT / DB_USER / DB_NAME variables the way Kubernetes Secrets are usually mounted.
+// DB_PASSWORD_FILE is resolved by the credential source so that rotations are picked up.
+func configFromEnv() (*mysqldriver.Config, error) {
+	var cfg *mysqldriver.Config
+	if dsn := os.Getenv("DATABASE_URI"); dsn != "" {
+		parsed, err := mysqldriver.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		cfg = parsed
+	} else {
+		// The connector dials the instance by its connection name
+		host := config.String("DB_HOST", config.String("DB_INSTANCE_CONNECTION_NAME", ""))
+		if host == "" {
+			return nil, errors.New("DATABASE_URI or DB_HOST environment variable must be set")
+		}
+
+		cfg = mysqldriver.NewConfig()
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(host, config.String("DB_PORT", "3306"))
+		cfg.User = config.String("DB_USER", "app")
+		cfg.Passwd = config.String("DB_PASSWORD", "")
+		cfg.DBName = config.String("DB_NAME", "app")
+		cfg.ParseTime = true
+		cfg.Loc = time.Local
+		cfg.Timeout = 10 * time.Second
+		cfg.Params = map[string]string{"charset": "utf8mb4"}
+	}
+
+	if err := applyParamsFromEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := applyTLSFromEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := connectorFromEnv(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyParamsFromEnv sets the connection parameters, each defaulting to what DATABASE_URI or the
+// discrete variables already set. DB_PARAMS adds server variables, e.g. DB_PARAMS=time_zone='+00:00'.
+func applyParamsFromEnv(cfg *mysqldriver.Config) error {
+	cfg.Timeout = config.Duration("DB_CONNECT_TIMEOUT", cfg.Timeout)
+	cfg.ReadTimeout = config.Duration("DB_READ_TIMEOUT", cfg.ReadTimeout)
+	cfg.WriteTimeout = config.Duration("DB_WRITE_TIMEOUT", cfg.WriteTimeout)
+	cfg.ParseTime = config.Bool("DB_PARSE_TIME", cfg.ParseTime)
+	if name := config.String("DB_LOC", ""); name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return fmt.Errorf("invalid DB_LOC %q: %w", name, err)
+		}
+		cfg.Loc = loc
+	}
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	if charset := config.String("DB_CHARSET", ""); charset != "" {
+		cfg.Params["charset"] = charset
+	}
+	if collation := config.String("DB_COLLATION", ""); collation != "" {
+		cfg.Collation = collation
+	}
+	for _, param := range config.List("DB_PARAMS") {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid DB_PARAMS entry %q, expected name=value", param)
+		}
+		cfg.Params[name] = value
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("DB_HOST", "mysql.default.svc")
+	t.Setenv("DB_READ_TIMEOUT", "30s")
+	t.Setenv("DB_PARAMS", "time_zone='+00:00'")
+	t.Setenv("DB_TLS", TLSRequired)
+
+	cfg, err := configFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Timeout != 10*time.Second || cfg.ReadTimeout != 30*time.Second || !cfg.ParseTime {
+		t.Errorf("timeout = %v, read timeout = %v, parseTime = %v", cfg.Timeout, cfg.ReadTimeout, cfg.ParseTime)
+	}
+	if cfg.Params["charset"] != "utf8mb4" || cfg.Params["time_zone"] != "'+00:00'" {
+		t.Errorf("params = %v", cfg.Params)
+	}
+	if cfg.TLSConfig != tlsConfigName {
+		t.Errorf("tls = %q, want the registered %q", cfg.TLSConfig, tlsConfigName)
+	}
+	if args := ClientTLSArgs(); !slices.Equal(args, []string{"--ssl-mode=REQUIRED"}) {
+		t.Errorf("ClientTLSArgs() = %v", args)
+	}
+
+	// The variables override the DSN's parameters only when they are set
+	t.Setenv("DATABASE_URI", "app:secret@tcp(db:3306)/app?parseTime=true&readTimeout=5s&tls=skip-verify")
+	t.Setenv("DB_READ_TIMEOUT", "")
+	t.Setenv("DB_TLS", "")
+	if cfg, err = configFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ReadTimeout != 5*time.Second || cfg.TLSConfig != "skip-verify" {
+		t.Errorf("read timeout = %v, tls = %q", cfg.ReadTimeout, cfg.TLSConfig)
+	}
+
+	t.Setenv("DB_TLS", "sometimes")
+	if _, err := configFromEnv(); err == nil {
+		t.Error("unsupported DB_TLS accepted")
+	}
+}
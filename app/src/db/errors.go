@@ -0,0 +1,97 @@
+package db
+
+import (
+	"app/apperrors"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	sqlite "github.com/glebarez/go-sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// MySQL error numbers and SQLite extended result codes of the constraint violations
+const (
+	mysqlDuplicateEntry  = 1062
+	mysqlRowIsReferenced = 1451
+	mysqlNoReferencedRow = 1452
+	mysqlLockDeadlock    = 1213
+
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// TranslateError classifies a database error: a missing record is NotFound, a duplicate key or
+// a foreign key violation is a Conflict, a deadlock is Retryable and a lost connection is a Dependency failure.
+// The original error stays in the chain, so errors.Is(err, gorm.ErrRecordNotFound) still holds.
+func TranslateError(err error) error {
+	var appErr *apperrors.Error
+	if err == nil || errors.As(err, &appErr) {
+		return err
+	}
+
+	var mysqlErr *mysqldriver.MySQLError
+	var sqliteErr *sqlite.Error
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		// The request ran out of time, which says nothing about the database. The deadline error is
+		// also a net.Error and would otherwise be reported as the database being unavailable.
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apperrors.Wrap(apperrors.NotFound, "", err)
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.Is(err, gorm.ErrForeignKeyViolated):
+		return apperrors.Wrap(apperrors.Conflict, "", err)
+	case errors.As(err, &mysqlErr):
+		switch mysqlErr.Number {
+		case mysqlDuplicateEntry:
+			return apperrors.Wrap(apperrors.Conflict, "duplicate key", err)
+		case mysqlRowIsReferenced, mysqlNoReferencedRow:
+			return apperrors.Wrap(apperrors.Conflict, "foreign key violation", err)
+		case mysqlLockDeadlock:
+			// MySQL rolled the transaction back, it can be run again from the start
+			return apperrors.Wrap(apperrors.Retryable, "deadlock", err)
+		}
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() {
+		case sqliteConstraintUnique, sqliteConstraintPrimaryKey:
+			return apperrors.Wrap(apperrors.Conflict, "duplicate key", err)
+		case sqliteConstraintForeignKey:
+			return apperrors.Wrap(apperrors.Conflict, "foreign key violation", err)
+		}
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, mysqldriver.ErrInvalidConn), errors.As(err, &netErr):
+		return apperrors.Wrap(apperrors.Dependency, "database unavailable", err)
+	}
+	return err
+}
+
+// ErrorTranslator is a GORM plugin running TranslateError on the error of every statement,
+// so services and controllers see classified errors without translating them themselves
+type ErrorTranslator struct{}
+
+func (ErrorTranslator) Name() string {
+	return "app:error_translator"
+}
+
+func (ErrorTranslator) Initialize(db *gorm.DB) error {
+	translate := func(tx *gorm.DB) {
+		tx.Error = TranslateError(tx.Error)
+	}
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().After("*").Register("app:translate_create_error", translate),
+		callbacks.Query().After("*").Register("app:translate_query_error", translate),
+		callbacks.Update().After("*").Register("app:translate_update_error", translate),
+		callbacks.Delete().After("*").Register("app:translate_delete_error", translate),
+		callbacks.Row().After("*").Register("app:translate_row_error", translate),
+		callbacks.Raw().After("*").Register("app:translate_raw_error", translate),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package db_test
+
+import (
+	"app/apperrors"
+	"app/apptest"
+	"app/db"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type uniqueRecord struct {
+	ID   uint
+	Name string `gorm:"uniqueIndex"`
+}
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want apperrors.Kind
+	}{
+		{"record not found", gorm.ErrRecordNotFound, apperrors.NotFound},
+		{"mysql duplicate entry", &mysqldriver.MySQLError{Number: 1062, Message: "Duplicate entry"}, apperrors.Conflict},
+		{"mysql row is referenced", &mysqldriver.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row"}, apperrors.Conflict},
+		{"mysql no referenced row", &mysqldriver.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}, apperrors.Conflict},
+		{"mysql deadlock", &mysqldriver.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, apperrors.Retryable},
+		{"other mysql error", &mysqldriver.MySQLError{Number: 1064, Message: "syntax error"}, apperrors.Internal},
+		{"bad connection", fmt.Errorf("query: %w", driver.ErrBadConn), apperrors.Dependency},
+		{"network error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, apperrors.Dependency},
+		{"deadline exceeded", fmt.Errorf("query: %w", context.DeadlineExceeded), apperrors.Internal},
+		{"already classified", apperrors.New(apperrors.Validation, "invalid"), apperrors.Validation},
+	}
+	for _, tt := range tests {
+		err := db.TranslateError(tt.err)
+		if kind := apperrors.KindOf(err); kind != tt.want {
+			t.Errorf("%s: kind = %v, want %v", tt.name, kind, tt.want)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: the original error is not in the chain of %v", tt.name, err)
+		}
+	}
+	if db.TranslateError(nil) != nil {
+		t.Error("nil was translated to an error")
+	}
+}
+
+func TestErrorTranslatorClassifiesSQLiteErrors(t *testing.T) {
+	conn := apptest.DB(t, &uniqueRecord{})
+	ctx := context.Background()
+
+	if err := conn.WithContext(ctx).Create(&uniqueRecord{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	err := conn.WithContext(ctx).Create(&uniqueRecord{Name: "a"}).Error
+	if kind := apperrors.KindOf(err); kind != apperrors.Conflict {
+		t.Errorf("duplicate unique key: kind = %v (%v), want conflict", kind, err)
+	}
+	err = conn.WithContext(ctx).Create(&uniqueRecord{ID: 1, Name: "b"}).Error
+	if kind := apperrors.KindOf(err); kind != apperrors.Conflict {
+		t.Errorf("duplicate primary key: kind = %v (%v), want conflict", kind, err)
+	}
+
+	err = conn.WithContext(ctx).First(&uniqueRecord{}, "name = ?", "missing").Error
+	if kind := apperrors.KindOf(err); kind != apperrors.NotFound || !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("missing record: kind = %v (%v), want not_found wrapping gorm.ErrRecordNotFound", kind, err)
+	}
+}
+
+func TestErrorTranslatorClassifiesSQLiteForeignKeyErrors(t *testing.T) {
+	// apptest's database does not enforce foreign keys, this one does on its single connection
+	conn, err := gorm.Open(sqlite.Open("file::memory:?_pragma=foreign_keys(1)"), &gorm.Config{
+		Logger:  logger.Discard,
+		Plugins: map[string]gorm.Plugin{db.ErrorTranslator{}.Name(): db.ErrorTranslator{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := conn.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	for _, statement := range []string{
+		"CREATE TABLE parents (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER NOT NULL REFERENCES parents (id))",
+	} {
+		if err := conn.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = conn.Exec("INSERT INTO children (id, parent_id) VALUES (1, 42)").Error
+	if kind := apperrors.KindOf(err); kind != apperrors.Conflict {
+		t.Errorf("missing parent: kind = %v (%v), want conflict", kind, err)
+	}
+}
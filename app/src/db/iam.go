@@ -0,0 +1,150 @@
+package db
+
+import (
+	"app/config"
+	"app/httpclient"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// Token is a short-lived database password
+type Token struct {
+	Value  string
+	Expiry time.Time
+}
+
+// TokenProvider mints database passwords from the workload's cloud identity
+type TokenProvider interface {
+	Name() string
+	Token(ctx context.Context) (Token, error)
+}
+
+// TokenSource is a CredentialSource whose password is a token minted by Provider, reused until
+// RefreshBefore its expiry. Tokens only authenticate new connections, open ones outlive them.
+type TokenSource struct {
+	Provider      TokenProvider
+	RefreshBefore time.Duration
+
+	mu    sync.Mutex
+	token Token
+}
+
+func (s *TokenSource) Name() string {
+	return "iam:" + s.Provider.Name()
+}
+
+func (s *TokenSource) Credentials(ctx context.Context) (Credentials, error) {
+	token, err := s.Token(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{Password: token.Value}, nil
+}
+
+// Token returns the cached token, or a new one once the cached one expires within RefreshBefore
+func (s *TokenSource) Token(ctx context.Context) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token.Value == "" || time.Until(s.token.Expiry) < s.RefreshBefore {
+		token, err := s.Provider.Token(ctx)
+		if err != nil {
+			return Token{}, fmt.Errorf("failed to mint %s database token: %w", s.Provider.Name(), err)
+		}
+		s.token = token
+	}
+	return s.token, nil
+}
+
+// tokenSourceFromEnv returns the source for DB_IAM_AUTH (rds or cloudsql), nil when it is unset.
+// The token is sent as a cleartext password, so it is refused unless the connection verifies the
+// server: a token sent in plaintext or to whoever answers on the address is a database login.
+func tokenSourceFromEnv(cfg *mysqldriver.Config) (*TokenSource, error) {
+	var provider TokenProvider
+	switch mode := config.String("DB_IAM_AUTH", ""); mode {
+	case "":
+		return nil, nil
+	case "rds":
+		rds, err := NewRDSProviderFromEnv(cfg.Addr, cfg.User)
+		if err != nil {
+			return nil, err
+		}
+		provider = rds
+	case "cloudsql":
+		provider = NewCloudSQLProviderFromEnv()
+	default:
+		return nil, fmt.Errorf("unsupported DB_IAM_AUTH %q", mode)
+	}
+	if !verifiesServer(cfg) {
+		return nil, errors.New("DB_IAM_AUTH sends the token as a cleartext password, set DB_TLS to verify-ca or verify-full or connect with DB_CONNECTOR=cloudsql")
+	}
+	cfg.AllowCleartextPasswords = true
+	return &TokenSource{Provider: provider, RefreshBefore: config.Duration("DB_IAM_REFRESH_BEFORE", 5*time.Minute)}, nil
+}
+
+// verifiesServer reports whether cfg connects over TLS checking the server's certificate. The Cloud SQL
+// connector does the TLS itself with the instance's certificate, the driver's own is off then.
+func verifiesServer(cfg *mysqldriver.Config) bool {
+	if cfg.Net == cloudSQLNet {
+		return true
+	}
+	switch cfg.TLSConfig {
+	case "", "false", "skip-verify", "preferred":
+		return false
+	case tlsConfigName:
+		return tlsSettings.Mode != TLSRequired
+	}
+	return true
+}
+
+// CloudSQLProvider uses an OAuth2 access token of the workload's Google service account as the password
+// of a Cloud SQL IAM database user. On GKE with Workload Identity the metadata server issues it for the
+// service account bound to the pod's Kubernetes ServiceAccount.
+type CloudSQLProvider struct {
+	TokenURL string
+	Client   *http.Client
+}
+
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+func NewCloudSQLProviderFromEnv() *CloudSQLProvider {
+	return &CloudSQLProvider{
+		TokenURL: config.String("DB_IAM_TOKEN_URL", metadataTokenURL),
+		Client:   httpclient.New(10 * time.Second),
+	}
+}
+
+func (p *CloudSQLProvider) Name() string {
+	return "cloudsql"
+}
+
+func (p *CloudSQLProvider) Token(ctx context.Context) (Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.TokenURL, nil)
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return Token{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return Token{}, fmt.Errorf("metadata server answered %d", res.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return Token{}, err
+	}
+	return Token{Value: body.AccessToken, Expiry: time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)}, nil
+}
@@ -0,0 +1,47 @@
+package db
+
+import "testing"
+
+func TestTokenSourceRequiresVerifiedTLS(t *testing.T) {
+	t.Setenv("DB_HOST", "mysql.default.svc")
+	t.Setenv("DB_IAM_AUTH", "cloudsql")
+
+	for _, tt := range []struct {
+		tls    string
+		uri    string
+		accept bool
+	}{
+		{tls: "", accept: false},
+		{tls: TLSDisabled, accept: false},
+		{tls: TLSPreferred, accept: false},
+		// required encrypts but takes any certificate, the token would go to whoever answers
+		{tls: TLSRequired, accept: false},
+		{tls: TLSVerifyCA, accept: true},
+		{tls: TLSVerifyFull, accept: true},
+		{uri: "app@tcp(db:3306)/app?tls=skip-verify", accept: false},
+		{uri: "app@tcp(db:3306)/app?tls=true", accept: true},
+	} {
+		t.Setenv("DB_TLS", tt.tls)
+		t.Setenv("DATABASE_URI", tt.uri)
+		cfg, err := configFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		source, err := tokenSourceFromEnv(cfg)
+		if accepted := err == nil && source != nil; accepted != tt.accept {
+			t.Errorf("DB_TLS %q DATABASE_URI %q: accepted = %v (%v), want %v", tt.tls, tt.uri, accepted, err, tt.accept)
+		}
+		if err == nil && !cfg.AllowCleartextPasswords {
+			t.Errorf("DB_TLS %q DATABASE_URI %q: cleartext passwords are not allowed for the token", tt.tls, tt.uri)
+		}
+	}
+
+	// The Cloud SQL connector does the TLS itself
+	t.Setenv("DB_TLS", "")
+	t.Setenv("DATABASE_URI", "")
+	cfg, _ := configFromEnv()
+	cfg.Net = cloudSQLNet
+	if _, err := tokenSourceFromEnv(cfg); err != nil {
+		t.Errorf("through the cloud sql connector: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+)
+
+// Leader elects one replica for a singleton task by the MySQL named lock name, held on a connection
+// of its own for as long as the replica lives. The others find it taken and skip the task, so it runs
+// once per interval instead of once per replica. Databases other than MySQL have one process, which leads.
+type Leader struct {
+	name string
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func NewLeader(name string) *Leader {
+	return &Leader{name: name}
+}
+
+// Acquire reports whether this replica leads, taking the lock when nobody holds it. A leader whose
+// connection was lost gives the lock up with it and competes again.
+func (l *Leader) Acquire(ctx context.Context) bool {
+	if DB == nil || DB.Dialector.Name() != "mysql" {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		var held sql.NullInt64
+		err := l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", l.name).Scan(&held)
+		if err == nil && held.Valid && held.Int64 == 1 {
+			return true
+		}
+		slog.Warn("lost the leader lock", "lock", l.name, "error", err)
+		_ = l.conn.Close()
+		l.conn = nil
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return false
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		slog.Warn("failed to open a connection for the leader lock", "lock", l.name, "error", err)
+		return false
+	}
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", l.name).Scan(&acquired); err != nil || !acquired.Valid || acquired.Int64 != 1 {
+		_ = conn.Close()
+		return false
+	}
+	slog.Info("took the leader lock", "lock", l.name)
+	l.conn = conn
+	return true
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"app/config"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLock is the MySQL named lock every replica and `app migrate` take before migrating
+const migrationLock = "app_migrate"
+
+// ErrMigrationLockTimeout is returned when another replica held the lock for all of DB_MIGRATE_LOCK_TIMEOUT
+var ErrMigrationLockTimeout = errors.New("timed out waiting for the migration lock")
+
+// Migrate runs AutoMigrate for models while holding the migration lock, so replicas starting together
+// migrate one after another instead of racing on the same ALTER TABLEs. The ones that waited find the
+// schema up to date and their AutoMigrate changes nothing. Databases other than MySQL migrate unlocked.
+func Migrate(ctx context.Context, tx *gorm.DB, models ...any) error {
+	if tx.Dialector.Name() != "mysql" {
+		return tx.WithContext(ctx).AutoMigrate(models...)
+	}
+	sqlDB, err := tx.DB()
+	if err != nil {
+		return err
+	}
+	// A named lock belongs to the connection that took it, so one is kept aside until it is released
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	timeout := config.Duration("DB_MIGRATE_LOCK_TIMEOUT", 5*time.Minute)
+	started := time.Now()
+	slog.Info("waiting for the migration lock", "lock", migrationLock, "timeout", timeout)
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLock, int(timeout.Seconds())).Scan(&acquired); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return ErrMigrationLockTimeout
+	}
+	defer func() {
+		// Released on a fresh context, the migration's may be canceled by now
+		if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationLock); err != nil {
+			slog.Warn("failed to release the migration lock, it goes with the connection", "error", err)
+		}
+	}()
+	slog.Info("took the migration lock", "waited", time.Since(started).Round(time.Millisecond))
+
+	return tx.WithContext(ctx).AutoMigrate(models...)
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"app/config"
+	"app/health"
+	"log/slog"
+
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// InitMock backs DB with an in-memory SQLite database for APP_MODE=mock, so the app runs as a
+// stub without MySQL. The data lives as long as the process, every pod has its own copy.
+func InitMock() {
+	// A shared cache keeps one database across the pool's connections
+	conn, err := gorm.Open(sqlite.Open("file:mock?mode=memory&cache=shared"), gormConfigFromEnv())
+	if err != nil {
+		slog.Error("failed to open mock database", "error", err)
+		panic("failed to open mock database")
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		panic(err)
+	}
+	// The in-memory database is dropped when its last connection closes, so one is always kept
+	sqlDB.SetMaxIdleConns(max(config.Int("DB_MAX_IDLE_CONNS", 2), 1))
+
+	DB = conn
+	connConfig = mysqldriver.NewConfig()
+	connConfig.DBName = "mock"
+	slog.Warn("running in mock mode, data is kept in memory and lost on restart")
+
+	health.Register(health.Check{
+		Name:     "database",
+		Critical: true,
+		Run:      sqlDB.PingContext,
+	})
+}
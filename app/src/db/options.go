@@ -0,0 +1,34 @@
+package db
+
+import (
+	"app/config"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// gormConfigFromEnv enables the GORM performance options. All of them default to off
+// so the behaviour only changes once a deployment opts in.
+func gormConfigFromEnv() *gorm.Config {
+	cfg := &gorm.Config{
+		// Caches the server-side prepared statement per SQL string and connection,
+		// saving the parse/plan round trip on every hot query
+		PrepareStmt:        config.Bool("DB_PREPARE_STMT", false),
+		PrepareStmtMaxSize: config.Int("DB_PREPARE_STMT_MAX_SIZE", 0),
+		PrepareStmtTTL:     config.Duration("DB_PREPARE_STMT_TTL", 0),
+		// Single-statement writes do not need the BEGIN/COMMIT GORM wraps them in
+		SkipDefaultTransaction: config.Bool("DB_SKIP_DEFAULT_TRANSACTION", false),
+		Plugins:                map[string]gorm.Plugin{ErrorTranslator{}.Name(): ErrorTranslator{}},
+	}
+	if compat := SchemaCompatFromEnv(); compat != nil {
+		cfg.Plugins[compat.Name()] = compat
+	}
+	if cfg.PrepareStmt || cfg.SkipDefaultTransaction {
+		slog.Info("gorm performance options are enabled",
+			"prepare_stmt", cfg.PrepareStmt,
+			"prepare_stmt_max_size", cfg.PrepareStmtMaxSize,
+			"skip_default_transaction", cfg.SkipDefaultTransaction,
+		)
+	}
+	return cfg
+}
@@ -0,0 +1,245 @@
+package db
+
+import (
+	"app/config"
+	"app/httpclient"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rdsTokenLifetime is fixed by RDS, a token authenticates new connections for 15 minutes
+const rdsTokenLifetime = 15 * time.Minute
+
+// RDSProvider signs RDS IAM authentication tokens for Endpoint (host:port) and User, a database user
+// created WITH AWSAuthenticationPlugin AS 'RDS'. The signing credentials come from the pod's identity.
+type RDSProvider struct {
+	Endpoint    string
+	User        string
+	Region      string
+	Credentials *AWSCredentials
+}
+
+func NewRDSProviderFromEnv(endpoint, user string) (*RDSProvider, error) {
+	region := config.String("DB_IAM_REGION", config.String("AWS_REGION", config.String("AWS_DEFAULT_REGION", "")))
+	if region == "" {
+		return nil, errors.New("DB_IAM_AUTH=rds needs AWS_REGION or DB_IAM_REGION")
+	}
+	return &RDSProvider{Endpoint: endpoint, User: user, Region: region, Credentials: NewAWSCredentialsFromEnv(region)}, nil
+}
+
+func (p *RDSProvider) Name() string {
+	return "rds"
+}
+
+// Token is a SigV4 presigned connect request, the way the AWS SDKs build it, without the scheme
+func (p *RDSProvider) Token(ctx context.Context) (Token, error) {
+	creds, err := p.Credentials.get(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	now := time.Now().UTC()
+	query := url.Values{
+		"Action":              {"connect"},
+		"DBUser":              {p.User},
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {creds.AccessKeyID + "/" + credentialScope(now, p.Region, "rds-db")},
+		"X-Amz-Date":          {now.Format("20060102T150405Z")},
+		"X-Amz-Expires":       {fmt.Sprint(int(rdsTokenLifetime.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if creds.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	canonicalQuery := canonicalQueryString(query)
+	signature := signV4(creds.SecretAccessKey, now, p.Region, "rds-db",
+		"GET\n/\n"+canonicalQuery+"\nhost:"+p.Endpoint+"\n\nhost\n"+emptyPayloadHash)
+	return Token{
+		Value:  p.Endpoint + "/?" + canonicalQuery + "&X-Amz-Signature=" + signature,
+		Expiry: now.Add(rdsTokenLifetime),
+	}, nil
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func credentialScope(t time.Time, region, service string) string {
+	return t.Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+}
+
+// signV4 signs a canonical request with the Signature Version 4 key derivation
+func signV4(secret string, t time.Time, region, service, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" +
+		credentialScope(t, region, service) + "\n" + hex.EncodeToString(hashed[:])
+
+	// The signing key is derived from the date, region and service, the last HMAC is the signature
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{t.Format("20060102"), region, service, "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	return hex.EncodeToString(key)
+}
+
+// canonicalQueryString sorts the parameters and escapes everything but the unreserved characters,
+// which differs from url.Values.Encode in writing spaces as %20
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, awsEscape(key)+"="+awsEscape(query.Get(key)))
+	}
+	return strings.Join(parts, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// AWSCredentials resolves the pod's AWS credentials like the SDKs' default chain does for Kubernetes:
+// EKS Pod Identity (AWS_CONTAINER_CREDENTIALS_FULL_URI), IRSA (AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE)
+// or static AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY. Temporary credentials are reused until close to
+// their expiry.
+type AWSCredentials struct {
+	Region string
+	Client *http.Client
+
+	mu      sync.Mutex
+	current awsCredentials
+}
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiry          time.Time
+}
+
+func NewAWSCredentialsFromEnv(region string) *AWSCredentials {
+	return &AWSCredentials{Region: region, Client: httpclient.New(10 * time.Second)}
+}
+
+func (c *AWSCredentials) get(ctx context.Context) (awsCredentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current.AccessKeyID != "" && (c.current.Expiry.IsZero() || time.Until(c.current.Expiry) > 5*time.Minute) {
+		return c.current, nil
+	}
+
+	var creds awsCredentials
+	var err error
+	switch {
+	case config.String("AWS_CONTAINER_CREDENTIALS_FULL_URI", "") != "":
+		creds, err = c.podIdentity(ctx)
+	case config.String("AWS_ROLE_ARN", "") != "" && config.String("AWS_WEB_IDENTITY_TOKEN_FILE", "") != "":
+		creds, err = c.webIdentity(ctx)
+	case config.String("AWS_ACCESS_KEY_ID", "") != "":
+		creds = awsCredentials{
+			AccessKeyID:     config.String("AWS_ACCESS_KEY_ID", ""),
+			SecretAccessKey: config.String("AWS_SECRET_ACCESS_KEY", ""),
+			SessionToken:    config.String("AWS_SESSION_TOKEN", ""),
+		}
+	default:
+		err = errors.New("no aws credentials: set up EKS Pod Identity or IRSA for the pod's ServiceAccount")
+	}
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	c.current = creds
+	return creds, nil
+}
+
+// podIdentity asks the EKS Pod Identity agent, authenticating with the token it projects into the pod
+func (c *AWSCredentials) podIdentity(ctx context.Context) (awsCredentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.String("AWS_CONTAINER_CREDENTIALS_FULL_URI", ""), nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if path := config.String("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", ""); path != "" {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("failed to read the pod identity token: %w", err)
+		}
+		req.Header.Set("Authorization", strings.TrimSpace(string(token)))
+	}
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("pod identity agent answered %d", res.StatusCode)
+	}
+
+	var body struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return awsCredentials{}, err
+	}
+	return awsCredentials{AccessKeyID: body.AccessKeyID, SecretAccessKey: body.SecretAccessKey, SessionToken: body.Token, Expiry: body.Expiration}, nil
+}
+
+// webIdentity exchanges the projected ServiceAccount token for the IRSA role, the call needs no signature
+func (c *AWSCredentials) webIdentity(ctx context.Context) (awsCredentials, error) {
+	token, err := os.ReadFile(config.String("AWS_WEB_IDENTITY_TOKEN_FILE", ""))
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to read the web identity token: %w", err)
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {config.String("AWS_ROLE_ARN", "")},
+		"RoleSessionName":  {config.String("AWS_ROLE_SESSION_NAME", "app-db")},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	endpoint := config.String("AWS_STS_ENDPOINT", "https://sts."+c.Region+".amazonaws.com")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(query.Encode()))
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("sts answered %d", res.StatusCode)
+	}
+
+	var body struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(res.Body).Decode(&body); err != nil {
+		return awsCredentials{}, err
+	}
+	creds := body.Credentials
+	return awsCredentials{AccessKeyID: creds.AccessKeyID, SecretAccessKey: creds.SecretAccessKey, SessionToken: creds.SessionToken, Expiry: creds.Expiration}, nil
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"app/config"
+	"app/metrics"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"sync/atomic"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// Replica read modes of DB_REPLICA_READS
+const (
+	// ReplicaReadsFailover reads from the primary and only from the replicas while it is down
+	ReplicaReadsFailover = "failover"
+	// ReplicaReadsAlways spreads every read outside a transaction over the replicas
+	ReplicaReadsAlways = "always"
+)
+
+var (
+	replicaReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "db",
+		Name:      "replica_reads_total",
+		Help:      "Reads sent to a replica by reason: failover while the primary is down or balanced by DB_REPLICA_READS=always.",
+	}, []string{"reason"})
+
+	failoverActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "db",
+		Name:      "failover_active",
+		Help:      "1 while the primary is down and reads fail over to the replicas.",
+	})
+)
+
+// primaryDown is set by the database health check, reads fail over while it is
+var primaryDown atomic.Bool
+
+// PrimaryDown reports whether the last database check failed to reach the primary
+func PrimaryDown() bool {
+	return primaryDown.Load()
+}
+
+type replica struct {
+	addr string
+	db   *sql.DB
+	down atomic.Bool
+}
+
+// failoverPolicy picks the connection pool of a read among the primary's own, first, and the replicas'
+type failoverPolicy struct {
+	always   bool
+	replicas []*replica
+}
+
+func (p *failoverPolicy) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	failover := primaryDown.Load()
+	if !failover && !p.always {
+		return pools[0]
+	}
+	up := make([]int, 0, len(p.replicas))
+	for i, r := range p.replicas {
+		if !r.down.Load() {
+			up = append(up, i+1)
+		}
+	}
+	if len(up) == 0 {
+		// Every replica is down as well, the primary's error is the one to report
+		return pools[0]
+	}
+	reason := "balanced"
+	if failover {
+		reason = "failover"
+	}
+	replicaReads.WithLabelValues(reason).Inc()
+	return pools[up[rand.IntN(len(up))]]
+}
+
+// useReplicasFromEnv registers dbresolver with the replicas of DB_REPLICA_HOSTS, host:port pairs reached
+// with the primary's user, database, TLS and credentials. Writes and transactions stay on the primary.
+// It returns the replicas for their health check, none when DB_REPLICA_HOSTS is unset.
+func useReplicasFromEnv(conn *gorm.DB, primary *sql.DB, cfg *mysqldriver.Config) ([]*replica, error) {
+	hosts := config.List("DB_REPLICA_HOSTS")
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	mode := config.String("DB_REPLICA_READS", ReplicaReadsFailover)
+	if mode != ReplicaReadsFailover && mode != ReplicaReadsAlways {
+		return nil, fmt.Errorf("invalid DB_REPLICA_READS %q, expected failover or always", mode)
+	}
+
+	policy := &failoverPolicy{always: mode == ReplicaReadsAlways}
+	// The primary's pool comes first among the read pools, for the policy to keep reads on it
+	dialectors := []gorm.Dialector{mysql.New(mysql.Config{Conn: primary})}
+	for _, host := range hosts {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "3306")
+		}
+		replicaCfg := cfg.Clone()
+		replicaCfg.Addr = host
+		connector, err := mysqldriver.NewConnector(replicaCfg)
+		if err != nil {
+			return nil, fmt.Errorf("replica %s: %w", host, err)
+		}
+		r := &replica{addr: host, db: sql.OpenDB(connector)}
+		r.db.SetMaxIdleConns(config.Int("DB_MAX_IDLE_CONNS", 2))
+		r.db.SetConnMaxLifetime(config.Duration("DB_CONN_MAX_LIFETIME", 30*time.Minute))
+		policy.replicas = append(policy.replicas, r)
+		dialectors = append(dialectors, mysql.New(mysql.Config{Conn: r.db}))
+	}
+
+	err := conn.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   policy,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("database replicas are configured", "replicas", hosts, "reads", mode)
+	return policy.replicas, nil
+}
+
+// pingPrimary is the database check with replicas, recording whether reads fail over
+func pingPrimary(primary *sql.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		err := primary.PingContext(ctx)
+		if down := err != nil; primaryDown.Swap(down) != down {
+			if down {
+				slog.Warn("primary database is down, reads fail over to the replicas", "error", err)
+				failoverActive.Set(1)
+			} else {
+				slog.Info("primary database is back, reads return to it")
+				failoverActive.Set(0)
+			}
+		}
+		return err
+	}
+}
+
+// pingReplicas fails with the replicas it cannot reach, which reads skip until they answer again
+func pingReplicas(replicas []*replica) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, r := range replicas {
+			err := r.db.PingContext(ctx)
+			r.down.Store(err != nil)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", r.addr, err))
+			}
+		}
+		if len(errs) == len(replicas) {
+			return errors.Join(errs...)
+		}
+		// Some replicas are enough for the reads, the others are only logged
+		for _, err := range errs {
+			slog.Warn("database replica is down", "error", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestFailoverPolicy(t *testing.T) {
+	primary, first, second := &sql.DB{}, &sql.DB{}, &sql.DB{}
+	pools := []gorm.ConnPool{primary, first, second}
+	policy := &failoverPolicy{replicas: []*replica{{addr: "replica-0"}, {addr: "replica-1"}}}
+	t.Cleanup(func() { primaryDown.Store(false) })
+
+	if got := policy.Resolve(pools); got != primary {
+		t.Error("read left the primary while it is up")
+	}
+
+	primaryDown.Store(true)
+	policy.replicas[0].down.Store(true)
+	for range 10 {
+		if got := policy.Resolve(pools); got != second {
+			t.Fatal("read did not fail over to the replica that is up")
+		}
+	}
+
+	policy.replicas[1].down.Store(true)
+	if got := policy.Resolve(pools); got != primary {
+		t.Error("read went to a replica that is down")
+	}
+
+	primaryDown.Store(false)
+	policy.replicas[0].down.Store(false)
+	policy.always = true
+	if got := policy.Resolve(pools); got != first {
+		t.Error("DB_REPLICA_READS=always kept a read on the primary")
+	}
+}
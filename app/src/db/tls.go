@@ -0,0 +1,143 @@
+package db
+
+import (
+	"app/config"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// tlsConfigName is the name the TLS config is registered under in the driver, tls=app in a DSN
+const tlsConfigName = "app"
+
+// TLS modes of DB_TLS, named like the mysql client's --ssl-mode
+const (
+	TLSDisabled   = "disabled"
+	TLSPreferred  = "preferred"
+	TLSRequired   = "required"
+	TLSVerifyCA   = "verify-ca"
+	TLSVerifyFull = "verify-full"
+)
+
+// TLSSettings are the DB_TLS* variables, kept for the tools that connect outside the driver
+type TLSSettings struct {
+	Mode       string
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+var tlsSettings TLSSettings
+
+// applyTLSFromEnv registers the TLS config for DB_TLS and points cfg at it. DB_TLS_CA_FILE is a
+// mounted CA bundle, e.g. the RDS or Cloud SQL server CA, DB_TLS_CERT_FILE and DB_TLS_KEY_FILE a client
+// certificate. verify-ca checks the chain only, for servers reached through an address their certificate
+// does not name. Leaving DB_TLS unset keeps the tls parameter of DATABASE_URI.
+func applyTLSFromEnv(cfg *mysqldriver.Config) error {
+	settings := TLSSettings{
+		Mode:       config.String("DB_TLS", ""),
+		CAFile:     config.String("DB_TLS_CA_FILE", ""),
+		CertFile:   config.String("DB_TLS_CERT_FILE", ""),
+		KeyFile:    config.String("DB_TLS_KEY_FILE", ""),
+		ServerName: config.String("DB_TLS_SERVER_NAME", ""),
+	}
+	tlsSettings = settings
+
+	switch settings.Mode {
+	case "":
+		return nil
+	case TLSDisabled:
+		cfg.TLSConfig, cfg.TLS = "false", nil
+		return nil
+	case TLSPreferred:
+		// The driver's own mode: TLS without verification when the server offers it, plaintext otherwise
+		cfg.TLSConfig, cfg.TLS = "preferred", nil
+		return nil
+	case TLSRequired, TLSVerifyCA, TLSVerifyFull:
+	default:
+		return fmt.Errorf("unsupported DB_TLS %q", settings.Mode)
+	}
+
+	tlsConfig, err := settings.tlsConfig(cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if err := mysqldriver.RegisterTLSConfig(tlsConfigName, tlsConfig); err != nil {
+		return err
+	}
+	cfg.TLSConfig, cfg.TLS = tlsConfigName, nil
+	return nil
+}
+
+func (s TLSSettings) tlsConfig(addr string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: s.ServerName}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	if s.CAFile != "" {
+		pem, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read DB_TLS_CA_FILE: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("DB_TLS_CA_FILE has no PEM certificates")
+		}
+	}
+	if s.CertFile != "" || s.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the database client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch s.Mode {
+	case TLSRequired:
+		tlsConfig.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		// Verified below without the host name check crypto/tls would do
+		tlsConfig.InsecureSkipVerify = true
+		roots := tlsConfig.RootCAs
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("database server sent no certificate")
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range state.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+			return err
+		}
+	}
+	return tlsConfig, nil
+}
+
+// ClientTLSArgs returns the mysql client options for DB_TLS, for mysqldump and the like
+func ClientTLSArgs() []string {
+	s := tlsSettings
+	if s.Mode == "" {
+		return nil
+	}
+	args := []string{"--ssl-mode=" + map[string]string{
+		TLSDisabled:   "DISABLED",
+		TLSPreferred:  "PREFERRED",
+		TLSRequired:   "REQUIRED",
+		TLSVerifyCA:   "VERIFY_CA",
+		TLSVerifyFull: "VERIFY_IDENTITY",
+	}[s.Mode]}
+	if s.CAFile != "" {
+		args = append(args, "--ssl-ca="+s.CAFile)
+	}
+	if s.CertFile != "" {
+		args = append(args, "--ssl-cert="+s.CertFile, "--ssl-key="+s.KeyFile)
+	}
+	return args
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"app/config"
+	"app/httpclient"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default location of the projected ServiceAccount token used for Vault's Kubernetes auth method
+const serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// VaultSource reads credentials from a KV (v1 or v2) or database secrets engine path.
+// It authenticates with VAULT_TOKEN, VAULT_TOKEN_FILE or the Kubernetes auth method when VAULT_K8S_ROLE is set.
+type VaultSource struct {
+	Addr          string
+	SecretPath    string
+	PasswordField string
+	UsernameField string
+	Token         string
+	TokenFile     string
+	K8sRole       string
+	K8sMount      string
+	Client        *http.Client
+
+	mu          sync.Mutex
+	loginToken  string
+	loginExpiry time.Time
+}
+
+func NewVaultSourceFromEnv(addr string) *VaultSource {
+	return &VaultSource{
+		Addr:          strings.TrimRight(addr, "/"),
+		SecretPath:    config.String("VAULT_DB_SECRET_PATH", "secret/data/app/database"),
+		PasswordField: config.String("VAULT_DB_PASSWORD_FIELD", "password"),
+		UsernameField: config.String("VAULT_DB_USERNAME_FIELD", "username"),
+		Token:         config.String("VAULT_TOKEN", ""),
+		TokenFile:     config.String("VAULT_TOKEN_FILE", ""),
+		K8sRole:       config.String("VAULT_K8S_ROLE", ""),
+		K8sMount:      config.String("VAULT_K8S_MOUNT", "kubernetes"),
+		Client:        httpclient.New(10 * time.Second),
+	}
+}
+
+func (v *VaultSource) Name() string {
+	return "vault"
+}
+
+func (v *VaultSource) Credentials(ctx context.Context) (Credentials, error) {
+	token, err := v.token(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	var res struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, "/v1/"+strings.TrimLeft(v.SecretPath, "/"), token, nil, &res); err != nil {
+		return Credentials{}, err
+	}
+
+	// KV v2 nests the secret under data.data, KV v1 and the database engine do not
+	data := res.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		data = nested
+	}
+
+	password, _ := data[v.PasswordField].(string)
+	if password == "" {
+		return Credentials{}, fmt.Errorf("vault secret has no %q field", v.PasswordField)
+	}
+	username, _ := data[v.UsernameField].(string)
+	return Credentials{Username: username, Password: password}, nil
+}
+
+func (v *VaultSource) token(ctx context.Context) (string, error) {
+	if v.Token != "" {
+		return v.Token, nil
+	}
+	if v.TokenFile != "" {
+		raw, err := os.ReadFile(v.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read vault token file: %w", err)
+		}
+		return strings.TrimSpace(string(raw)), nil
+	}
+	if v.K8sRole == "" {
+		return "", errors.New("no vault authentication configured")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.loginToken != "" && time.Now().Before(v.loginExpiry) {
+		return v.loginToken, nil
+	}
+
+	jwt, err := os.ReadFile(serviceAccountTokenPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	var res struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	body := map[string]string{"role": v.K8sRole, "jwt": strings.TrimSpace(string(jwt))}
+	if err := v.do(ctx, http.MethodPost, "/v1/auth/"+v.K8sMount+"/login", "", body, &res); err != nil {
+		return "", fmt.Errorf("vault kubernetes login failed: %w", err)
+	}
+
+	// Renew a little before the lease runs out
+	v.loginToken = res.Auth.ClientToken
+	v.loginExpiry = time.Now().Add(time.Duration(res.Auth.LeaseDuration) * time.Second * 9 / 10)
+	return v.loginToken, nil
+}
+
+func (v *VaultSource) do(ctx context.Context, method, path, token string, body any, out any) error {
+	var reader *bytes.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, v.Addr+path, reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := v.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault returned status %d for %s", res.StatusCode, path)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,351 @@
+package degrade
+
+import (
+	"app/config"
+	"app/health"
+	"app/metrics"
+	"app/scheduler"
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Tiers, from everything working to the least the pod still serves
+const (
+	TierFull = "full"
+	// TierLocalState is redis down: sessions are kept in the database and counters in the pod
+	TierLocalState = "local-state"
+	// TierReplicaReads is the primary database down with replicas up: reads fail over to them, writes are rejected
+	TierReplicaReads = "replica-reads"
+	// TierStaleReads is the database down: sample reads come from the last responses, writes are rejected
+	TierStaleReads = "stale-reads"
+)
+
+var tierLevels = map[string]float64{TierFull: 0, TierLocalState: 1, TierReplicaReads: 2, TierStaleReads: 3}
+
+var (
+	tierGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "degradation",
+		Name:      "tier",
+		Help:      "Current degradation tier: 0 full, 1 local-state (redis down), 2 replica-reads (primary database down), 3 stale-reads (database down).",
+	})
+	cachedReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "degradation",
+		Name:      "cached_reads_total",
+		Help:      "Reads while the database is down by result: hit served from the cache, miss passed to the handler.",
+	}, []string{"result"})
+	staleFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "degradation",
+		Name:      "stale_fallbacks_total",
+		Help:      "Responses of STALE_FALLBACK_ROUTES answered from the cache instead of a 5xx, by route.",
+	}, []string{"route"})
+)
+
+var current atomic.Value
+
+// now is time.Now, replaced by tests
+var now = time.Now
+
+func init() {
+	current.Store(TierFull)
+}
+
+// Current returns the tier derived from the last background health checks
+func Current() string {
+	return current.Load().(string)
+}
+
+// Start re-derives the tier from the cached health results on interval, HEALTH_CHECK_INTERVAL
+func Start(ctx context.Context, interval time.Duration) {
+	scheduler.Every(ctx, "degradation-tier", interval, func(ctx context.Context) error {
+		report, ok := health.Default.Cached()
+		if !ok {
+			return nil
+		}
+		set(tierOf(report))
+		return nil
+	})
+}
+
+func tierOf(report health.Report) string {
+	failing := func(name string) bool {
+		result, ok := report.Checks[name]
+		// Checks that have not run yet do not degrade anything
+		return ok && !result.CheckedAt.IsZero() && result.Status != health.StatusUp
+	}
+	_, replicas := report.Checks["database-replicas"]
+	switch {
+	case failing("database") && replicas && !failing("database-replicas"):
+		return TierReplicaReads
+	case failing("database"):
+		return TierStaleReads
+	case failing("redis"):
+		return TierLocalState
+	}
+	return TierFull
+}
+
+func set(tier string) {
+	if previous := current.Swap(tier); previous != tier {
+		slog.Warn("degradation tier changed", "from", previous, "to", tier)
+	}
+	tierGauge.Set(tierLevels[tier])
+}
+
+type Config struct {
+	// Reads keeps the last sample reads and answers them from memory while the database is down,
+	// so the pod stays in the Service. db.Init gives the database check a fallback for it.
+	Reads bool
+	// StaleRoutes are the GET routes answered from their last successful response, at most as
+	// old as the route's duration, when their handler fails with a 5xx. Responses are shared by every
+	// caller of the URL, see cacheKey, so routes answering per caller such as /whoami or /quota must
+	// never be listed.
+	StaleRoutes map[string]time.Duration
+	// MaxEntries is how many responses are kept, the least recently used are evicted
+	MaxEntries int
+	// RetryAfter is sent with the writes rejected while the database is down
+	RetryAfter string
+}
+
+// ConfigFromEnv reads STALE_FALLBACK_ROUTES as route=max-age pairs, e.g. /samples=10m,/sample/:id=1m,
+// with the routes as registered. Only list routes whose response is the same for every caller.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Reads:       config.Bool("DB_DEGRADED_READS", false),
+		StaleRoutes: map[string]time.Duration{},
+		MaxEntries:  config.Int("DEGRADED_CACHE_ENTRIES", 512),
+		RetryAfter:  config.String("DEGRADED_RETRY_AFTER", "30"),
+	}
+	for _, item := range config.List("STALE_FALLBACK_ROUTES") {
+		route, value, ok := strings.Cut(item, "=")
+		maxAge, err := time.ParseDuration(value)
+		if !ok || err != nil || maxAge <= 0 {
+			return Config{}, fmt.Errorf("invalid STALE_FALLBACK_ROUTES entry %q, expected route=duration", item)
+		}
+		cfg.StaleRoutes[route] = maxAge
+	}
+	return cfg, nil
+}
+
+// readRoutes are the routes whose responses are kept for the stale-reads tier
+var readRoutes = map[string]bool{"/sample": true, "/sample/:id": true, "/samples": true}
+
+// maxBody is the largest response kept, bigger ones are never served stale
+const maxBody = 1 << 20
+
+// Middleware adds X-Degradation-Tier to responses while a dependency is down. With cfg.Reads it keeps
+// the successful sample reads and, while the database is down, answers them from memory and rejects
+// writes with 503, which they also are while reads fail over to the replicas. The admin API keeps
+// working in every tier. Routes in cfg.StaleRoutes answer from their last response instead of a 5xx
+// of their handler. Responses from memory carry Age, X-Data-Stale and a Warning header.
+func Middleware(cfg Config) echo.MiddlewareFunc {
+	cache := &responseCache{maxEntries: cfg.MaxEntries, entries: map[string]*list.Element{}, lru: list.New()}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			tier := Current()
+			res := ctx.Response()
+			if tier != TierFull {
+				res.Header().Set("X-Degradation-Tier", tier)
+			}
+
+			req := ctx.Request()
+			route := ctx.Path()
+			staleMaxAge, staleFallback := cfg.StaleRoutes[route]
+			cacheable := req.Method == http.MethodGet && (cfg.Reads && readRoutes[route] || staleFallback)
+			if tier == TierReplicaReads && mutating(req.Method) && !strings.HasPrefix(req.URL.Path, "/admin/") {
+				res.Header().Set("Retry-After", cfg.RetryAfter)
+				return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": "the primary database is unavailable, writes are paused"})
+			}
+			if cfg.Reads && tier == TierStaleReads {
+				if mutating(req.Method) && !strings.HasPrefix(req.URL.Path, "/admin/") {
+					res.Header().Set("Retry-After", cfg.RetryAfter)
+					return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": "the database is unavailable, writes are paused"})
+				}
+				if cacheable && readRoutes[route] {
+					if entry := cache.get(cacheKey(req)); entry != nil {
+						cachedReads.WithLabelValues("hit").Inc()
+						return entry.write(ctx)
+					}
+					cachedReads.WithLabelValues("miss").Inc()
+				}
+			}
+			if !cacheable {
+				return next(ctx)
+			}
+
+			before := res.Header().Clone()
+			capture := &captureWriter{ResponseWriter: res.Writer, hold: staleFallback}
+			res.Writer = capture
+			err := next(ctx)
+			res.Writer = capture.ResponseWriter
+
+			status := metrics.ResponseStatus(ctx, err)
+			if staleFallback && (capture.held || !res.Committed && status >= http.StatusInternalServerError) {
+				if entry := cache.get(cacheKey(req)); entry != nil && now().Sub(entry.storedAt) <= staleMaxAge {
+					staleFallbacks.WithLabelValues(route).Inc()
+					slog.Warn("serving a stale response", "route", route, "age", now().Sub(entry.storedAt).Round(time.Second), "status", status, "error", err)
+					// The handler's error response is dropped, only the headers set before it are kept
+					header := res.Header()
+					clear(header)
+					for name, values := range before {
+						header[name] = values
+					}
+					res.Committed, res.Size = false, 0
+					return entry.write(ctx)
+				}
+				if capture.held {
+					return capture.release()
+				}
+				return err
+			}
+			if err == nil && res.Status == http.StatusOK && !capture.overflow {
+				header := res.Header().Clone()
+				header.Del("X-Degradation-Tier")
+				cache.put(&cachedResponse{
+					key:      cacheKey(req),
+					header:   header,
+					body:     capture.body.Bytes(),
+					storedAt: now(),
+				})
+			}
+			return err
+		}
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// cacheKey separates the representations a read may be negotiated into. The caller is not part of it,
+// a response is served to anyone the middleware before this one let in.
+func cacheKey(req *http.Request) string {
+	return req.URL.RequestURI() + "\n" + req.Header.Get("Accept") + "\n" + req.Header.Get("Accept-Language")
+}
+
+type cachedResponse struct {
+	key      string
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+}
+
+func (e *cachedResponse) write(ctx echo.Context) error {
+	// Headers this request's middleware already set, such as its request ID, are not replaced
+	header := ctx.Response().Header()
+	for name, values := range e.header {
+		if _, ok := header[name]; !ok {
+			header[name] = values
+		}
+	}
+	header.Set("Age", strconv.Itoa(int(now().Sub(e.storedAt).Seconds())))
+	header.Set("Warning", `110 - "Response is Stale"`)
+	header.Set("X-Data-Stale", "true")
+	return ctx.Blob(http.StatusOK, e.header.Get(echo.HeaderContentType), e.body)
+}
+
+type responseCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func (c *responseCache) get(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return element.Value.(*cachedResponse)
+}
+
+func (c *responseCache) put(entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+	} else {
+		c.entries[entry.key] = c.lru.PushFront(entry)
+	}
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// captureWriter copies the response as it is written, up to maxBody. With hold a 5xx response is
+// kept back, so a stale one can be sent in its place.
+type captureWriter struct {
+	http.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+
+	hold       bool
+	held       bool
+	heldStatus int
+	heldBody   bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	if w.hold && code >= http.StatusInternalServerError {
+		w.held, w.heldStatus = true, code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	if w.held {
+		return w.heldBody.Write(p)
+	}
+	if !w.overflow {
+		if w.body.Len()+len(p) > maxBody {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *captureWriter) Flush() {
+	if w.held {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// release sends the held back response when there was nothing to replace it with
+func (w *captureWriter) release() error {
+	w.ResponseWriter.WriteHeader(w.heldStatus)
+	_, err := w.ResponseWriter.Write(w.heldBody.Bytes())
+	return err
+}
@@ -0,0 +1,210 @@
+package degrade
+
+import (
+	"app/health"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestTierOf(t *testing.T) {
+	checked := time.Now()
+	up := health.Result{Status: health.StatusUp, CheckedAt: checked}
+	down := health.Result{Status: health.StatusDown, CheckedAt: checked}
+	tests := []struct {
+		name   string
+		checks map[string]health.Result
+		want   string
+	}{
+		{"everything up", map[string]health.Result{"database": up, "redis": up}, TierFull},
+		{"redis down", map[string]health.Result{"database": up, "redis": down}, TierLocalState},
+		{"database down", map[string]health.Result{"database": down, "redis": down}, TierStaleReads},
+		{"primary down with replicas up", map[string]health.Result{"database": down, "database-replicas": up}, TierReplicaReads},
+		{"primary and replicas down", map[string]health.Result{"database": down, "database-replicas": down}, TierStaleReads},
+		{"checks not run yet", map[string]health.Result{"database": {Status: health.StatusDown}}, TierFull},
+	}
+	for _, tt := range tests {
+		if got := tierOf(health.Report{Checks: tt.checks}); got != tt.want {
+			t.Errorf("%s: tier = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// inTier switches the tier for the test
+func inTier(t *testing.T, tier string) {
+	set(tier)
+	t.Cleanup(func() { set(TierFull) })
+}
+
+// fakeClock replaces now for the test
+func fakeClock(t *testing.T) func(time.Duration) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+	return func(d time.Duration) { clock = clock.Add(d) }
+}
+
+// server answers the sample routes with the body and status of its fields, counting the calls
+type server struct {
+	*echo.Echo
+	calls  int
+	status int
+	body   string
+}
+
+func newServer(cfg Config) *server {
+	s := &server{Echo: echo.New(), status: http.StatusOK, body: `{"id":"1"}`}
+	s.Use(Middleware(cfg))
+	handler := func(ctx echo.Context) error {
+		s.calls++
+		if s.status >= http.StatusInternalServerError && s.body == "" {
+			return echo.NewHTTPError(s.status, "handler failed")
+		}
+		return ctx.JSONBlob(s.status, []byte(s.body))
+	}
+	s.GET("/sample/:id", handler)
+	s.GET("/samples", handler)
+	s.POST("/sample", handler)
+	s.POST("/admin/readonly", handler)
+	return s
+}
+
+func (s *server) do(method, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWritesAreRejectedWithoutThePrimary(t *testing.T) {
+	s := newServer(Config{Reads: true, RetryAfter: "30"})
+	if rec := s.do(http.MethodPost, "/sample"); rec.Code != http.StatusOK || rec.Header().Get("X-Degradation-Tier") != "" {
+		t.Errorf("full tier: status %d with tier %q, want the write served without a tier header", rec.Code, rec.Header().Get("X-Degradation-Tier"))
+	}
+
+	for _, tier := range []string{TierReplicaReads, TierStaleReads} {
+		inTier(t, tier)
+		rec := s.do(http.MethodPost, "/sample")
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "30" || rec.Header().Get("X-Degradation-Tier") != tier {
+			t.Errorf("%s: status %d with Retry-After %q and tier %q, want 503", tier, rec.Code, rec.Header().Get("Retry-After"), rec.Header().Get("X-Degradation-Tier"))
+		}
+		if rec := s.do(http.MethodPost, "/admin/readonly"); rec.Code != http.StatusOK {
+			t.Errorf("%s: admin write status %d, want the admin API working", tier, rec.Code)
+		}
+	}
+
+	inTier(t, TierLocalState)
+	if rec := s.do(http.MethodPost, "/sample"); rec.Code != http.StatusOK {
+		t.Errorf("local-state tier: write status %d, want it served with the database up", rec.Code)
+	}
+}
+
+func TestReadsAreServedFromMemoryWithoutTheDatabase(t *testing.T) {
+	s := newServer(Config{Reads: true, MaxEntries: 10})
+	s.do(http.MethodGet, "/sample/1")
+	s.do(http.MethodGet, "/sample/1", echo.HeaderAccept, "application/vnd.api+json")
+
+	inTier(t, TierStaleReads)
+	calls := s.calls
+	rec := s.do(http.MethodGet, "/sample/1")
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"id":"1"}` || s.calls != calls {
+		t.Fatalf("cached read: status %d body %q after %d handler calls, want the kept response", rec.Code, rec.Body, s.calls-calls)
+	}
+	if rec.Header().Get("X-Data-Stale") != "true" || rec.Header().Get("Warning") == "" || rec.Header().Get("X-Degradation-Tier") != TierStaleReads {
+		t.Errorf("cached read headers = %v, want X-Data-Stale, Warning and the tier", rec.Header())
+	}
+
+	// Another sample, or another representation of it, is a miss the handler answers
+	s.status, s.body = http.StatusServiceUnavailable, ""
+	if rec := s.do(http.MethodGet, "/sample/2"); rec.Code != http.StatusServiceUnavailable || s.calls != calls+1 {
+		t.Errorf("uncached read: status %d, want the handler's 503", rec.Code)
+	}
+	if rec := s.do(http.MethodGet, "/sample/1", echo.HeaderAccept, "text/csv"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("read with another Accept: status %d, want the handler's 503", rec.Code)
+	}
+	if rec := s.do(http.MethodGet, "/sample/1", echo.HeaderAccept, "application/vnd.api+json"); rec.Code != http.StatusOK {
+		t.Errorf("read with the Accept kept: status %d, want the kept response", rec.Code)
+	}
+}
+
+func TestReadsAreNotKeptWithoutReads(t *testing.T) {
+	s := newServer(Config{MaxEntries: 10})
+	s.do(http.MethodGet, "/sample/1")
+	inTier(t, TierStaleReads)
+	calls := s.calls
+	if s.do(http.MethodGet, "/sample/1"); s.calls != calls+1 {
+		t.Error("a read was answered from memory with DB_DEGRADED_READS off")
+	}
+}
+
+func TestStaleFallback(t *testing.T) {
+	advance := fakeClock(t)
+	s := newServer(Config{StaleRoutes: map[string]time.Duration{"/samples": time.Minute}, MaxEntries: 10})
+	s.do(http.MethodGet, "/samples")
+
+	// A 5xx written by the handler is held back and replaced
+	advance(30 * time.Second)
+	s.status, s.body = http.StatusInternalServerError, `{"error":"boom"}`
+	rec := s.do(http.MethodGet, "/samples")
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"id":"1"}` || rec.Header().Get("Age") != "30" {
+		t.Errorf("failing within max age: %d %q with Age %q, want the 30s old response", rec.Code, rec.Body, rec.Header().Get("Age"))
+	}
+	// So is a 5xx error returned to echo's error handler
+	s.body = ""
+	if rec := s.do(http.MethodGet, "/samples"); rec.Code != http.StatusOK {
+		t.Errorf("handler error within max age: status %d, want the stale response", rec.Code)
+	}
+
+	advance(31 * time.Second)
+	s.body = `{"error":"boom"}`
+	rec = s.do(http.MethodGet, "/samples")
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != `{"error":"boom"}` {
+		t.Errorf("failing past max age: %d %q, want the handler's held back 500", rec.Code, rec.Body)
+	}
+
+	// Client errors are the handler's answer, not a failure to hide
+	s.status = http.StatusNotFound
+	if rec := s.do(http.MethodGet, "/samples"); rec.Code != http.StatusNotFound {
+		t.Errorf("404: status %d, want it passed through", rec.Code)
+	}
+	if rec := s.do(http.MethodGet, "/sample/1"); rec.Code != http.StatusNotFound {
+		t.Errorf("route without a stale fallback: status %d, want the handler's answer", rec.Code)
+	}
+}
+
+func TestOversizedResponsesAreNotKept(t *testing.T) {
+	s := newServer(Config{StaleRoutes: map[string]time.Duration{"/samples": time.Minute}, MaxEntries: 10})
+	s.body = `"` + strings.Repeat("x", maxBody) + `"`
+	if rec := s.do(http.MethodGet, "/samples"); rec.Body.Len() != len(s.body) {
+		t.Fatalf("oversized response: %d bytes written, want all %d passed through", rec.Body.Len(), len(s.body))
+	}
+	s.status, s.body = http.StatusInternalServerError, `{"error":"boom"}`
+	if rec := s.do(http.MethodGet, "/samples"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("after an oversized response: status %d, want the 500 as nothing was kept", rec.Code)
+	}
+}
+
+func TestCacheEvictsTheLeastRecentlyUsed(t *testing.T) {
+	s := newServer(Config{Reads: true, MaxEntries: 2})
+	s.do(http.MethodGet, "/sample/1")
+	s.do(http.MethodGet, "/sample/2")
+	inTier(t, TierStaleReads)
+	s.do(http.MethodGet, "/sample/1")
+	set(TierFull)
+	s.do(http.MethodGet, "/sample/3")
+
+	set(TierStaleReads)
+	s.status, s.body = http.StatusServiceUnavailable, ""
+	for path, want := range map[string]int{"/sample/1": http.StatusOK, "/sample/2": http.StatusServiceUnavailable, "/sample/3": http.StatusOK} {
+		if rec := s.do(http.MethodGet, path); rec.Code != want {
+			t.Errorf("%s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
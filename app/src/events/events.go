@@ -0,0 +1,79 @@
+package events
+
+import (
+	"app/tracing"
+	"context"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	SampleCreated = "sample.created"
+	SampleUpdated = "sample.updated"
+	SampleDeleted = "sample.deleted"
+)
+
+type Event struct {
+	Type    string
+	Payload any
+	// Headers carry the W3C trace context like message headers on a broker would
+	Headers map[string]string
+}
+
+// Handler receives a context holding the publisher's trace, so work it does joins the same trace
+type Handler func(ctx context.Context, event Event)
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string][]Handler{}
+)
+
+func Subscribe(eventType string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[eventType] = append(handlers[eventType], handler)
+}
+
+// Publish delivers the event to every subscriber asynchronously
+func Publish(ctx context.Context, event Event) {
+	ctx, span := tracing.Tracer().Start(ctx, "publish "+event.Type,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", event.Type)),
+	)
+	defer span.End()
+
+	if event.Headers == nil {
+		event.Headers = map[string]string{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(event.Headers))
+
+	mu.RLock()
+	subscribers := handlers[event.Type]
+	mu.RUnlock()
+
+	for _, handler := range subscribers {
+		go deliver(handler, event)
+	}
+}
+
+func deliver(handler Handler, event Event) {
+	// The publisher's request context is cancelled once it responds, only its trace is carried over
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(event.Headers))
+	ctx, span := tracing.Tracer().Start(ctx, "process "+event.Type,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", event.Type)),
+	)
+	defer span.End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("event handler panicked", "event", event.Type, "panic", r)
+		}
+	}()
+	handler(ctx, event)
+}
@@ -0,0 +1,232 @@
+package experiments
+
+import (
+	"app/apikey"
+	"app/config"
+	"app/jwtauth"
+	"app/metrics"
+	"app/session"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Header lists the variants a response was served with, e.g. X-Experiments: checkout=new, search=control
+const Header = "X-Experiments"
+
+const contextKey = "experiments.assignments"
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "experiment",
+		Name:      "requests_total",
+		Help:      "HTTP requests of callers assigned to an experiment by variant and status code.",
+	}, []string{"experiment", "variant", "code"})
+
+	duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "experiment",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests of callers assigned to an experiment by variant.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"experiment", "variant"})
+)
+
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// Experiment splits the callers between its variants by weight. The first variant is the control,
+// which callers that cannot be identified get. Paths limit the requests it applies to, all by default.
+type Experiment struct {
+	Name     string    `json:"name"`
+	Variants []Variant `json:"variants"`
+	Paths    []string  `json:"paths,omitempty"`
+	total    int
+}
+
+type Config struct {
+	Experiments []Experiment
+}
+
+// ConfigFromEnv reads the experiments named by EXPERIMENTS, each from EXPERIMENT_<NAME>_VARIANTS
+// written as name=weight and the optional EXPERIMENT_<NAME>_PATHS prefixes.
+// e.g. EXPERIMENTS=checkout EXPERIMENT_CHECKOUT_VARIANTS=control=50,new=50
+func ConfigFromEnv() (Config, error) {
+	var cfg Config
+	for _, name := range config.List("EXPERIMENTS") {
+		prefix := "EXPERIMENT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		experiment := Experiment{Name: name, Paths: config.List(prefix + "PATHS")}
+		for _, entry := range config.List(prefix + "VARIANTS") {
+			variant, raw, found := strings.Cut(entry, "=")
+			weight, err := strconv.Atoi(strings.TrimSpace(raw))
+			variant = strings.TrimSpace(variant)
+			if !found || err != nil || weight < 0 || variant == "" {
+				return Config{}, fmt.Errorf("%sVARIANTS: invalid entry %q, want \"variant=weight\"", prefix, entry)
+			}
+			experiment.Variants = append(experiment.Variants, Variant{Name: variant, Weight: weight})
+			experiment.total += weight
+		}
+		if len(experiment.Variants) < 2 || experiment.total == 0 {
+			return Config{}, fmt.Errorf("%sVARIANTS: experiment %q needs two variants or more and a positive total weight", prefix, name)
+		}
+		cfg.Experiments = append(cfg.Experiments, experiment)
+	}
+	return cfg, nil
+}
+
+// applies reports whether the experiment covers path
+func (e *Experiment) applies(path string) bool {
+	return len(e.Paths) == 0 || slices.ContainsFunc(e.Paths, func(prefix string) bool {
+		return strings.HasPrefix(path, prefix)
+	})
+}
+
+// Assign buckets unit into a variant by the hash of the experiment and unit, so a caller keeps its
+// variant across requests and replicas, and the buckets of two experiments are independent
+func (e *Experiment) Assign(unit string) string {
+	h := fnv.New64a()
+	h.Write([]byte(e.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(unit))
+	bucket := int(h.Sum64() % uint64(e.total))
+	for _, variant := range e.Variants {
+		if bucket < variant.Weight {
+			return variant.Name
+		}
+		bucket -= variant.Weight
+	}
+	return e.Variants[0].Name
+}
+
+// unit identifies the caller the variants are assigned to: the user of the session or bearer token,
+// or else the API key. Anonymous callers have none.
+func unit(ctx echo.Context) string {
+	if current := session.Get(ctx); current != nil {
+		return "user:" + current.UserID
+	}
+	if claims := jwtauth.ClaimsFrom(ctx); claims != nil && claims.Subject != "" {
+		return "user:" + claims.Subject
+	}
+	if key := apikey.Get(ctx); key != nil {
+		return "key:" + key.ID
+	}
+	return ""
+}
+
+// Assignment is the variant of one experiment for the request, Assigned false for an anonymous caller
+// who got the control.
+type Assignment struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+	Assigned   bool   `json:"assigned"`
+}
+
+type assignments struct {
+	experiments []Experiment
+	byName      map[string]Assignment
+}
+
+// Middleware makes the experiments covering the request path available to Get. Variants are assigned
+// on first use, once the route's authentication identified the caller, and listed in X-Experiments.
+// The requests of assigned callers are counted per variant.
+func Middleware(cfg Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			state := &assignments{byName: map[string]Assignment{}}
+			for _, experiment := range cfg.Experiments {
+				if experiment.applies(ctx.Request().URL.Path) {
+					state.experiments = append(state.experiments, experiment)
+				}
+			}
+			if len(state.experiments) == 0 {
+				return next(ctx)
+			}
+			ctx.Set(contextKey, state)
+			res := ctx.Response()
+			res.Before(func() {
+				var header []string
+				for _, assignment := range All(ctx) {
+					if assignment.Assigned {
+						header = append(header, assignment.Experiment+"="+assignment.Variant)
+					}
+				}
+				if len(header) > 0 {
+					res.Header().Set(Header, strings.Join(header, ", "))
+				}
+			})
+
+			start := time.Now()
+			err := next(ctx)
+			status := metrics.ResponseStatus(ctx, err)
+			for _, assignment := range All(ctx) {
+				if assignment.Assigned {
+					requests.WithLabelValues(assignment.Experiment, assignment.Variant, strconv.Itoa(status)).Inc()
+					duration.WithLabelValues(assignment.Experiment, assignment.Variant).Observe(time.Since(start).Seconds())
+				}
+			}
+			return err
+		}
+	}
+}
+
+// Get returns the variant of the named experiment for the request: the assigned one, the control
+// for anonymous callers, or "" when the experiment does not cover the request.
+func Get(ctx echo.Context, name string) string {
+	state, _ := ctx.Get(contextKey).(*assignments)
+	if state == nil {
+		return ""
+	}
+	return state.get(ctx, name).Variant
+}
+
+// All returns the assignments of every experiment covering the request
+func All(ctx echo.Context) []Assignment {
+	state, _ := ctx.Get(contextKey).(*assignments)
+	if state == nil {
+		return nil
+	}
+	all := make([]Assignment, 0, len(state.experiments))
+	for _, experiment := range state.experiments {
+		all = append(all, state.get(ctx, experiment.Name))
+	}
+	return all
+}
+
+func (s *assignments) get(ctx echo.Context, name string) Assignment {
+	if assignment, ok := s.byName[name]; ok {
+		return assignment
+	}
+	i := slices.IndexFunc(s.experiments, func(e Experiment) bool { return e.Name == name })
+	if i < 0 {
+		return Assignment{}
+	}
+	experiment := &s.experiments[i]
+	assignment := Assignment{Experiment: name, Variant: experiment.Variants[0].Name}
+	if caller := unit(ctx); caller != "" {
+		assignment.Variant, assignment.Assigned = experiment.Assign(caller), true
+		// Only an identified caller's variant is final, one who logs in later in the request gets theirs
+		s.byName[name] = assignment
+	}
+	return assignment
+}
+
+// Handler serves GET /experiments, the caller's variants for a frontend to render them
+func Handler(ctx echo.Context) error {
+	assignments := All(ctx)
+	if assignments == nil {
+		assignments = []Assignment{}
+	}
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "private, no-store")
+	return ctx.JSON(http.StatusOK, assignments)
+}
@@ -0,0 +1,71 @@
+package experiments
+
+import (
+	"app/model"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("EXPERIMENTS", "new-checkout")
+	t.Setenv("EXPERIMENT_NEW_CHECKOUT_VARIANTS", "control=90, new=10")
+	t.Setenv("EXPERIMENT_NEW_CHECKOUT_PATHS", "/sample")
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Experiments) != 1 || len(cfg.Experiments[0].Variants) != 2 || cfg.Experiments[0].total != 100 {
+		t.Fatalf("parsed %+v", cfg.Experiments)
+	}
+
+	t.Setenv("EXPERIMENT_NEW_CHECKOUT_VARIANTS", "control")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("a variant without a weight was accepted")
+	}
+}
+
+func TestAssignIsStableAndWeighted(t *testing.T) {
+	experiment := Experiment{Name: "checkout", Variants: []Variant{{"control", 75}, {"new", 25}}, total: 100}
+	counts := map[string]int{}
+	for i := range 10000 {
+		unit := fmt.Sprintf("user:%d", i)
+		variant := experiment.Assign(unit)
+		if experiment.Assign(unit) != variant {
+			t.Fatalf("%s changed variant", unit)
+		}
+		counts[variant]++
+	}
+	if counts["new"] < 2200 || counts["new"] > 2800 {
+		t.Errorf("new got %d of 10000 callers, want about 2500", counts["new"])
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	cfg := Config{Experiments: []Experiment{
+		{Name: "checkout", Variants: []Variant{{"control", 0}, {"new", 1}}, total: 1},
+		{Name: "admin", Variants: []Variant{{"a", 1}, {"b", 1}}, Paths: []string{"/admin"}, total: 2},
+	}}
+	e := echo.New()
+	e.GET("/sample", func(ctx echo.Context) error {
+		if ctx.QueryParam("key") != "" {
+			ctx.Set("api_key", &model.APIKey{ID: ctx.QueryParam("key")})
+		}
+		return ctx.String(http.StatusOK, Get(ctx, "checkout")+" "+Get(ctx, "admin"))
+	}, Middleware(cfg))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sample?key=k1", nil))
+	if rec.Body.String() != "new " || rec.Header().Get(Header) != "checkout=new" {
+		t.Errorf("identified caller got %q with %s: %q", rec.Body.String(), Header, rec.Header().Get(Header))
+	}
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sample", nil))
+	if rec.Body.String() != "control " || rec.Header().Get(Header) != "" {
+		t.Errorf("anonymous caller got %q with %s: %q", rec.Body.String(), Header, rec.Header().Get(Header))
+	}
+}
@@ -0,0 +1,155 @@
+package fieldcrypt
+
+import (
+	"app/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Ciphertexts are stored as enc:<key id>:<base64(nonce|ciphertext)> so rows encrypted
+// with a retired key can still be read after the active key is rotated.
+const prefix = "enc:"
+
+var (
+	ErrNoKey         = errors.New("no encryption key configured")
+	ErrUnknownKey    = errors.New("ciphertext was encrypted with an unknown key")
+	ErrMalformedData = errors.New("malformed ciphertext")
+)
+
+type KeyRing struct {
+	activeID string
+	aeads    map[string]cipher.AEAD
+}
+
+var (
+	mu      sync.RWMutex
+	keyRing = &KeyRing{aeads: map[string]cipher.AEAD{}}
+)
+
+// ParseKeyRing parses "id:base64key,id:base64key", the first entry is used for new writes
+func ParseKeyRing(spec string) (*KeyRing, error) {
+	ring := &KeyRing{aeads: map[string]cipher.AEAD{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key entry %q, expected id:base64key", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes for AES-256", id)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if ring.activeID == "" {
+			ring.activeID = id
+		}
+		ring.aeads[id] = aead
+	}
+	return ring, nil
+}
+
+// Init loads the key ring from ENCRYPTION_KEYS or the file named by ENCRYPTION_KEYS_FILE (a mounted Secret)
+func Init() error {
+	spec := config.String("ENCRYPTION_KEYS", "")
+	if path := config.String("ENCRYPTION_KEYS_FILE", ""); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read ENCRYPTION_KEYS_FILE: %w", err)
+		}
+		spec = strings.ReplaceAll(strings.TrimSpace(string(raw)), "\n", ",")
+	}
+
+	ring, err := ParseKeyRing(spec)
+	if err != nil {
+		return err
+	}
+	if ring.activeID == "" {
+		slog.Warn("ENCRYPTION_KEYS is not set, encrypted fields cannot be written")
+	} else {
+		slog.Info("field encryption is enabled", "active_key", ring.activeID, "keys", len(ring.aeads))
+	}
+
+	mu.Lock()
+	keyRing = ring
+	mu.Unlock()
+	return nil
+}
+
+func current() *KeyRing {
+	mu.RLock()
+	defer mu.RUnlock()
+	return keyRing
+}
+
+func Encrypt(plaintext string) (string, error) {
+	ring := current()
+	if ring.activeID == "" {
+		return "", ErrNoKey
+	}
+
+	aead := ring.aeads[ring.activeID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(ring.activeID))
+	return prefix + ring.activeID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns values without the enc: prefix unchanged so existing plaintext rows stay readable
+func Decrypt(value string) (string, error) {
+	rest, found := strings.CutPrefix(value, prefix)
+	if !found {
+		return value, nil
+	}
+
+	id, encoded, found := strings.Cut(rest, ":")
+	if !found {
+		return "", ErrMalformedData
+	}
+	aead, ok := current().aeads[id]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformedData
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", ErrMalformedData
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether the stored value is plaintext or encrypted with a non-active key
+func NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, prefix+current().activeID+":")
+}
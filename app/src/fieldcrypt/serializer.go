@@ -0,0 +1,49 @@
+package fieldcrypt
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// Serializer encrypts string fields tagged with `gorm:"serializer:encrypted"`
+type Serializer struct{}
+
+// Registered at package load so model schemas parse even before the keys are loaded
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+		return nil
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("unsupported encrypted column value %T", dbValue)
+	}
+
+	plaintext, err := Decrypt(stored)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", field.Name, err)
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue any) (any, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted serializer only supports string fields, got %T", fieldValue)
+	}
+	if plaintext == "" {
+		return "", nil
+	}
+	return Encrypt(plaintext)
+}
@@ -0,0 +1,247 @@
+// Package filter parses search expressions such as
+//
+//	message ~ "hello" AND (created_at >= 2024-01-01 OR key = null)
+//
+// into parameterized SQL conditions. Only fields of a Schema can be named and they are
+// replaced by the schema's columns, every value is bound as a parameter, so no part of
+// the input ever reaches the SQL text.
+package filter
+
+import (
+	"app/apperrors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidFilter = apperrors.New(apperrors.Validation, "invalid filter")
+
+// Limits keeping the generated query small however the expression is written
+const (
+	MaxLength      = 1024
+	MaxComparisons = 32
+	MaxDepth       = 8
+)
+
+type Type int
+
+const (
+	String Type = iota
+	// Time values are RFC 3339 timestamps or dates like 2024-01-31, which are midnight UTC
+	Time
+)
+
+// Field is a column a filter may compare, Column is trusted and written into the SQL as is
+type Field struct {
+	Column string
+	Type   Type
+}
+
+// Schema maps the field names of the expression to their columns
+type Schema map[string]Field
+
+// Filter is a parsed expression. The zero value matches every row.
+type Filter struct {
+	sql  string
+	vars []any
+}
+
+func (f Filter) Empty() bool {
+	return f.sql == ""
+}
+
+// SQL returns the condition and its parameters
+func (f Filter) SQL() (string, []any) {
+	return f.sql, f.vars
+}
+
+// Scope adds the condition to a query, for gorm's Scopes
+func (f Filter) Scope(query *gorm.DB) *gorm.DB {
+	if f.Empty() {
+		return query
+	}
+	return query.Where(f.sql, f.vars...)
+}
+
+// Parse parses input against schema. The grammar is
+//
+//	expr       = and { "OR" and }
+//	and        = unary { "AND" unary }
+//	unary      = "NOT" unary | "(" expr ")" | comparison
+//	comparison = field op value
+//	op         = "=" | "!=" | "<" | "<=" | ">" | ">=" | "~"
+//
+// Keywords are case insensitive. A value is a quoted string or a bare word, null compares with
+// = and != only and ~ matches strings containing the value. An empty input is the zero Filter.
+func Parse(input string, schema Schema) (Filter, error) {
+	if strings.TrimSpace(input) == "" {
+		return Filter{}, nil
+	}
+	if len(input) > MaxLength {
+		return Filter{}, fmt.Errorf("%w: longer than %d characters", ErrInvalidFilter, MaxLength)
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return Filter{}, err
+	}
+	p := &parser{tokens: tokens, schema: schema}
+	sql, err := p.expr(0)
+	if err != nil {
+		return Filter{}, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return Filter{}, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return Filter{sql: sql, vars: p.vars}, nil
+}
+
+type parser struct {
+	tokens      []token
+	pos         int
+	schema      Schema
+	vars        []any
+	comparisons int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrInvalidFilter, fmt.Sprintf(format, args...), tok.offset)
+}
+
+func (p *parser) keyword(word string) bool {
+	if tok := p.peek(); tok.kind == tokenWord && strings.EqualFold(tok.text, word) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expr(depth int) (string, error) {
+	return p.join(depth, "OR", p.and)
+}
+
+func (p *parser) and(depth int) (string, error) {
+	return p.join(depth, "AND", p.unary)
+}
+
+// join parses operands separated by keyword, parenthesized so the SQL keeps the precedence of the filter
+func (p *parser) join(depth int, keyword string, operand func(depth int) (string, error)) (string, error) {
+	first, err := operand(depth)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{first}
+	for p.keyword(keyword) {
+		part, err := operand(depth)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 1 {
+		return first, nil
+	}
+	return "(" + strings.Join(parts, " "+keyword+" ") + ")", nil
+}
+
+func (p *parser) unary(depth int) (string, error) {
+	if depth > MaxDepth {
+		return "", p.errorf(p.peek(), "nested deeper than %d", MaxDepth)
+	}
+	if p.keyword("NOT") {
+		operand, err := p.unary(depth + 1)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + operand, nil
+	}
+	if tok := p.peek(); tok.kind == tokenOpen {
+		p.next()
+		inner, err := p.expr(depth + 1)
+		if err != nil {
+			return "", err
+		}
+		if tok := p.next(); tok.kind != tokenClose {
+			return "", p.errorf(tok, "expected )")
+		}
+		return "(" + inner + ")", nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (string, error) {
+	name := p.next()
+	if name.kind != tokenWord {
+		return "", p.errorf(name, "expected a field")
+	}
+	field, ok := p.schema[name.text]
+	if !ok {
+		return "", p.errorf(name, "unknown field %q", name.text)
+	}
+	op := p.next()
+	if op.kind != tokenOperator {
+		return "", p.errorf(op, "expected an operator after %s", name.text)
+	}
+	value := p.next()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return "", p.errorf(value, "expected a value after %s %s", name.text, op.text)
+	}
+	if p.comparisons++; p.comparisons > MaxComparisons {
+		return "", p.errorf(name, "more than %d comparisons", MaxComparisons)
+	}
+
+	if value.kind == tokenWord && strings.EqualFold(value.text, "null") {
+		switch op.text {
+		case "=":
+			return field.Column + " IS NULL", nil
+		case "!=":
+			return field.Column + " IS NOT NULL", nil
+		}
+		return "", p.errorf(op, "null only compares with = and !=")
+	}
+
+	if op.text == "~" {
+		if field.Type != String {
+			return "", p.errorf(op, "~ only matches strings")
+		}
+		p.vars = append(p.vars, "%"+escapeLike(value.text)+"%")
+		return field.Column + " LIKE ? ESCAPE '!'", nil
+	}
+
+	var bound any = value.text
+	if field.Type == Time {
+		at, err := parseTime(value.text)
+		if err != nil {
+			return "", p.errorf(value, "%s is not a time", value.text)
+		}
+		bound = at
+	}
+	p.vars = append(p.vars, bound)
+	return field.Column + " " + op.text + " ?", nil
+}
+
+// escapeLike makes the LIKE wildcards literal. ! is the escape character because
+// MySQL and SQLite disagree on how a backslash is written in a string literal.
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return at, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var schema = Schema{
+	"message":    {Column: "message", Type: String},
+	"key":        {Column: "natural_key", Type: String},
+	"created_at": {Column: "created_at", Type: Time},
+}
+
+func TestParse(t *testing.T) {
+	day := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input string
+		sql   string
+		vars  []any
+	}{
+		{"", "", nil},
+		{`message = hello`, "message = ?", []any{"hello"}},
+		{`key != "a b" and message ~ '50%_off!'`, "(natural_key != ? AND message LIKE ? ESCAPE '!')", []any{"a b", "%50!%!_off!!%"}},
+		{`message = a OR message = b AND key = c`, "(message = ? OR (message = ? AND natural_key = ?))", []any{"a", "b", "c"}},
+		{`NOT (message = a OR key = null) AND created_at >= 2024-01-31`, "(NOT ((message = ? OR natural_key IS NULL)) AND created_at >= ?)", []any{"a", day}},
+		{`created_at < "2024-01-31T00:00:00Z"`, "created_at < ?", []any{day}},
+		{`message = "it's \"quoted\""`, "message = ?", []any{`it's "quoted"`}},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.input, schema)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		sql, vars := f.SQL()
+		if sql != tt.sql || !reflect.DeepEqual(vars, tt.vars) {
+			t.Errorf("Parse(%q) = %q %v, want %q %v", tt.input, sql, vars, tt.sql, tt.vars)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, input := range []string{
+		`password = x`,
+		`message = x; DROP TABLE samples`,
+		`message = x) OR (1 = 1`,
+		`(message = x`,
+		`message`,
+		`message == x`,
+		`message =`,
+		`message < null`,
+		`created_at ~ 2024`,
+		`created_at > yesterday`,
+		`message = "unterminated`,
+		`message = a AND`,
+		`((((((((((message = x))))))))))`,
+	} {
+		if _, err := Parse(input, schema); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidFilter", input, err)
+		}
+	}
+}
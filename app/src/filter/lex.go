@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+const operatorChars = "=!<>~"
+
+// lex splits the input into words, quoted strings, operators and parentheses.
+// A quoted string is delimited by " or ' and escapes its quote and backslashes with a backslash.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenClose, ")", i})
+			i++
+		case c == '"' || c == '\'':
+			text, end, err := lexString(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenString, text, i})
+			i = end
+		case strings.IndexByte(operatorChars, c) >= 0:
+			op := input[i : i+1]
+			if i+1 < len(input) && input[i+1] == '=' && c != '=' && c != '~' {
+				op = input[i : i+2]
+			}
+			switch op {
+			case "=", "!=", "<", "<=", ">", ">=", "~":
+			default:
+				return nil, fmt.Errorf("%w: unknown operator %q at offset %d", ErrInvalidFilter, op, i)
+			}
+			tokens = append(tokens, token{tokenOperator, op, i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(input) && !strings.ContainsRune(" \t\n\r()\"'"+operatorChars, rune(input[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, input[start:i], start})
+		}
+	}
+	return append(tokens, token{tokenEOF, "end of filter", len(input)}), nil
+}
+
+func lexString(input string, start int) (string, int, error) {
+	quote := input[start]
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		switch c := input[i]; {
+		case c == '\\' && i+1 < len(input):
+			i++
+			b.WriteByte(input[i])
+		case c == quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("%w: unterminated string at offset %d", ErrInvalidFilter, start)
+}
@@ -0,0 +1,1276 @@
+// Package api provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.4.1 DO NOT EDIT.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/oapi-codegen/runtime"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+// Defines values for ChangeType.
+const (
+	ChangeTypeCreated ChangeType = "created"
+	ChangeTypeDeleted ChangeType = "deleted"
+	ChangeTypeUpdated ChangeType = "updated"
+)
+
+// Defines values for JSONPatchOp.
+const (
+	Add     JSONPatchOp = "add"
+	Copy    JSONPatchOp = "copy"
+	Move    JSONPatchOp = "move"
+	Remove  JSONPatchOp = "remove"
+	Replace JSONPatchOp = "replace"
+	Test    JSONPatchOp = "test"
+)
+
+// Defines values for SampleRevisionAction.
+const (
+	Create SampleRevisionAction = "create"
+	Delete SampleRevisionAction = "delete"
+	Revert SampleRevisionAction = "revert"
+	Update SampleRevisionAction = "update"
+)
+
+// Defines values for SampleRevisionChangesField.
+const (
+	SampleRevisionChangesFieldDeletedAt SampleRevisionChangesField = "deleted_at"
+	SampleRevisionChangesFieldKey       SampleRevisionChangesField = "key"
+	SampleRevisionChangesFieldLabels    SampleRevisionChangesField = "labels"
+	SampleRevisionChangesFieldMessage   SampleRevisionChangesField = "message"
+)
+
+// Defines values for GetFirstSampleParamsStream.
+const (
+	Json   GetFirstSampleParamsStream = "json"
+	Ndjson GetFirstSampleParamsStream = "ndjson"
+)
+
+// Defines values for GetSampleTimeseriesParamsEvent.
+const (
+	GetSampleTimeseriesParamsEventCreated GetSampleTimeseriesParamsEvent = "created"
+	GetSampleTimeseriesParamsEventDeleted GetSampleTimeseriesParamsEvent = "deleted"
+	GetSampleTimeseriesParamsEventUpdated GetSampleTimeseriesParamsEvent = "updated"
+)
+
+// Defines values for ListSamplesParamsCount.
+const (
+	Estimate ListSamplesParamsCount = "estimate"
+	Exact    ListSamplesParamsCount = "exact"
+	None     ListSamplesParamsCount = "none"
+)
+
+// Change defines model for Change.
+type Change struct {
+	// Sample Only the requested fields are present when fields is given
+	Sample Sample     `json:"sample"`
+	Type   ChangeType `json:"type"`
+}
+
+// ChangeType defines model for Change.Type.
+type ChangeType string
+
+// ChangeFeed defines model for ChangeFeed.
+type ChangeFeed struct {
+	Changes  *[]Change `json:"changes"`
+	Cursor   string    `json:"cursor"`
+	TimedOut bool      `json:"timed_out"`
+}
+
+// Error defines model for Error.
+type Error struct {
+	Details *string `json:"details,omitempty"`
+	Error   string  `json:"error"`
+}
+
+// HealthReport defines model for HealthReport.
+type HealthReport struct {
+	Checks map[string]struct {
+		CheckedAt *time.Time `json:"checked_at,omitempty"`
+		Critical  *bool      `json:"critical,omitempty"`
+		Error     *string    `json:"error,omitempty"`
+		LatencyMs *float32   `json:"latency_ms,omitempty"`
+		Status    *string    `json:"status,omitempty"`
+	} `json:"checks"`
+	Status string `json:"status"`
+}
+
+// JSONAPIDocument defines model for JSONAPIDocument.
+type JSONAPIDocument struct {
+	Data    JSONAPIDocument_Data    `json:"data"`
+	Jsonapi map[string]interface{}  `json:"jsonapi"`
+	Links   *map[string]string      `json:"links,omitempty"`
+	Meta    *map[string]interface{} `json:"meta,omitempty"`
+}
+
+// JSONAPIDocumentData1 defines model for .
+type JSONAPIDocumentData1 = []JSONAPIResource
+
+// JSONAPIDocument_Data defines model for JSONAPIDocument.Data.
+type JSONAPIDocument_Data struct {
+	union json.RawMessage
+}
+
+// JSONAPIResource defines model for JSONAPIResource.
+type JSONAPIResource struct {
+	Attributes    map[string]interface{}  `json:"attributes"`
+	Id            string                  `json:"id"`
+	Links         *map[string]string      `json:"links,omitempty"`
+	Relationships *map[string]interface{} `json:"relationships,omitempty"`
+	Type          string                  `json:"type"`
+}
+
+// JSONPatch RFC 6902 operations on /message and /key
+type JSONPatch = []struct {
+	From  *string      `json:"from,omitempty"`
+	Op    JSONPatchOp  `json:"op"`
+	Path  string       `json:"path"`
+	Value *interface{} `json:"value,omitempty"`
+}
+
+// JSONPatchOp defines model for JSONPatch.Op.
+type JSONPatchOp string
+
+// Labels Label names are qualified names like app.kubernetes.io/name, values are at most 63 characters
+type Labels map[string]string
+
+// Link defines model for Link.
+type Link struct {
+	Href   string  `json:"href"`
+	Method *string `json:"method,omitempty"`
+}
+
+// Links defines model for Links.
+type Links map[string]Link
+
+// Problem defines model for Problem.
+type Problem struct {
+	Detail   *string `json:"detail,omitempty"`
+	Instance *string `json:"instance,omitempty"`
+	Status   *int    `json:"status,omitempty"`
+	Title    *string `json:"title,omitempty"`
+	Type     *string `json:"type,omitempty"`
+}
+
+// Sample Only the requested fields are present when fields is given
+type Sample struct {
+	Links     *Links              `json:"_links,omitempty"`
+	CreatedAt *time.Time          `json:"created_at,omitempty"`
+	DeletedAt *time.Time          `json:"deleted_at"`
+	Id        *openapi_types.UUID `json:"id,omitempty"`
+
+	// Key Natural key chosen by the client, set by PUT /sample
+	Key       *string    `json:"key,omitempty"`
+	Message   *string    `json:"message,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// SampleCollection defines model for SampleCollection.
+type SampleCollection struct {
+	Links *Links                  `json:"_links,omitempty"`
+	Items []Sample                `json:"items"`
+	Meta  *map[string]interface{} `json:"meta,omitempty"`
+}
+
+// SampleLabels defines model for SampleLabels.
+type SampleLabels struct {
+	// Labels Label names are qualified names like app.kubernetes.io/name, values are at most 63 characters
+	Labels   Labels `json:"labels"`
+	SampleId string `json:"sample_id"`
+}
+
+// SampleLabelsRequest defines model for SampleLabelsRequest.
+type SampleLabelsRequest struct {
+	// Labels Label names are qualified names like app.kubernetes.io/name, values are at most 63 characters
+	Labels Labels `json:"labels"`
+}
+
+// SampleMergePatch RFC 7386 merge patch, null removes the key
+type SampleMergePatch struct {
+	Key     *string `json:"key"`
+	Message *string `json:"message,omitempty"`
+}
+
+// SampleRequest defines model for SampleRequest.
+type SampleRequest struct {
+	Message string `json:"message"`
+}
+
+// SampleRevision defines model for SampleRevision.
+type SampleRevision struct {
+	Action  SampleRevisionAction `json:"action"`
+	Changes []struct {
+		Field SampleRevisionChangesField `json:"field"`
+		New   *string                    `json:"new"`
+		Old   *string                    `json:"old"`
+	} `json:"changes"`
+	CreatedAt time.Time          `json:"created_at"`
+	Id        openapi_types.UUID `json:"id"`
+
+	// RevertedTo The revision a revert restored
+	RevertedTo *int   `json:"reverted_to,omitempty"`
+	Revision   int    `json:"revision"`
+	SampleId   string `json:"sample_id"`
+}
+
+// SampleRevisionAction defines model for SampleRevision.Action.
+type SampleRevisionAction string
+
+// SampleRevisionChangesField defines model for SampleRevision.Changes.Field.
+type SampleRevisionChangesField string
+
+// SampleStat defines model for SampleStat.
+type SampleStat struct {
+	Created int                `json:"created"`
+	Day     openapi_types.Date `json:"day"`
+	Deleted int                `json:"deleted"`
+	Tag     *string            `json:"tag,omitempty"`
+	Updated int                `json:"updated"`
+}
+
+// SampleUpsertRequest defines model for SampleUpsertRequest.
+type SampleUpsertRequest struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// DryRun defines model for dryRun.
+type DryRun = bool
+
+// DryRunHeader defines model for dryRunHeader.
+type DryRunHeader = bool
+
+// Fields defines model for fields.
+type Fields = string
+
+// FieldsSamples defines model for fieldsSamples.
+type FieldsSamples = string
+
+// ErrorApplicationJSON defines model for Error.
+type ErrorApplicationJSON = Error
+
+// ErrorApplicationProblemPlusJSON defines model for Error.
+type ErrorApplicationProblemPlusJSON = Problem
+
+// GetFirstSampleParams defines parameters for GetFirstSample.
+type GetFirstSampleParams struct {
+	// Fields Comma separated fields to return
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// FieldsSamples The JSON:API spelling of fields
+	FieldsSamples *FieldsSamples `form:"fields[samples],omitempty" json:"fields[samples],omitempty"`
+
+	// Ids Comma separated ids to look up at once
+	Ids *string `form:"ids,omitempty" json:"ids,omitempty"`
+
+	// Stream Stream every sample as NDJSON or a JSON array
+	Stream *GetFirstSampleParamsStream `form:"stream,omitempty" json:"stream,omitempty"`
+}
+
+// GetFirstSampleParamsStream defines parameters for GetFirstSample.
+type GetFirstSampleParamsStream string
+
+// CreateSampleParams defines parameters for CreateSample.
+type CreateSampleParams struct {
+	// Fields Comma separated fields to return
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// FieldsSamples The JSON:API spelling of fields
+	FieldsSamples *FieldsSamples `form:"fields[samples],omitempty" json:"fields[samples],omitempty"`
+
+	// DryRun Validate and run the write in a transaction that is rolled back, responding with what would have been stored
+	DryRun *DryRun `form:"dry_run,omitempty" json:"dry_run,omitempty"`
+
+	// XDryRun The header form of dry_run
+	XDryRun *DryRunHeader `json:"X-Dry-Run,omitempty"`
+}
+
+// UpsertSampleParams defines parameters for UpsertSample.
+type UpsertSampleParams struct {
+	// Fields Comma separated fields to return
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// FieldsSamples The JSON:API spelling of fields
+	FieldsSamples *FieldsSamples `form:"fields[samples],omitempty" json:"fields[samples],omitempty"`
+
+	// DryRun Validate and run the write in a transaction that is rolled back, responding with what would have been stored
+	DryRun *DryRun `form:"dry_run,omitempty" json:"dry_run,omitempty"`
+
+	// XDryRun The header form of dry_run
+	XDryRun *DryRunHeader `json:"X-Dry-Run,omitempty"`
+}
+
+// SampleChangesParams defines parameters for SampleChanges.
+type SampleChangesParams struct {
+	// Since Cursor of the previous response, empty for changes from now on
+	Since *string `form:"since,omitempty" json:"since,omitempty"`
+
+	// Timeout Seconds to wait, capped at SAMPLE_CHANGES_MAX_WAIT
+	Timeout *int `form:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// LookupSamplesJSONBody defines parameters for LookupSamples.
+type LookupSamplesJSONBody struct {
+	Ids *[]string `json:"ids,omitempty"`
+}
+
+// LookupSamplesParams defines parameters for LookupSamples.
+type LookupSamplesParams struct {
+	// Fields Comma separated fields to return
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// FieldsSamples The JSON:API spelling of fields
+	FieldsSamples *FieldsSamples `form:"fields[samples],omitempty" json:"fields[samples],omitempty"`
+}
+
+// GetSampleStatsParams defines parameters for GetSampleStats.
+type GetSampleStatsParams struct {
+	Days *int `form:"days,omitempty" json:"days,omitempty"`
+
+	// Tag A name=value label, the totals of all samples when omitted
+	Tag *string `form:"tag,omitempty" json:"tag,omitempty"`
+}
+
+// GetSampleTimeseriesParams defines parameters for GetSampleTimeseries.
+type GetSampleTimeseriesParams struct {
+	Event *GetSampleTimeseriesParamsEvent `form:"event,omitempty" json:"event,omitempty"`
+
+	// Window Bucket width such as 5m, 1h or 1d, at least one minute
+	Window *string `form:"window,omitempty" json:"window,omitempty"`
+
+	// Range How far back the series goes, at most 1000 windows
+	Range *string `form:"range,omitempty" json:"range,omitempty"`
+}
+
+// GetSampleTimeseriesParamsEvent defines parameters for GetSampleTimeseries.
+type GetSampleTimeseriesParamsEvent string
+
+// DeleteSampleParams defines parameters for DeleteSample.
+type DeleteSampleParams struct {
+	// DryRun Validate and run the write in a transaction that is rolled back, responding with what would have been stored
+	DryRun *DryRun `form:"dry_run,omitempty" json:"dry_run,omitempty"`
+
+	// XDryRun The header form of dry_run
+	XDryRun *DryRunHeader `json:"X-Dry-Run,omitempty"`
+}
+
+// GetSampleParams defines parameters for GetSample.
+type GetSampleParams struct {
+	// Fields Comma separated fields to return
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// FieldsSamples The JSON:API spelling of fields
+	FieldsSamples *FieldsSamples `form:"fields[samples],omitempty" json:"fields[samples],omitempty"`
+}
+
+// PatchSampleParams defines parameters for PatchSample.
+type PatchSampleParams struct {
+	// Fields Comma separated fields to return
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// FieldsSamples The JSON:API spelling of fields
+	FieldsSamples *FieldsSamples `form:"fields[samples],omitempty" json:"fields[samples],omitempty"`
+
+	// DryRun Validate and run the write in a transaction that is rolled back, responding with what would have been stored
+	DryRun *DryRun `form:"dry_run,omitempty" json:"dry_run,omitempty"`
+
+	// XDryRun The header form of dry_run
+	XDryRun *DryRunHeader `json:"X-Dry-Run,omitempty"`
+}
+
+// UpdateSampleParams defines parameters for UpdateSample.
+type UpdateSampleParams struct {
+	// Fields Comma separated fields to return
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// FieldsSamples The JSON:API spelling of fields
+	FieldsSamples *FieldsSamples `form:"fields[samples],omitempty" json:"fields[samples],omitempty"`
+
+	// DryRun Validate and run the write in a transaction that is rolled back, responding with what would have been stored
+	DryRun *DryRun `form:"dry_run,omitempty" json:"dry_run,omitempty"`
+
+	// XDryRun The header form of dry_run
+	XDryRun *DryRunHeader `json:"X-Dry-Run,omitempty"`
+}
+
+// SetSampleLabelsParams defines parameters for SetSampleLabels.
+type SetSampleLabelsParams struct {
+	// DryRun Validate and run the write in a transaction that is rolled back, responding with what would have been stored
+	DryRun *DryRun `form:"dry_run,omitempty" json:"dry_run,omitempty"`
+
+	// XDryRun The header form of dry_run
+	XDryRun *DryRunHeader `json:"X-Dry-Run,omitempty"`
+}
+
+// RevertSampleParams defines parameters for RevertSample.
+type RevertSampleParams struct {
+	// Fields Comma separated fields to return
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// FieldsSamples The JSON:API spelling of fields
+	FieldsSamples *FieldsSamples `form:"fields[samples],omitempty" json:"fields[samples],omitempty"`
+
+	// DryRun Validate and run the write in a transaction that is rolled back, responding with what would have been stored
+	DryRun *DryRun `form:"dry_run,omitempty" json:"dry_run,omitempty"`
+
+	// XDryRun The header form of dry_run
+	XDryRun *DryRunHeader `json:"X-Dry-Run,omitempty"`
+}
+
+// ListSamplesParams defines parameters for ListSamples.
+type ListSamplesParams struct {
+	// Fields Comma separated fields to return
+	Fields *Fields `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// FieldsSamples The JSON:API spelling of fields
+	FieldsSamples *FieldsSamples `form:"fields[samples],omitempty" json:"fields[samples],omitempty"`
+	Limit         *int           `form:"limit,omitempty" json:"limit,omitempty"`
+	Offset        *int           `form:"offset,omitempty" json:"offset,omitempty"`
+
+	// Cursor Pages by keyset when present, empty for the first page
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+
+	// Count How offset pages are totalled
+	Count *ListSamplesParamsCount `form:"count,omitempty" json:"count,omitempty"`
+
+	// Selector Kubernetes label selector such as env=prod,tier!=cache,zone in (a,b),!canary
+	Selector *string `form:"selector,omitempty" json:"selector,omitempty"`
+
+	// Filter Filter expression comparing id, message, key, created_at and updated_at with = != < <= > >= and ~ (contains), combined with AND, OR, NOT and parentheses, e.g. message ~ "hello" AND created_at >= 2024-01-01
+	Filter *string `form:"filter,omitempty" json:"filter,omitempty"`
+}
+
+// ListSamplesParamsCount defines parameters for ListSamples.
+type ListSamplesParamsCount string
+
+// CreateSampleJSONRequestBody defines body for CreateSample for application/json ContentType.
+type CreateSampleJSONRequestBody = SampleRequest
+
+// UpsertSampleJSONRequestBody defines body for UpsertSample for application/json ContentType.
+type UpsertSampleJSONRequestBody = SampleUpsertRequest
+
+// LookupSamplesJSONRequestBody defines body for LookupSamples for application/json ContentType.
+type LookupSamplesJSONRequestBody LookupSamplesJSONBody
+
+// PatchSampleJSONRequestBody defines body for PatchSample for application/json ContentType.
+type PatchSampleJSONRequestBody = SampleMergePatch
+
+// PatchSampleApplicationJSONPatchPlusJSONRequestBody defines body for PatchSample for application/json-patch+json ContentType.
+type PatchSampleApplicationJSONPatchPlusJSONRequestBody = JSONPatch
+
+// PatchSampleApplicationMergePatchPlusJSONRequestBody defines body for PatchSample for application/merge-patch+json ContentType.
+type PatchSampleApplicationMergePatchPlusJSONRequestBody = SampleMergePatch
+
+// UpdateSampleJSONRequestBody defines body for UpdateSample for application/json ContentType.
+type UpdateSampleJSONRequestBody = SampleRequest
+
+// SetSampleLabelsJSONRequestBody defines body for SetSampleLabels for application/json ContentType.
+type SetSampleLabelsJSONRequestBody = SampleLabelsRequest
+
+// AsJSONAPIResource returns the union data inside the JSONAPIDocument_Data as a JSONAPIResource
+func (t JSONAPIDocument_Data) AsJSONAPIResource() (JSONAPIResource, error) {
+	var body JSONAPIResource
+	err := json.Unmarshal(t.union, &body)
+	return body, err
+}
+
+// FromJSONAPIResource overwrites any union data inside the JSONAPIDocument_Data as the provided JSONAPIResource
+func (t *JSONAPIDocument_Data) FromJSONAPIResource(v JSONAPIResource) error {
+	b, err := json.Marshal(v)
+	t.union = b
+	return err
+}
+
+// MergeJSONAPIResource performs a merge with any union data inside the JSONAPIDocument_Data, using the provided JSONAPIResource
+func (t *JSONAPIDocument_Data) MergeJSONAPIResource(v JSONAPIResource) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	merged, err := runtime.JSONMerge(t.union, b)
+	t.union = merged
+	return err
+}
+
+// AsJSONAPIDocumentData1 returns the union data inside the JSONAPIDocument_Data as a JSONAPIDocumentData1
+func (t JSONAPIDocument_Data) AsJSONAPIDocumentData1() (JSONAPIDocumentData1, error) {
+	var body JSONAPIDocumentData1
+	err := json.Unmarshal(t.union, &body)
+	return body, err
+}
+
+// FromJSONAPIDocumentData1 overwrites any union data inside the JSONAPIDocument_Data as the provided JSONAPIDocumentData1
+func (t *JSONAPIDocument_Data) FromJSONAPIDocumentData1(v JSONAPIDocumentData1) error {
+	b, err := json.Marshal(v)
+	t.union = b
+	return err
+}
+
+// MergeJSONAPIDocumentData1 performs a merge with any union data inside the JSONAPIDocument_Data, using the provided JSONAPIDocumentData1
+func (t *JSONAPIDocument_Data) MergeJSONAPIDocumentData1(v JSONAPIDocumentData1) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	merged, err := runtime.JSONMerge(t.union, b)
+	t.union = merged
+	return err
+}
+
+func (t JSONAPIDocument_Data) MarshalJSON() ([]byte, error) {
+	b, err := t.union.MarshalJSON()
+	return b, err
+}
+
+func (t *JSONAPIDocument_Data) UnmarshalJSON(b []byte) error {
+	err := t.union.UnmarshalJSON(b)
+	return err
+}
+
+// ServerInterface represents all server handlers.
+type ServerInterface interface {
+	// First sample, a batch lookup with ids, or every sample streamed with stream
+	// (GET /sample)
+	GetFirstSample(ctx echo.Context, params GetFirstSampleParams) error
+	// Create a sample
+	// (POST /sample)
+	CreateSample(ctx echo.Context, params CreateSampleParams) error
+	// Create or update the sample with a natural key
+	// (PUT /sample)
+	UpsertSample(ctx echo.Context, params UpsertSampleParams) error
+	// Long-poll the changes made after a cursor
+	// (GET /sample/changes)
+	SampleChanges(ctx echo.Context, params SampleChangesParams) error
+	// Look up samples by id, for id lists too long for a URL
+	// (POST /sample/lookup)
+	LookupSamples(ctx echo.Context, params LookupSamplesParams) error
+	// Daily create, update and delete counts from the sample_stats read model, newest day first
+	// (GET /sample/stats)
+	GetSampleStats(ctx echo.Context, params GetSampleStatsParams) error
+	// Samples created, updated or deleted per window, oldest bucket first
+	// (GET /sample/stats/timeseries)
+	GetSampleTimeseries(ctx echo.Context, params GetSampleTimeseriesParams) error
+	// Delete a sample
+	// (DELETE /sample/{id})
+	DeleteSample(ctx echo.Context, id string, params DeleteSampleParams) error
+	// Get a sample
+	// (GET /sample/{id})
+	GetSample(ctx echo.Context, id string, params GetSampleParams) error
+	// Change only the fields a JSON Merge Patch or JSON Patch touches
+	// (PATCH /sample/{id})
+	PatchSample(ctx echo.Context, id string, params PatchSampleParams) error
+	// Update a sample
+	// (PUT /sample/{id})
+	UpdateSample(ctx echo.Context, id string, params UpdateSampleParams) error
+	// Field changes of every write to the sample, newest revision first
+	// (GET /sample/{id}/history)
+	GetSampleHistory(ctx echo.Context, id string) error
+	// Labels of the sample
+	// (GET /sample/{id}/labels)
+	GetSampleLabels(ctx echo.Context, id string) error
+	// Replace the labels of the sample
+	// (PUT /sample/{id}/labels)
+	SetSampleLabels(ctx echo.Context, id string, params SetSampleLabelsParams) error
+	// Restore the sample to its state right after a revision
+	// (POST /sample/{id}/revert/{revision})
+	RevertSample(ctx echo.Context, id string, revision int, params RevertSampleParams) error
+	// Page through samples newest first, by offset or by cursor
+	// (GET /samples)
+	ListSamples(ctx echo.Context, params ListSamplesParams) error
+}
+
+// ServerInterfaceWrapper converts echo contexts to parameters.
+type ServerInterfaceWrapper struct {
+	Handler ServerInterface
+}
+
+// GetFirstSample converts echo context to params.
+func (w *ServerInterfaceWrapper) GetFirstSample(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetFirstSampleParams
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", ctx.QueryParams(), &params.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields: %s", err))
+	}
+
+	// ------------- Optional query parameter "fields[samples]" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields[samples]", ctx.QueryParams(), &params.FieldsSamples)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields[samples]: %s", err))
+	}
+
+	// ------------- Optional query parameter "ids" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "ids", ctx.QueryParams(), &params.Ids)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter ids: %s", err))
+	}
+
+	// ------------- Optional query parameter "stream" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "stream", ctx.QueryParams(), &params.Stream)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter stream: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetFirstSample(ctx, params)
+	return err
+}
+
+// CreateSample converts echo context to params.
+func (w *ServerInterfaceWrapper) CreateSample(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CreateSampleParams
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", ctx.QueryParams(), &params.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields: %s", err))
+	}
+
+	// ------------- Optional query parameter "fields[samples]" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields[samples]", ctx.QueryParams(), &params.FieldsSamples)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields[samples]: %s", err))
+	}
+
+	// ------------- Optional query parameter "dry_run" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "dry_run", ctx.QueryParams(), &params.DryRun)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter dry_run: %s", err))
+	}
+
+	headers := ctx.Request().Header
+	// ------------- Optional header parameter "X-Dry-Run" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Dry-Run")]; found {
+		var XDryRun DryRunHeader
+		n := len(valueList)
+		if n != 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Expected one value for X-Dry-Run, got %d", n))
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Dry-Run", valueList[0], &XDryRun, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter X-Dry-Run: %s", err))
+		}
+
+		params.XDryRun = &XDryRun
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.CreateSample(ctx, params)
+	return err
+}
+
+// UpsertSample converts echo context to params.
+func (w *ServerInterfaceWrapper) UpsertSample(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params UpsertSampleParams
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", ctx.QueryParams(), &params.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields: %s", err))
+	}
+
+	// ------------- Optional query parameter "fields[samples]" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields[samples]", ctx.QueryParams(), &params.FieldsSamples)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields[samples]: %s", err))
+	}
+
+	// ------------- Optional query parameter "dry_run" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "dry_run", ctx.QueryParams(), &params.DryRun)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter dry_run: %s", err))
+	}
+
+	headers := ctx.Request().Header
+	// ------------- Optional header parameter "X-Dry-Run" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Dry-Run")]; found {
+		var XDryRun DryRunHeader
+		n := len(valueList)
+		if n != 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Expected one value for X-Dry-Run, got %d", n))
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Dry-Run", valueList[0], &XDryRun, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter X-Dry-Run: %s", err))
+		}
+
+		params.XDryRun = &XDryRun
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.UpsertSample(ctx, params)
+	return err
+}
+
+// SampleChanges converts echo context to params.
+func (w *ServerInterfaceWrapper) SampleChanges(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params SampleChangesParams
+	// ------------- Optional query parameter "since" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "since", ctx.QueryParams(), &params.Since)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter since: %s", err))
+	}
+
+	// ------------- Optional query parameter "timeout" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "timeout", ctx.QueryParams(), &params.Timeout)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter timeout: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.SampleChanges(ctx, params)
+	return err
+}
+
+// LookupSamples converts echo context to params.
+func (w *ServerInterfaceWrapper) LookupSamples(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params LookupSamplesParams
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", ctx.QueryParams(), &params.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields: %s", err))
+	}
+
+	// ------------- Optional query parameter "fields[samples]" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields[samples]", ctx.QueryParams(), &params.FieldsSamples)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields[samples]: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.LookupSamples(ctx, params)
+	return err
+}
+
+// GetSampleStats converts echo context to params.
+func (w *ServerInterfaceWrapper) GetSampleStats(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetSampleStatsParams
+	// ------------- Optional query parameter "days" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "days", ctx.QueryParams(), &params.Days)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter days: %s", err))
+	}
+
+	// ------------- Optional query parameter "tag" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "tag", ctx.QueryParams(), &params.Tag)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter tag: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetSampleStats(ctx, params)
+	return err
+}
+
+// GetSampleTimeseries converts echo context to params.
+func (w *ServerInterfaceWrapper) GetSampleTimeseries(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetSampleTimeseriesParams
+	// ------------- Optional query parameter "event" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "event", ctx.QueryParams(), &params.Event)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter event: %s", err))
+	}
+
+	// ------------- Optional query parameter "window" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "window", ctx.QueryParams(), &params.Window)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter window: %s", err))
+	}
+
+	// ------------- Optional query parameter "range" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "range", ctx.QueryParams(), &params.Range)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter range: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetSampleTimeseries(ctx, params)
+	return err
+}
+
+// DeleteSample converts echo context to params.
+func (w *ServerInterfaceWrapper) DeleteSample(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", ctx.Param("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params DeleteSampleParams
+	// ------------- Optional query parameter "dry_run" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "dry_run", ctx.QueryParams(), &params.DryRun)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter dry_run: %s", err))
+	}
+
+	headers := ctx.Request().Header
+	// ------------- Optional header parameter "X-Dry-Run" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Dry-Run")]; found {
+		var XDryRun DryRunHeader
+		n := len(valueList)
+		if n != 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Expected one value for X-Dry-Run, got %d", n))
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Dry-Run", valueList[0], &XDryRun, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter X-Dry-Run: %s", err))
+		}
+
+		params.XDryRun = &XDryRun
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.DeleteSample(ctx, id, params)
+	return err
+}
+
+// GetSample converts echo context to params.
+func (w *ServerInterfaceWrapper) GetSample(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", ctx.Param("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetSampleParams
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", ctx.QueryParams(), &params.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields: %s", err))
+	}
+
+	// ------------- Optional query parameter "fields[samples]" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields[samples]", ctx.QueryParams(), &params.FieldsSamples)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields[samples]: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetSample(ctx, id, params)
+	return err
+}
+
+// PatchSample converts echo context to params.
+func (w *ServerInterfaceWrapper) PatchSample(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", ctx.Param("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PatchSampleParams
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", ctx.QueryParams(), &params.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields: %s", err))
+	}
+
+	// ------------- Optional query parameter "fields[samples]" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields[samples]", ctx.QueryParams(), &params.FieldsSamples)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields[samples]: %s", err))
+	}
+
+	// ------------- Optional query parameter "dry_run" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "dry_run", ctx.QueryParams(), &params.DryRun)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter dry_run: %s", err))
+	}
+
+	headers := ctx.Request().Header
+	// ------------- Optional header parameter "X-Dry-Run" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Dry-Run")]; found {
+		var XDryRun DryRunHeader
+		n := len(valueList)
+		if n != 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Expected one value for X-Dry-Run, got %d", n))
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Dry-Run", valueList[0], &XDryRun, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter X-Dry-Run: %s", err))
+		}
+
+		params.XDryRun = &XDryRun
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.PatchSample(ctx, id, params)
+	return err
+}
+
+// UpdateSample converts echo context to params.
+func (w *ServerInterfaceWrapper) UpdateSample(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", ctx.Param("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params UpdateSampleParams
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", ctx.QueryParams(), &params.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields: %s", err))
+	}
+
+	// ------------- Optional query parameter "fields[samples]" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields[samples]", ctx.QueryParams(), &params.FieldsSamples)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields[samples]: %s", err))
+	}
+
+	// ------------- Optional query parameter "dry_run" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "dry_run", ctx.QueryParams(), &params.DryRun)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter dry_run: %s", err))
+	}
+
+	headers := ctx.Request().Header
+	// ------------- Optional header parameter "X-Dry-Run" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Dry-Run")]; found {
+		var XDryRun DryRunHeader
+		n := len(valueList)
+		if n != 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Expected one value for X-Dry-Run, got %d", n))
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Dry-Run", valueList[0], &XDryRun, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter X-Dry-Run: %s", err))
+		}
+
+		params.XDryRun = &XDryRun
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.UpdateSample(ctx, id, params)
+	return err
+}
+
+// GetSampleHistory converts echo context to params.
+func (w *ServerInterfaceWrapper) GetSampleHistory(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", ctx.Param("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetSampleHistory(ctx, id)
+	return err
+}
+
+// GetSampleLabels converts echo context to params.
+func (w *ServerInterfaceWrapper) GetSampleLabels(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", ctx.Param("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.GetSampleLabels(ctx, id)
+	return err
+}
+
+// SetSampleLabels converts echo context to params.
+func (w *ServerInterfaceWrapper) SetSampleLabels(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", ctx.Param("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params SetSampleLabelsParams
+	// ------------- Optional query parameter "dry_run" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "dry_run", ctx.QueryParams(), &params.DryRun)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter dry_run: %s", err))
+	}
+
+	headers := ctx.Request().Header
+	// ------------- Optional header parameter "X-Dry-Run" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Dry-Run")]; found {
+		var XDryRun DryRunHeader
+		n := len(valueList)
+		if n != 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Expected one value for X-Dry-Run, got %d", n))
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Dry-Run", valueList[0], &XDryRun, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter X-Dry-Run: %s", err))
+		}
+
+		params.XDryRun = &XDryRun
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.SetSampleLabels(ctx, id, params)
+	return err
+}
+
+// RevertSample converts echo context to params.
+func (w *ServerInterfaceWrapper) RevertSample(ctx echo.Context) error {
+	var err error
+	// ------------- Path parameter "id" -------------
+	var id string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", ctx.Param("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter id: %s", err))
+	}
+
+	// ------------- Path parameter "revision" -------------
+	var revision int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "revision", ctx.Param("revision"), &revision, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter revision: %s", err))
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params RevertSampleParams
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", ctx.QueryParams(), &params.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields: %s", err))
+	}
+
+	// ------------- Optional query parameter "fields[samples]" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields[samples]", ctx.QueryParams(), &params.FieldsSamples)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields[samples]: %s", err))
+	}
+
+	// ------------- Optional query parameter "dry_run" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "dry_run", ctx.QueryParams(), &params.DryRun)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter dry_run: %s", err))
+	}
+
+	headers := ctx.Request().Header
+	// ------------- Optional header parameter "X-Dry-Run" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Dry-Run")]; found {
+		var XDryRun DryRunHeader
+		n := len(valueList)
+		if n != 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Expected one value for X-Dry-Run, got %d", n))
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "X-Dry-Run", valueList[0], &XDryRun, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter X-Dry-Run: %s", err))
+		}
+
+		params.XDryRun = &XDryRun
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.RevertSample(ctx, id, revision, params)
+	return err
+}
+
+// ListSamples converts echo context to params.
+func (w *ServerInterfaceWrapper) ListSamples(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListSamplesParams
+	// ------------- Optional query parameter "fields" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields", ctx.QueryParams(), &params.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields: %s", err))
+	}
+
+	// ------------- Optional query parameter "fields[samples]" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "fields[samples]", ctx.QueryParams(), &params.FieldsSamples)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter fields[samples]: %s", err))
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", ctx.QueryParams(), &params.Limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter limit: %s", err))
+	}
+
+	// ------------- Optional query parameter "offset" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "offset", ctx.QueryParams(), &params.Offset)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter offset: %s", err))
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", ctx.QueryParams(), &params.Cursor)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter cursor: %s", err))
+	}
+
+	// ------------- Optional query parameter "count" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "count", ctx.QueryParams(), &params.Count)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter count: %s", err))
+	}
+
+	// ------------- Optional query parameter "selector" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "selector", ctx.QueryParams(), &params.Selector)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter selector: %s", err))
+	}
+
+	// ------------- Optional query parameter "filter" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "filter", ctx.QueryParams(), &params.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter filter: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.ListSamples(ctx, params)
+	return err
+}
+
+// This is a simple interface which specifies echo.Route addition functions which
+// are present on both echo.Echo and echo.Group, since we want to allow using
+// either of them for path registration
+type EchoRouter interface {
+	CONNECT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	HEAD(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	OPTIONS(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	PATCH(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+	TRACE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+}
+
+// RegisterHandlers adds each server route to the EchoRouter.
+func RegisterHandlers(router EchoRouter, si ServerInterface) {
+	RegisterHandlersWithBaseURL(router, si, "")
+}
+
+// Registers handlers, and prepends BaseURL to the paths, so that the paths
+// can be served under a prefix.
+func RegisterHandlersWithBaseURL(router EchoRouter, si ServerInterface, baseURL string) {
+
+	wrapper := ServerInterfaceWrapper{
+		Handler: si,
+	}
+
+	router.GET(baseURL+"/sample", wrapper.GetFirstSample)
+	router.POST(baseURL+"/sample", wrapper.CreateSample)
+	router.PUT(baseURL+"/sample", wrapper.UpsertSample)
+	router.GET(baseURL+"/sample/changes", wrapper.SampleChanges)
+	router.POST(baseURL+"/sample/lookup", wrapper.LookupSamples)
+	router.GET(baseURL+"/sample/stats", wrapper.GetSampleStats)
+	router.GET(baseURL+"/sample/stats/timeseries", wrapper.GetSampleTimeseries)
+	router.DELETE(baseURL+"/sample/:id", wrapper.DeleteSample)
+	router.GET(baseURL+"/sample/:id", wrapper.GetSample)
+	router.PATCH(baseURL+"/sample/:id", wrapper.PatchSample)
+	router.PUT(baseURL+"/sample/:id", wrapper.UpdateSample)
+	router.GET(baseURL+"/sample/:id/history", wrapper.GetSampleHistory)
+	router.GET(baseURL+"/sample/:id/labels", wrapper.GetSampleLabels)
+	router.PUT(baseURL+"/sample/:id/labels", wrapper.SetSampleLabels)
+	router.POST(baseURL+"/sample/:id/revert/:revision", wrapper.RevertSample)
+	router.GET(baseURL+"/samples", wrapper.ListSamples)
+
+}
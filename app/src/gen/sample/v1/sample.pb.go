@@ -0,0 +1,474 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: sample/v1/sample.proto
+
+package samplev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Sample struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	CreateTime    *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=create_time,json=createTime,proto3" json:"create_time,omitempty"`
+	UpdateTime    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=update_time,json=updateTime,proto3" json:"update_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sample) Reset() {
+	*x = Sample{}
+	mi := &file_sample_v1_sample_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sample) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sample) ProtoMessage() {}
+
+func (x *Sample) ProtoReflect() protoreflect.Message {
+	mi := &file_sample_v1_sample_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sample.ProtoReflect.Descriptor instead.
+func (*Sample) Descriptor() ([]byte, []int) {
+	return file_sample_v1_sample_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Sample) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Sample) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Sample) GetCreateTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreateTime
+	}
+	return nil
+}
+
+func (x *Sample) GetUpdateTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdateTime
+	}
+	return nil
+}
+
+type GetSampleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSampleRequest) Reset() {
+	*x = GetSampleRequest{}
+	mi := &file_sample_v1_sample_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSampleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSampleRequest) ProtoMessage() {}
+
+func (x *GetSampleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sample_v1_sample_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSampleRequest.ProtoReflect.Descriptor instead.
+func (*GetSampleRequest) Descriptor() ([]byte, []int) {
+	return file_sample_v1_sample_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetSampleRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetSampleResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sample        *Sample                `protobuf:"bytes,1,opt,name=sample,proto3" json:"sample,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSampleResponse) Reset() {
+	*x = GetSampleResponse{}
+	mi := &file_sample_v1_sample_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSampleResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSampleResponse) ProtoMessage() {}
+
+func (x *GetSampleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sample_v1_sample_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSampleResponse.ProtoReflect.Descriptor instead.
+func (*GetSampleResponse) Descriptor() ([]byte, []int) {
+	return file_sample_v1_sample_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetSampleResponse) GetSample() *Sample {
+	if x != nil {
+		return x.Sample
+	}
+	return nil
+}
+
+type ListSamplesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to 20, at most 100
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token of the previous response, empty for the first page
+	PageToken     string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSamplesRequest) Reset() {
+	*x = ListSamplesRequest{}
+	mi := &file_sample_v1_sample_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSamplesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSamplesRequest) ProtoMessage() {}
+
+func (x *ListSamplesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sample_v1_sample_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSamplesRequest.ProtoReflect.Descriptor instead.
+func (*ListSamplesRequest) Descriptor() ([]byte, []int) {
+	return file_sample_v1_sample_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListSamplesRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListSamplesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListSamplesResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Samples []*Sample              `protobuf:"bytes,1,rep,name=samples,proto3" json:"samples,omitempty"`
+	// Empty on the last page
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSamplesResponse) Reset() {
+	*x = ListSamplesResponse{}
+	mi := &file_sample_v1_sample_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSamplesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSamplesResponse) ProtoMessage() {}
+
+func (x *ListSamplesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sample_v1_sample_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSamplesResponse.ProtoReflect.Descriptor instead.
+func (*ListSamplesResponse) Descriptor() ([]byte, []int) {
+	return file_sample_v1_sample_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListSamplesResponse) GetSamples() []*Sample {
+	if x != nil {
+		return x.Samples
+	}
+	return nil
+}
+
+func (x *ListSamplesResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type CreateSampleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSampleRequest) Reset() {
+	*x = CreateSampleRequest{}
+	mi := &file_sample_v1_sample_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSampleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSampleRequest) ProtoMessage() {}
+
+func (x *CreateSampleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sample_v1_sample_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSampleRequest.ProtoReflect.Descriptor instead.
+func (*CreateSampleRequest) Descriptor() ([]byte, []int) {
+	return file_sample_v1_sample_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateSampleRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type CreateSampleResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sample        *Sample                `protobuf:"bytes,1,opt,name=sample,proto3" json:"sample,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSampleResponse) Reset() {
+	*x = CreateSampleResponse{}
+	mi := &file_sample_v1_sample_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSampleResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSampleResponse) ProtoMessage() {}
+
+func (x *CreateSampleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sample_v1_sample_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSampleResponse.ProtoReflect.Descriptor instead.
+func (*CreateSampleResponse) Descriptor() ([]byte, []int) {
+	return file_sample_v1_sample_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateSampleResponse) GetSample() *Sample {
+	if x != nil {
+		return x.Sample
+	}
+	return nil
+}
+
+var File_sample_v1_sample_proto protoreflect.FileDescriptor
+
+const file_sample_v1_sample_proto_rawDesc = "" +
+	"\n" +
+	"\x16sample/v1/sample.proto\x12\tsample.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xac\x01\n" +
+	"\x06Sample\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12;\n" +
+	"\vcreate_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"createTime\x12;\n" +
+	"\vupdate_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"updateTime\"\"\n" +
+	"\x10GetSampleRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\">\n" +
+	"\x11GetSampleResponse\x12)\n" +
+	"\x06sample\x18\x01 \x01(\v2\x11.sample.v1.SampleR\x06sample\"P\n" +
+	"\x12ListSamplesRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\"j\n" +
+	"\x13ListSamplesResponse\x12+\n" +
+	"\asamples\x18\x01 \x03(\v2\x11.sample.v1.SampleR\asamples\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"/\n" +
+	"\x13CreateSampleRequest\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"A\n" +
+	"\x14CreateSampleResponse\x12)\n" +
+	"\x06sample\x18\x01 \x01(\v2\x11.sample.v1.SampleR\x06sample2\xf6\x01\n" +
+	"\rSampleService\x12F\n" +
+	"\tGetSample\x12\x1b.sample.v1.GetSampleRequest\x1a\x1c.sample.v1.GetSampleResponse\x12L\n" +
+	"\vListSamples\x12\x1d.sample.v1.ListSamplesRequest\x1a\x1e.sample.v1.ListSamplesResponse\x12O\n" +
+	"\fCreateSample\x12\x1e.sample.v1.CreateSampleRequest\x1a\x1f.sample.v1.CreateSampleResponseB}\n" +
+	"\rcom.sample.v1B\vSampleProtoP\x01Z\x1aapp/gen/sample/v1;samplev1\xa2\x02\x03SXX\xaa\x02\tSample.V1\xca\x02\tSample\\V1\xe2\x02\x15Sample\\V1\\GPBMetadata\xea\x02\n" +
+	"Sample::V1b\x06proto3"
+
+var (
+	file_sample_v1_sample_proto_rawDescOnce sync.Once
+	file_sample_v1_sample_proto_rawDescData []byte
+)
+
+func file_sample_v1_sample_proto_rawDescGZIP() []byte {
+	file_sample_v1_sample_proto_rawDescOnce.Do(func() {
+		file_sample_v1_sample_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sample_v1_sample_proto_rawDesc), len(file_sample_v1_sample_proto_rawDesc)))
+	})
+	return file_sample_v1_sample_proto_rawDescData
+}
+
+var file_sample_v1_sample_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_sample_v1_sample_proto_goTypes = []any{
+	(*Sample)(nil),                // 0: sample.v1.Sample
+	(*GetSampleRequest)(nil),      // 1: sample.v1.GetSampleRequest
+	(*GetSampleResponse)(nil),     // 2: sample.v1.GetSampleResponse
+	(*ListSamplesRequest)(nil),    // 3: sample.v1.ListSamplesRequest
+	(*ListSamplesResponse)(nil),   // 4: sample.v1.ListSamplesResponse
+	(*CreateSampleRequest)(nil),   // 5: sample.v1.CreateSampleRequest
+	(*CreateSampleResponse)(nil),  // 6: sample.v1.CreateSampleResponse
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+}
+var file_sample_v1_sample_proto_depIdxs = []int32{
+	7, // 0: sample.v1.Sample.create_time:type_name -> google.protobuf.Timestamp
+	7, // 1: sample.v1.Sample.update_time:type_name -> google.protobuf.Timestamp
+	0, // 2: sample.v1.GetSampleResponse.sample:type_name -> sample.v1.Sample
+	0, // 3: sample.v1.ListSamplesResponse.samples:type_name -> sample.v1.Sample
+	0, // 4: sample.v1.CreateSampleResponse.sample:type_name -> sample.v1.Sample
+	1, // 5: sample.v1.SampleService.GetSample:input_type -> sample.v1.GetSampleRequest
+	3, // 6: sample.v1.SampleService.ListSamples:input_type -> sample.v1.ListSamplesRequest
+	5, // 7: sample.v1.SampleService.CreateSample:input_type -> sample.v1.CreateSampleRequest
+	2, // 8: sample.v1.SampleService.GetSample:output_type -> sample.v1.GetSampleResponse
+	4, // 9: sample.v1.SampleService.ListSamples:output_type -> sample.v1.ListSamplesResponse
+	6, // 10: sample.v1.SampleService.CreateSample:output_type -> sample.v1.CreateSampleResponse
+	8, // [8:11] is the sub-list for method output_type
+	5, // [5:8] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_sample_v1_sample_proto_init() }
+func file_sample_v1_sample_proto_init() {
+	if File_sample_v1_sample_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sample_v1_sample_proto_rawDesc), len(file_sample_v1_sample_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sample_v1_sample_proto_goTypes,
+		DependencyIndexes: file_sample_v1_sample_proto_depIdxs,
+		MessageInfos:      file_sample_v1_sample_proto_msgTypes,
+	}.Build()
+	File_sample_v1_sample_proto = out.File
+	file_sample_v1_sample_proto_goTypes = nil
+	file_sample_v1_sample_proto_depIdxs = nil
+}
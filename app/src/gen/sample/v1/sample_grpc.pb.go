@@ -0,0 +1,203 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: sample/v1/sample.proto
+
+package samplev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SampleService_GetSample_FullMethodName    = "/sample.v1.SampleService/GetSample"
+	SampleService_ListSamples_FullMethodName  = "/sample.v1.SampleService/ListSamples"
+	SampleService_CreateSample_FullMethodName = "/sample.v1.SampleService/CreateSample"
+)
+
+// SampleServiceClient is the client API for SampleService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SampleService is the gRPC counterpart of the /sample HTTP API
+type SampleServiceClient interface {
+	GetSample(ctx context.Context, in *GetSampleRequest, opts ...grpc.CallOption) (*GetSampleResponse, error)
+	// ListSamples pages newest first, continuing from page_token like GET /samples?cursor=
+	ListSamples(ctx context.Context, in *ListSamplesRequest, opts ...grpc.CallOption) (*ListSamplesResponse, error)
+	CreateSample(ctx context.Context, in *CreateSampleRequest, opts ...grpc.CallOption) (*CreateSampleResponse, error)
+}
+
+type sampleServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSampleServiceClient(cc grpc.ClientConnInterface) SampleServiceClient {
+	return &sampleServiceClient{cc}
+}
+
+func (c *sampleServiceClient) GetSample(ctx context.Context, in *GetSampleRequest, opts ...grpc.CallOption) (*GetSampleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSampleResponse)
+	err := c.cc.Invoke(ctx, SampleService_GetSample_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sampleServiceClient) ListSamples(ctx context.Context, in *ListSamplesRequest, opts ...grpc.CallOption) (*ListSamplesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSamplesResponse)
+	err := c.cc.Invoke(ctx, SampleService_ListSamples_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sampleServiceClient) CreateSample(ctx context.Context, in *CreateSampleRequest, opts ...grpc.CallOption) (*CreateSampleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateSampleResponse)
+	err := c.cc.Invoke(ctx, SampleService_CreateSample_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SampleServiceServer is the server API for SampleService service.
+// All implementations must embed UnimplementedSampleServiceServer
+// for forward compatibility.
+//
+// SampleService is the gRPC counterpart of the /sample HTTP API
+type SampleServiceServer interface {
+	GetSample(context.Context, *GetSampleRequest) (*GetSampleResponse, error)
+	// ListSamples pages newest first, continuing from page_token like GET /samples?cursor=
+	ListSamples(context.Context, *ListSamplesRequest) (*ListSamplesResponse, error)
+	CreateSample(context.Context, *CreateSampleRequest) (*CreateSampleResponse, error)
+	mustEmbedUnimplementedSampleServiceServer()
+}
+
+// UnimplementedSampleServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSampleServiceServer struct{}
+
+func (UnimplementedSampleServiceServer) GetSample(context.Context, *GetSampleRequest) (*GetSampleResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetSample not implemented")
+}
+func (UnimplementedSampleServiceServer) ListSamples(context.Context, *ListSamplesRequest) (*ListSamplesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListSamples not implemented")
+}
+func (UnimplementedSampleServiceServer) CreateSample(context.Context, *CreateSampleRequest) (*CreateSampleResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateSample not implemented")
+}
+func (UnimplementedSampleServiceServer) mustEmbedUnimplementedSampleServiceServer() {}
+func (UnimplementedSampleServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeSampleServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SampleServiceServer will
+// result in compilation errors.
+type UnsafeSampleServiceServer interface {
+	mustEmbedUnimplementedSampleServiceServer()
+}
+
+func RegisterSampleServiceServer(s grpc.ServiceRegistrar, srv SampleServiceServer) {
+	// If the following call panics, it indicates UnimplementedSampleServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SampleService_ServiceDesc, srv)
+}
+
+func _SampleService_GetSample_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSampleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SampleServiceServer).GetSample(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SampleService_GetSample_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SampleServiceServer).GetSample(ctx, req.(*GetSampleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SampleService_ListSamples_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSamplesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SampleServiceServer).ListSamples(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SampleService_ListSamples_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SampleServiceServer).ListSamples(ctx, req.(*ListSamplesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SampleService_CreateSample_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSampleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SampleServiceServer).CreateSample(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SampleService_CreateSample_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SampleServiceServer).CreateSample(ctx, req.(*CreateSampleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SampleService_ServiceDesc is the grpc.ServiceDesc for SampleService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SampleService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sample.v1.SampleService",
+	HandlerType: (*SampleServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSample",
+			Handler:    _SampleService_GetSample_Handler,
+		},
+		{
+			MethodName: "ListSamples",
+			Handler:    _SampleService_ListSamples_Handler,
+		},
+		{
+			MethodName: "CreateSample",
+			Handler:    _SampleService_CreateSample_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sample/v1/sample.proto",
+}
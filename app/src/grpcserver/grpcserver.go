@@ -0,0 +1,116 @@
+package grpcserver
+
+import (
+	"app/config"
+	"app/health"
+	"app/runtimeutil"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+type Config struct {
+	Enabled bool
+	Addr    string
+	// HealthInterval is how often the serving status is refreshed from the health checks
+	HealthInterval time.Duration
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		Enabled:        config.Bool("GRPC_ENABLED", false),
+		Addr:           config.String("GRPC_ADDR", ":9090"),
+		HealthInterval: config.Duration("GRPC_HEALTH_INTERVAL", 5*time.Second),
+	}
+}
+
+// Server is the gRPC listener next to the HTTP server
+type Server struct {
+	*grpc.Server
+	health *grpchealth.Server
+}
+
+// New returns nil when the gRPC server is disabled.
+// It already carries the standard health service, which grpc-health-probe and the kubelet's
+// grpc probe query, and server reflection for grpcurl.
+// RPC services generated from proto/ are registered on it before Start, guarded by interceptors.
+func New(cfg Config, interceptors ...grpc.UnaryServerInterceptor) *Server {
+	if !cfg.Enabled {
+		return nil
+	}
+	s := &Server{Server: grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...)), health: grpchealth.NewServer()}
+	healthpb.RegisterHealthServer(s.Server, s.health)
+	reflection.Register(s.Server)
+	return s
+}
+
+// Run serves on cfg.Addr until Shutdown, keeping the health status in line with the HTTP readiness
+// checks. It returns nil once shut down and the error otherwise, failing to listen included.
+func (s *Server) Run(ctx context.Context, cfg Config) error {
+	listener, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	runtimeutil.Go("grpc health sync", func() { s.syncHealth(ctx, cfg.HealthInterval) })
+	slog.Info("grpc server started", "addr", cfg.Addr)
+	if err := s.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// syncHealth mirrors the health registry: the overall "" service is NOT_SERVING only when a
+// critical check fails, like /readyz, and every check is also reported under its own name
+func (s *Server) syncHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report, ok := health.Default.Cached()
+		if !ok {
+			report = health.Default.Run(ctx)
+		}
+		s.health.SetServingStatus("", servingStatus(report.Status != health.StatusDown))
+		for name, result := range report.Checks {
+			s.health.SetServingStatus(name, servingStatus(result.Status == health.StatusUp))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func servingStatus(up bool) healthpb.HealthCheckResponse_ServingStatus {
+	if up {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// Shutdown reports NOT_SERVING, then waits for in-flight RPCs until ctx ends
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,118 @@
+package grpcserver
+
+import (
+	samplev1 "app/gen/sample/v1"
+	"app/ipfilter"
+	"app/readonly"
+	"app/service"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The HTTP middleware guarding the API does not run for RPCs, the interceptors below apply the same
+// checks to the application's services. The standard health and reflection services stay open like
+// /healthz.
+
+// TokenVerifier checks a bearer token the way apiWriteAuth does on the HTTP write routes
+type TokenVerifier func(ctx context.Context, raw string) error
+
+func internal(method string) bool {
+	return strings.HasPrefix(method, "/grpc.")
+}
+
+// IPFilter rejects peers the IP allow and deny lists reject on HTTP. The peer is the direct client,
+// the gRPC port is not behind the ingress.
+func IPFilter(cfg ipfilter.Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if internal(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		var ip net.IP
+		if p, ok := peer.FromContext(ctx); ok {
+			if addr, ok := p.Addr.(*net.TCPAddr); ok {
+				ip = addr.IP
+			}
+		}
+		if !cfg.Allows(ip) {
+			slog.Warn("rpc rejected by ip filter", "ip", ip, "method", info.FullMethod)
+			return nil, status.Error(codes.PermissionDenied, "access from this address is not allowed")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// WriteAuth requires a bearer token accepted by verify in the authorization metadata of methods
+func WriteAuth(verify TokenVerifier, methods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !slices.Contains(methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		raw, found := strings.CutPrefix(first(ctx, "authorization"), "Bearer ")
+		if !found || raw == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+		if err := verify(ctx, raw); err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// WriteMethods are the RPCs that change data, guarded by WriteAuth and ReadOnly
+var WriteMethods = []string{samplev1.SampleService_CreateSample_FullMethodName}
+
+// ReadOnly rejects methods with Unavailable while read-only mode is on, like readonly.Middleware on HTTP
+func ReadOnly(methods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if readonly.Enabled() && slices.Contains(methods, info.FullMethod) {
+			return nil, status.Error(codes.Unavailable, "the service is read-only for maintenance, retry later")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// APIKeys meters RPCs presenting an API key in x-api-key against the key's quotas like apikey.Middleware.
+// RPCs without a key pass untouched, an unknown or revoked key is rejected.
+func APIKeys(keys *service.APIKeyService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		secret := first(ctx, "x-api-key")
+		if internal(info.FullMethod) || secret == "" {
+			return handler(ctx, req)
+		}
+
+		key, err := keys.Authenticate(ctx, secret)
+		switch {
+		case errors.Is(err, service.ErrInvalidAPIKey):
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		case err != nil:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		quota, err := keys.Consume(ctx, key, time.Now())
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			return nil, status.Error(codes.ResourceExhausted, "api key quota for the "+quota.Window+" is exceeded")
+		}
+		if err != nil {
+			// Metering must not take the API down with it
+			slog.Error("failed to count api key usage", "key_id", key.ID, "error", err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func first(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
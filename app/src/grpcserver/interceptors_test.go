@@ -0,0 +1,88 @@
+package grpcserver
+
+import (
+	samplev1 "app/gen/sample/v1"
+	"app/ipfilter"
+	"app/readonly"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func call(interceptor grpc.UnaryServerInterceptor, ctx context.Context, method string) codes.Code {
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, any) (any, error) {
+		return nil, nil
+	})
+	return status.Code(err)
+}
+
+func TestWriteAuth(t *testing.T) {
+	interceptor := WriteAuth(func(_ context.Context, raw string) error {
+		if raw != "valid" {
+			return errors.New("invalid")
+		}
+		return nil
+	}, samplev1.SampleService_CreateSample_FullMethodName)
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		want   codes.Code
+	}{
+		{"read without a token", context.Background(), samplev1.SampleService_GetSample_FullMethodName, codes.OK},
+		{"write without a token", context.Background(), samplev1.SampleService_CreateSample_FullMethodName, codes.Unauthenticated},
+		{"write with an invalid token", withToken("forged"), samplev1.SampleService_CreateSample_FullMethodName, codes.Unauthenticated},
+		{"write with a valid token", withToken("valid"), samplev1.SampleService_CreateSample_FullMethodName, codes.OK},
+	}
+	for _, tt := range tests {
+		if got := call(interceptor, tt.ctx, tt.method); got != tt.want {
+			t.Errorf("%s: code = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	deny, _ := ipfilter.ParseCIDRs([]string{"203.0.113.0/24"})
+	interceptor := IPFilter(ipfilter.Config{Deny: deny})
+	from := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 5000}})
+	}
+
+	if got := call(interceptor, from("203.0.113.7"), samplev1.SampleService_GetSample_FullMethodName); got != codes.PermissionDenied {
+		t.Errorf("denied peer: code = %s, want PermissionDenied", got)
+	}
+	if got := call(interceptor, from("203.0.113.7"), "/grpc.health.v1.Health/Check"); got != codes.OK {
+		t.Errorf("health check from a denied peer: code = %s, want OK", got)
+	}
+	if got := call(interceptor, from("198.51.100.1"), samplev1.SampleService_GetSample_FullMethodName); got != codes.OK {
+		t.Errorf("allowed peer: code = %s, want OK", got)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	interceptor := ReadOnly(WriteMethods...)
+	readonly.SetEnabled(true)
+	t.Cleanup(func() { readonly.SetEnabled(false) })
+
+	if got := call(interceptor, context.Background(), samplev1.SampleService_CreateSample_FullMethodName); got != codes.Unavailable {
+		t.Errorf("write in read-only mode: code = %s, want Unavailable", got)
+	}
+	if got := call(interceptor, context.Background(), samplev1.SampleService_GetSample_FullMethodName); got != codes.OK {
+		t.Errorf("read in read-only mode: code = %s, want OK", got)
+	}
+	readonly.SetEnabled(false)
+	if got := call(interceptor, context.Background(), samplev1.SampleService_CreateSample_FullMethodName); got != codes.OK {
+		t.Errorf("write with read-only mode off: code = %s, want OK", got)
+	}
+}
@@ -0,0 +1,80 @@
+package grpcserver
+
+import (
+	samplev1 "app/gen/sample/v1"
+	"app/model"
+	"app/service"
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	samplePageSizeDefault = 20
+	samplePageSizeMax     = 100
+)
+
+// SampleServer implements sample.v1.SampleService on top of the same service as the HTTP API
+type SampleServer struct {
+	samplev1.UnimplementedSampleServiceServer
+	SampleService service.SampleService
+}
+
+func (s *SampleServer) GetSample(ctx context.Context, req *samplev1.GetSampleRequest) (*samplev1.GetSampleResponse, error) {
+	sample, err := s.SampleService.FindSample(ctx, req.GetId())
+	if err != nil {
+		return nil, sampleStatus(err)
+	}
+	return &samplev1.GetSampleResponse{Sample: toProto(sample)}, nil
+}
+
+func (s *SampleServer) ListSamples(ctx context.Context, req *samplev1.ListSamplesRequest) (*samplev1.ListSamplesResponse, error) {
+	size := int(req.GetPageSize())
+	if size <= 0 {
+		size = samplePageSizeDefault
+	}
+	size = min(size, samplePageSizeMax)
+
+	page, err := s.SampleService.SampleCursorPage(ctx, req.GetPageToken(), size, nil)
+	if err != nil {
+		return nil, sampleStatus(err)
+	}
+	res := &samplev1.ListSamplesResponse{NextPageToken: page.NextCursor}
+	for _, sample := range page.Samples {
+		res.Samples = append(res.Samples, toProto(sample))
+	}
+	return res, nil
+}
+
+func (s *SampleServer) CreateSample(ctx context.Context, req *samplev1.CreateSampleRequest) (*samplev1.CreateSampleResponse, error) {
+	if req.GetMessage() == "" {
+		return nil, status.Error(codes.InvalidArgument, "message is required")
+	}
+	sample, err := s.SampleService.CreateSample(ctx, req.GetMessage())
+	if err != nil {
+		return nil, sampleStatus(err)
+	}
+	return &samplev1.CreateSampleResponse{Sample: toProto(sample)}, nil
+}
+
+func toProto(sample model.Sample) *samplev1.Sample {
+	return &samplev1.Sample{
+		Id:         sample.ID,
+		Message:    sample.Message,
+		CreateTime: timestamppb.New(sample.CreatedAt),
+		UpdateTime: timestamppb.New(sample.UpdatedAt),
+	}
+}
+
+func sampleStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrSampleNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,104 @@
+package health
+
+import (
+	"app/metrics"
+	"app/runtimeutil"
+	"context"
+	"maps"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	checkUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "health",
+		Name:      "check_up",
+		Help:      "Whether the dependency check passed on its last run.",
+	}, []string{"check"})
+
+	checkLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "health",
+		Name:      "check_latency_seconds",
+		Help:      "Latency of the last dependency check.",
+	}, []string{"check"})
+)
+
+// StartBackground runs every check on interval and caches the results, so readiness probes
+// read the cache instead of hitting the dependencies on every probe.
+func (r *Registry) StartBackground(ctx context.Context, interval time.Duration) {
+	r.mu.Lock()
+	r.cache = map[string]Result{}
+	r.history = map[string]*ring{}
+	r.historySize = int(HistoryWindow/interval) + 1
+	r.mu.Unlock()
+
+	runtimeutil.Go("health checks", func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			report := r.Run(ctx)
+			r.mu.Lock()
+			r.cache = report.Checks
+			r.record(report.Checks)
+			r.mu.Unlock()
+
+			for name, result := range report.Checks {
+				up := 0.0
+				if result.Status == StatusUp {
+					up = 1
+				}
+				checkUp.WithLabelValues(name).Set(up)
+				checkLatency.WithLabelValues(name).Set(result.LatencyMs / 1000)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Cached returns the last background results, reporting checks that have not run yet as down
+// so a pod never turns ready before its dependencies were verified once.
+// It returns false when background checking is not running.
+func (r *Registry) Cached() (Report, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cache == nil {
+		return Report{}, false
+	}
+
+	results := make(map[string]Result, len(r.checks))
+	for name, check := range r.checks {
+		result, ok := r.cache[name]
+		if !ok {
+			result = Result{Status: StatusDown, Critical: check.Critical, Error: "not checked yet"}
+		}
+		results[name] = result
+	}
+	return Summarize(results), true
+}
+
+// Recheck runs the named check now and caches its result, for a check whose state is known to have
+// changed, so readiness does not wait for the next background run
+func (r *Registry) Recheck(ctx context.Context, name string) {
+	r.mu.RLock()
+	check, ok := r.checks[name]
+	running := r.cache != nil
+	r.mu.RUnlock()
+	if !ok || !running {
+		return
+	}
+	result := RunCheck(ctx, check)
+	r.mu.Lock()
+	r.cache = maps.Clone(r.cache)
+	r.cache[name] = result
+	r.mu.Unlock()
+}
@@ -0,0 +1,169 @@
+package health
+
+import (
+	"app/runtimeutil"
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+type Check struct {
+	Name string
+	// Critical checks take the pod out of the Service when they fail, others only degrade it
+	Critical bool
+	// Fallback describes what is served instead while the check fails. A critical check with
+	// a fallback degrades the pod rather than taking it out of the Service.
+	Fallback string
+	Timeout  time.Duration
+	Run      CheckFunc
+}
+
+type Result struct {
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	Fallback  string    `json:"fallback,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]Check
+	cache  map[string]Result
+	// history holds the background results of the last HistoryWindow per check
+	history     map[string]*ring
+	historySize int
+}
+
+func NewRegistry() *Registry {
+	return &Registry{checks: map[string]Check{}}
+}
+
+// Default is the registry subsystems register their checkers with
+var Default = NewRegistry()
+
+func Register(check Check) {
+	Default.Register(check)
+}
+
+func (r *Registry) Register(check Check) {
+	if check.Timeout <= 0 {
+		check.Timeout = 2 * time.Second
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[check.Name] = check
+}
+
+func (r *Registry) Checks() []Check {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	checks := make([]Check, 0, len(r.checks))
+	for _, check := range r.checks {
+		checks = append(checks, check)
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Name < checks[j].Name })
+	return checks
+}
+
+// RunCheck executes one check with its timeout
+func RunCheck(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := check.Run(ctx)
+	result := Result{
+		Status:    StatusUp,
+		Critical:  check.Critical,
+		LatencyMs: float64(time.Since(started).Microseconds()) / 1000,
+		CheckedAt: started,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+		result.Fallback = check.Fallback
+	}
+	return result
+}
+
+// Run executes every check concurrently
+func (r *Registry) Run(ctx context.Context) Report {
+	checks := r.Checks()
+	results := make(map[string]Result, len(checks))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		runtimeutil.GoWait("health check", &wg, func() {
+			// Kept when the check panics
+			result := Result{Status: StatusDown, Critical: check.Critical, Error: "check panicked", Fallback: check.Fallback, CheckedAt: time.Now()}
+			defer func() {
+				mu.Lock()
+				results[check.Name] = result
+				mu.Unlock()
+			}()
+			result = RunCheck(ctx, check)
+		})
+	}
+	wg.Wait()
+
+	return Summarize(results)
+}
+
+// Summarize derives the overall state: down if a critical check without a fallback fails,
+// degraded if any other does
+func Summarize(results map[string]Result) Report {
+	status := StatusUp
+	for _, result := range results {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical && result.Fallback == "" {
+			status = StatusDown
+		} else if status == StatusUp {
+			status = StatusDegraded
+		}
+	}
+	return Report{Status: status, Checks: results}
+}
+
+// ReadyHandler reports per-dependency status, answering 503 only when a critical dependency is down.
+// Cached background results are served when available.
+func (r *Registry) ReadyHandler() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		report, ok := r.Cached()
+		if !ok {
+			report = r.Run(ctx.Request().Context())
+		}
+		code := http.StatusOK
+		if report.Status == StatusDown {
+			code = http.StatusServiceUnavailable
+		}
+		return ctx.JSON(code, report)
+	}
+}
+
+// LiveHandler only reports that the process is serving requests, dependencies must not restart the pod
+func LiveHandler(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]string{"status": StatusUp})
+}
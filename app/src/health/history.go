@@ -0,0 +1,87 @@
+package health
+
+import "time"
+
+// HistoryWindow is how far back the results of the background checks are kept
+const HistoryWindow = time.Hour
+
+// Sample is one past result of a check
+type Sample struct {
+	Status    string    `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ring keeps the last len(samples) results of a check, overwriting the oldest
+type ring struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+func newRing(size int) *ring {
+	return &ring{samples: make([]Sample, size)}
+}
+
+func (r *ring) add(sample Sample) {
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// since returns the samples checked after cutoff, oldest first
+func (r *ring) since(cutoff time.Time) []Sample {
+	ordered := r.samples[:r.next]
+	if r.full {
+		ordered = append(append([]Sample{}, r.samples[r.next:]...), r.samples[:r.next]...)
+	}
+	samples := make([]Sample, 0, len(ordered))
+	for _, sample := range ordered {
+		if sample.CheckedAt.After(cutoff) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// record adds a background run to the history, r.mu must be locked
+func (r *Registry) record(results map[string]Result) {
+	for name, result := range results {
+		history, ok := r.history[name]
+		if !ok {
+			history = newRing(r.historySize)
+			r.history[name] = history
+		}
+		history.add(Sample{Status: result.Status, LatencyMs: result.LatencyMs, Error: result.Error, CheckedAt: result.CheckedAt})
+	}
+}
+
+// History returns the results of the last HistoryWindow per check, oldest first. It is empty
+// when background checking is not running.
+func (r *Registry) History() map[string][]Sample {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cutoff := time.Now().Add(-HistoryWindow)
+	history := make(map[string][]Sample, len(r.history))
+	for name, samples := range r.history {
+		history[name] = samples.since(cutoff)
+	}
+	return history
+}
+
+// Availability is the share of samples that were up, 1 without samples
+func Availability(samples []Sample) float64 {
+	if len(samples) == 0 {
+		return 1
+	}
+	up := 0
+	for _, sample := range samples {
+		if sample.Status == StatusUp {
+			up++
+		}
+	}
+	return float64(up) / float64(len(samples))
+}
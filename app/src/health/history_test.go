@@ -0,0 +1,25 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRingKeepsLastWindow(t *testing.T) {
+	r := newRing(3)
+	start := time.Now().Add(-2 * HistoryWindow)
+	for i, status := range []string{StatusUp, StatusDown, StatusUp, StatusDown, StatusUp} {
+		r.add(Sample{Status: status, CheckedAt: start.Add(time.Duration(i) * HistoryWindow / 2)})
+	}
+	// Five samples half a window apart: three fit the ring, two of those are in the window
+	samples := r.since(time.Now().Add(-HistoryWindow + time.Minute))
+	if len(samples) != 2 || samples[0].Status != StatusDown || samples[1].Status != StatusUp {
+		t.Fatalf("samples = %+v", samples)
+	}
+	if got := Availability(samples); got != 0.5 {
+		t.Errorf("availability = %v, want 0.5", got)
+	}
+	if got := Availability(nil); got != 1 {
+		t.Errorf("availability without samples = %v, want 1", got)
+	}
+}
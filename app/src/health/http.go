@@ -0,0 +1,28 @@
+package health
+
+import (
+	"app/httpclient"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// HTTPCheck succeeds when a GET to url answers with a non-5xx status
+func HTTPCheck(url string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		res, err := httpclient.Default.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		if res.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("unexpected status %d", res.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,82 @@
+package heartbeat
+
+import (
+	"app/config"
+	"app/httpclient"
+	"app/metrics"
+	"app/scheduler"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "heartbeat",
+		Name:      "requests_total",
+		Help:      "Synthetic heartbeat requests by result and status code.",
+	}, []string{"result", "code"})
+
+	latency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "heartbeat",
+		Name:      "latency_seconds",
+		Help:      "Latency of synthetic heartbeat requests.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	lastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "heartbeat",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful heartbeat.",
+	})
+)
+
+// Start periodically calls HEARTBEAT_URL, typically the app's own Service DNS name
+// (http://app.<namespace>.svc.cluster.local:8080/), so the metrics show the path through kube-proxy.
+func Start(ctx context.Context) {
+	url := config.String("HEARTBEAT_URL", "")
+	if url == "" {
+		return
+	}
+
+	client := httpclient.New(config.Duration("HEARTBEAT_TIMEOUT", 5*time.Second))
+	scheduler.Every(ctx, "heartbeat", config.Duration("HEARTBEAT_INTERVAL", 30*time.Second), func(ctx context.Context) error {
+		return beat(ctx, client, url)
+	})
+}
+
+func beat(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "k8s-sample-app-heartbeat")
+
+	started := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		requests.WithLabelValues("error", "").Inc()
+		return fmt.Errorf("heartbeat failed: %w", err)
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	latency.Observe(time.Since(started).Seconds())
+
+	code := strconv.Itoa(res.StatusCode)
+	if res.StatusCode >= http.StatusBadRequest {
+		requests.WithLabelValues("failure", code).Inc()
+		return fmt.Errorf("heartbeat returned status %d", res.StatusCode)
+	}
+	requests.WithLabelValues("success", code).Inc()
+	lastSuccess.SetToCurrentTime()
+	return nil
+}
@@ -0,0 +1,373 @@
+package httpclient
+
+import (
+	"app/metrics"
+	"app/runtimeutil"
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "httpclient",
+		Name:      "cache_requests_total",
+		Help:      "Outbound requests by cache, which is the client name, and result: hit, stale, stale_if_error, revalidated, miss or bypass.",
+	}, []string{"cache", "result"})
+
+	cacheEntries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "httpclient",
+		Name:      "cache_entries",
+		Help:      "Responses held by the cache.",
+	}, []string{"cache"})
+)
+
+// cacheMaxBody is the largest response body kept, bigger ones pass through uncached
+const cacheMaxBody = 1 << 20
+
+// credentialHeaders identify the caller, a response to a request with one of them is only cached and
+// served to other callers when the upstream marks it public
+var credentialHeaders = []string{"Authorization", "X-API-Key", "X-Service-Token"}
+
+// revalidateTimeout bounds a background revalidation, which outlives the request that started it
+const revalidateTimeout = 30 * time.Second
+
+// Cache is a private HTTP cache for GET responses, honoring the upstream's Cache-Control (RFC 9111):
+// max-age, no-store, no-cache, must-revalidate and the RFC 5861 extensions stale-while-revalidate,
+// answering from the cache while refreshing it in the background, and stale-if-error, answering
+// from the cache when the upstream fails. Stale entries are revalidated with ETag and Last-Modified.
+// The key is the URL alone, so requests carrying credentials only share public responses.
+type Cache struct {
+	// Name labels the cache metrics
+	Name string
+	Next http.RoundTripper
+	// MaxEntries evicts the least recently used responses beyond it, 0 keeps all
+	MaxEntries int
+
+	mu           sync.Mutex
+	entries      map[string]*list.Element
+	lru          *list.List
+	revalidating map[string]bool
+	// now is time.Now unless a test sets it
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	key        string
+	status     int
+	header     http.Header
+	body       []byte
+	vary       map[string]string
+	storedAt   time.Time
+	public     bool
+	maxAge     time.Duration
+	swr        time.Duration
+	staleError time.Duration
+}
+
+// NewCached returns New's client with a Cache of maxEntries responses in front of its transport
+func NewCached(name string, timeout time.Duration, maxEntries int) *http.Client {
+	client := New(timeout)
+	client.Transport = &Cache{Name: name, Next: client.Transport, MaxEntries: maxEntries}
+	return client
+}
+
+func (c *Cache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return c.Next.RoundTrip(req)
+	}
+	key := req.URL.String()
+	requestDirectives := parseCacheControl(req.Header.Get("Cache-Control"))
+	_, noCache := requestDirectives["no-cache"]
+
+	entry := c.lookup(key, req)
+	if entry != nil && !noCache {
+		age := c.clock().Sub(entry.storedAt)
+		switch {
+		case age < entry.maxAge:
+			cacheRequests.WithLabelValues(c.Name, "hit").Inc()
+			return c.response(entry, req), nil
+		case age < entry.maxAge+entry.swr:
+			cacheRequests.WithLabelValues(c.Name, "stale").Inc()
+			c.revalidate(req, entry)
+			return c.response(entry, req), nil
+		}
+	}
+
+	res, err := c.Next.RoundTrip(conditional(req, entry))
+	if entry != nil && (err != nil || res.StatusCode >= http.StatusInternalServerError) &&
+		c.clock().Sub(entry.storedAt) < entry.maxAge+entry.staleError {
+		if res != nil {
+			res.Body.Close()
+		}
+		cacheRequests.WithLabelValues(c.Name, "stale_if_error").Inc()
+		return c.response(entry, req), nil
+	}
+	if err != nil {
+		cacheRequests.WithLabelValues(c.Name, "miss").Inc()
+		return nil, err
+	}
+	if entry != nil && res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		cacheRequests.WithLabelValues(c.Name, "revalidated").Inc()
+		return c.response(c.refresh(entry, res), req), nil
+	}
+	if noCache || credentialed(req) && !public(res.Header) {
+		cacheRequests.WithLabelValues(c.Name, "bypass").Inc()
+	} else {
+		cacheRequests.WithLabelValues(c.Name, "miss").Inc()
+	}
+	return c.store(key, req, res), nil
+}
+
+func credentialed(req *http.Request) bool {
+	for _, name := range credentialHeaders {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func public(header http.Header) bool {
+	_, ok := parseCacheControl(header.Get("Cache-Control"))["public"]
+	return ok
+}
+
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// lookup returns the entry for key when the request matches the headers its response varies on and,
+// with credentials, when the response is public
+func (c *Cache) lookup(key string, req *http.Request) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if credentialed(req) && !entry.public {
+		return nil
+	}
+	for name, value := range entry.vary {
+		if req.Header.Get(name) != value {
+			return nil
+		}
+	}
+	c.lru.MoveToFront(element)
+	return entry
+}
+
+// conditional asks the upstream whether the stale entry is still current
+func conditional(req *http.Request, entry *cacheEntry) *http.Request {
+	if entry == nil {
+		return req
+	}
+	etag, modified := entry.header.Get("ETag"), entry.header.Get("Last-Modified")
+	if etag == "" && modified == "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if modified != "" {
+		req.Header.Set("If-Modified-Since", modified)
+	}
+	return req
+}
+
+// revalidate refreshes entry in the background, once at a time per key
+func (c *Cache) revalidate(req *http.Request, entry *cacheEntry) {
+	c.mu.Lock()
+	if c.revalidating[entry.key] {
+		c.mu.Unlock()
+		return
+	}
+	if c.revalidating == nil {
+		c.revalidating = map[string]bool{}
+	}
+	c.revalidating[entry.key] = true
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), revalidateTimeout)
+	req = conditional(req.Clone(ctx), entry)
+	runtimeutil.Go("httpclient revalidate", func() {
+		defer cancel()
+		defer func() {
+			c.mu.Lock()
+			delete(c.revalidating, entry.key)
+			c.mu.Unlock()
+		}()
+		res, err := c.Next.RoundTrip(req)
+		if err != nil {
+			return
+		}
+		if res.StatusCode == http.StatusNotModified {
+			res.Body.Close()
+			c.refresh(entry, res)
+			return
+		}
+		if res.StatusCode >= http.StatusInternalServerError {
+			// Keep serving the stale entry, stale-if-error decides for how long
+			res.Body.Close()
+			return
+		}
+		res = c.store(entry.key, req, res)
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	})
+}
+
+// refresh restarts the freshness of entry from a 304 and the headers it updates
+func (c *Cache) refresh(entry *cacheEntry, res *http.Response) *cacheEntry {
+	header := entry.header.Clone()
+	for name, values := range res.Header {
+		header[name] = values
+	}
+	refreshed := *entry
+	refreshed.header = header
+	refreshed.storedAt = c.clock()
+	refreshed.public = public(header)
+	refreshed.maxAge, refreshed.swr, refreshed.staleError, _ = freshness(header)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = &refreshed
+	}
+	return &refreshed
+}
+
+// store keeps res when it is cacheable and returns it with the body readable again
+func (c *Cache) store(key string, req *http.Request, res *http.Response) *http.Response {
+	maxAge, swr, staleError, cacheable := freshness(res.Header)
+	if !cacheable || credentialed(req) && !public(res.Header) || res.StatusCode != http.StatusOK || res.Header.Get("Vary") == "*" {
+		return res
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, cacheMaxBody+1))
+	if err != nil || len(body) > cacheMaxBody {
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), res.Body), res.Body}
+		return res
+	}
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &cacheEntry{
+		key:        key,
+		status:     res.StatusCode,
+		header:     res.Header.Clone(),
+		body:       body,
+		vary:       map[string]string{},
+		storedAt:   c.clock(),
+		public:     public(res.Header),
+		maxAge:     maxAge,
+		swr:        swr,
+		staleError: staleError,
+	}
+	for _, name := range strings.Split(res.Header.Get("Vary"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			entry.vary[name] = req.Header.Get(name)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]*list.Element{}
+		c.lru = list.New()
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+	} else {
+		c.entries[key] = c.lru.PushFront(entry)
+	}
+	for c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	cacheEntries.WithLabelValues(c.Name).Set(float64(c.lru.Len()))
+	return res
+}
+
+func (c *Cache) response(e *cacheEntry, req *http.Request) *http.Response {
+	header := e.header.Clone()
+	header.Set("Age", strconv.Itoa(int(c.clock().Sub(e.storedAt).Seconds())))
+	return &http.Response{
+		Status:        strconv.Itoa(e.status) + " " + http.StatusText(e.status),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// freshness reads how long a response may be served from the cache, less the Age it already has.
+// Responses without max-age or with no-store or no-cache are not cached. Private ones are, the cache
+// belongs to one client of the process, but not when the request carried credentials: then only
+// public ones are, see credentialHeaders.
+func freshness(header http.Header) (maxAge, swr, staleError time.Duration, cacheable bool) {
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	for _, name := range []string{"no-store", "no-cache"} {
+		if _, ok := directives[name]; ok {
+			return 0, 0, 0, false
+		}
+	}
+	seconds := func(name string) (time.Duration, bool) {
+		value, err := strconv.Atoi(directives[name])
+		if err != nil || value < 0 {
+			return 0, false
+		}
+		return time.Duration(value) * time.Second, true
+	}
+	maxAge, cacheable = seconds("max-age")
+	if !cacheable {
+		return 0, 0, 0, false
+	}
+	if age, err := strconv.Atoi(header.Get("Age")); err == nil && age > 0 {
+		maxAge = max(maxAge-time.Duration(age)*time.Second, 0)
+	}
+	// must-revalidate forbids serving a stale response, whatever the extensions say
+	if _, ok := directives["must-revalidate"]; !ok {
+		swr, _ = seconds("stale-while-revalidate")
+		staleError, _ = seconds("stale-if-error")
+	}
+	return maxAge, swr, staleError, true
+}
+
+// parseCacheControl splits a Cache-Control header into lower-cased directives and their values
+func parseCacheControl(raw string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(raw, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
@@ -0,0 +1,220 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// upstream answers with the Cache-Control and status it is set to, numbering its responses in the
+// body, and 304 to a matching If-None-Match
+type upstream struct {
+	*httptest.Server
+	requests     atomic.Int64
+	mu           sync.Mutex
+	cacheControl string
+	status       int
+	vary         string
+}
+
+func newUpstream(t *testing.T, cacheControl string) *upstream {
+	u := &upstream{cacheControl: cacheControl, status: http.StatusOK}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := u.requests.Add(1)
+		u.mu.Lock()
+		cacheControl, status, vary := u.cacheControl, u.status, u.vary
+		u.mu.Unlock()
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", `"v1"`)
+		if vary != "" {
+			w.Header().Set("Vary", vary)
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` && status == http.StatusOK {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, strconv.FormatInt(n, 10))
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *upstream) set(cacheControl string, status int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.cacheControl, u.status = cacheControl, status
+}
+
+func newTestCache(maxEntries int) (*http.Client, *fakeClock) {
+	clock := newFakeClock()
+	return &http.Client{Transport: &Cache{Name: "test", Next: http.DefaultTransport, MaxEntries: maxEntries, now: clock.now}}, clock
+}
+
+// get returns the status and body of a GET of url with the headers given as name, value pairs
+func get(t *testing.T, client *http.Client, url string, header ...string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(body)
+}
+
+func TestCacheServesFreshResponses(t *testing.T) {
+	u := newUpstream(t, "max-age=60")
+	client, clock := newTestCache(0)
+
+	if _, body := get(t, client, u.URL); body != "1" {
+		t.Fatalf("first GET = %q, want the upstream's first response", body)
+	}
+	clock.advance(59 * time.Second)
+	if _, body := get(t, client, u.URL); body != "1" || u.requests.Load() != 1 {
+		t.Errorf("GET within max-age = %q after %d upstream requests, want the cached response", body, u.requests.Load())
+	}
+
+	// Stale without stale-while-revalidate: the ETag revalidates it and the 304 restarts the max-age
+	clock.advance(2 * time.Second)
+	if _, body := get(t, client, u.URL); body != "1" || u.requests.Load() != 2 {
+		t.Errorf("GET past max-age = %q after %d upstream requests, want one revalidation", body, u.requests.Load())
+	}
+	clock.advance(30 * time.Second)
+	if get(t, client, u.URL); u.requests.Load() != 2 {
+		t.Errorf("upstream requests = %d, want the revalidated entry fresh again", u.requests.Load())
+	}
+
+	if get(t, client, u.URL, "Cache-Control", "no-cache"); u.requests.Load() != 3 {
+		t.Errorf("upstream requests = %d, want a no-cache request sent upstream", u.requests.Load())
+	}
+}
+
+func TestCacheSkipsUncacheableResponses(t *testing.T) {
+	for _, cacheControl := range []string{"no-store", "no-cache, max-age=60", "public", "max-age=60, must-revalidate, no-store"} {
+		u := newUpstream(t, cacheControl)
+		client, _ := newTestCache(0)
+		get(t, client, u.URL)
+		if _, body := get(t, client, u.URL); body != "2" {
+			t.Errorf("Cache-Control %q: second GET = %q, want it sent upstream", cacheControl, body)
+		}
+	}
+
+	u := newUpstream(t, "max-age=60")
+	u.set("max-age=60", http.StatusNotFound)
+	client, _ := newTestCache(0)
+	get(t, client, u.URL)
+	if _, body := get(t, client, u.URL); body != "2" {
+		t.Errorf("404: second GET = %q, want only 200s cached", body)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	u := newUpstream(t, "max-age=10, stale-while-revalidate=60")
+	u.mu.Lock()
+	u.vary = "Accept"
+	u.mu.Unlock()
+	client, clock := newTestCache(0)
+	get(t, client, u.URL)
+
+	clock.advance(30 * time.Second)
+	if _, body := get(t, client, u.URL); body != "1" {
+		t.Errorf("stale GET = %q, want the stale response while it is revalidated", body)
+	}
+	deadline := time.Now().Add(time.Second)
+	for u.requests.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if u.requests.Load() != 2 {
+		t.Fatalf("upstream requests = %d, want a background revalidation", u.requests.Load())
+	}
+
+	// The response varies on Accept, another Accept is a miss
+	if _, body := get(t, client, u.URL, "Accept", "text/plain"); body != "3" {
+		t.Errorf("GET with another Accept = %q, want it sent upstream", body)
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	u := newUpstream(t, "max-age=10, stale-if-error=60")
+	client, clock := newTestCache(0)
+	get(t, client, u.URL)
+
+	u.set("no-store", http.StatusServiceUnavailable)
+	clock.advance(30 * time.Second)
+	if status, body := get(t, client, u.URL); status != http.StatusOK || body != "1" {
+		t.Errorf("upstream failing within stale-if-error: %d %q, want the cached 200", status, body)
+	}
+	clock.advance(time.Minute)
+	if status, _ := get(t, client, u.URL); status != http.StatusServiceUnavailable {
+		t.Errorf("upstream failing past stale-if-error: status %d, want its 503", status)
+	}
+}
+
+func TestCacheEvictsTheLeastRecentlyUsed(t *testing.T) {
+	u := newUpstream(t, "max-age=60")
+	client, _ := newTestCache(2)
+	get(t, client, u.URL+"/a")
+	get(t, client, u.URL+"/b")
+	get(t, client, u.URL+"/a")
+	get(t, client, u.URL+"/c")
+
+	if _, body := get(t, client, u.URL+"/a"); body != "1" {
+		t.Errorf("/a = %q, want it cached as the most recently used", body)
+	}
+	if _, body := get(t, client, u.URL+"/b"); body != "4" {
+		t.Errorf("/b = %q, want it evicted as the least recently used", body)
+	}
+}
+
+func TestCacheKeepsCredentialedResponsesPrivate(t *testing.T) {
+	for _, name := range credentialHeaders {
+		u := newUpstream(t, "max-age=60")
+		client, _ := newTestCache(0)
+
+		get(t, client, u.URL, name, "alice")
+		if _, body := get(t, client, u.URL); body != "2" {
+			t.Errorf("%s: GET without credentials = %q, want the response to alice not served", name, body)
+		}
+		if _, body := get(t, client, u.URL, name, "bob"); body != "3" {
+			t.Errorf("%s: GET as bob = %q, want the cached response not served", name, body)
+		}
+	}
+
+	u := newUpstream(t, "public, max-age=60")
+	client, _ := newTestCache(0)
+	get(t, client, u.URL, "Authorization", "Bearer alice")
+	if _, body := get(t, client, u.URL, "Authorization", "Bearer bob"); body != "1" {
+		t.Errorf("public response: GET as bob = %q, want the cached response", body)
+	}
+}
@@ -0,0 +1,236 @@
+package httpclient
+
+import (
+	"app/metrics"
+	"app/runtimeutil"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	hedgeRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "httpclient",
+		Name:      "hedge_requests_total",
+		Help:      "Hedgeable requests by client and result: fast (answered before the delay), primary_won, hedge_won or skipped (over the hedge budget).",
+	}, []string{"client", "result"})
+
+	hedgeLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "httpclient",
+		Name:      "hedge_latency_seconds",
+		Help:      "Latency of hedgeable requests as seen by the caller, by the attempt that answered. Compare its tail with hedge_delay_seconds.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"client", "winner"})
+
+	hedgeDelay = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "httpclient",
+		Name:      "hedge_delay_seconds",
+		Help:      "Current delay before a second attempt is sent, the Quantile of recent latencies.",
+	}, []string{"client"})
+)
+
+// hedgeWindow is how many recent latencies the delay is computed from, hedgeRecompute how often
+const (
+	hedgeWindow    = 256
+	hedgeRecompute = 32
+)
+
+// Hedge sends a second attempt of a GET or HEAD that has not been answered after the Quantile of
+// recent latencies, and returns whichever answers first, cancelling the other. The tail caused by
+// one slow pod or a lost packet is cut to about the delay plus a typical request. Failures are not
+// hedged, retries are the caller's, and a budget caps the extra load when the whole upstream slows down.
+type Hedge struct {
+	// Name labels the hedge metrics
+	Name string
+	Next http.RoundTripper
+	// Quantile of recent latencies to wait before hedging, 0.95 sends about one request in twenty twice
+	Quantile float64
+	// MinDelay and MaxDelay bound the delay, MaxDelay is used until enough latencies are known
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// MaxRatio is the most hedges sent per request over time, bursts of up to ten are allowed
+	MaxRatio float64
+
+	delay atomic.Int64
+
+	mu       sync.Mutex
+	window   [hedgeWindow]time.Duration
+	observed int
+	tokens   float64
+}
+
+// NewHedge returns a Hedge in front of next with the p95 as its delay
+func NewHedge(name string, next http.RoundTripper) *Hedge {
+	return &Hedge{Name: name, Next: next, Quantile: 0.95, MinDelay: 5 * time.Millisecond, MaxDelay: time.Second, MaxRatio: 0.1}
+}
+
+var errAttemptPanicked = errors.New("hedged round trip panicked")
+
+type attempt struct {
+	res   *http.Response
+	err   error
+	index int
+	took  time.Duration
+}
+
+func (h *Hedge) RoundTrip(req *http.Request) (*http.Response, error) {
+	hedgeable := req.Method == http.MethodGet || req.Method == http.MethodHead
+	if !hedgeable || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return h.Next.RoundTrip(req)
+	}
+	start := time.Now()
+	// Buffered, attempts answering after the first one never block
+	results := make(chan attempt, 2)
+	var cancels []context.CancelFunc
+	send := func(hedge bool) error {
+		ctx, cancel := context.WithCancel(req.Context())
+		attemptReq := req.Clone(ctx)
+		if hedge && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			attemptReq.Body = body
+		}
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		runtimeutil.Go("httpclient hedge attempt", func() {
+			// Sent when the transport panics, the loop below waits for every attempt
+			result := attempt{err: errAttemptPanicked, index: index}
+			defer func() { results <- result }()
+			sent := time.Now()
+			res, err := h.Next.RoundTrip(attemptReq)
+			result = attempt{res: res, err: err, index: index, took: time.Since(sent)}
+		})
+		return nil
+	}
+
+	h.spend()
+	send(false)
+	timer := time.NewTimer(h.currentDelay())
+	defer timer.Stop()
+	pending, hedged, skipped := 1, false, false
+	for {
+		select {
+		case <-timer.C:
+			if !h.take() {
+				skipped = true
+				continue
+			}
+			if send(true) == nil {
+				pending++
+				hedged = true
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil && hedged && pending > 0 {
+				// The other attempt may still answer
+				cancels[result.index]()
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != result.index {
+					cancel()
+				}
+			}
+			// Close the bodies of the attempts answering after this one
+			runtimeutil.Go("httpclient hedge drain", func() {
+				for range pending {
+					if late := <-results; late.res != nil {
+						late.res.Body.Close()
+					}
+				}
+			})
+			if result.err != nil {
+				cancels[result.index]()
+				return nil, result.err
+			}
+			outcome := "fast"
+			switch {
+			case result.index == 1:
+				outcome = "hedge_won"
+			case hedged:
+				outcome = "primary_won"
+			case skipped:
+				outcome = "skipped"
+			}
+			h.observe(time.Since(start), result.took, outcome, result.index == 1)
+			result.res.Body = &cancelBody{ReadCloser: result.res.Body, cancel: cancels[result.index]}
+			return result.res, nil
+		}
+	}
+}
+
+// cancelBody releases the attempt's context once the caller is done with the response
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (h *Hedge) currentDelay() time.Duration {
+	if delay := h.delay.Load(); delay > 0 {
+		return time.Duration(delay)
+	}
+	return h.MaxDelay
+}
+
+// spend earns a fraction of a hedge for every request, take uses a whole one
+func (h *Hedge) spend() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens = min(h.tokens+h.MaxRatio, 10)
+}
+
+func (h *Hedge) take() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	return true
+}
+
+// observe records the latency seen by the caller, and the one of the answering attempt alone for the delay:
+// a won hedge counted from the primary's start would keep the quantile at the delay once hedges exceed it
+func (h *Hedge) observe(latency, attemptLatency time.Duration, outcome string, hedgeWon bool) {
+	winner := "primary"
+	if hedgeWon {
+		winner = "hedge"
+	}
+	hedgeRequests.WithLabelValues(h.Name, outcome).Inc()
+	hedgeLatency.WithLabelValues(h.Name, winner).Observe(latency.Seconds())
+
+	h.mu.Lock()
+	h.window[h.observed%hedgeWindow] = attemptLatency
+	h.observed++
+	if h.observed%hedgeRecompute != 0 {
+		h.mu.Unlock()
+		return
+	}
+	recent := slices.Clone(h.window[:min(h.observed, hedgeWindow)])
+	h.mu.Unlock()
+
+	slices.Sort(recent)
+	delay := min(max(recent[int(float64(len(recent)-1)*h.Quantile)], h.MinDelay), h.MaxDelay)
+	h.delay.Store(int64(delay))
+	hedgeDelay.WithLabelValues(h.Name).Set(delay.Seconds())
+}
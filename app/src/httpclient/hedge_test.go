@@ -0,0 +1,181 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// trackedBody records whether the response body it belongs to was closed
+type trackedBody struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+// fakeUpstream answers attempt i, counted from 0, after delays[i] with the attempt number as the body,
+// or with errs[i] when it is set. The delay ignores cancellation, like a response
+// already on its way.
+type fakeUpstream struct {
+	delays []time.Duration
+	errs   []error
+
+	mu     sync.Mutex
+	calls  int
+	bodies []*trackedBody
+}
+
+func (u *fakeUpstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	u.mu.Lock()
+	i := u.calls
+	u.calls++
+	u.mu.Unlock()
+	if i < len(u.delays) {
+		time.Sleep(u.delays[i])
+	}
+	if i < len(u.errs) && u.errs[i] != nil {
+		return nil, u.errs[i]
+	}
+	body := &trackedBody{Reader: strings.NewReader(string(rune('0' + i)))}
+	u.mu.Lock()
+	u.bodies = append(u.bodies, body)
+	u.mu.Unlock()
+	return &http.Response{StatusCode: http.StatusOK, Body: body, Request: req}, nil
+}
+
+func (u *fakeUpstream) callCount() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.calls
+}
+
+// loserClosed reports whether the second answer's body was closed
+func (u *fakeUpstream) loserClosed() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.bodies) == 2 && u.bodies[1].closed.Load()
+}
+
+func newTestHedge(next http.RoundTripper) *Hedge {
+	return &Hedge{Name: "test", Next: next, Quantile: 0.95, MinDelay: time.Millisecond, MaxDelay: 20 * time.Millisecond, MaxRatio: 1}
+}
+
+func roundTrip(t *testing.T, h *Hedge, method string, body io.Reader) string {
+	t.Helper()
+	req, _ := http.NewRequest(method, "http://upstream/samples", body)
+	res, err := h.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	return string(data)
+}
+
+func TestHedgeSendsASecondAttemptForASlowPrimary(t *testing.T) {
+	upstream := &fakeUpstream{delays: []time.Duration{200 * time.Millisecond, 0}}
+	h := newTestHedge(upstream)
+
+	started := time.Now()
+	if body := roundTrip(t, h, http.MethodGet, nil); body != "1" {
+		t.Errorf("body = %q, want the hedge's answer", body)
+	}
+	if took := time.Since(started); took >= 200*time.Millisecond {
+		t.Errorf("took %s, want the hedge answering before the slow primary", took)
+	}
+
+	// The primary answers after the hedge won, its body is closed for the caller who never sees it
+	deadline := time.Now().Add(time.Second)
+	for !upstream.loserClosed() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !upstream.loserClosed() {
+		t.Error("the body of the primary answering after the hedge was not closed")
+	}
+}
+
+func TestHedgeSkipsFastAndUnsafeRequests(t *testing.T) {
+	upstream := &fakeUpstream{}
+	h := newTestHedge(upstream)
+	if body := roundTrip(t, h, http.MethodGet, nil); body != "0" || upstream.callCount() != 1 {
+		t.Errorf("fast GET: body %q after %d calls, want the primary's answer alone", body, upstream.callCount())
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		upstream := &fakeUpstream{delays: []time.Duration{50 * time.Millisecond}}
+		h := newTestHedge(upstream)
+		roundTrip(t, h, method, strings.NewReader(`{"message":"hello"}`))
+		if upstream.callCount() != 1 {
+			t.Errorf("slow %s: %d calls, want it never hedged", method, upstream.callCount())
+		}
+	}
+}
+
+func TestHedgeBudget(t *testing.T) {
+	upstream := &fakeUpstream{delays: []time.Duration{50 * time.Millisecond}}
+	h := newTestHedge(upstream)
+	h.MaxRatio = 0.1
+	// One request earns a tenth of a hedge, not enough to send one
+	if body := roundTrip(t, h, http.MethodGet, nil); body != "0" || upstream.callCount() != 1 {
+		t.Errorf("over the budget: body %q after %d calls, want the primary's answer alone", body, upstream.callCount())
+	}
+}
+
+func TestHedgeOutlivesAFailedAttempt(t *testing.T) {
+	// The primary fails after the hedge was sent, the hedge's answer is returned instead
+	upstream := &fakeUpstream{delays: []time.Duration{40 * time.Millisecond, 80 * time.Millisecond}, errs: []error{errors.New("connection reset")}}
+	h := newTestHedge(upstream)
+	if body := roundTrip(t, h, http.MethodGet, nil); body != "1" {
+		t.Errorf("body = %q, want the hedge's answer after the primary failed", body)
+	}
+
+	upstream = &fakeUpstream{errs: []error{errors.New("connection refused")}}
+	h = newTestHedge(upstream)
+	req, _ := http.NewRequest(http.MethodGet, "http://upstream/samples", nil)
+	if _, err := h.RoundTrip(req); err == nil || upstream.callCount() != 1 {
+		t.Errorf("failing primary: err = %v after %d calls, want its error without a hedge", err, upstream.callCount())
+	}
+}
+
+func TestHedgeDelayFollowsTheQuantile(t *testing.T) {
+	h := newTestHedge(&fakeUpstream{})
+	if delay := h.currentDelay(); delay != h.MaxDelay {
+		t.Fatalf("delay before any latency = %s, want MaxDelay", delay)
+	}
+	for i := range hedgeRecompute {
+		h.observe(0, time.Duration(i+1)*100*time.Microsecond, "fast", false)
+	}
+	// The p95 of 0.1ms to 3.2ms is 3ms
+	if delay := h.currentDelay(); delay != 3*time.Millisecond {
+		t.Errorf("delay = %s, want the p95 of the recent latencies", delay)
+	}
+	for range hedgeRecompute {
+		h.observe(0, time.Second, "fast", false)
+	}
+	if delay := h.currentDelay(); delay != h.MaxDelay {
+		t.Errorf("delay = %s, want it capped at MaxDelay", delay)
+	}
+}
+
+type panickingTransport struct{}
+
+func (panickingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	panic("transport bug")
+}
+
+func TestHedgeRecoversAPanickingTransport(t *testing.T) {
+	h := newTestHedge(panickingTransport{})
+	req, _ := http.NewRequest(http.MethodGet, "http://upstream/samples", nil)
+	if _, err := h.RoundTrip(req); !errors.Is(err, errAttemptPanicked) {
+		t.Errorf("err = %v, want the panic reported as an error", err)
+	}
+}
@@ -0,0 +1,33 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// New returns the client every outbound call should use. Its transport starts a client
+// span and injects the W3C traceparent and baggage headers of the request context,
+// and dials through DefaultResolver when DNS caching is enabled.
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: otelhttp.NewTransport(transport()),
+	}
+}
+
+// transport is shared by every client, so they share one connection pool like http.DefaultTransport's
+var transport = sync.OnceValue(func() http.RoundTripper {
+	if DefaultResolver == nil {
+		return http.DefaultTransport
+	}
+	resolving := http.DefaultTransport.(*http.Transport).Clone()
+	resolving.DialContext = DefaultResolver.DialContext
+	DefaultResolver.CloseIdle = resolving.CloseIdleConnections
+	return resolving
+})
+
+// Default is shared by callers without a timeout of their own, requests are bounded by their context
+var Default = New(0)
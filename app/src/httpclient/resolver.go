@@ -0,0 +1,211 @@
+package httpclient
+
+import (
+	"app/config"
+	"app/metrics"
+	"app/runtimeutil"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	dnsLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "httpclient",
+		Name:      "dns_lookups_total",
+		Help:      "Outbound dials by how the host was resolved: hit from the cache, miss, refresh in the background or error.",
+	}, []string{"result"})
+
+	dnsAddresses = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "httpclient",
+		Name:      "dns_addresses",
+		Help:      "Addresses cached per host, the ready pods of a headless Service.",
+	}, []string{"host"})
+)
+
+// Resolver caches the addresses of the hosts dialed and re-resolves them every Refresh in the background,
+// so dials skip the lookup and pods added or removed by a scale event are picked up within Refresh.
+// A headless Service resolves to every ready pod, new connections are spread across them round robin.
+type Resolver struct {
+	Refresh time.Duration
+	// Lookup is net.DefaultResolver.LookupHost, replaceable for tests
+	Lookup func(ctx context.Context, host string) ([]string, error)
+	Dialer *net.Dialer
+	// CloseIdle closes the idle connections of the transport dialing through the resolver. It is
+	// called when a refresh finds other addresses, so kept-alive connections are spread again instead
+	// of staying on the pods there were before a scale event.
+	CloseIdle func()
+
+	mu    sync.Mutex
+	hosts map[string]*resolvedHost
+	start sync.Once
+}
+
+type resolvedHost struct {
+	addresses []string
+	next      atomic.Uint32
+	usedAt    atomic.Int64
+}
+
+// DefaultResolver is used by New when HTTPCLIENT_DNS_REFRESH is set, nil otherwise
+var DefaultResolver = resolverFromEnv()
+
+func resolverFromEnv() *Resolver {
+	refresh := config.Duration("HTTPCLIENT_DNS_REFRESH", 0)
+	if refresh <= 0 {
+		return nil
+	}
+	slog.Info("outbound dns caching is enabled", "refresh", refresh)
+	return &Resolver{Refresh: refresh}
+}
+
+// DialContext dials a cached address of the host, trying the others when it fails.
+// A host whose addresses all fail is resolved again instead of waiting for the refresh.
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return r.dialer().DialContext(ctx, network, address)
+	}
+	r.start.Do(func() { runtimeutil.Go("httpclient dns refresh", r.refreshLoop) })
+
+	resolved, err := r.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	resolved.usedAt.Store(time.Now().UnixNano())
+	offset := resolved.next.Add(1)
+	var errs []error
+	for i := range resolved.addresses {
+		ip := resolved.addresses[(int(offset)+i)%len(resolved.addresses)]
+		conn, err := r.dialer().DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	r.forget(host)
+	return nil, errors.Join(errs...)
+}
+
+func (r *Resolver) dialer() *net.Dialer {
+	if r.Dialer != nil {
+		return r.Dialer
+	}
+	return &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+}
+
+func (r *Resolver) lookup(ctx context.Context, host string) ([]string, error) {
+	if r.Lookup != nil {
+		return r.Lookup(ctx, host)
+	}
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
+func (r *Resolver) resolve(ctx context.Context, host string) (*resolvedHost, error) {
+	r.mu.Lock()
+	resolved, ok := r.hosts[host]
+	r.mu.Unlock()
+	if ok {
+		dnsLookups.WithLabelValues("hit").Inc()
+		return resolved, nil
+	}
+
+	addresses, err := r.lookup(ctx, host)
+	if err != nil || len(addresses) == 0 {
+		dnsLookups.WithLabelValues("error").Inc()
+		if err == nil {
+			err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+		}
+		return nil, err
+	}
+	dnsLookups.WithLabelValues("miss").Inc()
+	return r.set(host, addresses), nil
+}
+
+func (r *Resolver) set(host string, addresses []string) *resolvedHost {
+	resolved := &resolvedHost{addresses: addresses}
+	resolved.usedAt.Store(time.Now().UnixNano())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.hosts == nil {
+		r.hosts = map[string]*resolvedHost{}
+	}
+	if previous, ok := r.hosts[host]; ok {
+		resolved.usedAt.Store(previous.usedAt.Load())
+	}
+	r.hosts[host] = resolved
+	dnsAddresses.WithLabelValues(host).Set(float64(len(addresses)))
+	return resolved
+}
+
+func (r *Resolver) forget(host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.hosts, host)
+	dnsAddresses.DeleteLabelValues(host)
+}
+
+func (r *Resolver) refreshLoop() {
+	ticker := time.NewTicker(r.Refresh)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.refresh()
+	}
+}
+
+// refresh re-resolves the cached hosts, dropping the ones not dialed for ten refreshes.
+// A failed lookup keeps the previous addresses, a DNS outage does not stop the dials.
+func (r *Resolver) refresh() {
+	r.mu.Lock()
+	hosts := make(map[string]*resolvedHost, len(r.hosts))
+	for host, resolved := range r.hosts {
+		hosts[host] = resolved
+	}
+	r.mu.Unlock()
+
+	changed := false
+	for host, resolved := range hosts {
+		if time.Since(time.Unix(0, resolved.usedAt.Load())) > 10*r.Refresh {
+			r.forget(host)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), r.Refresh)
+		addresses, err := r.lookup(ctx, host)
+		cancel()
+		if err != nil || len(addresses) == 0 {
+			dnsLookups.WithLabelValues("error").Inc()
+			slog.Warn("failed to refresh dns", "host", host, "error", err)
+			continue
+		}
+		dnsLookups.WithLabelValues("refresh").Inc()
+		if !sameAddresses(resolved.addresses, addresses) {
+			changed = true
+		}
+		r.set(host, addresses)
+	}
+	if changed && r.CloseIdle != nil {
+		r.CloseIdle()
+	}
+}
+
+// sameAddresses compares the address sets, DNS servers rotate the order of the answers
+func sameAddresses(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,175 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeDNS answers Lookup with the addresses set for a host and counts the lookups
+type fakeDNS struct {
+	mu        sync.Mutex
+	addresses map[string][]string
+	err       error
+	lookups   int
+}
+
+func (d *fakeDNS) lookup(ctx context.Context, host string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lookups++
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.addresses[host], nil
+}
+
+func (d *fakeDNS) set(host string, addresses []string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addresses[host], d.err = addresses, err
+}
+
+var errRefused = errors.New("refused by the test")
+
+// testResolver records the host of each dial and refuses dials while refuse is set
+type testResolver struct {
+	*Resolver
+	mu     sync.Mutex
+	dialed []string
+	refuse bool
+	closed int
+}
+
+func newTestResolver(dns *fakeDNS) *testResolver {
+	tr := &testResolver{}
+	tr.Resolver = &Resolver{
+		Refresh: time.Minute,
+		Lookup:  dns.lookup,
+		Dialer: &net.Dialer{Control: func(network, address string, c syscall.RawConn) error {
+			tr.mu.Lock()
+			defer tr.mu.Unlock()
+			tr.dialed = append(tr.dialed, address)
+			if tr.refuse {
+				return errRefused
+			}
+			return nil
+		}},
+		CloseIdle: func() { tr.closed++ },
+	}
+	// The background loop would race the refreshes the tests run themselves
+	tr.start.Do(func() {})
+	return tr
+}
+
+func (tr *testResolver) dialedHosts() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var hosts []string
+	for _, address := range tr.dialed {
+		host, _, _ := net.SplitHostPort(address)
+		hosts = append(hosts, host)
+	}
+	tr.dialed = nil
+	return hosts
+}
+
+func TestResolverRotatesAddresses(t *testing.T) {
+	// Listening on every address accepts the dials to each loopback address below
+	listener, err := net.Listen("tcp4", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	dns := &fakeDNS{addresses: map[string][]string{"app": {"127.0.0.1", "127.0.0.2", "127.0.0.3"}}}
+	r := newTestResolver(dns)
+	ctx := t.Context()
+	for range 3 {
+		conn, err := r.DialContext(ctx, "tcp", net.JoinHostPort("app", port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if got := r.dialedHosts(); !slices.Equal(got, []string{"127.0.0.2", "127.0.0.3", "127.0.0.1"}) || dns.lookups != 1 {
+		t.Errorf("dialed %v after %d lookups, want one lookup and the dials spread round robin", got, dns.lookups)
+	}
+
+	// Every address failing tries each once, and the host is resolved again on the next dial
+	r.refuse = true
+	if _, err := r.DialContext(ctx, "tcp", net.JoinHostPort("app", port)); !errors.Is(err, errRefused) {
+		t.Fatalf("dial: err = %v, want the dialer's refusal", err)
+	}
+	if got := r.dialedHosts(); len(got) != 3 {
+		t.Errorf("dialed %v, want each address tried", got)
+	}
+	r.DialContext(ctx, "tcp", net.JoinHostPort("app", port))
+	if dns.lookups != 2 {
+		t.Errorf("lookups = %d, want the host resolved again after all its addresses failed", dns.lookups)
+	}
+
+	r.DialContext(ctx, "tcp", "127.0.0.1:1")
+	if dns.lookups != 2 {
+		t.Errorf("lookups = %d, want an IP dialed without a lookup", dns.lookups)
+	}
+}
+
+func TestResolverRefresh(t *testing.T) {
+	dns := &fakeDNS{addresses: map[string][]string{"app": {"10.0.0.1", "10.0.0.2"}}}
+	r := newTestResolver(dns)
+	if _, err := r.resolve(t.Context(), "app"); err != nil {
+		t.Fatal(err)
+	}
+	addresses := func() []string {
+		resolved, _ := r.resolve(t.Context(), "app")
+		return resolved.addresses
+	}
+
+	// The same pods in another order are no scale event
+	dns.set("app", []string{"10.0.0.2", "10.0.0.1"}, nil)
+	r.refresh()
+	if r.closed != 0 {
+		t.Errorf("idle connections closed %d times, want none for the same addresses", r.closed)
+	}
+
+	dns.set("app", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, nil)
+	r.refresh()
+	if got := addresses(); len(got) != 3 || r.closed != 1 {
+		t.Errorf("after a scale up: addresses %v with idle connections closed %d times, want the new pod and them closed once", got, r.closed)
+	}
+
+	// A failed lookup keeps the previous addresses
+	dns.set("app", nil, &net.DNSError{Err: "server misbehaving", Name: "app"})
+	r.refresh()
+	if got := addresses(); len(got) != 3 || r.closed != 1 {
+		t.Errorf("after a failed lookup: addresses %v with idle connections closed %d times, want the previous ones kept", got, r.closed)
+	}
+
+	// A host not dialed for ten refreshes is dropped
+	r.Resolver.hosts["app"].usedAt.Store(time.Now().Add(-11 * time.Minute).UnixNano())
+	r.refresh()
+	if _, ok := r.Resolver.hosts["app"]; ok {
+		t.Error("a host not dialed for ten refreshes is still cached")
+	}
+
+	dns.set("missing", nil, nil)
+	if _, err := r.resolve(t.Context(), "missing"); err == nil {
+		t.Error("a host without addresses resolved")
+	}
+}
@@ -0,0 +1,88 @@
+package httpclient
+
+import (
+	"app/config"
+	"app/metrics"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	retryAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "httpclient",
+		Name:      "retries_total",
+		Help:      "Outbound retries by budget and result: allowed, or exhausted when the budget refused it.",
+	}, []string{"budget", "result"})
+
+	retryTokens = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "httpclient",
+		Name:      "retry_budget_tokens",
+		Help:      "Retries the budget would allow right now, it drains while an upstream is failing.",
+	}, []string{"budget"})
+)
+
+// RetryBudget caps retries at Ratio of the requests sent, plus a Burst for the quiet periods.
+// Shared by every client of the process, retrying cannot multiply the load of an upstream that
+// is down by the retry count: once the tokens are spent only first attempts are sent.
+type RetryBudget struct {
+	// Name labels the budget metrics
+	Name  string
+	Ratio float64
+	Burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	primed bool
+}
+
+// DefaultRetryBudget is shared by the outbound clients, nil when HTTPCLIENT_RETRY_BUDGET_RATIO is 0
+var DefaultRetryBudget = retryBudgetFromEnv()
+
+func retryBudgetFromEnv() *RetryBudget {
+	ratio := config.Float("HTTPCLIENT_RETRY_BUDGET_RATIO", 0.2)
+	if ratio <= 0 {
+		return nil
+	}
+	return &RetryBudget{Name: "default", Ratio: ratio, Burst: config.Float("HTTPCLIENT_RETRY_BUDGET_BURST", 10)}
+}
+
+// Deposit earns Ratio of a retry, called once per request before any retry of it
+func (b *RetryBudget) Deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prime()
+	b.tokens = min(b.tokens+b.Ratio, b.Burst)
+	retryTokens.WithLabelValues(b.Name).Set(b.tokens)
+}
+
+// Withdraw reports whether a retry may be sent, using up one token when it may
+func (b *RetryBudget) Withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prime()
+	if b.tokens < 1 {
+		retryAttempts.WithLabelValues(b.Name, "exhausted").Inc()
+		return false
+	}
+	b.tokens--
+	retryTokens.WithLabelValues(b.Name).Set(b.tokens)
+	retryAttempts.WithLabelValues(b.Name, "allowed").Inc()
+	return true
+}
+
+// prime starts a budget with its full Burst
+func (b *RetryBudget) prime() {
+	if !b.primed {
+		b.tokens, b.primed = b.Burst, true
+	}
+}
@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+const localizerKey = "i18n.localizer"
+
+var bundle = newBundle()
+
+func newBundle() *goi18n.Bundle {
+	b := goi18n.NewBundle(language.English)
+	b.RegisterUnmarshalFunc("json", json.Unmarshal)
+
+	files, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, file := range files {
+		if _, err := b.LoadMessageFileFS(localeFS, "locales/"+file.Name()); err != nil {
+			panic(err)
+		}
+	}
+	return b
+}
+
+// Middleware attaches a localizer chosen from the ?lang query and Accept-Language header
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			localizer := goi18n.NewLocalizer(bundle, ctx.QueryParam("lang"), ctx.Request().Header.Get("Accept-Language"))
+			ctx.Set(localizerKey, localizer)
+			return next(ctx)
+		}
+	}
+}
+
+func localizer(ctx echo.Context) *goi18n.Localizer {
+	if l, ok := ctx.Get(localizerKey).(*goi18n.Localizer); ok {
+		return l
+	}
+	return goi18n.NewLocalizer(bundle, ctx.Request().Header.Get("Accept-Language"))
+}
+
+// T translates messageID for the request, falling back to the ID itself when it is unknown
+func T(ctx echo.Context, messageID string) string {
+	return Translate(ctx, messageID, nil)
+}
+
+func Translate(ctx echo.Context, messageID string, data map[string]any) string {
+	message, err := localizer(ctx).Localize(&goi18n.LocalizeConfig{
+		MessageID:    messageID,
+		TemplateData: data,
+	})
+	if err != nil {
+		slog.Warn("missing translation", "id", messageID, "error", err)
+		return messageID
+	}
+	return message
+}
+
+// Lang returns the language tag the request is being served in
+func Lang(ctx echo.Context) string {
+	_, tag, err := localizer(ctx).LocalizeWithTag(&goi18n.LocalizeConfig{MessageID: "lang"})
+	if err != nil {
+		return language.English.String()
+	}
+	return tag.String()
+}
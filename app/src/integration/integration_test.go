@@ -0,0 +1,257 @@
+//go:build integration
+
+// Package integration runs the built app against real MySQL, and Redis when INTEGRATION_REDIS=true,
+// started with testcontainers-go. Run it with `go test -tags integration ./integration/...`, docker is required.
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mysql"
+	"github.com/testcontainers/testcontainers-go/modules/redis"
+)
+
+// baseURL is where the app started by TestMain serves the API
+var baseURL string
+
+var redisEnabled = os.Getenv("INTEGRATION_REDIS") == "true"
+
+func TestMain(m *testing.M) {
+	code, err := run(m)
+	if err != nil {
+		log.Print(err)
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// run starts the containers and the app, and tears everything down once the tests finish
+func run(m *testing.M) (int, error) {
+	ctx := context.Background()
+	tmp, err := os.MkdirTemp("", "app-integration")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmp)
+
+	mysqlContainer, err := mysql.Run(ctx, "mysql:8.4",
+		mysql.WithDatabase("app"),
+		mysql.WithUsername("app"),
+		mysql.WithPassword("app"),
+	)
+	defer testcontainers.TerminateContainer(mysqlContainer)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start mysql: %w", err)
+	}
+	dsn, err := mysqlContainer.ConnectionString(ctx, "parseTime=true", "charset=utf8mb4")
+	if err != nil {
+		return 0, err
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return 0, err
+	}
+	env := append(os.Environ(),
+		"DATABASE_URI="+dsn,
+		"HTTP_ADDR=127.0.0.1:"+strconv.Itoa(port),
+		"STORAGE_BACKEND=local",
+		"STORAGE_DIR="+filepath.Join(tmp, "storage"),
+		"HEALTH_CHECK_INTERVAL=1s",
+	)
+
+	if redisEnabled {
+		redisContainer, err := redis.Run(ctx, "redis:7")
+		defer testcontainers.TerminateContainer(redisContainer)
+		if err != nil {
+			return 0, fmt.Errorf("failed to start redis: %w", err)
+		}
+		url, err := redisContainer.ConnectionString(ctx)
+		if err != nil {
+			return 0, err
+		}
+		env = append(env, "REDIS_URL="+url)
+	}
+
+	// The real binary is built so main's wiring and migrations are exercised as deployed
+	binary := filepath.Join(tmp, "app")
+	build := exec.Command("go", "build", "-o", binary, "app")
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		return 0, fmt.Errorf("failed to build app: %w", err)
+	}
+
+	var logs bytes.Buffer
+	app := exec.Command(binary)
+	app.Env = env
+	app.Stdout, app.Stderr = &logs, &logs
+	if err := app.Start(); err != nil {
+		return 0, err
+	}
+	defer func() {
+		app.Process.Signal(os.Interrupt)
+		app.Wait()
+	}()
+
+	baseURL = fmt.Sprintf("http://127.0.0.1:%d", port)
+	if err := waitReady(ctx, 2*time.Minute); err != nil {
+		os.Stderr.Write(logs.Bytes())
+		return 0, err
+	}
+
+	code := m.Run()
+	if code != 0 {
+		os.Stderr.Write(logs.Bytes())
+	}
+	return code, nil
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// waitReady polls /readyz until every dependency is up
+func waitReady(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		resp, err := http.Get(baseURL + "/readyz")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("app did not become ready: %w", ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// do sends a JSON request and decodes the response into out unless it is nil
+func do(t *testing.T, method, path string, body any, out any) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, baseURL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+type sample struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+func TestReadiness(t *testing.T) {
+	var report struct {
+		Status string                     `json:"status"`
+		Checks map[string]json.RawMessage `json:"checks"`
+	}
+	if status := do(t, http.MethodGet, "/readyz", nil, &report); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+
+	want := []string{"database"}
+	if redisEnabled {
+		want = append(want, "redis")
+	}
+	for _, name := range want {
+		if _, ok := report.Checks[name]; !ok {
+			t.Errorf("missing %s check", name)
+		}
+	}
+}
+
+func TestSampleLifecycle(t *testing.T) {
+	var created sample
+	if status := do(t, http.MethodPost, "/sample", map[string]string{"message": "integration"}, &created); status != http.StatusCreated {
+		t.Fatalf("create: status = %d", status)
+	}
+	if created.ID == "" || created.Message != "integration" {
+		t.Fatalf("create: got %+v", created)
+	}
+	path := "/sample/" + created.ID
+
+	var fetched sample
+	if status := do(t, http.MethodGet, path, nil, &fetched); status != http.StatusOK || fetched != created {
+		t.Fatalf("get: status = %d, got %+v", status, fetched)
+	}
+
+	var updated sample
+	if status := do(t, http.MethodPut, path, map[string]string{"message": "updated"}, &updated); status != http.StatusOK || updated.Message != "updated" {
+		t.Fatalf("update: status = %d, got %+v", status, updated)
+	}
+
+	var page struct {
+		Items []sample `json:"items"`
+	}
+	if status := do(t, http.MethodGet, "/samples?limit=100", nil, &page); status != http.StatusOK {
+		t.Fatalf("list: status = %d", status)
+	}
+	found := false
+	for _, item := range page.Items {
+		found = found || item.ID == created.ID
+	}
+	if !found {
+		t.Errorf("list: %s is missing", created.ID)
+	}
+
+	if status := do(t, http.MethodDelete, path, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete: status = %d", status)
+	}
+	if status := do(t, http.MethodGet, path, nil, nil); status != http.StatusNotFound {
+		t.Errorf("get after delete: status = %d", status)
+	}
+}
+
+func TestCreateSampleValidation(t *testing.T) {
+	var body map[string]string
+	if status := do(t, http.MethodPost, "/sample", map[string]string{}, &body); status != http.StatusBadRequest {
+		t.Fatalf("status = %d", status)
+	}
+	if body["error"] == "" {
+		t.Error("missing error message")
+	}
+}
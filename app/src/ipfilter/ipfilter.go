@@ -0,0 +1,89 @@
+package ipfilter
+
+import (
+	"app/config"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Config struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// Enabled reports whether any rule is configured
+func (c Config) Enabled() bool {
+	return len(c.Allow) > 0 || len(c.Deny) > 0
+}
+
+// ConfigFromEnv reads <prefix>ALLOW_CIDRS and <prefix>DENY_CIDRS
+func ConfigFromEnv(prefix string) (Config, error) {
+	allow, err := ParseCIDRs(config.List(prefix + "ALLOW_CIDRS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("%sALLOW_CIDRS: %w", prefix, err)
+	}
+	deny, err := ParseCIDRs(config.List(prefix + "DENY_CIDRS"))
+	if err != nil {
+		return Config{}, fmt.Errorf("%sDENY_CIDRS: %w", prefix, err)
+	}
+	return Config{Allow: allow, Deny: deny}, nil
+}
+
+// ParseCIDRs accepts CIDR notation as well as bare addresses
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", value)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", value)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether ip matches no deny rule and, when allow rules exist, one of them
+func (c Config) Allows(ip net.IP) bool {
+	return ip != nil && !contains(c.Deny, ip) && (len(c.Allow) == 0 || contains(c.Allow, ip))
+}
+
+// Middleware rejects clients matching a deny rule, or not matching any allow rule when allow rules exist.
+// The client address comes from c.RealIP(), so trusted proxies are configured on the router's IPExtractor.
+func Middleware(cfg Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !cfg.Allows(net.ParseIP(ctx.RealIP())) {
+				slog.Warn("request rejected by ip filter", "ip", ctx.RealIP(), "path", ctx.Path())
+				return ctx.JSON(http.StatusForbidden, map[string]string{"error": "access from this address is not allowed"})
+			}
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"app/apperrors"
+	"app/db"
+	"app/model"
+	"app/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownType     = apperrors.New(apperrors.Validation, "unknown job type")
+	ErrInvalidPriority = apperrors.New(apperrors.Validation, "priority must be high, normal or low")
+	ErrLeaseLost       = errors.New("job is no longer held by this replica")
+)
+
+// Handler runs one job, a returned error schedules a retry until the attempts are exhausted
+type Handler func(ctx context.Context, job *model.Job) error
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string]Handler{}
+)
+
+func Register(jobType string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[jobType] = handler
+}
+
+// Registered reports whether a handler exists for the job type on this replica
+func Registered(jobType string) bool {
+	_, ok := handlerFor(jobType)
+	return ok
+}
+
+func handlerFor(jobType string) (Handler, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	handler, ok := handlers[jobType]
+	return handler, ok
+}
+
+// Options override the queue defaults for one job
+type Options struct {
+	MaxAttempts int
+	// Priority is one of the model.Priority* levels
+	Priority int
+	// RunAt defers the job, the zero value runs it as soon as a worker is free
+	RunAt time.Time
+}
+
+// ParsePriority maps the API names high/normal/low to the stored levels, "" is normal
+func ParsePriority(name string) (int, error) {
+	switch name {
+	case "high":
+		return model.PriorityHigh, nil
+	case "", "normal":
+		return model.PriorityNormal, nil
+	case "low":
+		return model.PriorityLow, nil
+	}
+	return 0, ErrInvalidPriority
+}
+
+// Enqueue stores the job with its payload encoded as JSON, it runs on whichever replica claims it first
+func Enqueue(ctx context.Context, jobType string, payload any, opts Options) (model.Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return model.Job{}, err
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RunAt.IsZero() {
+		opts.RunAt = time.Now()
+	}
+
+	// The job runs in its own trace, linked back to the enqueuing request
+	var traceContext string
+	if carrier := tracing.Carrier(ctx); len(carrier) > 0 {
+		encodedCarrier, _ := json.Marshal(carrier)
+		traceContext = string(encodedCarrier)
+	}
+
+	job := model.Job{
+		TraceContext: traceContext,
+		Type:         jobType,
+		Payload:      string(encoded),
+		Status:       model.JobPending,
+		Priority:     opts.Priority,
+		RunAt:        opts.RunAt,
+		MaxAttempts:  opts.MaxAttempts,
+	}
+	err = db.DB.WithContext(ctx).Create(&job).Error
+	return job, err
+}
+
+// Decode unmarshals the job payload into v
+func Decode(job *model.Job, v any) error {
+	return json.Unmarshal([]byte(job.Payload), v)
+}
@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"app/model"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ReminderJob is a demo of deferred work, enqueue it with a RunAt such as "in one hour"
+const ReminderJob = "demo.reminder"
+
+type Reminder struct {
+	Message string `json:"message"`
+}
+
+func init() {
+	Register(ReminderJob, func(ctx context.Context, job *model.Job) error {
+		var reminder Reminder
+		if err := Decode(job, &reminder); err != nil {
+			return err
+		}
+		slog.Info("reminder",
+			"message", reminder.Message,
+			"scheduled_for", job.RunAt,
+			"lateness", time.Since(job.RunAt).Round(time.Millisecond),
+		)
+		return nil
+	})
+}
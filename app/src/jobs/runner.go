@@ -0,0 +1,349 @@
+package jobs
+
+import (
+	"app/config"
+	"app/db"
+	"app/metrics"
+	"app/model"
+	"app/runtimeutil"
+	"app/tracing"
+	"app/workerpool"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultMaxAttempts applies to jobs enqueued without their own limit
+var DefaultMaxAttempts = config.Int("JOBS_MAX_ATTEMPTS", 5)
+
+var (
+	processed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "jobs",
+		Name:      "processed_total",
+		Help:      "Job executions by type and outcome (success, retry, dead, interrupted).",
+	}, []string{"type", "result"})
+
+	duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "jobs",
+		Name:      "duration_seconds",
+		Help:      "Job execution time by type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type"})
+)
+
+type Config struct {
+	Enabled      bool
+	PollInterval time.Duration
+	Timeout      time.Duration
+	// A running job whose lock is older than this is assumed lost with its replica and put back
+	LeaseTimeout time.Duration
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	Pool         workerpool.Config
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		Enabled:      config.Bool("JOBS_ENABLED", true),
+		PollInterval: config.Duration("JOBS_POLL_INTERVAL", time.Second),
+		Timeout:      config.Duration("JOBS_TIMEOUT", 5*time.Minute),
+		LeaseTimeout: config.Duration("JOBS_LEASE_TIMEOUT", 15*time.Minute),
+		BackoffBase:  config.Duration("JOBS_BACKOFF_BASE", 10*time.Second),
+		BackoffMax:   config.Duration("JOBS_BACKOFF_MAX", time.Hour),
+		Pool: workerpool.ConfigFromEnv("JOBS_", workerpool.Config{
+			Size:       4,
+			QueueDepth: 0,
+			Policy:     workerpool.Block,
+		}),
+	}
+}
+
+type Runner struct {
+	Config Config
+	Pool   *workerpool.Pool
+	name   string
+
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+// Start polls for due jobs until ctx is cancelled, it returns nil when the runner is disabled
+func Start(ctx context.Context, cfg Config) *Runner {
+	if !cfg.Enabled {
+		slog.Info("job runner is disabled")
+		return nil
+	}
+
+	hostname, _ := os.Hostname()
+	ctx, stop := context.WithCancel(ctx)
+	r := &Runner{
+		Config:  cfg,
+		Pool:    workerpool.New("jobs", cfg.Pool),
+		name:    hostname,
+		stop:    stop,
+		stopped: make(chan struct{}),
+	}
+	runtimeutil.Critical("job runner", func(context.Context) error {
+		r.loop(ctx)
+		return nil
+	})
+	return r
+}
+
+// Shutdown stops claiming jobs and lets the running ones finish until ctx expires.
+// Jobs still running then see their context cancelled and are put back on the queue,
+// without using up an attempt, so another replica picks them up right away.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.stop()
+	<-r.stopped
+
+	err := r.Pool.Shutdown(ctx)
+	if err == nil {
+		slog.Info("job runner drained")
+		return nil
+	}
+
+	count, handOffErr := handOff(r.name)
+	if handOffErr != nil {
+		return fmt.Errorf("failed to hand off interrupted jobs: %w", handOffErr)
+	}
+	slog.Warn("drain timeout reached, interrupted jobs handed off", "count", count)
+	return err
+}
+
+// handOff puts the jobs the runner name is still running back on the queue and gives back their attempt
+func handOff(name string) (int64, error) {
+	result := db.DB.Model(&model.Job{}).
+		Where("status = ? AND locked_by = ?", model.JobRunning, name).
+		Updates(map[string]any{
+			"status":    model.JobPending,
+			"locked_by": "",
+			"locked_at": nil,
+			// CASE rather than GREATEST, which the SQLite database of APP_MODE=mock does not have
+			"attempts": gorm.Expr("CASE WHEN attempts > 0 THEN attempts - 1 ELSE 0 END"),
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (r *Runner) loop(ctx context.Context) {
+	defer close(r.stopped)
+	ticker := time.NewTicker(r.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.releaseExpired(ctx); err != nil {
+			slog.Error("failed to release expired job leases", "error", err)
+		}
+		claimed, err := r.claim(ctx, r.Pool.Config.Size)
+		if err != nil {
+			slog.Error("failed to claim jobs", "error", err)
+			continue
+		}
+		for i := range claimed {
+			job := claimed[i]
+			if err := r.Pool.Submit(ctx, func(poolCtx context.Context) { r.execute(poolCtx, &job) }); err != nil {
+				// Left running, the lease timeout hands it to another replica
+				slog.Warn("claimed job was not started", "id", job.ID, "error", err)
+			}
+		}
+	}
+}
+
+// claim locks up to limit due jobs for this replica. SKIP LOCKED lets every replica poll concurrently.
+func (r *Runner) claim(ctx context.Context, limit int) ([]model.Job, error) {
+	var jobs []model.Job
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", model.JobPending, time.Now()).
+			Order("priority DESC, run_at").
+			Limit(limit).
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(jobs))
+		for i := range jobs {
+			ids[i] = jobs[i].ID
+		}
+		now := time.Now()
+		return tx.Model(&model.Job{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":    model.JobRunning,
+			"locked_by": r.name,
+			"locked_at": now,
+			"attempts":  gorm.Expr("attempts + 1"),
+		}).Error
+	})
+	for i := range jobs {
+		jobs[i].Attempts++
+		jobs[i].LockedBy = r.name
+	}
+	return jobs, err
+}
+
+func (r *Runner) releaseExpired(ctx context.Context) error {
+	result := db.DB.WithContext(ctx).Model(&model.Job{}).
+		Where("status = ? AND locked_at < ?", model.JobRunning, time.Now().Add(-r.Config.LeaseTimeout)).
+		Updates(map[string]any{"status": model.JobPending, "locked_by": "", "locked_at": nil})
+	if result.RowsAffected > 0 {
+		slog.Warn("released expired job leases", "count", result.RowsAffected)
+	}
+	return result.Error
+}
+
+func (r *Runner) execute(poolCtx context.Context, job *model.Job) {
+	ctx, cancel := context.WithTimeout(poolCtx, r.Config.Timeout)
+	defer cancel()
+
+	ctx, span := r.startSpan(ctx, job)
+	started := time.Now()
+	err := r.run(ctx, job)
+	duration.WithLabelValues(job.Type).Observe(time.Since(started).Seconds())
+	tracing.End(span, err)
+
+	// Interrupted by Shutdown, which hands the job off instead of counting a failed attempt
+	if err != nil && poolCtx.Err() != nil {
+		processed.WithLabelValues(job.Type, "interrupted").Inc()
+		slog.Warn("job interrupted by shutdown", "id", job.ID, "type", job.Type)
+		return
+	}
+
+	if err == nil {
+		processed.WithLabelValues(job.Type, "success").Inc()
+		now := time.Now()
+		r.update(job, map[string]any{"status": model.JobDone, "finished_at": now, "last_error": ""})
+		return
+	}
+
+	if job.Attempts >= job.MaxAttempts || errors.Is(err, ErrUnknownType) {
+		processed.WithLabelValues(job.Type, "dead").Inc()
+		slog.Error("job moved to the dead letter table", "id", job.ID, "type", job.Type, "attempts", job.Attempts, "error", err)
+		if err := bury(job, err); err != nil {
+			slog.Error("failed to move job to the dead letter table", "id", job.ID, "error", err)
+		}
+		return
+	}
+
+	processed.WithLabelValues(job.Type, "retry").Inc()
+	delay := r.backoff(job.Attempts)
+	slog.Warn("job failed, retrying", "id", job.ID, "type", job.Type, "attempt", job.Attempts, "retry_in", delay, "error", err)
+	r.update(job, map[string]any{
+		"status":     model.JobPending,
+		"run_at":     time.Now().Add(delay),
+		"last_error": err.Error(),
+		"locked_by":  "",
+		"locked_at":  nil,
+	})
+}
+
+// startSpan opens the job's root span, linked to the trace of the request that enqueued it
+func (r *Runner) startSpan(ctx context.Context, job *model.Job) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("job.id", job.ID),
+			attribute.String("job.type", job.Type),
+			attribute.Int("job.attempt", job.Attempts),
+			attribute.Int("job.priority", job.Priority),
+		),
+	}
+	var carrier map[string]string
+	if job.TraceContext != "" && json.Unmarshal([]byte(job.TraceContext), &carrier) == nil {
+		opts = append(opts, trace.WithLinks(tracing.LinkTo(carrier)))
+	}
+	return tracing.Tracer().Start(ctx, "job "+job.Type, opts...)
+}
+
+func (r *Runner) run(ctx context.Context, job *model.Job) (err error) {
+	handler, ok := handlerFor(job.Type)
+	if !ok {
+		return ErrUnknownType
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// Checkpoint saves the job's progress as its new payload, so a replica that picks up the job
+// after an interruption resumes from there. It fails once this replica no longer holds the job.
+func Checkpoint(ctx context.Context, job *model.Job, progress any) error {
+	encoded, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	result := db.DB.WithContext(context.WithoutCancel(ctx)).Model(&model.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, model.JobRunning, job.LockedBy).
+		Update("payload", string(encoded))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	job.Payload = string(encoded)
+	return nil
+}
+
+// update writes the outcome only while this replica still holds the job
+func (r *Runner) update(job *model.Job, values map[string]any) {
+	err := db.DB.Model(&model.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, model.JobRunning, r.name).
+		Updates(values).Error
+	if err != nil {
+		slog.Error("failed to update job", "id", job.ID, "error", err)
+	}
+}
+
+// backoff doubles the delay per attempt up to BackoffMax, with full jitter to spread retries of a failed batch
+func (r *Runner) backoff(attempt int) time.Duration {
+	delay := r.Config.BackoffBase << min(attempt-1, 30)
+	if delay <= 0 || delay > r.Config.BackoffMax {
+		delay = r.Config.BackoffMax
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// bury moves the job into the dead letter table
+func bury(job *model.Job, cause error) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		dead := model.DeadJob{
+			ID:           job.ID,
+			CreatedAt:    job.CreatedAt,
+			FailedAt:     time.Now(),
+			Type:         job.Type,
+			Payload:      job.Payload,
+			Priority:     job.Priority,
+			Attempts:     job.Attempts,
+			TraceContext: job.TraceContext,
+			LastError:    cause.Error(),
+		}
+		if err := tx.Create(&dead).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Job{}, "id = ?", job.ID).Error
+	})
+}
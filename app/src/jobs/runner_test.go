@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"app/apptest"
+	"app/model"
+	"testing"
+)
+
+func TestHandOff(t *testing.T) {
+	conn := apptest.DB(t, &model.Job{})
+	jobs := []model.Job{
+		{ID: "running", Status: model.JobRunning, LockedBy: "pod-a", Attempts: 2},
+		{ID: "first attempt", Status: model.JobRunning, LockedBy: "pod-a", Attempts: 0},
+		{ID: "other runner", Status: model.JobRunning, LockedBy: "pod-b", Attempts: 1},
+	}
+	if err := conn.Create(&jobs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := handOff("pod-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("handed off %d jobs, want 2", count)
+	}
+	want := map[string]struct {
+		status   string
+		attempts int
+	}{
+		"running":       {model.JobPending, 1},
+		"first attempt": {model.JobPending, 0},
+		"other runner":  {model.JobRunning, 1},
+	}
+	for id, w := range want {
+		var job model.Job
+		if err := conn.First(&job, "id = ?", id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != w.status || job.Attempts != w.attempts {
+			t.Errorf("%s: status %s with %d attempts, want %s with %d", id, job.Status, job.Attempts, w.status, w.attempts)
+		}
+	}
+}
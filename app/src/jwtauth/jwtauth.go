@@ -0,0 +1,134 @@
+package jwtauth
+
+import (
+	"app/config"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	issuer    = "k8s-sample-app"
+	claimsKey = "jwt.claims"
+)
+
+var ErrRevoked = errors.New("token has been revoked")
+
+type Claims struct {
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
+// RevocationChecker reports whether an access token ID has been revoked
+type RevocationChecker func(jti string) (bool, error)
+
+type Signer struct {
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+	AccessTTL  time.Duration
+	IsRevoked  RevocationChecker
+}
+
+// NewFromEnv loads the Ed25519 key pair generated by openssl/genkey.sh, it returns nil when JWT_PRIVATE_KEY is unset
+func NewFromEnv() (*Signer, error) {
+	raw := config.String("JWT_PRIVATE_KEY", "")
+	if raw == "" {
+		slog.Info("JWT_PRIVATE_KEY is not set, jwt authentication is disabled")
+		return nil, nil
+	}
+
+	// genkey.sh writes the PEM into an env file with escaped newlines
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(raw, `\n`, "\n")))
+	if block == nil {
+		return nil, errors.New("JWT_PRIVATE_KEY is not a PEM block")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT_PRIVATE_KEY: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("JWT_PRIVATE_KEY is not an Ed25519 key")
+	}
+
+	return &Signer{
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public().(ed25519.PublicKey),
+		AccessTTL:  config.Duration("JWT_ACCESS_TTL", 15*time.Minute),
+	}, nil
+}
+
+// Issue signs a short lived access token for the user
+func (s *Signer) Issue(userID, username string) (string, Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		Username: username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    issuer,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.AccessTTL)),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(s.PrivateKey)
+	return signed, claims, err
+}
+
+func (s *Signer) Parse(raw string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		return s.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}), jwt.WithIssuer(issuer))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.IsRevoked != nil {
+		revoked, err := s.IsRevoked(claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrRevoked
+		}
+	}
+	return claims, nil
+}
+
+func (s *Signer) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			raw, found := strings.CutPrefix(ctx.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !found || raw == "" {
+				ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="app"`)
+				return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "missing bearer token"})
+			}
+
+			claims, err := s.Parse(raw)
+			if err != nil {
+				ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="app", error="invalid_token"`)
+				return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid bearer token"})
+			}
+			ctx.Set(claimsKey, claims)
+			return next(ctx)
+		}
+	}
+}
+
+// ClaimsFrom returns the verified access token claims, or nil
+func ClaimsFrom(ctx echo.Context) *Claims {
+	claims, _ := ctx.Get(claimsKey).(*Claims)
+	return claims
+}
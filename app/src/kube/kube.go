@@ -0,0 +1,61 @@
+package kube
+
+import (
+	"app/apperrors"
+	"app/config"
+	"log/slog"
+	"os"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+var ErrNotInCluster = apperrors.New(apperrors.Dependency, "not running in a kubernetes cluster")
+
+// Client is nil outside a cluster, callers check Enabled first
+var Client kubernetes.Interface
+
+func Enabled() bool {
+	return Client != nil
+}
+
+// Init builds the client from the pod's service account, it is a no-op outside a cluster
+func Init() {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		slog.Info("kubernetes api is not available", "reason", err)
+		return
+	}
+	cfg.UserAgent = "k8s-sample-app"
+
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		slog.Error("failed to create kubernetes client", "error", err)
+		return
+	}
+	Client = client
+	slog.Info("kubernetes api is available", "namespace", Namespace(), "pod", PodName())
+}
+
+// Namespace is POD_NAMESPACE (downward API) or the service account's namespace
+func Namespace() string {
+	if namespace := config.String("POD_NAMESPACE", ""); namespace != "" {
+		return namespace
+	}
+	if data, err := os.ReadFile(namespaceFile); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return "default"
+}
+
+// PodName is POD_NAME (downward API) or the hostname, which Kubernetes sets to the pod name
+func PodName() string {
+	if name := config.String("POD_NAME", ""); name != "" {
+		return name
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
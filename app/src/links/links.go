@@ -0,0 +1,57 @@
+package links
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Link is one HAL style link, Method tells clients which verb the route expects
+type Link struct {
+	Href   string `json:"href"`
+	Method string `json:"method"`
+}
+
+// Links is the _links object of a resource, keyed by relation
+type Links map[string]Link
+
+// Rel maps a relation to the name of the route it points at
+type Rel struct {
+	Name  string
+	Route string
+}
+
+// HeaderPrefix is set by the reverse proxy when it serves the app under a sub path (nginx serves it under /app)
+const HeaderPrefix = "X-Forwarded-Prefix"
+
+// Build resolves each relation through the router's named routes, filling path parameters
+// in order. Relations whose route is not registered, such as write routes disabled by
+// configuration, are left out so clients only see links they can follow.
+func Build(ctx echo.Context, rels []Rel, params ...any) Links {
+	prefix := strings.TrimSuffix(ctx.Request().Header.Get(HeaderPrefix), "/")
+	routes := ctx.Echo().Routes()
+
+	links := Links{}
+	for _, rel := range rels {
+		for _, route := range routes {
+			if route.Name != rel.Route {
+				continue
+			}
+			links[rel.Name] = Link{
+				Href:   prefix + ctx.Echo().Reverse(route.Name, params...),
+				Method: route.Method,
+			}
+			break
+		}
+	}
+	return links
+}
+
+// WithQuery returns the link with query appended to its href
+func (l Link) WithQuery(query url.Values) Link {
+	if len(query) > 0 {
+		l.Href += "?" + query.Encode()
+	}
+	return l
+}
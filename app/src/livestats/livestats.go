@@ -0,0 +1,135 @@
+package livestats
+
+import (
+	"app/config"
+	"app/db"
+	"app/metrics"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Stats are the request rates of the last Window seconds and the current state of the pod, for
+// dashboards polling the pod directly instead of querying Prometheus
+type Stats struct {
+	GeneratedAt   time.Time `json:"generated_at"`
+	WindowSeconds int       `json:"window_seconds"`
+	RPS           float64   `json:"rps"`
+	InFlight      int64     `json:"in_flight"`
+	Requests      int64     `json:"requests"`
+	// Errors are the 5xx responses, ErrorRate their share of Requests
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	DB        *Pool   `json:"db,omitempty"`
+}
+
+// Pool is the database/sql connection pool, Usage the share of the connections in use
+type Pool struct {
+	MaxOpen        int     `json:"max_open"`
+	Open           int     `json:"open"`
+	InUse          int     `json:"in_use"`
+	Idle           int     `json:"idle"`
+	WaitCount      int64   `json:"wait_count"`
+	WaitDurationMs float64 `json:"wait_duration_ms"`
+	Usage          float64 `json:"usage"`
+}
+
+// Recorder counts requests per second in a ring of Window seconds
+type Recorder struct {
+	inFlight atomic.Int64
+
+	mu      sync.Mutex
+	buckets []bucket
+}
+
+type bucket struct {
+	second   int64
+	requests int64
+	errors   int64
+}
+
+// Default is fed by Middleware and served by Handler
+var Default = New(config.Duration("LIVE_STATS_WINDOW", 10*time.Second))
+
+func New(window time.Duration) *Recorder {
+	seconds := max(int(window/time.Second), 1)
+	// One more bucket for the second in progress, which the rates leave out
+	return &Recorder{buckets: make([]bucket, seconds+1)}
+}
+
+func (r *Recorder) record(now time.Time, failed bool) {
+	second := now.Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := &r.buckets[second%int64(len(r.buckets))]
+	if b.second != second {
+		*b = bucket{second: second}
+	}
+	b.requests++
+	if failed {
+		b.errors++
+	}
+}
+
+// Snapshot sums the complete seconds of the window before now
+func (r *Recorder) Snapshot(now time.Time) Stats {
+	window := len(r.buckets) - 1
+	stats := Stats{GeneratedAt: now, WindowSeconds: window, InFlight: r.inFlight.Load()}
+	current := now.Unix()
+	r.mu.Lock()
+	for _, b := range r.buckets {
+		if b.second < current && b.second >= current-int64(window) {
+			stats.Requests += b.requests
+			stats.Errors += b.errors
+		}
+	}
+	r.mu.Unlock()
+	stats.RPS = float64(stats.Requests) / float64(window)
+	if stats.Requests > 0 {
+		stats.ErrorRate = float64(stats.Errors) / float64(stats.Requests)
+	}
+	return stats
+}
+
+// Middleware counts the requests in flight and the responses per second, 5xx as errors
+func (r *Recorder) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			r.inFlight.Add(1)
+			defer r.inFlight.Add(-1)
+			err := next(ctx)
+
+			r.record(time.Now(), metrics.ResponseStatus(ctx, err) >= http.StatusInternalServerError)
+			return err
+		}
+	}
+}
+
+// Handler serves GET /stats/live, the snapshot with the database pool when one is open.
+// The poll itself is counted like any request.
+func (r *Recorder) Handler(ctx echo.Context) error {
+	stats := r.Snapshot(time.Now())
+	if db.DB != nil {
+		if sqlDB, err := db.DB.DB(); err == nil {
+			s := sqlDB.Stats()
+			pool := &Pool{
+				MaxOpen:        s.MaxOpenConnections,
+				Open:           s.OpenConnections,
+				InUse:          s.InUse,
+				Idle:           s.Idle,
+				WaitCount:      s.WaitCount,
+				WaitDurationMs: float64(s.WaitDuration.Microseconds()) / 1000,
+			}
+			// An unbounded pool is measured against what it opened
+			if capacity := max(pool.MaxOpen, pool.Open); capacity > 0 {
+				pool.Usage = float64(pool.InUse) / float64(capacity)
+			}
+			stats.DB = pool
+		}
+	}
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(http.StatusOK, stats)
+}
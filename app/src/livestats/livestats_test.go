@@ -0,0 +1,35 @@
+package livestats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	r := New(4 * time.Second)
+	start := time.Unix(1_000_000, 0)
+	// Two seconds of 3 requests, one of them failing each time
+	for s := range 2 {
+		at := start.Add(time.Duration(s) * time.Second)
+		r.record(at, true)
+		r.record(at, false)
+		r.record(at, false)
+	}
+	// The second in progress is left out
+	r.record(start.Add(2*time.Second), true)
+
+	stats := r.Snapshot(start.Add(2*time.Second + 500*time.Millisecond))
+	if stats.Requests != 6 || stats.Errors != 2 || stats.RPS != 1.5 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.ErrorRate < 0.33 || stats.ErrorRate > 0.34 {
+		t.Errorf("error rate = %v", stats.ErrorRate)
+	}
+
+	// Seconds older than the window drop out, their buckets are reused
+	later := start.Add(6 * time.Second)
+	r.record(later, false)
+	if stats := r.Snapshot(later.Add(time.Second)); stats.Requests != 1 || stats.Errors != 0 {
+		t.Errorf("after the window = %+v", stats)
+	}
+}
@@ -0,0 +1,170 @@
+package loadgen
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Target   string
+	Method   string
+	RPS      int
+	Duration time.Duration
+	// Concurrency caps requests in flight, requests due while at the cap are counted as dropped
+	Concurrency int
+	Timeout     time.Duration
+}
+
+// Report summarizes a run, latencies are in milliseconds
+type Report struct {
+	Target      string         `json:"target"`
+	Requests    int            `json:"requests"`
+	Errors      int            `json:"errors"`
+	Dropped     int            `json:"dropped"`
+	Statuses    map[string]int `json:"statuses"`
+	ElapsedSecs float64        `json:"elapsed_seconds"`
+	AchievedRPS float64        `json:"achieved_rps"`
+	Latency     Percentiles    `json:"latency_ms"`
+}
+
+type Percentiles struct {
+	Min float64 `json:"min"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	latencies []float64
+	statuses  map[string]int
+	errors    int
+	dropped   int
+}
+
+func (r *recorder) record(latency time.Duration, status string, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, float64(latency.Microseconds())/1000)
+	r.statuses[status]++
+	if failed {
+		r.errors++
+	}
+}
+
+// Run sends requests at a fixed rate until the duration is over or ctx is cancelled.
+// The rate is kept whatever the response times (open loop), so a slow target shows up as
+// growing latency rather than as fewer requests, which is what users see during a scale-out.
+// progress, when set, receives a snapshot every interval while the run lasts.
+func Run(ctx context.Context, client *http.Client, cfg Config, progress func(Report)) Report {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	rec := &recorder{statuses: map[string]int{}}
+	slots := make(chan struct{}, cfg.Concurrency)
+	var wg sync.WaitGroup
+
+	started := time.Now()
+	ticker := time.NewTicker(time.Second / time.Duration(cfg.RPS))
+	defer ticker.Stop()
+	report := time.NewTicker(5 * time.Second)
+	defer report.Stop()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-report.C:
+			if progress != nil {
+				progress(rec.report(cfg.Target, time.Since(started)))
+			}
+		case <-ticker.C:
+			select {
+			case slots <- struct{}{}:
+			default:
+				rec.mu.Lock()
+				rec.dropped++
+				rec.mu.Unlock()
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				send(client, cfg, rec)
+			}()
+		}
+	}
+	elapsed := time.Since(started)
+	wg.Wait()
+	return rec.report(cfg.Target, elapsed)
+}
+
+// send uses its own context so requests in flight when the run ends still complete
+func send(client *http.Client, cfg Config, rec *recorder) {
+	reqCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	req, err := http.NewRequestWithContext(reqCtx, cfg.Method, cfg.Target, nil)
+	if err != nil {
+		rec.record(0, "error", true)
+		return
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		rec.record(time.Since(started), "error", true)
+		return
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	rec.record(time.Since(started), strconv.Itoa(res.StatusCode), res.StatusCode >= 500)
+}
+
+func (r *recorder) report(target string, elapsed time.Duration) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := Report{
+		Target:      target,
+		Requests:    len(r.latencies),
+		Errors:      r.errors,
+		Dropped:     r.dropped,
+		Statuses:    make(map[string]int, len(r.statuses)),
+		ElapsedSecs: elapsed.Seconds(),
+	}
+	for status, n := range r.statuses {
+		report.Statuses[status] = n
+	}
+	if elapsed > 0 {
+		report.AchievedRPS = float64(report.Requests) / elapsed.Seconds()
+	}
+
+	sorted := slices.Clone(r.latencies)
+	slices.Sort(sorted)
+	if len(sorted) > 0 {
+		report.Latency = Percentiles{
+			Min: sorted[0],
+			P50: percentile(sorted, 0.50),
+			P90: percentile(sorted, 0.90),
+			P95: percentile(sorted, 0.95),
+			P99: percentile(sorted, 0.99),
+			Max: sorted[len(sorted)-1],
+		}
+	}
+	return report
+}
+
+// percentile reads the nearest rank from sorted latencies
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
@@ -0,0 +1,215 @@
+package loadshed
+
+import (
+	"app/metrics"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	adaptiveLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "loadshed",
+		Name:      "adaptive_limit",
+		Help:      "Concurrency limit currently enforced by the adaptive controller.",
+	})
+
+	adaptiveLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "loadshed",
+		Name:      "adaptive_latency_seconds",
+		Help:      "Latency the adaptive controller compares, the last sample window against the no-load baseline.",
+	}, []string{"kind"})
+
+	adaptiveGradient = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "loadshed",
+		Name:      "adaptive_gradient",
+		Help:      "Last gradient applied to the limit, below 1 shrinks it and above 1 grows it.",
+	})
+
+	adaptiveMeasuring = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "loadshed",
+		Name:      "adaptive_measuring_baseline",
+		Help:      "1 while the limit is pinned to its minimum to measure the no-load latency.",
+	})
+)
+
+// AdaptiveConfig tunes the gradient controller, see ConfigFromEnv for the defaults
+type AdaptiveConfig struct {
+	Initial int
+	Min     int
+	Max     int
+	// Tolerance is how much slower than the no-load baseline requests may get before the limit shrinks
+	Tolerance float64
+	// Smoothing is the share of each new estimate applied to the limit, lower reacts slower
+	Smoothing float64
+	// Window is how long latency is averaged before the limit is adjusted
+	Window time.Duration
+	// BaselineInterval is how often the no-load latency is measured again, over BaselineSamples requests
+	BaselineInterval time.Duration
+	BaselineSamples  int
+}
+
+// gradient is an adaptive concurrency limit in the style of Envoy's adaptive concurrency filter.
+// Every window it compares the average latency against the no-load baseline: the limit grows while
+// requests are about as fast as the baseline and shrinks once they slow down, which is when they start
+// queueing somewhere. The baseline is measured with the limit pinned to Min, so it cannot creep up
+// under sustained load, at the cost of shedding what exceeds Min for the few requests it takes.
+type gradient struct {
+	cfg AdaptiveConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	inFlight int
+	limit    float64
+	// freed is closed and replaced whenever a slot is released, waking the queued requests
+	freed chan struct{}
+
+	baseline     float64
+	measuring    bool
+	drainedAt    time.Time
+	nextBaseline time.Time
+	probeSum     float64
+	probeCount   int
+
+	windowStart    time.Time
+	windowSum      float64
+	windowCount    int
+	windowInFlight int
+}
+
+func newGradient(cfg AdaptiveConfig) *gradient {
+	g := &gradient{cfg: cfg, now: time.Now, limit: float64(cfg.Initial), freed: make(chan struct{})}
+	adaptiveLimit.Set(g.limit)
+	return g
+}
+
+// enforced is the limit in effect, Min while the baseline is measured
+func (g *gradient) enforced() int {
+	if g.measuring {
+		return g.cfg.Min
+	}
+	return int(g.limit)
+}
+
+// acquire takes a slot, waiting at most timeout or until the request is gone like the semaphore
+func (g *gradient) acquire(req *http.Request, timeout time.Duration) bool {
+	var expired <-chan time.Time
+	for {
+		taken, freed := g.tryAcquire()
+		if taken {
+			return true
+		}
+		if timeout <= 0 {
+			return false
+		}
+		if expired == nil {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case <-freed:
+		case <-expired:
+			return false
+		case <-req.Context().Done():
+			return false
+		}
+	}
+}
+
+// tryAcquire takes a slot if the limit allows, otherwise it returns the channel closed on the next release
+func (g *gradient) tryAcquire() (bool, <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.baseline == 0 || !g.now().Before(g.nextBaseline) {
+		g.startMeasuring()
+	}
+	if g.inFlight >= g.enforced() {
+		return false, g.freed
+	}
+	g.inFlight++
+	g.windowInFlight = max(g.windowInFlight, g.inFlight)
+	inFlight.WithLabelValues("").Inc()
+	return true, nil
+}
+
+func (g *gradient) startMeasuring() {
+	if g.measuring {
+		return
+	}
+	g.measuring = true
+	g.drainedAt = time.Time{}
+	g.probeSum, g.probeCount = 0, 0
+	adaptiveMeasuring.Set(1)
+	adaptiveLimit.Set(float64(g.cfg.Min))
+}
+
+func (g *gradient) release(latency time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inFlight--
+	inFlight.WithLabelValues("").Dec()
+	close(g.freed)
+	g.freed = make(chan struct{})
+
+	rtt := latency.Seconds()
+	if rtt <= 0 {
+		return
+	}
+	now := g.now()
+
+	if g.measuring {
+		// Only requests started once the pod drained down to Min saw no load
+		if g.drainedAt.IsZero() {
+			if g.inFlight <= g.cfg.Min {
+				g.drainedAt = now
+			}
+			return
+		}
+		if now.Add(-latency).Before(g.drainedAt) {
+			return
+		}
+		g.probeSum += rtt
+		g.probeCount++
+		if g.probeCount < g.cfg.BaselineSamples {
+			return
+		}
+		g.baseline = g.probeSum / float64(g.probeCount)
+		g.measuring = false
+		g.nextBaseline = now.Add(g.cfg.BaselineInterval)
+		g.windowStart, g.windowSum, g.windowCount, g.windowInFlight = now, 0, 0, g.inFlight
+		adaptiveMeasuring.Set(0)
+		adaptiveLimit.Set(g.limit)
+		adaptiveLatency.WithLabelValues("baseline").Set(g.baseline)
+		return
+	}
+
+	g.windowSum += rtt
+	g.windowCount++
+	if now.Sub(g.windowStart) < g.cfg.Window {
+		return
+	}
+	sample := g.windowSum / float64(g.windowCount)
+	busiest := g.windowInFlight
+	g.windowStart, g.windowSum, g.windowCount, g.windowInFlight = now, 0, 0, g.inFlight
+	adaptiveLatency.WithLabelValues("sample").Set(sample)
+
+	gradient := max(0.5, min(2, g.cfg.Tolerance*g.baseline/sample))
+	adaptiveGradient.Set(gradient)
+	// A pod using less than half its limit says nothing about whether more would fit, growing then would run away
+	if gradient >= 1 && float64(busiest) < g.limit/2 {
+		return
+	}
+	estimate := g.limit*gradient + math.Sqrt(g.limit)
+	g.limit = g.limit*(1-g.cfg.Smoothing) + estimate*g.cfg.Smoothing
+	g.limit = max(float64(g.cfg.Min), min(float64(g.cfg.Max), g.limit))
+	adaptiveLimit.Set(g.limit)
+}
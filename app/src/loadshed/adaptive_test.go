@@ -0,0 +1,162 @@
+package loadshed
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestGradient() (*gradient, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	g := newGradient(AdaptiveConfig{
+		Initial:          10,
+		Min:              2,
+		Max:              100,
+		Tolerance:        1.5,
+		Smoothing:        1,
+		Window:           time.Second,
+		BaselineInterval: time.Hour,
+		BaselineSamples:  3,
+	})
+	g.now = clock.now
+	return g, clock
+}
+
+// measureBaseline drives g through the no-load measurement with requests taking rtt
+func measureBaseline(t *testing.T, g *gradient, clock *fakeClock, rtt time.Duration) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if !g.acquire(req, 0) || !g.acquire(req, 0) {
+		t.Fatal("the first requests did not get a slot")
+	}
+	if g.acquire(req, 0) {
+		t.Fatal("more than Min requests were let in while the baseline is measured")
+	}
+	g.release(rtt)
+	g.release(rtt)
+	for range 3 {
+		clock.advance(100 * time.Millisecond)
+		if !g.acquire(req, 0) {
+			t.Fatal("a baseline probe did not get a slot")
+		}
+		clock.advance(rtt)
+		g.release(rtt)
+	}
+	if g.measuring || g.baseline != rtt.Seconds() {
+		t.Fatalf("baseline = %v (measuring %v), want %v", g.baseline, g.measuring, rtt.Seconds())
+	}
+}
+
+func TestGradientLimitFollowsLatency(t *testing.T) {
+	g, clock := newTestGradient()
+	measureBaseline(t, g, clock, 10*time.Millisecond)
+	if g.enforced() != 10 {
+		t.Fatalf("enforced limit after the baseline = %d, want the initial 10", g.enforced())
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// Ten times slower than the baseline: the gradient is clamped to 0.5
+	clock.advance(time.Second)
+	g.acquire(req, 0)
+	g.release(100 * time.Millisecond)
+	want := 10*0.5 + math.Sqrt(10)
+	if math.Abs(g.limit-want) > 1e-9 {
+		t.Fatalf("limit after a slow window = %v, want %v", g.limit, want)
+	}
+
+	// As fast as the baseline with the pod using more than half the limit: it grows by the tolerance
+	for range 5 {
+		g.acquire(req, 0)
+	}
+	clock.advance(time.Second)
+	for range 5 {
+		g.release(10 * time.Millisecond)
+	}
+	want = want*1.5 + math.Sqrt(want)
+	if math.Abs(g.limit-want) > 1e-9 {
+		t.Fatalf("limit after a fast busy window = %v, want %v", g.limit, want)
+	}
+
+	// Fast but nearly idle: says nothing about more load fitting, the limit stays
+	clock.advance(time.Second)
+	g.acquire(req, 0)
+	g.release(10 * time.Millisecond)
+	if math.Abs(g.limit-want) > 1e-9 {
+		t.Errorf("limit after an idle window = %v, want it unchanged at %v", g.limit, want)
+	}
+
+	// The baseline is measured again once BaselineInterval has passed
+	clock.advance(time.Hour)
+	g.acquire(req, 0)
+	if !g.measuring || g.enforced() != 2 {
+		t.Errorf("after the baseline interval: measuring %v with limit %d, want the limit pinned to Min", g.measuring, g.enforced())
+	}
+}
+
+func TestGradientLimitStaysWithinBounds(t *testing.T) {
+	g, clock := newTestGradient()
+	measureBaseline(t, g, clock, 10*time.Millisecond)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for range 20 {
+		busy := g.enforced()
+		for range busy {
+			g.acquire(req, 0)
+		}
+		clock.advance(time.Second)
+		for range busy {
+			g.release(10 * time.Millisecond)
+		}
+	}
+	if g.limit != 100 {
+		t.Errorf("limit after sustained fast busy windows = %v, want Max = 100", g.limit)
+	}
+
+	// Halving plus the square root headroom settles at 4, so a Min above that holds the limit up
+	g.cfg.Min = 5
+	for range 20 {
+		clock.advance(time.Second)
+		g.acquire(req, 0)
+		g.release(time.Second)
+	}
+	if g.limit != 5 {
+		t.Errorf("limit after sustained slow windows = %v, want Min = 5", g.limit)
+	}
+}
+
+func TestGradientQueuesUpToTheTimeout(t *testing.T) {
+	g, clock := newTestGradient()
+	measureBaseline(t, g, clock, 10*time.Millisecond)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for range g.enforced() {
+		if !g.acquire(req, 0) {
+			t.Fatal("a slot below the limit was not taken")
+		}
+	}
+
+	if g.acquire(req, 0) {
+		t.Fatal("a slot above the limit was taken")
+	}
+	started := time.Now()
+	if g.acquire(req, 30*time.Millisecond) {
+		t.Fatal("a slot above the limit was taken after queueing")
+	}
+	if waited := time.Since(started); waited < 30*time.Millisecond {
+		t.Errorf("gave up after %s, want the queue timeout of 30ms", waited)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		g.release(10 * time.Millisecond)
+	}()
+	if !g.acquire(req, time.Second) {
+		t.Error("a queued request did not get the released slot")
+	}
+}
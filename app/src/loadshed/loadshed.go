@@ -0,0 +1,281 @@
+package loadshed
+
+import (
+	"app/config"
+	"app/metrics"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	inFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "loadshed",
+		Name:      "in_flight_requests",
+		Help:      "Requests holding a concurrency slot, route is the route or group name and empty for the global limit.",
+	}, []string{"route"})
+
+	shed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "loadshed",
+		Name:      "shed_requests_total",
+		Help:      "Requests rejected with 503 because a concurrency limit was reached.",
+	}, []string{"route"})
+
+	timedOut = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "loadshed",
+		Name:      "timed_out_requests_total",
+		Help:      "Requests whose route group timeout expired before the handler returned.",
+	}, []string{"group"})
+)
+
+type Config struct {
+	// Global bounds the requests served at once across all routes, zero is unlimited
+	Global int
+	// Adaptive replaces the fixed global limit with one adjusted from observed latency
+	Adaptive *AdaptiveConfig
+	// Routes bounds single routes, keyed by "METHOD /route/:pattern"
+	Routes map[string]int
+	// Groups are bulkheads with their own budget, a route belongs to the group with its longest prefix
+	Groups []Group
+	// QueueTimeout is how long a request waits for a slot before it is shed, zero sheds at once
+	QueueTimeout time.Duration
+	RetryAfter   time.Duration
+	// Exempt route patterns never take a slot, probes must answer however busy the pod is
+	Exempt []string
+}
+
+// Enabled reports whether any limit is configured
+func (c Config) Enabled() bool {
+	return c.Global > 0 || c.Adaptive != nil || len(c.Routes) > 0 || len(c.Groups) > 0
+}
+
+// Group is a bulkhead: the routes under its prefixes share a concurrency limit and a timeout,
+// so a slow dependency behind one group cannot take the slots the other groups need.
+// A zero Limit or Timeout leaves that budget unbounded.
+type Group struct {
+	Name     string
+	Prefixes []string
+	Limit    int
+	Timeout  time.Duration
+}
+
+// group returns the group whose prefix matches most of the route pattern, nil when none does
+func (c Config) group(path string) *Group {
+	var match *Group
+	longest := -1
+	for i, group := range c.Groups {
+		for _, prefix := range group.Prefixes {
+			prefix = strings.TrimSuffix(prefix, "/")
+			if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+				continue
+			}
+			if len(prefix) > longest {
+				match, longest = &c.Groups[i], len(prefix)
+			}
+		}
+	}
+	return match
+}
+
+// ConfigFromEnv reads CONCURRENCY_LIMIT and CONCURRENCY_ROUTE_LIMITS such as "GET /samples=10,POST /sample=5",
+// or with CONCURRENCY_ADAPTIVE=true the bounds of the adaptive global limit.
+// CONCURRENCY_GROUPS names route groups, each configured by CONCURRENCY_GROUP_<NAME>_PREFIXES,
+// _LIMIT and _TIMEOUT, for example CONCURRENCY_GROUPS=sample with
+// CONCURRENCY_GROUP_SAMPLE_PREFIXES=/sample,/samples and CONCURRENCY_GROUP_SAMPLE_TIMEOUT=5s.
+// Long-poll routes are exempt by default because they hold a slot while they wait.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Global:       config.Int("CONCURRENCY_LIMIT", 0),
+		Routes:       map[string]int{},
+		QueueTimeout: config.Duration("CONCURRENCY_QUEUE_TIMEOUT", 0),
+		RetryAfter:   config.Duration("CONCURRENCY_RETRY_AFTER", time.Second),
+		Exempt:       config.List("CONCURRENCY_EXEMPT_ROUTES"),
+	}
+	if len(cfg.Exempt) == 0 {
+		cfg.Exempt = []string{"/healthz", "/readyz", "/startupz", "/metrics", "/sample/changes"}
+	}
+	if config.Bool("CONCURRENCY_ADAPTIVE", false) {
+		adaptive := AdaptiveConfig{
+			Initial:   config.Int("CONCURRENCY_ADAPTIVE_INITIAL", 20),
+			Min:       config.Int("CONCURRENCY_ADAPTIVE_MIN", 5),
+			Max:       config.Int("CONCURRENCY_ADAPTIVE_MAX", 1000),
+			Tolerance: config.Float("CONCURRENCY_ADAPTIVE_TOLERANCE", 1.5),
+			Smoothing: config.Float("CONCURRENCY_ADAPTIVE_SMOOTHING", 0.2),
+			Window:    config.Duration("CONCURRENCY_ADAPTIVE_WINDOW", 250*time.Millisecond),
+
+			BaselineInterval: config.Duration("CONCURRENCY_ADAPTIVE_BASELINE_INTERVAL", time.Minute),
+			BaselineSamples:  config.Int("CONCURRENCY_ADAPTIVE_BASELINE_SAMPLES", 25),
+		}
+		if adaptive.Min < 1 || adaptive.Min > adaptive.Initial || adaptive.Initial > adaptive.Max ||
+			adaptive.Tolerance < 1 || adaptive.Smoothing <= 0 || adaptive.Smoothing > 1 ||
+			adaptive.Window <= 0 || adaptive.BaselineInterval <= 0 || adaptive.BaselineSamples < 1 {
+			return Config{}, fmt.Errorf("CONCURRENCY_ADAPTIVE_*: want 1 <= min <= initial <= max, tolerance >= 1, 0 < smoothing <= 1 and positive window and baseline settings")
+		}
+		cfg.Adaptive = &adaptive
+	}
+	for _, entry := range config.List("CONCURRENCY_ROUTE_LIMITS") {
+		route, raw, found := strings.Cut(entry, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(raw))
+		if !found || err != nil || limit <= 0 {
+			return Config{}, fmt.Errorf("CONCURRENCY_ROUTE_LIMITS: invalid entry %q, want \"METHOD /path=limit\"", entry)
+		}
+		cfg.Routes[strings.Join(strings.Fields(route), " ")] = limit
+	}
+	for _, name := range config.List("CONCURRENCY_GROUPS") {
+		prefix := "CONCURRENCY_GROUP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		group := Group{
+			Name:     name,
+			Prefixes: config.List(prefix + "PREFIXES"),
+			Limit:    config.Int(prefix+"LIMIT", 0),
+			Timeout:  config.Duration(prefix+"TIMEOUT", 0),
+		}
+		if len(group.Prefixes) == 0 || group.Limit < 0 || group.Timeout < 0 {
+			return Config{}, fmt.Errorf("%s*: group %q needs route prefixes and a non-negative limit and timeout", prefix, name)
+		}
+		cfg.Groups = append(cfg.Groups, group)
+	}
+	return cfg, nil
+}
+
+// limiter hands out concurrency slots, release is told how long the slot was held
+type limiter interface {
+	acquire(req *http.Request, timeout time.Duration) bool
+	release(latency time.Duration)
+}
+
+// semaphore is a counting semaphore over a buffered channel
+type semaphore struct {
+	name  string
+	slots chan struct{}
+}
+
+func newSemaphore(name string, size int) *semaphore {
+	return &semaphore{name: name, slots: make(chan struct{}, size)}
+}
+
+// acquire takes a slot, waiting at most timeout or until the request is gone
+func (s *semaphore) acquire(req *http.Request, timeout time.Duration) bool {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		if timeout <= 0 {
+			return false
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case s.slots <- struct{}{}:
+		case <-timer.C:
+			return false
+		case <-req.Context().Done():
+			return false
+		}
+	}
+	inFlight.WithLabelValues(s.name).Inc()
+	return true
+}
+
+func (s *semaphore) release(time.Duration) {
+	<-s.slots
+	inFlight.WithLabelValues(s.name).Dec()
+}
+
+// Middleware answers 503 with Retry-After once the global, a group's or a route's limit of concurrent
+// requests is reached, so a saturated pod sheds load instead of queueing it until it runs out of memory.
+// A group's timeout cancels the request context, a handler that has not answered by then gets a 504.
+// It must be installed with Use so the matched route is known.
+func Middleware(cfg Config) echo.MiddlewareFunc {
+	var global limiter
+	switch {
+	case cfg.Adaptive != nil:
+		global = newGradient(*cfg.Adaptive)
+	case cfg.Global > 0:
+		global = newSemaphore("", cfg.Global)
+	}
+	routes := make(map[string]limiter, len(cfg.Routes))
+	for route, limit := range cfg.Routes {
+		routes[route] = newSemaphore(route, limit)
+	}
+	groups := make(map[string]limiter, len(cfg.Groups))
+	for _, group := range cfg.Groups {
+		if group.Limit > 0 {
+			groups[group.Name] = newSemaphore(group.Name, group.Limit)
+		}
+	}
+	retryAfter := strconv.Itoa(int(math.Ceil(cfg.RetryAfter.Seconds())))
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if slices.Contains(cfg.Exempt, ctx.Path()) {
+				return next(ctx)
+			}
+
+			req := ctx.Request()
+			route := req.Method + " " + ctx.Path()
+			group := cfg.group(ctx.Path())
+			var groupName string
+			if group != nil {
+				groupName = group.Name
+			}
+			var held []limiter
+			for _, scope := range [...]struct {
+				name    string
+				limiter limiter
+			}{{"", global}, {groupName, groups[groupName]}, {route, routes[route]}} {
+				if scope.limiter == nil {
+					continue
+				}
+				if !scope.limiter.acquire(req, cfg.QueueTimeout) {
+					for _, l := range held {
+						l.release(0)
+					}
+					shed.WithLabelValues(scope.name).Inc()
+					slog.Warn("request shed", "method", req.Method, "path", ctx.Path(), "limit", cmp.Or(scope.name, "global"))
+					ctx.Response().Header().Set("Retry-After", retryAfter)
+					return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": "server is busy, retry later"})
+				}
+				held = append(held, scope.limiter)
+			}
+
+			start := time.Now()
+			defer func() {
+				latency := time.Since(start)
+				for _, l := range held {
+					l.release(latency)
+				}
+			}()
+			if group == nil || group.Timeout <= 0 {
+				return next(ctx)
+			}
+
+			reqCtx, cancel := context.WithTimeout(req.Context(), group.Timeout)
+			defer cancel()
+			ctx.SetRequest(req.WithContext(reqCtx))
+			err := next(ctx)
+			if !errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+				return err
+			}
+			timedOut.WithLabelValues(group.Name).Inc()
+			slog.Warn("request timed out", "method", req.Method, "path", ctx.Path(), "group", group.Name, "timeout", group.Timeout)
+			if ctx.Response().Committed {
+				return err
+			}
+			return ctx.JSON(http.StatusGatewayTimeout, map[string]string{"error": "request timed out"})
+		}
+	}
+}
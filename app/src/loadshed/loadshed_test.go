@@ -0,0 +1,187 @@
+package loadshed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// blockingServer serves cfg with handlers that hold their slot until release is closed
+func blockingServer(cfg Config) (*echo.Echo, chan struct{}) {
+	release := make(chan struct{})
+	e := echo.New()
+	e.Use(Middleware(cfg))
+	block := func(ctx echo.Context) error {
+		<-release
+		return ctx.NoContent(http.StatusOK)
+	}
+	e.GET("/samples", block)
+	e.POST("/sample", block)
+	e.GET("/healthz", block)
+	return e, release
+}
+
+func serve(e *echo.Echo, method, path string) int {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec.Code
+}
+
+// occupy starts n requests and waits until they hold their slots
+func occupy(t *testing.T, e *echo.Echo, n int, method, path string) *sync.WaitGroup {
+	t.Helper()
+	var started, done sync.WaitGroup
+	for range n {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			started.Done()
+			serve(e, method, path)
+		}()
+	}
+	started.Wait()
+	time.Sleep(20 * time.Millisecond)
+	return &done
+}
+
+func TestSemaphore(t *testing.T) {
+	s := newSemaphore("test", 1)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if !s.acquire(req, 0) {
+		t.Fatal("a free slot was not taken")
+	}
+	if s.acquire(req, 0) {
+		t.Fatal("a second slot was taken with the limit at one")
+	}
+
+	started := time.Now()
+	if s.acquire(req, 30*time.Millisecond) {
+		t.Fatal("a slot was taken while the only one was held")
+	}
+	if waited := time.Since(started); waited < 30*time.Millisecond {
+		t.Errorf("gave up after %s, want the queue timeout of 30ms", waited)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.release(0)
+	}()
+	if !s.acquire(req, time.Second) {
+		t.Error("a queued request did not get the released slot")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s.acquire(req.WithContext(ctx), time.Second) {
+		t.Error("a request whose client is gone still got a slot")
+	}
+}
+
+func TestMiddlewareShedsAtTheGlobalLimit(t *testing.T) {
+	e, release := blockingServer(Config{Global: 2, RetryAfter: 2 * time.Second, Exempt: []string{"/healthz"}})
+	done := occupy(t, e, 2, http.MethodGet, "/samples")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sample", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("third request: status %d with Retry-After %q, want 503 with 2", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	exempt := make(chan int)
+	go func() { exempt <- serve(e, http.MethodGet, "/healthz") }()
+	close(release)
+	if code := <-exempt; code != http.StatusOK {
+		t.Errorf("exempt probe: status %d, want 200", code)
+	}
+	done.Wait()
+	if code := serve(e, http.MethodGet, "/samples"); code != http.StatusOK {
+		t.Errorf("after the slots were released: status %d, want 200", code)
+	}
+}
+
+func TestMiddlewareRouteLimits(t *testing.T) {
+	e, release := blockingServer(Config{Global: 10, Routes: map[string]int{"POST /sample": 1}})
+	done := occupy(t, e, 1, http.MethodPost, "/sample")
+
+	if code := serve(e, http.MethodPost, "/sample"); code != http.StatusServiceUnavailable {
+		t.Errorf("second POST /sample: status %d, want 503", code)
+	}
+	other := make(chan int)
+	go func() { other <- serve(e, http.MethodGet, "/samples") }()
+	close(release)
+	if code := <-other; code != http.StatusOK {
+		t.Errorf("GET /samples next to a full route limit: status %d, want 200", code)
+	}
+	done.Wait()
+}
+
+func TestMiddlewareQueuesUpToTheTimeout(t *testing.T) {
+	e, release := blockingServer(Config{Global: 1, QueueTimeout: time.Second})
+	done := occupy(t, e, 1, http.MethodGet, "/samples")
+
+	queued := make(chan int)
+	go func() { queued <- serve(e, http.MethodGet, "/samples") }()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if code := <-queued; code != http.StatusOK {
+		t.Errorf("queued request: status %d, want 200 once the slot was released", code)
+	}
+	done.Wait()
+}
+
+func TestGroupLongestPrefix(t *testing.T) {
+	cfg := Config{Groups: []Group{
+		{Name: "sample", Prefixes: []string{"/sample", "/samples"}},
+		{Name: "changes", Prefixes: []string{"/sample/changes/"}},
+	}}
+	for path, want := range map[string]string{
+		"/sample":         "sample",
+		"/sample/:id":     "sample",
+		"/samples":        "sample",
+		"/sample/changes": "changes",
+		"/samplex":        "",
+		"/files":          "",
+	} {
+		var got string
+		if group := cfg.group(path); group != nil {
+			got = group.Name
+		}
+		if got != want {
+			t.Errorf("group(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestGroupBulkhead(t *testing.T) {
+	e, release := blockingServer(Config{Groups: []Group{{Name: "sample", Prefixes: []string{"/sample"}, Limit: 1}}})
+	done := occupy(t, e, 1, http.MethodPost, "/sample")
+
+	if code := serve(e, http.MethodPost, "/sample"); code != http.StatusServiceUnavailable {
+		t.Errorf("second request to a full group: status %d, want 503", code)
+	}
+	outside := make(chan int)
+	go func() { outside <- serve(e, http.MethodGet, "/samples") }()
+	close(release)
+	if code := <-outside; code != http.StatusOK {
+		t.Errorf("route outside the full group: status %d, want 200", code)
+	}
+	done.Wait()
+}
+
+func TestGroupTimeout(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware(Config{Groups: []Group{{Name: "slow", Prefixes: []string{"/slow"}, Timeout: 20 * time.Millisecond}}}))
+	e.GET("/slow", func(ctx echo.Context) error {
+		<-ctx.Request().Context().Done()
+		return ctx.Request().Context().Err()
+	})
+	if code := serve(e, http.MethodGet, "/slow"); code != http.StatusGatewayTimeout {
+		t.Errorf("handler outliving the group timeout: status %d, want 504", code)
+	}
+}
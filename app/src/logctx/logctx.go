@@ -0,0 +1,50 @@
+package logctx
+
+import (
+	"app/apikey"
+	"app/baggage"
+	"app/jwtauth"
+	"app/session"
+	"app/tracing"
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+)
+
+// From returns the default logger with the attributes identifying the request: its request ID and
+// trace, the route and method, the user of the session or bearer token or the API key, and the
+// tenant of the baggage. It is built on every call, so a user who just logged in is included.
+func From(ctx echo.Context) *slog.Logger {
+	return slog.Default().With(Attrs(ctx)...)
+}
+
+// Attrs are the attributes From adds, for loggers other than the default one
+func Attrs(ctx echo.Context) []any {
+	req := ctx.Request()
+	attrs := make([]any, 0, 16)
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, slog.String(key, value))
+		}
+	}
+
+	requestID := req.Header.Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = ctx.Response().Header().Get(echo.HeaderXRequestID)
+	}
+	add("request_id", requestID)
+	add("trace_id", tracing.TraceID(req.Context()))
+	add("method", req.Method)
+	add("route", ctx.Path())
+
+	if current := session.Get(ctx); current != nil {
+		add("user", current.UserID)
+	} else if claims := jwtauth.ClaimsFrom(ctx); claims != nil {
+		add("user", claims.Subject)
+	}
+	if key := apikey.Get(ctx); key != nil {
+		add("api_key", key.ID)
+	}
+	add("tenant", baggage.Get(ctx).Tenant)
+	return attrs
+}
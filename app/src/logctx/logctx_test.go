@@ -0,0 +1,52 @@
+package logctx
+
+import (
+	"app/jwtauth"
+	"bytes"
+	"crypto/ed25519"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestFrom(t *testing.T) {
+	var out bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&out, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &jwtauth.Signer{PrivateKey: private, PublicKey: public, AccessTTL: time.Minute}
+	token, _, err := signer.Issue("user-1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/samples/:id", func(ctx echo.Context) error {
+		From(ctx).Info("handled", "extra", 1)
+		return ctx.NoContent(http.StatusNoContent)
+	}, signer.Middleware())
+	req := httptest.NewRequest(http.MethodGet, "/samples/42", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-1")
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := out.String()
+	for _, want := range []string{"msg=handled", "request_id=req-1", "method=GET", "route=/samples/:id", "user=user-1", "extra=1"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log line %q lacks %s", line, want)
+		}
+	}
+	if strings.Contains(line, "tenant=") {
+		t.Errorf("log line %q has a tenant without baggage", line)
+	}
+}
@@ -1,48 +1,788 @@
 package main
 
 import (
+	"app/accesslog"
+	"app/adminauth"
+	"app/alert"
+	"app/announcement"
+	"app/apikey"
+	"app/baggage"
+	"app/binder"
+	"app/bodylog"
+	"app/cgroup"
+	"app/clusterinfo"
+	"app/config"
 	"app/controller"
 	"app/db"
+	"app/degrade"
+	"app/experiments"
+	"app/fieldcrypt"
+	samplev1 "app/gen/sample/v1"
+	"app/grpcserver"
+	"app/health"
+	"app/heartbeat"
+	"app/i18n"
+	"app/ipfilter"
+	"app/jobs"
+	"app/jwtauth"
+	"app/kube"
+	"app/links"
+	"app/livestats"
+	"app/loadshed"
+	"app/metrics"
 	"app/model"
+	"app/notification"
+	"app/oidcauth"
+	"app/openapi"
+	"app/profiling"
+	"app/readonly"
+	"app/realip"
+	"app/recorder"
+	"app/redisdb"
+	"app/respsign"
+	"app/retention"
+	"app/routeinfo"
+	"app/runtimeutil"
+	"app/scan"
+	"app/scheduler"
+	"app/service"
+	"app/serviceauth"
+	"app/serving"
+	"app/session"
+	"app/shadow"
+	"app/signedurl"
+	"app/simulate"
+	"app/slo"
+	"app/static"
+	"app/storage"
+	"app/tenantlimit"
+	"app/tracing"
+	"app/views"
+	"app/warmup"
+	"app/workerpool"
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	// Initialize Database
-	db.Init()
+	// CLI subcommands
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	startedAt := time.Now()
+
+	// Logs written with a request context carry its tenant, experiment and canary baggage
+	slog.SetDefault(slog.New(baggage.LogHandler(slog.NewTextHandler(os.Stderr, nil))))
+
+	// Fit the Go runtime to the container limits
+	cgroup.Tune()
+	metrics.InitRuntime()
+
+	// Cancelled on SIGTERM, which Kubernetes sends before killing the pod
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Initialize Tracing
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		slog.Error("failed to initialize tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// APP_ROLE=aggregator only serves endpoints composed from another deployment's Sample API
+	if config.String("APP_ROLE", "") == "aggregator" {
+		if err := runAggregator(ctx); err != nil {
+			slog.Error("shut down after a listener failed", "error", err)
+			shutdownTracing(context.Background())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The listeners run in one group with the job runner, scheduled tasks, worker pools and the database
+	// credential watcher, started with runtimeutil.Critical, sharing its context: the first of them to fail
+	// or panic cancels it, so everything shuts down together instead of leaving a half-alive pod
+	group, ctx := errgroup.WithContext(ctx)
+	runtimeutil.Supervise(ctx, group)
+
+	// Load field encryption keys
+	if err := fieldcrypt.Init(); err != nil {
+		slog.Error("failed to load encryption keys", "error", err)
+		panic("failed to load encryption keys")
+	}
+
+	// Initialize Database, APP_MODE=mock serves seeded data from memory without MySQL
+	mockMode := config.String("APP_MODE", "") == "mock"
+	if mockMode {
+		db.InitMock()
+	} else {
+		db.Init()
+	}
+
+	// Auto Migration, DB_AUTO_MIGRATE=false leaves it to `app migrate` run as a separate expand step.
+	// Replicas starting together take turns through the migration lock.
+	if mockMode || config.Bool("DB_AUTO_MIGRATE", true) {
+		if err := db.Migrate(ctx, db.DB, model.All...); err != nil {
+			slog.Error("failed to migrate database", "error", err)
+		}
+	}
+	if mockMode {
+		seedMock(ctx)
+	}
+
+	// Recorded per run, the shutdown marker tells the restarts after a crash or kill from graceful ones
+	uptimeService := &service.UptimeService{}
+	if err := uptimeService.RecordStart(ctx, startedAt); err != nil {
+		slog.Error("failed to record the process start", "error", err)
+	}
+
+	// Re-encrypt rows written with a retired key
+	if config.Bool("ENCRYPTION_ROTATE_ON_START", false) {
+		encryptionService := service.EncryptionService{}
+		if rotated, err := encryptionService.RotateKeys(); err != nil {
+			slog.Error("failed to rotate encryption keys", "error", err)
+		} else {
+			slog.Info("rotated encrypted fields", "rows", rotated)
+		}
+	}
+
+	// Initialize Object Storage
+	if err := storage.Init(); err != nil {
+		slog.Error("failed to initialize storage", "error", err)
+		panic("failed to initialize storage")
+	}
+
+	// Outbound dependencies checked by readiness
+	for _, url := range config.List("HEALTH_DEPENDENCY_URLS") {
+		health.Register(health.Check{Name: "http:" + url, Run: health.HTTPCheck(url)})
+	}
+
+	// Background jobs
+	if interval := config.Duration("HEALTH_CHECK_INTERVAL", 10*time.Second); interval > 0 {
+		health.Default.StartBackground(ctx, interval)
+		degrade.Start(ctx, interval)
+	}
+	retention.Start(ctx, retention.ConfigFromEnv())
+
+	heartbeat.Start(ctx)
 
-	// Auto Migration
-	if err := db.DB.AutoMigrate(&model.Sample{}); err != nil {
-		slog.Error("failed to migrate database", "error", err)
+	// sample_stats read model, counted from Sample events and written in batches
+	var sampleStats *service.SampleStats
+	if interval := config.Duration("SAMPLE_STATS_FLUSH_INTERVAL", 5*time.Second); interval > 0 {
+		sampleStats = service.NewSampleStats()
+		sampleStats.Subscribe()
+		scheduler.Every(ctx, "sample-stats", interval, sampleStats.Flush)
 	}
 
+	snapshotService := service.SnapshotService{}
+	// One replica takes the scheduled snapshots, the others would store a copy each
+	snapshotLeader := db.NewLeader("app_sample_snapshot")
+	scheduler.Every(ctx, "sample-snapshot", config.Duration("SNAPSHOT_INTERVAL", 0), func(ctx context.Context) error {
+		if !snapshotLeader.Acquire(ctx) {
+			return nil
+		}
+		if _, err := snapshotService.Create(ctx); err != nil {
+			return err
+		}
+		return snapshotService.Prune(ctx, config.Int("SNAPSHOT_KEEP", 24))
+	})
+
+	// CPU and heap profiles uploaded to object storage when the heap or latency crosses a threshold
+	profileCapturer, err := profiling.NewFromEnv()
+	if err != nil {
+		slog.Error("invalid profile capture configuration", "error", err)
+		panic("invalid profile capture configuration")
+	}
+	if profileCapturer != nil {
+		profileCapturer.Start(ctx)
+	}
+	// Continuous profiling pushed to Pyroscope when PYROSCOPE_SERVER_ADDRESS is set
+	if pusher := profiling.PusherFromEnv(); pusher != nil {
+		pusher.Start(ctx)
+	}
+
+	// Initialize Redis
+	redisdb.Init()
+
+	// Initialize Notifications
+	notification.Init()
+
+	// Initialize Kubernetes API client
+	kube.Init()
+	announcement.Start(ctx)
+	clusterinfo.Start(ctx)
+
+	// Initialize Job Runner
+	jobRunner := jobs.Start(ctx, jobs.ConfigFromEnv())
+
 	// Echo instance
-	router := echo.New()
+	// Records the middleware of every route for /debug/routes
+	router := routeinfo.New(echo.New())
+
+	// HTML templates
+	renderer, err := views.NewRenderer()
+	if err != nil {
+		slog.Error("failed to load templates", "error", err)
+		panic("failed to load templates")
+	}
+	router.Renderer = renderer
+
+	// problem+json for unknown routes and methods
+	router.HTTPErrorHandler = router.ErrorHandler(router.DefaultHTTPErrorHandler)
+
+	// Strict JSON request binding
+	router.Binder = &binder.StrictBinder{}
+
+	// Client IP extraction behind the ingress controller
+	ipExtractor, err := realip.ExtractorFromEnv()
+	if err != nil {
+		slog.Error("invalid client ip configuration", "error", err)
+		panic("invalid client ip configuration")
+	}
+	router.IPExtractor = ipExtractor
+	// X-Forwarded-Prefix of the links is only taken from the same trusted proxies
+	forwardedHeaders, err := realip.ForwardedHeadersFromEnv(links.HeaderPrefix)
+	if err != nil {
+		slog.Error("invalid client ip configuration", "error", err)
+		panic("invalid client ip configuration")
+	}
+	router.Use(forwardedHeaders)
 
 	// Middleware
-	router.Use(middleware.Logger())
+	router.Use(accesslog.Middleware(accesslog.Writer()))
 	router.Use(middleware.Recover())
+	router.Use(otelecho.Middleware(tracing.ServiceName))
+	router.Use(metrics.Middleware())
+	// tenant, experiment and canary from the W3C baggage header, propagated on outbound calls
+	router.Use(baggage.Middleware())
+	// Requests per second, in flight and failing for GET /stats/live
+	router.Use(livestats.Default.Middleware())
+	// Content-Digest and X-Response-Signature on every response when RESPONSE_SIGNING is set
+	responseSigner, err := respsign.NewFromEnv()
+	if err != nil {
+		slog.Error("invalid response signing configuration", "error", err)
+		panic("invalid response signing configuration")
+	}
+	if responseSigner != nil {
+		router.Use(responseSigner.Middleware())
+	}
+	sloTracker := slo.New(slo.ConfigFromEnv())
+	router.Use(sloTracker.Middleware())
+	if profileCapturer != nil {
+		router.Use(profileCapturer.Middleware())
+	}
+
+	// Concurrency limits, a saturated pod answers 503 instead of queueing requests
+	loadshedConfig, err := loadshed.ConfigFromEnv()
+	if err != nil {
+		slog.Error("invalid concurrency limit configuration", "error", err)
+		panic("invalid concurrency limit configuration")
+	}
+	if loadshedConfig.Enabled() {
+		router.Use(loadshed.Middleware(loadshedConfig))
+	}
+
+	// READ_ONLY=true or PUT /admin/readonly rejects writes with 503 while reads keep working. The toggle is
+	// stored in the database, the other replicas pick it up within READ_ONLY_SYNC_INTERVAL.
+	router.Use(readonly.Middleware())
+	scheduler.Every(ctx, "readonly-sync", config.Duration("READ_ONLY_SYNC_INTERVAL", 5*time.Second), readonly.Sync)
+
+	// X-Cluster-Name, X-Cluster-Region and X-Cluster-Zone on every response
+	router.Use(clusterinfo.Middleware())
+	// X-Serving-Pod, X-Serving-Zone and X-Serving-Version for traffic split demos
+	if config.Bool("SERVING_HEADERS", false) {
+		router.Use(serving.Middleware())
+	}
+	// Canary or stable behavior per VERSION and the X-Canary header
+	router.Use(serving.VariantMiddleware())
+
+	router.Use(i18n.Middleware())
+	router.Use(bodylog.Middleware())
+
+	// Responses checked against openapi.json, they are buffered so never enable in production.
+	// Requests are checked before the handlers, 400 for bodies and parameters the spec does not allow.
+	validateResponses := config.Bool("OPENAPI_VALIDATE_RESPONSES", false)
+	validateRequests := config.Bool("OPENAPI_VALIDATE_REQUESTS", false)
+	if validateResponses || validateRequests {
+		validator, err := openapi.NewValidator()
+		if err != nil {
+			slog.Error("invalid openapi spec", "error", err)
+			panic("invalid openapi spec")
+		}
+		if validateResponses {
+			slog.Warn("openapi response validation is enabled")
+			router.Use(validator.Middleware())
+		}
+		if validateRequests {
+			router.Use(validator.RequestMiddleware())
+		}
+	}
+
+	// Global IP allow/deny lists
+	ipFilter, err := ipfilter.ConfigFromEnv("IP_")
+	if err != nil {
+		slog.Error("invalid ip filter configuration", "error", err)
+		panic("invalid ip filter configuration")
+	}
+	if ipFilter.Enabled() {
+		router.Use(ipfilter.Middleware(ipFilter))
+	}
+	if alerter := alert.NewFromEnv(); alerter != nil {
+		router.Use(alert.Middleware(alerter))
+	}
+
+	// Calls from other workloads authenticated by their ServiceAccount token
+	if reviewer := serviceauth.NewFromEnv(); reviewer != nil {
+		router.Use(reviewer.Middleware())
+	}
+
+	// Daily and monthly request quotas for callers presenting an API key
+	apiKeysEnabled := config.Bool("API_KEYS_ENABLED", false)
+	if apiKeysEnabled {
+		router.Use(apikey.Middleware(&service.APIKeyService{}))
+	}
+	// Rate limits and daily quotas per tenant of the request baggage, registered from /admin/tenants.
+	// Unknown tenants are rejected and requests without one share the TENANT_* defaults.
+	tenantService := service.NewTenantServiceFromEnv()
+	if config.Bool("TENANT_LIMITS_ENABLED", false) {
+		router.Use(tenantlimit.Middleware(tenantService))
+	}
+	// Request recording for replay against another environment, after the IP filter and the auth
+	// middlewares so the requests they reject do not fill the buffer and the storage
+	requestRecorder := recorder.Start(recorder.ConfigFromEnv())
+	if requestRecorder != nil {
+		router.Use(requestRecorder.Middleware())
+	}
+	// A share of the requests mirrored to SHADOW_TARGET, such as a v2 deployment, for dark launches.
+	// After the IP filter and the auth middlewares, only the requests they let in are mirrored.
+	if mirror := shadow.New(shadow.ConfigFromEnv()); mirror != nil {
+		router.Use(mirror.Middleware())
+	}
+	// Degradation tiers: X-Degradation-Tier while a dependency is down, cached sample reads without
+	// the database, and stale responses instead of 5xx on STALE_FALLBACK_ROUTES. After the IP filter,
+	// service auth, API keys and tenant limits, so a cached response only goes to callers they let in.
+	degradeConfig, err := degrade.ConfigFromEnv()
+	if err != nil {
+		slog.Error("invalid degradation configuration", "error", err)
+		panic("invalid degradation configuration")
+	}
+	router.Use(degrade.Middleware(degradeConfig))
+	// A/B experiments of EXPERIMENTS, callers bucketed into their variants by user or API key
+	experimentConfig, err := experiments.ConfigFromEnv()
+	if err != nil {
+		slog.Error("invalid experiment config", "error", err)
+		panic("invalid experiment config")
+	}
+	if len(experimentConfig.Experiments) > 0 {
+		router.Use(experiments.Middleware(experimentConfig))
+	}
 
 	// Initialize Controller
-	sampleController := controller.SampleController{}
+	sampleCountMode, err := service.ParseCountMode(config.String("SAMPLES_COUNT_MODE", string(service.CountExact)))
+	if err != nil {
+		slog.Error("invalid SAMPLES_COUNT_MODE", "error", err)
+		panic("invalid SAMPLES_COUNT_MODE")
+	}
+	sampleController := controller.SampleController{
+		CountMode:    sampleCountMode,
+		CacheControl: config.String("SAMPLES_CACHE_CONTROL", ""),
+	}
+	webhookPool := workerpool.New("webhook", workerpool.ConfigFromEnv("WEBHOOK_", workerpool.Config{
+		Size:       4,
+		QueueDepth: 100,
+		Policy:     workerpool.Block,
+	}))
+	webhookController := controller.WebhookController{WebhookService: service.WebhookService{Pool: webhookPool}}
+	pageController := controller.PageController{}
+
+	// The hot sample reads are loaded before the pod reports ready, progress at /startupz. Without
+	// SAMPLE_CACHE_TTL nothing keeps them, so warming up is off by default then.
+	var warmupTasks []warmup.Task
+	if config.Bool("WARMUP_ENABLED", service.SampleCacheEnabled()) {
+		warmupTasks = append(sampleController.WarmUp(), pageController.WarmUp()...)
+	}
+	warmer := warmup.New(config.Duration("WARMUP_TIMEOUT", 30*time.Second), warmupTasks...)
+	health.Register(health.Check{Name: "warmup", Critical: true, Run: warmer.Check})
+	runtimeutil.Go("cache warm-up", func() {
+		warmer.Run(ctx)
+		health.Default.Recheck(ctx, "warmup")
+	})
+	adminPageController := controller.AdminPageController{WebhookService: service.WebhookService{Pool: webhookPool}}
+	statusController := controller.StatusController{Health: health.Default, Uptime: uptimeService}
+	sessions := session.NewManagerFromEnv()
+	oidcProvider, err := oidcauth.NewFromEnv(context.Background())
+	if errors.Is(err, oidcauth.ErrNoAudience) {
+		slog.Error("invalid oidc config", "error", err)
+		panic(err)
+	}
+	if err != nil {
+		slog.Error("failed to initialize oidc, oidc login is disabled", "error", err)
+	}
+	authController := controller.AuthController{Sessions: sessions, OIDC: oidcProvider}
+
+	signer, err := jwtauth.NewFromEnv()
+	if err != nil {
+		slog.Error("failed to load jwt keys, jwt authentication is disabled", "error", err)
+	}
+	tokenService := service.TokenService{Signer: signer}
+	if signer != nil {
+		signer.IsRevoked = tokenService.IsRevoked
+	}
+	tokenController := controller.TokenController{TokenService: tokenService}
+	backupController := controller.BackupController{}
+	exportController := controller.ExportController{ExportService: service.NewExportServiceFromEnv()}
+	snapshotController := controller.SnapshotController{}
+	profileController := controller.ProfileController{Capturer: profileCapturer}
+	selfTestController := controller.SelfTestController{}
+	schemaController := controller.SchemaController{}
+	jobController := controller.JobController{}
+	clusterController := controller.ClusterController{}
+	debugController := controller.DebugController{}
+	dashboardController := controller.DashboardController{}
+	devController := controller.DevController{}
+	benchController := controller.BenchController{}
+	counterController := controller.CounterController{}
+	whoamiController := controller.WhoAmIController{}
+	apiKeyController := controller.APIKeyController{}
+	tenantController := controller.TenantController{TenantService: tenantService}
+	urlSigner, err := signedurl.NewFromEnv()
+	if err != nil {
+		slog.Error("invalid signed url configuration", "error", err)
+		panic("invalid signed url configuration")
+	}
+	scanner, err := scan.NewFromEnv()
+	if err != nil {
+		slog.Error("invalid upload scanning configuration", "error", err)
+		panic("invalid upload scanning configuration")
+	}
+	fileController := controller.NewFileControllerFromEnv(urlSigner, scanner)
+	scheduler.Every(ctx, "upload-expiry", config.Duration("FILES_UPLOAD_PURGE_INTERVAL", time.Hour), fileController.FileService.PurgeExpiredUploads)
+	if scanner != nil {
+		jobs.Register(service.FileScanJob, fileController.FileService.ScanJob)
+		slog.Info("scanning uploads", "scanner", scanner.Name())
+	}
+
+	// Write endpoints optionally require an OIDC or self-issued access token
+	var apiWriteAuth []echo.MiddlewareFunc
+	var grpcWriteAuth grpcserver.TokenVerifier
+	if oidcProvider != nil && config.Bool("OIDC_PROTECT_API", false) {
+		apiWriteAuth = append(apiWriteAuth, oidcProvider.BearerMiddleware())
+		grpcWriteAuth = func(ctx context.Context, raw string) error {
+			_, err := oidcProvider.VerifyBearer(ctx, raw)
+			return err
+		}
+	} else if signer != nil && config.Bool("JWT_PROTECT_API", false) {
+		apiWriteAuth = append(apiWriteAuth, signer.Middleware())
+		grpcWriteAuth = func(ctx context.Context, raw string) error {
+			_, err := signer.Parse(raw)
+			return err
+		}
+	}
 
 	// Routes
 	router.GET("/", hello)
-	router.GET("/sample", sampleController.GetSample)
-	router.POST("/sample", sampleController.PostSample)
+	router.GET("/metrics", metrics.Handler())
+	router.GET("/healthz", health.LiveHandler)
+	router.GET("/readyz", health.Default.ReadyHandler())
+	router.GET("/startupz", warmer.Handler)
+	router.GET("/status", statusController.Status)
+	router.GET("/status/uptime", statusController.ProcessUptime)
+	router.GET("/openapi.json", openapi.Handler)
+	if responseSigner != nil {
+		router.GET("/signing-key", responseSigner.KeyHandler)
+	}
+	router.GET("/slo", sloTracker.Handler())
+	router.GET("/cluster/peers", clusterController.Peers)
+	router.GET("/hostname", clusterController.Hostname)
+	router.GET("/fanout", clusterController.Fanout)
+	router.GET("/announcement", announcement.Handler)
+	router.GET("/clusterinfo", clusterinfo.Handler)
+	router.GET("/dashboard", dashboardController.Get)
+	router.GET("/stats/live", livestats.Default.Handler)
+	if apiKeysEnabled {
+		router.GET("/quota", apiKeyController.Quota)
+	}
+	router.GET("/counter", counterController.Get)
+	router.POST("/counter", counterController.Increment)
+	router.GET("/whoami", whoamiController.Get)
+	router.GET("/whoami/pod", whoamiController.Pod)
+	router.GET("/whoami/shared", whoamiController.Shared)
+	router.GET("/experiments", experiments.Handler, sessions.Middleware())
+	sampleController.Register(router, apiWriteAuth...)
+	// Uploaded files need the same auth as the write endpoints, signed URLs download without it
+	fileController.Register(router, apiWriteAuth...)
+	router.POST("/hooks/:provider", webhookController.Receive)
+
+	// Password authentication
+	authGroup := router.Group("/auth", sessions.Middleware())
+	authGroup.POST("/register", authController.Register)
+	authGroup.POST("/login", authController.APILogin)
+	authGroup.POST("/password", authController.ChangePassword)
+	if signer != nil {
+		authGroup.POST("/token", tokenController.Issue)
+		authGroup.POST("/token/refresh", tokenController.Refresh)
+		authGroup.POST("/token/revoke", tokenController.Revoke)
+	}
+
+	// Admin endpoints
+	adminFilter, err := ipfilter.ConfigFromEnv("ADMIN_")
+	if err != nil {
+		slog.Error("invalid admin ip filter configuration", "error", err)
+		panic("invalid admin ip filter configuration")
+	}
+	adminAuth := []echo.MiddlewareFunc{ipfilter.Middleware(adminFilter), sessions.Middleware(), adminauth.Require()}
+	admin := router.Group("/admin", adminAuth...)
+	admin.POST("/backups", backupController.Create)
+	admin.GET("/backups", backupController.List)
+	admin.GET("/backups/:name", backupController.Download)
+	admin.POST("/exports/:table", exportController.Create)
+	admin.GET("/exports", exportController.List)
+	admin.POST("/snapshots", snapshotController.Create)
+	admin.GET("/snapshots", snapshotController.List)
+	admin.GET("/snapshots/:name", snapshotController.Download)
+	admin.POST("/profiles", profileController.Capture)
+	admin.GET("/profiles", profileController.List)
+	admin.GET("/profiles/:pod/:name", profileController.Download)
+	admin.POST("/selftest", selfTestController.Run)
+	admin.GET("/schema/drift", schemaController.Drift)
+	admin.POST("/jobs", jobController.Enqueue)
+	admin.GET("/jobs", jobController.List)
+	admin.GET("/jobs/dead", jobController.ListDead)
+	admin.GET("/jobs/dead/:id", jobController.GetDead)
+	admin.POST("/jobs/dead/:id/requeue", jobController.Requeue)
+	admin.DELETE("/jobs/dead/:id", jobController.Discard)
+	admin.POST("/api-keys", apiKeyController.Create)
+	admin.GET("/api-keys", apiKeyController.List)
+	admin.DELETE("/api-keys/:id", apiKeyController.Revoke)
+	admin.GET("/api-keys/:id/usage", apiKeyController.Usage)
+	admin.GET("/tenants", tenantController.List)
+	admin.GET("/tenants/:tenant", tenantController.Get)
+	admin.PUT("/tenants/:tenant", tenantController.Set)
+	admin.DELETE("/tenants/:tenant", tenantController.Delete)
+	router.GET("/debug/routes", router.Handler(), adminAuth...)
+	admin.GET("/debug/config", debugController.Config)
+	admin.GET("/debug/gc", debugController.GC)
+	admin.PUT("/debug/gc", debugController.SetGC)
+	admin.GET("/debug/resources", debugController.Resources)
+	admin.GET("/debug/bodylog", bodylog.ToggleHandler)
+	admin.PUT("/debug/bodylog", bodylog.ToggleHandler)
+	admin.GET("/readonly", readonly.ToggleHandler)
+	admin.PUT("/readonly", readonly.ToggleHandler)
+
+	// Development helpers, never enable in production
+	if config.Bool("DEV_ENDPOINTS_ENABLED", false) {
+		slog.Warn("development endpoints are enabled")
+		dev := router.Group("/dev", adminAuth...)
+		dev.POST("/generate", devController.Generate)
+		// Open to load generators, which cannot log in, so they are only served with the other helpers
+		router.GET("/bench/delay/:ms", benchController.Delay)
+		router.GET("/bench/payload/:kb", benchController.Payload)
+	}
+
+	// A deliberately flaky downstream, for a second deployment to practice retries, probes and circuit breakers
+	if config.Bool("SIMULATE_ENABLED", false) {
+		profile, err := simulate.ProfileFromEnv()
+		if err != nil {
+			slog.Error("invalid simulated downstream configuration", "error", err)
+			panic("invalid simulated downstream configuration")
+		}
+		slog.Warn("simulated downstream endpoints are enabled", "profile", profile)
+		simulator := simulate.New(profile)
+		sim := router.Group("/simulate")
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			sim.Add(method, "/call", simulator.Call)
+		}
+		sim.GET("/healthz", simulator.Health)
+		sim.GET("/profile", simulator.ProfileHandler)
+		sim.PUT("/profile", simulator.ProfileHandler, adminAuth...)
+		sim.DELETE("/profile", simulator.ProfileHandler, adminAuth...)
+	}
+
+	// HTML pages
+	pages := router.Group("/pages", sessions.Middleware(), middleware.CSRFWithConfig(middleware.CSRFConfig{
+		TokenLookup:    "form:_csrf",
+		CookiePath:     "/",
+		CookieHTTPOnly: true,
+		CookieSecure:   sessions.Secure,
+		CookieSameSite: http.SameSiteLaxMode,
+	}))
+	pages.GET("/login", authController.LoginPage)
+	pages.POST("/login", authController.Login)
+	pages.POST("/logout", authController.Logout)
+	if oidcProvider != nil {
+		pages.GET("/oidc/login", authController.OIDCLogin)
+		pages.GET("/oidc/callback", authController.OIDCCallback)
+	}
+
+	loggedIn := pages.Group("", sessions.RequireLogin("login"))
+	loggedIn.GET("/samples", pageController.ListSamples)
+	loggedIn.POST("/samples", pageController.CreateSample)
+
+	// Admin pages, for the admin account's session behind the admin IP filter
+	adminPages := pages.Group("/admin", ipfilter.Middleware(adminFilter), sessions.RequireLogin("../login"), adminauth.Require())
+	adminPages.GET("/jobs", adminPageController.Jobs)
+	adminPages.POST("/jobs", adminPageController.JobAction)
+	adminPages.GET("/webhooks", adminPageController.Webhooks)
+	adminPages.GET("/flags", adminPageController.Flags)
+	adminPages.POST("/flags", adminPageController.SetFlag)
+	adminPages.GET("/audit", adminPageController.Audit)
+
+	// Embedded frontend
+	router.GET("/ui", func(ctx echo.Context) error {
+		return ctx.Redirect(http.StatusMovedPermanently, "ui/")
+	})
+	router.GET("/ui/*", static.Handler("/ui"))
+
+	// gRPC server
+	// The HTTP middleware does not see RPCs, the same IP filter, API key quotas and write auth are interceptors
+	grpcConfig := grpcserver.ConfigFromEnv()
+	var grpcInterceptors []grpc.UnaryServerInterceptor
+	if ipFilter.Enabled() {
+		grpcInterceptors = append(grpcInterceptors, grpcserver.IPFilter(ipFilter))
+	}
+	if apiKeysEnabled {
+		grpcInterceptors = append(grpcInterceptors, grpcserver.APIKeys(&service.APIKeyService{}))
+	}
+	if grpcWriteAuth != nil {
+		grpcInterceptors = append(grpcInterceptors, grpcserver.WriteAuth(grpcWriteAuth, grpcserver.WriteMethods...))
+	}
+	grpcInterceptors = append(grpcInterceptors, grpcserver.ReadOnly(grpcserver.WriteMethods...))
+	grpcServer := grpcserver.New(grpcConfig, grpcInterceptors...)
+	if grpcServer != nil {
+		samplev1.RegisterSampleServiceServer(grpcServer, &grpcserver.SampleServer{})
+		group.Go(func() error {
+			if err := grpcServer.Run(ctx, grpcConfig); err != nil {
+				return fmt.Errorf("grpc server failed: %w", err)
+			}
+			return nil
+		})
+	}
 
 	// Start server
-	if err := router.Start(":8080"); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		slog.Error("failed to start server", "error", err)
+	group.Go(func() error {
+		if err := router.Start(config.String("HTTP_ADDR", ":8080")); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("http server failed: %w", err)
+		}
+		return nil
+	})
+
+	// SIGTERM or the first listener to fail
+	<-ctx.Done()
+	shutdown(router.Echo, grpcServer, jobRunner, webhookPool, requestRecorder, sampleStats)
+	stopReason := "graceful shutdown"
+	err = group.Wait()
+	if err != nil {
+		slog.Error("shut down after a component failed", "error", err)
+		stopReason = err.Error()
+	}
+	if err := uptimeService.RecordStop(context.Background(), stopReason); err != nil {
+		slog.Error("failed to record the shutdown marker", "error", err)
+	}
+	if err != nil {
+		// A non-zero exit shows as an Error termination and counts towards the restart backoff
+		shutdownTracing(context.Background())
+		os.Exit(1)
+	}
+}
+
+// seedMock fills the mock database from MOCK_SEED_FILE, a JSON array of samples,
+// or with MOCK_SEED_SAMPLES generated ones
+func seedMock(ctx context.Context) {
+	generatorService := service.GeneratorService{}
+	if path := config.String("MOCK_SEED_FILE", ""); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			slog.Error("failed to open MOCK_SEED_FILE", "error", err)
+			panic("failed to open MOCK_SEED_FILE")
+		}
+		defer file.Close()
+		loaded, err := generatorService.LoadSamples(ctx, file)
+		if err != nil {
+			slog.Error("failed to load MOCK_SEED_FILE", "error", err)
+			panic("failed to load MOCK_SEED_FILE")
+		}
+		slog.Info("seeded mock database", "samples", loaded, "file", path)
+		return
+	}
+
+	generated, err := generatorService.GenerateSamples(ctx, config.Int("MOCK_SEED_SAMPLES", 100))
+	if err != nil {
+		slog.Error("failed to seed mock database", "error", err)
+		return
+	}
+	slog.Info("seeded mock database", "samples", generated)
+}
+
+// shutdown stops taking requests, then drains in-flight requests and RPCs, jobs, webhook processing and
+// recorded requests and sample stats within SHUTDOWN_TIMEOUT, which must stay below the pod's terminationGracePeriodSeconds
+func shutdown(router *echo.Echo, grpcServer *grpcserver.Server, jobRunner *jobs.Runner, webhookPool *workerpool.Pool, requestRecorder *recorder.Recorder, sampleStats *service.SampleStats) {
+	timeout := config.Duration("SHUTDOWN_TIMEOUT", 25*time.Second)
+	slog.Info("shutting down", "timeout", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := router.Shutdown(ctx); err != nil {
+		slog.Error("failed to drain http server", "error", err)
+	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(ctx); err != nil {
+			slog.Error("failed to drain grpc server", "error", err)
+		}
+	}
+	if jobRunner != nil {
+		if err := jobRunner.Shutdown(ctx); err != nil {
+			slog.Error("failed to drain job runner", "error", err)
+		}
+	}
+	if err := webhookPool.Shutdown(ctx); err != nil {
+		slog.Error("failed to drain webhook processing", "error", err)
+	}
+	if requestRecorder != nil {
+		if err := requestRecorder.Shutdown(ctx); err != nil {
+			slog.Error("failed to flush recorded requests", "error", err)
+		}
+	}
+	if sampleStats != nil {
+		if err := sampleStats.Flush(ctx); err != nil {
+			slog.Error("failed to flush sample stats", "error", err)
+		}
+	}
+	// Background goroutines stop with the signal context, the ones left after a moment are logged
+	waitCtx, cancelWait := context.WithTimeout(ctx, 2*time.Second)
+	defer cancelWait()
+	if left := runtimeutil.Wait(waitCtx); len(left) > 0 {
+		slog.Info("background goroutines still running at exit", "goroutines", left)
 	}
 }
 
 // Handler
 func hello(ctx echo.Context) error {
+	// The canary answers with a JSON shape of its own, so a traffic split shows in every response
+	if serving.IsCanary(ctx) {
+		return ctx.JSON(http.StatusOK, map[string]any{
+			"message":      "Hello, World!",
+			"version":      config.String("VERSION", ""),
+			"variant":      serving.VariantCanary,
+			"announcement": announcement.Current(),
+		})
+	}
+	if banner := announcement.Current(); banner.Message != "" {
+		return ctx.String(http.StatusOK, "Hello, World!\n\n["+banner.Level+"] "+banner.Message+"\n")
+	}
 	return ctx.String(http.StatusOK, "Hello, World!")
 }
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"app/config"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var labelOverflows = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: Namespace,
+	Subsystem: "metrics",
+	Name:      "label_overflows_total",
+	Help:      "Observations recorded under the overflow value because a label reached its limit of distinct values.",
+}, []string{"label"})
+
+// LabelGuard bounds the distinct values of a label: once Max values were seen, new ones are
+// recorded as Overflow, so a client sending random values cannot grow the series without bound
+type LabelGuard struct {
+	Name     string
+	Max      int
+	Overflow string
+
+	mu   sync.RWMutex
+	seen map[string]struct{}
+}
+
+func NewLabelGuard(name string, max int, overflow string) *LabelGuard {
+	return &LabelGuard{Name: name, Max: max, Overflow: overflow, seen: map[string]struct{}{}}
+}
+
+// Value returns value while it is one of the first Max seen, Overflow afterwards
+func (g *LabelGuard) Value(value string) string {
+	g.mu.RLock()
+	_, ok := g.seen[value]
+	g.mu.RUnlock()
+	if ok {
+		return value
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.seen[value]; ok {
+		return value
+	}
+	if len(g.seen) >= g.Max {
+		labelOverflows.WithLabelValues(g.Name).Inc()
+		return g.Overflow
+	}
+	g.seen[value] = struct{}{}
+	return value
+}
+
+var (
+	// unmatchedPaths keeps the paths of requests matching no route, METRICS_UNMATCHED_PATHS
+	unmatchedPaths = config.Bool("METRICS_UNMATCHED_PATHS", false)
+	unmatchedGuard = NewLabelGuard("route", config.Int("METRICS_MAX_UNMATCHED_PATHS", 100), "unmatched")
+)
+
+// Route is the route label of a request: the route pattern it matched, which is bounded by the
+// routes registered. Requests matching none are "unmatched", or with METRICS_UNMATCHED_PATHS their
+// path with the IDs collapsed, at most METRICS_MAX_UNMATCHED_PATHS of them.
+func Route(pattern, path string) string {
+	if pattern != "" {
+		return pattern
+	}
+	if !unmatchedPaths {
+		return "unmatched"
+	}
+	return unmatchedGuard.Value(CollapseIDs(path))
+}
+
+// Method is the method label, anything but the standard methods is OTHER
+func Method(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	// hexSegment catches hashes and object IDs, longSegment tokens and other opaque keys
+	hexSegment  = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	longSegment = regexp.MustCompile(`^[A-Za-z0-9_-]{24,}$`)
+)
+
+// CollapseIDs replaces the path segments that look like identifiers, numbers, UUIDs, hex digests
+// and long opaque tokens, with :id
+func CollapseIDs(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if isID(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func isID(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if strings.Trim(segment, "0123456789") == "" {
+		return true
+	}
+	if uuidSegment.MatchString(segment) || hexSegment.MatchString(segment) {
+		return true
+	}
+	// Long words, such as the slug of a page, are kept
+	return longSegment.MatchString(segment) && strings.ContainsAny(segment, "0123456789")
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import "testing"
+
+func TestCollapseIDs(t *testing.T) {
+	tests := map[string]string{
+		"/sample/42": "/sample/:id",
+		"/sample/0b9f3a6e-8d4c-4f4b-9a4e-6f1f2d3c4b5a/history":                    "/sample/:id/history",
+		"/files/9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08": "/files/:id",
+		"/tokens/eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9":                            "/tokens/:id",
+		"/docs/getting-started-with-kubernetes-ingress":                           "/docs/getting-started-with-kubernetes-ingress",
+		"/v2/samples": "/v2/samples",
+		"/":           "/",
+	}
+	for path, want := range tests {
+		if got := CollapseIDs(path); got != want {
+			t.Errorf("CollapseIDs(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestLabelGuard(t *testing.T) {
+	guard := NewLabelGuard("test", 2, "other")
+	for _, value := range []string{"a", "b", "a"} {
+		if got := guard.Value(value); got != value {
+			t.Errorf("Value(%q) = %q within the limit", value, got)
+		}
+	}
+	if got := guard.Value("c"); got != "other" {
+		t.Errorf("Value(c) = %q past the limit, want other", got)
+	}
+	if got := guard.Value("b"); got != "b" {
+		t.Errorf("Value(b) = %q, values seen before the limit keep their label", got)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	if got := Route("/sample/:id", "/sample/42"); got != "/sample/:id" {
+		t.Errorf("matched route = %q", got)
+	}
+	if got := Route("", "/wp-login.php"); got != "unmatched" {
+		t.Errorf("unmatched route = %q", got)
+	}
+	if got := Method("PROPFIND"); got != "OTHER" {
+		t.Errorf("Method(PROPFIND) = %q", got)
+	}
+}
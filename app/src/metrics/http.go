@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"app/tracing"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+// ResponseStatus is the status of the response to a handler returning err. An error the handler
+// returned before writing is answered by the error handler after the middlewares, with the code of
+// an *echo.HTTPError or 500, so middlewares recording the status have to derive it from err.
+func ResponseStatus(ctx echo.Context, err error) int {
+	res := ctx.Response()
+	if err == nil || res.Committed {
+		return res.Status
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// Middleware records request counts and latency. When the request is traced the latency
+// observation carries the trace ID as an exemplar, so Grafana can link a spike to a trace.
+// It must run after the tracing middleware so the span is already in the request context.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			started := time.Now()
+			err := next(ctx)
+
+			status := ResponseStatus(ctx, err)
+			route := Route(ctx.Path(), ctx.Request().URL.Path)
+			labels := []string{Method(ctx.Request().Method), route, strconv.Itoa(status)}
+			elapsed := time.Since(started).Seconds()
+
+			httpRequests.WithLabelValues(labels...).Inc()
+			observer := httpDuration.WithLabelValues(labels...)
+			if traceID := tracing.TraceID(ctx.Request().Context()); traceID != "" {
+				observer.(prometheus.ExemplarObserver).ObserveWithExemplar(elapsed, prometheus.Labels{"trace_id": traceID})
+			} else {
+				observer.Observe(elapsed)
+			}
+			return err
+		}
+	}
+}
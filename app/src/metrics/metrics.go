@@ -0,0 +1,18 @@
+package metrics
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric exported by the app
+const Namespace = "app"
+
+// Handler serves the default Prometheus registry, subsystems register their collectors with promauto.
+// OpenMetrics is negotiated so exemplars are exposed to scrapers that ask for them.
+func Handler() echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	}))
+}
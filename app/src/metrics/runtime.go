@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"app/config"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// InitRuntime replaces the default Go collector with one also exporting runtime/metrics next to
+// the go_memstats_* set: GC pauses and cycles, GOGC and GOMEMLIMIT as in effect, heap classes and
+// scheduler latency, for watching how the memory settings behave under the pod's limits.
+// RUNTIME_METRICS=false keeps the default collector.
+func InitRuntime() {
+	if !config.Bool("RUNTIME_METRICS", true) {
+		return
+	}
+	prometheus.Unregister(collectors.NewGoCollector())
+	prometheus.MustRegister(collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+		collectors.MetricsGC,
+		collectors.MetricsMemory,
+		collectors.MetricsScheduler,
+	)))
+	slog.Info("go runtime metrics are exported")
+}
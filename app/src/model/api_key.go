@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APIKey only stores the SHA-256 of the key handed to the client, Prefix identifies it in listings.
+// A zero quota is unlimited.
+type APIKey struct {
+	ID           string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Name         string     `gorm:"type:varchar(64)" json:"name"`
+	Prefix       string     `gorm:"type:varchar(16)" json:"prefix"`
+	KeyHash      string     `gorm:"type:char(64);uniqueIndex" json:"-"`
+	DailyQuota   int64      `json:"daily_quota"`
+	MonthlyQuota int64      `json:"monthly_quota"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k *APIKey) BeforeCreate(tx *gorm.DB) (err error) {
+	if k.ID == "" {
+		k.ID = uuid.New().String()
+	}
+	return
+}
+
+// APIKeyUsage counts the requests of a key in one period, a day (2006-01-02) or a month (2006-01)
+type APIKeyUsage struct {
+	KeyID     string    `gorm:"primaryKey;type:varchar(36)" json:"-"`
+	Period    string    `gorm:"primaryKey;type:varchar(10)" json:"period"`
+	Requests  int64     `json:"requests"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
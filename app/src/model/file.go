@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// File is the metadata of an uploaded file, the content is the object StorageKey in object storage
+type File struct {
+	ID          string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Name        string    `gorm:"type:varchar(255)" json:"name"`
+	ContentType string    `gorm:"type:varchar(127)" json:"content_type"`
+	Size        int64     `json:"size"`
+	// SHA256 is the hex digest of the content, computed while it was uploaded
+	SHA256     string `gorm:"type:char(64)" json:"sha256"`
+	StorageKey string `gorm:"type:varchar(255)" json:"-"`
+	// ScanStatus is one of the FileScan* states, ScanResult the signature found or the scan error
+	ScanStatus string     `gorm:"type:varchar(16);index" json:"scan_status"`
+	ScanResult string     `gorm:"type:varchar(255)" json:"scan_result,omitempty"`
+	ScannedAt  *time.Time `json:"scanned_at,omitempty"`
+}
+
+// Scan states of a File. Only clean and skipped files are served.
+const (
+	// FileScanSkipped is an upload made while no scanner was configured
+	FileScanSkipped = "skipped"
+	FileScanPending = "pending"
+	FileScanClean   = "clean"
+	// FileScanInfected files are moved to the quarantine prefix of object storage
+	FileScanInfected = "infected"
+	// FileScanFailed is a scan that failed, it is retried by the job queue
+	FileScanFailed = "failed"
+)
+
+func (f *File) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+)
+
+// Higher priorities are claimed first among jobs that are due, the zero value is normal
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// Job is a unit of background work, claimed by one replica at a time
+type Job struct {
+	ID          string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Type        string     `gorm:"type:varchar(128);index" json:"type"`
+	Payload     string     `gorm:"type:mediumtext" json:"payload"`
+	Status      string     `gorm:"type:varchar(16);index:idx_job_ready,priority:1" json:"status"`
+	Priority    int        `gorm:"index:idx_job_ready,priority:2" json:"priority"`
+	RunAt       time.Time  `gorm:"index:idx_job_ready,priority:3" json:"run_at"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	LockedBy    string     `gorm:"type:varchar(255)" json:"locked_by,omitempty"`
+	LockedAt    *time.Time `json:"locked_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	// TraceContext is the JSON propagation carrier of the request that enqueued the job
+	TraceContext string `gorm:"type:varchar(1024)" json:"-"`
+}
+
+func (j *Job) BeforeCreate(tx *gorm.DB) (err error) {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return
+}
+
+// DeadJob keeps a job that exhausted its retries until an operator requeues or discards it
+type DeadJob struct {
+	ID        string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	FailedAt  time.Time `gorm:"index" json:"failed_at"`
+	Type      string    `gorm:"type:varchar(128);index" json:"type"`
+	Payload   string    `gorm:"type:mediumtext" json:"payload"`
+	Priority  int       `json:"priority"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `gorm:"type:text" json:"last_error"`
+	// TraceContext is kept so a requeued job still links to the request that enqueued it
+	TraceContext string `gorm:"type:varchar(1024)" json:"-"`
+}
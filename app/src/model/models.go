@@ -0,0 +1,10 @@
+package model
+
+// All is every model the application migrates
+var All = []any{
+	&Sample{}, &SampleRevision{}, &SampleLabel{}, &SampleStat{},
+	&WebhookEvent{}, &User{}, &RefreshToken{}, &RevokedToken{},
+	&Job{}, &DeadJob{}, &APIKey{}, &APIKeyUsage{},
+	&SessionRecord{}, &File{}, &UploadSession{}, &UploadPart{},
+	&ProcessEvent{}, &TenantLimit{}, &TenantUsage{}, &RuntimeFlag{},
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// ProcessEvent is one run of the process in a pod, recorded when it starts. StoppedAt and StopReason
+// are the shutdown marker written on a graceful shutdown, a run without one was killed or crashed.
+type ProcessEvent struct {
+	ID         string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Pod        string     `gorm:"type:varchar(253);index:idx_process_events_pod_started,priority:1" json:"pod"`
+	Version    string     `gorm:"type:varchar(64)" json:"version,omitempty"`
+	StartedAt  time.Time  `gorm:"index:idx_process_events_pod_started,priority:2" json:"started_at"`
+	StoppedAt  *time.Time `json:"stopped_at,omitempty"`
+	StopReason string     `gorm:"type:varchar(255)" json:"stop_reason,omitempty"`
+}
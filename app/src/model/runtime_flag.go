@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// RuntimeFlag is a switch flipped through the admin API that every replica picks up, such as read-only mode
+type RuntimeFlag struct {
+	Name      string    `gorm:"primaryKey;type:varchar(64)" json:"name"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -8,11 +8,15 @@ import (
 )
 
 type Sample struct {
-	ID        string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
+	// ID is a random UUID rather than an autoincrement key, so it is already opaque in the
+	// API and /sample/:id cannot be enumerated
+	ID        string         `gorm:"primaryKey;type:varchar(36);index:idx_samples_created_at_id,priority:2;index:idx_samples_updated_at_id,priority:2" json:"id"`
+	CreatedAt time.Time      `gorm:"index:idx_samples_created_at_id,priority:1" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"index:idx_samples_updated_at_id,priority:1" json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 	Message   string         `json:"message"`
+	// Key is an optional natural key chosen by the client, PUT /sample upserts by it
+	Key *string `gorm:"column:natural_key;type:varchar(64);uniqueIndex" json:"key,omitempty"`
 }
 
 func (s *Sample) BeforeCreate(tx *gorm.DB) (err error) {
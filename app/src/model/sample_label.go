@@ -0,0 +1,8 @@
+package model
+
+// SampleLabel is one key=value label of a Sample, selected with Kubernetes-style label selectors
+type SampleLabel struct {
+	SampleID string `gorm:"primaryKey;type:varchar(36)" json:"-"`
+	Name     string `gorm:"primaryKey;type:varchar(317);index:idx_sample_labels_name_value,priority:1" json:"name"`
+	Value    string `gorm:"type:varchar(63);index:idx_sample_labels_name_value,priority:2" json:"value"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Actions a SampleRevision records
+const (
+	SampleActionCreate = "create"
+	SampleActionUpdate = "update"
+	SampleActionDelete = "delete"
+	SampleActionRevert = "revert"
+)
+
+// SampleRevision records one write to a Sample with the old and new value of every field it changed.
+// Revisions are numbered per sample from 1.
+type SampleRevision struct {
+	ID        string        `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	SampleID  string        `gorm:"type:varchar(36);uniqueIndex:idx_sample_revision,priority:1" json:"sample_id"`
+	Revision  int           `gorm:"uniqueIndex:idx_sample_revision,priority:2" json:"revision"`
+	Action    string        `gorm:"type:varchar(16)" json:"action"`
+	Changes   []FieldChange `gorm:"type:text;serializer:json" json:"changes"`
+	// RevertedTo is the revision a revert restored
+	RevertedTo int `json:"reverted_to,omitempty"`
+}
+
+// FieldChange is the value of a field before and after a write, nil when it was unset
+type FieldChange struct {
+	Field string  `json:"field"`
+	Old   *string `json:"old"`
+	New   *string `json:"new"`
+}
+
+func (r *SampleRevision) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return
+}
@@ -0,0 +1,12 @@
+package model
+
+// SampleStat counts the Sample writes of one UTC day, for all samples when Tag is empty
+// or for those labelled name=value when it is set. It is a read model kept by the events
+// of the samples table, never written by request handlers.
+type SampleStat struct {
+	Day     string `gorm:"primaryKey;type:varchar(10)" json:"day"`
+	Tag     string `gorm:"primaryKey;type:varchar(381)" json:"tag,omitempty"`
+	Created int64  `json:"created"`
+	Updated int64  `json:"updated"`
+	Deleted int64  `json:"deleted"`
+}
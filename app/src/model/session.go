@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// SessionRecord holds the sessions started while redis is unreachable, the JSON the redis store keeps.
+// Deleted marks a session logged out while redis was unreachable, so its redis copy is no longer valid.
+type SessionRecord struct {
+	ID        string `gorm:"primaryKey;type:varchar(64)"`
+	UserID    string `gorm:"type:varchar(36);index"`
+	Data      []byte
+	Deleted   bool
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// TenantLimit is the rate limit and daily quota of a tenant, the tenant member of the request
+// baggage. Tenants without one are rejected. Zero leaves that limit unlimited for the tenant.
+type TenantLimit struct {
+	Tenant            string    `gorm:"primaryKey;type:varchar(64)" json:"tenant"`
+	RequestsPerMinute int       `json:"requests_per_minute"`
+	DailyQuota        int64     `json:"daily_quota"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TenantUsage counts the requests of a tenant in one day (2006-01-02, UTC)
+type TenantUsage struct {
+	Tenant    string    `gorm:"primaryKey;type:varchar(64)" json:"tenant"`
+	Day       string    `gorm:"primaryKey;type:varchar(10)" json:"day"`
+	Requests  int64     `json:"requests"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefreshToken only stores the SHA-256 of the token handed to the client.
+// Tokens issued from the same login share a FamilyID so reuse of a rotated token can revoke the whole chain.
+type RefreshToken struct {
+	ID         string `gorm:"primaryKey;type:varchar(36)"`
+	CreatedAt  time.Time
+	UserID     string `gorm:"type:varchar(36);index"`
+	Username   string `gorm:"type:varchar(64)"`
+	FamilyID   string `gorm:"type:varchar(36);index"`
+	TokenHash  string `gorm:"type:char(64);uniqueIndex"`
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy string `gorm:"type:varchar(36)"`
+}
+
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return
+}
+
+// RevokedToken is the revocation list for access tokens that have not expired yet
+type RevokedToken struct {
+	JTI       string `gorm:"primaryKey;type:varchar(36)"`
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// UploadSession is a resumable upload in progress. Its chunks are kept as separate objects in object
+// storage until the upload is completed into a File.
+type UploadSession struct {
+	ID          string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+	Name        string    `gorm:"type:varchar(255)" json:"name"`
+	ContentType string    `gorm:"type:varchar(127)" json:"content_type"`
+	// Size is the length of the whole file, declared when the upload is created
+	Size int64 `json:"size"`
+	// Offset is how much of it has been received, the next chunk must start there
+	Offset int64 `gorm:"column:upload_offset" json:"offset"`
+}
+
+// UploadPart is one chunk of an UploadSession, the object Key holds bytes Offset to Offset+Size of the file
+type UploadPart struct {
+	UploadID string `gorm:"primaryKey;type:varchar(36)" json:"-"`
+	Offset   int64  `gorm:"primaryKey;column:part_offset;autoIncrement:false" json:"offset"`
+	Size     int64  `json:"size"`
+	Key      string `gorm:"type:varchar(255)" json:"-"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	_ "app/fieldcrypt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type User struct {
+	ID            string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	Username      string         `gorm:"type:varchar(64);uniqueIndex" json:"username"`
+	Email         string         `gorm:"type:varchar(512);serializer:encrypted" json:"email,omitempty"`
+	PasswordHash  string         `gorm:"type:varchar(255)" json:"-"`
+	FailedLogins  int            `json:"-"`
+	LockedUntil   *time.Time     `json:"-"`
+	PasswordSetAt time.Time      `json:"password_set_at"`
+}
+
+func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
+	if u.ID == "" {
+		u.ID = uuid.New().String()
+	}
+	return
+}
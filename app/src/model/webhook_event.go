@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebhookEvent struct {
+	ID          string     `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Provider    string     `gorm:"type:varchar(64);uniqueIndex:idx_webhook_delivery" json:"provider"`
+	DeliveryID  string     `gorm:"type:varchar(128);uniqueIndex:idx_webhook_delivery" json:"delivery_id"`
+	EventType   string     `gorm:"type:varchar(128)" json:"event_type"`
+	Payload     string     `gorm:"type:mediumtext" json:"payload"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+func (e *WebhookEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.DeliveryID == "" {
+		e.DeliveryID = e.ID
+	}
+	return
+}
@@ -0,0 +1,36 @@
+package notification
+
+import (
+	"app/events"
+	"app/jobs"
+	"app/model"
+	"context"
+	"log/slog"
+)
+
+// mailJob sends the sample notification through the job queue so SMTP failures are retried
+const mailJob = "mail.sample_created"
+
+// Init subscribes the mail notifications to Sample events when SMTP is configured
+func Init() {
+	cfg := SMTPConfigFromEnv()
+	if cfg.Host == "" || len(cfg.To) == 0 {
+		slog.Info("mail notifications are disabled")
+		return
+	}
+
+	sender := NewSMTPSender(cfg)
+	jobs.Register(mailJob, func(ctx context.Context, job *model.Job) error {
+		var sample model.Sample
+		if err := jobs.Decode(job, &sample); err != nil {
+			return err
+		}
+		return sender.Send(cfg.To, "sample_created", sample)
+	})
+	events.Subscribe(events.SampleCreated, func(ctx context.Context, event events.Event) {
+		if _, err := jobs.Enqueue(ctx, mailJob, event.Payload, jobs.Options{}); err != nil {
+			slog.Error("failed to enqueue sample notification", "error", err)
+		}
+	})
+	slog.Info("mail notifications are enabled", "smtp_host", cfg.Host, "to", cfg.To)
+}
@@ -0,0 +1,26 @@
+package notification
+
+import "context"
+
+type Message struct {
+	Subject string
+	Text    string
+}
+
+// Notifier delivers a free-form message to an operator channel
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// MultiNotifier fans a message out to every notifier and returns the first error
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) Notify(ctx context.Context, msg Message) error {
+	var firstErr error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, msg); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,50 @@
+package notification
+
+import (
+	"app/httpclient"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SlackNotifier posts to a Slack-compatible incoming webhook
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		WebhookURL: webhookURL,
+		Client:     httpclient.New(10 * time.Second),
+	}
+}
+
+func (s *SlackNotifier) Notify(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", msg.Subject, msg.Text),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post slack webhook: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package notification
+
+import (
+	"app/config"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTPConfigFromEnv defaults to MailHog's SMTP port so local and cluster demos need no credentials
+func SMTPConfigFromEnv() SMTPConfig {
+	return SMTPConfig{
+		Host:     config.String("SMTP_HOST", ""),
+		Port:     config.String("SMTP_PORT", "1025"),
+		Username: config.String("SMTP_USERNAME", ""),
+		Password: config.String("SMTP_PASSWORD", ""),
+		From:     config.String("SMTP_FROM", "k8s-sample-app@example.com"),
+		To:       config.List("NOTIFY_EMAIL_TO"),
+	}
+}
+
+type SMTPSender struct {
+	Config SMTPConfig
+}
+
+func NewSMTPSender(cfg SMTPConfig) *SMTPSender {
+	return &SMTPSender{Config: cfg}
+}
+
+// Send renders the named template with data and mails it to the recipients
+func (s *SMTPSender) Send(to []string, name string, data any) error {
+	subject, body, err := render(name, data)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Config.Username != "" {
+		auth = smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)
+	}
+
+	addr := net.JoinHostPort(s.Config.Host, s.Config.Port)
+	if err := smtp.SendMail(addr, auth, s.Config.From, to, s.message(to, subject, body)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	slog.Info("mail sent", "template", name, "to", to)
+	return nil
+}
+
+// Notify mails the message to the configured recipients
+func (s *SMTPSender) Notify(ctx context.Context, msg Message) error {
+	return s.Send(s.Config.To, "alert", msg)
+}
+
+func (s *SMTPSender) message(to []string, subject, body string) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.Config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body)
+	return msg.Bytes()
+}
@@ -0,0 +1,26 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.tmpl"))
+
+// render executes the "<name>.subject" and "<name>.body" templates
+func render(name string, data any) (string, string, error) {
+	var subject, body bytes.Buffer
+	if err := templates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return "", "", fmt.Errorf("failed to render mail subject: %w", err)
+	}
+	if err := templates.ExecuteTemplate(&body, name+".body", data); err != nil {
+		return "", "", fmt.Errorf("failed to render mail body: %w", err)
+	}
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
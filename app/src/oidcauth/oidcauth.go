@@ -0,0 +1,200 @@
+package oidcauth
+
+import (
+	"app/config"
+	"app/httpclient"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+const claimsKey = "oidc.claims"
+
+var (
+	ErrNonceMismatch = errors.New("oidc nonce mismatch")
+	// ErrNoAudience is returned when neither OIDC_AUDIENCE nor OIDC_CLIENT_ID is set,
+	// bearer tokens issued to other clients of the issuer could not be told apart
+	ErrNoAudience = errors.New("oidc needs OIDC_AUDIENCE or OIDC_CLIENT_ID to check whom access tokens are for")
+	// ErrWrongAudience is returned for an access token issued for another client
+	ErrWrongAudience = errors.New("access token is not for this api")
+)
+
+// Claims are the identity fields the app cares about from ID and access tokens
+type Claims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+}
+
+// DisplayName picks the most human friendly identifier available
+func (c *Claims) DisplayName() string {
+	for _, name := range []string{c.PreferredUsername, c.Email, c.Name} {
+		if name != "" {
+			return name
+		}
+	}
+	return c.Subject
+}
+
+type Provider struct {
+	OAuth2         oauth2.Config
+	idVerifier     *oidc.IDTokenVerifier
+	accessVerifier *oidc.IDTokenVerifier
+	// audience and clientID are what an access token's aud or azp must name
+	audience string
+	clientID string
+}
+
+// NewFromEnv discovers the issuer configuration, it returns nil when OIDC_ISSUER_URL is unset
+func NewFromEnv(ctx context.Context) (*Provider, error) {
+	issuer := config.String("OIDC_ISSUER_URL", "")
+	if issuer == "" {
+		slog.Info("OIDC_ISSUER_URL is not set, oidc login is disabled")
+		return nil, nil
+	}
+	clientID := config.String("OIDC_CLIENT_ID", "")
+	// Keycloak and Dex put the client in azp rather than aud for access tokens,
+	// so a token is accepted when aud holds OIDC_AUDIENCE or azp is OIDC_CLIENT_ID
+	audience := config.String("OIDC_AUDIENCE", "")
+	if audience == "" && clientID == "" {
+		return nil, ErrNoAudience
+	}
+
+	// Discovery and the key set refreshes it starts use the traced client
+	ctx = oidc.ClientContext(ctx, httpclient.Default)
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover oidc issuer: %w", err)
+	}
+
+	scopes := config.List("OIDC_SCOPES")
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+
+	slog.Info("oidc login is enabled", "issuer", issuer, "client_id", clientID)
+	return &Provider{
+		OAuth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: config.String("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:  config.String("OIDC_REDIRECT_URL", ""),
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		idVerifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+		// aud and azp are checked together by VerifyBearer
+		accessVerifier: provider.Verifier(&oidc.Config{SkipClientIDCheck: true}),
+		audience:       audience,
+		clientID:       clientID,
+	}, nil
+}
+
+// AuthCodeURL returns the redirect to the identity provider together with the state and nonce to remember
+func (p *Provider) AuthCodeURL() (url, state, nonce string, err error) {
+	if state, err = randomString(); err != nil {
+		return
+	}
+	if nonce, err = randomString(); err != nil {
+		return
+	}
+	url = p.OAuth2.AuthCodeURL(state, oidc.Nonce(nonce))
+	return
+}
+
+// Exchange trades the authorization code for tokens and verifies the ID token
+func (p *Provider) Exchange(ctx context.Context, code, nonce string) (*Claims, error) {
+	token, err := p.OAuth2.Exchange(context.WithValue(ctx, oauth2.HTTPClient, httpclient.Default), code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id_token")
+	}
+	idToken, err := p.idVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id_token: %w", err)
+	}
+
+	claims := &Claims{}
+	if err := idToken.Claims(claims); err != nil {
+		return nil, err
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+	return claims, nil
+}
+
+// VerifyBearer verifies an access token issued by the provider and returns its claims
+func (p *Provider) VerifyBearer(ctx context.Context, raw string) (*Claims, error) {
+	token, err := p.accessVerifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims{}
+	if err := token.Claims(claims); err != nil {
+		return nil, err
+	}
+	if !p.intendedFor(token.Audience, claims.AuthorizedParty) {
+		return nil, ErrWrongAudience
+	}
+	return claims, nil
+}
+
+// intendedFor reports whether a token with the aud and azp claims given was issued for this api
+func (p *Provider) intendedFor(audience []string, authorizedParty string) bool {
+	if p.audience != "" && slices.Contains(audience, p.audience) {
+		return true
+	}
+	return p.clientID != "" && authorizedParty == p.clientID
+}
+
+// BearerMiddleware rejects API requests without a valid access token issued by the provider
+func (p *Provider) BearerMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			raw, found := strings.CutPrefix(ctx.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !found || raw == "" {
+				ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="app"`)
+				return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "missing bearer token"})
+			}
+
+			claims, err := p.VerifyBearer(ctx.Request().Context(), raw)
+			if err != nil {
+				ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="app", error="invalid_token"`)
+				return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid bearer token"})
+			}
+			ctx.Set(claimsKey, claims)
+			return next(ctx)
+		}
+	}
+}
+
+// ClaimsFrom returns the claims of the verified bearer token, or nil
+func ClaimsFrom(ctx echo.Context) *Claims {
+	claims, _ := ctx.Get(claimsKey).(*Claims)
+	return claims
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
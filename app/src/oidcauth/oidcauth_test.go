@@ -0,0 +1,25 @@
+package oidcauth
+
+import "testing"
+
+func TestIntendedFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		audience []string
+		azp      string
+		want     bool
+	}{
+		{"audience in aud", Provider{audience: "api"}, []string{"account", "api"}, "other", true},
+		{"other audience", Provider{audience: "api"}, []string{"account"}, "other", false},
+		{"client in azp", Provider{clientID: "app"}, []string{"account"}, "app", true},
+		{"other client in azp", Provider{clientID: "app"}, []string{"account"}, "other", false},
+		{"either matching", Provider{audience: "api", clientID: "app"}, nil, "app", true},
+		{"no azp", Provider{clientID: "app"}, []string{"app"}, "", false},
+	}
+	for _, tt := range tests {
+		if got := tt.provider.intendedFor(tt.audience, tt.azp); got != tt.want {
+			t.Errorf("%s: intendedFor = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
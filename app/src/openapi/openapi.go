@@ -0,0 +1,220 @@
+// Package openapi serves the API description at /openapi.json and checks responses against it,
+// in the contract tests and, with OPENAPI_VALIDATE_RESPONSES=true in development, at runtime.
+// With OPENAPI_VALIDATE_REQUESTS=true requests are checked before they reach the handlers.
+// The request and response types and the echo server interface in app/gen/api are generated from the same spec, see oapi-codegen.yaml.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Handler serves the embedded spec
+func Handler(ctx echo.Context) error {
+	return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, spec)
+}
+
+// Validator checks responses of the operations described in the spec
+type Validator struct {
+	doc *openapi3.T
+}
+
+// NewValidator loads the embedded spec and fails when it is not a valid OpenAPI document
+func NewValidator() (*Validator, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	return &Validator{doc: doc}, nil
+}
+
+// options makes violations name the failing field without the whole schema, which would drown it.
+// It is set per validation, openapi3.SchemaErrorDetailsDisabled would change every user of kin-openapi.
+func options(o *openapi3filter.Options) *openapi3filter.Options {
+	o.WithCustomSchemaErrorFunc(schemaError)
+	return o
+}
+
+func schemaError(err *openapi3.SchemaError) string {
+	reason := err.Reason
+	if err.Origin != nil {
+		reason = err.Origin.Error()
+	} else if reason == "" {
+		reason = fmt.Sprintf("doesn't match schema %q", err.SchemaField)
+	}
+	if pointer := err.JSONPointer(); len(pointer) > 0 {
+		return fmt.Sprintf("Error at %q: %s", "/"+strings.Join(pointer, "/"), reason)
+	}
+	return reason
+}
+
+// Paths lists the spec's paths in Echo's notation, /sample/{id} as /sample/:id
+func (v *Validator) Paths() []string {
+	var paths []string
+	for _, path := range v.doc.Paths.InMatchingOrder() {
+		paths = append(paths, toEcho(path))
+	}
+	return paths
+}
+
+func toEcho(path string) string {
+	return strings.NewReplacer("{", ":", "}", "").Replace(path)
+}
+
+func fromEcho(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// route finds the operation of the route the request was matched to, nil when the spec does not describe it
+func (v *Validator) route(ctx echo.Context) (*routers.Route, map[string]string) {
+	req := ctx.Request()
+	path := fromEcho(ctx.Path())
+	item := v.doc.Paths.Value(path)
+	if item == nil {
+		return nil, nil
+	}
+	operation := item.GetOperation(req.Method)
+	if operation == nil {
+		return nil, nil
+	}
+
+	params := map[string]string{}
+	for i, name := range ctx.ParamNames() {
+		params[name] = ctx.ParamValues()[i]
+	}
+	return &routers.Route{Spec: v.doc, Path: path, PathItem: item, Method: req.Method, Operation: operation}, params
+}
+
+// Validate checks one response of the route the request was matched to.
+// Routes the spec does not describe are not checked.
+func (v *Validator) Validate(ctx echo.Context, status int, header http.Header, body []byte) error {
+	route, params := v.route(ctx)
+	if route == nil {
+		return nil
+	}
+	req := ctx.Request()
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: params,
+			Route:      route,
+		},
+		Status:  status,
+		Header:  header,
+		Body:    io.NopCloser(bytes.NewReader(body)),
+		Options: options(&openapi3filter.Options{IncludeResponseStatus: true, MultiError: true}),
+	}
+	return openapi3filter.ValidateResponse(req.Context(), input)
+}
+
+// ValidateRequest checks the parameters and body of a request against its operation, leaving the
+// body readable for the handler. Authentication is left to the auth middleware and defaults of the
+// spec are not filled in, the handlers apply their own. Routes the spec does not describe pass.
+func (v *Validator) ValidateRequest(ctx echo.Context) error {
+	route, params := v.route(ctx)
+	if route == nil {
+		return nil
+	}
+	req := ctx.Request()
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route:      route,
+		Options: options(&openapi3filter.Options{
+			MultiError:          true,
+			SkipSettingDefaults: true,
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+		}),
+	}
+	return openapi3filter.ValidateRequest(req.Context(), input)
+}
+
+// RequestMiddleware answers 400 naming the violations to requests that do not match the spec,
+// before they reach the handler. It does not buffer responses, the body of a request is read once.
+func (v *Validator) RequestMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if violation := v.ValidateRequest(ctx); violation != nil {
+				return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "request does not match the api spec", "details": violation.Error()})
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// bufferedWriter holds the response back so it can be checked before it is sent
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
+
+// Flush is a no-op, streamed responses are sent in one piece once they are checked
+func (w *bufferedWriter) Flush() {}
+
+// Middleware replaces responses that violate the spec with a 500 naming the violation.
+// Every response is buffered, so it is for development only.
+func (v *Validator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			res := ctx.Response()
+			original := res.Writer
+			buffered := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+			res.Writer = buffered
+
+			err := next(ctx)
+			res.Writer = original
+			if !res.Committed {
+				// Nothing was written, errors are rendered by the error handler after the chain returns
+				return err
+			}
+
+			if violation := v.Validate(ctx, buffered.status, res.Header(), buffered.body.Bytes()); violation != nil {
+				slog.Error("response does not match the openapi spec", "method", ctx.Request().Method, "path", ctx.Path(), "status", buffered.status, "error", violation)
+				res.Header().Del(echo.HeaderContentLength)
+				res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				res.Status = http.StatusInternalServerError
+				original.WriteHeader(http.StatusInternalServerError)
+				return json.NewEncoder(original).Encode(map[string]string{"error": violation.Error()})
+			}
+
+			original.WriteHeader(buffered.status)
+			if _, writeErr := original.Write(buffered.body.Bytes()); writeErr != nil {
+				return writeErr
+			}
+			return err
+		}
+	}
+}
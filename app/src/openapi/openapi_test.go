@@ -0,0 +1,114 @@
+package openapi_test
+
+import (
+	"app/openapi"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestValidate(t *testing.T) {
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		t.Fatalf("spec is invalid: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		status      int
+		contentType string
+		body        string
+		valid       bool
+	}{
+		{"matching sample", "/sample/:id", http.StatusOK, echo.MIMEApplicationJSON, `{"id":"0d9f8a55-3f5e-4b0e-9d4c-2a7a4d1c6f10","message":"hi"}`, true},
+		{"sparse sample", "/sample/:id", http.StatusOK, echo.MIMEApplicationJSON, `{"message":"hi"}`, true},
+		{"undocumented field", "/sample/:id", http.StatusOK, echo.MIMEApplicationJSON, `{"message":"hi","secret":1}`, false},
+		{"wrong type", "/sample/:id", http.StatusOK, echo.MIMEApplicationJSON, `{"message":1}`, false},
+		{"error body", "/sample/:id", http.StatusNotFound, echo.MIMEApplicationJSON, `{"error":"sample not found"}`, true},
+		{"undocumented content type", "/sample/:id", http.StatusOK, echo.MIMETextPlain, `hi`, false},
+		{"undocumented route", "/bench/delay/:ms", http.StatusOK, echo.MIMETextPlain, `hi`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			ctx.SetPath(tt.path)
+			err := validator.Validate(ctx, tt.status, http.Header{echo.HeaderContentType: {tt.contentType}}, []byte(tt.body))
+			if valid := err == nil; valid != tt.valid {
+				t.Errorf("valid = %v, want %v (%v)", valid, tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRejectsViolations(t *testing.T) {
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := echo.New()
+	router.Use(validator.Middleware())
+	router.GET("/sample/:id", func(ctx echo.Context) error {
+		if ctx.Param("id") == "bad" {
+			return ctx.JSON(http.StatusOK, map[string]any{"message": 1})
+		}
+		return ctx.JSON(http.StatusOK, map[string]any{"message": "hi"})
+	})
+
+	for id, want := range map[string]int{"good": http.StatusOK, "bad": http.StatusInternalServerError} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sample/"+id, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d: %s", id, rec.Code, want, rec.Body)
+		}
+	}
+}
+
+func TestRequestMiddlewareRejectsViolations(t *testing.T) {
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := echo.New()
+	router.Use(validator.RequestMiddleware())
+	router.POST("/sample", func(ctx echo.Context) error {
+		// The handler still reads the body the validator read
+		body, err := io.ReadAll(ctx.Request().Body)
+		if err != nil {
+			return err
+		}
+		return ctx.Blob(http.StatusCreated, echo.MIMEApplicationJSON, body)
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"matching body", `{"message":"hi"}`, http.StatusCreated},
+		{"empty message", `{"message":""}`, http.StatusBadRequest},
+		{"undocumented field", `{"message":"hi","key":"k"}`, http.StatusBadRequest},
+		{"missing body", ``, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/sample", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusCreated && rec.Body.String() != tt.body {
+				t.Errorf("handler read %q, want %q", rec.Body, tt.body)
+			}
+			if strings.Contains(rec.Body.String(), "Schema:") {
+				t.Errorf("violation carries the whole schema: %s", rec.Body)
+			}
+		})
+	}
+}
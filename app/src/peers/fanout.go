@@ -0,0 +1,72 @@
+package peers
+
+import (
+	"app/runtimeutil"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Result is the answer of one replica to a fan-out call
+type Result struct {
+	Endpoint  Endpoint        `json:"endpoint"`
+	Status    int             `json:"status,omitempty"`
+	LatencyMs float64         `json:"latency_ms"`
+	Body      json.RawMessage `json:"body,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// maxResponse bounds how much of each replica's response is kept
+const maxResponse = 64 << 10
+
+// FanOut GETs path on every endpoint concurrently and returns the results in endpoint order
+func FanOut(ctx context.Context, client *http.Client, endpoints []Endpoint, path string) []Result {
+	results := make([]Result, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		// Kept when the call panics
+		results[i] = Result{Endpoint: endpoint, Error: "call panicked"}
+		runtimeutil.GoWait("peers fan-out", &wg, func() {
+			results[i] = call(ctx, client, endpoint, path)
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+func call(ctx context.Context, client *http.Client, endpoint Endpoint, path string) (result Result) {
+	result.Endpoint = endpoint
+	started := time.Now()
+	defer func() {
+		result.LatencyMs = float64(time.Since(started).Microseconds()) / 1000
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+endpoint.HostPort()+path, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer res.Body.Close()
+
+	result.Status = res.StatusCode
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponse))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if json.Valid(body) {
+		result.Body = body
+	} else {
+		encoded, _ := json.Marshal(string(body))
+		result.Body = encoded
+	}
+	return result
+}
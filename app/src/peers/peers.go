@@ -0,0 +1,103 @@
+package peers
+
+import (
+	"app/config"
+	"app/kube"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Endpoint is one replica behind a Service
+type Endpoint struct {
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	Pod     string `json:"pod,omitempty"`
+	Ready   bool   `json:"ready"`
+}
+
+// HostPort is the address to dial the endpoint at
+func (e Endpoint) HostPort() string {
+	return net.JoinHostPort(e.Address, strconv.Itoa(e.Port))
+}
+
+type Resolver interface {
+	Resolve(ctx context.Context, service string, port int) ([]Endpoint, error)
+}
+
+// NewResolverFromEnv picks PEERS_RESOLVER (dns or endpoints), defaulting to the Endpoints API
+// inside a cluster and to DNS elsewhere, e.g. docker compose where a service name resolves to every replica
+func NewResolverFromEnv() Resolver {
+	mode := config.String("PEERS_RESOLVER", "")
+	if mode == "" {
+		mode = "dns"
+		if kube.Enabled() {
+			mode = "endpoints"
+		}
+	}
+	if mode == "endpoints" && kube.Enabled() {
+		return EndpointsResolver{}
+	}
+	return DNSResolver{Domain: config.String("PEERS_DNS_DOMAIN", "")}
+}
+
+// DNSResolver looks up every A record of the service name, which a headless Service returns one per ready pod
+type DNSResolver struct {
+	// Domain is appended to the service name, e.g. "<namespace>.svc.cluster.local"; empty uses the search path
+	Domain string
+}
+
+func (r DNSResolver) Resolve(ctx context.Context, service string, port int) ([]Endpoint, error) {
+	host := service
+	if r.Domain != "" {
+		host = service + "." + r.Domain
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+
+	endpoints := make([]Endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		endpoints = append(endpoints, Endpoint{Address: addr, Port: port, Ready: true})
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Address < endpoints[j].Address })
+	return endpoints, nil
+}
+
+// EndpointsResolver reads the Service's EndpointSlices, which also works for ClusterIP Services
+// and reports not-ready pods that DNS would hide
+type EndpointsResolver struct{}
+
+func (r EndpointsResolver) Resolve(ctx context.Context, service string, port int) ([]Endpoint, error) {
+	if !kube.Enabled() {
+		return nil, kube.ErrNotInCluster
+	}
+	slices, err := kube.Client.DiscoveryV1().EndpointSlices(kube.Namespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + service,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []Endpoint
+	for _, slice := range slices.Items {
+		for _, ep := range slice.Endpoints {
+			ready := ep.Conditions.Ready == nil || *ep.Conditions.Ready
+			pod := ""
+			if ep.TargetRef != nil {
+				pod = ep.TargetRef.Name
+			}
+			for _, addr := range ep.Addresses {
+				endpoints = append(endpoints, Endpoint{Address: addr, Port: port, Pod: pod, Ready: ready})
+			}
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Address < endpoints[j].Address })
+	return endpoints, nil
+}
@@ -0,0 +1,256 @@
+package profiling
+
+import (
+	"app/cgroup"
+	"app/config"
+	"app/kube"
+	"app/metrics"
+	"app/scheduler"
+	"app/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	runtimemetrics "runtime/metrics"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prefix is where profiles are stored, one directory per pod
+const Prefix = "profiles/"
+
+// heapMetric counts the bytes of heap objects, live or not yet collected, what the OOM killer sees growing
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+var ErrCapturing = errors.New("a profile capture is already running")
+
+var captures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "profiling",
+	Name:      "captures_total",
+	Help:      "Automatic and manual profile captures by trigger (heap, latency or manual) and result.",
+}, []string{"trigger", "result"})
+
+// Capturer uploads a CPU and a heap profile to object storage when the heap or the request latency
+// crosses a threshold, so a pod that is OOM killed or rescheduled after an incident leaves them behind.
+type Capturer struct {
+	// HeapThreshold triggers on heap object bytes above it, 0 disables
+	HeapThreshold uint64
+	// LatencyThreshold triggers when more than LatencyRatio of the requests in a check interval
+	// took longer than it, at least MinRequests of them. 0 disables
+	LatencyThreshold time.Duration
+	LatencyRatio     float64
+	MinRequests      int64
+	Interval         time.Duration
+	CPUDuration      time.Duration
+	// Cooldown is the least time between automatic captures, a sustained incident does not fill the bucket
+	Cooldown time.Duration
+	// Keep is how many captures of this pod are kept, the oldest are deleted
+	Keep int
+
+	requests atomic.Int64
+	slow     atomic.Int64
+	running  atomic.Bool
+
+	mu         sync.Mutex
+	capturedAt time.Time
+}
+
+// NewFromEnv returns nil unless PROFILE_CAPTURE_ENABLED is set
+func NewFromEnv() (*Capturer, error) {
+	if !config.Bool("PROFILE_CAPTURE_ENABLED", false) {
+		return nil, nil
+	}
+	c := &Capturer{
+		LatencyThreshold: config.Duration("PROFILE_LATENCY_THRESHOLD", 0),
+		LatencyRatio:     config.Float("PROFILE_LATENCY_RATIO", 0.1),
+		MinRequests:      int64(config.Int("PROFILE_MIN_REQUESTS", 20)),
+		Interval:         config.Duration("PROFILE_CHECK_INTERVAL", 15*time.Second),
+		CPUDuration:      config.Duration("PROFILE_CPU_DURATION", 10*time.Second),
+		Cooldown:         config.Duration("PROFILE_COOLDOWN", 10*time.Minute),
+		Keep:             config.Int("PROFILE_KEEP", 20),
+	}
+	// A quantity like the container's memory limit, e.g. 384Mi
+	if raw := config.String("PROFILE_HEAP_THRESHOLD", ""); raw != "" {
+		threshold, err := cgroup.ParseMemoryLimit(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROFILE_HEAP_THRESHOLD %q: %w", raw, err)
+		}
+		c.HeapThreshold = uint64(threshold)
+	}
+	return c, nil
+}
+
+// Start checks the thresholds every Interval until ctx is cancelled
+func (c *Capturer) Start(ctx context.Context) {
+	slog.Info("profile capture is enabled",
+		"heap_threshold", c.HeapThreshold, "latency_threshold", c.LatencyThreshold, "cooldown", c.Cooldown)
+	scheduler.Every(ctx, "profile-capture", c.Interval, c.check)
+}
+
+// Middleware counts the requests slower than LatencyThreshold
+func (c *Capturer) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if c.LatencyThreshold <= 0 {
+				return next(ctx)
+			}
+			start := time.Now()
+			err := next(ctx)
+			c.requests.Add(1)
+			if time.Since(start) > c.LatencyThreshold {
+				c.slow.Add(1)
+			}
+			return err
+		}
+	}
+}
+
+func (c *Capturer) check(ctx context.Context) error {
+	requests, slow := c.requests.Swap(0), c.slow.Swap(0)
+	trigger := ""
+	switch {
+	case c.HeapThreshold > 0 && heapBytes() > c.HeapThreshold:
+		trigger = "heap"
+	case c.LatencyThreshold > 0 && requests >= c.MinRequests && float64(slow) > c.LatencyRatio*float64(requests):
+		trigger = "latency"
+	default:
+		return nil
+	}
+
+	c.mu.Lock()
+	cooling := time.Since(c.capturedAt) < c.Cooldown
+	c.mu.Unlock()
+	if cooling {
+		return nil
+	}
+	slog.Warn("threshold crossed, capturing profiles", "trigger", trigger, "requests", requests, "slow", slow)
+	_, err := c.Capture(ctx, trigger)
+	if errors.Is(err, ErrCapturing) {
+		return nil
+	}
+	return err
+}
+
+// Capture profiles the CPU for CPUDuration, then takes a heap profile, and uploads both.
+// It fails with ErrCapturing while another capture is running.
+func (c *Capturer) Capture(ctx context.Context, trigger string) ([]storage.Object, error) {
+	if !c.running.CompareAndSwap(false, true) {
+		captures.WithLabelValues(trigger, "busy").Inc()
+		return nil, ErrCapturing
+	}
+	defer c.running.Store(false)
+	c.mu.Lock()
+	c.capturedAt = time.Now()
+	c.mu.Unlock()
+
+	objects, err := c.capture(ctx, trigger)
+	if err != nil {
+		captures.WithLabelValues(trigger, "error").Inc()
+		return nil, err
+	}
+	captures.WithLabelValues(trigger, "success").Inc()
+	if err := c.prune(ctx); err != nil {
+		slog.Warn("failed to prune profiles", "error", err)
+	}
+	return objects, nil
+}
+
+func (c *Capturer) capture(ctx context.Context, trigger string) ([]storage.Object, error) {
+	// The CPU profiler is taken while a Pusher runs, its profiles already cover this period
+	var cpu bytes.Buffer
+	if err := pprof.StartCPUProfile(&cpu); err == nil {
+		timer := time.NewTimer(c.CPUDuration)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+		pprof.StopCPUProfile()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		slog.Info("cpu profiler is in use, capturing the heap profile only", "error", err)
+	}
+
+	var heap bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&heap, 0); err != nil {
+		return nil, err
+	}
+
+	// Keys sort by time within the pod's directory
+	base := fmt.Sprintf("%s%s/%s-%s", Prefix, kube.PodName(), time.Now().UTC().Format("20060102T150405Z"), trigger)
+	var objects []storage.Object
+	for _, profile := range []struct {
+		kind string
+		data *bytes.Buffer
+	}{{"cpu", &cpu}, {"heap", &heap}} {
+		if profile.data.Len() == 0 {
+			continue
+		}
+		key := base + "." + profile.kind + ".pprof"
+		size := int64(profile.data.Len())
+		if err := storage.Default.Put(ctx, key, profile.data, size, "application/octet-stream"); err != nil {
+			return nil, fmt.Errorf("failed to upload %s profile: %w", profile.kind, err)
+		}
+		objects = append(objects, storage.Object{Key: key, Size: size, ModifiedAt: time.Now()})
+	}
+	slog.Info("profiles captured", "trigger", trigger, "key", base)
+	return objects, nil
+}
+
+// prune deletes this pod's oldest captures beyond Keep, each being a heap and usually a CPU profile
+func (c *Capturer) prune(ctx context.Context) error {
+	objects, err := storage.Default.List(ctx, Prefix+kube.PodName()+"/")
+	if err != nil || c.Keep <= 0 {
+		return err
+	}
+	// A capture is every object sharing the key up to the profile kind, keys sort by time
+	seen := map[string]bool{}
+	var names []string
+	for _, obj := range objects {
+		if name := captureName(obj.Key); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) <= c.Keep {
+		return nil
+	}
+	sort.Strings(names)
+	newestPruned := names[len(names)-c.Keep-1]
+	for _, obj := range objects {
+		if captureName(obj.Key) > newestPruned {
+			continue
+		}
+		if err := storage.Default.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func captureName(key string) string {
+	name, _, _ := strings.Cut(path.Base(key), ".")
+	return name
+}
+
+func heapBytes() uint64 {
+	sample := []runtimemetrics.Sample{{Name: heapMetric}}
+	runtimemetrics.Read(sample)
+	if sample[0].Value.Kind() != runtimemetrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
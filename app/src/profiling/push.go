@@ -0,0 +1,219 @@
+package profiling
+
+import (
+	"app/config"
+	"app/httpclient"
+	"app/kube"
+	"app/metrics"
+	"app/runtimeutil"
+	"app/tracing"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var pushes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "profiling",
+	Name:      "pushes_total",
+	Help:      "Profiles pushed to the continuous profiling server by profile (cpu or heap) and result.",
+}, []string{"profile", "result"})
+
+// heapSampleTypes tells the server the alloc_* values of a Go heap profile count from the process start
+const heapSampleTypes = `{"alloc_objects":{"units":"objects","cumulative":true},"alloc_space":{"units":"bytes","cumulative":true},` +
+	`"inuse_objects":{"units":"objects","aggregation":"average"},"inuse_space":{"units":"bytes","aggregation":"average"}}`
+
+// Pusher profiles the process continuously and pushes a CPU and a heap profile every Period
+// to the /ingest endpoint of Pyroscope, which Grafana Pyroscope still accepts.
+// Parca has no push API of this kind, it is run as parca-agent profiling every process on the node.
+type Pusher struct {
+	// ServerURL is the Pyroscope server, e.g. http://pyroscope.observability:4040
+	ServerURL string
+	// AppName names the series, tagged with Tags like app{namespace=default,pod=app-7d9f}
+	AppName string
+	Tags    map[string]string
+	Period  time.Duration
+	// BasicUser and BasicPassword, or Token as a bearer, authenticate against a hosted server
+	BasicUser     string
+	BasicPassword string
+	Token         string
+	// TenantID is sent as X-Scope-OrgID to a multi-tenant Grafana Pyroscope
+	TenantID string
+	HTTP     *http.Client
+}
+
+// PusherFromEnv returns nil unless PYROSCOPE_SERVER_ADDRESS is set
+func PusherFromEnv() *Pusher {
+	serverURL := config.String("PYROSCOPE_SERVER_ADDRESS", "")
+	if serverURL == "" {
+		return nil
+	}
+	tags := map[string]string{
+		"pod":       kube.PodName(),
+		"namespace": kube.Namespace(),
+		"version":   config.String("VERSION", ""),
+	}
+	// e.g. PYROSCOPE_TAGS=region=tokyo,cluster=dev
+	for _, item := range config.List("PYROSCOPE_TAGS") {
+		if name, value, ok := strings.Cut(item, "="); ok {
+			tags[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return &Pusher{
+		ServerURL:     strings.TrimSuffix(serverURL, "/"),
+		AppName:       config.String("PYROSCOPE_APPLICATION_NAME", tracing.ServiceName),
+		Tags:          tags,
+		Period:        config.Duration("PYROSCOPE_UPLOAD_PERIOD", 10*time.Second),
+		BasicUser:     config.String("PYROSCOPE_BASIC_AUTH_USER", ""),
+		BasicPassword: config.String("PYROSCOPE_BASIC_AUTH_PASSWORD", ""),
+		Token:         config.String("PYROSCOPE_AUTH_TOKEN", ""),
+		TenantID:      config.String("PYROSCOPE_TENANT_ID", ""),
+		HTTP:          httpclient.New(config.Duration("PYROSCOPE_TIMEOUT", 10*time.Second)),
+	}
+}
+
+// Start profiles the CPU in back-to-back periods until ctx is cancelled. While it runs the CPU
+// profiler is taken, so a threshold capture uploads its heap profile only.
+func (p *Pusher) Start(ctx context.Context) {
+	slog.Info("continuous profiling is enabled", "server", p.ServerURL, "app", p.AppName, "period", p.Period)
+	runtimeutil.Go("profile push", func() { p.run(ctx) })
+}
+
+func (p *Pusher) run(ctx context.Context) {
+	ticker := time.NewTicker(p.Period)
+	defer ticker.Stop()
+
+	cpu, from := &bytes.Buffer{}, time.Now()
+	profiling := startCPU(cpu)
+	for {
+		select {
+		case <-ctx.Done():
+			if profiling {
+				pprof.StopCPUProfile()
+			}
+			return
+		case <-ticker.C:
+		}
+		if profiling {
+			pprof.StopCPUProfile()
+		}
+		until := time.Now()
+		previous, previousFrom := cpu, from
+		cpu, from = &bytes.Buffer{}, until
+		profiling = startCPU(cpu)
+
+		// A period that lost the profiler to a threshold capture has nothing to push
+		if previous.Len() > 0 {
+			p.push(ctx, "cpu", previous, previousFrom, until, "")
+		}
+		var heap bytes.Buffer
+		if err := pprof.Lookup("heap").WriteTo(&heap, 0); err == nil {
+			p.push(ctx, "heap", &heap, previousFrom, until, heapSampleTypes)
+		}
+	}
+}
+
+func startCPU(w io.Writer) bool {
+	return pprof.StartCPUProfile(w) == nil
+}
+
+func (p *Pusher) push(ctx context.Context, profile string, data *bytes.Buffer, from, until time.Time, sampleTypes string) {
+	err := p.upload(ctx, data, from, until, sampleTypes)
+	if err != nil {
+		pushes.WithLabelValues(profile, "error").Inc()
+		slog.Warn("failed to push profile", "profile", profile, "error", err)
+		return
+	}
+	pushes.WithLabelValues(profile, "success").Inc()
+}
+
+func (p *Pusher) upload(ctx context.Context, data *bytes.Buffer, from, until time.Time, sampleTypes string) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := data.WriteTo(part); err != nil {
+		return err
+	}
+	if sampleTypes != "" {
+		part, err := form.CreateFormFile("sample_type_config", "sample_type_config.json")
+		if err != nil {
+			return err
+		}
+		io.WriteString(part, sampleTypes)
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	query := url.Values{
+		"name":       {p.name()},
+		"from":       {strconv.FormatInt(from.Unix(), 10)},
+		"until":      {strconv.FormatInt(until.Unix(), 10)},
+		"format":     {"pprof"},
+		"spyName":    {"gospy"},
+		"sampleRate": {"100"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.ServerURL+"/ingest?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	switch {
+	case p.BasicUser != "":
+		req.SetBasicAuth(p.BasicUser, p.BasicPassword)
+	case p.Token != "":
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+	if p.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", p.TenantID)
+	}
+
+	res, err := p.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("pyroscope answered %d: %s", res.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// name is the series key, app{name=value,...} with the tags sorted
+func (p *Pusher) name() string {
+	names := make([]string, 0, len(p.Tags))
+	for name, value := range p.Tags {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(p.AppName)
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name + "=" + p.Tags[name])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
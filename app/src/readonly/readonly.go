@@ -0,0 +1,162 @@
+package readonly
+
+import (
+	"app/config"
+	"app/db"
+	"app/metrics"
+	"app/model"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// flagName is the runtime_flags row the mode is shared through
+const flagName = "readonly"
+
+var (
+	enabled atomic.Bool
+	// allowed are path prefixes that keep accepting writes, the admin API so the mode can be switched back off
+	allowed    = config.List("READ_ONLY_ALLOW_PATHS")
+	retryAfter = config.String("READ_ONLY_RETRY_AFTER", "30")
+	// pinned is set when READ_ONLY is, the mode it sets wins over a row older than the pod
+	pinned  = config.String("READ_ONLY", "") != ""
+	started = time.Now()
+)
+
+var (
+	enabledGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "readonly",
+		Name:      "enabled",
+		Help:      "1 while mutating requests are rejected",
+	})
+	rejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "readonly",
+		Name:      "rejected_total",
+		Help:      "Mutating requests rejected in read-only mode",
+	}, []string{"method"})
+)
+
+func init() {
+	if len(allowed) == 0 {
+		// The admin pages and their login, so the mode can be switched off from the browser, and the
+		// POSTs that only read or sign in, so users can still log in and look samples up
+		allowed = []string{"/admin/", "/pages/admin/", "/pages/login", "/sample/lookup", "/auth/login", "/auth/token/refresh"}
+	}
+	if on, _ := strconv.ParseBool(config.String("READ_ONLY", "false")); on {
+		enabled.Store(true)
+		enabledGauge.Set(1)
+	}
+}
+
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// SetEnabled switches read-only mode on this pod, Switch on every replica
+func SetEnabled(on bool) {
+	if enabled.Load() == on {
+		return
+	}
+	enabled.Store(on)
+	if on {
+		enabledGauge.Set(1)
+	} else {
+		enabledGauge.Set(0)
+	}
+	slog.Warn("read-only mode toggled", "enabled", on)
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// Middleware answers mutating requests with 503 and Retry-After while read-only mode is on, reads are
+// served as usual. Paths under READ_ONLY_ALLOW_PATHS, the admin API and pages by default, still accept writes.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			if !enabled.Load() || !mutating(req.Method) {
+				return next(ctx)
+			}
+			for _, prefix := range allowed {
+				if strings.HasPrefix(req.URL.Path, prefix) {
+					return next(ctx)
+				}
+			}
+			rejected.WithLabelValues(req.Method).Inc()
+			ctx.Response().Header().Set("Retry-After", retryAfter)
+			return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": "the service is read-only for maintenance, retry later"})
+		}
+	}
+}
+
+// Switch sets read-only mode on this pod and stores it for the other replicas, which pick it up on their
+// next Sync, e.g. for the duration of a database failover. The mode is switched here even when storing
+// it fails, a primary being failed over may not take the write.
+func Switch(ctx context.Context, on bool) error {
+	SetEnabled(on)
+	err := db.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(&model.RuntimeFlag{Name: flagName, Enabled: on}).Error
+	if err != nil {
+		return fmt.Errorf("read-only mode is switched on this pod only: %w", err)
+	}
+	return nil
+}
+
+// Sync applies the mode another replica stored with Switch. With READ_ONLY set, a row from before this pod
+// started is ignored: the deployment's setting is newer.
+func Sync(ctx context.Context) error {
+	var flag model.RuntimeFlag
+	err := db.DB.WithContext(ctx).Take(&flag, "name = ?", flagName).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pinned && flag.UpdatedAt.Before(started) {
+		return nil
+	}
+	SetEnabled(flag.Enabled)
+	return nil
+}
+
+type toggleRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// ToggleHandler reports the mode on GET and switches it on every replica on PUT {"enabled": true|false}
+func ToggleHandler(ctx echo.Context) error {
+	if ctx.Request().Method == http.MethodPut {
+		req := new(toggleRequest)
+		if err := ctx.Bind(req); err != nil || req.Enabled == nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": `body must be {"enabled": true|false}`})
+		}
+		if err := Switch(ctx.Request().Context(), *req.Enabled); err != nil {
+			slog.Error("failed to store read-only mode", "error", err)
+			return ctx.JSON(http.StatusServiceUnavailable, map[string]any{"error": err.Error(), "enabled": Enabled()})
+		}
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"enabled": Enabled(), "allowed_paths": allowed})
+}
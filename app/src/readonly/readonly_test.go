@@ -0,0 +1,82 @@
+package readonly
+
+import (
+	"app/apptest"
+	"app/db"
+	"app/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func serve(method, path string) int {
+	e := echo.New()
+	e.Use(Middleware())
+	e.Any("/*", func(ctx echo.Context) error { return ctx.NoContent(http.StatusOK) })
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec.Code
+}
+
+func TestMiddleware(t *testing.T) {
+	SetEnabled(true)
+	t.Cleanup(func() { SetEnabled(false) })
+
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/samples", http.StatusOK},
+		{http.MethodPost, "/sample", http.StatusServiceUnavailable},
+		{http.MethodDelete, "/sample/1", http.StatusServiceUnavailable},
+		// POSTs that only read or sign in keep working
+		{http.MethodPost, "/sample/lookup", http.StatusOK},
+		{http.MethodPost, "/auth/login", http.StatusOK},
+		{http.MethodPost, "/auth/token/refresh", http.StatusOK},
+		{http.MethodPut, "/admin/readonly", http.StatusOK},
+	} {
+		if code := serve(tt.method, tt.path); code != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, code, tt.want)
+		}
+	}
+}
+
+func TestSwitchReachesTheOtherReplicas(t *testing.T) {
+	apptest.DB(t, &model.RuntimeFlag{})
+	t.Cleanup(func() { SetEnabled(false) })
+	ctx := t.Context()
+
+	if err := Switch(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	// Another replica still running in read-write mode picks the row up on its next Sync
+	SetEnabled(false)
+	if err := Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !Enabled() {
+		t.Error("Sync did not apply the mode stored by Switch")
+	}
+
+	if err := Switch(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	SetEnabled(true)
+	Sync(ctx)
+	if Enabled() {
+		t.Error("Sync did not apply read-only mode switched back off")
+	}
+
+	// With READ_ONLY set, a row from before the pod started does not override it
+	pinned = true
+	t.Cleanup(func() { pinned = false })
+	db.DB.Model(&model.RuntimeFlag{}).Where("name = ?", flagName).Update("updated_at", started.Add(-time.Minute))
+	SetEnabled(true)
+	Sync(ctx)
+	if !Enabled() {
+		t.Error("a row older than the pod overrode READ_ONLY")
+	}
+}
@@ -0,0 +1,89 @@
+package realip
+
+import (
+	"app/config"
+	"app/ipfilter"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ExtractorFromEnv builds the echo.IPExtractor used by c.RealIP(), so logging, rate limiting
+// and ip filters all see the same client address.
+//
+// REAL_IP_HEADER selects x-forwarded-for (default), x-real-ip or direct. Headers are only
+// honored when the peer is inside TRUSTED_PROXY_CIDRS; without trusted proxies the peer address is used.
+func ExtractorFromEnv() (echo.IPExtractor, error) {
+	header := strings.ToLower(config.String("REAL_IP_HEADER", "x-forwarded-for"))
+
+	trusted, err := ipfilter.ParseCIDRs(config.List("TRUSTED_PROXY_CIDRS"))
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXY_CIDRS: %w", err)
+	}
+	if len(trusted) == 0 || header == "direct" {
+		slog.Info("client ip is taken from the peer address")
+		return echo.ExtractIPDirect(), nil
+	}
+
+	// Only the configured ranges are trusted, not echo's loopback/private defaults
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, n := range trusted {
+		options = append(options, echo.TrustIPRange(n))
+	}
+
+	switch header {
+	case "x-forwarded-for":
+		slog.Info("client ip is taken from X-Forwarded-For", "trusted_proxies", len(trusted))
+		return echo.ExtractIPFromXFFHeader(options...), nil
+	case "x-real-ip":
+		slog.Info("client ip is taken from X-Real-IP", "trusted_proxies", len(trusted))
+		return echo.ExtractIPFromRealIPHeader(options...), nil
+	default:
+		return nil, fmt.Errorf("unsupported REAL_IP_HEADER %q", header)
+	}
+}
+
+// ForwardedHeadersFromEnv drops headers only the reverse proxy may set, such as X-Forwarded-Prefix,
+// from requests whose peer is not inside TRUSTED_PROXY_CIDRS, the way X-Forwarded-For is ignored
+// from them. Without trusted proxies they are dropped from every request.
+func ForwardedHeadersFromEnv(headers ...string) (echo.MiddlewareFunc, error) {
+	trusted, err := ipfilter.ParseCIDRs(config.List("TRUSTED_PROXY_CIDRS"))
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXY_CIDRS: %w", err)
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			if !trustedPeer(trusted, req.RemoteAddr) {
+				for _, header := range headers {
+					req.Header.Del(header)
+				}
+			}
+			return next(ctx)
+		}
+	}, nil
+}
+
+func trustedPeer(trusted []*net.IPNet, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
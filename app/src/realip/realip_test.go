@@ -0,0 +1,33 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestForwardedHeadersAreOnlyTakenFromTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXY_CIDRS", "10.0.0.0/8")
+	strip, err := ForwardedHeadersFromEnv("X-Forwarded-Prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	e.Use(strip)
+	e.GET("/", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, ctx.Request().Header.Get("X-Forwarded-Prefix"))
+	})
+
+	for peer, want := range map[string]string{"10.1.2.3:4000": "/app", "203.0.113.7:4000": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = peer
+		req.Header.Set("X-Forwarded-Prefix", "/app")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Body.String() != want {
+			t.Errorf("peer %s: prefix = %q, want %q", peer, rec.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package recorder
+
+import (
+	"app/authheader"
+	"app/bodylog"
+	"app/config"
+	"app/metrics"
+	"app/runtimeutil"
+	"app/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prefix is where recordings are stored, one NDJSON object per flush and replica
+const Prefix = "recordings/"
+
+var dropped = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "recorder",
+	Name:      "dropped_total",
+	Help:      "Requests not recorded because the buffer waiting for the next flush was full.",
+})
+
+// Record is one captured request
+type Record struct {
+	ID         string      `json:"id"`
+	ReceivedAt time.Time   `json:"received_at"`
+	Method     string      `json:"method"`
+	URI        string      `json:"uri"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body,omitempty"`
+	Truncated  bool        `json:"truncated,omitempty"`
+	Status     int         `json:"status"`
+}
+
+type Config struct {
+	Enabled      bool
+	Paths        []string
+	MaxBodyBytes int
+	// MaxPending and MaxPendingBytes cap the records buffered between flushes, so a burst of traffic or
+	// a storage outage does not grow the buffer without bound. Requests past the cap are not recorded.
+	MaxPending      int
+	MaxPendingBytes int
+	FlushInterval   time.Duration
+	KeepAuth        bool
+	RedactBodies    bool
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		Enabled:         config.Bool("RECORD_ENABLED", false),
+		Paths:           config.List("RECORD_PATHS"),
+		MaxBodyBytes:    config.Int("RECORD_MAX_BODY_BYTES", 1<<20),
+		MaxPending:      config.Int("RECORD_MAX_PENDING", 10000),
+		MaxPendingBytes: config.Int("RECORD_MAX_PENDING_BYTES", 64<<20),
+		FlushInterval:   config.Duration("RECORD_FLUSH_INTERVAL", 10*time.Second),
+		KeepAuth:        config.Bool("RECORD_KEEP_AUTH", false),
+		RedactBodies:    config.Bool("RECORD_REDACT_BODIES", true),
+	}
+}
+
+// Recorder buffers captured requests and writes them to object storage in batches
+type Recorder struct {
+	Config Config
+	host   string
+
+	mu           sync.Mutex
+	pending      []Record
+	pendingBytes int
+	done         chan struct{}
+	stopped      chan struct{}
+}
+
+// Start returns nil when recording is disabled
+func Start(cfg Config) *Recorder {
+	if !cfg.Enabled {
+		return nil
+	}
+	hostname, _ := os.Hostname()
+	r := &Recorder{Config: cfg, host: hostname, done: make(chan struct{}), stopped: make(chan struct{})}
+	runtimeutil.Go("request recorder", r.loop)
+	slog.Warn("request recording is enabled", "paths", cfg.Paths, "flush_interval", cfg.FlushInterval)
+	return r
+}
+
+func (r *Recorder) loop() {
+	defer close(r.stopped)
+	ticker := time.NewTicker(r.Config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			if err := r.Flush(context.Background()); err != nil {
+				slog.Error("failed to flush recorded requests", "error", err)
+			}
+		}
+	}
+}
+
+// Flush writes the buffered records as one NDJSON object
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	records := r.pending
+	r.pending = nil
+	r.pendingBytes = 0
+	r.mu.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range records {
+		if err := encoder.Encode(&records[i]); err != nil {
+			return err
+		}
+	}
+	key := fmt.Sprintf("%s%s/%s.ndjson", Prefix, r.host, time.Now().UTC().Format("20060102T150405.000Z"))
+	return storage.Default.Put(ctx, key, &buf, int64(buf.Len()), "application/x-ndjson")
+}
+
+// Shutdown stops the flush loop and writes what is still buffered
+func (r *Recorder) Shutdown(ctx context.Context) error {
+	close(r.done)
+	<-r.stopped
+	return r.Flush(ctx)
+}
+
+func (r *Recorder) matches(path string) bool {
+	if len(r.Config.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range r.Config.Paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware captures matching requests. The body is read up front and handed to the handler
+// unchanged, bodies above RECORD_MAX_BODY_BYTES are kept only up to the cap.
+func (r *Recorder) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			if !r.matches(req.URL.Path) {
+				return next(ctx)
+			}
+
+			record := Record{
+				ID:         uuid.New().String(),
+				ReceivedAt: time.Now(),
+				Method:     req.Method,
+				URI:        req.RequestURI,
+				Header:     req.Header.Clone(),
+			}
+			if !r.Config.KeepAuth {
+				for _, name := range authheader.Names {
+					record.Header.Del(name)
+				}
+			}
+			if req.Body != nil {
+				body, err := io.ReadAll(io.LimitReader(req.Body, int64(r.Config.MaxBodyBytes)+1))
+				if err != nil {
+					return err
+				}
+				// Whatever was not read stays in the original body for the handler
+				req.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+				if len(body) > r.Config.MaxBodyBytes {
+					body = body[:r.Config.MaxBodyBytes]
+					record.Truncated = true
+				}
+				if r.Config.RedactBodies {
+					body = []byte(bodylog.Redact(string(body)))
+				}
+				record.Body = body
+			}
+
+			err := next(ctx)
+			record.Status = ctx.Response().Status
+
+			r.add(record)
+			return err
+		}
+	}
+}
+
+// add buffers record unless that would exceed MaxPending or MaxPendingBytes
+func (r *Recorder) add(record Record) {
+	size := len(record.URI) + len(record.Body)
+	for name, values := range record.Header {
+		for _, value := range values {
+			size += len(name) + len(value)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) >= r.Config.MaxPending || r.pendingBytes+size > r.Config.MaxPendingBytes {
+		dropped.Inc()
+		return
+	}
+	r.pending = append(r.pending, record)
+	r.pendingBytes += size
+}
@@ -0,0 +1,63 @@
+package recorder
+
+import (
+	"app/authheader"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddlewareCapsTheBuffer(t *testing.T) {
+	r := &Recorder{Config: Config{MaxBodyBytes: 1024, MaxPending: 2, MaxPendingBytes: 1 << 20}}
+	e := echo.New()
+	e.Use(r.Middleware())
+	e.POST("/samples", func(ctx echo.Context) error { return ctx.NoContent(http.StatusCreated) })
+
+	for range 3 {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/samples", strings.NewReader(`{"name":"a"}`)))
+	}
+	if len(r.pending) != 2 {
+		t.Errorf("buffered %d records, want MaxPending = 2", len(r.pending))
+	}
+
+	r = &Recorder{Config: Config{MaxBodyBytes: 1024, MaxPending: 100, MaxPendingBytes: 100}}
+	e = echo.New()
+	e.Use(r.Middleware())
+	e.POST("/samples", func(ctx echo.Context) error { return ctx.NoContent(http.StatusCreated) })
+	for range 3 {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/samples", strings.NewReader(strings.Repeat("a", 40))))
+	}
+	if r.pendingBytes > 100 || len(r.pending) != 2 {
+		t.Errorf("buffered %d records of %d bytes, want 2 within MaxPendingBytes = 100", len(r.pending), r.pendingBytes)
+	}
+}
+
+func TestMiddlewareDropsCredentials(t *testing.T) {
+	r := &Recorder{Config: Config{MaxBodyBytes: 1024, MaxPending: 10, MaxPendingBytes: 1 << 20}}
+	e := echo.New()
+	e.Use(r.Middleware())
+	e.POST("/samples", func(ctx echo.Context) error { return ctx.NoContent(http.StatusCreated) })
+
+	req := httptest.NewRequest(http.MethodPost, "/samples", nil)
+	for _, name := range authheader.Names {
+		req.Header.Set(name, "secret")
+	}
+	req.Header.Set("X-Request-Id", "kept")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(r.pending) != 1 {
+		t.Fatalf("buffered %d records, want 1", len(r.pending))
+	}
+	header := r.pending[0].Header
+	for _, name := range authheader.Names {
+		if header.Get(name) != "" {
+			t.Errorf("%s was recorded", name)
+		}
+	}
+	if header.Get("X-Request-Id") != "kept" {
+		t.Error("X-Request-Id was dropped with the credentials")
+	}
+}
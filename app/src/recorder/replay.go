@@ -0,0 +1,90 @@
+package recorder
+
+import (
+	"app/storage"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of replaying one record
+type Result struct {
+	ID        string  `json:"id"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Recorded  int     `json:"recorded_status"`
+	Status    int     `json:"status,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Load reads every record stored under Prefix+prefix in the order they were received
+func Load(ctx context.Context, prefix string) ([]Record, error) {
+	objects, err := storage.Default.List(ctx, Prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, obj := range objects {
+		reader, _, err := storage.Default.Get(ctx, obj.Key)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64<<10), 64<<20)
+		for scanner.Scan() {
+			var record Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				reader.Close()
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		reader.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].ReceivedAt.Before(records[j].ReceivedAt) })
+	return records, nil
+}
+
+// Replay sends the record to target, a base URL such as https://staging.example.com/app
+func Replay(ctx context.Context, client *http.Client, target string, record Record) (result Result) {
+	result = Result{ID: record.ID, Method: record.Method, URI: record.URI, Recorded: record.Status}
+	started := time.Now()
+	defer func() {
+		result.LatencyMs = float64(time.Since(started).Microseconds()) / 1000
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, record.Method, strings.TrimSuffix(target, "/")+record.URI, bytes.NewReader(record.Body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for name, values := range record.Header {
+		// Hop-by-hop and length headers are recomputed by the client
+		if name == "Content-Length" || name == "Connection" || name == "Accept-Encoding" {
+			continue
+		}
+		req.Header[name] = values
+	}
+	req.Header.Set("X-Replayed-From", record.ID)
+
+	res, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	result.Status = res.StatusCode
+	return result
+}
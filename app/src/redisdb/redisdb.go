@@ -0,0 +1,48 @@
+package redisdb
+
+import (
+	"app/health"
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Client is nil when REDIS_URL is not set, callers must fall back to in-process state
+var Client *redis.Client
+
+func Init() {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		slog.Info("REDIS_URL is not set, redis features are disabled")
+		return
+	}
+
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		slog.Error("invalid REDIS_URL", "error", err)
+		panic("invalid REDIS_URL")
+	}
+	Client = redis.NewClient(opts)
+
+	// Not critical: while redis is down sessions are kept in session_records, unless
+	// SESSION_DB_FALLBACK=false which fails every login, and counters are counted per pod
+	health.Register(health.Check{
+		Name:     "redis",
+		Fallback: "sessions in the database, counters in the pod",
+		Run: func(ctx context.Context) error {
+			return Client.Ping(ctx).Err()
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Client.Ping(ctx).Err(); err != nil {
+		// Keep the client, redis may become reachable after the pod starts
+		slog.Warn("failed to ping redis", "error", err)
+		return
+	}
+	slog.Info("connected to redis")
+}
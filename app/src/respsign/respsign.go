@@ -0,0 +1,286 @@
+package respsign
+
+import (
+	"app/config"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Algorithms of RESPONSE_SIGNING
+const (
+	HMACSHA256 = "hmac-sha256"
+	Ed25519    = "ed25519"
+)
+
+const (
+	HeaderSignature     = "X-Response-Signature"
+	HeaderContentDigest = "Content-Digest"
+)
+
+// Signer signs response bodies so a consumer or a validating proxy can tell they were not altered
+// on the way. The signature covers when it was made, the request it answers, the status and the
+// body's digest, one per line:
+//
+//	<created unix seconds>
+//	<method> <request URI>
+//	<status>
+//	sha-256=:<base64 SHA-256 of the body>:
+//
+// and is sent as X-Response-Signature: keyid="...", alg="...", created=..., sig="<base64>",
+// next to the Content-Digest (RFC 9530) it signs.
+type Signer struct {
+	Algorithm string
+	KeyID     string
+	// MaxBytes is the largest body buffered to be signed, bigger and streamed responses go unsigned
+	MaxBytes int
+
+	secret     []byte
+	privateKey ed25519.PrivateKey
+}
+
+// NewFromEnv returns nil when RESPONSE_SIGNING is unset. The key is RESPONSE_SIGNING_KEY or the
+// file named by RESPONSE_SIGNING_KEY_FILE (a mounted Secret): the shared secret for hmac-sha256,
+// a PKCS#8 PEM private key for ed25519, as openssl/genkey.sh writes for JWTs.
+func NewFromEnv() (*Signer, error) {
+	algorithm := config.String("RESPONSE_SIGNING", "")
+	if algorithm == "" {
+		return nil, nil
+	}
+	key := []byte(config.String("RESPONSE_SIGNING_KEY", ""))
+	if path := config.String("RESPONSE_SIGNING_KEY_FILE", ""); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RESPONSE_SIGNING_KEY_FILE: %w", err)
+		}
+		key = bytes.TrimSpace(raw)
+	}
+	signer, err := New(algorithm, config.String("RESPONSE_SIGNING_KEY_ID", "default"), key)
+	if err != nil {
+		return nil, err
+	}
+	signer.MaxBytes = config.Int("RESPONSE_SIGNING_MAX_BYTES", 1<<20)
+	slog.Info("response signing is enabled", "alg", algorithm, "keyid", signer.KeyID)
+	return signer, nil
+}
+
+func New(algorithm, keyID string, key []byte) (*Signer, error) {
+	signer := &Signer{Algorithm: algorithm, KeyID: keyID, MaxBytes: 1 << 20}
+	switch algorithm {
+	case HMACSHA256:
+		if len(key) < 32 {
+			return nil, errors.New("the hmac-sha256 response signing key must be at least 32 bytes")
+		}
+		signer.secret = key
+	case Ed25519:
+		// Env files carry the PEM with escaped newlines
+		block, _ := pem.Decode(bytes.ReplaceAll(key, []byte(`\n`), []byte("\n")))
+		if block == nil {
+			return nil, errors.New("the ed25519 response signing key is not a PEM block")
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the response signing key: %w", err)
+		}
+		privateKey, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("the response signing key is not an Ed25519 key")
+		}
+		signer.privateKey = privateKey
+	default:
+		return nil, fmt.Errorf("unsupported RESPONSE_SIGNING %q, expected hmac-sha256 or ed25519", algorithm)
+	}
+	return signer, nil
+}
+
+// Digest is the Content-Digest of body
+func Digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// SigningString is what the signature of a response covers
+func SigningString(created int64, method, requestURI string, status int, digest string) string {
+	return strconv.FormatInt(created, 10) + "\n" + method + " " + requestURI + "\n" + strconv.Itoa(status) + "\n" + digest
+}
+
+func (s *Signer) sign(message string) []byte {
+	if s.privateKey != nil {
+		return ed25519.Sign(s.privateKey, []byte(message))
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// Verify checks a signature made by a signer with the same key. Verifying of ed25519 signatures only
+// needs the public key, consumers outside the app check them with PublicKey.
+func (s *Signer) Verify(message string, signature []byte) bool {
+	if s.privateKey != nil {
+		return ed25519.Verify(s.privateKey.Public().(ed25519.PublicKey), []byte(message), signature)
+	}
+	return hmac.Equal(s.sign(message), signature)
+}
+
+// PublicKey returns the PEM public key of an ed25519 signer, nil for hmac-sha256
+func (s *Signer) PublicKey() []byte {
+	if s.privateKey == nil {
+		return nil
+	}
+	der, err := x509.MarshalPKIXPublicKey(s.privateKey.Public())
+	if err != nil {
+		return nil
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// KeyHandler publishes the key to verify with, the public key for ed25519. The hmac secret is never served.
+func (s *Signer) KeyHandler(ctx echo.Context) error {
+	body := map[string]string{"keyid": s.KeyID, "alg": s.Algorithm}
+	if publicKey := s.PublicKey(); publicKey != nil {
+		body["public_key"] = string(publicKey)
+	}
+	return ctx.JSON(http.StatusOK, body)
+}
+
+// Middleware buffers responses up to MaxBytes and sends them with their Content-Digest and signature.
+// Errors are rendered here, so the error responses are signed as well.
+func (s *Signer) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			res := ctx.Response()
+			writer := &bufferedWriter{ResponseWriter: res.Writer, max: s.MaxBytes}
+			res.Writer = writer
+			defer func() { res.Writer = writer.ResponseWriter }()
+
+			if err := next(ctx); err != nil {
+				ctx.Error(err)
+			}
+			if writer.passthrough || !res.Committed {
+				return nil
+			}
+
+			req := ctx.Request()
+			created := time.Now().Unix()
+			digest := Digest(writer.body.Bytes())
+			signature := s.sign(SigningString(created, req.Method, req.RequestURI, writer.status, digest))
+			header := writer.Header()
+			header.Set(HeaderContentDigest, digest)
+			header.Set(HeaderSignature, fmt.Sprintf(`keyid=%q, alg=%q, created=%d, sig=%q`,
+				s.KeyID, s.Algorithm, created, base64.StdEncoding.EncodeToString(signature)))
+			return writer.release()
+		}
+	}
+}
+
+// bufferedWriter holds the response back until it can be signed. Bodies over max and
+// flushed responses, such as server-sent events, are sent on as they are, unsigned.
+type bufferedWriter struct {
+	http.ResponseWriter
+	max         int
+	status      int
+	body        bytes.Buffer
+	passthrough bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body.Len()+len(p) > w.max {
+		if err := w.giveUp(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	return w.body.Write(p)
+}
+
+func (w *bufferedWriter) Flush() {
+	if !w.passthrough {
+		if err := w.giveUp(); err != nil {
+			return
+		}
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// giveUp sends what was buffered unsigned and passes the rest of the response through
+func (w *bufferedWriter) giveUp() error {
+	w.passthrough = true
+	return w.release()
+}
+
+func (w *bufferedWriter) release() error {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.body.WriteTo(w.ResponseWriter)
+	return err
+}
+
+// Signature is a parsed X-Response-Signature
+type Signature struct {
+	KeyID     string
+	Algorithm string
+	Created   int64
+	Value     []byte
+}
+
+// ParseHeader reads an X-Response-Signature value
+func ParseHeader(value string) (Signature, error) {
+	var signature Signature
+	for _, part := range strings.Split(value, ",") {
+		name, raw, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return Signature{}, fmt.Errorf("malformed signature parameter %q", part)
+		}
+		raw = strings.Trim(raw, `"`)
+		var err error
+		switch name {
+		case "keyid":
+			signature.KeyID = raw
+		case "alg":
+			signature.Algorithm = raw
+		case "created":
+			signature.Created, err = strconv.ParseInt(raw, 10, 64)
+		case "sig":
+			signature.Value, err = base64.StdEncoding.DecodeString(raw)
+		}
+		if err != nil {
+			return Signature{}, fmt.Errorf("malformed signature parameter %s: %w", name, err)
+		}
+	}
+	if signature.Value == nil || signature.Created == 0 {
+		return Signature{}, errors.New("signature lacks sig or created")
+	}
+	return signature, nil
+}
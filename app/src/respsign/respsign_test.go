@@ -0,0 +1,73 @@
+package respsign_test
+
+import (
+	"app/respsign"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, err := respsign.New(respsign.Ed25519, "k1", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, err := respsign.New(respsign.HMACSHA256, "k2", []byte(strings.Repeat("s", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, signer := range []*respsign.Signer{ed, mac} {
+		signer.MaxBytes = 64
+		e := echo.New()
+		e.Use(signer.Middleware())
+		e.GET("/small", func(ctx echo.Context) error { return ctx.String(http.StatusCreated, "signed body") })
+		e.GET("/large", func(ctx echo.Context) error { return ctx.String(http.StatusOK, strings.Repeat("x", 100)) })
+		e.GET("/error", func(ctx echo.Context) error { return echo.NewHTTPError(http.StatusTeapot) })
+
+		for _, path := range []string{"/small?a=1", "/error"} {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			signature, err := respsign.ParseHeader(rec.Header().Get(respsign.HeaderSignature))
+			if err != nil {
+				t.Fatalf("%s %s: %v", signer.Algorithm, path, err)
+			}
+			digest := rec.Header().Get(respsign.HeaderContentDigest)
+			if digest != respsign.Digest(rec.Body.Bytes()) {
+				t.Errorf("%s %s: Content-Digest %q does not match the body", signer.Algorithm, path, digest)
+			}
+			message := respsign.SigningString(signature.Created, http.MethodGet, path, rec.Code, digest)
+			if signature.KeyID != signer.KeyID || !signer.Verify(message, signature.Value) {
+				t.Errorf("%s %s: signature %+v does not verify", signer.Algorithm, path, signature)
+			}
+			if signer.Verify(respsign.SigningString(signature.Created, http.MethodGet, path, rec.Code, respsign.Digest([]byte("tampered"))), signature.Value) {
+				t.Errorf("%s %s: signature verifies a tampered body", signer.Algorithm, path)
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/large", nil))
+		if rec.Header().Get(respsign.HeaderSignature) != "" || rec.Body.Len() != 100 {
+			t.Errorf("%s: large response signed or cut: %d bytes", signer.Algorithm, rec.Body.Len())
+		}
+	}
+
+	if ed.PublicKey() == nil || mac.PublicKey() != nil {
+		t.Error("only the ed25519 signer has a public key")
+	}
+}
@@ -0,0 +1,156 @@
+package retention
+
+import (
+	"app/config"
+	"app/db"
+	"app/metrics"
+	"app/model"
+	"app/scheduler"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	rowsPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "retention",
+		Name:      "rows_purged_total",
+		Help:      "Rows hard-deleted by the retention job.",
+	}, []string{"table"})
+
+	lastRun = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "retention",
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix time of the last successful retention run.",
+	})
+
+	runErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "retention",
+		Name:      "errors_total",
+		Help:      "Retention runs that failed.",
+	})
+)
+
+// Config is the number of days to keep each kind of record, zero disables that purge
+type Config struct {
+	Interval          time.Duration
+	DeletedSampleDays int
+	WebhookEventDays  int
+	ExpiredTokenDays  int
+	FinishedJobDays   int
+	ProcessEventDays  int
+	TenantUsageDays   int
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		Interval:          config.Duration("RETENTION_INTERVAL", time.Hour),
+		DeletedSampleDays: config.Int("RETENTION_DELETED_SAMPLE_DAYS", 30),
+		WebhookEventDays:  config.Int("RETENTION_WEBHOOK_EVENT_DAYS", 14),
+		ExpiredTokenDays:  config.Int("RETENTION_EXPIRED_TOKEN_DAYS", 7),
+		FinishedJobDays:   config.Int("RETENTION_FINISHED_JOB_DAYS", 7),
+		ProcessEventDays:  config.Int("RETENTION_PROCESS_EVENT_DAYS", 30),
+		TenantUsageDays:   config.Int("RETENTION_TENANT_USAGE_DAYS", 30),
+	}
+}
+
+// Start runs the purge on every interval until ctx is cancelled.
+// Running on every replica is safe because the deletes are idempotent.
+func Start(ctx context.Context, cfg Config) {
+	scheduler.Every(ctx, "retention", cfg.Interval, func(ctx context.Context) error {
+		return Run(ctx, cfg)
+	})
+}
+
+// Run purges every table once, cancelling ctx stops the delete running and skips the rest
+func Run(ctx context.Context, cfg Config) error {
+	conn := db.DB.WithContext(ctx)
+	type purge struct {
+		table string
+		days  int
+		run   func(cutoff time.Time) (int64, error)
+	}
+
+	purges := []purge{
+		{"samples", cfg.DeletedSampleDays, func(cutoff time.Time) (int64, error) {
+			// Unscoped so soft-deleted rows are removed for good
+			result := conn.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(&model.Sample{})
+			return result.RowsAffected, result.Error
+		}},
+		{"sample_revisions", cfg.DeletedSampleDays, func(time.Time) (int64, error) {
+			// History goes with the sample it belongs to once that is purged
+			purged := conn.Unscoped().Model(&model.Sample{}).Select("id")
+			result := conn.Where("sample_id NOT IN (?)", purged).Delete(&model.SampleRevision{})
+			return result.RowsAffected, result.Error
+		}},
+		{"sample_labels", cfg.DeletedSampleDays, func(time.Time) (int64, error) {
+			purged := conn.Unscoped().Model(&model.Sample{}).Select("id")
+			result := conn.Where("sample_id NOT IN (?)", purged).Delete(&model.SampleLabel{})
+			return result.RowsAffected, result.Error
+		}},
+		{"webhook_events", cfg.WebhookEventDays, func(cutoff time.Time) (int64, error) {
+			result := conn.Where("created_at < ?", cutoff).Delete(&model.WebhookEvent{})
+			return result.RowsAffected, result.Error
+		}},
+		{"refresh_tokens", cfg.ExpiredTokenDays, func(cutoff time.Time) (int64, error) {
+			result := conn.Where("expires_at < ?", cutoff).Delete(&model.RefreshToken{})
+			return result.RowsAffected, result.Error
+		}},
+		{"revoked_tokens", cfg.ExpiredTokenDays, func(cutoff time.Time) (int64, error) {
+			result := conn.Where("expires_at < ?", cutoff).Delete(&model.RevokedToken{})
+			return result.RowsAffected, result.Error
+		}},
+		{"session_records", cfg.ExpiredTokenDays, func(cutoff time.Time) (int64, error) {
+			result := conn.Where("expires_at < ?", cutoff).Delete(&model.SessionRecord{})
+			return result.RowsAffected, result.Error
+		}},
+		{"jobs", cfg.FinishedJobDays, func(cutoff time.Time) (int64, error) {
+			result := conn.Where("status = ? AND finished_at < ?", model.JobDone, cutoff).Delete(&model.Job{})
+			return result.RowsAffected, result.Error
+		}},
+		{"process_events", cfg.ProcessEventDays, func(cutoff time.Time) (int64, error) {
+			result := conn.Where("started_at < ?", cutoff).Delete(&model.ProcessEvent{})
+			return result.RowsAffected, result.Error
+		}},
+		{"tenant_usages", cfg.TenantUsageDays, func(cutoff time.Time) (int64, error) {
+			// Days are 2006-01-02 in UTC and compare in order as strings
+			result := conn.Where("day < ?", cutoff.UTC().Format(time.DateOnly)).Delete(&model.TenantUsage{})
+			return result.RowsAffected, result.Error
+		}},
+	}
+
+	var failed error
+	for _, p := range purges {
+		if p.days <= 0 {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		cutoff := time.Now().AddDate(0, 0, -p.days)
+		rows, err := p.run(cutoff)
+		if err != nil {
+			slog.Error("retention purge failed", "table", p.table, "error", err)
+			failed = err
+			continue
+		}
+		rowsPurged.WithLabelValues(p.table).Add(float64(rows))
+		if rows > 0 {
+			slog.Info("retention purge completed", "table", p.table, "rows", rows, "cutoff", cutoff)
+		}
+	}
+
+	if failed != nil {
+		runErrors.Inc()
+		return failed
+	}
+	lastRun.SetToCurrentTime()
+	return nil
+}
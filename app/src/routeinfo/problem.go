@@ -0,0 +1,156 @@
+package routeinfo
+
+import (
+	"app/i18n"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const mimeProblemJSON = "application/problem+json"
+
+// maxSuggestions caps the "did you mean" list of a 404
+const maxSuggestions = 3
+
+// hiddenPrefixes are never suggested, a 404 must not advertise the admin and debug routes to whoever
+// guesses a path
+var hiddenPrefixes = []string{"/admin", "/debug", "/dev"}
+
+// Problem is an RFC 9457 problem details body
+type Problem struct {
+	Type           string       `json:"type"`
+	Title          string       `json:"title"`
+	Status         int          `json:"status"`
+	Detail         string       `json:"detail"`
+	Instance       string       `json:"instance"`
+	AllowedMethods []string     `json:"allowed_methods,omitempty"`
+	Suggestions    []Suggestion `json:"suggestions,omitempty"`
+}
+
+type Suggestion struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// ErrorHandler answers routing 404s and 405s with problem+json listing the allowed methods
+// or the closest registered routes, every other error goes to next
+func (e *Echo) ErrorHandler(next echo.HTTPErrorHandler) echo.HTTPErrorHandler {
+	return func(err error, ctx echo.Context) {
+		if ctx.Response().Committed {
+			next(err, ctx)
+			return
+		}
+
+		req := ctx.Request()
+		problem := Problem{Type: "about:blank", Instance: req.URL.Path}
+		switch {
+		case errors.Is(err, echo.ErrMethodNotAllowed):
+			problem.Status = http.StatusMethodNotAllowed
+			problem.Detail = i18n.Translate(ctx, "error.method_not_allowed", map[string]any{"Method": req.Method, "Path": req.URL.Path})
+			if allow, ok := ctx.Get(echo.ContextKeyHeaderAllow).(string); ok {
+				ctx.Response().Header().Set(echo.HeaderAllow, allow)
+				for _, method := range strings.Split(allow, ",") {
+					problem.AllowedMethods = append(problem.AllowedMethods, strings.TrimSpace(method))
+				}
+			}
+		case errors.Is(err, echo.ErrNotFound):
+			problem.Status = http.StatusNotFound
+			problem.Detail = i18n.Translate(ctx, "error.route_not_found", map[string]any{"Method": req.Method, "Path": req.URL.Path})
+			problem.Suggestions = e.suggest(req.URL.Path)
+		default:
+			next(err, ctx)
+			return
+		}
+		problem.Title = http.StatusText(problem.Status)
+
+		ctx.Response().Header().Set(echo.HeaderContentType, mimeProblemJSON)
+		if req.Method == http.MethodHead {
+			err = ctx.NoContent(problem.Status)
+		} else {
+			err = ctx.JSON(problem.Status, problem)
+		}
+		if err != nil {
+			ctx.Logger().Error(err)
+		}
+	}
+}
+
+// suggest ranks the registered routes by edit distance to path, parameters match any segment
+func (e *Echo) suggest(path string) []Suggestion {
+	type candidate struct {
+		Suggestion
+		distance int
+	}
+	threshold := max(2, len(path)/3)
+
+	var candidates []candidate
+	for _, r := range e.Routes() {
+		if r.Method == echo.RouteNotFound || strings.Contains(r.Path, "*") || hidden(r.Path) {
+			continue
+		}
+		distance := levenshtein(path, fillParams(r.Path, path))
+		if distance <= threshold {
+			candidates = append(candidates, candidate{Suggestion{Method: r.Method, Path: r.Path}, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].Path < candidates[j].Path
+	})
+
+	suggestions := make([]Suggestion, 0, maxSuggestions)
+	for _, c := range candidates {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, c.Suggestion)
+	}
+	return suggestions
+}
+
+func hidden(path string) bool {
+	for _, prefix := range hiddenPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// fillParams replaces the route's :params with the request's segments at the same position,
+// so "/jobs/dead/:id" is compared to "/jobs/deda/123" as "/jobs/dead/123"
+func fillParams(route, path string) string {
+	routeSegments := strings.Split(route, "/")
+	pathSegments := strings.Split(path, "/")
+	for i, segment := range routeSegments {
+		if strings.HasPrefix(segment, ":") && i < len(pathSegments) {
+			routeSegments[i] = pathSegments[i]
+		}
+	}
+	return strings.Join(routeSegments, "/")
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,29 @@
+package routeinfo
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestSuggestSkipsHiddenRoutes(t *testing.T) {
+	e := New(echo.New())
+	handler := func(ctx echo.Context) error { return nil }
+	e.GET("/samples", handler)
+	e.GET("/samples/:id", handler)
+	admin := e.Group("/admin")
+	admin.GET("/samples", handler)
+	e.GET("/debug/routes", handler)
+
+	got := e.suggest("/sample")
+	if len(got) != 1 || got[0] != (Suggestion{Method: http.MethodGet, Path: "/samples"}) {
+		t.Errorf("suggest(/sample) = %v, want only GET /samples", got)
+	}
+	if got := e.suggest("/admin/sample"); len(got) != 0 {
+		t.Errorf("suggest(/admin/sample) = %v, want no admin routes", got)
+	}
+	if got := e.suggest("/debug/route"); len(got) != 0 {
+		t.Errorf("suggest(/debug/route) = %v, want no debug routes", got)
+	}
+}
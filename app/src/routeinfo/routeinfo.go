@@ -0,0 +1,205 @@
+package routeinfo
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Echo does not keep track of which middleware wraps a route, so Echo and Group record it
+// while routes are registered. Echo embeds *echo.Echo, anything not overridden behaves as usual.
+type Echo struct {
+	*echo.Echo
+	registry *registry
+}
+
+// Group wraps *echo.Group with the route registration methods the app uses
+type Group struct {
+	group      *echo.Group
+	prefix     string
+	middleware []string
+	registry   *registry
+}
+
+type registry struct {
+	mu     sync.RWMutex
+	global []string
+	routes map[string][]string
+}
+
+func New(e *echo.Echo) *Echo {
+	return &Echo{Echo: e, registry: &registry{routes: map[string][]string{}}}
+}
+
+func (r *registry) record(method, path string, middleware []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[method+" "+path] = middleware
+}
+
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$|-fm$`)
+
+// Name turns a handler or middleware into a readable name such as "ipfilter.Middleware"
+func Name(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	name := closureSuffix.ReplaceAllString(f.Name(), "")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func names(middleware []echo.MiddlewareFunc) []string {
+	list := make([]string, len(middleware))
+	for i, m := range middleware {
+		list[i] = Name(m)
+	}
+	return list
+}
+
+func join(a, b []string) []string {
+	return append(append([]string{}, a...), b...)
+}
+
+func (e *Echo) Use(middleware ...echo.MiddlewareFunc) {
+	e.Echo.Use(middleware...)
+	e.registry.mu.Lock()
+	e.registry.global = append(e.registry.global, names(middleware)...)
+	e.registry.mu.Unlock()
+}
+
+func (e *Echo) Add(method, path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	e.registry.record(method, path, names(m))
+	return e.Echo.Add(method, path, h, m...)
+}
+
+func (e *Echo) GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return e.Add(http.MethodGet, path, h, m...)
+}
+
+func (e *Echo) POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return e.Add(http.MethodPost, path, h, m...)
+}
+
+func (e *Echo) PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return e.Add(http.MethodPut, path, h, m...)
+}
+
+func (e *Echo) PATCH(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return e.Add(http.MethodPatch, path, h, m...)
+}
+
+func (e *Echo) DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return e.Add(http.MethodDelete, path, h, m...)
+}
+
+func (e *Echo) Group(prefix string, m ...echo.MiddlewareFunc) *Group {
+	g := &Group{group: e.Echo.Group(prefix, m...), prefix: prefix, middleware: names(m), registry: e.registry}
+	g.recordCatchAll()
+	return g
+}
+
+// recordCatchAll mirrors the not-found routes Echo adds so group middleware also runs for unknown paths
+func (g *Group) recordCatchAll() {
+	if len(g.middleware) > 0 {
+		g.registry.record(echo.RouteNotFound, g.prefix, g.middleware)
+		g.registry.record(echo.RouteNotFound, g.prefix+"/*", g.middleware)
+	}
+}
+
+func (g *Group) Use(middleware ...echo.MiddlewareFunc) {
+	g.group.Use(middleware...)
+	g.middleware = join(g.middleware, names(middleware))
+	g.recordCatchAll()
+}
+
+func (g *Group) Add(method, path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	g.registry.record(method, g.prefix+path, join(g.middleware, names(m)))
+	return g.group.Add(method, path, h, m...)
+}
+
+func (g *Group) GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodGet, path, h, m...)
+}
+
+func (g *Group) POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodPost, path, h, m...)
+}
+
+func (g *Group) PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodPut, path, h, m...)
+}
+
+func (g *Group) PATCH(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodPatch, path, h, m...)
+}
+
+func (g *Group) DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodDelete, path, h, m...)
+}
+
+func (g *Group) Group(prefix string, m ...echo.MiddlewareFunc) *Group {
+	sg := &Group{
+		group:      g.group.Group(prefix, m...),
+		prefix:     g.prefix + prefix,
+		middleware: join(g.middleware, names(m)),
+		registry:   g.registry,
+	}
+	sg.recordCatchAll()
+	return sg
+}
+
+type Route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+	// Middleware lists the route's own and its groups' middleware in execution order, after the global ones
+	Middleware []string `json:"middleware"`
+}
+
+type Report struct {
+	Global []string `json:"global_middleware"`
+	Routes []Route  `json:"routes"`
+}
+
+// Report lists every route Echo knows about with the middleware recorded for it
+func (e *Echo) Report() Report {
+	e.registry.mu.RLock()
+	defer e.registry.mu.RUnlock()
+
+	report := Report{Global: append([]string{}, e.registry.global...)}
+	for _, r := range e.Routes() {
+		middleware := e.registry.routes[r.Method+" "+r.Path]
+		if middleware == nil {
+			middleware = []string{}
+		}
+		report.Routes = append(report.Routes, Route{
+			Method:     r.Method,
+			Path:       r.Path,
+			Handler:    closureSuffix.ReplaceAllString(r.Name[strings.LastIndex(r.Name, "/")+1:], ""),
+			Middleware: middleware,
+		})
+	}
+	sort.Slice(report.Routes, func(i, j int) bool {
+		if report.Routes[i].Path != report.Routes[j].Path {
+			return report.Routes[i].Path < report.Routes[j].Path
+		}
+		return report.Routes[i].Method < report.Routes[j].Method
+	})
+	return report
+}
+
+func (e *Echo) Handler() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, e.Report())
+	}
+}
@@ -0,0 +1,154 @@
+package runtimeutil
+
+import (
+	"app/metrics"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"runtime/debug"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	running = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "goroutine",
+		Name:      "running",
+		Help:      "Background goroutines started with runtimeutil.Go that are still running, by name.",
+	}, []string{"name"})
+
+	panics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "goroutine",
+		Name:      "panics_total",
+		Help:      "Panics recovered in background goroutines, by name.",
+	}, []string{"name"})
+)
+
+var (
+	mu      sync.Mutex
+	names   = map[string]int{}
+	stopped = sync.NewCond(&mu)
+)
+
+// Go runs fn in a goroutine registered under name until it returns. A panic in fn is recovered,
+// logged with its stack and counted in app_goroutine_panics_total instead of crashing the pod;
+// the goroutine is not restarted, which app_goroutine_running shows.
+func Go(name string, fn func()) {
+	start(name)
+	go func() {
+		defer stop(name, nil)
+		fn()
+	}()
+}
+
+// GoWait starts fn like Go as one goroutine of a fan-out waited for with wg, which is done whether
+// fn returns or panics. Callers record a failed result before fn runs so a panic leaves one behind.
+func GoWait(name string, wg *sync.WaitGroup, fn func()) {
+	wg.Add(1)
+	Go(name, func() {
+		defer wg.Done()
+		fn()
+	})
+}
+
+var (
+	supervisorMu  sync.Mutex
+	supervisor    *errgroup.Group
+	supervisorCtx = context.Background()
+)
+
+// Supervise runs the goroutines started with Critical from now on in group, passing them ctx, the
+// group's context. The first of them to fail or panic cancels it, so the pod shuts down instead of
+// running on without that component.
+func Supervise(ctx context.Context, group *errgroup.Group) {
+	supervisorMu.Lock()
+	defer supervisorMu.Unlock()
+	supervisor, supervisorCtx = group, ctx
+}
+
+// Critical starts fn like Go for a component the pod cannot run without. Under Supervise it runs
+// in the group, and the error it returns, a recovered panic included, fails it; otherwise, in CLI
+// commands and tests, the error is logged and ctx is never cancelled.
+func Critical(name string, fn func(ctx context.Context) error) {
+	supervisorMu.Lock()
+	group, ctx := supervisor, supervisorCtx
+	supervisorMu.Unlock()
+
+	run := func() (err error) {
+		start(name)
+		defer stop(name, &err)
+		if err := fn(ctx); err != nil {
+			return fmt.Errorf("%s failed: %w", name, err)
+		}
+		return nil
+	}
+	if group != nil {
+		group.Go(run)
+		return
+	}
+	go func() {
+		if err := run(); err != nil {
+			slog.Error("background goroutine failed", "name", name, "error", err)
+		}
+	}()
+}
+
+func start(name string) {
+	mu.Lock()
+	names[name]++
+	mu.Unlock()
+	running.WithLabelValues(name).Inc()
+}
+
+// stop ends the goroutine started under name, recovering a panic into *err when err is not nil.
+// It must be deferred.
+func stop(name string, err *error) {
+	if r := recover(); r != nil {
+		slog.Error("background goroutine panicked", "name", name, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+		panics.WithLabelValues(name).Inc()
+		if err != nil {
+			*err = fmt.Errorf("%s panicked: %v", name, r)
+		}
+	}
+	running.WithLabelValues(name).Dec()
+	mu.Lock()
+	if names[name]--; names[name] == 0 {
+		delete(names, name)
+	}
+	stopped.Broadcast()
+	mu.Unlock()
+}
+
+// Running counts the goroutines started with Go that have not returned, by name
+func Running() map[string]int {
+	mu.Lock()
+	defer mu.Unlock()
+	return maps.Clone(names)
+}
+
+// Wait blocks until every goroutine started with Go returned, or until ctx is done, when it
+// returns the ones still running. Shutdown calls it after cancelling their context.
+func Wait(ctx context.Context) map[string]int {
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		stopped.Broadcast()
+		mu.Unlock()
+	})
+	defer stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for len(names) > 0 && ctx.Err() == nil {
+		stopped.Wait()
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return maps.Clone(names)
+}
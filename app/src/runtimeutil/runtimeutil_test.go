@@ -0,0 +1,83 @@
+package runtimeutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestGo(t *testing.T) {
+	panicked := testutil.ToFloat64(panics.WithLabelValues("test panic"))
+	release := make(chan struct{})
+	Go("test blocked", func() { <-release })
+	Go("test panic", func() { panic("boom") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if left := Wait(ctx); left["test blocked"] != 1 || left["test panic"] != 0 {
+		t.Fatalf("still running = %v", left)
+	}
+	if got := testutil.ToFloat64(panics.WithLabelValues("test panic")) - panicked; got != 1 {
+		t.Errorf("panics = %v", got)
+	}
+
+	close(release)
+	if left := Wait(context.Background()); left != nil {
+		t.Errorf("after release = %v", left)
+	}
+	if got := testutil.ToFloat64(running.WithLabelValues("test blocked")); got != 0 {
+		t.Errorf("running gauge = %v", got)
+	}
+}
+
+func TestCritical(t *testing.T) {
+	group, ctx := errgroup.WithContext(context.Background())
+	Supervise(ctx, group)
+	t.Cleanup(func() { Supervise(context.Background(), nil) })
+
+	// The component waiting for the context stops once the other one panicked
+	Critical("test waiting", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	Critical("test critical panic", func(context.Context) error { panic("boom") })
+	err := group.Wait()
+	if err == nil || !strings.Contains(err.Error(), "test critical panic panicked: boom") {
+		t.Fatalf("group error = %v, want the panic", err)
+	}
+	if left := Running(); left["test waiting"] != 0 || left["test critical panic"] != 0 {
+		t.Errorf("still running = %v", left)
+	}
+
+	errFailed := errors.New("failed")
+	group, ctx = errgroup.WithContext(context.Background())
+	Supervise(ctx, group)
+	Critical("test critical error", func(context.Context) error { return errFailed })
+	if err := group.Wait(); !errors.Is(err, errFailed) {
+		t.Errorf("group error = %v, want the returned one", err)
+	}
+}
+
+func TestGoWait(t *testing.T) {
+	var wg sync.WaitGroup
+	results := make([]string, 3)
+	for i := range results {
+		results[i] = "panicked"
+		GoWait("test fan-out", &wg, func() {
+			if i == 1 {
+				panic("boom")
+			}
+			results[i] = "done"
+		})
+	}
+	wg.Wait()
+	if results[0] != "done" || results[1] != "panicked" || results[2] != "done" {
+		t.Errorf("results = %v, want the panicking call's placeholder kept", results)
+	}
+}
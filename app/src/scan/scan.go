@@ -0,0 +1,151 @@
+package scan
+
+import (
+	"app/config"
+	"app/metrics"
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var scans = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "scan",
+	Name:      "files_total",
+	Help:      "Scanned uploads by scanner and result: clean, infected or error.",
+}, []string{"scanner", "result"})
+
+// Result is the verdict on one file, Signature names what was found in an infected one
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner checks uploaded content, implementations are selected by SCAN_BACKEND
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// Run scans r with scanner and counts the result
+func Run(ctx context.Context, scanner Scanner, r io.Reader) (Result, error) {
+	result, err := scanner.Scan(ctx, r)
+	outcome := "clean"
+	switch {
+	case err != nil:
+		outcome = "error"
+	case result.Infected:
+		outcome = "infected"
+	}
+	scans.WithLabelValues(scanner.Name(), outcome).Inc()
+	return result, err
+}
+
+// NewFromEnv returns nil when SCAN_BACKEND is unset, uploads are then not scanned
+func NewFromEnv() (Scanner, error) {
+	switch backend := config.String("SCAN_BACKEND", ""); backend {
+	case "":
+		return nil, nil
+	case "clamav":
+		return &ClamAV{
+			Addr:      config.String("CLAMAV_ADDR", "clamav:3310"),
+			Timeout:   config.Duration("CLAMAV_TIMEOUT", 2*time.Minute),
+			ChunkSize: config.Int("CLAMAV_CHUNK_SIZE", 64<<10),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported SCAN_BACKEND %q", backend)
+	}
+}
+
+// ClamAV streams the content to clamd with the INSTREAM command, clamd never needs access to the storage.
+// Its StreamMaxLength must be at least the FILES_MAX_BYTES of the app, larger streams are refused.
+type ClamAV struct {
+	Addr      string
+	Timeout   time.Duration
+	ChunkSize int
+}
+
+func (c *ClamAV) Name() string {
+	return "clamav"
+}
+
+func (c *ClamAV) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(c.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	// clamd answers as soon as it refuses the stream, the reply tells why the upload stopped
+	if err := c.stream(conn, r); err != nil {
+		if reply, readErr := readReply(conn); readErr == nil && reply != "" {
+			return parseReply(reply)
+		}
+		return Result{}, err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read the clamd reply: %w", err)
+	}
+	return parseReply(reply)
+}
+
+// stream sends the content as chunks prefixed with their length, a zero length ends it
+func (c *ClamAV) stream(conn net.Conn, r io.Reader) error {
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+	chunk := make([]byte, 4+max(c.ChunkSize, 1024))
+	for {
+		n, err := io.ReadFull(r, chunk[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(chunk[:4], uint32(n))
+			if _, err := conn.Write(chunk[:4+n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+// parseReply reads "stream: OK", "stream: <signature> FOUND" or "<reason> ERROR"
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+	return Result{}, fmt.Errorf("clamd: %s", reply)
+}
@@ -0,0 +1,71 @@
+package scan_test
+
+import (
+	"app/scan"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd answers INSTREAM like clamd, reporting streams containing "EICAR" as infected
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				if command, _ := reader.ReadString(0); command != "zINSTREAM\x00" {
+					io.WriteString(conn, "UNKNOWN COMMAND\x00")
+					return
+				}
+				var content bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					io.CopyN(&content, reader, int64(size))
+				}
+				if strings.Contains(content.String(), "EICAR") {
+					io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+				} else {
+					io.WriteString(conn, "stream: OK\x00")
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestClamAV(t *testing.T) {
+	scanner := &scan.ClamAV{Addr: fakeClamd(t), Timeout: 5 * time.Second, ChunkSize: 1024}
+	ctx := context.Background()
+
+	result, err := scanner.Scan(ctx, strings.NewReader(strings.Repeat("clean ", 1000)))
+	if err != nil || result.Infected {
+		t.Errorf("clean content: %+v %v", result, err)
+	}
+	result, err = scanner.Scan(ctx, strings.NewReader(strings.Repeat("x", 3000)+"EICAR"))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Errorf("infected content: %+v %v", result, err)
+	}
+}
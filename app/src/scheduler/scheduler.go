@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"app/metrics"
+	"app/runtimeutil"
+	"app/tracing"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	runs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "scheduler",
+		Name:      "runs_total",
+		Help:      "Scheduled task runs by result.",
+	}, []string{"task", "result"})
+
+	duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "scheduler",
+		Name:      "run_duration_seconds",
+		Help:      "Duration of scheduled task runs.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8),
+	}, []string{"task"})
+)
+
+type Task func(ctx context.Context) error
+
+// Every runs task immediately and then on every interval until ctx is cancelled.
+// A panicking task is logged and counted as a failure instead of crashing the pod.
+func Every(ctx context.Context, name string, interval time.Duration, task Task) {
+	if interval <= 0 {
+		slog.Info("scheduled task is disabled", "task", name)
+		return
+	}
+	slog.Info("scheduled task registered", "task", name, "interval", interval)
+
+	runtimeutil.Critical("scheduler "+name, func(context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(ctx, name, task)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func run(ctx context.Context, name string, task Task) {
+	// Every run is a trace of its own
+	ctx, span := tracing.Tracer().Start(ctx, "scheduled "+name,
+		trace.WithNewRoot(),
+		trace.WithAttributes(attribute.String("scheduler.task", name)),
+	)
+
+	started := time.Now()
+	result := "success"
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("scheduled task panicked", "task", name, "panic", r)
+			result = "panic"
+			err = fmt.Errorf("panic: %v", r)
+		}
+		runs.WithLabelValues(name, result).Inc()
+		duration.WithLabelValues(name).Observe(time.Since(started).Seconds())
+		tracing.End(span, err)
+	}()
+
+	if err = task(ctx); err != nil {
+		slog.Error("scheduled task failed", "task", name, "error", err)
+		result = "error"
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !jsoniter
+
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// marshal and encodeTo are the JSON encoder of every response, encoding/json unless
+// built with -tags jsoniter. Both escape HTML like json.Marshal and write compact JSON.
+func marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// encodeTo appends v to buf without the newline an Encoder ends with
+func encodeTo(buf *bytes.Buffer, v any) error {
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
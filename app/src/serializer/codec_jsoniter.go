@@ -0,0 +1,29 @@
+//go:build jsoniter
+
+package serializer
+
+import (
+	"bytes"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// codec is json-iterator with cached per-type encoders, built with -tags jsoniter. It escapes HTML
+// like encoding/json but leaves map keys unsorted, so meta and _links keys come in any order.
+var codec = jsoniter.Config{EscapeHTML: true}.Froze()
+
+func marshal(v any) ([]byte, error) {
+	return codec.Marshal(v)
+}
+
+func encodeTo(buf *bytes.Buffer, v any) error {
+	// A stream without a writer encodes into its own pooled buffer, copied over once
+	stream := codec.BorrowStream(nil)
+	defer codec.ReturnStream(stream)
+	stream.WriteVal(v)
+	if stream.Error != nil {
+		return stream.Error
+	}
+	buf.Write(stream.Buffer())
+	return nil
+}
@@ -0,0 +1,90 @@
+package serializer
+
+import (
+	"app/links"
+	"encoding/json"
+)
+
+// MIMEJSONAPI is the JSON:API media type
+const MIMEJSONAPI = "application/vnd.api+json"
+
+// JSONAPI renders resources as JSON:API documents, https://jsonapi.org/format/1.1/
+type JSONAPI struct{}
+
+type jsonAPIDocument struct {
+	Data    any               `json:"data"`
+	Meta    map[string]any    `json:"meta,omitempty"`
+	Links   map[string]string `json:"links,omitempty"`
+	JSONAPI map[string]any    `json:"jsonapi"`
+}
+
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]json.RawMessage     `json:"attributes"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+type jsonAPIRelationship struct {
+	Data []Identifier `json:"data"`
+}
+
+var jsonAPIVersion = map[string]any{"version": "1.1"}
+
+func (JSONAPI) ContentType() string { return MIMEJSONAPI }
+
+func (JSONAPI) One(r Resource) (any, error) {
+	resource, err := toJSONAPI(r)
+	if err != nil {
+		return nil, err
+	}
+	return jsonAPIDocument{Data: resource, JSONAPI: jsonAPIVersion}, nil
+}
+
+func (JSONAPI) Many(c Collection) (any, error) {
+	data := make([]jsonAPIResource, 0, len(c.Items))
+	for _, r := range c.Items {
+		resource, err := toJSONAPI(r)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, resource)
+	}
+	return jsonAPIDocument{Data: data, Meta: c.Meta, Links: hrefs(c.Links), JSONAPI: jsonAPIVersion}, nil
+}
+
+// hrefs drops the methods, JSON:API links carry none
+func hrefs(l links.Links) map[string]string {
+	if len(l) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(l))
+	for rel, link := range l {
+		out[rel] = link.Href
+	}
+	return out
+}
+
+// toJSONAPI moves every field of the value except id into attributes
+func toJSONAPI(r Resource) (jsonAPIResource, error) {
+	raw, err := encode(r)
+	if err != nil {
+		return jsonAPIResource{}, err
+	}
+	attributes := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &attributes); err != nil {
+		return jsonAPIResource{}, err
+	}
+	delete(attributes, "id")
+
+	resource := jsonAPIResource{Type: r.Type, ID: r.ID, Attributes: attributes}
+	if len(r.Relationships) > 0 {
+		resource.Relationships = map[string]jsonAPIRelationship{}
+		for name, ids := range r.Relationships {
+			resource.Relationships[name] = jsonAPIRelationship{Data: ids}
+		}
+	}
+	resource.Links = hrefs(r.Links)
+	return resource, nil
+}
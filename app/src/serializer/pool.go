@@ -0,0 +1,24 @@
+package serializer
+
+import (
+	"bytes"
+	"sync"
+)
+
+// pooledBufferMax keeps the buffers of unusually large responses out of the pool, so one big
+// export does not pin its memory for the lifetime of the process
+const pooledBufferMax = 1 << 20
+
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > pooledBufferMax {
+		return
+	}
+	buf.Reset()
+	buffers.Put(buf)
+}
@@ -0,0 +1,270 @@
+package serializer
+
+import (
+	"app/links"
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Resource is what handlers render, independent of the wire format
+type Resource struct {
+	// Type is the plural resource name, e.g. "samples"
+	Type  string
+	ID    string
+	Value any
+	Links links.Links
+	// Relationships maps a relation name to the resources it points at
+	Relationships map[string][]Identifier
+	// Fields limits the rendered fields of Value to these JSON names, all fields when empty
+	Fields []string
+}
+
+// Identifier points at another resource
+type Identifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Collection is a page of resources with its paging metadata and links such as next
+type Collection struct {
+	Items []Resource
+	Meta  map[string]any
+	Links links.Links
+}
+
+// Serializer turns resources into a response body for one media type
+type Serializer interface {
+	ContentType() string
+	One(r Resource) (any, error)
+	Many(c Collection) (any, error)
+}
+
+var serializers = []Serializer{JSONAPI{}}
+
+// Negotiate picks the serializer for the Accept header, plain JSON unless another one is asked for
+func Negotiate(ctx echo.Context) Serializer {
+	accept := ctx.Request().Header.Get(echo.HeaderAccept)
+	for _, s := range serializers {
+		if strings.Contains(accept, s.ContentType()) {
+			return s
+		}
+	}
+	return Plain{}
+}
+
+// One renders a single resource in the negotiated format
+func One(ctx echo.Context, status int, r Resource) error {
+	s := Negotiate(ctx)
+	if a, ok := s.(appender); ok {
+		return writeWith(ctx, s, status, func(buf *bytes.Buffer) error { return a.appendOne(buf, r) })
+	}
+	body, err := s.One(r)
+	if err != nil {
+		return err
+	}
+	return write(ctx, s, status, body)
+}
+
+// Many renders a collection in the negotiated format
+func Many(ctx echo.Context, status int, c Collection) error {
+	s := Negotiate(ctx)
+	if a, ok := s.(appender); ok {
+		return writeWith(ctx, s, status, func(buf *bytes.Buffer) error { return a.appendMany(buf, c) })
+	}
+	body, err := s.Many(c)
+	if err != nil {
+		return err
+	}
+	return write(ctx, s, status, body)
+}
+
+// appender is a Serializer encoding straight into the response buffer, instead of building
+// a value for json.Marshal to walk once more
+type appender interface {
+	appendOne(buf *bytes.Buffer, r Resource) error
+	appendMany(buf *bytes.Buffer, c Collection) error
+}
+
+func write(ctx echo.Context, s Serializer, status int, body any) error {
+	raw, err := marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	return ctx.Blob(status, s.ContentType(), raw)
+}
+
+// writeWith renders into a pooled buffer, which is reused once the response has been written
+func writeWith(ctx echo.Context, s Serializer, status int, render func(*bytes.Buffer) error) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := render(buf); err != nil {
+		return err
+	}
+	ctx.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	return ctx.Blob(status, s.ContentType(), buf.Bytes())
+}
+
+// Plain renders the resource value as is, with its links under _links
+type Plain struct{}
+
+type plainCollection struct {
+	Items []json.RawMessage `json:"items"`
+	Meta  map[string]any    `json:"meta,omitempty"`
+	Links links.Links       `json:"_links,omitempty"`
+}
+
+func (Plain) ContentType() string { return echo.MIMEApplicationJSON }
+
+func (Plain) One(r Resource) (any, error) {
+	return withLinks(r)
+}
+
+func (Plain) Many(c Collection) (any, error) {
+	items := make([]json.RawMessage, 0, len(c.Items))
+	for _, r := range c.Items {
+		raw, err := withLinks(r)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, raw)
+	}
+	return plainCollection{Items: items, Meta: c.Meta, Links: c.Links}, nil
+}
+
+func (Plain) appendOne(buf *bytes.Buffer, r Resource) error {
+	return appendWithLinks(buf, r)
+}
+
+// appendMany writes what Many's plainCollection marshals to
+func (Plain) appendMany(buf *bytes.Buffer, c Collection) error {
+	buf.WriteString(`{"items":[`)
+	for i, r := range c.Items {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := appendWithLinks(buf, r); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	if len(c.Meta) > 0 {
+		buf.WriteString(`,"meta":`)
+		if err := encodeTo(buf, c.Meta); err != nil {
+			return err
+		}
+	}
+	if len(c.Links) > 0 {
+		buf.WriteString(`,"_links":`)
+		if err := encodeTo(buf, c.Links); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// Pick keeps only fields of an encoded JSON object, in their original order
+func Pick(raw json.RawMessage, fields []string) (json.RawMessage, error) {
+	if len(fields) == 0 {
+		return raw, nil
+	}
+	var buf bytes.Buffer
+	if err := pickTo(&buf, raw, fields); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pickTo appends the fields of raw to buf, or raw unchanged when it is no object
+func pickTo(buf *bytes.Buffer, raw []byte, fields []string) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		// Not an object, nothing to pick from
+		buf.Write(raw)
+		return err
+	}
+	start := buf.Len()
+	buf.WriteByte('{')
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		key := token.(string)
+		if !slices.Contains(fields, key) {
+			continue
+		}
+		if buf.Len() > start+1 {
+			buf.WriteByte(',')
+		}
+		if err := encodeTo(buf, key); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// encode marshals the value with only the selected fields
+func encode(r Resource) (json.RawMessage, error) {
+	raw, err := marshal(r.Value)
+	if err != nil {
+		return nil, err
+	}
+	return Pick(raw, r.Fields)
+}
+
+// withLinks appends _links to the encoded value, keeping its own field order
+func withLinks(r Resource) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	if err := appendWithLinks(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// appendWithLinks appends the encoded value with _links added to buf
+func appendWithLinks(buf *bytes.Buffer, r Resource) error {
+	start := buf.Len()
+	if len(r.Fields) == 0 {
+		if err := encodeTo(buf, r.Value); err != nil {
+			return err
+		}
+	} else {
+		scratch := getBuffer()
+		defer putBuffer(scratch)
+		if err := encodeTo(scratch, r.Value); err != nil {
+			return err
+		}
+		if err := pickTo(buf, scratch.Bytes(), r.Fields); err != nil {
+			return err
+		}
+	}
+
+	raw := buf.Bytes()[start:]
+	if len(r.Links) == 0 || len(raw) < 2 || raw[len(raw)-1] != '}' {
+		return nil
+	}
+	empty := len(raw) == 2
+	buf.Truncate(buf.Len() - 1)
+	if !empty {
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"_links":`)
+	if err := encodeTo(buf, r.Links); err != nil {
+		return err
+	}
+	buf.WriteByte('}')
+	return nil
+}
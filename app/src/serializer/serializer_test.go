@@ -0,0 +1,86 @@
+package serializer_test
+
+import (
+	"app/links"
+	"app/serializer"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type benchSample struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Message   string    `json:"message"`
+}
+
+func benchCollection(n int) serializer.Collection {
+	now := time.Now()
+	collection := serializer.Collection{Meta: map[string]any{"limit": n, "has_more": true}}
+	for i := range n {
+		id := "0195f1c2-7a3b-7c4d-8e5f-" + time.Duration(i).String()
+		collection.Items = append(collection.Items, serializer.Resource{
+			Type:  "samples",
+			ID:    id,
+			Value: benchSample{ID: id, CreatedAt: now, UpdatedAt: now, Message: "hello <world>"},
+			Links: links.Links{"self": {Href: "/sample/" + id}, "update": {Href: "/sample/" + id, Method: http.MethodPut}},
+		})
+	}
+	collection.Links = links.Links{"next": {Href: "/samples?cursor=abc"}}
+	return collection
+}
+
+func render(tb testing.TB, accept string, collection serializer.Collection) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/samples", nil)
+	req.Header.Set(echo.HeaderAccept, accept)
+	rec := httptest.NewRecorder()
+	if err := serializer.Many(echo.New().NewContext(req, rec), http.StatusOK, collection); err != nil {
+		tb.Fatal(err)
+	}
+	return rec
+}
+
+func TestPlainMany(t *testing.T) {
+	collection := benchCollection(2)
+	collection.Items[1].Fields = []string{"message"}
+	rec := render(t, echo.MIMEApplicationJSON, collection)
+
+	var body struct {
+		Items []map[string]json.RawMessage `json:"items"`
+		Meta  map[string]any               `json:"meta"`
+		Links map[string]links.Link        `json:"_links"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid json %s: %v", rec.Body, err)
+	}
+	if len(body.Items) != 2 || body.Links["next"].Href != "/samples?cursor=abc" || body.Meta["has_more"] != true {
+		t.Fatalf("body = %s", rec.Body)
+	}
+	if _, ok := body.Items[0]["_links"]; !ok {
+		t.Errorf("first item has no _links: %s", rec.Body)
+	}
+	if _, ok := body.Items[1]["created_at"]; ok || len(body.Items[1]) != 2 {
+		t.Errorf("picked item = %v, want message and _links", body.Items[1])
+	}
+}
+
+func BenchmarkPlainMany(b *testing.B) {
+	collection := benchCollection(100)
+	b.ReportAllocs()
+	for b.Loop() {
+		render(b, echo.MIMEApplicationJSON, collection)
+	}
+}
+
+func BenchmarkJSONAPIMany(b *testing.B) {
+	collection := benchCollection(100)
+	b.ReportAllocs()
+	for b.Loop() {
+		render(b, serializer.MIMEJSONAPI, collection)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/client"
+	"context"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// aggregatorPageSize is the page size the aggregator reads the upstream collection with
+const aggregatorPageSize = 100
+
+// AggregatorService composes responses from another deployment of this app, reached through
+// the client package, as an API gateway in front of the Sample API would
+type AggregatorService struct {
+	Upstream *client.Client
+	// MaxPages bounds how many upstream pages a summary reads, it is marked truncated beyond that
+	MaxPages int
+}
+
+// SampleDetail is a sample together with its labels, two upstream calls answered as one
+type SampleDetail struct {
+	Sample client.Sample     `json:"sample"`
+	Labels map[string]string `json:"labels"`
+}
+
+// SampleSummary aggregates the upstream samples matching a label selector
+type SampleSummary struct {
+	Selector string     `json:"selector"`
+	Count    int        `json:"count"`
+	Pages    int        `json:"pages"`
+	Oldest   *time.Time `json:"oldest"`
+	Newest   *time.Time `json:"newest"`
+	// Truncated is set when more samples match than MaxPages pages hold
+	Truncated bool `json:"truncated"`
+}
+
+// upstreamError classifies a failed upstream call: a missing sample and a rejected request stay what they are,
+// anything else means the upstream deployment is unavailable
+func upstreamError(err error) error {
+	switch client.StatusOf(err) {
+	case http.StatusNotFound:
+		return apperrors.Wrap(apperrors.NotFound, "upstream", err)
+	case http.StatusBadRequest:
+		return apperrors.Wrap(apperrors.Validation, "upstream", err)
+	default:
+		return apperrors.Wrap(apperrors.Dependency, "upstream is unavailable", err)
+	}
+}
+
+// SampleDetail reads the sample and its labels from upstream concurrently
+func (s *AggregatorService) SampleDetail(ctx context.Context, id string) (SampleDetail, error) {
+	var detail SampleDetail
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() (err error) {
+		detail.Sample, err = s.Upstream.GetSample(ctx, id)
+		return err
+	})
+	group.Go(func() (err error) {
+		detail.Labels, err = s.Upstream.Labels(ctx, id)
+		return err
+	})
+	if err := group.Wait(); err != nil {
+		return SampleDetail{}, upstreamError(err)
+	}
+	return detail, nil
+}
+
+// Summary walks the upstream collection page by page, counting the samples matching selector
+func (s *AggregatorService) Summary(ctx context.Context, selector string) (SampleSummary, error) {
+	summary := SampleSummary{Selector: selector}
+	opts := client.ListOptions{Limit: aggregatorPageSize, Selector: selector}
+	for {
+		if summary.Pages == max(s.MaxPages, 1) {
+			summary.Truncated = true
+			return summary, nil
+		}
+		page, err := s.Upstream.ListSamples(ctx, opts)
+		if err != nil {
+			return SampleSummary{}, upstreamError(err)
+		}
+		summary.Pages++
+		summary.Count += len(page.Samples)
+		for _, sample := range page.Samples {
+			if summary.Oldest == nil || sample.CreatedAt.Before(*summary.Oldest) {
+				summary.Oldest = &sample.CreatedAt
+			}
+			if summary.Newest == nil || sample.CreatedAt.After(*summary.Newest) {
+				summary.Newest = &sample.CreatedAt
+			}
+		}
+		if page.NextCursor == "" {
+			return summary, nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/db"
+	"app/model"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidAPIKey  = apperrors.New(apperrors.Unauthorized, "invalid api key")
+	ErrAPIKeyNotFound = apperrors.New(apperrors.NotFound, "api key not found")
+	ErrQuotaExceeded  = errors.New("request quota exceeded")
+)
+
+// apiKeyPrefix marks the keys this app issues, so a leaked one is recognisable in secret scanners
+const apiKeyPrefix = "ak_"
+
+// Most usage periods a report lists, a little over a year of days and months
+const apiKeyUsageHistory = 400
+
+type APIKeyService struct{}
+
+// Quota is the state of one of a key's quotas, Remaining is what is left after the current request
+type Quota struct {
+	Window    string    `json:"window"`
+	Period    string    `json:"period"`
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+type UsageReport struct {
+	Key    model.APIKey        `json:"key"`
+	Quotas []Quota             `json:"quotas"`
+	Days   []model.APIKeyUsage `json:"days"`
+	Months []model.APIKeyUsage `json:"months"`
+}
+
+type quotaWindow struct {
+	name   string
+	period string
+	limit  int64
+	reset  time.Time
+}
+
+// quotaWindows are the day and month the time falls in, periods are counted in UTC
+func quotaWindows(key model.APIKey, now time.Time) []quotaWindow {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return []quotaWindow{
+		{name: "day", period: day.Format(time.DateOnly), limit: key.DailyQuota, reset: day.AddDate(0, 0, 1)},
+		{name: "month", period: month.Format("2006-01"), limit: key.MonthlyQuota, reset: month.AddDate(0, 1, 0)},
+	}
+}
+
+func (w quotaWindow) quota(used int64) Quota {
+	return Quota{
+		Window:    w.name,
+		Period:    w.period,
+		Limit:     w.limit,
+		Used:      used,
+		Remaining: max(w.limit-used, 0),
+		ResetAt:   w.reset,
+	}
+}
+
+// Create issues a key, the returned secret is shown once and only its hash is kept
+func (s *APIKeyService) Create(ctx context.Context, name string, dailyQuota, monthlyQuota int64) (model.APIKey, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return model.APIKey{}, "", err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	key := model.APIKey{
+		Name:         name,
+		Prefix:       secret[:len(apiKeyPrefix)+8],
+		KeyHash:      hashToken(secret),
+		DailyQuota:   dailyQuota,
+		MonthlyQuota: monthlyQuota,
+	}
+	if err := db.DB.WithContext(ctx).Create(&key).Error; err != nil {
+		return key, "", err
+	}
+	return key, secret, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	err := db.DB.WithContext(ctx).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (s *APIKeyService) Find(ctx context.Context, id string) (model.APIKey, error) {
+	var key model.APIKey
+	err := db.DB.WithContext(ctx).First(&key, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return key, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// Revoke stops a key from authenticating, its usage is kept for reporting
+func (s *APIKeyService) Revoke(ctx context.Context, id string) error {
+	result := db.DB.WithContext(ctx).Model(&model.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate finds the active key for a presented secret
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (model.APIKey, error) {
+	var key model.APIKey
+	err := db.DB.WithContext(ctx).First(&key, "key_hash = ? AND revoked_at IS NULL", hashToken(secret)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return key, ErrInvalidAPIKey
+	}
+	return key, err
+}
+
+// Consume counts one request against the key's daily and monthly usage and returns the tightest quota.
+// A request over either quota is rejected with ErrQuotaExceeded and not counted. Keys without quotas
+// are still counted for the usage report, the returned quota has a zero Limit then.
+func (s *APIKeyService) Consume(ctx context.Context, key model.APIKey, now time.Time) (Quota, error) {
+	var tightest Quota
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tightest = Quota{}
+		for _, window := range quotaWindows(key, now) {
+			// The upsert locks the usage row, so concurrent requests of a key are counted one at a time
+			usage := model.APIKeyUsage{KeyID: key.ID, Period: window.period, Requests: 1, UpdatedAt: now}
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key_id"}, {Name: "period"}},
+				DoUpdates: clause.Assignments(map[string]any{"requests": gorm.Expr("requests + 1"), "updated_at": now}),
+			}).Create(&usage).Error
+			if err != nil {
+				return err
+			}
+			if window.limit <= 0 {
+				continue
+			}
+
+			if err := tx.First(&usage, "key_id = ? AND period = ?", key.ID, window.period).Error; err != nil {
+				return err
+			}
+			quota := window.quota(usage.Requests)
+			if tightest.Limit == 0 || quota.Remaining < tightest.Remaining {
+				tightest = quota
+			}
+			if usage.Requests > window.limit {
+				tightest = window.quota(window.limit)
+				return ErrQuotaExceeded
+			}
+		}
+		return nil
+	})
+	return tightest, err
+}
+
+// Usage reports the key's quotas as of now and its requests per day and month, newest first
+func (s *APIKeyService) Usage(ctx context.Context, key model.APIKey, now time.Time) (UsageReport, error) {
+	report := UsageReport{Key: key, Quotas: []Quota{}, Days: []model.APIKeyUsage{}, Months: []model.APIKeyUsage{}}
+
+	var history []model.APIKeyUsage
+	err := db.DB.WithContext(ctx).
+		Where("key_id = ?", key.ID).
+		Order("period DESC").
+		Limit(apiKeyUsageHistory).
+		Find(&history).Error
+	if err != nil {
+		return report, err
+	}
+
+	used := map[string]int64{}
+	for _, usage := range history {
+		used[usage.Period] = usage.Requests
+		if len(usage.Period) == len(time.DateOnly) {
+			report.Days = append(report.Days, usage)
+		} else {
+			report.Months = append(report.Months, usage)
+		}
+	}
+	for _, window := range quotaWindows(key, now) {
+		if window.limit > 0 {
+			report.Quotas = append(report.Quotas, window.quota(used[window.period]))
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"app/apptest"
+	"app/model"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyConsume(t *testing.T) {
+	apptest.DB(t, &model.APIKey{}, &model.APIKeyUsage{})
+	s := APIKeyService{}
+	ctx := context.Background()
+
+	key, secret, err := s.Create(ctx, "demo", 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authenticated, err := s.Authenticate(ctx, secret); err != nil || authenticated.ID != key.ID {
+		t.Fatalf("authenticate = %+v, %v", authenticated, err)
+	}
+
+	day := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		name      string
+		at        time.Time
+		window    string
+		remaining int64
+		exceeded  bool
+	}{
+		{"first", day, "day", 2, false},
+		{"second", day, "day", 1, false},
+		{"last of the day", day, "day", 0, false},
+		{"over the daily quota", day, "day", 0, true},
+		{"next day, last of the month", day.AddDate(0, 0, 1), "month", 0, false},
+		{"over the monthly quota", day.AddDate(0, 0, 1), "month", 0, true},
+		{"next month", day.AddDate(0, 1, 0), "day", 2, false},
+	}
+	for _, step := range steps {
+		quota, err := s.Consume(ctx, key, step.at)
+		if exceeded := errors.Is(err, ErrQuotaExceeded); exceeded != step.exceeded || (err != nil && !exceeded) {
+			t.Fatalf("%s: err = %v, want exceeded %v", step.name, err, step.exceeded)
+		}
+		if quota.Window != step.window || quota.Remaining != step.remaining {
+			t.Errorf("%s: quota = %+v, want %s with %d remaining", step.name, quota, step.window, step.remaining)
+		}
+	}
+
+	// Rejected requests are not counted
+	report, err := s.Usage(ctx, key, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Months) != 2 || report.Months[1].Requests != 4 {
+		t.Errorf("months = %+v, want 4 requests in 2026-10", report.Months)
+	}
+	if len(report.Days) != 3 || report.Days[2].Requests != 3 {
+		t.Errorf("days = %+v, want 3 requests on 2026-10-14", report.Days)
+	}
+
+	if err := s.Revoke(ctx, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("revoked key authenticates: %v", err)
+	}
+}
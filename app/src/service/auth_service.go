@@ -0,0 +1,196 @@
+package service
+
+import (
+	"app/adminauth"
+	"app/apperrors"
+	"app/config"
+	"app/db"
+	"app/model"
+	"app/password"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCredentials = apperrors.New(apperrors.Unauthorized, "invalid username or password")
+	ErrAccountLocked      = apperrors.New(apperrors.Unauthorized, "account is locked")
+	ErrUserExists         = apperrors.New(apperrors.Conflict, "username is already taken")
+	ErrWeakPassword       = apperrors.New(apperrors.Validation, "password is too short")
+	ErrInvalidUsername    = apperrors.New(apperrors.Validation, "username is invalid")
+)
+
+// Passwords below this length are rejected on registration and change
+const minPasswordLength = 12
+
+type AuthService struct{}
+
+type AuthUser struct {
+	ID       string
+	Username string
+}
+
+func maxFailedLogins() int {
+	return config.Int("AUTH_MAX_FAILED_LOGINS", 5)
+}
+
+func lockoutDuration() time.Duration {
+	return config.Duration("AUTH_LOCKOUT_DURATION", 15*time.Minute)
+}
+
+func (s *AuthService) Register(username, email, plain string) (model.User, error) {
+	if len(username) < 3 || len(username) > 64 {
+		return model.User{}, ErrInvalidUsername
+	}
+	if len(plain) < minPasswordLength {
+		return model.User{}, ErrWeakPassword
+	}
+
+	// The admin account from the environment cannot be shadowed by a registered user,
+	// in any case as the username lookups of MySQL's _ci collations ignore it
+	if strings.EqualFold(username, adminUsername()) {
+		return model.User{}, ErrUserExists
+	}
+
+	var count int64
+	db.DB.Model(&model.User{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		return model.User{}, ErrUserExists
+	}
+
+	hash, err := password.Hash(plain)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user := model.User{Username: username, Email: email, PasswordHash: hash, PasswordSetAt: time.Now()}
+	if err := db.DB.Create(&user).Error; err != nil {
+		return model.User{}, err
+	}
+	slog.Info("user registered", "user_id", user.ID)
+	return user, nil
+}
+
+// Authenticate checks the admin account from ADMIN_USERNAME / ADMIN_PASSWORD first, so a registered
+// user cannot take its name, and then the registered users. Repeated failures lock the user account.
+func (s *AuthService) Authenticate(username, plain string) (AuthUser, error) {
+	if strings.EqualFold(username, adminUsername()) {
+		return s.authenticateAdmin(username, plain)
+	}
+
+	var user model.User
+	err := db.DB.Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Hashing anyway keeps unknown usernames from answering faster than wrong passwords
+		password.Verify(plain, dummyHash())
+		return AuthUser{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return AuthUser{}, err
+	}
+
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		return AuthUser{}, ErrAccountLocked
+	}
+
+	ok, err := password.Verify(plain, user.PasswordHash)
+	if err != nil {
+		return AuthUser{}, err
+	}
+	if !ok {
+		s.recordFailure(&user)
+		return AuthUser{}, ErrInvalidCredentials
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		db.DB.Model(&user).Updates(map[string]any{"failed_logins": 0, "locked_until": nil})
+	}
+	return AuthUser{ID: user.ID, Username: user.Username}, nil
+}
+
+func (s *AuthService) recordFailure(user *model.User) {
+	updates := map[string]any{"failed_logins": gorm.Expr("failed_logins + 1")}
+	if user.FailedLogins+1 >= maxFailedLogins() {
+		updates["locked_until"] = time.Now().Add(lockoutDuration())
+		updates["failed_logins"] = 0
+		slog.Warn("account locked after repeated login failures", "user_id", user.ID)
+	}
+	if err := db.DB.Model(user).Updates(updates).Error; err != nil {
+		slog.Error("failed to record login failure", "error", err)
+	}
+}
+
+// ChangePassword replaces the user's password and revokes every refresh token issued to the user
+func (s *AuthService) ChangePassword(userID, current, next string) error {
+	var user model.User
+	if err := db.DB.First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	ok, err := password.Verify(current, user.PasswordHash)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.recordFailure(&user)
+		return ErrInvalidCredentials
+	}
+	if len(next) < minPasswordLength {
+		return ErrWeakPassword
+	}
+
+	hash, err := password.Hash(next)
+	if err != nil {
+		return err
+	}
+	// A refresh token stolen before the change stops working with it
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&user).Updates(map[string]any{
+			"password_hash":   hash,
+			"password_set_at": now,
+			"failed_logins":   0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now).Error
+	})
+}
+
+func adminUsername() string {
+	return config.String("ADMIN_USERNAME", "admin")
+}
+
+// dummyHash is verified against for usernames without an account, costing what a real check does
+var dummyHash = sync.OnceValue(func() string {
+	hash, err := password.Hash("no account has this password")
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+func (s *AuthService) authenticateAdmin(username, plain string) (AuthUser, error) {
+	adminUsername := adminUsername()
+	adminPassword := config.String("ADMIN_PASSWORD", "")
+	if adminPassword == "" {
+		return AuthUser{}, ErrInvalidCredentials
+	}
+
+	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(adminUsername))
+	passwordMatch := subtle.ConstantTimeCompare([]byte(plain), []byte(adminPassword))
+	if usernameMatch&passwordMatch != 1 {
+		return AuthUser{}, ErrInvalidCredentials
+	}
+	return AuthUser{ID: adminauth.AdminUserID, Username: adminUsername}, nil
+}
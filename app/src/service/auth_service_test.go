@@ -0,0 +1,74 @@
+package service
+
+import (
+	"app/adminauth"
+	"app/apptest"
+	"app/jwtauth"
+	"app/model"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChangePasswordRevokesRefreshTokens(t *testing.T) {
+	apptest.DB(t, &model.User{}, &model.RefreshToken{}, &model.RevokedToken{})
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := TokenService{Signer: &jwtauth.Signer{PrivateKey: private, PublicKey: public, AccessTTL: time.Minute}}
+	auth := AuthService{}
+
+	user, err := auth.Register("alice", "", "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two logins, two refresh token families
+	first, err := tokens.Issue(AuthUser{ID: user.ID, Username: user.Username})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := tokens.Issue(AuthUser{ID: user.ID, Username: user.Username})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := tokens.Issue(AuthUser{ID: "user-2", Username: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := auth.ChangePassword(user.ID, "correct horse battery", "another long password"); err != nil {
+		t.Fatal(err)
+	}
+	for name, pair := range map[string]TokenPair{"first login": first, "second login": second} {
+		if _, err := tokens.Refresh(pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("%s: refresh after the password change: error = %v, want ErrInvalidRefreshToken", name, err)
+		}
+	}
+	if _, err := tokens.Refresh(other.RefreshToken); err != nil {
+		t.Errorf("refresh of another user: %v, want their tokens kept", err)
+	}
+	if _, err := auth.Authenticate("alice", "another long password"); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+}
+
+func TestAdminAccountCannotBeShadowed(t *testing.T) {
+	apptest.DB(t, &model.User{})
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "admin password")
+	auth := AuthService{}
+
+	for _, name := range []string{"admin", "Admin", "ADMIN"} {
+		if _, err := auth.Register(name, "", "correct horse battery"); !errors.Is(err, ErrUserExists) {
+			t.Errorf("register %q: error = %v, want ErrUserExists", name, err)
+		}
+	}
+	if user, err := auth.Authenticate("admin", "admin password"); err != nil || user.ID != adminauth.AdminUserID {
+		t.Errorf("admin login = %+v, %v, want the admin account", user, err)
+	}
+	if _, err := auth.Authenticate("nobody", "correct horse battery"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("unknown user: error = %v, want ErrInvalidCredentials", err)
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/config"
+	"app/db"
+	"app/runtimeutil"
+	"app/storage"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const backupPrefix = "backups/"
+
+var ErrInvalidBackupName = apperrors.New(apperrors.NotFound, "invalid backup name")
+
+type BackupService struct{}
+
+// Create streams a gzipped logical dump into object storage. mysqldump is used when it is
+// on the PATH (and BACKUP_METHOD is not "builtin"), otherwise a built-in dumper is used so the
+// distroless release image can still take backups.
+func (s *BackupService) Create(ctx context.Context) (storage.Object, error) {
+	cfg := db.ConnectionConfig()
+	key := fmt.Sprintf("%s%s-%s.sql.gz", backupPrefix, cfg.DBName, time.Now().UTC().Format("20060102T150405Z"))
+
+	dump := s.dumpBuiltin
+	// mysqldump cannot dial through DB_CONNECTOR
+	if _, err := exec.LookPath("mysqldump"); err == nil && cfg.Net == "tcp" && config.String("BACKUP_METHOD", "auto") != "builtin" {
+		dump = s.dumpMysqldump
+	}
+
+	reader, writer := io.Pipe()
+	runtimeutil.Go("backup writer", func() {
+		err := errWriterPanicked
+		defer func() { writer.CloseWithError(err) }()
+		gz := gzip.NewWriter(writer)
+		err = dump(ctx, gz)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+	})
+
+	started := time.Now()
+	if err := storage.Default.Put(ctx, key, reader, -1, "application/gzip"); err != nil {
+		reader.CloseWithError(err)
+		return storage.Object{}, fmt.Errorf("backup failed: %w", err)
+	}
+	slog.Info("backup created", "key", key, "duration", time.Since(started))
+
+	_, obj, err := s.open(ctx, key)
+	return obj, err
+}
+
+func (s *BackupService) List(ctx context.Context) ([]storage.Object, error) {
+	return storage.Default.List(ctx, backupPrefix)
+}
+
+// Open returns the backup by its file name, without the backups/ prefix
+func (s *BackupService) Open(ctx context.Context, name string) (io.ReadCloser, storage.Object, error) {
+	if name == "" || path.Base(name) != name || !strings.HasSuffix(name, ".sql.gz") {
+		return nil, storage.Object{}, ErrInvalidBackupName
+	}
+	return s.open(ctx, backupPrefix+name)
+}
+
+func (s *BackupService) open(ctx context.Context, key string) (io.ReadCloser, storage.Object, error) {
+	return storage.Default.Get(ctx, key)
+}
+
+func (s *BackupService) dumpMysqldump(ctx context.Context, w io.Writer) error {
+	cfg := db.ConnectionConfig()
+	host, port, found := strings.Cut(cfg.Addr, ":")
+	if !found {
+		port = "3306"
+	}
+
+	args := append([]string{
+		"--single-transaction", "--routines", "--triggers", "--no-tablespaces",
+		"-h", host, "-P", port, "-u", cfg.User,
+	}, db.ClientTLSArgs()...)
+	cmd := exec.CommandContext(ctx, "mysqldump", append(args, cfg.DBName)...)
+	// The password is passed through the environment so it does not show up in ps
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+cfg.Passwd)
+	cmd.Stdout = w
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mysqldump failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// dumpBuiltin writes CREATE TABLE and INSERT statements for every table in a consistent snapshot
+func (s *BackupService) dumpBuiltin(ctx context.Context, w io.Writer) error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "-- k8s-sample-app logical backup %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintln(out, "SET FOREIGN_KEY_CHECKS=0;")
+
+	tables, err := queryStrings(ctx, tx, "SHOW FULL TABLES WHERE Table_type = 'BASE TABLE'")
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := dumpTable(ctx, tx, out, table); err != nil {
+			return fmt.Errorf("failed to dump %s: %w", table, err)
+		}
+	}
+
+	fmt.Fprintln(out, "SET FOREIGN_KEY_CHECKS=1;")
+	return out.Flush()
+}
+
+func dumpTable(ctx context.Context, tx *sql.Tx, out *bufio.Writer, table string) error {
+	var name, create string
+	if err := tx.QueryRowContext(ctx, "SHOW CREATE TABLE `"+table+"`").Scan(&name, &create); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nDROP TABLE IF EXISTS `%s`;\n%s;\n", table, create)
+
+	rows, err := tx.QueryContext(ctx, "SELECT * FROM `"+table+"`")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	scan := make([]any, len(columns))
+	for i := range values {
+		scan[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(scan...); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "INSERT INTO `%s` VALUES (", table)
+		for i, value := range values {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			out.WriteString(sqlLiteral(value))
+		}
+		out.WriteString(");\n")
+	}
+	return rows.Err()
+}
+
+func sqlLiteral(value sql.RawBytes) string {
+	if value == nil {
+		return "NULL"
+	}
+	if !utf8.Valid(value) {
+		return "X'" + hex.EncodeToString(value) + "'"
+	}
+
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range string(value) {
+		switch r {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case 0x1a:
+			b.WriteString(`\Z`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+func queryStrings(ctx context.Context, tx *sql.Tx, query string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var value, kind string
+		if err := rows.Scan(&value, &kind); err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/config"
+	"app/httpclient"
+	"app/kube"
+	"app/peers"
+	"context"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type Peer struct {
+	Name      string     `json:"name"`
+	IP        string     `json:"ip"`
+	Phase     string     `json:"phase"`
+	Ready     bool       `json:"ready"`
+	Node      string     `json:"node"`
+	Self      bool       `json:"self"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+type ClusterService struct{}
+
+// PodInfo identifies the replica serving a request
+type PodInfo struct {
+	Hostname  string `json:"hostname"`
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace"`
+	Node      string `json:"node"`
+	PodIP     string `json:"pod_ip"`
+}
+
+func CurrentPod() PodInfo {
+	hostname, _ := os.Hostname()
+	return PodInfo{
+		Hostname:  hostname,
+		Pod:       kube.PodName(),
+		Namespace: kube.Namespace(),
+		Node:      config.String("NODE_NAME", ""),
+		PodIP:     config.String("POD_IP", ""),
+	}
+}
+
+type FanoutReport struct {
+	Service   string         `json:"service"`
+	Path      string         `json:"path"`
+	Replicas  int            `json:"replicas"`
+	Succeeded int            `json:"succeeded"`
+	Results   []peers.Result `json:"results"`
+}
+
+var fanoutClient = httpclient.New(config.Duration("FANOUT_TIMEOUT", 3*time.Second))
+
+// Fanout calls FANOUT_PATH on every replica of FANOUT_SERVICE directly, bypassing the Service's
+// load balancing, so the report shows each replica answering for itself
+func (s *ClusterService) Fanout(ctx context.Context) (FanoutReport, error) {
+	report := FanoutReport{
+		Service: config.String("FANOUT_SERVICE", "app"),
+		Path:    config.String("FANOUT_PATH", "/hostname"),
+	}
+	endpoints, err := peers.NewResolverFromEnv().Resolve(ctx, report.Service, config.Int("FANOUT_PORT", 8080))
+	if err != nil {
+		return report, apperrors.Wrap(apperrors.Dependency, "resolve replicas", err)
+	}
+
+	report.Results = peers.FanOut(ctx, fanoutClient, endpoints, report.Path)
+	report.Replicas = len(report.Results)
+	for _, result := range report.Results {
+		if result.Error == "" && result.Status < http.StatusBadRequest {
+			report.Succeeded++
+		}
+	}
+	return report, nil
+}
+
+// Peers lists the pods selected by PEERS_SERVICE_NAME, or when it is unset the pods
+// sharing this pod's labels, which are the replicas of the same Deployment
+func (s *ClusterService) Peers(ctx context.Context) ([]Peer, error) {
+	if !kube.Enabled() {
+		return nil, kube.ErrNotInCluster
+	}
+
+	selector, err := s.peerSelector(ctx)
+	if err != nil {
+		return nil, apperrors.Wrap(apperrors.Dependency, "kubernetes api", err)
+	}
+	pods, err := kube.Client.CoreV1().Pods(kube.Namespace()).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, apperrors.Wrap(apperrors.Dependency, "kubernetes api", err)
+	}
+
+	self := kube.PodName()
+	peers := make([]Peer, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		peer := Peer{
+			Name:  pod.Name,
+			IP:    pod.Status.PodIP,
+			Phase: string(pod.Status.Phase),
+			Ready: podReady(&pod),
+			Node:  pod.Spec.NodeName,
+			Self:  pod.Name == self,
+		}
+		if pod.Status.StartTime != nil {
+			peer.StartedAt = &pod.Status.StartTime.Time
+		}
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Name < peers[j].Name })
+	return peers, nil
+}
+
+func (s *ClusterService) peerSelector(ctx context.Context) (string, error) {
+	namespace := kube.Namespace()
+	if name := config.String("PEERS_SERVICE_NAME", ""); name != "" {
+		svc, err := kube.Client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return labels.SelectorFromSet(svc.Spec.Selector).String(), nil
+	}
+
+	pod, err := kube.Client.CoreV1().Pods(namespace).Get(ctx, kube.PodName(), metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	// The template hash differs between ReplicaSets, dropping it keeps pods of a rollout in the list
+	set := labels.Set{}
+	for key, value := range pod.Labels {
+		if key != "pod-template-hash" {
+			set[key] = value
+		}
+	}
+	return labels.SelectorFromSet(set).String(), nil
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
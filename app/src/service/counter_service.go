@@ -0,0 +1,63 @@
+package service
+
+import (
+	"app/kube"
+	"app/redisdb"
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const counterKey = "counter:visits"
+
+// localVisits is this replica's count, lost on restart and different on every replica
+var localVisits atomic.Int64
+
+// Counter is the visit counter as one request saw it
+type Counter struct {
+	Pod string `json:"pod"`
+	// Visits is the count every replica shares when Backend is redis, the replica's own otherwise
+	Visits int64 `json:"visits"`
+	// Local is what this replica counted itself, what every replica would show without redis
+	Local   int64  `json:"local"`
+	Backend string `json:"backend"`
+}
+
+type CounterService struct{}
+
+// Increment counts a visit. With redis the count lives outside the replicas, so scaling or
+// restarting them loses nothing and every replica answers with the same sequence.
+func (s *CounterService) Increment(ctx context.Context) (Counter, error) {
+	counter := Counter{Pod: kube.PodName(), Local: localVisits.Add(1), Backend: "memory"}
+	counter.Visits = counter.Local
+	if redisdb.Client == nil {
+		return counter, nil
+	}
+	visits, err := redisdb.Client.Incr(ctx, counterKey).Result()
+	if err != nil {
+		// The replica's own count while redis is down, Backend tells the caller
+		slog.Warn("redis is unavailable, counting in the pod", "error", err)
+		return counter, nil
+	}
+	counter.Visits, counter.Backend = visits, "redis"
+	return counter, nil
+}
+
+// Counter reads the count without adding a visit
+func (s *CounterService) Counter(ctx context.Context) (Counter, error) {
+	counter := Counter{Pod: kube.PodName(), Local: localVisits.Load(), Backend: "memory"}
+	counter.Visits = counter.Local
+	if redisdb.Client == nil {
+		return counter, nil
+	}
+	visits, err := redisdb.Client.Get(ctx, counterKey).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		slog.Warn("redis is unavailable, reading the pod's count", "error", err)
+		return counter, nil
+	}
+	counter.Visits, counter.Backend = visits, "redis"
+	return counter, nil
+}
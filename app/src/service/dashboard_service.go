@@ -0,0 +1,65 @@
+package service
+
+import (
+	"app/db"
+	"app/health"
+	"app/model"
+	"context"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Number of samples shown on the dashboard
+const dashboardRecentSamples = 5
+
+type DashboardCounts struct {
+	Samples     int64 `json:"samples"`
+	PendingJobs int64 `json:"pending_jobs"`
+	DeadJobs    int64 `json:"dead_jobs"`
+}
+
+type Dashboard struct {
+	Counts        DashboardCounts `json:"counts"`
+	RecentSamples []model.Sample  `json:"recent_samples"`
+	Pod           PodInfo         `json:"pod"`
+	Health        health.Report   `json:"health"`
+	GeneratedAt   time.Time       `json:"generated_at"`
+	LatencyMs     float64         `json:"latency_ms"`
+}
+
+type DashboardService struct{}
+
+// Build runs every part of the dashboard concurrently, so the response takes as long as
+// the slowest part rather than their sum. Each goroutine writes only its own field.
+// A failed database query cancels the others, dependency health is reported whatever its state.
+func (s *DashboardService) Build(ctx context.Context) (Dashboard, error) {
+	dashboard := Dashboard{GeneratedAt: time.Now(), Pod: CurrentPod()}
+	group, ctx := errgroup.WithContext(ctx)
+
+	group.Go(func() error {
+		return db.DB.WithContext(ctx).Model(&model.Sample{}).Count(&dashboard.Counts.Samples).Error
+	})
+	group.Go(func() error {
+		return db.DB.WithContext(ctx).Model(&model.Job{}).Where("status = ?", model.JobPending).Count(&dashboard.Counts.PendingJobs).Error
+	})
+	group.Go(func() error {
+		return db.DB.WithContext(ctx).Model(&model.DeadJob{}).Count(&dashboard.Counts.DeadJobs).Error
+	})
+	group.Go(func() error {
+		return db.DB.WithContext(ctx).Order("created_at DESC").Limit(dashboardRecentSamples).Find(&dashboard.RecentSamples).Error
+	})
+
+	group.Go(func() error {
+		report, ok := health.Default.Cached()
+		if !ok {
+			report = health.Default.Run(ctx)
+		}
+		dashboard.Health = report
+		return nil
+	})
+
+	err := group.Wait()
+	dashboard.LatencyMs = float64(time.Since(dashboard.GeneratedAt).Microseconds()) / 1000
+	return dashboard, err
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"app/apptest"
+	"app/db"
+	"app/fieldcrypt"
+	"app/model"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// useKeys loads a key ring of the ids given, the first one active. Each key is its id's first byte repeated.
+func useKeys(t *testing.T, ids ...string) {
+	t.Helper()
+	var entries []string
+	for _, id := range ids {
+		entries = append(entries, id+":"+base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id[:1], 32))))
+	}
+	t.Setenv("ENCRYPTION_KEYS", strings.Join(entries, ","))
+	if err := fieldcrypt.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		t.Setenv("ENCRYPTION_KEYS", "")
+		fieldcrypt.Init()
+	})
+}
+
+func rawEmail(t *testing.T, id string) string {
+	t.Helper()
+	var row rawUser
+	if err := db.DB.Model(&model.User{}).Select("id", "email").First(&row, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return row.Email
+}
+
+func TestRotateKeysStoresCiphertext(t *testing.T) {
+	apptest.DB(t, &model.User{})
+	useKeys(t, "old")
+	user := model.User{Username: "x", Email: "x@example.com"}
+	if err := db.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored := rawEmail(t, user.ID); !strings.HasPrefix(stored, "enc:old:") {
+		t.Fatalf("stored email = %q, want it encrypted with the old key", stored)
+	}
+
+	useKeys(t, "new", "old")
+	rotated, err := (&EncryptionService{}).RotateKeys()
+	if err != nil || rotated != 1 {
+		t.Fatalf("rotate = %d, %v, want 1 row rotated", rotated, err)
+	}
+	stored := rawEmail(t, user.ID)
+	if !strings.HasPrefix(stored, "enc:new:") || fieldcrypt.NeedsRotation(stored) {
+		t.Errorf("stored email after rotation = %q, want it encrypted with the new key", stored)
+	}
+	var read model.User
+	if err := db.DB.First(&read, "id = ?", user.ID).Error; err != nil || read.Email != "x@example.com" {
+		t.Errorf("read email = %q (%v), want the plaintext back", read.Email, err)
+	}
+	if rotated, _ := (&EncryptionService{}).RotateKeys(); rotated != 0 {
+		t.Errorf("second rotation rotated %d rows, want none", rotated)
+	}
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/config"
+	"app/db"
+	"app/runtimeutil"
+	"app/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const exportPrefix = "exports/"
+
+// exportBatch is how many rows are handed to the Parquet writer at once
+const exportBatch = 1000
+
+var ErrExportTableNotAllowed = apperrors.New(apperrors.NotFound, "table is not exported")
+
+// ExportService writes tables to Parquet files in object storage, for analytics tools reading them
+// there instead of from the database. Only the tables of EXPORT_TABLES are exported, so tables
+// holding credentials such as users and api_keys never leave the database.
+type ExportService struct {
+	Tables []string
+}
+
+func NewExportServiceFromEnv() ExportService {
+	tables := config.List("EXPORT_TABLES")
+	if len(tables) == 0 {
+		tables = []string{"samples", "sample_revisions", "sample_labels", "sample_stats"}
+	}
+	return ExportService{Tables: tables}
+}
+
+// Export streams every row of table into exports/<table>/<table>-<time>.parquet. Rows are read with
+// one query and written in row groups as they arrive, the table is never held in memory.
+func (s *ExportService) Export(ctx context.Context, table string) (storage.Object, error) {
+	if !slices.Contains(s.Tables, table) {
+		return storage.Object{}, ErrExportTableNotAllowed
+	}
+	key := fmt.Sprintf("%s%s/%s-%s.parquet", exportPrefix, table, table, time.Now().UTC().Format("20060102T150405Z"))
+
+	rows, err := db.DB.WithContext(ctx).Table(table).Rows()
+	if err != nil {
+		return storage.Object{}, fmt.Errorf("export of %s failed: %w", table, err)
+	}
+
+	reader, writer := io.Pipe()
+	var count int
+	runtimeutil.Go("export writer", func() {
+		err := errWriterPanicked
+		defer func() { writer.CloseWithError(err) }()
+		defer rows.Close()
+		count, err = writeParquet(writer, table, rows)
+	})
+
+	started := time.Now()
+	if err := storage.Default.Put(ctx, key, reader, -1, "application/vnd.apache.parquet"); err != nil {
+		reader.CloseWithError(err)
+		return storage.Object{}, fmt.Errorf("export of %s failed: %w", table, err)
+	}
+	slog.Info("table exported", "table", table, "key", key, "rows", count, "duration", time.Since(started))
+
+	_, obj, err := storage.Default.Get(ctx, key)
+	return obj, err
+}
+
+func (s *ExportService) List(ctx context.Context) ([]storage.Object, error) {
+	return storage.Default.List(ctx, exportPrefix)
+}
+
+// exportColumn converts the values database/sql scans of one column into Parquet values
+type exportColumn struct {
+	name  string
+	index int
+	kind  string
+}
+
+// Parquet value kinds of exportColumn
+const (
+	exportInt       = "int"
+	exportDouble    = "double"
+	exportTimestamp = "timestamp"
+	exportBytes     = "bytes"
+	exportString    = "string"
+)
+
+// exportKind maps a database type to a Parquet one, DECIMAL loses its exactness as a double
+func exportKind(databaseType string) string {
+	switch t := strings.ToUpper(databaseType); {
+	case strings.Contains(t, "INT"):
+		return exportInt
+	case strings.Contains(t, "FLOAT"), strings.Contains(t, "DOUBLE"), strings.Contains(t, "REAL"),
+		strings.Contains(t, "DECIMAL"), strings.Contains(t, "NUMERIC"):
+		return exportDouble
+	case strings.Contains(t, "DATE"), strings.Contains(t, "TIMESTAMP"):
+		return exportTimestamp
+	case strings.Contains(t, "BLOB"), strings.Contains(t, "BINARY"):
+		return exportBytes
+	}
+	return exportString
+}
+
+// writeParquet writes rows as a Parquet file with one optional column per result column
+func writeParquet(w io.Writer, table string, rows *sql.Rows) (int, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	group := parquet.Group{}
+	columns := make([]exportColumn, len(types))
+	for i, columnType := range types {
+		kind := exportKind(columnType.DatabaseTypeName())
+		node := parquet.String()
+		switch kind {
+		case exportInt:
+			node = parquet.Int(64)
+		case exportDouble:
+			node = parquet.Leaf(parquet.DoubleType)
+		case exportTimestamp:
+			node = parquet.Timestamp(parquet.Millisecond)
+		case exportBytes:
+			node = parquet.Leaf(parquet.ByteArrayType)
+		}
+		group[columnType.Name()] = parquet.Optional(node)
+		columns[i] = exportColumn{name: columnType.Name(), kind: kind}
+	}
+	schema := parquet.NewSchema(table, group)
+	// The schema orders its columns by name, the values are placed by that order
+	for i := range columns {
+		columns[i].index = slices.IndexFunc(schema.Columns(), func(path []string) bool {
+			return len(path) == 1 && path[0] == columns[i].name
+		})
+	}
+
+	writer := parquet.NewWriter(w, schema, parquet.Compression(&parquet.Zstd),
+		parquet.MaxRowsPerRowGroup(int64(config.Int("EXPORT_ROW_GROUP_ROWS", 100000))))
+	values := make([]any, len(columns))
+	targets := make([]any, len(columns))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	batch := make([]parquet.Row, 0, exportBatch)
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(targets...); err != nil {
+			return count, err
+		}
+		row := make(parquet.Row, len(columns))
+		for i, column := range columns {
+			value, err := column.value(values[i])
+			if err != nil {
+				return count, fmt.Errorf("column %s: %w", column.name, err)
+			}
+			row[column.index] = value
+		}
+		batch = append(batch, row)
+		if len(batch) == exportBatch {
+			if _, err := writer.WriteRows(batch); err != nil {
+				return count, err
+			}
+			count += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	if _, err := writer.WriteRows(batch); err != nil {
+		return count, err
+	}
+	count += len(batch)
+	return count, writer.Close()
+}
+
+// exportTimeLayouts are the layouts of times scanned as text, as SQLite returns them
+var exportTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", time.DateTime, time.DateOnly}
+
+func (c exportColumn) value(scanned any) (parquet.Value, error) {
+	if scanned == nil {
+		return parquet.NullValue().Level(0, 0, c.index), nil
+	}
+	text := func() string {
+		switch v := scanned.(type) {
+		case []byte:
+			return string(v)
+		case string:
+			return v
+		}
+		return fmt.Sprint(scanned)
+	}
+
+	var value parquet.Value
+	switch c.kind {
+	case exportInt:
+		switch v := scanned.(type) {
+		case int64:
+			value = parquet.Int64Value(v)
+		case bool:
+			n := int64(0)
+			if v {
+				n = 1
+			}
+			value = parquet.Int64Value(n)
+		default:
+			n, err := strconv.ParseInt(text(), 10, 64)
+			if err != nil {
+				return value, err
+			}
+			value = parquet.Int64Value(n)
+		}
+	case exportDouble:
+		switch v := scanned.(type) {
+		case float64:
+			value = parquet.DoubleValue(v)
+		case int64:
+			value = parquet.DoubleValue(float64(v))
+		default:
+			f, err := strconv.ParseFloat(text(), 64)
+			if err != nil {
+				return value, err
+			}
+			value = parquet.DoubleValue(f)
+		}
+	case exportTimestamp:
+		t, ok := scanned.(time.Time)
+		if !ok {
+			var err error
+			for _, layout := range exportTimeLayouts {
+				if t, err = time.Parse(layout, text()); err == nil {
+					break
+				}
+			}
+			if err != nil {
+				return value, err
+			}
+		}
+		value = parquet.Int64Value(t.UnixMilli())
+	case exportBytes:
+		b, ok := scanned.([]byte)
+		if !ok {
+			b = []byte(text())
+		}
+		value = parquet.ByteArrayValue(b)
+	default:
+		value = parquet.ByteArrayValue([]byte(text()))
+	}
+	return value.Level(0, 1, c.index), nil
+}
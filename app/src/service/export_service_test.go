@@ -0,0 +1,64 @@
+package service
+
+import (
+	"app/apptest"
+	"app/db"
+	"app/model"
+	"app/storage"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestExportParquet(t *testing.T) {
+	apptest.DB(t)
+	local, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := storage.Default
+	storage.Default = local
+	t.Cleanup(func() { storage.Default = previous })
+
+	for _, message := range []string{"first", "second", "third"} {
+		if err := db.DB.Create(&model.Sample{Message: message}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := ExportService{Tables: []string{"samples"}}
+	ctx := context.Background()
+	if _, err := s.Export(ctx, "users"); !errors.Is(err, ErrExportTableNotAllowed) {
+		t.Fatalf("export of a table not listed: %v", err)
+	}
+
+	obj, err := s.Export(ctx, "samples")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, _, err := storage.Default.Get(ctx, obj.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := file.NumRows(); rows != 3 {
+		t.Errorf("exported %d rows, want 3", rows)
+	}
+	for _, name := range []string{"id", "message", "created_at", "deleted_at"} {
+		if _, ok := file.Schema().Lookup(name); !ok {
+			t.Errorf("column %s is missing from %s", name, file.Schema())
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/db"
+	"app/jobs"
+	"app/model"
+	"app/scan"
+	"app/signedurl"
+	"app/storage"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFileNotFound      = apperrors.New(apperrors.NotFound, "file not found")
+	ErrSignedURLDisabled = apperrors.New(apperrors.NotFound, "signed urls are disabled, set SIGNED_URL_KEY")
+	ErrFileNotScanned    = apperrors.New(apperrors.Conflict, "file has not been scanned yet")
+	ErrFileQuarantined   = apperrors.New(apperrors.Conflict, "file is quarantined")
+	ErrScanDisabled      = apperrors.New(apperrors.NotFound, "upload scanning is disabled, set SCAN_BACKEND")
+)
+
+const (
+	// filePrefix is where uploaded files are kept in object storage
+	filePrefix = "files/"
+	// quarantinePrefix is where infected files are moved, out of reach of the download route
+	quarantinePrefix = "quarantine/"
+)
+
+// FileScanJob scans an uploaded file, queued after the upload so the request does not wait for it
+const FileScanJob = "files.scan"
+
+type FileScan struct {
+	FileID string `json:"file_id"`
+}
+
+type FileService struct {
+	// URLs signs download URLs, nil without SIGNED_URL_KEY
+	URLs *signedurl.Signer
+	// Scanner checks uploads, nil without SCAN_BACKEND
+	Scanner scan.Scanner
+	// UploadTTL is how long a resumable upload is kept without receiving a chunk
+	UploadTTL time.Duration
+}
+
+// Upload streams r to object storage and records the file once it is stored completely
+func (s *FileService) Upload(ctx context.Context, name, contentType string, r io.Reader) (model.File, error) {
+	file := model.File{ID: uuid.New().String(), Name: name, ContentType: contentType, ScanStatus: model.FileScanSkipped}
+	file.StorageKey = filePrefix + file.ID
+	if s.Scanner != nil {
+		file.ScanStatus = model.FileScanPending
+	}
+
+	hash := sha256.New()
+	counter := &countingReader{Reader: io.TeeReader(r, hash)}
+	if err := storage.Default.Put(ctx, file.StorageKey, counter, -1, contentType); err != nil {
+		return model.File{}, err
+	}
+	file.Size = counter.n
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if err := db.DB.WithContext(ctx).Create(&file).Error; err != nil {
+		// The object would be unreachable without its row
+		storage.Default.Delete(context.WithoutCancel(ctx), file.StorageKey)
+		return model.File{}, err
+	}
+	if file.ScanStatus == model.FileScanPending {
+		// The file stays pending and unserved, POST /files/:id/scan queues it again
+		if _, err := jobs.Enqueue(ctx, FileScanJob, FileScan{FileID: file.ID}, jobs.Options{}); err != nil {
+			slog.Error("failed to queue the upload scan", "file", file.ID, "error", err)
+		}
+	}
+	return file, nil
+}
+
+// Rescan queues another scan of the file, e.g. after the scanner's signatures were updated
+func (s *FileService) Rescan(ctx context.Context, id string) (model.File, error) {
+	if s.Scanner == nil {
+		return model.File{}, ErrScanDisabled
+	}
+	file, err := s.Get(ctx, id)
+	if err != nil {
+		return model.File{}, err
+	}
+	if file.ScanStatus == model.FileScanInfected {
+		return model.File{}, ErrFileQuarantined
+	}
+	file.ScanStatus = model.FileScanPending
+	if err := db.DB.WithContext(ctx).Model(&file).Update("scan_status", file.ScanStatus).Error; err != nil {
+		return model.File{}, err
+	}
+	_, err = jobs.Enqueue(ctx, FileScanJob, FileScan{FileID: file.ID}, jobs.Options{})
+	return file, err
+}
+
+// ScanJob is the FileScanJob handler. Infected files are moved to the quarantine prefix, a failed
+// scan is marked on the file and retried by the queue.
+func (s *FileService) ScanJob(ctx context.Context, job *model.Job) error {
+	var payload FileScan
+	if err := jobs.Decode(job, &payload); err != nil {
+		return err
+	}
+	file, err := s.Get(ctx, payload.FileID)
+	if errors.Is(err, ErrFileNotFound) {
+		// Deleted before it was scanned
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if file.ScanStatus != model.FileScanPending && file.ScanStatus != model.FileScanFailed {
+		return nil
+	}
+
+	reader, _, err := storage.Default.Get(ctx, file.StorageKey)
+	if err != nil {
+		return err
+	}
+	result, err := scan.Run(ctx, s.Scanner, reader)
+	reader.Close()
+	now := time.Now()
+	if err != nil {
+		s.recordScan(ctx, &file, model.FileScanFailed, err.Error(), now)
+		return err
+	}
+	if !result.Infected {
+		return s.recordScan(ctx, &file, model.FileScanClean, "", now)
+	}
+
+	slog.Warn("uploaded file is infected, quarantining it", "file", file.ID, "signature", result.Signature)
+	quarantined := quarantinePrefix + file.ID
+	if err := moveObject(ctx, file.StorageKey, quarantined, file.ContentType); err != nil {
+		return err
+	}
+	file.StorageKey = quarantined
+	return s.recordScan(ctx, &file, model.FileScanInfected, result.Signature, now)
+}
+
+func (s *FileService) recordScan(ctx context.Context, file *model.File, status, result string, at time.Time) error {
+	if len(result) > 255 {
+		result = result[:255]
+	}
+	return db.DB.WithContext(ctx).Model(file).Updates(map[string]any{
+		"scan_status": status, "scan_result": result, "scanned_at": at, "storage_key": file.StorageKey,
+	}).Error
+}
+
+// moveObject copies the object to its new key before deleting it, object storage has no rename
+func moveObject(ctx context.Context, from, to, contentType string) error {
+	reader, obj, err := storage.Default.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := storage.Default.Put(ctx, to, reader, obj.Size, contentType); err != nil {
+		return err
+	}
+	return storage.Default.Delete(ctx, from)
+}
+
+func (s *FileService) List(ctx context.Context) ([]model.File, error) {
+	var files []model.File
+	err := db.DB.WithContext(ctx).Order("created_at DESC").Find(&files).Error
+	return files, err
+}
+
+func (s *FileService) Get(ctx context.Context, id string) (model.File, error) {
+	var file model.File
+	err := db.DB.WithContext(ctx).First(&file, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.File{}, ErrFileNotFound
+	}
+	return file, err
+}
+
+// Open returns the file's metadata and content
+func (s *FileService) Open(ctx context.Context, id string) (io.ReadCloser, model.File, error) {
+	file, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, model.File{}, err
+	}
+	switch file.ScanStatus {
+	case model.FileScanPending, model.FileScanFailed:
+		return nil, model.File{}, ErrFileNotScanned
+	case model.FileScanInfected:
+		return nil, model.File{}, ErrFileQuarantined
+	}
+	reader, _, err := storage.Default.Get(ctx, file.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, model.File{}, ErrFileNotFound
+	}
+	return reader, file, err
+}
+
+func (s *FileService) Delete(ctx context.Context, id string) error {
+	file, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := db.DB.WithContext(ctx).Delete(&file).Error; err != nil {
+		return err
+	}
+	return storage.Default.Delete(ctx, file.StorageKey)
+}
+
+// SignedURL returns the query that lets anyone download the file at path until ttl from now
+func (s *FileService) SignedURL(ctx context.Context, id, path string, ttl time.Duration) (string, time.Time, error) {
+	if s.URLs == nil {
+		return "", time.Time{}, ErrSignedURLDisabled
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	return s.URLs.Sign(path, expires).Encode(), expires, nil
+}
+
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
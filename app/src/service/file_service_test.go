@@ -0,0 +1,98 @@
+package service
+
+import (
+	"app/apptest"
+	"app/db"
+	"app/model"
+	"app/scan"
+	"app/storage"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// stubScanner flags files containing "EICAR"
+type stubScanner struct{}
+
+func (stubScanner) Name() string { return "stub" }
+
+func (stubScanner) Scan(ctx context.Context, r io.Reader) (scan.Result, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return scan.Result{}, err
+	}
+	if strings.Contains(string(content), "EICAR") {
+		return scan.Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return scan.Result{}, nil
+}
+
+func TestFileScanQuarantine(t *testing.T) {
+	apptest.DB(t, &model.File{}, &model.Job{})
+	local, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := storage.Default
+	storage.Default = local
+	t.Cleanup(func() { storage.Default = previous })
+
+	s := FileService{Scanner: stubScanner{}}
+	ctx := context.Background()
+	upload := func(content string) model.File {
+		file, err := s.Upload(ctx, "upload.txt", "text/plain", strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.ScanStatus != model.FileScanPending {
+			t.Fatalf("status after upload = %q", file.ScanStatus)
+		}
+		if _, _, err := s.Open(ctx, file.ID); !errors.Is(err, ErrFileNotScanned) {
+			t.Fatalf("open before the scan = %v", err)
+		}
+		return file
+	}
+	scanJob := func(file model.File) model.File {
+		var queued []model.Job
+		if err := db.DB.Where("type = ?", FileScanJob).Find(&queued).Error; err != nil || len(queued) == 0 {
+			t.Fatalf("scan job not queued: %v", err)
+		}
+		// Files already scanned are skipped, running every job again is safe
+		for _, job := range queued {
+			if err := s.ScanJob(ctx, &job); err != nil {
+				t.Fatal(err)
+			}
+		}
+		scanned, err := s.Get(ctx, file.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return scanned
+	}
+
+	clean := scanJob(upload("hello"))
+	if clean.ScanStatus != model.FileScanClean || clean.ScannedAt == nil {
+		t.Errorf("clean file = %+v", clean)
+	}
+	if reader, _, err := s.Open(ctx, clean.ID); err != nil {
+		t.Errorf("open clean file: %v", err)
+	} else {
+		reader.Close()
+	}
+
+	infected := scanJob(upload("X5O!P%@AP EICAR"))
+	if infected.ScanStatus != model.FileScanInfected || infected.ScanResult != "Eicar-Test-Signature" {
+		t.Errorf("infected file = %+v", infected)
+	}
+	if !strings.HasPrefix(infected.StorageKey, quarantinePrefix) {
+		t.Errorf("infected file stored at %q", infected.StorageKey)
+	}
+	if _, _, err := storage.Default.Get(ctx, filePrefix+infected.ID); err == nil {
+		t.Error("infected file is still under the files prefix")
+	}
+	if _, _, err := s.Open(ctx, infected.ID); !errors.Is(err, ErrFileQuarantined) {
+		t.Errorf("open infected file = %v", err)
+	}
+}
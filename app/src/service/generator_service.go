@@ -0,0 +1,72 @@
+package service
+
+import (
+	"app/db"
+	"app/model"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+)
+
+// Rows per INSERT when generating, large enough to be fast and small enough for max_allowed_packet
+const generateBatchSize = 1000
+
+// Generated samples are spread over this period so listings and pagination have something to page through
+const generateSpread = 30 * 24 * time.Hour
+
+type GeneratorService struct{}
+
+// GenerateSamples bulk inserts count fake samples and returns how many were created.
+// No sample.created events are published, a demo fill must not send thousands of notifications.
+func (s *GeneratorService) GenerateSamples(ctx context.Context, count int) (int, error) {
+	now := time.Now()
+	created := 0
+	for created < count {
+		batch := make([]model.Sample, min(generateBatchSize, count-created))
+		for i := range batch {
+			at := now.Add(-rand.N(generateSpread))
+			batch[i] = model.Sample{
+				ID:        uuid.New().String(),
+				CreatedAt: at,
+				UpdatedAt: at,
+				Message:   gofakeit.HipsterSentence(),
+			}
+		}
+		if err := db.DB.WithContext(ctx).Create(&batch).Error; err != nil {
+			return created, err
+		}
+		created += len(batch)
+	}
+	return created, nil
+}
+
+// LoadSamples inserts the samples of a JSON array such as [{"id": "...", "message": "..."}],
+// so a stub serves the same canned data on every start. Missing ids and timestamps are filled in.
+func (s *GeneratorService) LoadSamples(ctx context.Context, r io.Reader) (int, error) {
+	var samples []model.Sample
+	if err := json.NewDecoder(r).Decode(&samples); err != nil {
+		return 0, err
+	}
+	if len(samples) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	for i := range samples {
+		if samples[i].CreatedAt.IsZero() {
+			samples[i].CreatedAt = now
+		}
+		if samples[i].UpdatedAt.IsZero() {
+			samples[i].UpdatedAt = samples[i].CreatedAt
+		}
+	}
+	if err := db.DB.WithContext(ctx).CreateInBatches(&samples, generateBatchSize).Error; err != nil {
+		return 0, err
+	}
+	return len(samples), nil
+}
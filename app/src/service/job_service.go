@@ -0,0 +1,104 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/db"
+	"app/jobs"
+	"app/model"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrDeadJobNotFound = apperrors.New(apperrors.NotFound, "dead job not found")
+
+type JobService struct{}
+
+type EnqueueJobInput struct {
+	Type     string
+	Payload  any
+	Priority string
+	RunAt    time.Time
+}
+
+// Enqueue adds a job of a type this replica can run, with priority high/normal/low and an optional start time
+func (s *JobService) Enqueue(ctx context.Context, input EnqueueJobInput) (model.Job, error) {
+	if !jobs.Registered(input.Type) {
+		return model.Job{}, jobs.ErrUnknownType
+	}
+	priority, err := jobs.ParsePriority(input.Priority)
+	if err != nil {
+		return model.Job{}, err
+	}
+	return jobs.Enqueue(ctx, input.Type, input.Payload, jobs.Options{Priority: priority, RunAt: input.RunAt})
+}
+
+// ListJobs returns queued jobs in the order they will be claimed, status "" lists every status
+func (s *JobService) ListJobs(ctx context.Context, status string, limit int) ([]model.Job, error) {
+	var list []model.Job
+	query := db.DB.WithContext(ctx).Order("priority DESC, run_at").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	result := query.Find(&list)
+	return list, result.Error
+}
+
+// ListDeadJobs returns the most recently failed jobs first
+func (s *JobService) ListDeadJobs(ctx context.Context, limit int) ([]model.DeadJob, error) {
+	var dead []model.DeadJob
+	result := db.DB.WithContext(ctx).Order("failed_at DESC").Limit(limit).Find(&dead)
+	return dead, result.Error
+}
+
+func (s *JobService) GetDeadJob(ctx context.Context, id string) (model.DeadJob, error) {
+	var dead model.DeadJob
+	err := db.DB.WithContext(ctx).First(&dead, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return dead, ErrDeadJobNotFound
+	}
+	return dead, err
+}
+
+// RequeueDeadJob puts the job back on the queue under its original ID with a fresh set of attempts
+func (s *JobService) RequeueDeadJob(ctx context.Context, id string) (model.Job, error) {
+	var job model.Job
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var dead model.DeadJob
+		err := tx.First(&dead, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeadJobNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		job = model.Job{
+			ID:           dead.ID,
+			CreatedAt:    dead.CreatedAt,
+			Type:         dead.Type,
+			Payload:      dead.Payload,
+			Priority:     dead.Priority,
+			TraceContext: dead.TraceContext,
+			Status:       model.JobPending,
+			RunAt:        time.Now(),
+			MaxAttempts:  jobs.DefaultMaxAttempts,
+			LastError:    dead.LastError,
+		}
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&dead).Error
+	})
+	return job, err
+}
+
+func (s *JobService) DiscardDeadJob(ctx context.Context, id string) error {
+	result := db.DB.WithContext(ctx).Delete(&model.DeadJob{}, "id = ?", id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrDeadJobNotFound
+	}
+	return result.Error
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/profiling"
+	"app/storage"
+	"context"
+	"io"
+	"path"
+	"strings"
+)
+
+var ErrInvalidProfileName = apperrors.New(apperrors.NotFound, "invalid profile name")
+
+type ProfileService struct{}
+
+// List returns the profiles captured by every pod, including the ones that no longer exist
+func (s *ProfileService) List(ctx context.Context) ([]storage.Object, error) {
+	return storage.Default.List(ctx, profiling.Prefix)
+}
+
+// Open returns the profile name captured by pod
+func (s *ProfileService) Open(ctx context.Context, pod, name string) (io.ReadCloser, storage.Object, error) {
+	for _, part := range []string{pod, name} {
+		if part == "" || part == "." || part == ".." || path.Base(part) != part {
+			return nil, storage.Object{}, ErrInvalidProfileName
+		}
+	}
+	if !strings.HasSuffix(name, ".pprof") {
+		return nil, storage.Object{}, ErrInvalidProfileName
+	}
+	return storage.Default.Get(ctx, profiling.Prefix+pod+"/"+name)
+}
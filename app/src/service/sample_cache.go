@@ -0,0 +1,72 @@
+package service
+
+import (
+	"app/config"
+	"sync"
+	"time"
+)
+
+// sampleCache keeps the results of the hottest sample reads, the first listing pages and the stats
+// totals, for SAMPLE_CACHE_TTL, in front of sampleReads. Writes through SampleService clear it, the
+// writes of other replicas show once the entries expire. It is off with the TTL of 0, the default.
+var sampleCache = newReadCache(config.Duration("SAMPLE_CACHE_TTL", 0))
+
+type readCache struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	// generation changes on every clear, so a read started before a write is not stored after it
+	generation uint64
+	entries    map[string]readEntry
+}
+
+type readEntry struct {
+	value   any
+	expires time.Time
+}
+
+// SampleCacheEnabled reports whether SAMPLE_CACHE_TTL keeps the hot sample reads, which is what
+// warming them up fills
+func SampleCacheEnabled() bool {
+	return sampleCache.ttl > 0
+}
+
+func newReadCache(ttl time.Duration) *readCache {
+	return &readCache{ttl: ttl, entries: map[string]readEntry{}}
+}
+
+// get returns the cached value of key or loads it, coalesced with the identical reads in flight.
+// Callers share the returned value and must not modify it.
+func (c *readCache) get(key string, load func() (any, error)) (any, error) {
+	if c.ttl <= 0 {
+		v, err, _ := sampleReads.Do(key, load)
+		return v, err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expires) {
+		c.mu.Unlock()
+		return entry.value, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	v, err, _ := sampleReads.Do(key, load)
+	if err != nil {
+		return v, err
+	}
+	c.mu.Lock()
+	if c.generation == generation {
+		c.entries[key] = readEntry{value: v, expires: now.Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return v, nil
+}
+
+func (c *readCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	clear(c.entries)
+}
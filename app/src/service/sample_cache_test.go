@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadCache(t *testing.T) {
+	cache := newReadCache(time.Minute)
+	loads := 0
+	load := func() (any, error) {
+		loads++
+		return loads, nil
+	}
+
+	for range 3 {
+		if v, _ := cache.get("test:cache", load); v != 1 {
+			t.Fatalf("got %v, want the first load", v)
+		}
+	}
+	cache.clear()
+	if v, _ := cache.get("test:cache", load); v != 2 {
+		t.Errorf("got %v after clear, want a new load", v)
+	}
+
+	// A read that started before a write is not stored after it
+	cache.get("test:racing", func() (any, error) {
+		cache.clear()
+		return "stale", nil
+	})
+	if v, _ := cache.get("test:racing", func() (any, error) { return "fresh", nil }); v != "fresh" {
+		t.Errorf("got %v, want the read after the write", v)
+	}
+}
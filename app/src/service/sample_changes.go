@@ -0,0 +1,152 @@
+package service
+
+import (
+	"app/config"
+	"app/db"
+	"app/model"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Change is one created, updated or deleted sample in the change feed
+type Change struct {
+	Type   string       `json:"type"`
+	Sample model.Sample `json:"sample"`
+}
+
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+type ChangeFeed struct {
+	Changes []Change `json:"changes"`
+	// Cursor is passed as since on the next request to continue after these changes
+	Cursor   string `json:"cursor"`
+	TimedOut bool   `json:"timed_out"`
+}
+
+// Most changes returned at once, a client that gets this many asks again immediately
+const changeBatchSize = 100
+
+// Other replicas' writes are only seen by polling, local writes wake waiting requests at once
+var changePollInterval = config.Duration("SAMPLE_CHANGES_POLL_INTERVAL", time.Second)
+
+// sampleChanged is closed and replaced on every local write to wake every waiting request
+var (
+	sampleChangedMu sync.Mutex
+	sampleChanged   = make(chan struct{})
+)
+
+func notifySampleChange() {
+	sampleCache.clear()
+	sampleChangedMu.Lock()
+	close(sampleChanged)
+	sampleChanged = make(chan struct{})
+	sampleChangedMu.Unlock()
+}
+
+func sampleChangeSignal() <-chan struct{} {
+	sampleChangedMu.Lock()
+	defer sampleChangedMu.Unlock()
+	return sampleChanged
+}
+
+// changeCursor is the (updated_at, id) of the last change a client has seen
+type changeCursor struct {
+	UpdatedAt time.Time `json:"u"`
+	ID        string    `json:"i"`
+}
+
+func (c changeCursor) String() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeChangeCursor(cursor string) (changeCursor, error) {
+	var c changeCursor
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.UpdatedAt.IsZero() {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// WaitForChanges returns the changes after since, waiting up to wait for one to happen.
+// An empty since starts at the current time without waiting, so a client first gets a cursor
+// and then tails from there.
+// Changes are ordered by updated_at, so a write whose transaction commits after a later one
+// was already returned is skipped, which is acceptable for notifications but not for replication.
+func (s *SampleService) WaitForChanges(ctx context.Context, since string, wait time.Duration) (ChangeFeed, error) {
+	if since == "" {
+		// Truncated to the column's millisecond precision, a write in the same millisecond may be
+		// reported although it happened just before, rather than being missed
+		start := time.Now().Truncate(time.Millisecond)
+		return ChangeFeed{Changes: []Change{}, Cursor: changeCursor{UpdatedAt: start}.String()}, nil
+	}
+	after, err := decodeChangeCursor(since)
+	if err != nil {
+		return ChangeFeed{}, err
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	poll := time.NewTicker(changePollInterval)
+	defer poll.Stop()
+
+	for {
+		// Taken before querying so a write landing during the query is not missed
+		signal := sampleChangeSignal()
+
+		feed, err := changesAfter(ctx, after)
+		if err != nil || len(feed.Changes) > 0 {
+			return feed, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return feed, ctx.Err()
+		case <-timeout.C:
+			feed.TimedOut = true
+			return feed, nil
+		case <-signal:
+		case <-poll.C:
+		}
+	}
+}
+
+func changesAfter(ctx context.Context, after changeCursor) (ChangeFeed, error) {
+	var samples []model.Sample
+	err := db.DB.WithContext(ctx).Unscoped().
+		Where("updated_at > ? OR (updated_at = ? AND id > ?)", after.UpdatedAt, after.UpdatedAt, after.ID).
+		Order("updated_at, id").
+		Limit(changeBatchSize).
+		Find(&samples).Error
+
+	feed := ChangeFeed{Changes: make([]Change, 0, len(samples)), Cursor: after.String()}
+	if err != nil {
+		return feed, err
+	}
+	for _, sample := range samples {
+		change := Change{Type: ChangeUpdated, Sample: sample}
+		switch {
+		case sample.DeletedAt.Valid:
+			change.Type = ChangeDeleted
+		case sample.CreatedAt.Equal(sample.UpdatedAt):
+			change.Type = ChangeCreated
+		}
+		feed.Changes = append(feed.Changes, change)
+	}
+	if len(samples) > 0 {
+		last := samples[len(samples)-1]
+		feed.Cursor = changeCursor{UpdatedAt: last.UpdatedAt, ID: last.ID}.String()
+	}
+	return feed, nil
+}
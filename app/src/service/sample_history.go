@@ -0,0 +1,199 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/db"
+	"app/events"
+	"app/model"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrRevisionNotFound = apperrors.New(apperrors.NotFound, "revision not found")
+
+// sampleFieldValues are the fields whose changes are kept in the history, by JSON name
+func sampleFieldValues(sample *model.Sample) map[string]*string {
+	if sample == nil {
+		return map[string]*string{}
+	}
+	values := map[string]*string{"message": &sample.Message, "key": sample.Key}
+	if sample.DeletedAt.Valid {
+		deletedAt := sample.DeletedAt.Time.UTC().Format(time.RFC3339Nano)
+		values["deleted_at"] = &deletedAt
+	}
+	return values
+}
+
+// historyFields orders the changes of a revision
+var historyFields = []string{"message", "key", "deleted_at"}
+
+// sampleDiff lists the fields that differ between before and after, nil is a sample that did not exist
+func sampleDiff(before, after *model.Sample) []model.FieldChange {
+	old, current := sampleFieldValues(before), sampleFieldValues(after)
+	var changes []model.FieldChange
+	for _, field := range historyFields {
+		o, n := old[field], current[field]
+		if o == nil && n == nil || o != nil && n != nil && *o == *n {
+			continue
+		}
+		changes = append(changes, model.FieldChange{Field: field, Old: copyValue(o), New: copyValue(n)})
+	}
+	return changes
+}
+
+func copyValue(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
+
+// recordRevision adds revision, numbered next, with the changes it already holds such as the labels and
+// those from before to after in the write's transaction. A write changing nothing adds none. Writes read
+// the sample with forUpdate so they number their revisions one after the other, the unique index is the
+// backstop.
+func recordRevision(tx *gorm.DB, revision model.SampleRevision, before, after *model.Sample) error {
+	revision.Changes = append(revision.Changes, sampleDiff(before, after)...)
+	if len(revision.Changes) == 0 {
+		return nil
+	}
+	var last int
+	err := tx.Model(&model.SampleRevision{}).Where("sample_id = ?", after.ID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	revision.SampleID = after.ID
+	revision.Revision = last + 1
+	return tx.Create(&revision).Error
+}
+
+// History lists the revisions of a sample newest first, deleted samples keep theirs
+func (s *SampleService) History(ctx context.Context, id string) ([]model.SampleRevision, error) {
+	var sample model.Sample
+	err := db.DB.WithContext(ctx).Unscoped().Select("id").First(&sample, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSampleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []model.SampleRevision{}
+	err = db.DB.WithContext(ctx).Where("sample_id = ?", id).Order("revision DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+// RecentRevisions lists the latest writes to any sample newest first, the audit trail of the samples
+func (s *SampleService) RecentRevisions(ctx context.Context, limit int) ([]model.SampleRevision, error) {
+	revisions := []model.SampleRevision{}
+	err := db.DB.WithContext(ctx).Order("created_at DESC").Limit(limit).Find(&revisions).Error
+	return revisions, err
+}
+
+// setSampleField sets a field of the history to a recorded value, nil unsets it
+func setSampleField(sample *model.Sample, field string, value *string) error {
+	switch field {
+	case "message":
+		sample.Message = ""
+		if value != nil {
+			sample.Message = *value
+		}
+	case "key":
+		sample.Key = copyValue(value)
+	case "deleted_at":
+		sample.DeletedAt = gorm.DeletedAt{}
+		if value != nil {
+			at, err := time.Parse(time.RFC3339Nano, *value)
+			if err != nil {
+				return fmt.Errorf("revision holds an invalid deleted_at %q: %w", *value, err)
+			}
+			sample.DeletedAt = gorm.DeletedAt{Time: at, Valid: true}
+		}
+	}
+	return nil
+}
+
+// RevertSample restores the fields the history tracks to their state right after revision.
+// The later revisions are undone newest first from the current state, and the revert is recorded
+// as a revision of its own, so it can be reverted in turn. A deleted sample is restored when the
+// revision predates its deletion.
+func (s *SampleService) RevertSample(ctx context.Context, id string, revision int) (model.Sample, error) {
+	var sample model.Sample
+	var reverted bool
+	err := s.write(ctx, func(tx *gorm.DB) error {
+		if err := forUpdate(tx).Unscoped().First(&sample, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSampleNotFound
+			}
+			return err
+		}
+
+		var target model.SampleRevision
+		if err := tx.First(&target, "sample_id = ? AND revision = ?", id, revision).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRevisionNotFound
+			}
+			return err
+		}
+		var later []model.SampleRevision
+		if err := tx.Where("sample_id = ? AND revision > ?", id, revision).Order("revision DESC").Find(&later).Error; err != nil {
+			return err
+		}
+
+		before := sample
+		var labels *string
+		var labelsUndone bool
+		for _, undone := range later {
+			for _, change := range undone.Changes {
+				if change.Field == labelsField {
+					labels, labelsUndone = change.Old, true
+					continue
+				}
+				if err := setSampleField(&sample, change.Field, change.Old); err != nil {
+					return err
+				}
+			}
+		}
+		// Labels live in their own table, they are restored here and recorded next to the fields
+		var changes []model.FieldChange
+		if labelsUndone {
+			current, err := labelsOf(tx, id)
+			if err != nil {
+				return err
+			}
+			restored, err := decodeLabels(labels)
+			if err != nil {
+				return err
+			}
+			if change := labelChange(current, restored); change != nil {
+				if err := replaceLabels(tx, id, restored); err != nil {
+					return err
+				}
+				changes = append(changes, *change)
+			}
+		}
+		if len(changes) == 0 && len(sampleDiff(&before, &sample)) == 0 {
+			// Already in that state
+			return nil
+		}
+		sample.UpdatedAt = time.Now()
+		if err := tx.Unscoped().Save(&sample).Error; err != nil {
+			return err
+		}
+		reverted = true
+		return recordRevision(tx, model.SampleRevision{Action: model.SampleActionRevert, RevertedTo: revision, Changes: changes}, &before, &sample)
+	})
+	if err != nil {
+		return sample, err
+	}
+	if reverted {
+		s.changed(ctx, events.Event{Type: events.SampleUpdated, Payload: sample})
+	}
+	return sample, nil
+}
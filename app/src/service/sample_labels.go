@@ -0,0 +1,220 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/db"
+	"app/events"
+	"app/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var (
+	ErrInvalidLabel    = apperrors.New(apperrors.Validation, "invalid label")
+	ErrInvalidSelector = apperrors.New(apperrors.Validation, "invalid label selector")
+)
+
+const (
+	// SampleLabelsMax bounds the labels of one sample
+	SampleLabelsMax = 64
+	// selectorRequirementsMax bounds the subqueries one selector turns into
+	selectorRequirementsMax = 16
+)
+
+// ValidateLabels checks the names and values like Kubernetes does for object labels
+func ValidateLabels(set map[string]string) error {
+	if len(set) > SampleLabelsMax {
+		return fmt.Errorf("%w: at most %d labels", ErrInvalidLabel, SampleLabelsMax)
+	}
+	for name, value := range set {
+		if errs := validation.IsQualifiedName(name); len(errs) > 0 {
+			return fmt.Errorf("%w: %q: %s", ErrInvalidLabel, name, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("%w: %q=%q: %s", ErrInvalidLabel, name, value, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// SampleLabels returns the labels of a sample
+func (s *SampleService) SampleLabels(ctx context.Context, id string) (map[string]string, error) {
+	if _, err := s.FindSample(ctx, id, "id"); err != nil {
+		return nil, err
+	}
+	return labelsOf(db.DB.WithContext(ctx), id)
+}
+
+// SetSampleLabels replaces every label of a sample with set
+func (s *SampleService) SetSampleLabels(ctx context.Context, id string, set map[string]string) (map[string]string, error) {
+	if err := ValidateLabels(set); err != nil {
+		return nil, err
+	}
+	if set == nil {
+		set = map[string]string{}
+	}
+	var sample model.Sample
+	var changed bool
+	err := s.write(ctx, func(tx *gorm.DB) error {
+		if err := forUpdate(tx).First(&sample, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSampleNotFound
+			}
+			return err
+		}
+		before, err := labelsOf(tx, id)
+		if err != nil {
+			return err
+		}
+		change := labelChange(before, set)
+		if change == nil {
+			return nil
+		}
+		if err := replaceLabels(tx, id, set); err != nil {
+			return err
+		}
+		// updated_at moves so the change feed reports the sample
+		sample.UpdatedAt = time.Now()
+		if err := tx.Model(&sample).Update("updated_at", sample.UpdatedAt).Error; err != nil {
+			return err
+		}
+		changed = true
+		return recordRevision(tx, model.SampleRevision{Action: model.SampleActionUpdate, Changes: []model.FieldChange{*change}}, &sample, &sample)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		s.changed(ctx, events.Event{Type: events.SampleUpdated, Payload: sample})
+	}
+	return set, nil
+}
+
+// labelsField is the history field of the labels, its values are the label set as a JSON object
+const labelsField = "labels"
+
+func labelsOf(tx *gorm.DB, id string) (map[string]string, error) {
+	var rows []model.SampleLabel
+	if err := tx.Where("sample_id = ?", id).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	set := make(map[string]string, len(rows))
+	for _, row := range rows {
+		set[row.Name] = row.Value
+	}
+	return set, nil
+}
+
+func replaceLabels(tx *gorm.DB, id string, set map[string]string) error {
+	if err := tx.Where("sample_id = ?", id).Delete(&model.SampleLabel{}).Error; err != nil {
+		return err
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	rows := make([]model.SampleLabel, 0, len(set))
+	for name, value := range set {
+		rows = append(rows, model.SampleLabel{SampleID: id, Name: name, Value: value})
+	}
+	return tx.Create(&rows).Error
+}
+
+// labelChange is the history change from before to after, nil when they are the same.
+// An empty set is recorded as unset.
+func labelChange(before, after map[string]string) *model.FieldChange {
+	old, current := encodeLabels(before), encodeLabels(after)
+	if old == nil && current == nil || old != nil && current != nil && *old == *current {
+		return nil
+	}
+	return &model.FieldChange{Field: labelsField, Old: old, New: current}
+}
+
+func encodeLabels(set map[string]string) *string {
+	if len(set) == 0 {
+		return nil
+	}
+	// Map keys are marshalled sorted, equal sets encode the same
+	encoded, _ := json.Marshal(set)
+	value := string(encoded)
+	return &value
+}
+
+func decodeLabels(value *string) (map[string]string, error) {
+	set := map[string]string{}
+	if value == nil {
+		return set, nil
+	}
+	if err := json.Unmarshal([]byte(*value), &set); err != nil {
+		return nil, fmt.Errorf("revision holds invalid labels %q: %w", *value, err)
+	}
+	return set, nil
+}
+
+// LabelSelector is a parsed Kubernetes label selector such as env=prod,tier!=cache,zone in (a,b),!canary.
+// The zero value selects every sample.
+type LabelSelector struct {
+	requirements []labels.Requirement
+}
+
+// ParseLabelSelector parses the selector syntax of kubectl -l. Names and values are validated by the parser,
+// the ordering operators gt and lt are rejected because label values are compared as strings here.
+func ParseLabelSelector(raw string) (LabelSelector, error) {
+	requirements, err := labels.ParseToRequirements(raw)
+	if err != nil {
+		return LabelSelector{}, fmt.Errorf("%w: %v", ErrInvalidSelector, err)
+	}
+	if len(requirements) > selectorRequirementsMax {
+		return LabelSelector{}, fmt.Errorf("%w: at most %d requirements", ErrInvalidSelector, selectorRequirementsMax)
+	}
+	for _, r := range requirements {
+		if r.Operator() == selection.GreaterThan || r.Operator() == selection.LessThan {
+			return LabelSelector{}, fmt.Errorf("%w: operator %s is not supported", ErrInvalidSelector, r.Operator())
+		}
+	}
+	return LabelSelector{requirements: requirements}, nil
+}
+
+func (s LabelSelector) Empty() bool {
+	return len(s.requirements) == 0
+}
+
+func (s LabelSelector) String() string {
+	parts := make([]string, len(s.requirements))
+	for i, r := range s.requirements {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Scope restricts a samples query to the samples the selector matches. Every requirement is an
+// EXISTS or NOT EXISTS subquery on sample_labels, names and values are bound as parameters.
+// As in Kubernetes, != and notin also match samples that do not have the label at all.
+func (s LabelSelector) Scope(query *gorm.DB) *gorm.DB {
+	for _, r := range s.requirements {
+		label := db.DB.Model(&model.SampleLabel{}).Select("1").
+			Where("sample_labels.sample_id = samples.id AND sample_labels.name = ?", r.Key())
+		switch r.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+			query = query.Where("EXISTS (?)", label.Where("sample_labels.value IN ?", r.ValuesUnsorted()))
+		case selection.NotEquals, selection.NotIn:
+			query = query.Where("NOT EXISTS (?)", label.Where("sample_labels.value IN ?", r.ValuesUnsorted()))
+		case selection.Exists:
+			query = query.Where("EXISTS (?)", label)
+		case selection.DoesNotExist:
+			query = query.Where("NOT EXISTS (?)", label)
+		default:
+			// Rejected by ParseLabelSelector, match nothing rather than everything
+			query = query.Where("1 = 0")
+		}
+	}
+	return query
+}
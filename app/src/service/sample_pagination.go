@@ -0,0 +1,167 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/db"
+	"app/model"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCursor    = apperrors.New(apperrors.Validation, "invalid cursor")
+	ErrInvalidCountMode = apperrors.New(apperrors.Validation, "invalid count mode, expected exact, estimate or none")
+)
+
+// CountMode is how an offset page totals the samples
+type CountMode string
+
+const (
+	// CountExact runs COUNT(*), which scans the whole index on large tables
+	CountExact CountMode = "exact"
+	// CountEstimate reads the row estimate InnoDB keeps in information_schema.
+	// It is cheap but can be off by a large margin and includes soft-deleted rows.
+	CountEstimate CountMode = "estimate"
+	// CountNone skips the total, clients rely on has_more
+	CountNone CountMode = "none"
+)
+
+func ParseCountMode(raw string) (CountMode, error) {
+	switch mode := CountMode(raw); mode {
+	case CountExact, CountEstimate, CountNone:
+		return mode, nil
+	}
+	return "", ErrInvalidCountMode
+}
+
+// SamplePage is one page of samples, newest first
+type SamplePage struct {
+	Samples []model.Sample
+	HasMore bool
+	// Total is the number of samples, only counted for offset pages and per their CountMode
+	Total int64
+	// NextCursor continues a cursor page after its last sample, empty on the last page
+	NextCursor string
+}
+
+// sampleCursor is the (created_at, id) of the last sample of a page
+type sampleCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"i"`
+}
+
+func encodeCursor(sample model.Sample) string {
+	raw, _ := json.Marshal(sampleCursor{CreatedAt: sample.CreatedAt, ID: sample.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(cursor string) (sampleCursor, error) {
+	var c sampleCursor
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// keysetColumns adds the columns the keyset is built from to a field selection
+func keysetColumns(columns []string) []string {
+	if len(columns) == 0 {
+		return columns
+	}
+	if !slices.Contains(columns, "created_at") {
+		columns = append(columns, "created_at")
+	}
+	return columns
+}
+
+// Scope narrows a samples query, such as LabelSelector.Scope
+type Scope = func(*gorm.DB) *gorm.DB
+
+// SampleOffsetPage skips offset samples, which gets slower the deeper the page
+// and shifts when samples are created between requests. Scopes such as a LabelSelector's
+// narrow the page and an exact total, an estimate always covers the whole table.
+// The first page of all samples with every column, the hottest read, goes through sampleCache.
+func (s *SampleService) SampleOffsetPage(ctx context.Context, offset, limit int, columns []string, count CountMode, scopes ...func(*gorm.DB) *gorm.DB) (SamplePage, error) {
+	if offset != 0 || len(columns) > 0 || len(scopes) > 0 {
+		return s.sampleOffsetPage(ctx, offset, limit, columns, count, scopes...)
+	}
+	v, err := sampleCache.get("page:"+strconv.Itoa(limit)+":"+string(count), func() (any, error) {
+		return s.sampleOffsetPage(context.WithoutCancel(ctx), 0, limit, nil, count)
+	})
+	return v.(SamplePage), err
+}
+
+func (s *SampleService) sampleOffsetPage(ctx context.Context, offset, limit int, columns []string, count CountMode, scopes ...func(*gorm.DB) *gorm.DB) (SamplePage, error) {
+	var page SamplePage
+	var err error
+	switch count {
+	case CountExact:
+		err = db.DB.WithContext(ctx).Model(&model.Sample{}).Scopes(scopes...).Count(&page.Total).Error
+	case CountEstimate:
+		page.Total, err = estimateSamples(ctx)
+	}
+	if err != nil {
+		return page, err
+	}
+
+	// One extra row tells whether there is a next page
+	err = selectColumns(db.DB.WithContext(ctx), columns).
+		Scopes(scopes...).
+		Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit + 1).
+		Find(&page.Samples).Error
+	if err != nil {
+		return page, err
+	}
+	if len(page.Samples) > limit {
+		page.Samples = page.Samples[:limit]
+		page.HasMore = true
+	}
+	return page, nil
+}
+
+// estimateSamples reads the table statistics instead of counting rows
+func estimateSamples(ctx context.Context) (int64, error) {
+	var rows int64
+	err := db.DB.WithContext(ctx).
+		Raw("SELECT COALESCE(TABLE_ROWS, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", db.DB.NamingStrategy.TableName("Sample")).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// SampleCursorPage continues after cursor, the first page when it is empty.
+// The keyset on (created_at, id) reads the index from where the previous page ended,
+// so every page costs the same and inserts never shift the pages.
+func (s *SampleService) SampleCursorPage(ctx context.Context, cursor string, limit int, columns []string, scopes ...func(*gorm.DB) *gorm.DB) (SamplePage, error) {
+	var page SamplePage
+	query := selectColumns(db.DB.WithContext(ctx), keysetColumns(columns)).Scopes(scopes...)
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return page, err
+		}
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", after.CreatedAt, after.CreatedAt, after.ID)
+	}
+
+	err := query.Order("created_at DESC, id DESC").Limit(limit + 1).Find(&page.Samples).Error
+	if err != nil {
+		return page, err
+	}
+	if len(page.Samples) > limit {
+		page.Samples = page.Samples[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(page.Samples[limit-1])
+	}
+	return page, nil
+}
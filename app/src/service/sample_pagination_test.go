@@ -0,0 +1,68 @@
+package service
+
+import (
+	"app/apptest"
+	"app/model"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSampleCursorPageTies(t *testing.T) {
+	apptest.DB(t)
+	// Every sample shares created_at, so only the id orders them
+	at := time.Now().Truncate(time.Millisecond)
+	for range 7 {
+		apptest.Seed(t, &model.Sample{Message: "tie", CreatedAt: at, UpdatedAt: at})
+	}
+
+	s := SampleService{}
+	seen := map[string]bool{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 7 {
+			t.Fatal("pagination does not end")
+		}
+		page, err := s.SampleCursorPage(context.Background(), cursor, 3, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sample := range page.Samples {
+			if seen[sample.ID] {
+				t.Fatalf("sample %s returned twice", sample.ID)
+			}
+			seen[sample.ID] = true
+		}
+		if !page.HasMore {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 7 {
+		t.Errorf("saw %d samples, want 7", len(seen))
+	}
+}
+
+func TestSampleColumns(t *testing.T) {
+	tests := []struct {
+		fields []string
+		want   []string
+		err    error
+	}{
+		{nil, nil, nil},
+		{[]string{"message"}, []string{"id", "message"}, nil},
+		{[]string{"id", "created_at"}, []string{"id", "created_at"}, nil},
+		{[]string{"name"}, nil, ErrUnknownSampleField},
+	}
+	for _, tt := range tests {
+		got, err := SampleColumns(tt.fields)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("SampleColumns(%v) error = %v, want %v", tt.fields, err, tt.err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("SampleColumns(%v) = %v, want %v", tt.fields, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/events"
+	"app/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPatch       = apperrors.New(apperrors.Validation, "invalid patch")
+	ErrPatchTestFailed    = apperrors.New(apperrors.Conflict, "patch test operation failed")
+	ErrInvalidSampleKey   = apperrors.New(apperrors.Validation, "invalid sample key")
+	ErrSampleMessageEmpty = apperrors.New(apperrors.Validation, "message is required")
+)
+
+// SampleKeyMaxLength is the longest natural key, the column is varchar(64)
+const SampleKeyMaxLength = 64
+
+// ValidSampleKey reports whether key may be used as a natural key
+func ValidSampleKey(key string) bool {
+	if key == "" || len(key) > SampleKeyMaxLength {
+		return false
+	}
+	for _, r := range key {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("._:-", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+type PatchFormat string
+
+const (
+	// MergePatch is an RFC 7386 JSON Merge Patch, null removes a field
+	MergePatch PatchFormat = "merge"
+	// JSONPatch is an RFC 6902 list of operations
+	JSONPatch PatchFormat = "json"
+)
+
+// samplePatchDocument is the part of a Sample a patch may change, the paths a JSON Patch addresses
+type samplePatchDocument struct {
+	Message string  `json:"message"`
+	Key     *string `json:"key,omitempty"`
+}
+
+// applyPatch applies the patch to the document and decodes the result, rejecting fields that cannot be patched
+func applyPatch(doc samplePatchDocument, format PatchFormat, patch []byte) (samplePatchDocument, error) {
+	original, err := json.Marshal(doc)
+	if err != nil {
+		return doc, err
+	}
+
+	var patched []byte
+	switch format {
+	case MergePatch:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(patch, &fields); err != nil {
+			return doc, fmt.Errorf("%w: a merge patch must be a JSON object", ErrInvalidPatch)
+		}
+		patched, err = jsonpatch.MergePatch(original, patch)
+	case JSONPatch:
+		var operations jsonpatch.Patch
+		if operations, err = jsonpatch.DecodePatch(patch); err == nil {
+			patched, err = operations.Apply(original)
+		}
+	default:
+		return doc, fmt.Errorf("%w: unknown format %q", ErrInvalidPatch, format)
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return doc, ErrPatchTestFailed
+	}
+	if err != nil {
+		return doc, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	var result samplePatchDocument
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return doc, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	if result.Message == "" {
+		return doc, ErrSampleMessageEmpty
+	}
+	if result.Key != nil && !ValidSampleKey(*result.Key) {
+		return doc, ErrInvalidSampleKey
+	}
+	return result, nil
+}
+
+// PatchSample applies a merge patch or JSON Patch to the sample's message and key. Only the fields
+// the patch touches change, the result is validated like a full update before it is stored.
+func (s *SampleService) PatchSample(ctx context.Context, id string, format PatchFormat, patch []byte) (model.Sample, error) {
+	var sample model.Sample
+	err := s.write(ctx, func(tx *gorm.DB) error {
+		// Locked, so a JSON Patch test op still holds when the patched sample is stored
+		if err := forUpdate(tx).First(&sample, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSampleNotFound
+			}
+			return err
+		}
+		doc, err := applyPatch(samplePatchDocument{Message: sample.Message, Key: sample.Key}, format, patch)
+		if err != nil {
+			return err
+		}
+		before := sample
+		sample.Message, sample.Key = doc.Message, doc.Key
+		if err := tx.Save(&sample).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, model.SampleRevision{Action: model.SampleActionUpdate}, &before, &sample)
+	})
+	if err != nil {
+		return sample, err
+	}
+	s.changed(ctx, events.Event{Type: events.SampleUpdated, Payload: sample})
+	return sample, nil
+}
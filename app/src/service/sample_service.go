@@ -1,14 +1,160 @@
 package service
 
 import (
+	"app/apperrors"
 	"app/db"
+	"app/events"
+	"app/filter"
 	"app/model"
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSampleNotFound     = apperrors.New(apperrors.NotFound, "sample not found")
+	ErrUnknownSampleField = apperrors.New(apperrors.Validation, "unknown sample field")
+	// ErrSchemaNotMigrated is a feature whose columns the database does not have yet, see db.SchemaCompat
+	ErrSchemaNotMigrated = apperrors.New(apperrors.Dependency, "the database schema is not migrated to this version yet")
 )
 
-type SampleService struct{}
+// sampleFields maps the JSON field names clients may select to their columns
+var sampleFields = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"deleted_at": "deleted_at",
+	"message":    "message",
+	"key":        "natural_key",
+}
+
+// sampleFilterSchema is what ?filter= expressions on samples may compare
+var sampleFilterSchema = filter.Schema{
+	"id":         {Column: "id", Type: filter.String},
+	"message":    {Column: "message", Type: filter.String},
+	"key":        {Column: "natural_key", Type: filter.String},
+	"created_at": {Column: "created_at", Type: filter.Time},
+	"updated_at": {Column: "updated_at", Type: filter.Time},
+}
+
+// ParseSampleFilter parses a filter expression like message ~ "hello" AND created_at >= 2024-01-01
+func ParseSampleFilter(raw string) (filter.Filter, error) {
+	return filter.Parse(raw, sampleFilterSchema)
+}
+
+// SampleColumns resolves selected JSON field names to the columns to SELECT.
+// The id is always selected because links are built from it. No fields selects every column.
+func SampleColumns(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	columns := []string{"id"}
+	for _, field := range fields {
+		column, ok := sampleFields[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSampleField, field)
+		}
+		if column != "id" {
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
+
+// SampleFieldNames lists the selectable fields, for error messages
+func SampleFieldNames() []string {
+	names := make([]string, 0, len(sampleFields))
+	for name := range sampleFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectColumns limits the query to columns, all columns when empty
+func selectColumns(query *gorm.DB, columns []string) *gorm.DB {
+	if len(columns) == 0 {
+		return query
+	}
+	return query.Select(columns)
+}
 
-func (s *SampleService) GetSample() (model.Sample, error) {
+type SampleService struct {
+	// DryRun rolls back every write and publishes nothing, writes return what they would have stored
+	DryRun bool
+}
+
+// errDryRun aborts the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// write runs fn in a transaction, rolled back instead of committed on a dry run
+func (s *SampleService) write(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	var err error
+	// A deadlock rolls the whole transaction back, so fn runs again from the start
+	for attempt := 1; ; attempt++ {
+		err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := fn(tx); err != nil {
+				return err
+			}
+			if s.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if attempt == sampleWriteAttempts || apperrors.KindOf(err) != apperrors.Retryable {
+			break
+		}
+		slog.WarnContext(ctx, "sample write deadlocked, retrying", "attempt", attempt, "error", err)
+	}
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// Most times a write is run when it deadlocks with another
+const sampleWriteAttempts = 3
+
+// forUpdate locks the rows a write reads until its transaction ends, so concurrent writes to one sample
+// take turns and each numbers its revision after the last one
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// changed publishes the events and wakes change feed waiters, unless this is a dry run
+func (s *SampleService) changed(ctx context.Context, published ...events.Event) {
+	if s.DryRun {
+		return
+	}
+	for _, event := range published {
+		events.Publish(ctx, event)
+	}
+	notifySampleChange()
+}
+
+// sampleReads collapses identical concurrent reads into one query, so a burst of
+// requests for the same data does not turn into a burst of identical queries.
+// Callers share the returned value and must not modify it.
+var sampleReads singleflight.Group
+
+// GetSample returns the first sample, reading only columns when given
+func (s *SampleService) GetSample(columns ...string) (model.Sample, error) {
+	v, err, _ := sampleReads.Do("get:"+strings.Join(columns, ","), func() (any, error) {
+		return s.getSample(columns)
+	})
+	return v.(model.Sample), err
+}
+
+func (s *SampleService) getSample(columns []string) (model.Sample, error) {
 	var sample model.Sample
 
 	// Ensure there is at least one record
@@ -23,14 +169,164 @@ func (s *SampleService) GetSample() (model.Sample, error) {
 		}
 	}
 
-	result := db.DB.First(&sample)
+	result := selectColumns(db.DB, columns).First(&sample)
 	return sample, result.Error
 }
 
-func (s *SampleService) CreateSample(message string) (model.Sample, error) {
+// ListSamples returns the newest samples first
+func (s *SampleService) ListSamples(ctx context.Context, limit int) ([]model.Sample, error) {
+	v, err := sampleCache.get("list:"+strconv.Itoa(limit), func() (any, error) {
+		var samples []model.Sample
+		result := db.DB.WithContext(context.WithoutCancel(ctx)).Order("created_at DESC").Limit(limit).Find(&samples)
+		return samples, result.Error
+	})
+	return v.([]model.Sample), err
+}
+
+func (s *SampleService) CreateSample(ctx context.Context, message string) (model.Sample, error) {
 	sample := model.Sample{
 		Message: message,
 	}
-	result := db.DB.Create(&sample)
-	return sample, result.Error
-}
\ No newline at end of file
+	err := s.write(ctx, func(tx *gorm.DB) error {
+		if err := tx.Create(&sample).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, model.SampleRevision{Action: model.SampleActionCreate}, nil, &sample)
+	})
+	if err != nil {
+		return sample, err
+	}
+
+	s.changed(ctx, events.Event{Type: events.SampleCreated, Payload: sample})
+	return sample, nil
+}
+
+// UpsertSample stores message under the natural key: the sample holding the key is updated, or a new
+// one is created when none does, which created reports. A deleted sample holding the key is restored.
+func (s *SampleService) UpsertSample(ctx context.Context, key, message string) (model.Sample, bool, error) {
+	var sample model.Sample
+	var created bool
+	err := s.write(ctx, func(tx *gorm.DB) error {
+		if !db.HasColumn(tx, &model.Sample{}, "natural_key") {
+			return ErrSchemaNotMigrated
+		}
+		var before *model.Sample
+		var existing model.Sample
+		err := forUpdate(tx).Unscoped().First(&existing, "natural_key = ?", key).Error
+		switch {
+		case err == nil:
+			before = &existing
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		now := time.Now()
+		candidate := model.Sample{Key: &key, Message: message, CreatedAt: now, UpdatedAt: now}
+		// INSERT ... ON DUPLICATE KEY UPDATE. Two clients upserting a new key both hold the gap lock of the
+		// read above and deadlock on their inserts, write runs the transaction MySQL rolled back again.
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "natural_key"}},
+			DoUpdates: clause.Assignments(map[string]any{"message": message, "updated_at": now, "deleted_at": nil}),
+		}).Create(&candidate).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&sample, "natural_key = ?", key).Error; err != nil {
+			return err
+		}
+		// An update leaves the row with its own id, not the one generated for the insert
+		created = sample.ID == candidate.ID
+		if created {
+			return recordRevision(tx, model.SampleRevision{Action: model.SampleActionCreate}, nil, &sample)
+		}
+		return recordRevision(tx, model.SampleRevision{Action: model.SampleActionUpdate}, before, &sample)
+	})
+	if err != nil {
+		return sample, false, err
+	}
+	if created {
+		s.changed(ctx, events.Event{Type: events.SampleCreated, Payload: sample})
+	} else {
+		s.changed(ctx, events.Event{Type: events.SampleUpdated, Payload: sample})
+	}
+	return sample, created, nil
+}
+
+func (s *SampleService) FindSample(ctx context.Context, id string, columns ...string) (model.Sample, error) {
+	var sample model.Sample
+	err := selectColumns(db.DB.WithContext(ctx), columns).First(&sample, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sample, ErrSampleNotFound
+	}
+	return sample, err
+}
+
+// FindSamples returns the samples with the given ids that exist, in no particular order
+func (s *SampleService) FindSamples(ctx context.Context, ids []string, columns ...string) ([]model.Sample, error) {
+	var samples []model.Sample
+	err := selectColumns(db.DB.WithContext(ctx), columns).Where("id IN ?", ids).Find(&samples).Error
+	return samples, err
+}
+
+func (s *SampleService) UpdateSample(ctx context.Context, id string, message string) (model.Sample, error) {
+	var sample model.Sample
+	err := s.write(ctx, func(tx *gorm.DB) error {
+		if err := forUpdate(tx).First(&sample, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSampleNotFound
+			}
+			return err
+		}
+		before := sample
+		sample.Message = message
+		if err := tx.Save(&sample).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, model.SampleRevision{Action: model.SampleActionUpdate}, &before, &sample)
+	})
+	if err != nil {
+		return sample, err
+	}
+	s.changed(ctx, events.Event{Type: events.SampleUpdated, Payload: sample})
+	return sample, nil
+}
+
+// DeleteSample soft deletes, the row is purged later by the retention job.
+// updated_at is bumped with deleted_at so the deletion shows up in the change feed.
+func (s *SampleService) DeleteSample(ctx context.Context, id string) error {
+	now := time.Now()
+	var sample model.Sample
+	err := s.write(ctx, func(tx *gorm.DB) error {
+		if err := forUpdate(tx).First(&sample, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSampleNotFound
+			}
+			return err
+		}
+		before := sample
+		err := tx.Model(&model.Sample{}).Where("id = ?", id).
+			Updates(map[string]any{"deleted_at": now, "updated_at": now}).Error
+		if err != nil {
+			return err
+		}
+		sample.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		return recordRevision(tx, model.SampleRevision{Action: model.SampleActionDelete}, &before, &sample)
+	})
+	if err != nil {
+		return err
+	}
+	s.changed(ctx, events.Event{Type: events.SampleDeleted, Payload: sample})
+	return nil
+}
+
+// sampleBatchSize bounds how many rows are held in memory while iterating the whole table
+const sampleBatchSize = 500
+
+// EachSample calls fn with every Sample in batches, in primary key order, reading only columns when given.
+// FindInBatches pages by primary key, so no other ordering can be applied.
+func EachSample(ctx context.Context, columns []string, fn func(batch []model.Sample) error) error {
+	var batch []model.Sample
+	return selectColumns(db.DB.WithContext(ctx), columns).FindInBatches(&batch, sampleBatchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
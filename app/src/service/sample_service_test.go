@@ -0,0 +1,44 @@
+package service
+
+import (
+	"app/apptest"
+	"app/db"
+	"app/model"
+	"context"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestWriteRetriesDeadlocks(t *testing.T) {
+	apptest.DB(t)
+	s := SampleService{}
+	deadlock := db.TranslateError(&mysqldriver.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"})
+
+	attempts := 0
+	err := s.write(context.Background(), func(tx *gorm.DB) error {
+		attempts++
+		if attempts == 1 {
+			return deadlock
+		}
+		return tx.Create(&model.Sample{Message: "retried"}).Error
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("write = %v after %d attempts, want it run again after the deadlock", err, attempts)
+	}
+	var count int64
+	db.DB.Model(&model.Sample{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d samples stored, want the retried one alone", count)
+	}
+
+	attempts = 0
+	err = s.write(context.Background(), func(*gorm.DB) error {
+		attempts++
+		return deadlock
+	})
+	if err == nil || attempts != sampleWriteAttempts {
+		t.Errorf("write = %v after %d attempts, want the deadlock after %d", err, attempts, sampleWriteAttempts)
+	}
+}
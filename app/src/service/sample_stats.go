@@ -0,0 +1,230 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/db"
+	"app/events"
+	"app/model"
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type sampleStatKey struct {
+	day string
+	tag string
+}
+
+type sampleStatDelta struct {
+	created, updated, deleted int64
+}
+
+// SampleStats keeps the sample_stats read model from Sample events. Events only add to counters in
+// memory, Flush writes them as one increment per day and tag, so however many writes a burst brings
+// the table sees a handful of upserts per flush. Counts lag the samples by up to one flush interval.
+type SampleStats struct {
+	mu      sync.Mutex
+	pending map[sampleStatKey]sampleStatDelta
+}
+
+func NewSampleStats() *SampleStats {
+	return &SampleStats{pending: map[sampleStatKey]sampleStatDelta{}}
+}
+
+// Subscribe counts every Sample event published from now on
+func (s *SampleStats) Subscribe() {
+	for _, eventType := range []string{events.SampleCreated, events.SampleUpdated, events.SampleDeleted} {
+		events.Subscribe(eventType, s.handle)
+	}
+}
+
+func (s *SampleStats) handle(ctx context.Context, event events.Event) {
+	sample, ok := event.Payload.(model.Sample)
+	if !ok {
+		return
+	}
+	var rows []model.SampleLabel
+	if err := db.DB.WithContext(ctx).Where("sample_id = ?", sample.ID).Find(&rows).Error; err != nil {
+		// Still counted in the totals
+		slog.Warn("failed to read sample labels for stats", "sample_id", sample.ID, "error", err)
+	}
+	tags := []string{""}
+	for _, row := range rows {
+		tags = append(tags, row.Name+"="+row.Value)
+	}
+
+	var delta sampleStatDelta
+	at := sample.UpdatedAt
+	switch event.Type {
+	case events.SampleCreated:
+		delta.created, at = 1, sample.CreatedAt
+	case events.SampleUpdated:
+		delta.updated = 1
+	case events.SampleDeleted:
+		delta.deleted, at = 1, sample.DeletedAt.Time
+	}
+	s.add(at.UTC().Format(time.DateOnly), tags, delta)
+}
+
+func (s *SampleStats) add(day string, tags []string, delta sampleStatDelta) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		key := sampleStatKey{day: day, tag: tag}
+		total := s.pending[key]
+		total.created += delta.created
+		total.updated += delta.updated
+		total.deleted += delta.deleted
+		s.pending[key] = total
+	}
+}
+
+// Flush writes the counted events in one transaction. When it fails they are kept for the next flush.
+func (s *SampleStats) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[sampleStatKey]sampleStatDelta{}
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for key, delta := range pending {
+			// INSERT ... ON DUPLICATE KEY UPDATE created = created + ?, replicas flushing at once add up
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "day"}, {Name: "tag"}},
+				DoUpdates: clause.Assignments(map[string]any{
+					"created": gorm.Expr("created + ?", delta.created),
+					"updated": gorm.Expr("updated + ?", delta.updated),
+					"deleted": gorm.Expr("deleted + ?", delta.deleted),
+				}),
+			}).Create(&model.SampleStat{Day: key.day, Tag: key.tag, Created: delta.created, Updated: delta.updated, Deleted: delta.deleted}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for key, delta := range pending {
+			s.add(key.day, []string{key.tag}, delta)
+		}
+	} else {
+		sampleCache.clear()
+	}
+	return err
+}
+
+// Stats returns the counters of the last days up to today, newest day first. An empty tag
+// returns the totals, a name=value tag the counters of the samples labelled with it.
+func (s *SampleService) Stats(ctx context.Context, days int, tag string) ([]model.SampleStat, error) {
+	since := time.Now().UTC().AddDate(0, 0, 1-days).Format(time.DateOnly)
+	v, err := sampleCache.get("stats:"+since+":"+tag, func() (any, error) {
+		stats := []model.SampleStat{}
+		// The first caller's cancellation must not fail the ones sharing its read
+		err := db.DB.WithContext(context.WithoutCancel(ctx)).
+			Where("tag = ? AND day >= ?", tag, since).
+			Order("day DESC").
+			Find(&stats).Error
+		return stats, err
+	})
+	return v.([]model.SampleStat), err
+}
+
+var ErrInvalidTimeseries = apperrors.New(apperrors.Validation, "invalid timeseries")
+
+// Bounds of a timeseries, so one request never groups more than sampleTimeseriesMaxBuckets buckets
+const (
+	sampleTimeseriesMinWindow  = time.Minute
+	sampleTimeseriesMaxBuckets = 1000
+)
+
+// sampleTimeseriesColumns are the events a timeseries counts, by the column holding their time
+var sampleTimeseriesColumns = map[string]string{
+	"created": "created_at",
+	"updated": "updated_at",
+	"deleted": "deleted_at",
+}
+
+// TimeBucket is the number of events from Start for one window
+type TimeBucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// ParseSpan parses a duration like time.ParseDuration, also accepting whole days and weeks such as 7d or 2w
+func ParseSpan(raw string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(raw, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", raw)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(raw)
+}
+
+// bucketExpression is the SQL numbering the window a timestamp column falls into, counted from the Unix epoch
+// MySQL reads the DATETIME in the session time zone, which is the zone the DSN writes times in as long as
+// the server and the pods share it, UTC in the usual cluster.
+func bucketExpression(column string) string {
+	if db.DB.Dialector.Name() == "sqlite" {
+		return "CAST(strftime('%s', " + column + ") AS INTEGER) / ?"
+	}
+	return "UNIX_TIMESTAMP(" + column + ") DIV ?"
+}
+
+// SampleTimeseries counts the samples created, updated or deleted per window over the last span.
+// Buckets start at multiples of window since the Unix epoch and empty ones are returned with a zero count,
+// so the series can be plotted as is. The aggregation runs in the database, only the buckets are read.
+func (s *SampleService) SampleTimeseries(ctx context.Context, event string, window, span time.Duration) ([]TimeBucket, error) {
+	column, ok := sampleTimeseriesColumns[event]
+	if !ok {
+		return nil, fmt.Errorf("%w: event must be created, updated or deleted", ErrInvalidTimeseries)
+	}
+	if window < sampleTimeseriesMinWindow || window%time.Second != 0 {
+		return nil, fmt.Errorf("%w: window must be whole seconds of at least %s", ErrInvalidTimeseries, sampleTimeseriesMinWindow)
+	}
+	if span < window || span/window > sampleTimeseriesMaxBuckets {
+		return nil, fmt.Errorf("%w: range must hold 1 to %d windows", ErrInvalidTimeseries, sampleTimeseriesMaxBuckets)
+	}
+
+	seconds := int64(window / time.Second)
+	last := time.Now().Unix() / seconds
+	first := last - int64(span/window) + 1
+	since := time.Unix(first*seconds, 0)
+
+	var rows []struct {
+		Bucket int64
+		Count  int64
+	}
+	// Unscoped, samples deleted since were still created or updated back then
+	err := db.DB.WithContext(ctx).Unscoped().Model(&model.Sample{}).
+		Select(bucketExpression(column)+" AS bucket, COUNT(*) AS count", seconds).
+		Where(column+" >= ?", since).
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Bucket] = row.Count
+	}
+	buckets := make([]TimeBucket, 0, last-first+1)
+	for n := first; n <= last; n++ {
+		buckets = append(buckets, TimeBucket{Start: time.Unix(n*seconds, 0).UTC(), Count: counts[n]})
+	}
+	return buckets, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"app/apptest"
+	"app/events"
+	"app/model"
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestSampleStats(t *testing.T) {
+	apptest.DB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	today := now.Format(time.DateOnly)
+
+	labelled := model.Sample{Message: "labelled", CreatedAt: now, UpdatedAt: now}
+	plain := model.Sample{Message: "plain", CreatedAt: now, UpdatedAt: now}
+	apptest.Seed(t, &labelled, &plain)
+	apptest.Seed(t, &model.SampleLabel{SampleID: labelled.ID, Name: "env", Value: "prod"})
+
+	stats := NewSampleStats()
+	stats.handle(ctx, events.Event{Type: events.SampleCreated, Payload: labelled})
+	stats.handle(ctx, events.Event{Type: events.SampleCreated, Payload: plain})
+	stats.handle(ctx, events.Event{Type: events.SampleUpdated, Payload: labelled})
+	if err := stats.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// A second flush adds to the stored counters
+	deleted := plain
+	deleted.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	stats.handle(ctx, events.Event{Type: events.SampleDeleted, Payload: deleted})
+	stats.handle(ctx, events.Event{Type: events.SampleUpdated, Payload: labelled})
+	if err := stats.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	s := SampleService{}
+	for _, tt := range []struct {
+		tag  string
+		want model.SampleStat
+	}{
+		{"", model.SampleStat{Day: today, Created: 2, Updated: 2, Deleted: 1}},
+		{"env=prod", model.SampleStat{Day: today, Tag: "env=prod", Created: 1, Updated: 2}},
+	} {
+		got, err := s.Stats(ctx, 7, tt.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("Stats(%q) = %+v, want %+v", tt.tag, got, tt.want)
+		}
+	}
+}
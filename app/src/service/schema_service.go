@@ -0,0 +1,14 @@
+package service
+
+import (
+	"app/db"
+	"app/model"
+	"context"
+)
+
+type SchemaService struct{}
+
+// Drift compares the live schema with the models this version migrates to
+func (s *SchemaService) Drift(ctx context.Context) (db.DriftReport, error) {
+	return db.Drift(db.DB.WithContext(ctx), model.All...)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"app/db"
+	"app/model"
+	"app/redisdb"
+	"app/storage"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SelfTestStep struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type SelfTestReport struct {
+	Status string         `json:"status"`
+	Steps  []SelfTestStep `json:"steps"`
+}
+
+type SelfTestService struct{}
+
+// Run performs write/read/delete round trips against every configured backend
+// and keeps going after a failure so the report shows everything that is broken.
+func (s *SelfTestService) Run(ctx context.Context) SelfTestReport {
+	report := SelfTestReport{Status: "pass"}
+	canary := "selftest-" + uuid.New().String()
+
+	step := func(name string, fn func() error) {
+		started := time.Now()
+		err := fn()
+		result := SelfTestStep{
+			Name:      name,
+			Status:    "pass",
+			LatencyMs: float64(time.Since(started).Microseconds()) / 1000,
+		}
+		if err != nil {
+			result.Status = "fail"
+			result.Error = err.Error()
+			report.Status = "fail"
+		}
+		report.Steps = append(report.Steps, result)
+	}
+
+	// Database, bypassing SampleService so no notifications are triggered
+	sample := model.Sample{Message: canary}
+	step("database.insert", func() error {
+		return db.DB.WithContext(ctx).Create(&sample).Error
+	})
+	step("database.read", func() error {
+		var found model.Sample
+		if err := db.DB.WithContext(ctx).First(&found, "id = ?", sample.ID).Error; err != nil {
+			return err
+		}
+		if found.Message != canary {
+			return fmt.Errorf("read back %q, expected %q", found.Message, canary)
+		}
+		return nil
+	})
+	step("database.delete", func() error {
+		return db.DB.WithContext(ctx).Unscoped().Delete(&model.Sample{}, "id = ?", sample.ID).Error
+	})
+
+	if redisdb.Client != nil {
+		key := "selftest:" + canary
+		step("redis.set", func() error {
+			return redisdb.Client.Set(ctx, key, canary, time.Minute).Err()
+		})
+		step("redis.get", func() error {
+			value, err := redisdb.Client.Get(ctx, key).Result()
+			if err == nil && value != canary {
+				err = fmt.Errorf("read back %q, expected %q", value, canary)
+			}
+			return err
+		})
+		step("redis.delete", func() error {
+			return redisdb.Client.Del(ctx, key).Err()
+		})
+	}
+
+	if storage.Default != nil {
+		key := "selftest/" + canary
+		step("storage.put", func() error {
+			return storage.Default.Put(ctx, key, strings.NewReader(canary), int64(len(canary)), "text/plain")
+		})
+		step("storage.get", func() error {
+			reader, _, err := storage.Default.Get(ctx, key)
+			if err != nil {
+				return err
+			}
+			defer reader.Close()
+			body, err := io.ReadAll(reader)
+			if err == nil && string(body) != canary {
+				err = fmt.Errorf("read back %q, expected %q", body, canary)
+			}
+			return err
+		})
+		step("storage.delete", func() error {
+			return storage.Default.Delete(ctx, key)
+		})
+	}
+
+	return report
+}
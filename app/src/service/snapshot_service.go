@@ -0,0 +1,98 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/model"
+	"app/runtimeutil"
+	"app/storage"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const snapshotPrefix = "snapshots/samples/"
+
+var ErrInvalidSnapshotName = apperrors.New(apperrors.NotFound, "invalid snapshot name")
+
+// errWriterPanicked closes the pipe of a writer goroutine that panicked before it finished
+var errWriterPanicked = errors.New("pipe writer panicked")
+
+type SnapshotService struct{}
+
+// Create writes every Sample as gzipped newline-delimited JSON into object storage.
+// Rows are read in batches so memory use does not grow with the table.
+func (s *SnapshotService) Create(ctx context.Context) (storage.Object, error) {
+	key := fmt.Sprintf("%ssamples-%s.ndjson.gz", snapshotPrefix, time.Now().UTC().Format("20060102T150405Z"))
+
+	reader, writer := io.Pipe()
+	runtimeutil.Go("snapshot writer", func() {
+		// A panic still closes the pipe, so the upload fails instead of waiting for more
+		err := errWriterPanicked
+		defer func() { writer.CloseWithError(err) }()
+		gz := gzip.NewWriter(writer)
+		encoder := json.NewEncoder(gz)
+
+		err = EachSample(ctx, nil, func(batch []model.Sample) error {
+			for i := range batch {
+				if err := encoder.Encode(&batch[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+	})
+
+	if err := storage.Default.Put(ctx, key, reader, -1, "application/gzip"); err != nil {
+		reader.CloseWithError(err)
+		return storage.Object{}, fmt.Errorf("snapshot failed: %w", err)
+	}
+
+	r, obj, err := storage.Default.Get(ctx, key)
+	if err != nil {
+		return storage.Object{}, err
+	}
+	r.Close()
+	slog.Info("sample snapshot created", "key", key, "size", obj.Size)
+	return obj, nil
+}
+
+func (s *SnapshotService) List(ctx context.Context) ([]storage.Object, error) {
+	return storage.Default.List(ctx, snapshotPrefix)
+}
+
+// Open returns the snapshot by its file name, without the storage prefix
+func (s *SnapshotService) Open(ctx context.Context, name string) (io.ReadCloser, storage.Object, error) {
+	if name == "" || path.Base(name) != name || !strings.HasPrefix(name, "samples-") {
+		return nil, storage.Object{}, ErrInvalidSnapshotName
+	}
+	return storage.Default.Get(ctx, snapshotPrefix+name)
+}
+
+// Prune deletes the oldest snapshots beyond keep
+func (s *SnapshotService) Prune(ctx context.Context, keep int) error {
+	snapshots, err := s.List(ctx)
+	if err != nil || keep <= 0 || len(snapshots) <= keep {
+		return err
+	}
+
+	// Keys embed a sortable timestamp
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Key < snapshots[j].Key })
+	for _, obj := range snapshots[:len(snapshots)-keep] {
+		if err := storage.Default.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
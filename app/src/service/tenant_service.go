@@ -0,0 +1,259 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/config"
+	"app/db"
+	"app/model"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTenantRateLimited   = errors.New("tenant rate limit exceeded")
+	ErrTenantQuotaExceeded = errors.New("tenant daily quota exceeded")
+	ErrUnknownTenant       = errors.New("unknown tenant")
+	ErrTenantLimitNotFound = apperrors.New(apperrors.NotFound, "tenant limit not found")
+)
+
+// TenantService enforces a rate limit and a daily quota per tenant stored as a TenantLimit. The
+// tenant comes from the client, so one without a TenantLimit is unknown and rejected, and requests
+// naming no tenant share the defaults as if they were one tenant. The rate limit is a token bucket
+// of a minute of requests kept by each replica, so N replicas let up to N times the limit through.
+// The daily quota is counted in the database and shared, only for tenants that have one.
+type TenantService struct {
+	// Defaults for the requests without a tenant, zero is unlimited
+	DefaultRequestsPerMinute int
+	DefaultDailyQuota        int64
+	// CacheTTL is how long a replica uses the stored limits before reading them again
+	CacheTTL time.Duration
+
+	mu       sync.Mutex
+	limits   map[string]model.TenantLimit
+	loadedAt time.Time
+	tenants  map[string]*tenantState
+}
+
+type tenantState struct {
+	limit   model.TenantLimit
+	limiter *rate.Limiter
+}
+
+// TenantDecision is what Allow decided for a request
+type TenantDecision struct {
+	Limit model.TenantLimit
+	// Quota is the daily quota after the request, with a zero Limit when the tenant has none
+	Quota Quota
+	// RetryAfter is when a rejected request can be sent again
+	RetryAfter time.Duration
+}
+
+func NewTenantServiceFromEnv() *TenantService {
+	return &TenantService{
+		DefaultRequestsPerMinute: config.Int("TENANT_RATE_LIMIT_PER_MINUTE", 0),
+		DefaultDailyQuota:        int64(config.Int("TENANT_DAILY_QUOTA", 0)),
+		CacheTTL:                 config.Duration("TENANT_LIMIT_CACHE_TTL", 30*time.Second),
+	}
+}
+
+// Allow counts one request of the tenant, "" for none, against its rate limit and daily quota. A
+// rejected request returns ErrTenantRateLimited, ErrTenantQuotaExceeded or ErrUnknownTenant and
+// is not counted against the quota.
+func (s *TenantService) Allow(ctx context.Context, tenant string, now time.Time) (TenantDecision, error) {
+	state, ok := s.state(ctx, tenant, now)
+	if !ok {
+		return TenantDecision{}, ErrUnknownTenant
+	}
+	decision := TenantDecision{Limit: state.limit}
+
+	if state.limiter != nil {
+		reservation := state.limiter.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			decision.RetryAfter = delay
+			return decision, ErrTenantRateLimited
+		}
+	}
+	if state.limit.DailyQuota <= 0 {
+		return decision, nil
+	}
+
+	window := tenantDay(state.limit, now)
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The upsert locks the usage row like the api key quotas do
+		usage := model.TenantUsage{Tenant: tenant, Day: window.period, Requests: 1, UpdatedAt: now}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]any{"requests": gorm.Expr("requests + 1"), "updated_at": now}),
+		}).Create(&usage).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&usage, "tenant = ? AND day = ?", tenant, window.period).Error; err != nil {
+			return err
+		}
+		if usage.Requests > window.limit {
+			decision.Quota = window.quota(window.limit)
+			decision.RetryAfter = window.reset.Sub(now)
+			return ErrTenantQuotaExceeded
+		}
+		decision.Quota = window.quota(usage.Requests)
+		return nil
+	})
+	return decision, err
+}
+
+func tenantDay(limit model.TenantLimit, now time.Time) quotaWindow {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return quotaWindow{name: "day", period: day.Format(time.DateOnly), limit: limit.DailyQuota, reset: day.AddDate(0, 0, 1)}
+}
+
+// state returns the limits and rate limiter of the tenant, false when it has no TenantLimit. Only
+// stored tenants are tracked, so made up ones neither grow the map nor query the database.
+func (s *TenantService) state(ctx context.Context, tenant string, now time.Time) (*tenantState, bool) {
+	s.load(ctx, now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit, ok := s.limits[tenant]
+	switch {
+	case tenant == "":
+		limit, ok = model.TenantLimit{RequestsPerMinute: s.DefaultRequestsPerMinute, DailyQuota: s.DefaultDailyQuota}, true
+	case s.limits == nil:
+		// The limits were never read, the tenant is let through on the defaults until they are
+		limit, ok = model.TenantLimit{Tenant: tenant, RequestsPerMinute: s.DefaultRequestsPerMinute, DailyQuota: s.DefaultDailyQuota}, true
+		tenant = ""
+	}
+	if !ok {
+		return nil, false
+	}
+
+	if s.tenants == nil {
+		s.tenants = map[string]*tenantState{}
+	}
+	state, ok := s.tenants[tenant]
+	if !ok {
+		state = &tenantState{}
+		s.tenants[tenant] = state
+	}
+	if state.limiter == nil || state.limit.RequestsPerMinute != limit.RequestsPerMinute {
+		state.limiter = nil
+		if limit.RequestsPerMinute > 0 {
+			state.limiter = rate.NewLimiter(rate.Limit(float64(limit.RequestsPerMinute)/60), limit.RequestsPerMinute)
+		}
+	}
+	state.limit = limit
+	return state, true
+}
+
+// load reads every stored limit again once CacheTTL passed, dropping the rate limiters of the
+// tenants that no longer have one. When they cannot be read the previous ones apply until the
+// next attempt.
+func (s *TenantService) load(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	fresh := s.limits != nil && now.Sub(s.loadedAt) < s.CacheTTL
+	s.mu.Unlock()
+	if fresh {
+		return
+	}
+
+	stored, err := s.ListLimits(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = now
+	if err != nil {
+		slog.Warn("failed to read the tenant limits", "error", err)
+		return
+	}
+	s.limits = make(map[string]model.TenantLimit, len(stored))
+	for _, limit := range stored {
+		s.limits[limit.Tenant] = limit
+	}
+	for tenant := range s.tenants {
+		if _, ok := s.limits[tenant]; !ok && tenant != "" {
+			delete(s.tenants, tenant)
+		}
+	}
+}
+
+// forget makes this replica read the limits again on its next request, the others pick a change
+// up within CacheTTL
+func (s *TenantService) forget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Time{}
+}
+
+// EffectiveLimit is the stored limit of the tenant, or the defaults requests without one get
+func (s *TenantService) EffectiveLimit(ctx context.Context, tenant string) (model.TenantLimit, error) {
+	limit, err := s.GetLimit(ctx, tenant)
+	if errors.Is(err, ErrTenantLimitNotFound) {
+		return model.TenantLimit{Tenant: tenant, RequestsPerMinute: s.DefaultRequestsPerMinute, DailyQuota: s.DefaultDailyQuota}, nil
+	}
+	if err != nil {
+		return model.TenantLimit{Tenant: tenant, RequestsPerMinute: s.DefaultRequestsPerMinute, DailyQuota: s.DefaultDailyQuota}, err
+	}
+	return limit, nil
+}
+
+func (s *TenantService) GetLimit(ctx context.Context, tenant string) (model.TenantLimit, error) {
+	var limit model.TenantLimit
+	err := db.DB.WithContext(ctx).First(&limit, "tenant = ?", tenant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return limit, ErrTenantLimitNotFound
+	}
+	return limit, err
+}
+
+func (s *TenantService) ListLimits(ctx context.Context) ([]model.TenantLimit, error) {
+	var limits []model.TenantLimit
+	err := db.DB.WithContext(ctx).Order("tenant").Find(&limits).Error
+	return limits, err
+}
+
+// SetLimit stores the limits of a tenant, which makes it known
+func (s *TenantService) SetLimit(ctx context.Context, limit model.TenantLimit) (model.TenantLimit, error) {
+	err := db.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant"}},
+		DoUpdates: clause.AssignmentColumns([]string{"requests_per_minute", "daily_quota", "updated_at"}),
+	}).Create(&limit).Error
+	if err != nil {
+		return limit, err
+	}
+	s.forget()
+	return s.GetLimit(ctx, limit.Tenant)
+}
+
+// DeleteLimit makes the tenant unknown, its requests are rejected from then on
+func (s *TenantService) DeleteLimit(ctx context.Context, tenant string) error {
+	result := db.DB.WithContext(ctx).Delete(&model.TenantLimit{}, "tenant = ?", tenant)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTenantLimitNotFound
+	}
+	s.forget()
+	return nil
+}
+
+// TenantUsage reports the limits that apply to the tenant and its requests of today, counted only
+// while it has a daily quota
+func (s *TenantService) TenantUsage(ctx context.Context, tenant string, now time.Time) (model.TenantLimit, Quota, error) {
+	limit, err := s.EffectiveLimit(ctx, tenant)
+	if err != nil {
+		return limit, Quota{}, err
+	}
+	window := tenantDay(limit, now)
+	var usage model.TenantUsage
+	err = db.DB.WithContext(ctx).Where("tenant = ? AND day = ?", tenant, window.period).Limit(1).Find(&usage).Error
+	return limit, window.quota(usage.Requests), err
+}
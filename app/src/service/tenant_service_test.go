@@ -0,0 +1,85 @@
+package service
+
+import (
+	"app/apptest"
+	"app/model"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTenantAllow(t *testing.T) {
+	apptest.DB(t, &model.TenantLimit{}, &model.TenantUsage{})
+	apptest.Seed(t, &model.TenantLimit{Tenant: "acme", RequestsPerMinute: 2}, &model.TenantLimit{Tenant: "globex", RequestsPerMinute: 2})
+	s := TenantService{DefaultRequestsPerMinute: 1, CacheTTL: time.Minute}
+	ctx := context.Background()
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	allow := func(tenant string, at time.Time) error {
+		t.Helper()
+		_, err := s.Allow(ctx, tenant, at)
+		if err != nil && !errors.Is(err, ErrTenantRateLimited) && !errors.Is(err, ErrTenantQuotaExceeded) {
+			t.Fatal(err)
+		}
+		return err
+	}
+
+	// The limit allows a burst of a minute of requests, then one every 30s
+	for i := range 2 {
+		if err := allow("acme", now); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := allow("acme", now); !errors.Is(err, ErrTenantRateLimited) {
+		t.Fatalf("over the rate limit = %v", err)
+	}
+	if err := allow("acme", now.Add(30*time.Second)); err != nil {
+		t.Fatalf("after the refill = %v", err)
+	}
+	if err := allow("globex", now); err != nil {
+		t.Fatalf("other tenant = %v", err)
+	}
+
+	// Requests without a tenant share the defaults, unknown tenants are rejected
+	if err := allow("", now); err != nil {
+		t.Fatalf("without a tenant = %v", err)
+	}
+	if err := allow("", now); !errors.Is(err, ErrTenantRateLimited) {
+		t.Fatalf("without a tenant over the defaults = %v", err)
+	}
+	if _, err := s.Allow(ctx, "initech", now); !errors.Is(err, ErrUnknownTenant) {
+		t.Fatalf("unknown tenant = %v", err)
+	}
+
+	// A change applies at once on this replica
+	if _, err := s.SetLimit(ctx, model.TenantLimit{Tenant: "acme", DailyQuota: 2}); err != nil {
+		t.Fatal(err)
+	}
+	later := now.Add(time.Second)
+	for i := range 2 {
+		if err := allow("acme", later); err != nil {
+			t.Fatalf("quota request %d: %v", i, err)
+		}
+	}
+	decision, err := s.Allow(ctx, "acme", later)
+	if !errors.Is(err, ErrTenantQuotaExceeded) || decision.Quota.Remaining != 0 {
+		t.Fatalf("over the quota = %+v, %v", decision, err)
+	}
+	if decision.RetryAfter != now.AddDate(0, 0, 1).Truncate(24*time.Hour).Sub(later) {
+		t.Errorf("retry after = %v", decision.RetryAfter)
+	}
+	if err := allow("acme", now.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("next day = %v", err)
+	}
+
+	if err := s.DeleteLimit(ctx, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Allow(ctx, "acme", now.AddDate(0, 0, 1)); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("after delete = %v, want the tenant unknown", err)
+	}
+	if limit, err := s.EffectiveLimit(ctx, "acme"); err != nil || limit.RequestsPerMinute != 1 || limit.DailyQuota != 0 {
+		t.Errorf("limit after delete = %+v, %v", limit, err)
+	}
+}
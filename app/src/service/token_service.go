@@ -0,0 +1,173 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/config"
+	"app/db"
+	"app/jwtauth"
+	"app/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidRefreshToken = apperrors.New(apperrors.Unauthorized, "invalid refresh token")
+
+type TokenService struct {
+	Signer *jwtauth.Signer
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func refreshTTL() time.Duration {
+	return config.Duration("JWT_REFRESH_TTL", 30*24*time.Hour)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsRevoked checks the access token revocation list
+func (s *TokenService) IsRevoked(jti string) (bool, error) {
+	var count int64
+	err := db.DB.Model(&model.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// Issue starts a new refresh token family for a fresh login
+func (s *TokenService) Issue(user AuthUser) (TokenPair, error) {
+	return s.issue(db.DB, user, uuid.New().String())
+}
+
+func (s *TokenService) issue(tx *gorm.DB, user AuthUser, familyID string) (TokenPair, error) {
+	access, _, err := s.Signer.Issue(user.ID, user.Username)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return TokenPair{}, err
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(b)
+
+	record := model.RefreshToken{
+		UserID:    user.ID,
+		Username:  user.Username,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: time.Now().Add(refreshTTL()),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.Signer.AccessTTL.Seconds()),
+	}, nil
+}
+
+// Refresh rotates the refresh token. Presenting an already rotated token is treated
+// as theft and revokes every token in its family.
+func (s *TokenService) Refresh(refresh string) (TokenPair, error) {
+	var pair TokenPair
+	reused := false
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so a second use of the token waits for this rotation and then sees it revoked
+		var record model.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", hashToken(refresh)).First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if record.RevokedAt != nil {
+			reused = record.ReplacedBy != ""
+			return ErrInvalidRefreshToken
+		}
+		if time.Now().After(record.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		next, err := s.issue(tx, AuthUser{ID: record.UserID, Username: record.Username}, record.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		var replacement model.RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(next.RefreshToken)).First(&replacement).Error; err != nil {
+			return err
+		}
+		// Databases without row locks still only rotate once, the loser counts as a reuse
+		result := tx.Model(&record).Where("revoked_at IS NULL").Updates(map[string]any{
+			"revoked_at":  time.Now(),
+			"replaced_by": replacement.ID,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return ErrInvalidRefreshToken
+		}
+
+		pair = next
+		return nil
+	})
+
+	if reused {
+		slog.Warn("rotated refresh token was reused, revoking token family")
+		if err := s.revokeFamily(refresh); err != nil {
+			slog.Error("failed to revoke refresh token family", "error", err)
+		}
+	}
+	return pair, err
+}
+
+// Revoke invalidates the refresh token family and, when given, the access token presented with it
+func (s *TokenService) Revoke(refresh string, access *jwtauth.Claims) error {
+	if refresh != "" {
+		if err := s.revokeFamily(refresh); err != nil {
+			return err
+		}
+	}
+	if access != nil {
+		entry := model.RevokedToken{JTI: access.ID, ExpiresAt: access.ExpiresAt.Time}
+		if err := db.DB.Save(&entry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TokenService) revokeFamily(refresh string) error {
+	var record model.RefreshToken
+	if err := db.DB.Where("token_hash = ?", hashToken(refresh)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+	return db.DB.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", record.FamilyID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"app/apptest"
+	"app/jwtauth"
+	"app/model"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshRotatesOnceAndRevokesTheFamilyOnReuse(t *testing.T) {
+	apptest.DB(t, &model.RefreshToken{}, &model.RevokedToken{})
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := TokenService{Signer: &jwtauth.Signer{PrivateKey: private, PublicKey: public, AccessTTL: time.Minute}}
+
+	first, err := s.Issue(AuthUser{ID: "user-1", Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Refresh(first.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("reusing the rotated token: error = %v, want ErrInvalidRefreshToken", err)
+	}
+	// The reuse revoked the whole family, the token it was rotated to included
+	if _, err := s.Refresh(second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("refresh after reuse: error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
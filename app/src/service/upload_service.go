@@ -0,0 +1,219 @@
+package service
+
+import (
+	"app/apperrors"
+	"app/db"
+	"app/model"
+	"app/storage"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUploadNotFound   = apperrors.New(apperrors.NotFound, "upload not found")
+	ErrUploadOffset     = apperrors.New(apperrors.Conflict, "chunk does not start at the upload's offset")
+	ErrUploadIncomplete = apperrors.New(apperrors.Conflict, "upload is incomplete")
+	ErrChunkEmpty       = apperrors.New(apperrors.Validation, "chunk is empty")
+	ErrChunkTooLong     = apperrors.New(apperrors.Validation, "chunk goes past the size of the upload")
+)
+
+// uploadPrefix is where the chunks of resumable uploads are kept until they are completed
+const uploadPrefix = "uploads/"
+
+// CreateUpload starts a resumable upload of size bytes. The chunks are sent with WriteChunk, each one
+// small enough for the ingress body limit, and joined into a file by CompleteUpload.
+func (s *FileService) CreateUpload(ctx context.Context, name, contentType string, size int64) (model.UploadSession, error) {
+	upload := model.UploadSession{
+		ID:          uuid.New().String(),
+		ExpiresAt:   time.Now().Add(s.UploadTTL),
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+	}
+	err := db.DB.WithContext(ctx).Create(&upload).Error
+	return upload, err
+}
+
+func (s *FileService) GetUpload(ctx context.Context, id string) (model.UploadSession, error) {
+	var upload model.UploadSession
+	err := db.DB.WithContext(ctx).Where("expires_at > ?", time.Now()).First(&upload, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return upload, ErrUploadNotFound
+	}
+	return upload, err
+}
+
+// WriteChunk stores r as the part of the upload starting at offset, which must be the upload's
+// current offset. A chunk that was interrupted is sent again from the offset GetUpload reports.
+func (s *FileService) WriteChunk(ctx context.Context, id string, offset int64, r io.Reader) (model.UploadSession, error) {
+	upload, err := s.GetUpload(ctx, id)
+	if err != nil {
+		return upload, err
+	}
+	if offset != upload.Offset {
+		return upload, ErrUploadOffset
+	}
+
+	part := model.UploadPart{UploadID: id, Offset: offset, Key: uploadPrefix + id + "/" + uuid.New().String()}
+	// One byte past the rest of the file tells a chunk that is too long
+	counter := &countingReader{Reader: io.LimitReader(r, upload.Size-offset+1)}
+	if err := storage.Default.Put(ctx, part.Key, counter, -1, "application/octet-stream"); err != nil {
+		return upload, err
+	}
+	part.Size = counter.n
+	discard := func(err error) (model.UploadSession, error) {
+		storage.Default.Delete(context.WithoutCancel(ctx), part.Key)
+		return upload, err
+	}
+	switch {
+	case part.Size == 0:
+		return discard(ErrChunkEmpty)
+	case offset+part.Size > upload.Size:
+		return discard(ErrChunkTooLong)
+	}
+
+	expiresAt := time.Now().Add(s.UploadTTL)
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Only one of two chunks sent for the same offset moves it
+		result := tx.Model(&model.UploadSession{}).
+			Where("id = ? AND upload_offset = ?", id, offset).
+			Updates(map[string]any{"upload_offset": offset + part.Size, "expires_at": expiresAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUploadOffset
+		}
+		return tx.Create(&part).Error
+	})
+	if err != nil {
+		return discard(err)
+	}
+	upload.Offset, upload.ExpiresAt = offset+part.Size, expiresAt
+	return upload, nil
+}
+
+// CompleteUpload joins the parts of a fully received upload into a file, stored and scanned like
+// one uploaded at once, and removes the upload
+func (s *FileService) CompleteUpload(ctx context.Context, id string) (model.File, error) {
+	upload, err := s.GetUpload(ctx, id)
+	if err != nil {
+		return model.File{}, err
+	}
+	if upload.Offset != upload.Size {
+		return model.File{}, ErrUploadIncomplete
+	}
+	var parts []model.UploadPart
+	if err := db.DB.WithContext(ctx).Where("upload_id = ?", id).Order("part_offset").Find(&parts).Error; err != nil {
+		return model.File{}, err
+	}
+
+	reader := &partsReader{ctx: ctx, parts: parts}
+	file, err := s.Upload(ctx, upload.Name, upload.ContentType, reader)
+	reader.Close()
+	if err != nil {
+		return model.File{}, err
+	}
+	// A concurrent completion of the same upload already created the file
+	result := db.DB.WithContext(ctx).Delete(&model.UploadSession{}, "id = ?", id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		s.Delete(context.WithoutCancel(ctx), file.ID)
+		return model.File{}, ErrUploadNotFound
+	}
+	s.removeParts(context.WithoutCancel(ctx), id, parts)
+	return file, result.Error
+}
+
+// AbortUpload removes the upload and the chunks received so far
+func (s *FileService) AbortUpload(ctx context.Context, id string) error {
+	if _, err := s.GetUpload(ctx, id); err != nil {
+		return err
+	}
+	return s.removeUpload(ctx, id)
+}
+
+// PurgeExpiredUploads removes the uploads that received no chunk for FILES_UPLOAD_TTL
+func (s *FileService) PurgeExpiredUploads(ctx context.Context) error {
+	var ids []string
+	if err := db.DB.WithContext(ctx).Model(&model.UploadSession{}).Where("expires_at <= ?", time.Now()).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.removeUpload(ctx, id); err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		slog.Info("purged expired uploads", "count", len(ids))
+	}
+	return nil
+}
+
+func (s *FileService) removeUpload(ctx context.Context, id string) error {
+	var parts []model.UploadPart
+	if err := db.DB.WithContext(ctx).Where("upload_id = ?", id).Find(&parts).Error; err != nil {
+		return err
+	}
+	if err := db.DB.WithContext(ctx).Delete(&model.UploadSession{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	s.removeParts(ctx, id, parts)
+	return nil
+}
+
+// removeParts deletes the chunk objects and their rows, a failed delete only leaves garbage behind
+func (s *FileService) removeParts(ctx context.Context, id string, parts []model.UploadPart) {
+	for _, part := range parts {
+		if err := storage.Default.Delete(ctx, part.Key); err != nil {
+			slog.Warn("failed to delete an upload chunk", "upload", id, "key", part.Key, "error", err)
+		}
+	}
+	if err := db.DB.WithContext(ctx).Where("upload_id = ?", id).Delete(&model.UploadPart{}).Error; err != nil {
+		slog.Warn("failed to delete the upload chunks", "upload", id, "error", err)
+	}
+}
+
+// partsReader reads the parts one after the other, opening each only when the previous one is done
+type partsReader struct {
+	ctx     context.Context
+	parts   []model.UploadPart
+	current io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			reader, _, err := storage.Default.Get(r.ctx, r.parts[0].Key)
+			if err != nil {
+				return 0, err
+			}
+			r.current, r.parts = reader, r.parts[1:]
+		}
+		n, err := r.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Close()
+}
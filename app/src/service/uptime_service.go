@@ -0,0 +1,159 @@
+package service
+
+import (
+	"app/config"
+	"app/db"
+	"app/kube"
+	"app/model"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// uptimeHistory is how many past runs GET /status/uptime lists
+const uptimeHistory = 10
+
+// reasonNoMarker is the exit reason of a run that never wrote its shutdown marker
+const reasonNoMarker = "no shutdown marker: the process was killed (OOM kill, liveness probe, eviction) or crashed"
+
+// Uptime is how long this process has been running and how the previous runs in the pod ended
+type Uptime struct {
+	Pod           string    `json:"pod"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	// Restarts counts the earlier runs recorded for the pod, Crashes those without a shutdown marker
+	Restarts  int64      `json:"restarts"`
+	Crashes   int64      `json:"crashes"`
+	LastCrash *LastCrash `json:"last_crash,omitempty"`
+	// Container is what the kubelet reports, only in a cluster
+	Container *ContainerRestarts   `json:"container,omitempty"`
+	History   []model.ProcessEvent `json:"history"`
+}
+
+type LastCrash struct {
+	StartedAt time.Time `json:"started_at"`
+	Reason    string    `json:"reason"`
+}
+
+// ContainerRestarts is the container status of the pod, which knows why the last run was killed
+type ContainerRestarts struct {
+	Name         string     `json:"name"`
+	RestartCount int32      `json:"restart_count"`
+	LastReason   string     `json:"last_reason,omitempty"`
+	LastExitCode int32      `json:"last_exit_code,omitempty"`
+	LastFinished *time.Time `json:"last_finished_at,omitempty"`
+}
+
+// UptimeService records the start of the process and its shutdown marker, so restarts caused by
+// probes and OOM kills can be seen from the app
+type UptimeService struct {
+	mu      sync.Mutex
+	current model.ProcessEvent
+}
+
+// RecordStart records this run, started at startedAt
+func (s *UptimeService) RecordStart(ctx context.Context, startedAt time.Time) error {
+	event := model.ProcessEvent{
+		ID:        uuid.New().String(),
+		Pod:       kube.PodName(),
+		Version:   config.String("VERSION", ""),
+		StartedAt: startedAt,
+	}
+	s.mu.Lock()
+	s.current = event
+	s.mu.Unlock()
+	return db.DB.WithContext(ctx).Create(&event).Error
+}
+
+// RecordStop writes the shutdown marker of this run, the last thing a graceful shutdown does
+func (s *UptimeService) RecordStop(ctx context.Context, reason string) error {
+	s.mu.Lock()
+	current := s.current
+	s.mu.Unlock()
+	if current.ID == "" {
+		return nil
+	}
+	return db.DB.WithContext(ctx).Model(&current).Updates(map[string]any{"stopped_at": time.Now(), "stop_reason": reason}).Error
+}
+
+func (s *UptimeService) Uptime(ctx context.Context) (Uptime, error) {
+	s.mu.Lock()
+	current := s.current
+	s.mu.Unlock()
+
+	uptime := Uptime{Pod: kube.PodName(), StartedAt: current.StartedAt, UptimeSeconds: time.Since(current.StartedAt).Seconds()}
+	earlier := db.DB.WithContext(ctx).Model(&model.ProcessEvent{}).Where("pod = ? AND id <> ?", uptime.Pod, current.ID)
+	if err := earlier.Session(&gorm.Session{}).Count(&uptime.Restarts).Error; err != nil {
+		return uptime, err
+	}
+	crashes := earlier.Session(&gorm.Session{}).Where("stopped_at IS NULL")
+	if err := crashes.Session(&gorm.Session{}).Count(&uptime.Crashes).Error; err != nil {
+		return uptime, err
+	}
+	if err := db.DB.WithContext(ctx).Where("pod = ?", uptime.Pod).Order("started_at desc").Limit(uptimeHistory).Find(&uptime.History).Error; err != nil {
+		return uptime, err
+	}
+
+	if kube.Enabled() {
+		container, err := containerRestarts(ctx)
+		if err != nil {
+			// The recorded runs are still worth answering with
+			slog.Warn("failed to read the container status", "error", err)
+		}
+		uptime.Container = container
+	}
+
+	var crashed model.ProcessEvent
+	err := crashes.Order("started_at desc").First(&crashed).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return uptime, nil
+	case err != nil:
+		return uptime, err
+	}
+	uptime.LastCrash = &LastCrash{StartedAt: crashed.StartedAt, Reason: reasonNoMarker}
+	// The kubelet knows why the run right before this one ended
+	if container := uptime.Container; container != nil && container.LastReason != "" && wasPrevious(crashed, uptime.History, current.ID) {
+		uptime.LastCrash.Reason = fmt.Sprintf("%s (exit code %d)", container.LastReason, container.LastExitCode)
+	}
+	return uptime, nil
+}
+
+// wasPrevious is whether event is the run right before the current one
+func wasPrevious(event model.ProcessEvent, history []model.ProcessEvent, currentID string) bool {
+	for i, run := range history {
+		if run.ID == currentID {
+			return i+1 < len(history) && history[i+1].ID == event.ID
+		}
+	}
+	return false
+}
+
+// containerRestarts reads the status of the app's container, CONTAINER_NAME or the pod's first
+func containerRestarts(ctx context.Context) (*ContainerRestarts, error) {
+	pod, err := kube.Client.CoreV1().Pods(kube.Namespace()).Get(ctx, kube.PodName(), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	name := config.String("CONTAINER_NAME", "")
+	for _, status := range pod.Status.ContainerStatuses {
+		if name != "" && status.Name != name {
+			continue
+		}
+		restarts := &ContainerRestarts{Name: status.Name, RestartCount: status.RestartCount}
+		if terminated := status.LastTerminationState.Terminated; terminated != nil {
+			restarts.LastReason = terminated.Reason
+			restarts.LastExitCode = terminated.ExitCode
+			restarts.LastFinished = &terminated.FinishedAt.Time
+		}
+		return restarts, nil
+	}
+	return nil, nil
+}
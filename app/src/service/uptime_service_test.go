@@ -0,0 +1,54 @@
+package service
+
+import (
+	"app/apptest"
+	"app/db"
+	"app/model"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUptimeRestarts(t *testing.T) {
+	apptest.DB(t, &model.ProcessEvent{})
+	t.Setenv("POD_NAME", "app-0")
+	ctx := context.Background()
+
+	now := time.Now()
+	stopped := now.Add(-2 * time.Hour)
+	earlier := []model.ProcessEvent{
+		{ID: "clean", Pod: "app-0", StartedAt: now.Add(-3 * time.Hour), StoppedAt: &stopped, StopReason: "graceful shutdown"},
+		{ID: "killed", Pod: "app-0", StartedAt: now.Add(-time.Hour)},
+		{ID: "other-pod", Pod: "app-1", StartedAt: now.Add(-time.Hour)},
+	}
+	if err := db.DB.Create(&earlier).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	s := UptimeService{}
+	if err := s.RecordStart(ctx, now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	uptime, err := s.Uptime(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uptime.Restarts != 2 || uptime.Crashes != 1 {
+		t.Errorf("restarts = %d, crashes = %d, want 2 and 1", uptime.Restarts, uptime.Crashes)
+	}
+	if uptime.LastCrash == nil || uptime.LastCrash.Reason != reasonNoMarker || !uptime.LastCrash.StartedAt.Equal(earlier[1].StartedAt) {
+		t.Errorf("last crash = %+v", uptime.LastCrash)
+	}
+	if uptime.UptimeSeconds < 60 || len(uptime.History) != 3 {
+		t.Errorf("uptime = %v, history = %d runs", uptime.UptimeSeconds, len(uptime.History))
+	}
+
+	if err := s.RecordStop(ctx, "graceful shutdown"); err != nil {
+		t.Fatal(err)
+	}
+	var current model.ProcessEvent
+	db.DB.Order("started_at desc").First(&current, "pod = ?", "app-0")
+	if current.StoppedAt == nil || current.StopReason != "graceful shutdown" {
+		t.Errorf("shutdown marker = %+v", current)
+	}
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"app/apptest"
+	"app/model"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestWebhookReceiveDuplicate(t *testing.T) {
+	apptest.DB(t, &model.WebhookEvent{})
+	s := WebhookService{}
+	ctx := context.Background()
+	header := http.Header{}
+	header.Set(webhookProviders["github"].DeliveryHeader, "delivery-1")
+
+	if _, err := s.Receive(ctx, "github", header, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Receive(ctx, "github", header, []byte(`{}`)); !errors.Is(err, ErrDuplicateEvent) {
+		t.Fatalf("redelivery error = %v, want ErrDuplicateEvent", err)
+	}
+	// Deliveries without an ID are never duplicates
+	for range 2 {
+		if _, err := s.Receive(ctx, "github", http.Header{}, []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"app/kube"
+	"app/redisdb"
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	whoamiKeyPrefix = "whoami:"
+	whoamiTTL       = 24 * time.Hour
+	// whoamiMaxVisitors bounds the visitors a pod remembers, it forgets them all when full
+	whoamiMaxVisitors = 10000
+)
+
+// Visit is what one store remembers of a visitor
+type Visit struct {
+	// Known is false on the first visit the store has seen, with pod state that is every visit landing on another pod
+	Known     bool      `json:"known"`
+	Visits    int64     `json:"visits"`
+	FirstSeen time.Time `json:"first_seen"`
+	// Store is pod for the replica's memory, redis or pod again when redis is not configured or down
+	Store string `json:"store"`
+}
+
+type podVisitor struct {
+	visits    int64
+	firstSeen time.Time
+}
+
+// podVisitors is per-pod state on purpose, it shows what keeping sessions in memory does once there are replicas
+var podVisitors = struct {
+	sync.Mutex
+	visitors map[string]*podVisitor
+	requests int64
+}{visitors: map[string]*podVisitor{}}
+
+type WhoAmIService struct{}
+
+// PodVisit counts the visit in this replica's memory. Without session affinity the next request
+// may land on a replica that has never seen the visitor, and the pod's count of all whoami requests
+// shows how the load balancer spread them.
+func (s *WhoAmIService) PodVisit(visitor string) (visit Visit, podRequests int64) {
+	podVisitors.Lock()
+	defer podVisitors.Unlock()
+	podVisitors.requests++
+	return rememberVisit(visitor), podVisitors.requests
+}
+
+// rememberVisit counts a visit under key in the pod's memory, podVisitors must be locked
+func rememberVisit(key string) Visit {
+	v, known := podVisitors.visitors[key]
+	if !known {
+		if len(podVisitors.visitors) >= whoamiMaxVisitors {
+			podVisitors.visitors = map[string]*podVisitor{}
+		}
+		v = &podVisitor{firstSeen: time.Now()}
+		podVisitors.visitors[key] = v
+	}
+	v.visits++
+	return Visit{Known: known, Visits: v.visits, FirstSeen: v.firstSeen, Store: "pod"}
+}
+
+// SharedVisit counts the visit in redis, the same for whichever replica answers. It falls back to
+// the pod's memory when redis is not configured or unreachable, which then behaves exactly like PodVisit.
+func (s *WhoAmIService) SharedVisit(ctx context.Context, visitor string) (Visit, error) {
+	if redisdb.Client == nil {
+		podVisitors.Lock()
+		defer podVisitors.Unlock()
+		return rememberVisit(whoamiKeyPrefix + visitor), nil
+	}
+
+	key := whoamiKeyPrefix + visitor
+	now := time.Now()
+	pipe := redisdb.Client.TxPipeline()
+	visits := pipe.HIncrBy(ctx, key, "visits", 1)
+	pipe.HSetNX(ctx, key, "first_seen", now.UnixMilli())
+	firstSeen := pipe.HGet(ctx, key, "first_seen")
+	pipe.Expire(ctx, key, whoamiTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		// Remembered by this pod while redis is down, Store tells the caller
+		slog.Warn("redis is unavailable, remembering the visit in the pod", "error", err)
+		podVisitors.Lock()
+		defer podVisitors.Unlock()
+		return rememberVisit(key), nil
+	}
+
+	visit := Visit{Known: visits.Val() > 1, Visits: visits.Val(), FirstSeen: now, Store: "redis"}
+	if ms, err := strconv.ParseInt(firstSeen.Val(), 10, 64); err == nil {
+		visit.FirstSeen = time.UnixMilli(ms)
+	}
+	return visit, nil
+}
+
+// Pod is the replica answering, for the whoami responses
+func (s *WhoAmIService) Pod() string {
+	return kube.PodName()
+}
@@ -0,0 +1,192 @@
+// Package serviceauth authenticates calls from other workloads of the cluster by their projected
+// ServiceAccount token, reviewed with the TokenReview API, so services trust each other without a mesh.
+package serviceauth
+
+import (
+	"app/config"
+	"app/kube"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	authv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Header carries the caller's ServiceAccount token, apart from Authorization which user tokens use
+const Header = "X-Service-Token"
+
+const (
+	contextKey         = "serviceauth.identity"
+	serviceAccountUser = "system:serviceaccount:"
+	podNameExtra       = "authentication.kubernetes.io/pod-name"
+	maxCachedReviews   = 1000
+)
+
+var (
+	ErrInvalidToken = errors.New("service account token is invalid")
+	ErrNotAllowed   = errors.New("service account is not allowed")
+)
+
+// Identity is the workload a verified token belongs to
+type Identity struct {
+	Namespace      string `json:"namespace"`
+	ServiceAccount string `json:"service_account"`
+	// Pod is set for tokens bound to a pod, which projected tokens are
+	Pod string `json:"pod,omitempty"`
+}
+
+func (i Identity) String() string {
+	return i.Namespace + "/" + i.ServiceAccount
+}
+
+type Reviewer struct {
+	// Audience the token must be issued for, so a token meant for another service is not accepted here
+	Audience string
+	// Allowed lists namespace/name service accounts, empty allows any
+	Allowed []string
+	// Required rejects requests without a token, except the probe and metrics endpoints
+	Required bool
+	// CacheTTL is how long a review is reused, a revoked token stays accepted that long
+	CacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]review
+	// now is time.Now unless a test sets it
+	now func() time.Time
+}
+
+type review struct {
+	identity Identity
+	err      error
+	at       time.Time
+}
+
+// NewFromEnv returns nil unless SERVICE_AUTH_ENABLED is set. SERVICE_AUTH_AUDIENCE is the audience the
+// callers request their projected token for, SERVICE_AUTH_ALLOWED the service accounts let in.
+func NewFromEnv() *Reviewer {
+	if !config.Bool("SERVICE_AUTH_ENABLED", false) {
+		return nil
+	}
+	reviewer := &Reviewer{
+		Audience: config.String("SERVICE_AUTH_AUDIENCE", "app"),
+		Allowed:  config.List("SERVICE_AUTH_ALLOWED"),
+		Required: config.Bool("SERVICE_AUTH_REQUIRED", false),
+		CacheTTL: config.Duration("SERVICE_AUTH_CACHE_TTL", time.Minute),
+	}
+	slog.Info("service account authentication is enabled", "audience", reviewer.Audience, "allowed", reviewer.Allowed, "required", reviewer.Required)
+	return reviewer
+}
+
+// Review verifies token with the API server, answers are cached for CacheTTL by the token's hash
+func (r *Reviewer) Review(ctx context.Context, token string) (Identity, error) {
+	key := sha256.Sum256([]byte(token))
+	r.mu.Lock()
+	cached, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && r.clock().Sub(cached.at) < r.CacheTTL {
+		return cached.identity, cached.err
+	}
+
+	identity, err := r.review(ctx, token)
+	if err != nil && !errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrNotAllowed) {
+		// The API server being unreachable is not cached, the next call asks again
+		return Identity{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache == nil || len(r.cache) >= maxCachedReviews {
+		r.cache = map[[sha256.Size]byte]review{}
+	}
+	r.cache[key] = review{identity: identity, err: err, at: r.clock()}
+	return identity, err
+}
+
+func (r *Reviewer) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+func (r *Reviewer) review(ctx context.Context, token string) (Identity, error) {
+	if !kube.Enabled() {
+		return Identity{}, kube.ErrNotInCluster
+	}
+	result, err := kube.Client.AuthenticationV1().TokenReviews().Create(ctx, &authv1.TokenReview{
+		Spec: authv1.TokenReviewSpec{Token: token, Audiences: []string{r.Audience}},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return Identity{}, fmt.Errorf("token review: %w", err)
+	}
+	status := result.Status
+	if !status.Authenticated || !slices.Contains(status.Audiences, r.Audience) {
+		return Identity{}, ErrInvalidToken
+	}
+
+	// system:serviceaccount:<namespace>:<name>, other users such as nodes are no workloads
+	account, isServiceAccount := strings.CutPrefix(status.User.Username, serviceAccountUser)
+	namespace, name, found := strings.Cut(account, ":")
+	if !isServiceAccount || !found {
+		return Identity{}, ErrInvalidToken
+	}
+	identity := Identity{Namespace: namespace, ServiceAccount: name}
+	if pods := status.User.Extra[podNameExtra]; len(pods) > 0 {
+		identity.Pod = pods[0]
+	}
+	if len(r.Allowed) > 0 && !slices.Contains(r.Allowed, identity.String()) {
+		return identity, ErrNotAllowed
+	}
+	return identity, nil
+}
+
+// exempt paths are called by the kubelet and Prometheus, which present no token. /simulate/healthz
+// is the probe of a simulated downstream.
+func exempt(path string) bool {
+	return path == "/healthz" || path == "/readyz" || path == "/startupz" || path == "/metrics" || path == "/simulate/healthz"
+}
+
+// Middleware verifies the token of requests presenting one and rejects those with an invalid token
+// or of a service account not allowed; with Required, requests without one are rejected as well.
+func (r *Reviewer) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			token := ctx.Request().Header.Get(Header)
+			if token == "" {
+				if r.Required && !exempt(ctx.Request().URL.Path) {
+					return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "missing service account token"})
+				}
+				return next(ctx)
+			}
+
+			identity, err := r.Review(ctx.Request().Context(), token)
+			switch {
+			case errors.Is(err, ErrInvalidToken):
+				return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid service account token"})
+			case errors.Is(err, ErrNotAllowed):
+				return ctx.JSON(http.StatusForbidden, map[string]string{"error": "service account " + identity.String() + " is not allowed"})
+			case err != nil:
+				slog.Error("failed to review service account token", "error", err)
+				return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": "failed to review service account token"})
+			}
+			ctx.Set(contextKey, identity)
+			return next(ctx)
+		}
+	}
+}
+
+// Get returns the workload the request was authenticated as, false when it presented no token
+func Get(ctx echo.Context) (Identity, bool) {
+	identity, ok := ctx.Get(contextKey).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,158 @@
+package serviceauth
+
+import (
+	"app/kube"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeAPIServer points kube.Client at a clientset answering TokenReviews for users, keyed by token.
+// The returned counter is the number of reviews it answered.
+func fakeAPIServer(t *testing.T, users map[string]authv1.UserInfo) *int {
+	t.Helper()
+	reviews := 0
+	client := fake.NewClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		tokenReview := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenReview)
+		if tokenReview.Spec.Token == "unreachable" {
+			return true, nil, errors.New("connection refused")
+		}
+		if user, ok := users[tokenReview.Spec.Token]; ok {
+			tokenReview.Status = authv1.TokenReviewStatus{Authenticated: true, User: user, Audiences: tokenReview.Spec.Audiences}
+		}
+		return true, tokenReview, nil
+	})
+	previous := kube.Client
+	kube.Client = client
+	t.Cleanup(func() { kube.Client = previous })
+	return &reviews
+}
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestReviewer(t *testing.T) (*Reviewer, *int, *fakeClock) {
+	t.Helper()
+	reviews := fakeAPIServer(t, map[string]authv1.UserInfo{
+		"frontend": {
+			Username: "system:serviceaccount:shop:frontend",
+			Extra:    map[string]authv1.ExtraValue{podNameExtra: {"frontend-0"}},
+		},
+		"batch": {Username: "system:serviceaccount:shop:batch"},
+		"node":  {Username: "system:node:worker-1"},
+	})
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	reviewer := &Reviewer{Audience: "app", Allowed: []string{"shop/frontend"}, Required: true, CacheTTL: time.Minute, now: clock.now}
+	return reviewer, reviews, clock
+}
+
+func serve(r *Reviewer, path, token string) (int, Identity) {
+	e := echo.New()
+	e.Use(r.Middleware())
+	var identity Identity
+	handler := func(ctx echo.Context) error {
+		identity, _ = Get(ctx)
+		return ctx.NoContent(http.StatusOK)
+	}
+	e.GET("/samples", handler)
+	e.GET("/healthz", handler)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set(Header, token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code, identity
+}
+
+func TestMiddleware(t *testing.T) {
+	reviewer, _, _ := newTestReviewer(t)
+
+	code, identity := serve(reviewer, "/samples", "frontend")
+	if code != http.StatusOK || identity != (Identity{Namespace: "shop", ServiceAccount: "frontend", Pod: "frontend-0"}) {
+		t.Errorf("allowed service account: status %d as %+v, want 200 as shop/frontend on frontend-0", code, identity)
+	}
+
+	for _, tt := range []struct {
+		name, path, token string
+		want              int
+	}{
+		{"unknown token", "/samples", "forged", http.StatusUnauthorized},
+		{"user that is no service account", "/samples", "node", http.StatusUnauthorized},
+		{"service account not allowed", "/samples", "batch", http.StatusForbidden},
+		{"missing token", "/samples", "", http.StatusUnauthorized},
+		{"probe without a token", "/healthz", "", http.StatusOK},
+		{"api server unreachable", "/samples", "unreachable", http.StatusServiceUnavailable},
+	} {
+		if code, _ := serve(reviewer, tt.path, tt.token); code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestReviewRejectsAnotherAudience(t *testing.T) {
+	reviewer, _, _ := newTestReviewer(t)
+	client := kube.Client.(*fake.Clientset)
+	// The API server authenticates the token but for another audience than asked
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tokenReview := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenReview)
+		tokenReview.Status = authv1.TokenReviewStatus{
+			Authenticated: true,
+			User:          authv1.UserInfo{Username: "system:serviceaccount:shop:frontend"},
+			Audiences:     []string{"other"},
+		}
+		return true, tokenReview, nil
+	})
+	if _, err := reviewer.Review(t.Context(), "frontend"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token for another audience: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestReviewCache(t *testing.T) {
+	reviewer, reviews, clock := newTestReviewer(t)
+	ctx := t.Context()
+
+	for range 3 {
+		if _, err := reviewer.Review(ctx, "frontend"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reviewer.Review(ctx, "batch"); !errors.Is(err, ErrNotAllowed) {
+			t.Fatalf("batch: err = %v, want ErrNotAllowed", err)
+		}
+	}
+	if *reviews != 2 {
+		t.Errorf("reviews = %d, want one per token while cached, rejections included", *reviews)
+	}
+
+	clock.advance(time.Minute)
+	if _, err := reviewer.Review(ctx, "frontend"); err != nil {
+		t.Fatal(err)
+	}
+	if *reviews != 3 {
+		t.Errorf("reviews = %d, want the token reviewed again once CacheTTL passed", *reviews)
+	}
+
+	// A failed review is not cached, the next call asks the API server again
+	for range 2 {
+		if _, err := reviewer.Review(ctx, "unreachable"); err == nil {
+			t.Fatal("review succeeded with the API server unreachable")
+		}
+	}
+	if *reviews != 5 {
+		t.Errorf("reviews = %d, want both reviews of the unreachable case sent", *reviews)
+	}
+}
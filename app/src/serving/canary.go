@@ -0,0 +1,72 @@
+package serving
+
+import (
+	"app/baggage"
+	"app/config"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// HeaderCanary forces a variant per request, true for the canary and false for the stable behavior
+	HeaderCanary = "X-Canary"
+	// HeaderVariant tells which variant answered, canary or stable
+	HeaderVariant = "X-Serving-Variant"
+)
+
+const (
+	VariantStable = "stable"
+	VariantCanary = "canary"
+)
+
+const variantKey = "serving.variant"
+
+// canaryRelease reports whether this replica runs the canary, VERSION matching CANARY_VERSION (v2 by default)
+func canaryRelease() bool {
+	version := config.String("VERSION", "")
+	return version != "" && version == config.String("CANARY_VERSION", "v2")
+}
+
+// Variant picks the behavior for a request: the replica's own, unless X-Canary or the canary member
+// of the baggage overrides it. The override lets one Deployment show both behaviors before a rollout
+// splits the traffic, and goes on in the baggage of the calls the request makes, so the services
+// downstream answer with the same variant.
+func Variant(ctx echo.Context) string {
+	if variant, ok := ctx.Get(variantKey).(string); ok {
+		return variant
+	}
+	canary, forced := baggage.Get(ctx).IsCanary()
+	if header, err := strconv.ParseBool(ctx.Request().Header.Get(HeaderCanary)); err == nil {
+		canary, forced = header, true
+	}
+	if forced {
+		req := ctx.Request()
+		ctx.SetRequest(req.WithContext(baggage.With(req.Context(), baggage.KeyCanary, strconv.FormatBool(canary))))
+	} else {
+		canary = canaryRelease()
+	}
+	variant := VariantStable
+	if canary {
+		variant = VariantCanary
+	}
+	ctx.Set(variantKey, variant)
+	return variant
+}
+
+func IsCanary(ctx echo.Context) bool {
+	return Variant(ctx) == VariantCanary
+}
+
+// VariantMiddleware answers every response with X-Serving-Variant, so rollout analysis and
+// clients can tell which behavior they got. The header varies with X-Canary for caches.
+func VariantMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			res := ctx.Response()
+			res.Header().Set(HeaderVariant, Variant(ctx))
+			res.Header().Add(echo.HeaderVary, HeaderCanary)
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package serving
+
+import (
+	"app/clusterinfo"
+	"app/config"
+	"app/kube"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Response headers naming the replica that served a request, for canary and topology-aware routing demos
+const (
+	HeaderPod     = "X-Serving-Pod"
+	HeaderZone    = "X-Serving-Zone"
+	HeaderVersion = "X-Serving-Version"
+)
+
+var hexColor = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Replica is what the UI shows of the replica rendering a page
+type Replica struct {
+	Pod     string
+	Zone    string
+	Version string
+	// Tint is a #rrggbb color for the page, empty for the default look
+	Tint string
+}
+
+// Current describes this replica. VERSION is the deployed version such as v2, SERVING_TINT tints the UI:
+// "version" or "zone" derive a stable color from that value, so every version or zone has its own,
+// a hex color such as #16a34a is used as is and an empty value keeps the default look.
+func Current() Replica {
+	replica := Replica{
+		Pod:     kube.PodName(),
+		Zone:    clusterinfo.Current().Zone,
+		Version: config.String("VERSION", ""),
+	}
+	switch tint := config.String("SERVING_TINT", ""); {
+	case tint == "version":
+		replica.Tint = colorOf(replica.Version)
+	case tint == "zone":
+		replica.Tint = colorOf(replica.Zone)
+	case hexColor.MatchString(tint):
+		replica.Tint = strings.ToLower(tint)
+	}
+	return replica
+}
+
+// colorOf hashes value to a hue and returns a saturated mid-lightness color, readable with white text
+func colorOf(value string) string {
+	if value == "" {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	return hslToHex(float64(h.Sum32()%360), 0.65, 0.42)
+}
+
+func hslToHex(hue, saturation, lightness float64) string {
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g = chroma, x
+	case hue < 120:
+		r, g = x, chroma
+	case hue < 180:
+		g, b = chroma, x
+	case hue < 240:
+		g, b = x, chroma
+	case hue < 300:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	m := lightness - chroma/2
+	return fmt.Sprintf("#%02x%02x%02x", int((r+m)*255+0.5), int((g+m)*255+0.5), int((b+m)*255+0.5))
+}
+
+// Middleware adds the serving pod, zone and version headers to every response, leaving out the unknown ones
+func Middleware() echo.MiddlewareFunc {
+	replica := Current()
+	slog.Info("serving headers are enabled", "pod", replica.Pod, "zone", replica.Zone, "version", replica.Version)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			header := ctx.Response().Header()
+			for name, value := range map[string]string{HeaderPod: replica.Pod, HeaderZone: replica.Zone, HeaderVersion: replica.Version} {
+				if value != "" {
+					header.Set(name, value)
+				}
+			}
+			return next(ctx)
+		}
+	}
+}
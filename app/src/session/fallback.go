@@ -0,0 +1,243 @@
+package session
+
+import (
+	"app/db"
+	"app/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DBStore keeps sessions in the session_records table, shared between replicas like redis but slower
+type DBStore struct{}
+
+func (d *DBStore) Load(ctx context.Context, id string) (*Session, error) {
+	record, err := d.record(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.Deleted {
+		return nil, ErrNotFound
+	}
+	return decode(record)
+}
+
+// records returns the unexpired rows, tombstones included
+func (d *DBStore) records(ctx context.Context) ([]model.SessionRecord, error) {
+	var records []model.SessionRecord
+	err := db.DB.WithContext(ctx).Find(&records, "expires_at > ?", time.Now()).Error
+	return records, err
+}
+
+// record returns the unexpired row of the session, tombstones included
+func (d *DBStore) record(ctx context.Context, id string) (*model.SessionRecord, error) {
+	var record model.SessionRecord
+	err := db.DB.WithContext(ctx).First(&record, "id = ? AND expires_at > ?", id, time.Now()).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func decode(record *model.SessionRecord) (*Session, error) {
+	s := &Session{}
+	if err := json.Unmarshal(record.Data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (d *DBStore) Save(ctx context.Context, s *Session, ttl time.Duration) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return db.DB.WithContext(ctx).Save(&model.SessionRecord{ID: s.ID, UserID: s.UserID, Data: raw, ExpiresAt: time.Now().Add(ttl)}).Error
+}
+
+func (d *DBStore) Delete(ctx context.Context, id string) error {
+	return db.DB.WithContext(ctx).Delete(&model.SessionRecord{}, "id = ?", id).Error
+}
+
+func (d *DBStore) DeleteUser(ctx context.Context, userID string) error {
+	return db.DB.WithContext(ctx).Delete(&model.SessionRecord{}, "user_id = ?", userID).Error
+}
+
+// Tombstone replaces the session with a marker that it was deleted, kept for ttl
+func (d *DBStore) Tombstone(ctx context.Context, id string, ttl time.Duration) error {
+	return db.DB.WithContext(ctx).Save(&model.SessionRecord{ID: id, Deleted: true, ExpiresAt: time.Now().Add(ttl)}).Error
+}
+
+// FallbackStore uses Primary and turns to Fallback while Primary fails, so logins keep working
+// through a redis outage. A healthy Primary is used alone and the database is not touched.
+//
+// Once Primary failed, Fallback may hold rows newer than what Primary has: sessions saved during
+// the outage, and tombstones of sessions deleted meanwhile that hide the copy Primary still holds.
+// Until those rows are moved back, Load reads Fallback first. The first call Primary answers
+// afterwards reconciles them, and so does the first one after the pod starts, an outage may
+// have been seen by another replica.
+type FallbackStore struct {
+	Primary  Store
+	Fallback *DBStore
+	// TTL keeps tombstones at least as long as the copy in Primary they hide
+	TTL time.Duration
+
+	// clean is set while Fallback holds no rows Primary lacks
+	clean atomic.Bool
+	// failures counts the calls Primary failed, a reconcile racing one leaves the store unclean
+	failures    atomic.Uint64
+	reconciling sync.Mutex
+}
+
+func (f *FallbackStore) Load(ctx context.Context, id string) (*Session, error) {
+	if !f.clean.Load() {
+		if s, ok, err := f.loadFallback(ctx, id); ok {
+			return s, err
+		}
+	}
+
+	s, err := f.Primary.Load(ctx, id)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		f.recovered(ctx)
+		return s, err
+	}
+	if f.clean.Load() {
+		f.failed(err)
+		// The session may have been saved to Fallback by a replica that saw the outage first
+		if s, ok, err := f.loadFallback(ctx, id); ok {
+			return s, err
+		}
+	}
+	return nil, err
+}
+
+// loadFallback returns the session or tombstone Fallback holds, ok is false when it holds none
+func (f *FallbackStore) loadFallback(ctx context.Context, id string) (s *Session, ok bool, err error) {
+	record, err := f.Fallback.record(ctx, id)
+	switch {
+	case err == nil && record.Deleted:
+		return nil, true, ErrNotFound
+	case err == nil:
+		s, err = decode(record)
+		return s, true, err
+	case !errors.Is(err, ErrNotFound):
+		slog.Warn("session fallback failed, reading the session store alone", "error", err)
+	}
+	return nil, false, nil
+}
+
+func (f *FallbackStore) Save(ctx context.Context, s *Session, ttl time.Duration) error {
+	err := f.Primary.Save(ctx, s, ttl)
+	if err == nil {
+		// An older row in the fallback would win over what was just saved
+		if !f.clean.Load() {
+			if err := f.Fallback.Delete(ctx, s.ID); err != nil {
+				slog.Warn("failed to remove the session from the fallback", "error", err)
+			}
+			f.recovered(ctx)
+		}
+		return nil
+	}
+	f.failed(err)
+	slog.Warn("session store failed, saving to the fallback", "error", err)
+	return f.Fallback.Save(ctx, s, ttl)
+}
+
+// Delete removes the session, from Fallback as well while it may hold it. While Primary fails the
+// session is replaced by a tombstone in Fallback, and deleting it succeeds when that was written.
+func (f *FallbackStore) Delete(ctx context.Context, id string) error {
+	err := f.Primary.Delete(ctx, id)
+	if err == nil {
+		if f.clean.Load() {
+			return nil
+		}
+		if err := f.Fallback.Delete(ctx, id); err != nil {
+			return err
+		}
+		f.recovered(ctx)
+		return nil
+	}
+	f.failed(err)
+	slog.Warn("session store failed, leaving a tombstone in the fallback", "error", err)
+	return f.Fallback.Tombstone(ctx, id, f.TTL)
+}
+
+// DeleteUser removes the user's sessions from both. While Primary fails the sessions it holds
+// cannot be listed, so its error is returned for the caller to report.
+func (f *FallbackStore) DeleteUser(ctx context.Context, userID string) error {
+	err := f.Primary.DeleteUser(ctx, userID)
+	if err != nil {
+		f.failed(err)
+	}
+	return errors.Join(err, f.Fallback.DeleteUser(ctx, userID))
+}
+
+// failed marks Fallback as possibly holding rows Primary lacks, before they are written
+func (f *FallbackStore) failed(err error) {
+	f.failures.Add(1)
+	if f.clean.Swap(false) {
+		slog.Warn("session store failed, using the fallback until it recovers", "error", err)
+	}
+}
+
+// recovered moves the rows of Fallback back to Primary, once, after Primary answered again.
+// A call arriving while another reconciles carries on reading Fallback first.
+func (f *FallbackStore) recovered(ctx context.Context) {
+	if f.clean.Load() || !f.reconciling.TryLock() {
+		return
+	}
+	defer f.reconciling.Unlock()
+	if f.clean.Load() {
+		return
+	}
+
+	failures := f.failures.Load()
+	moved, err := f.reconcile(ctx)
+	if err != nil {
+		slog.Warn("failed to move the fallback sessions back to the session store", "moved", moved, "error", err)
+		return
+	}
+	if failures == f.failures.Load() {
+		f.clean.Store(true)
+	}
+	if moved > 0 {
+		slog.Info("moved the fallback sessions back to the session store", "moved", moved)
+	}
+}
+
+// reconcile saves the sessions of Fallback to Primary and deletes what its tombstones hide,
+// removing each row once Primary has it
+func (f *FallbackStore) reconcile(ctx context.Context) (int, error) {
+	records, err := f.Fallback.records(ctx)
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, record := range records {
+		if record.Deleted {
+			err = f.Primary.Delete(ctx, record.ID)
+		} else {
+			var s *Session
+			if s, err = decode(&record); err == nil {
+				err = f.Primary.Save(ctx, s, time.Until(record.ExpiresAt))
+			}
+		}
+		if err != nil {
+			return moved, err
+		}
+		if err := f.Fallback.Delete(ctx, record.ID); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
@@ -0,0 +1,189 @@
+package session
+
+import (
+	"app/apptest"
+	"app/model"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("redis is down")
+
+// flakyStore is a MemoryStore failing every call while down is set
+type flakyStore struct {
+	*MemoryStore
+	down bool
+}
+
+func (f *flakyStore) Load(ctx context.Context, id string) (*Session, error) {
+	if f.down {
+		return nil, errDown
+	}
+	return f.MemoryStore.Load(ctx, id)
+}
+
+func (f *flakyStore) Save(ctx context.Context, s *Session, ttl time.Duration) error {
+	if f.down {
+		return errDown
+	}
+	return f.MemoryStore.Save(ctx, s, ttl)
+}
+
+func (f *flakyStore) Delete(ctx context.Context, id string) error {
+	if f.down {
+		return errDown
+	}
+	return f.MemoryStore.Delete(ctx, id)
+}
+
+func newFallbackStore(t *testing.T) (*FallbackStore, *flakyStore) {
+	apptest.DB(t, &model.SessionRecord{})
+	primary := &flakyStore{MemoryStore: NewMemoryStore()}
+	return &FallbackStore{Primary: primary, Fallback: &DBStore{}, TTL: time.Hour}, primary
+}
+
+func TestFallbackStoreKeepsSessionsThroughAnOutage(t *testing.T) {
+	store, primary := newFallbackStore(t)
+	ctx := t.Context()
+
+	primary.down = true
+	if err := store.Save(ctx, &Session{ID: "s1", Username: "alice"}, time.Hour); err != nil {
+		t.Fatalf("save during the outage: %v", err)
+	}
+	if s, err := store.Load(ctx, "s1"); err != nil || s.Username != "alice" {
+		t.Fatalf("load during the outage = %v, %v, want the session from the fallback", s, err)
+	}
+	if _, err := store.Load(ctx, "missing"); !errors.Is(err, errDown) {
+		t.Errorf("load of an unknown session during the outage: err = %v, want the store's failure", err)
+	}
+
+	primary.down = false
+	if s, err := store.Load(ctx, "s1"); err != nil || s.Username != "alice" {
+		t.Errorf("load after the outage = %v, %v, want the session saved to the fallback", s, err)
+	}
+
+	// Saving to the recovered store removes the row that would win over it
+	if err := store.Save(ctx, &Session{ID: "s1", Username: "bob"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := store.Load(ctx, "s1"); err != nil || s.Username != "bob" {
+		t.Errorf("load after saving again = %v, %v, want the session just saved", s, err)
+	}
+}
+
+func TestFallbackStoreDeleteDuringAnOutage(t *testing.T) {
+	store, primary := newFallbackStore(t)
+	ctx := t.Context()
+	if err := store.Save(ctx, &Session{ID: "s1", Username: "alice"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	primary.down = true
+	if err := store.Delete(ctx, "s1"); err != nil {
+		t.Fatalf("delete during the outage: %v, want the tombstone written", err)
+	}
+
+	// The copy the recovered store still holds is hidden by the tombstone
+	primary.down = false
+	if s, err := primary.Load(ctx, "s1"); err != nil {
+		t.Fatalf("the test expects the stale copy in the store: %v %v", s, err)
+	}
+	if _, err := store.Load(ctx, "s1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("load after the outage: err = %v, want the deleted session not found", err)
+	}
+
+	// Without the session_records table the tombstone cannot be written and the delete fails
+	primary.down = true
+	apptest.DB(t)
+	if err := store.Delete(ctx, "s1"); err == nil {
+		t.Error("delete with both stores failing succeeded")
+	}
+}
+
+func TestFallbackStoreDeleteWithoutAnOutage(t *testing.T) {
+	store, primary := newFallbackStore(t)
+	ctx := t.Context()
+	primary.down = true
+	store.Save(ctx, &Session{ID: "s1"}, time.Hour)
+	primary.down = false
+	store.Save(ctx, &Session{ID: "s2"}, time.Hour)
+
+	for _, id := range []string{"s1", "s2"} {
+		if err := store.Delete(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Load(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: load after delete: err = %v, want not found", id, err)
+		}
+	}
+}
+
+func TestFallbackStoreDeleteUser(t *testing.T) {
+	store, primary := newFallbackStore(t)
+	ctx := t.Context()
+
+	store.Save(ctx, &Session{ID: "s1", UserID: "alice"}, time.Hour)
+	primary.down = true
+	store.Save(ctx, &Session{ID: "s2", UserID: "alice"}, time.Hour)
+	primary.down = false
+	store.Save(ctx, &Session{ID: "s3", UserID: "bob"}, time.Hour)
+
+	if err := store.DeleteUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"s1", "s2"} {
+		if _, err := store.Load(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: load after DeleteUser: err = %v, want ErrNotFound", id, err)
+		}
+	}
+	if _, err := store.Load(ctx, "s3"); err != nil {
+		t.Errorf("session of another user: %v, want it kept", err)
+	}
+}
+
+func TestFallbackStoreLeavesTheDatabaseAloneWhileHealthy(t *testing.T) {
+	store, _ := newFallbackStore(t)
+	ctx := t.Context()
+	// The first call answered reconciles what an earlier outage left behind
+	if _, err := store.Load(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatal(err)
+	}
+
+	// Without the session_records table every database call would fail
+	apptest.DB(t)
+	if err := store.Save(ctx, &Session{ID: "s1", Username: "alice"}, time.Hour); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if s, err := store.Load(ctx, "s1"); err != nil || s.Username != "alice" {
+		t.Fatalf("load = %v, %v, want the session", s, err)
+	}
+	if err := store.Delete(ctx, "s1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+}
+
+func TestFallbackStoreReconcilesAfterAnOutage(t *testing.T) {
+	store, primary := newFallbackStore(t)
+	ctx := t.Context()
+	store.Save(ctx, &Session{ID: "stale", Username: "alice"}, time.Hour)
+
+	primary.down = true
+	store.Save(ctx, &Session{ID: "s1", Username: "bob"}, time.Hour)
+	store.Delete(ctx, "stale")
+
+	primary.down = false
+	if _, err := store.Load(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatal(err)
+	}
+	if s, err := primary.Load(ctx, "s1"); err != nil || s.Username != "bob" {
+		t.Errorf("session saved during the outage = %v, %v, want it moved to the store", s, err)
+	}
+	if _, err := primary.Load(ctx, "stale"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("session deleted during the outage: err = %v, want it deleted from the store", err)
+	}
+	if records, err := store.Fallback.records(ctx); err != nil || len(records) != 0 {
+		t.Errorf("fallback rows after reconciling = %v, %v, want none", records, err)
+	}
+}
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"app/config"
+	"app/redisdb"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	CookieName = "session_id"
+	contextKey = "session"
+)
+
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Manager struct {
+	Store  Store
+	TTL    time.Duration
+	Secure bool
+}
+
+// NewManagerFromEnv stores sessions in redis when it is configured, and in the database while
+// redis is unreachable unless SESSION_DB_FALLBACK=false
+func NewManagerFromEnv() *Manager {
+	ttl := config.Duration("SESSION_TTL", 24*time.Hour)
+	var store Store
+	if redisdb.Client != nil {
+		store = &RedisStore{Client: redisdb.Client, Prefix: "session:"}
+		if config.Bool("SESSION_DB_FALLBACK", true) {
+			store = &FallbackStore{Primary: store, Fallback: &DBStore{}, TTL: ttl}
+		}
+	} else {
+		slog.Warn("redis is not configured, sessions are stored in memory and are not shared between replicas")
+		store = NewMemoryStore()
+	}
+
+	return &Manager{
+		Store:  store,
+		TTL:    ttl,
+		Secure: config.Bool("SESSION_COOKIE_SECURE", true),
+	}
+}
+
+// Middleware loads the session referenced by the cookie, if any, into the context
+func (m *Manager) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			cookie, err := ctx.Cookie(CookieName)
+			if err == nil && cookie.Value != "" {
+				s, err := m.Store.Load(ctx.Request().Context(), cookie.Value)
+				if err == nil {
+					ctx.Set(contextKey, s)
+				} else if !errors.Is(err, ErrNotFound) {
+					slog.Error("failed to load session", "error", err)
+				}
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// RequireLogin redirects anonymous visitors to the login page
+func (m *Manager) RequireLogin(loginPath string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if Get(ctx) == nil {
+				return ctx.Redirect(http.StatusSeeOther, loginPath)
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Get returns the current session or nil when the visitor is not logged in
+func Get(ctx echo.Context) *Session {
+	s, _ := ctx.Get(contextKey).(*Session)
+	return s
+}
+
+// Start creates a fresh session for the user, discarding any previous one to prevent fixation
+func (m *Manager) Start(ctx echo.Context, userID, username string) error {
+	if old := Get(ctx); old != nil {
+		_ = m.Store.Delete(ctx.Request().Context(), old.ID)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	s := &Session{ID: id, UserID: userID, Username: username, CreatedAt: time.Now()}
+	if err := m.Store.Save(ctx.Request().Context(), s, m.TTL); err != nil {
+		return err
+	}
+
+	ctx.SetCookie(m.cookie(id, int(m.TTL.Seconds())))
+	ctx.Set(contextKey, s)
+	return nil
+}
+
+// DestroyUser ends every session of the user, on every device
+func (m *Manager) DestroyUser(ctx context.Context, userID string) error {
+	return m.Store.DeleteUser(ctx, userID)
+}
+
+func (m *Manager) Destroy(ctx echo.Context) error {
+	if s := Get(ctx); s != nil {
+		if err := m.Store.Delete(ctx.Request().Context(), s.ID); err != nil {
+			return err
+		}
+	}
+	ctx.SetCookie(m.cookie("", -1))
+	ctx.Set(contextKey, nil)
+	return nil
+}
+
+func (m *Manager) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   m.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,132 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+type Store interface {
+	Load(ctx context.Context, id string) (*Session, error)
+	Save(ctx context.Context, s *Session, ttl time.Duration) error
+	Delete(ctx context.Context, id string) error
+	// DeleteUser removes every session of the user
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+// RedisStore shares sessions between every replica of the app. The ids of a user's sessions
+// are kept in a set under <Prefix>user:<user id> so DeleteUser can find them.
+type RedisStore struct {
+	Client *redis.Client
+	Prefix string
+}
+
+func (r *RedisStore) Load(ctx context.Context, id string) (*Session, error) {
+	raw, err := r.Client.Get(ctx, r.Prefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *RedisStore) Save(ctx context.Context, s *Session, ttl time.Duration) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.Prefix+s.ID, raw, ttl)
+		pipe.SAdd(ctx, r.userKey(s.UserID), s.ID)
+		pipe.Expire(ctx, r.userKey(s.UserID), ttl)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStore) userKey(userID string) string {
+	return r.Prefix + "user:" + userID
+}
+
+// Delete leaves the id in the user's set, DeleteUser deleting a missing session is harmless
+func (r *RedisStore) Delete(ctx context.Context, id string) error {
+	return r.Client.Del(ctx, r.Prefix+id).Err()
+}
+
+func (r *RedisStore) DeleteUser(ctx context.Context, userID string) error {
+	ids, err := r.Client.SMembers(ctx, r.userKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{r.userKey(userID)}
+	for _, id := range ids {
+		keys = append(keys, r.Prefix+id)
+	}
+	return r.Client.Del(ctx, keys...).Err()
+}
+
+// MemoryStore keeps sessions in the pod, so users are logged out whenever they hit another replica
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	session   Session
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]memoryEntry{}}
+}
+
+func (m *MemoryStore) Load(ctx context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.sessions[id]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(m.sessions, id)
+		return nil, ErrNotFound
+	}
+	s := entry.session
+	return &s, nil
+}
+
+func (m *MemoryStore) Save(ctx context.Context, s *Session, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = memoryEntry{session: *s, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemoryStore) DeleteUser(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, entry := range m.sessions {
+		if entry.session.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package shadow
+
+import (
+	"app/authheader"
+	"app/config"
+	"app/httpclient"
+	"app/metrics"
+	"app/runtimeutil"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Header marks a mirrored request, the shadow backend can tell them apart and they are never mirrored again
+const Header = "X-Shadow-Request"
+
+var (
+	mirrored = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "shadow",
+		Name:      "requests_total",
+		Help:      "Requests mirrored to the shadow backend by result: match, status_mismatch, error or dropped when too many were in flight.",
+	}, []string{"result"})
+
+	duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "shadow",
+		Name:      "duration_seconds",
+		Help:      "Latency of the mirrored requests on the primary and on the shadow backend.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"backend"})
+
+	statusDiffs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "shadow",
+		Name:      "status_diffs_total",
+		Help:      "Mirrored requests answered with a different status class, by the primary's and the shadow's.",
+	}, []string{"primary", "shadow"})
+)
+
+type Config struct {
+	// Target is the base URL of the shadow backend such as http://app-v2:8080, empty disables mirroring
+	Target       string
+	Percent      float64
+	Methods      []string
+	Paths        []string
+	Timeout      time.Duration
+	MaxInFlight  int
+	MaxBodyBytes int
+	KeepAuth     bool
+}
+
+func ConfigFromEnv() Config {
+	methods := config.List("SHADOW_METHODS")
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+	return Config{
+		Target:       strings.TrimSuffix(config.String("SHADOW_TARGET", ""), "/"),
+		Percent:      config.Float("SHADOW_PERCENT", 10),
+		Methods:      methods,
+		Paths:        config.List("SHADOW_PATHS"),
+		Timeout:      config.Duration("SHADOW_TIMEOUT", 5*time.Second),
+		MaxInFlight:  config.Int("SHADOW_MAX_IN_FLIGHT", 50),
+		MaxBodyBytes: config.Int("SHADOW_MAX_BODY_BYTES", 1<<20),
+		KeepAuth:     config.Bool("SHADOW_KEEP_AUTH", false),
+	}
+}
+
+// Mirror sends a share of the requests to a second backend after the primary answered them, for
+// dark launching a version: its responses are thrown away, only their status and latency are
+// compared with the primary's in the app_shadow metrics.
+type Mirror struct {
+	Config Config
+	client *http.Client
+	slots  chan struct{}
+}
+
+// New returns nil when no target is configured
+func New(cfg Config) *Mirror {
+	if cfg.Target == "" || cfg.Percent <= 0 {
+		return nil
+	}
+	slog.Warn("shadow traffic is enabled", "target", cfg.Target, "percent", cfg.Percent, "methods", cfg.Methods)
+	return &Mirror{
+		Config: cfg,
+		client: httpclient.New(cfg.Timeout),
+		slots:  make(chan struct{}, max(cfg.MaxInFlight, 1)),
+	}
+}
+
+func (m *Mirror) matches(req *http.Request) bool {
+	if req.Header.Get(Header) != "" || !slices.Contains(m.Config.Methods, req.Method) {
+		return false
+	}
+	if len(m.Config.Paths) > 0 && !slices.ContainsFunc(m.Config.Paths, func(prefix string) bool {
+		return strings.HasPrefix(req.URL.Path, prefix)
+	}) {
+		return false
+	}
+	return rand.Float64()*100 < m.Config.Percent
+}
+
+// Middleware mirrors the sampled requests once the handler returned. A body is only mirrored when
+// it fits in SHADOW_MAX_BODY_BYTES, the request is not mirrored otherwise.
+func (m *Mirror) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			if !m.matches(req) {
+				return next(ctx)
+			}
+
+			var body []byte
+			if req.Body != nil && req.Body != http.NoBody {
+				read, err := io.ReadAll(io.LimitReader(req.Body, int64(m.Config.MaxBodyBytes)+1))
+				if err != nil {
+					return err
+				}
+				req.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(read), req.Body), req.Body}
+				body = read
+			}
+			shadowReq := m.request(req, body)
+
+			start := time.Now()
+			err := next(ctx)
+			elapsed := time.Since(start)
+			if shadowReq == nil || len(body) > m.Config.MaxBodyBytes {
+				return err
+			}
+
+			select {
+			case m.slots <- struct{}{}:
+			default:
+				mirrored.WithLabelValues("dropped").Inc()
+				return err
+			}
+			status := metrics.ResponseStatus(ctx, err)
+			route := ctx.Path()
+			runtimeutil.Go("shadow request", func() {
+				defer func() { <-m.slots }()
+				m.send(shadowReq, route, status, elapsed)
+			})
+			return err
+		}
+	}
+}
+
+// request copies req for the shadow backend. Its context keeps the trace but not the cancellation,
+// the mirror is sent after the primary response.
+func (m *Mirror) request(req *http.Request, body []byte) *http.Request {
+	ctx := context.WithoutCancel(req.Context())
+	shadowReq, err := http.NewRequestWithContext(ctx, req.Method, m.Config.Target+req.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		slog.Error("failed to build shadow request", "error", err)
+		return nil
+	}
+	shadowReq.Header = req.Header.Clone()
+	if !m.Config.KeepAuth {
+		for _, name := range authheader.Names {
+			shadowReq.Header.Del(name)
+		}
+	}
+	shadowReq.Header.Set(Header, "1")
+	return shadowReq
+}
+
+func (m *Mirror) send(req *http.Request, route string, primaryStatus int, primaryElapsed time.Duration) {
+	start := time.Now()
+	res, err := m.client.Do(req)
+	if err != nil {
+		mirrored.WithLabelValues("error").Inc()
+		slog.Debug("shadow request failed", "method", req.Method, "route", route, "error", err)
+		return
+	}
+	// The body is read to the end so the latency covers the whole response
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	elapsed := time.Since(start)
+
+	duration.WithLabelValues("primary").Observe(primaryElapsed.Seconds())
+	duration.WithLabelValues("shadow").Observe(elapsed.Seconds())
+	primaryClass, shadowClass := statusClass(primaryStatus), statusClass(res.StatusCode)
+	if primaryClass == shadowClass {
+		mirrored.WithLabelValues("match").Inc()
+		return
+	}
+	mirrored.WithLabelValues("status_mismatch").Inc()
+	statusDiffs.WithLabelValues(primaryClass, shadowClass).Inc()
+	slog.Info("shadow backend answered differently", "method", req.Method, "route", route,
+		"primary_status", primaryStatus, "shadow_status", res.StatusCode,
+		"primary_ms", primaryElapsed.Milliseconds(), "shadow_ms", elapsed.Milliseconds())
+}
+
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
@@ -0,0 +1,114 @@
+package shadow
+
+import (
+	"app/apikey"
+	"app/serviceauth"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMirror(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(body)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	mirror := New(Config{
+		Target:       backend.URL,
+		Percent:      100,
+		Methods:      []string{http.MethodPost},
+		Timeout:      time.Second,
+		MaxInFlight:  1,
+		MaxBodyBytes: 1024,
+	})
+	e := echo.New()
+	e.Use(mirror.Middleware())
+	e.POST("/samples", func(ctx echo.Context) error {
+		body, _ := io.ReadAll(ctx.Request().Body)
+		return ctx.String(http.StatusCreated, string(body))
+	})
+
+	before := testutil.ToFloat64(statusDiffs.WithLabelValues("2xx", "5xx"))
+	req := httptest.NewRequest(http.MethodPost, "/samples?x=1", strings.NewReader(`{"name":"a"}`))
+	req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+	req.Header.Set(apikey.Header, "key")
+	req.Header.Set(serviceauth.Header, "token")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"name":"a"}` {
+		t.Fatalf("primary answered %d %q", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case got := <-received:
+		if got.URL.RequestURI() != "/samples?x=1" || got.Header.Get(Header) == "" || got.Header.Get(echo.HeaderAuthorization) != "" ||
+			got.Header.Get(apikey.Header) != "" || got.Header.Get(serviceauth.Header) != "" {
+			t.Errorf("mirrored %s with headers %v", got.URL.RequestURI(), got.Header)
+		}
+		if body := <-bodies; body != `{"name":"a"}` {
+			t.Errorf("mirrored body %q", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("request was not mirrored")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(statusDiffs.WithLabelValues("2xx", "5xx")) == before {
+		if time.Now().After(deadline) {
+			t.Fatal("status difference was not counted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMirroredRequestsAreNotMirroredAgain(t *testing.T) {
+	mirror := New(Config{Target: "http://shadow.invalid", Percent: 100, Methods: []string{http.MethodGet}})
+	req := httptest.NewRequest(http.MethodGet, "/samples", nil)
+	if !mirror.matches(req) {
+		t.Fatal("GET was not sampled at 100%")
+	}
+	req.Header.Set(Header, "1")
+	if mirror.matches(req) {
+		t.Error("a mirrored request was mirrored again")
+	}
+	if mirror.matches(httptest.NewRequest(http.MethodDelete, "/samples", nil)) {
+		t.Error("DELETE was mirrored without being configured")
+	}
+}
+
+func TestErrorStatusIsCompared(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer backend.Close()
+
+	mirror := New(Config{Target: backend.URL, Percent: 100, Methods: []string{http.MethodGet}, Timeout: time.Second, MaxInFlight: 1})
+	e := echo.New()
+	e.Use(mirror.Middleware())
+	e.GET("/samples/:id", func(ctx echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "sample not found")
+	})
+
+	before := testutil.ToFloat64(mirrored.WithLabelValues("match"))
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/samples/1", nil))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(mirrored.WithLabelValues("match")) == before {
+		if time.Now().After(deadline) {
+			t.Fatal("the 404 returned as an error did not match the shadow's 404")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,342 @@
+// Package simulate serves /simulate, a downstream that misbehaves on purpose: it answers late by a
+// latency distribution, fails with error codes and resets connections at configured rates. Deploy
+// the app a second time with SIMULATE_ENABLED=true and point the first one at it to practice tuning
+// retries, timeouts, probes and circuit breakers.
+package simulate
+
+import (
+	"app/binder"
+	"app/config"
+	"app/metrics"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// maxLatency caps every drawn latency, so no request holds a connection for long
+var maxLatency = config.Duration("SIMULATE_MAX_LATENCY", time.Minute)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "simulate",
+		Name:      "requests_total",
+		Help:      "Simulated requests by endpoint (call or healthz) and outcome: ok, error, reset or canceled.",
+	}, []string{"endpoint", "outcome"})
+
+	injectedLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "simulate",
+		Name:      "injected_latency_seconds",
+		Help:      "Latency drawn for simulated requests by endpoint.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"endpoint"})
+)
+
+// Distributions of the latency around Profile.Latency
+const (
+	// Fixed answers after exactly Latency
+	Fixed = "fixed"
+	// Uniform adds up to Jitter either way
+	Uniform = "uniform"
+	// Normal adds a normal deviation of Jitter
+	Normal = "normal"
+	// Exponential adds an exponential tail with a mean of Jitter, like a queue behind the downstream
+	Exponential = "exponential"
+)
+
+// Profile is how the downstream misbehaves. Every field is also a query parameter of the same name
+// overriding it for one request, such as /simulate/call?error_rate=0.5&latency=200ms.
+type Profile struct {
+	Distribution string        `query:"distribution" oneof:"fixed|uniform|normal|exponential"`
+	Latency      time.Duration `query:"latency"`
+	Jitter       time.Duration `query:"jitter"`
+	// SlowRate of the requests take SlowLatency instead, the far tail a timeout has to cut off
+	SlowRate    float64       `query:"slow_rate" min:"0" max:"1"`
+	SlowLatency time.Duration `query:"slow_latency"`
+	// ErrorRate of the requests fail with one of ErrorCodes, comma separated, after their latency
+	ErrorRate  float64 `query:"error_rate" min:"0" max:"1"`
+	ErrorCodes string  `query:"error_codes"`
+	// ResetRate of the requests have their connection reset instead of an answer
+	ResetRate float64 `query:"reset_rate" min:"0" max:"1"`
+	// HealthFailureRate of the probes of /simulate/healthz answer 503
+	HealthFailureRate float64 `query:"health_failure_rate" min:"0" max:"1"`
+}
+
+// ProfileFromEnv reads the profile the pod starts with from SIMULATE_*. It behaves by default.
+func ProfileFromEnv() (Profile, error) {
+	p := Profile{
+		Distribution:      config.String("SIMULATE_DISTRIBUTION", Fixed),
+		Latency:           config.Duration("SIMULATE_LATENCY", 0),
+		Jitter:            config.Duration("SIMULATE_JITTER", 0),
+		SlowRate:          config.Float("SIMULATE_SLOW_RATE", 0),
+		SlowLatency:       config.Duration("SIMULATE_SLOW_LATENCY", 5*time.Second),
+		ErrorRate:         config.Float("SIMULATE_ERROR_RATE", 0),
+		ErrorCodes:        config.String("SIMULATE_ERROR_CODES", "500,502,503"),
+		ResetRate:         config.Float("SIMULATE_RESET_RATE", 0),
+		HealthFailureRate: config.Float("SIMULATE_HEALTH_FAILURE_RATE", 0),
+	}
+	return p, p.validate()
+}
+
+func (p Profile) validate() error {
+	switch p.Distribution {
+	case Fixed, Uniform, Normal, Exponential:
+	default:
+		return fmt.Errorf("distribution must be one of fixed, uniform, normal, exponential")
+	}
+	for name, d := range map[string]time.Duration{"latency": p.Latency, "jitter": p.Jitter, "slow_latency": p.SlowLatency} {
+		if d < 0 || d > maxLatency {
+			return fmt.Errorf("%s must be between 0 and %s", name, maxLatency)
+		}
+	}
+	for name, rate := range map[string]float64{"slow_rate": p.SlowRate, "error_rate": p.ErrorRate, "reset_rate": p.ResetRate, "health_failure_rate": p.HealthFailureRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1", name)
+		}
+	}
+	if _, err := p.codes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p Profile) codes() ([]int, error) {
+	var codes []int
+	for _, raw := range strings.Split(p.ErrorCodes, ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		code, err := strconv.Atoi(raw)
+		if err != nil || code < 400 || code > 599 {
+			return nil, fmt.Errorf("error_codes must be status codes between 400 and 599, got %q", raw)
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("error_codes must name at least one status code")
+	}
+	return codes, nil
+}
+
+// delay draws the latency of one request, never below zero nor above maxLatency
+func (p Profile) delay() time.Duration {
+	if rand.Float64() < p.SlowRate {
+		return min(p.SlowLatency, maxLatency)
+	}
+	d := p.Latency
+	jitter := float64(p.Jitter)
+	switch p.Distribution {
+	case Uniform:
+		d += time.Duration((rand.Float64()*2 - 1) * jitter)
+	case Normal:
+		d += time.Duration(rand.NormFloat64() * jitter)
+	case Exponential:
+		d += time.Duration(rand.ExpFloat64() * jitter)
+	}
+	return min(max(d, 0), maxLatency)
+}
+
+// profileJSON spells the durations like the environment, 250ms instead of nanoseconds
+type profileJSON struct {
+	Distribution      string  `json:"distribution"`
+	Latency           string  `json:"latency"`
+	Jitter            string  `json:"jitter"`
+	SlowRate          float64 `json:"slow_rate"`
+	SlowLatency       string  `json:"slow_latency"`
+	ErrorRate         float64 `json:"error_rate"`
+	ErrorCodes        string  `json:"error_codes"`
+	ResetRate         float64 `json:"reset_rate"`
+	HealthFailureRate float64 `json:"health_failure_rate"`
+}
+
+func (p Profile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(profileJSON{
+		Distribution:      p.Distribution,
+		Latency:           p.Latency.String(),
+		Jitter:            p.Jitter.String(),
+		SlowRate:          p.SlowRate,
+		SlowLatency:       p.SlowLatency.String(),
+		ErrorRate:         p.ErrorRate,
+		ErrorCodes:        p.ErrorCodes,
+		ResetRate:         p.ResetRate,
+		HealthFailureRate: p.HealthFailureRate,
+	})
+}
+
+// UnmarshalJSON changes only the fields present, so PUT {"error_rate": 0.2} keeps the rest
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	current, err := p.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var v profileJSON
+	if err := json.Unmarshal(current, &v); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	for field, raw := range map[*time.Duration]string{&p.Latency: v.Latency, &p.Jitter: v.Jitter, &p.SlowLatency: v.SlowLatency} {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		*field = d
+	}
+	p.Distribution = v.Distribution
+	p.SlowRate = v.SlowRate
+	p.ErrorRate = v.ErrorRate
+	p.ErrorCodes = v.ErrorCodes
+	p.ResetRate = v.ResetRate
+	p.HealthFailureRate = v.HealthFailureRate
+	return nil
+}
+
+// Simulator serves the /simulate endpoints with a profile that can be changed at runtime
+type Simulator struct {
+	initial Profile
+
+	mu      sync.Mutex
+	profile Profile
+}
+
+// New starts from profile, which PUT /simulate/profile changes and DELETE restores
+func New(profile Profile) *Simulator {
+	return &Simulator{initial: profile, profile: profile}
+}
+
+// Profile is the profile requests without query parameters get
+func (s *Simulator) Profile() Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profile
+}
+
+// request is the profile with the overrides of the query parameters
+func (s *Simulator) request(ctx echo.Context) (Profile, error) {
+	p := s.Profile()
+	if err := binder.Query(ctx, &p); err != nil {
+		return p, err
+	}
+	return p, p.validate()
+}
+
+// wait sleeps the drawn latency, false when the caller gave up first
+func (s *Simulator) wait(ctx echo.Context, endpoint string, p Profile) (time.Duration, bool) {
+	delay := p.delay()
+	injectedLatency.WithLabelValues(endpoint).Observe(delay.Seconds())
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, true
+	case <-ctx.Request().Context().Done():
+		requests.WithLabelValues(endpoint, "canceled").Inc()
+		return delay, false
+	}
+}
+
+// Call serves any method on /simulate/call, the request body is read and dropped. It answers 200
+// or a simulated error after the drawn latency, or resets the connection.
+func (s *Simulator) Call(ctx echo.Context) error {
+	p, err := s.request(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if _, err := io.Copy(io.Discard, ctx.Request().Body); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "failed to read the request body"})
+	}
+
+	delay, ok := s.wait(ctx, "call", p)
+	if !ok {
+		return nil
+	}
+	if rand.Float64() < p.ResetRate {
+		requests.WithLabelValues("call", "reset").Inc()
+		reset(ctx)
+		return nil
+	}
+	if rand.Float64() < p.ErrorRate {
+		requests.WithLabelValues("call", "error").Inc()
+		codes, _ := p.codes()
+		code := codes[rand.IntN(len(codes))]
+		if code == http.StatusServiceUnavailable || code == http.StatusTooManyRequests {
+			// For practicing retries that honor it
+			ctx.Response().Header().Set("Retry-After", "1")
+		}
+		return ctx.JSON(code, map[string]any{"error": "simulated failure", "latency_ms": delay.Milliseconds()})
+	}
+	requests.WithLabelValues("call", "ok").Inc()
+	return ctx.JSON(http.StatusOK, map[string]any{"outcome": "ok", "latency_ms": delay.Milliseconds()})
+}
+
+// Health serves GET /simulate/healthz for the probes of a simulated deployment: it answers after
+// the drawn latency, probe timeouts apply, and fails with 503 at HealthFailureRate
+func (s *Simulator) Health(ctx echo.Context) error {
+	p, err := s.request(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if _, ok := s.wait(ctx, "healthz", p); !ok {
+		return nil
+	}
+	if rand.Float64() < p.HealthFailureRate {
+		requests.WithLabelValues("healthz", "error").Inc()
+		return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"status": "unhealthy", "error": "simulated probe failure"})
+	}
+	requests.WithLabelValues("healthz", "ok").Inc()
+	return ctx.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ProfileHandler reports the profile on GET, changes the fields of the body on PUT and restores the
+// profile the pod started with on DELETE. Like the read-only toggle it is per pod.
+func (s *Simulator) ProfileHandler(ctx echo.Context) error {
+	switch ctx.Request().Method {
+	case http.MethodPut:
+		p := s.Profile()
+		if err := json.NewDecoder(ctx.Request().Body).Decode(&p); err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "body must be a JSON object of profile fields", "details": err.Error()})
+		}
+		if err := p.validate(); err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		s.set(p)
+	case http.MethodDelete:
+		s.set(s.initial)
+	}
+	return ctx.JSON(http.StatusOK, s.Profile())
+}
+
+func (s *Simulator) set(p Profile) {
+	s.mu.Lock()
+	s.profile = p
+	s.mu.Unlock()
+	slog.Warn("simulated downstream profile changed", "profile", p)
+}
+
+// reset takes the connection over and closes it with an RST, which the caller sees as connection
+// reset by peer. HTTP/2 connections cannot be taken over, the stream is aborted instead.
+func reset(ctx echo.Context) {
+	conn, _, err := http.NewResponseController(ctx.Response()).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,161 @@
+package simulate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func serve(t *testing.T, profile Profile) (*Simulator, *httptest.Server) {
+	t.Helper()
+	simulator := New(profile)
+	router := echo.New()
+	router.GET("/simulate/call", simulator.Call)
+	router.GET("/simulate/healthz", simulator.Health)
+	router.GET("/simulate/profile", simulator.ProfileHandler)
+	router.PUT("/simulate/profile", simulator.ProfileHandler)
+	router.DELETE("/simulate/profile", simulator.ProfileHandler)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return simulator, server
+}
+
+func healthy() Profile {
+	return Profile{Distribution: Fixed, SlowLatency: 5 * time.Second, ErrorCodes: "500,502,503"}
+}
+
+func TestCall(t *testing.T) {
+	_, server := serve(t, healthy())
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"healthy", "", http.StatusOK},
+		{"always failing", "?error_rate=1&error_codes=503", http.StatusServiceUnavailable},
+		{"unknown distribution", "?distribution=pareto", http.StatusBadRequest},
+		{"rate above one", "?error_rate=2", http.StatusBadRequest},
+		{"invalid error code", "?error_rate=1&error_codes=200", http.StatusBadRequest},
+		{"latency above the cap", "?latency=2h", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Get(server.URL + "/simulate/call" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.status)
+			}
+			if tt.status == http.StatusServiceUnavailable && res.Header.Get("Retry-After") == "" {
+				t.Error("503 without Retry-After")
+			}
+		})
+	}
+}
+
+func TestCallWaitsForTheLatency(t *testing.T) {
+	_, server := serve(t, healthy())
+	started := time.Now()
+	res, err := http.Get(server.URL + "/simulate/call?latency=50ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if elapsed := time.Since(started); elapsed < 50*time.Millisecond {
+		t.Errorf("answered after %s, want at least 50ms", elapsed)
+	}
+}
+
+func TestCallResetsTheConnection(t *testing.T) {
+	_, server := serve(t, healthy())
+	res, err := http.Get(server.URL + "/simulate/call?reset_rate=1")
+	if err == nil {
+		res.Body.Close()
+		t.Fatalf("got status %d, want the connection reset", res.StatusCode)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	_, server := serve(t, healthy())
+	for query, want := range map[string]int{"": http.StatusOK, "?health_failure_rate=1": http.StatusServiceUnavailable} {
+		res, err := http.Get(server.URL + "/simulate/healthz" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Errorf("%q: status = %d, want %d", query, res.StatusCode, want)
+		}
+	}
+}
+
+func TestProfileHandler(t *testing.T) {
+	simulator, server := serve(t, healthy())
+	do := func(method, body string) Profile {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+"/simulate/profile", strings.NewReader(body))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status = %d", method, res.StatusCode)
+		}
+		var p Profile
+		if err := json.NewDecoder(res.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	changed := do(http.MethodPut, `{"error_rate":0.25,"latency":"200ms"}`)
+	if changed.ErrorRate != 0.25 || changed.Latency != 200*time.Millisecond || changed.ErrorCodes != "500,502,503" {
+		t.Errorf("changed profile = %+v, want error_rate and latency changed and the rest kept", changed)
+	}
+	if simulator.Profile() != changed {
+		t.Errorf("simulator profile = %+v, want %+v", simulator.Profile(), changed)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/simulate/profile", strings.NewReader(`{"reset_rate":3}`))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest || simulator.Profile() != changed {
+		t.Errorf("invalid profile: status = %d, profile = %+v", res.StatusCode, simulator.Profile())
+	}
+
+	if restored := do(http.MethodDelete, ""); restored != healthy() {
+		t.Errorf("restored profile = %+v, want %+v", restored, healthy())
+	}
+}
+
+func TestDelayDistributions(t *testing.T) {
+	tests := []struct {
+		profile  Profile
+		low, top time.Duration
+	}{
+		{Profile{Distribution: Fixed, Latency: 100 * time.Millisecond, Jitter: time.Second}, 100 * time.Millisecond, 100 * time.Millisecond},
+		{Profile{Distribution: Uniform, Latency: 100 * time.Millisecond, Jitter: 50 * time.Millisecond}, 50 * time.Millisecond, 150 * time.Millisecond},
+		{Profile{Distribution: Exponential, Latency: 100 * time.Millisecond, Jitter: 10 * time.Millisecond}, 100 * time.Millisecond, maxLatency},
+		{Profile{Distribution: Normal, Jitter: time.Hour}, 0, maxLatency},
+		{Profile{Distribution: Fixed, SlowRate: 1, SlowLatency: 3 * time.Second}, 3 * time.Second, 3 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 1000 {
+			if d := tt.profile.delay(); d < tt.low || d > tt.top {
+				t.Fatalf("%s: delay %s outside [%s, %s]", tt.profile.Distribution, d, tt.low, tt.top)
+			}
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package slo
+
+import (
+	"app/config"
+	"app/metrics"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Objective is the target for one route. Availability is the fraction of requests
+// that must not fail with 5xx, Latency is the fraction that must finish within Threshold.
+type Objective struct {
+	Availability float64       `json:"availability"`
+	Latency      float64       `json:"latency"`
+	Threshold    time.Duration `json:"-"`
+}
+
+// Config maps "METHOD /route" to its objective, routes not listed use Default
+type Config struct {
+	Default Objective
+	Routes  map[string]Objective
+}
+
+// ConfigFromEnv reads SLO_DEFAULT and SLO_ROUTES, both written as availability:latency:threshold.
+// e.g. SLO_ROUTES="GET /sample=0.999:0.99:200ms,POST /sample=0.995:0.95:500ms"
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Default: Objective{Availability: 0.999, Latency: 0.99, Threshold: 300 * time.Millisecond},
+		Routes:  map[string]Objective{},
+	}
+	if value := config.String("SLO_DEFAULT", ""); value != "" {
+		objective, err := ParseObjective(value)
+		if err != nil {
+			slog.Warn("invalid SLO_DEFAULT, using default", "value", value, "error", err)
+		} else {
+			cfg.Default = objective
+		}
+	}
+	for _, item := range config.List("SLO_ROUTES") {
+		route, value, ok := strings.Cut(item, "=")
+		objective, err := ParseObjective(value)
+		if !ok || err != nil {
+			slog.Warn("invalid SLO_ROUTES entry, skipping", "entry", item, "error", err)
+			continue
+		}
+		cfg.Routes[strings.TrimSpace(route)] = objective
+	}
+	return cfg
+}
+
+func ParseObjective(value string) (Objective, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 3 {
+		return Objective{}, errors.New("expected availability:latency:threshold")
+	}
+	availability, err := parseRatio(parts[0])
+	if err != nil {
+		return Objective{}, err
+	}
+	latency, err := parseRatio(parts[1])
+	if err != nil {
+		return Objective{}, err
+	}
+	threshold, err := time.ParseDuration(parts[2])
+	if err != nil {
+		return Objective{}, err
+	}
+	return Objective{Availability: availability, Latency: latency, Threshold: threshold}, nil
+}
+
+func parseRatio(value string) (float64, error) {
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if ratio <= 0 || ratio >= 1 {
+		return 0, fmt.Errorf("objective %v must be between 0 and 1", ratio)
+	}
+	return ratio, nil
+}
+
+var (
+	// Burn rate is computed in PromQL as rate(errors)/rate(requests) divided by (1 - objective)
+	sloRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "slo",
+		Name:      "requests_total",
+		Help:      "Requests counted towards the route SLOs.",
+	}, []string{"route"})
+
+	sloErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "slo",
+		Name:      "errors_total",
+		Help:      "Requests that failed the availability SLI.",
+	}, []string{"route"})
+
+	sloSlow = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "slo",
+		Name:      "slow_requests_total",
+		Help:      "Requests that failed the latency SLI.",
+	}, []string{"route"})
+
+	sloObjective = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "slo",
+		Name:      "objective_ratio",
+		Help:      "Configured objective per route and SLI.",
+	}, []string{"route", "sli"})
+
+	sloThreshold = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "slo",
+		Name:      "latency_threshold_seconds",
+		Help:      "Latency threshold of the latency SLI per route.",
+	}, []string{"route"})
+)
+
+type counts struct {
+	requests int64
+	errors   int64
+	slow     int64
+}
+
+// Tracker keeps in-process SLI counters next to the Prometheus ones for the /slo summary
+type Tracker struct {
+	Config  Config
+	started time.Time
+
+	mu     sync.Mutex
+	routes map[string]*counts
+}
+
+func New(cfg Config) *Tracker {
+	return &Tracker{Config: cfg, started: time.Now(), routes: map[string]*counts{}}
+}
+
+func (t *Tracker) Objective(route string) Objective {
+	if objective, ok := t.Config.Routes[route]; ok {
+		return objective
+	}
+	return t.Config.Default
+}
+
+func (t *Tracker) Record(route string, status int, elapsed time.Duration) {
+	objective := t.Objective(route)
+	failed := status >= http.StatusInternalServerError
+	slow := elapsed > objective.Threshold
+
+	t.mu.Lock()
+	c, ok := t.routes[route]
+	if !ok {
+		c = &counts{}
+		t.routes[route] = c
+		sloObjective.WithLabelValues(route, "availability").Set(objective.Availability)
+		sloObjective.WithLabelValues(route, "latency").Set(objective.Latency)
+		sloThreshold.WithLabelValues(route).Set(objective.Threshold.Seconds())
+	}
+	c.requests++
+	if failed {
+		c.errors++
+	}
+	if slow {
+		c.slow++
+	}
+	t.mu.Unlock()
+
+	sloRequests.WithLabelValues(route).Inc()
+	if failed {
+		sloErrors.WithLabelValues(route).Inc()
+	}
+	if slow {
+		sloSlow.WithLabelValues(route).Inc()
+	}
+}
+
+// Middleware records the SLIs of every matched route, unmatched paths do not count towards any SLO
+func (t *Tracker) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			started := time.Now()
+			err := next(ctx)
+
+			if ctx.Path() == "" {
+				return err
+			}
+			t.Record(ctx.Request().Method+" "+ctx.Path(), metrics.ResponseStatus(ctx, err), time.Since(started))
+			return err
+		}
+	}
+}
+
+type SLI struct {
+	Objective float64 `json:"objective"`
+	Actual    float64 `json:"actual"`
+	Bad       int64   `json:"bad"`
+	// BudgetRemaining is the share of the error budget left, negative once it is exhausted
+	BudgetRemaining float64 `json:"budget_remaining"`
+}
+
+type RouteSummary struct {
+	Route        string `json:"route"`
+	Requests     int64  `json:"requests"`
+	Availability SLI    `json:"availability"`
+	Latency      SLI    `json:"latency"`
+	ThresholdMs  int64  `json:"latency_threshold_ms"`
+	Met          bool   `json:"met"`
+}
+
+type Summary struct {
+	Since  time.Time      `json:"since"`
+	Routes []RouteSummary `json:"routes"`
+}
+
+func newSLI(objective float64, requests, bad int64) SLI {
+	sli := SLI{Objective: objective, Actual: 1, Bad: bad, BudgetRemaining: 1}
+	if requests > 0 {
+		badRatio := float64(bad) / float64(requests)
+		sli.Actual = 1 - badRatio
+		sli.BudgetRemaining = 1 - badRatio/(1-objective)
+	}
+	return sli
+}
+
+// Summary reports the SLIs since the process started
+func (t *Tracker) Summary() Summary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	summary := Summary{Since: t.started, Routes: make([]RouteSummary, 0, len(t.routes))}
+	for route, c := range t.routes {
+		objective := t.Objective(route)
+		availability := newSLI(objective.Availability, c.requests, c.errors)
+		latency := newSLI(objective.Latency, c.requests, c.slow)
+		summary.Routes = append(summary.Routes, RouteSummary{
+			Route:        route,
+			Requests:     c.requests,
+			Availability: availability,
+			Latency:      latency,
+			ThresholdMs:  objective.Threshold.Milliseconds(),
+			Met:          availability.Actual >= availability.Objective && latency.Actual >= latency.Objective,
+		})
+	}
+	sort.Slice(summary.Routes, func(i, j int) bool { return summary.Routes[i].Route < summary.Routes[j].Route })
+	return summary
+}
+
+func (t *Tracker) Handler() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, t.Summary())
+	}
+}
@@ -0,0 +1,63 @@
+package static
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed all:dist
+var distFS embed.FS
+
+// Vite emits content-hashed file names under assets/, so those can be cached forever
+const assetsDir = "assets/"
+
+// Handler serves the embedded SPA mounted under prefix.
+// Unknown paths without a file extension fall back to index.html for history-mode routing.
+func Handler(prefix string) echo.HandlerFunc {
+	dist, err := fs.Sub(distFS, "dist")
+	if err != nil {
+		panic(err)
+	}
+	fileServer := http.FileServer(http.FS(dist))
+
+	return func(ctx echo.Context) error {
+		name := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(ctx.Request().URL.Path, prefix)), "/")
+
+		if name == "" || name == "index.html" {
+			return serveIndex(ctx, dist)
+		}
+
+		if info, err := fs.Stat(dist, name); err != nil || info.IsDir() {
+			if path.Ext(name) != "" {
+				return echo.ErrNotFound
+			}
+			return serveIndex(ctx, dist)
+		}
+
+		if strings.HasPrefix(name, assetsDir) {
+			ctx.Response().Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			ctx.Response().Header().Set("Cache-Control", "public, max-age=3600")
+		}
+
+		req := ctx.Request().Clone(ctx.Request().Context())
+		req.URL.Path = "/" + name
+		fileServer.ServeHTTP(ctx.Response(), req)
+		return nil
+	}
+}
+
+// index.html must always be revalidated so new deployments pick up new asset hashes
+func serveIndex(ctx echo.Context, dist fs.FS) error {
+	index, err := fs.ReadFile(dist, "index.html")
+	if err != nil {
+		return echo.ErrNotFound
+	}
+	ctx.Response().Header().Set("Cache-Control", "no-cache")
+	return ctx.HTMLBlob(http.StatusOK, index)
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"app/config"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 works with AWS S3 and S3 compatible stores such as MinIO
+type S3 struct {
+	Endpoint string
+	Bucket   string
+	client   *minio.Client
+}
+
+func NewS3FromEnv() (*S3, error) {
+	endpoint := config.String("STORAGE_S3_ENDPOINT", "")
+	bucket := config.String("STORAGE_S3_BUCKET", "")
+	if endpoint == "" || bucket == "" {
+		return nil, errors.New("STORAGE_S3_ENDPOINT and STORAGE_S3_BUCKET must be set")
+	}
+
+	// Static keys when given, otherwise the IAM / IRSA credential chain
+	var creds *credentials.Credentials
+	if accessKey := config.String("STORAGE_S3_ACCESS_KEY", ""); accessKey != "" {
+		creds = credentials.NewStaticV4(accessKey, config.String("STORAGE_S3_SECRET_KEY", ""), "")
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.IAM{},
+		})
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: config.Bool("STORAGE_S3_SECURE", true),
+		Region: config.String("STORAGE_S3_REGION", ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3{Endpoint: endpoint, Bucket: bucket, client: client}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.Bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	obj, err := s.client.GetObject(ctx, s.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, Object{}, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, Object{}, ErrNotFound
+		}
+		return nil, Object{}, err
+	}
+	return obj, Object{Key: key, Size: info.Size, ModifiedAt: info.LastModified}, nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	for info := range s.client.ListObjects(ctx, s.Bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		objects = append(objects, Object{Key: info.Key, Size: info.Size, ModifiedAt: info.LastModified})
+	}
+	return objects, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.Bucket, key, minio.RemoveObjectOptions{})
+}
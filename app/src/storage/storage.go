@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"app/apperrors"
+	"app/config"
+	"app/health"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+var ErrNotFound = apperrors.New(apperrors.NotFound, "object not found")
+
+type Object struct {
+	Key        string    `json:"key"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// Storage is the object storage used for backups, exports and uploaded files
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, Object, error)
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Default is the configured backend, set by Init
+var Default Storage
+
+// Init selects the backend with STORAGE_BACKEND (local or s3)
+func Init() error {
+	backend := config.String("STORAGE_BACKEND", "local")
+	switch backend {
+	case "local":
+		dir := config.String("STORAGE_DIR", "./data/storage")
+		local, err := NewLocal(dir)
+		if err != nil {
+			return err
+		}
+		Default = local
+		slog.Info("using local object storage", "dir", dir)
+	case "s3":
+		s3, err := NewS3FromEnv()
+		if err != nil {
+			return err
+		}
+		Default = s3
+		slog.Info("using s3 object storage", "endpoint", s3.Endpoint, "bucket", s3.Bucket)
+	default:
+		return fmt.Errorf("unsupported STORAGE_BACKEND %q", backend)
+	}
+
+	health.Register(health.Check{
+		Name: "storage",
+		Run: func(ctx context.Context) error {
+			_, err := Default.List(ctx, "health/")
+			return err
+		},
+	})
+	return nil
+}
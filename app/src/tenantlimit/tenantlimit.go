@@ -0,0 +1,94 @@
+package tenantlimit
+
+import (
+	"app/baggage"
+	"app/config"
+	"app/metrics"
+	"app/service"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "tenant",
+		Name:      "requests_total",
+		Help:      "HTTP requests by tenant of the request baggage, none or unknown, and status code.",
+	}, []string{"tenant", "code"})
+
+	rejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "tenant",
+		Name:      "rejections_total",
+		Help:      "Requests rejected by tenant and reason: rate_limit, quota or unknown.",
+	}, []string{"tenant", "reason"})
+
+	// The busiest tenants get their own series, the rest are counted as other
+	tenants = metrics.NewLabelGuard("tenant", config.Int("TENANT_METRICS_MAX_TENANTS", 50), "other")
+)
+
+// Middleware applies the rate limit and daily quota of the tenant named by the request baggage,
+// answering 429 with Retry-After over either, and counts its requests. A tenant without a
+// TenantLimit is answered 403, and requests without a tenant share the defaults. It must run after
+// the baggage middleware.
+func Middleware(limits *service.TenantService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if exempt(ctx.Request().URL.Path) {
+				return next(ctx)
+			}
+			tenant := baggage.Get(ctx).Tenant
+
+			now := time.Now()
+			decision, err := limits.Allow(ctx.Request().Context(), tenant, now)
+			if errors.Is(err, service.ErrUnknownTenant) {
+				// Made up tenants would each take a series of their own
+				rejections.WithLabelValues("unknown", "unknown").Inc()
+				requests.WithLabelValues("unknown", strconv.Itoa(http.StatusForbidden)).Inc()
+				return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+			label := "none"
+			if tenant != "" {
+				label = tenants.Value(tenant)
+			}
+
+			var reason string
+			switch {
+			case errors.Is(err, service.ErrTenantRateLimited):
+				reason = "rate_limit"
+			case errors.Is(err, service.ErrTenantQuotaExceeded):
+				reason = "quota"
+			case err != nil:
+				// Like api key metering, a failure to count does not reject the request
+				slog.Error("failed to count tenant usage", "tenant", tenant, "error", err)
+			}
+			if reason != "" {
+				rejections.WithLabelValues(label, reason).Inc()
+				requests.WithLabelValues(label, strconv.Itoa(http.StatusTooManyRequests)).Inc()
+				retryAfter := int64(math.Ceil(decision.RetryAfter.Seconds()))
+				ctx.Response().Header().Set("Retry-After", strconv.FormatInt(max(retryAfter, 1), 10))
+				return ctx.JSON(http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+			}
+
+			err = next(ctx)
+			requests.WithLabelValues(label, strconv.Itoa(metrics.ResponseStatus(ctx, err))).Inc()
+			return err
+		}
+	}
+}
+
+// exempt paths are called by the kubelet and Prometheus, which send no baggage and must not use
+// up the defaults of requests without a tenant. /simulate/healthz is the probe of a simulated
+// downstream.
+func exempt(path string) bool {
+	return path == "/healthz" || path == "/readyz" || path == "/startupz" || path == "/metrics" || path == "/simulate/healthz"
+}
@@ -0,0 +1,77 @@
+package tenantlimit
+
+import (
+	"app/apptest"
+	"app/model"
+	"app/service"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func newRouter(t *testing.T, limits *service.TenantService) *echo.Echo {
+	t.Helper()
+	router := echo.New()
+	// What the tracing middleware does with the baggage header
+	router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			ctx.SetRequest(req.WithContext(propagation.Baggage{}.Extract(req.Context(), propagation.HeaderCarrier(req.Header))))
+			return next(ctx)
+		}
+	})
+	router.Use(Middleware(limits))
+	ok := func(ctx echo.Context) error { return ctx.NoContent(http.StatusOK) }
+	router.GET("/samples", ok)
+	router.GET("/healthz", ok)
+	return router
+}
+
+func get(t *testing.T, router *echo.Echo, path, tenant string) int {
+	t.Helper()
+	var opts []apptest.RequestOption
+	if tenant != "" {
+		opts = append(opts, apptest.WithHeader("Baggage", "tenant="+tenant))
+	}
+	return apptest.Do(t, router, http.MethodGet, path, nil, opts...).Code
+}
+
+func TestMiddleware(t *testing.T) {
+	apptest.DB(t, &model.TenantLimit{}, &model.TenantUsage{})
+	apptest.Seed(t, &model.TenantLimit{Tenant: "acme", RequestsPerMinute: 1}, &model.TenantLimit{Tenant: "globex", DailyQuota: 1})
+	router := newRouter(t, &service.TenantService{DefaultRequestsPerMinute: 2, CacheTTL: time.Minute})
+
+	if code := get(t, router, "/samples", "acme"); code != http.StatusOK {
+		t.Fatalf("first request of acme = %d", code)
+	}
+	rec := apptest.Do(t, router, http.MethodGet, "/samples", nil, apptest.WithHeader("Baggage", "tenant=acme"))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("acme over its rate limit = %d with Retry-After %q, want 429", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if code := get(t, router, "/samples", "globex"); code != http.StatusOK {
+		t.Errorf("other tenant = %d, want its own limits", code)
+	}
+	if code := get(t, router, "/samples", "globex"); code != http.StatusTooManyRequests {
+		t.Errorf("globex over its daily quota = %d, want 429", code)
+	}
+
+	// Made up tenants get no limits of their own
+	for _, tenant := range []string{"initech", "random-1", "random-2"} {
+		if code := get(t, router, "/samples", tenant); code != http.StatusForbidden {
+			t.Errorf("unknown tenant %s = %d, want 403", tenant, code)
+		}
+	}
+
+	// Leaving the tenant out shares the defaults instead of skipping every limit
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := get(t, router, "/samples", ""); code != want {
+			t.Errorf("request %d without a tenant = %d, want %d", i, code, want)
+		}
+	}
+	if code := get(t, router, "/healthz", ""); code != http.StatusOK {
+		t.Errorf("probe past the defaults = %d, want probes exempt", code)
+	}
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"app/config"
+	"context"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifies the app in the tracing backend
+var ServiceName = config.String("OTEL_SERVICE_NAME", "k8s-sample-app")
+
+var enabled bool
+
+// Enabled reports whether spans are exported
+func Enabled() bool {
+	return enabled
+}
+
+// Tracer returns the app's tracer, a no-op tracer when tracing is disabled
+func Tracer() trace.Tracer {
+	return otel.Tracer("app")
+}
+
+// Init configures the OTLP/HTTP exporter from the standard OTEL_EXPORTER_OTLP_* variables.
+// W3C trace context and baggage are always propagated, even when nothing is exported.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	noop := func(context.Context) error { return nil }
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		slog.Info("OTEL_EXPORTER_OTLP_ENDPOINT is not set, tracing is disabled")
+		return noop, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return noop, err
+	}
+
+	hostname, _ := os.Hostname()
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+		semconv.ServiceInstanceID(hostname),
+	))
+	if err != nil {
+		return noop, err
+	}
+
+	// Sampling follows OTEL_TRACES_SAMPLER / OTEL_TRACES_SAMPLER_ARG
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	enabled = true
+
+	slog.Info("tracing is enabled", "service", ServiceName)
+	return provider.Shutdown, nil
+}
+
+// TraceID returns the sampled trace ID of ctx, or "" when the request is not traced
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// Carrier serializes the trace context of ctx for work that leaves the process or outlives the request
+func Carrier(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
+
+// LinkTo returns a link to the span serialized by Carrier. Deferred and retried work starts
+// its own trace and links back to the originator instead of becoming a late child of it.
+func LinkTo(carrier map[string]string) trace.Link {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(carrier))
+	return trace.LinkFromContext(ctx)
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,79 @@
+package views
+
+import (
+	"app/announcement"
+	"app/i18n"
+	"app/serving"
+	"app/session"
+	"embed"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Request scoped functions are swapped in at render time, these only make parsing succeed
+var placeholderFuncs = template.FuncMap{
+	"t":            func(string) string { return "" },
+	"lang":         func() string { return "" },
+	"csrf":         func() string { return "" },
+	"user":         func() *session.Session { return nil },
+	"announcement": announcement.Current,
+	"replica":      serving.Current,
+	"canary":       func() bool { return false },
+}
+
+// Renderer renders pages from the embedded templates.
+// Each page is parsed together with layout.html so pages only define their own blocks.
+type Renderer struct {
+	pages map[string]*template.Template
+}
+
+func NewRenderer() (*Renderer, error) {
+	pages := map[string]*template.Template{}
+
+	files, err := fs.Glob(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		name := path.Base(file)
+		if name == "layout.html" {
+			continue
+		}
+		tmpl, err := template.New(name).Funcs(placeholderFuncs).ParseFS(templateFS, "templates/layout.html", file)
+		if err != nil {
+			return nil, err
+		}
+		pages[name] = tmpl
+	}
+	return &Renderer{pages: pages}, nil
+}
+
+func (r *Renderer) Render(w io.Writer, name string, data any, ctx echo.Context) error {
+	tmpl, ok := r.pages[name]
+	if !ok {
+		return echo.NewHTTPError(500, "template not found: "+name)
+	}
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	tmpl.Funcs(template.FuncMap{
+		"t":    func(id string) string { return i18n.T(ctx, id) },
+		"lang": func() string { return i18n.Lang(ctx) },
+		"csrf": func() string {
+			token, _ := ctx.Get(middleware.DefaultCSRFConfig.ContextKey).(string)
+			return token
+		},
+		"user":   func() *session.Session { return session.Get(ctx) },
+		"canary": func() bool { return serving.IsCanary(ctx) },
+	})
+	return tmpl.ExecuteTemplate(w, "layout", data)
+}
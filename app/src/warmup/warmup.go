@@ -0,0 +1,155 @@
+package warmup
+
+import (
+	"app/runtimeutil"
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// ErrNotWarm fails the readiness check until the warm-up finished
+var ErrNotWarm = errors.New("caches are still warming up")
+
+// Task loads one cache, such as the first page of the sample listing
+type Task struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type TaskProgress struct {
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Progress is what /startupz reports
+type Progress struct {
+	Status     string                  `json:"status"`
+	Total      int                     `json:"total"`
+	Completed  int                     `json:"completed"`
+	StartedAt  *time.Time              `json:"started_at,omitempty"`
+	FinishedAt *time.Time              `json:"finished_at,omitempty"`
+	Tasks      map[string]TaskProgress `json:"tasks"`
+}
+
+// Warmer runs the tasks once when the pod starts, before it reports ready, so a freshly scaled
+// replica serves its first requests from caches instead of sending them all to the database at once.
+// A failed task does not keep the pod out of the Service, the cache it was loading fills on demand.
+type Warmer struct {
+	tasks   []Task
+	timeout time.Duration
+
+	mu       sync.Mutex
+	progress Progress
+}
+
+// New returns a Warmer giving the tasks timeout in total
+func New(timeout time.Duration, tasks ...Task) *Warmer {
+	w := &Warmer{tasks: tasks, timeout: timeout}
+	w.progress = Progress{Status: StatusPending, Total: len(tasks), Tasks: make(map[string]TaskProgress, len(tasks))}
+	for _, task := range tasks {
+		w.progress.Tasks[task.Name] = TaskProgress{Status: StatusPending}
+	}
+	return w
+}
+
+// Run runs the tasks concurrently and returns once every one finished or the timeout passed
+func (w *Warmer) Run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	started := time.Now()
+	w.mu.Lock()
+	w.progress.Status = StatusRunning
+	w.progress.StartedAt = &started
+	w.mu.Unlock()
+
+	finished := make(chan struct{}, len(w.tasks))
+	for _, task := range w.tasks {
+		runtimeutil.Go("cache warm-up "+task.Name, func() {
+			defer func() { finished <- struct{}{} }()
+			w.set(task.Name, TaskProgress{Status: StatusRunning})
+			taskStarted := time.Now()
+			err := task.Run(ctx)
+			progress := TaskProgress{Status: StatusDone, DurationMs: float64(time.Since(taskStarted).Microseconds()) / 1000}
+			if err != nil {
+				progress.Status, progress.Error = StatusFailed, err.Error()
+				slog.Warn("cache warm-up task failed", "task", task.Name, "error", err)
+			}
+			w.set(task.Name, progress)
+		})
+	}
+	// A task ignoring ctx keeps running, but no longer holds the pod back
+wait:
+	for range w.tasks {
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			slog.Warn("cache warm-up timed out", "timeout", w.timeout)
+			break wait
+		}
+	}
+
+	finishedAt := time.Now()
+	w.mu.Lock()
+	w.progress.Status = StatusDone
+	w.progress.FinishedAt = &finishedAt
+	w.mu.Unlock()
+	slog.Info("caches warmed up", "tasks", len(w.tasks), "duration", finishedAt.Sub(started))
+}
+
+func (w *Warmer) set(name string, progress TaskProgress) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.progress.Tasks[name] = progress
+	if progress.Status == StatusDone || progress.Status == StatusFailed {
+		w.progress.Completed++
+	}
+}
+
+// Progress returns a copy of the current progress
+func (w *Warmer) Progress() Progress {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	progress := w.progress
+	progress.Tasks = maps.Clone(w.progress.Tasks)
+	return progress
+}
+
+func (w *Warmer) Done() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.progress.Status == StatusDone
+}
+
+// Check is a critical readiness check failing until Run returned
+func (w *Warmer) Check(ctx context.Context) error {
+	if !w.Done() {
+		return ErrNotWarm
+	}
+	return nil
+}
+
+// Handler serves GET /startupz for a startupProbe: 503 with the progress while the caches warm up, 200 after
+func (w *Warmer) Handler(ctx echo.Context) error {
+	progress := w.Progress()
+	code := http.StatusOK
+	if progress.Status != StatusDone {
+		code = http.StatusServiceUnavailable
+	}
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(code, progress)
+}
@@ -0,0 +1,65 @@
+package warmup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestWarmer(t *testing.T) {
+	release := make(chan struct{})
+	warmer := New(time.Second,
+		Task{Name: "slow", Run: func(ctx context.Context) error { <-release; return nil }},
+		Task{Name: "broken", Run: func(ctx context.Context) error { return errors.New("database is down") }},
+	)
+	e := echo.New()
+	e.GET("/startupz", warmer.Handler)
+
+	done := make(chan struct{})
+	go func() {
+		warmer.Run(context.Background())
+		close(done)
+	}()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/startupz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/startupz answered %d while warming up", rec.Code)
+	}
+	if err := warmer.Check(context.Background()); !errors.Is(err, ErrNotWarm) {
+		t.Errorf("check passed while warming up: %v", err)
+	}
+
+	close(release)
+	<-done
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/startupz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/startupz answered %d after warming up", rec.Code)
+	}
+	// A failed task still lets the pod turn ready
+	progress := warmer.Progress()
+	if warmer.Check(context.Background()) != nil || progress.Completed != 2 || progress.Tasks["broken"].Status != StatusFailed {
+		t.Errorf("progress after warming up %+v", progress)
+	}
+}
+
+func TestWarmerTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	warmer := New(20*time.Millisecond, Task{Name: "stuck", Run: func(ctx context.Context) error { <-release; return nil }})
+
+	started := time.Now()
+	warmer.Run(context.Background())
+	if took := time.Since(started); took > time.Second {
+		t.Errorf("Run returned after %s, want the timeout to bound a task ignoring ctx", took)
+	}
+	if warmer.Check(context.Background()) != nil || warmer.Progress().Tasks["stuck"].Status == StatusDone {
+		t.Errorf("progress after the timeout %+v, want the pod ready with the task still running", warmer.Progress())
+	}
+}
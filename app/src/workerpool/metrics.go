@@ -0,0 +1,53 @@
+package workerpool
+
+import (
+	"app/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	poolSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "workerpool",
+		Name:      "workers",
+		Help:      "Configured number of workers.",
+	}, []string{"pool"})
+
+	busy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "workerpool",
+		Name:      "busy_workers",
+		Help:      "Workers currently running a task.",
+	}, []string{"pool"})
+
+	queueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "workerpool",
+		Name:      "queue_length",
+		Help:      "Tasks waiting for a worker.",
+	}, []string{"pool"})
+
+	tasks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "workerpool",
+		Name:      "tasks_total",
+		Help:      "Tasks run by result.",
+	}, []string{"pool", "result"})
+
+	rejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "workerpool",
+		Name:      "rejected_total",
+		Help:      "Tasks refused because the queue was full or the submitter gave up waiting.",
+	}, []string{"pool"})
+
+	waitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "workerpool",
+		Name:      "wait_seconds",
+		Help:      "Time tasks spent in the queue.",
+		Buckets:   []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10, 30},
+	}, []string{"pool"})
+)
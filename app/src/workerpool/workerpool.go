@@ -0,0 +1,178 @@
+package workerpool
+
+import (
+	"app/config"
+	"app/runtimeutil"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull = errors.New("worker pool queue is full")
+	ErrClosed    = errors.New("worker pool is closed")
+)
+
+// Policy decides what Submit does when the queue is full
+type Policy string
+
+const (
+	// Block waits for a free slot until the submitter's context is done
+	Block Policy = "block"
+	// Reject fails immediately with ErrQueueFull
+	Reject Policy = "reject"
+)
+
+type Config struct {
+	Size       int
+	QueueDepth int
+	Policy     Policy
+}
+
+// ConfigFromEnv reads <prefix>WORKERS, <prefix>QUEUE_DEPTH and <prefix>QUEUE_POLICY, falling back to def
+func ConfigFromEnv(prefix string, def Config) Config {
+	cfg := Config{
+		Size:       config.Int(prefix+"WORKERS", def.Size),
+		QueueDepth: config.Int(prefix+"QUEUE_DEPTH", def.QueueDepth),
+		Policy:     Policy(strings.ToLower(config.String(prefix+"QUEUE_POLICY", string(def.Policy)))),
+	}
+	if cfg.Policy != Block && cfg.Policy != Reject {
+		slog.Warn("invalid queue policy, blocking instead", "key", prefix+"QUEUE_POLICY", "value", cfg.Policy)
+		cfg.Policy = Block
+	}
+	return cfg
+}
+
+type Task func(ctx context.Context)
+
+type queued struct {
+	task       Task
+	enqueuedAt time.Time
+}
+
+// Pool runs submitted tasks on a fixed number of goroutines behind a bounded queue
+type Pool struct {
+	Name   string
+	Config Config
+
+	queue  chan queued
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func New(name string, cfg Config) *Pool {
+	if cfg.Size <= 0 {
+		cfg.Size = 1
+	}
+	if cfg.QueueDepth < 0 {
+		cfg.QueueDepth = 0
+	}
+	if cfg.Policy == "" {
+		cfg.Policy = Block
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		Name:   name,
+		Config: cfg,
+		queue:  make(chan queued, cfg.QueueDepth),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	poolSize.WithLabelValues(name).Set(float64(cfg.Size))
+	for i := 0; i < cfg.Size; i++ {
+		p.wg.Add(1)
+		runtimeutil.Critical("worker pool "+name, func(context.Context) error {
+			p.work()
+			return nil
+		})
+	}
+	slog.Info("worker pool started", "pool", name, "workers", cfg.Size, "queue_depth", cfg.QueueDepth, "policy", cfg.Policy)
+	return p
+}
+
+// Submit queues the task, applying the pool's backpressure policy when the queue is full
+func (p *Pool) Submit(ctx context.Context, task Task) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+
+	item := queued{task: task, enqueuedAt: time.Now()}
+	select {
+	case p.queue <- item:
+		queueLength.WithLabelValues(p.Name).Inc()
+		return nil
+	default:
+	}
+
+	if p.Config.Policy == Reject {
+		rejected.WithLabelValues(p.Name).Inc()
+		return ErrQueueFull
+	}
+	select {
+	case p.queue <- item:
+		queueLength.WithLabelValues(p.Name).Inc()
+		return nil
+	case <-ctx.Done():
+		rejected.WithLabelValues(p.Name).Inc()
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for item := range p.queue {
+		queueLength.WithLabelValues(p.Name).Dec()
+		waitDuration.WithLabelValues(p.Name).Observe(time.Since(item.enqueuedAt).Seconds())
+		p.run(item.task)
+	}
+}
+
+func (p *Pool) run(task Task) {
+	busy.WithLabelValues(p.Name).Inc()
+	defer busy.WithLabelValues(p.Name).Dec()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tasks.WithLabelValues(p.Name, "panic").Inc()
+			slog.Error("worker pool task panicked", "pool", p.Name, "panic", r)
+		}
+	}()
+	task(p.ctx)
+	tasks.WithLabelValues(p.Name, "done").Inc()
+}
+
+// Shutdown stops accepting tasks and waits for the queued ones to finish.
+// When ctx expires first, the context passed to running tasks is cancelled.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
@@ -1,3 +1,4 @@
+import base64
 import secrets
 import os
 import sys
@@ -74,9 +75,30 @@ def main():
     # database.env ファイルを生成
     db_password = create_database_env()
 
+    # Webhook 署名検証用のシークレットを自動生成
+    webhook_secret = generate_random_key(32)
+
+    # 管理者ログイン用のパスワードを自動生成
+    admin_password = generate_random_key(16)
+
+    # カラム暗号化用の AES-256 キーを自動生成 (先頭のキーが書き込みに使われる)
+    encryption_key = base64.b64encode(secrets.token_bytes(32)).decode()
+
     # app.env のテンプレート
     app_env_template = f"""
 DATABASE_URI="app:{db_password}@tcp(db:3306)/app?charset=utf8mb4&parseTime=True&loc=Local"
+WEBHOOK_SECRET_GITHUB="{webhook_secret}"
+WEBHOOK_SECRET_GENERIC="{webhook_secret}"
+SMTP_HOST="mailhog"
+SMTP_PORT="1025"
+SMTP_FROM="k8s-sample-app@example.com"
+NOTIFY_EMAIL_TO="admin@example.com"
+REDIS_URL="redis://redis:6379/0"
+ADMIN_USERNAME="admin"
+ADMIN_PASSWORD="{admin_password}"
+TRUSTED_PROXY_CIDRS="172.16.0.0/12,10.0.0.0/8"
+ENCRYPTION_KEYS="v1:{encryption_key}"
+ADMIN_TOKEN="{generate_random_key(32)}"
 """
 
     # app.env ファイルを生成
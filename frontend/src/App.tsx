@@ -33,6 +33,30 @@ type ChartData = {
 
 type ViewMode = "dashboard" | "network";
 
+type Announcement = {
+  message: string;
+  level: string;
+};
+
+type LiveStats = {
+  window_seconds: number;
+  rps: number;
+  in_flight: number;
+  error_rate: number;
+  db?: {
+    in_use: number;
+    open: number;
+    max_open: number;
+    usage: number;
+  };
+};
+
+const announcementStyles: Record<string, string> = {
+  info: "bg-blue-50 text-blue-800 border-blue-200",
+  warning: "bg-yellow-50 text-yellow-800 border-yellow-200",
+  critical: "bg-red-50 text-red-800 border-red-200",
+};
+
 function App() {
   const [viewMode, setViewMode] = useState<ViewMode>("dashboard");
   const [intervalMs, setIntervalMs] = useState<number>(1000);
@@ -45,9 +69,48 @@ function App() {
   });
   const [logs, setLogs] = useState<Log[]>([]);
   const [chartData, setChartData] = useState<ChartData[]>([]);
+  const [announcement, setAnnouncement] = useState<Announcement | null>(null);
+  const [liveStats, setLiveStats] = useState<LiveStats | null>(null);
   const logsContainerRef = useRef<HTMLDivElement>(null);
   const networkContainerRef = useRef<HTMLDivElement>(null);
 
+  // Poll the announcement banner (backed by a ConfigMap, updated without restarts)
+  useEffect(() => {
+    const load = async () => {
+      try {
+        const res = await fetch("/app/announcement");
+        if (res.ok) {
+          setAnnouncement(await res.json());
+        }
+      } catch {
+        // Keep the last banner when the backend is unreachable
+      }
+    };
+    load();
+    const timer = setInterval(load, 10000);
+    return () => clearInterval(timer);
+  }, []);
+
+  // Poll the live stats of the pod serving the UI, counted in-process without Prometheus
+  useEffect(() => {
+    if (viewMode !== "dashboard") {
+      return;
+    }
+    const load = async () => {
+      try {
+        const res = await fetch("/app/stats/live");
+        if (res.ok) {
+          setLiveStats(await res.json());
+        }
+      } catch {
+        // Keep the last values when the backend is unreachable
+      }
+    };
+    load();
+    const timer = setInterval(load, 2000);
+    return () => clearInterval(timer);
+  }, [viewMode]);
+
   // Auto-scroll logs (Dashboard)
   useEffect(() => {
     if (viewMode === "dashboard" && logsContainerRef.current) {
@@ -193,6 +256,17 @@ function App() {
 
   return (
     <div className="min-h-screen bg-gray-50 flex flex-col font-sans">
+      {/* Announcement Banner */}
+      {announcement?.message && (
+        <div
+          className={`px-4 py-2 sm:px-6 text-sm border-b ${
+            announcementStyles[announcement.level] ?? announcementStyles.info
+          }`}
+        >
+          {announcement.message}
+        </div>
+      )}
+
       {/* Top Navigation Bar */}
       <div className="bg-white border-b border-gray-200 px-4 py-3 sm:px-6 flex flex-col md:flex-row items-center justify-between gap-4 sticky top-0 z-10 shadow-sm">
         <div className="flex items-center space-x-4">
@@ -290,6 +364,41 @@ function App() {
               />
             </div>
 
+            {/* Server Stats, from one pod behind the Service */}
+            {liveStats && (
+              <div>
+                <h3 className="text-sm font-medium text-gray-500 mb-2">
+                  Server (last {liveStats.window_seconds}s)
+                </h3>
+                <div className="grid grid-cols-2 md:grid-cols-4 gap-4">
+                  <StatCard
+                    title="Requests/s"
+                    value={liveStats.rps.toFixed(1)}
+                    color="bg-blue-50 text-blue-700"
+                  />
+                  <StatCard
+                    title="In Flight"
+                    value={liveStats.in_flight}
+                    color="bg-indigo-50 text-indigo-700"
+                  />
+                  <StatCard
+                    title="Server Error Rate"
+                    value={`${(liveStats.error_rate * 100).toFixed(2)}%`}
+                    color="bg-yellow-50 text-yellow-700"
+                  />
+                  <StatCard
+                    title="DB Pool"
+                    value={
+                      liveStats.db
+                        ? `${liveStats.db.in_use}/${liveStats.db.max_open || liveStats.db.open}`
+                        : "-"
+                    }
+                    color="bg-green-50 text-green-700"
+                  />
+                </div>
+              </div>
+            )}
+
             {/* Charts & Logs Container */}
             <div className="grid grid-cols-1 lg:grid-cols-2 gap-8">
               {/* Latency Chart */}